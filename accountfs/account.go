@@ -0,0 +1,293 @@
+// Package accountfs provides a billy filesystem wrapper accounting the
+// operations done and the bytes transferred to a tenant, such as the
+// customer of a multi-tenant service, for billing and chargeback.
+package accountfs // import "srcd.works/go-billy.v1/accountfs"
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/runner"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the given tenant key.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant key carried by ctx, or an empty string if none.
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Usage is the usage attributed to a tenant.
+type Usage struct {
+	// Ops is the number of filesystem operations, opening a file is one,
+	// but reading or writing it isn't.
+	Ops uint64
+	// BytesRead is the number of bytes read from the files.
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to the files.
+	BytesWritten uint64
+}
+
+// MeterOptions holds the configuration of a Meter.
+type MeterOptions struct {
+	// Interval is the period of the exports, one minute by default.
+	Interval time.Duration
+	// Export, if not nil, is called periodically, and on Close, with the
+	// usage of every tenant since the previous export. The tenants without
+	// usage are not included.
+	Export func(map[string]Usage)
+}
+
+// Meter accumulates the usage of the tenants. It's safe for concurrent use.
+type Meter struct {
+	opts MeterOptions
+
+	m     sync.Mutex
+	usage map[string]*Usage
+	r     *runner.Runner
+}
+
+// NewMeter returns a new Meter, exporting the usage periodically if an Export
+// function is given. If opts is nil the default options are used, as for
+// their zero fields.
+func NewMeter(opts *MeterOptions) *Meter {
+	m := &Meter{
+		usage: make(map[string]*Usage),
+		r:     runner.New(nil),
+	}
+
+	if opts != nil {
+		m.opts = *opts
+	}
+
+	if m.opts.Interval <= 0 {
+		m.opts.Interval = time.Minute
+	}
+
+	if m.opts.Export != nil {
+		m.r.Every("export", m.opts.Interval, func(ctx context.Context) error {
+			m.Flush()
+			return nil
+		})
+	}
+
+	return m
+}
+
+// Usage returns the usage of every tenant since the previous export.
+func (m *Meter) Usage() map[string]Usage {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	usage := make(map[string]Usage, len(m.usage))
+	for tenant, u := range m.usage {
+		usage[tenant] = *u
+	}
+
+	return usage
+}
+
+// Flush exports the usage accumulated, if an Export function was given, and
+// resets it.
+func (m *Meter) Flush() {
+	m.m.Lock()
+	usage := make(map[string]Usage, len(m.usage))
+	for tenant, u := range m.usage {
+		usage[tenant] = *u
+	}
+
+	m.usage = make(map[string]*Usage)
+	m.m.Unlock()
+
+	if m.opts.Export != nil && len(usage) != 0 {
+		m.opts.Export(usage)
+	}
+}
+
+// Close stops the periodic exports, exporting the remaining usage.
+func (m *Meter) Close() error {
+	m.r.Close()
+	m.Flush()
+	return nil
+}
+
+func (m *Meter) add(tenant string, ops, read, written uint64) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	u, ok := m.usage[tenant]
+	if !ok {
+		u = &Usage{}
+		m.usage[tenant] = u
+	}
+
+	u.Ops += ops
+	u.BytesRead += read
+	u.BytesWritten += written
+}
+
+// Accounting wraps a billy.Filesystem attributing its usage to a tenant.
+type Accounting struct {
+	fs     billy.Filesystem
+	m      *Meter
+	tenant string
+}
+
+// New returns a new Accounting filesystem wrapping fs, attributing the usage
+// to the tenant carried by ctx, as set by WithTenant, in m. A filesystem is
+// usually created for every request, the context isn't used otherwise.
+func New(ctx context.Context, fs billy.Filesystem, m *Meter) *Accounting {
+	return &Accounting{fs: fs, m: m, tenant: Tenant(ctx)}
+}
+
+func (fs *Accounting) op() {
+	fs.m.add(fs.tenant, 1, 0, 0)
+}
+
+func (fs *Accounting) file(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+// Create creates the named file.
+func (fs *Accounting) Create(filename string) (billy.File, error) {
+	fs.op()
+	return fs.file(fs.fs.Create(filename))
+}
+
+// Open opens the named file for reading.
+func (fs *Accounting) Open(filename string) (billy.File, error) {
+	fs.op()
+	return fs.file(fs.fs.Open(filename))
+}
+
+// OpenFile opens the named file with the given flag and permissions.
+func (fs *Accounting) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fs.op()
+	return fs.file(fs.fs.OpenFile(filename, flag, perm))
+}
+
+// Stat returns the FileInfo structure describing file.
+func (fs *Accounting) Stat(filename string) (billy.FileInfo, error) {
+	fs.op()
+	return fs.fs.Stat(filename)
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory.
+func (fs *Accounting) ReadDir(path string) ([]billy.FileInfo, error) {
+	fs.op()
+	return fs.fs.ReadDir(path)
+}
+
+// TempFile creates a temporary file.
+func (fs *Accounting) TempFile(dir, prefix string) (billy.File, error) {
+	fs.op()
+	return fs.file(fs.fs.TempFile(dir, prefix))
+}
+
+// Rename renames a file.
+func (fs *Accounting) Rename(from, to string) error {
+	fs.op()
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file.
+func (fs *Accounting) Remove(filename string) error {
+	fs.op()
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link.
+func (fs *Accounting) Symlink(target, link string) error {
+	fs.op()
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Accounting) Readlink(link string) (string, error) {
+	fs.op()
+	return fs.fs.Readlink(link)
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links.
+func (fs *Accounting) Lstat(filename string) (billy.FileInfo, error) {
+	fs.op()
+	return fs.fs.Lstat(filename)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *Accounting) MkdirAll(path string, perm os.FileMode) error {
+	fs.op()
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Accounting) Chmod(name string, mode os.FileMode) error {
+	fs.op()
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Accounting) Chtimes(name string, atime, mtime time.Time) error {
+	fs.op()
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Accounting) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Accounting filesystem rooted at the given path,
+// attributing the usage to the same tenant.
+func (fs *Accounting) Dir(path string) billy.Filesystem {
+	return &Accounting{fs: fs.fs.Dir(path), m: fs.m, tenant: fs.tenant}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Accounting) Base() string {
+	return fs.fs.Base()
+}
+
+// file is a file accounting the bytes read and written.
+type file struct {
+	billy.File
+	fs *Accounting
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.fs.m.add(f.fs.tenant, 0, uint64(n), 0)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	n, err := r.ReadAt(p, off)
+	f.fs.m.add(f.fs.tenant, 0, uint64(n), 0)
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.fs.m.add(f.fs.tenant, 0, 0, uint64(n))
+	return n, err
+}
@@ -0,0 +1,73 @@
+package accountfs
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AccountingSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&AccountingSuite{})
+
+func (s *AccountingSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(context.Background(), memory.New(), NewMeter(nil))
+}
+
+func (s *AccountingSuite) TestUsage(c *C) {
+	m := NewMeter(nil)
+	mem := memory.New()
+	foo := New(WithTenant(context.Background(), "foo"), mem, m)
+	bar := New(WithTenant(context.Background(), "bar"), mem, m)
+
+	f, err := foo.Create("qux")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = bar.Dir("/").Open("qux")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+
+	_, err = bar.Stat("qux")
+	c.Assert(err, IsNil)
+
+	c.Assert(m.Usage(), DeepEquals, map[string]Usage{
+		"foo": {Ops: 1, BytesWritten: 3},
+		"bar": {Ops: 2, BytesRead: 3},
+	})
+}
+
+func (s *AccountingSuite) TestExport(c *C) {
+	exported := make(chan map[string]Usage, 10)
+	m := NewMeter(&MeterOptions{
+		Interval: 10 * time.Millisecond,
+		Export:   func(u map[string]Usage) { exported <- u },
+	})
+
+	fs := New(WithTenant(context.Background(), "foo"), memory.New(), m)
+	_, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+
+	c.Assert(<-exported, DeepEquals, map[string]Usage{"foo": {Ops: 1}})
+	c.Assert(m.Usage(), HasLen, 0)
+
+	_, err = fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(m.Close(), IsNil)
+
+	c.Assert(<-exported, DeepEquals, map[string]Usage{"foo": {Ops: 1}})
+}
@@ -0,0 +1,364 @@
+// Package allowfs provides a read-only billy filesystem wrapper exposing only
+// the paths of an allowlist, to sandbox the plugins given a
+// billy.Filesystem.
+package allowfs // import "srcd.works/go-billy.v1/allowfs"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Allow wraps a billy.Filesystem exposing, read-only, only the files matching
+// the patterns of an allowlist. The patterns are slash separated paths
+// relative to the root, matched with path.Match, so they can hold wildcards,
+// such as docs/*.md, and a directory matched exposes its whole tree. The
+// parents of the allowed paths are visible too, listing only the entries
+// leading to them, so the allowed files can be reached walking from the
+// root. Any other path returns os.ErrNotExist, as if it didn't exist, and
+// any write returns billy.ErrReadOnly.
+//
+// The symbolic links are resolved by the wrapper, the absolute targets being
+// relative to the root, and the paths reached through them must be allowed
+// as well, so they can't escape the allowlist.
+type Allow struct {
+	fs       billy.Filesystem
+	patterns [][]string
+	// prefix is the path of the root of the filesystem in fs.
+	prefix string
+}
+
+// New returns a new Allow filesystem wrapping fs, exposing the paths matching
+// the given patterns. It returns an error if any pattern is malformed.
+func New(fs billy.Filesystem, patterns ...string) (*Allow, error) {
+	a := &Allow{fs: fs}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("allowfs: invalid pattern %q: %s", p, err)
+		}
+
+		a.patterns = append(a.patterns, split(clean(p)))
+	}
+
+	return a, nil
+}
+
+// Create returns billy.ErrReadOnly.
+func (fs *Allow) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// Open opens the named file for reading, if allowed.
+func (fs *Allow) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, if allowed, returns billy.ErrReadOnly if
+// flag requests any kind of write access.
+func (fs *Allow) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if isWrite(flag) {
+		return nil, billy.ErrReadOnly
+	}
+
+	key, _, err := fs.lookup("open", filename, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.fs.OpenFile(key, flag, perm)
+	if err != nil {
+		return nil, fs.pathError("open", err, filename)
+	}
+
+	return &file{File: f, name: filename}, nil
+}
+
+// Stat returns the FileInfo of the named file, if allowed.
+func (fs *Allow) Stat(filename string) (billy.FileInfo, error) {
+	_, fi, err := fs.lookup("stat", filename, true)
+	return fi, err
+}
+
+// Lstat returns the FileInfo of the named file, if allowed, without
+// following symbolic links.
+func (fs *Allow) Lstat(filename string) (billy.FileInfo, error) {
+	_, fi, err := fs.lookup("lstat", filename, false)
+	return fi, err
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory, if
+// allowed, only with the entries allowed or leading to them.
+func (fs *Allow) ReadDir(dir string) ([]billy.FileInfo, error) {
+	key, _, err := fs.lookup("readdir", dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.fs.ReadDir(key)
+	if err != nil || fs.allowed(key) {
+		return entries, fs.pathError("readdir", err, dir)
+	}
+
+	var visible []billy.FileInfo
+	for _, fi := range entries {
+		if fs.visible(path.Join(key, fi.Name())) {
+			visible = append(visible, fi)
+		}
+	}
+
+	return visible, nil
+}
+
+// TempFile returns billy.ErrReadOnly.
+func (fs *Allow) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// Rename returns billy.ErrReadOnly.
+func (fs *Allow) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+// Remove returns billy.ErrReadOnly.
+func (fs *Allow) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+// Symlink returns billy.ErrReadOnly.
+func (fs *Allow) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+// Readlink returns the target of the named symbolic link, if allowed.
+func (fs *Allow) Readlink(link string) (string, error) {
+	key, _, err := fs.lookup("readlink", link, false)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := fs.fs.Readlink(key)
+	return target, fs.pathError("readlink", err, link)
+}
+
+// MkdirAll returns billy.ErrReadOnly.
+func (fs *Allow) MkdirAll(path string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Chmod returns billy.ErrReadOnly.
+func (fs *Allow) Chmod(name string, mode os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Chtimes returns billy.ErrReadOnly.
+func (fs *Allow) Chtimes(name string, atime, mtime time.Time) error {
+	return billy.ErrReadOnly
+}
+
+// Ping checks the underlying filesystem, as billy.Ping.
+func (fs *Allow) Ping(ctx context.Context) error {
+	_, err := billy.Ping(ctx, fs.fs)
+	return err
+}
+
+// Flush flushes the underlying filesystem, as billy.Flush.
+func (fs *Allow) Flush(ctx context.Context) error {
+	return billy.Flush(ctx, fs.fs)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Allow) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close.
+func (fs *Allow) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Allow) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Allow filesystem rooted at the given path, with the same
+// allowlist, still relative to the root of the current one.
+func (fs *Allow) Dir(p string) billy.Filesystem {
+	return &Allow{fs: fs.fs, patterns: fs.patterns, prefix: fs.key(p)}
+}
+
+// Base returns the base path of the filesystem.
+func (fs *Allow) Base() string {
+	return path.Join(fs.fs.Base(), fs.prefix)
+}
+
+// lookup resolves the named file, following the symbolic links of its
+// parents, and of the file itself if follow is true, returning its path in
+// the underlying filesystem and its FileInfo. The errors, as the paths not
+// allowed, are *os.PathError for the given operation.
+func (fs *Allow) lookup(op, filename string, follow bool) (string, billy.FileInfo, error) {
+	notExist := &os.PathError{Op: op, Path: filename, Err: os.ErrNotExist}
+
+	if !fs.visible("") {
+		return "", nil, notExist
+	}
+
+	parts := split(fs.key(filename))
+	var resolved string
+	var fi billy.FileInfo
+	for hops, max := 0, billy.MaxLinks(fs.fs); len(parts) != 0; {
+		p := path.Join(resolved, parts[0])
+		parts = parts[1:]
+		if !fs.visible(p) {
+			return "", nil, notExist
+		}
+
+		var err error
+		if fi, err = fs.fs.Lstat(p); err != nil {
+			return "", nil, fs.pathError(op, err, filename)
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 || (len(parts) == 0 && !follow) {
+			resolved = p
+			continue
+		}
+
+		if hops++; hops > max {
+			return "", nil, &os.PathError{Op: op, Path: filename, Err: billy.ErrTooManyLinks}
+		}
+
+		target, err := fs.fs.Readlink(p)
+		if err != nil {
+			return "", nil, fs.pathError(op, err, filename)
+		}
+
+		target = slash(target)
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+
+		resolved, fi = "", nil
+		parts = append(split(clean(target)), parts...)
+	}
+
+	if fi == nil {
+		var err error
+		if fi, err = fs.fs.Stat(resolved); err != nil {
+			return "", nil, fs.pathError(op, err, filename)
+		}
+	}
+
+	// the parents of the allowed paths are only visible as directories.
+	if !fs.allowed(resolved) && !fi.IsDir() {
+		return "", nil, notExist
+	}
+
+	return resolved, fi, nil
+}
+
+// allowed returns true if key, or any of its parents, matches a pattern.
+func (fs *Allow) allowed(key string) bool {
+	parts := split(key)
+	for _, pattern := range fs.patterns {
+		if len(pattern) <= len(parts) && match(pattern, parts[:len(pattern)]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// visible returns true if key is allowed or a parent of the allowed paths.
+func (fs *Allow) visible(key string) bool {
+	return fs.allowed(key) || fs.isParent(key)
+}
+
+// isParent returns true if key is a parent of the paths matching any
+// pattern.
+func (fs *Allow) isParent(key string) bool {
+	parts := split(key)
+	for _, pattern := range fs.patterns {
+		if len(parts) < len(pattern) && match(pattern[:len(parts)], parts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathError returns err, from the underlying filesystem, as a *os.PathError
+// for the given operation and filename, so the paths in the underlying
+// filesystem aren't revealed.
+func (fs *Allow) pathError(op string, err error, filename string) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+
+	return &os.PathError{Op: op, Path: filename, Err: err}
+}
+
+// key returns the path of filename in the underlying filesystem.
+func (fs *Allow) key(filename string) string {
+	return clean(path.Join(fs.prefix, clean(filename)))
+}
+
+// match returns true if every part matches the pattern at the same position.
+func match(pattern, parts []string) bool {
+	for i, p := range pattern {
+		if ok, _ := path.Match(p, parts[i]); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func split(key string) []string {
+	if key == "" {
+		return nil
+	}
+
+	return strings.Split(key, "/")
+}
+
+func slash(filename string) string {
+	return strings.Replace(filename, `\`, "/", -1)
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+slash(p)), "/")
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// file is a file open for reading, named as given to Open.
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Filename() string {
+	return f.name
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
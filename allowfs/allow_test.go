@@ -0,0 +1,175 @@
+package allowfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AllowSuite struct {
+	under billy.Filesystem
+	fs    *Allow
+}
+
+var _ = Suite(&AllowSuite{})
+
+func (s *AllowSuite) SetUpTest(c *C) {
+	s.under = memory.New()
+	for _, name := range []string{"README.md", "secret", "docs/foo.md", "docs/foo.txt", "docs/api/bar.md", "data/qux", "data/sub/baz"} {
+		writeFile(c, s.under, name, name)
+	}
+
+	var err error
+	s.fs, err = New(s.under, "README.md", "docs/*.md", "/data/")
+	c.Assert(err, IsNil)
+}
+
+func (s *AllowSuite) TestRead(c *C) {
+	for _, name := range []string{"README.md", "docs/foo.md", "data/qux", "data/sub/baz", "/docs/../data/qux"} {
+		c.Assert(readFile(c, s.fs, name), Equals, clean(name), Commentf(name))
+	}
+
+	for _, name := range []string{"secret", "docs/foo.txt", "docs/api/bar.md", "other"} {
+		_, err := s.fs.Open(name)
+		c.Assert(os.IsNotExist(err), Equals, true, Commentf(name))
+		_, err = s.fs.Stat(name)
+		c.Assert(os.IsNotExist(err), Equals, true, Commentf(name))
+		c.Assert(err.(*os.PathError).Path, Equals, name)
+	}
+
+	f, err := s.fs.Open("docs/foo.md")
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, "docs/foo.md")
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *AllowSuite) TestReadDir(c *C) {
+	c.Assert(readDirNames(c, s.fs, ""), DeepEquals, []string{"README.md", "data", "docs"})
+	c.Assert(readDirNames(c, s.fs, "docs"), DeepEquals, []string{"foo.md"})
+	c.Assert(readDirNames(c, s.fs, "data"), DeepEquals, []string{"qux", "sub"})
+
+	fi, err := s.fs.Stat("docs")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	_, err = s.fs.ReadDir("docs/api")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *AllowSuite) TestParentFile(c *C) {
+	writeFile(c, s.under, "file", "file")
+	fs, err := New(s.under, "file/foo")
+	c.Assert(err, IsNil)
+
+	// a file can't be the parent of the allowed paths.
+	_, err = fs.Stat("file")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"file"})
+}
+
+func (s *AllowSuite) TestSymlinks(c *C) {
+	c.Assert(s.under.Symlink("../secret", "data/escape"), IsNil)
+	c.Assert(s.under.Symlink("/docs/foo.md", "data/doc"), IsNil)
+	c.Assert(s.under.Symlink("data", "link"), IsNil)
+	c.Assert(s.under.Symlink("loop", "data/loop"), IsNil)
+
+	_, err := s.fs.Open("data/escape")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(readFile(c, s.fs, "data/doc"), Equals, "docs/foo.md")
+
+	fi, err := s.fs.Lstat("data/escape")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+	target, err := s.fs.Readlink("data/escape")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "../secret")
+
+	// the links must be allowed themselves, not only their targets.
+	_, err = s.fs.Open("link/qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = s.fs.Stat("data/loop")
+	c.Assert(err.(*os.PathError).Err, Equals, billy.ErrTooManyLinks)
+}
+
+func (s *AllowSuite) TestWrite(c *C) {
+	_, err := s.fs.Create("README.md")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	_, err = s.fs.OpenFile("data/qux", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	_, err = s.fs.TempFile("data", "foo")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+
+	c.Assert(s.fs.Rename("data/qux", "data/bar"), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Remove("data/qux"), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.MkdirAll("data/new", 0755), Equals, billy.ErrReadOnly)
+}
+
+func (s *AllowSuite) TestDir(c *C) {
+	dir := s.fs.Dir("docs")
+	c.Assert(dir.Base(), Equals, "/docs")
+	c.Assert(readFile(c, dir, "foo.md"), Equals, "docs/foo.md")
+	c.Assert(readFile(c, dir, "../foo.md"), Equals, "docs/foo.md")
+
+	_, err := dir.Open("foo.txt")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = dir.Open("../data/qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *AllowSuite) TestInvalidPattern(c *C) {
+	_, err := New(s.under, "docs/[")
+	c.Assert(err, ErrorMatches, `allowfs: invalid pattern "docs/\[".*`)
+}
+
+func (s *AllowSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend:  "mem://allowfs",
+		Wrappers: []billy.WrapperConfig{{Name: "allow", Options: map[string]string{"paths": "foo,docs/*.md"}}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fs, FitsTypeOf, &Allow{})
+	c.Assert(fs.(*Allow).patterns, DeepEquals, [][]string{{"foo"}, {"docs", "*.md"}})
+
+	_, err = billy.Compose(&billy.Config{
+		Backend:  "mem://allowfs",
+		Wrappers: []billy.WrapperConfig{{Name: "allow"}},
+	})
+	c.Assert(err, ErrorMatches, `.*missing paths option`)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return string(b)
+}
+
+func readDirNames(c *C, fs billy.Filesystem, dir string) []string {
+	infos, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
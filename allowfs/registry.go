@@ -0,0 +1,28 @@
+package allowfs
+
+import (
+	"errors"
+	"strings"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("allow", wrap)
+}
+
+// wrap returns an Allow filesystem wrapping fs, exposing the comma separated
+// patterns of the paths option.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	paths, ok := opts["paths"]
+	if !ok {
+		return nil, errors.New("missing paths option")
+	}
+
+	a, err := New(fs, strings.Split(paths, ",")...)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,217 @@
+package billy
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AppenderOptions describes when the file written by an Appender is rotated.
+type AppenderOptions struct {
+	// MaxSize, if greater than zero, rotates the file before a write that
+	// would make it exceed this size. A record bigger than MaxSize is written
+	// alone in a new file.
+	MaxSize int64
+	// MaxAge, if greater than zero, rotates the file when it was opened, by
+	// the first Appender of the path in the Filesystem, more than MaxAge ago.
+	MaxAge time.Duration
+	// Rotate is called to rotate the file, once it is closed, the following
+	// writes go to a new file at the same path. If nil, RotateTimestamp is
+	// used.
+	Rotate func(fs Filesystem, path string) error
+}
+
+// RotateTimestamp renames the file appending the current time to its name.
+func RotateTimestamp(fs Filesystem, path string) error {
+	return fs.Rename(path, path+"."+time.Now().Format("20060102T150405.000000000"))
+}
+
+// Appender writes whole records at the end of a file, being safe to use from
+// several goroutines, and by several Appenders of the same path in the
+// process. Every Write is a record, written at once while holding a lock
+// shared by the Appenders of the path in the same Filesystem, so the records
+// are never interleaved.
+// The file is opened with os.O_APPEND, so the appends are also safe against
+// other processes on the backends supporting it.
+type Appender struct {
+	fs         Filesystem
+	path       string
+	opts       *AppenderOptions
+	key        appendKey
+	lock       *appendLock
+	f          File
+	generation int
+	closed     bool
+}
+
+// appendKey identifies the path of an Appender in its Filesystem, so the
+// Appenders of different filesystems sharing a base never share a lock.
+type appendKey struct {
+	fs   Filesystem
+	path string
+}
+
+// appendLock is shared by all the Appenders of a path.
+type appendLock struct {
+	sync.Mutex
+	refs       int
+	generation int
+	created    time.Time
+}
+
+var appendLocks = struct {
+	sync.Mutex
+	paths map[appendKey]*appendLock
+}{paths: make(map[appendKey]*appendLock)}
+
+// OpenAppender returns an Appender writing to the given path, the file is
+// created if needed. If opts is nil the file is never rotated.
+func OpenAppender(fs Filesystem, path string, opts *AppenderOptions) (*Appender, error) {
+	if opts == nil {
+		opts = &AppenderOptions{}
+	}
+
+	a := &Appender{fs: fs, path: path, opts: opts}
+	a.key = appendKey{fs: fs, path: fs.Join(fs.Base(), path)}
+	a.lock = acquireAppendLock(a.key)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err := a.open(); err != nil {
+		releaseAppendLock(a.key)
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Write appends p to the file as a single record, rotating the file first if
+// needed.
+func (a *Appender) Write(p []byte) (int, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return 0, ErrClosed
+	}
+
+	// the file was rotated by other Appender, or a previous rotation failed.
+	if a.f == nil || a.generation != a.lock.generation {
+		if err := a.reopen(); err != nil {
+			return 0, err
+		}
+	}
+
+	size, err := a.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if a.mustRotate(size, len(p)) {
+		if err := a.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	return a.f.Write(p)
+}
+
+// Close closes the file, the Appender can't be used after.
+func (a *Appender) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return ErrClosed
+	}
+
+	a.closed = true
+	releaseAppendLock(a.key)
+	if a.f == nil {
+		return nil
+	}
+
+	return a.f.Close()
+}
+
+func (a *Appender) mustRotate(size int64, n int) bool {
+	if size == 0 {
+		return false
+	}
+
+	if a.opts.MaxSize > 0 && size+int64(n) > a.opts.MaxSize {
+		return true
+	}
+
+	return a.opts.MaxAge > 0 && time.Since(a.lock.created) >= a.opts.MaxAge
+}
+
+func (a *Appender) rotate() error {
+	f := a.f
+	a.f = nil
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	rotate := a.opts.Rotate
+	if rotate == nil {
+		rotate = RotateTimestamp
+	}
+
+	if err := rotate(a.fs, a.path); err != nil {
+		return err
+	}
+
+	a.lock.generation++
+	a.lock.created = time.Now()
+	return a.open()
+}
+
+func (a *Appender) reopen() error {
+	if a.f != nil {
+		f := a.f
+		a.f = nil
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return a.open()
+}
+
+func (a *Appender) open() error {
+	f, err := a.fs.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	a.f = f
+	a.generation = a.lock.generation
+	return nil
+}
+
+func acquireAppendLock(key appendKey) *appendLock {
+	appendLocks.Lock()
+	defer appendLocks.Unlock()
+
+	l, ok := appendLocks.paths[key]
+	if !ok {
+		l = &appendLock{created: time.Now()}
+		appendLocks.paths[key] = l
+	}
+
+	l.refs++
+	return l
+}
+
+func releaseAppendLock(key appendKey) {
+	appendLocks.Lock()
+	defer appendLocks.Unlock()
+
+	l := appendLocks.paths[key]
+	if l.refs--; l.refs == 0 {
+		delete(appendLocks.paths, key)
+	}
+}
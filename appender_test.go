@@ -0,0 +1,122 @@
+package billy_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type AppenderSuite struct{}
+
+var _ = Suite(&AppenderSuite{})
+
+func (s *AppenderSuite) TestAppend(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "log", "foo\n")
+
+	a, err := billy.OpenAppender(fs, "log", nil)
+	c.Assert(err, IsNil)
+	b, err := billy.OpenAppender(fs, "log", nil)
+	c.Assert(err, IsNil)
+
+	_, err = a.Write([]byte("bar\n"))
+	c.Assert(err, IsNil)
+	_, err = b.Write([]byte("baz\n"))
+	c.Assert(err, IsNil)
+	_, err = a.Write([]byte("qux\n"))
+	c.Assert(err, IsNil)
+
+	c.Assert(a.Close(), IsNil)
+	c.Assert(b.Close(), IsNil)
+	c.Assert(a.Close(), Equals, billy.ErrClosed)
+
+	_, err = a.Write([]byte("foo\n"))
+	c.Assert(err, Equals, billy.ErrClosed)
+
+	c.Assert(readFile(c, fs, "log"), Equals, "foo\nbar\nbaz\nqux\n")
+}
+
+func (s *AppenderSuite) TestRotateMaxSize(c *C) {
+	fs := memory.New()
+
+	var rotated []string
+	opts := &billy.AppenderOptions{
+		MaxSize: 8,
+		Rotate: func(fs billy.Filesystem, path string) error {
+			name := fmt.Sprintf("%s.%d", path, len(rotated))
+			rotated = append(rotated, name)
+			return fs.Rename(path, name)
+		},
+	}
+
+	a, err := billy.OpenAppender(fs, "log", opts)
+	c.Assert(err, IsNil)
+	b, err := billy.OpenAppender(fs, "log", opts)
+	c.Assert(err, IsNil)
+
+	for _, r := range []string{"foo\n", "bar\n", "baz\n", "too long\n", "qux\n"} {
+		_, err = a.Write([]byte(r))
+		c.Assert(err, IsNil)
+		a, b = b, a
+	}
+
+	c.Assert(a.Close(), IsNil)
+	c.Assert(b.Close(), IsNil)
+
+	c.Assert(rotated, DeepEquals, []string{"log.0", "log.1", "log.2"})
+	c.Assert(readFile(c, fs, "log.0"), Equals, "foo\nbar\n")
+	c.Assert(readFile(c, fs, "log.1"), Equals, "baz\n")
+	c.Assert(readFile(c, fs, "log.2"), Equals, "too long\n")
+	c.Assert(readFile(c, fs, "log"), Equals, "qux\n")
+}
+
+func (s *AppenderSuite) TestAppendFilesystems(c *C) {
+	opts := &billy.AppenderOptions{MaxAge: 50 * time.Millisecond}
+	a, err := billy.OpenAppender(memory.New(), "log", opts)
+	c.Assert(err, IsNil)
+	defer a.Close()
+
+	time.Sleep(60 * time.Millisecond)
+
+	fs := memory.New()
+	b, err := billy.OpenAppender(fs, "log", opts)
+	c.Assert(err, IsNil)
+	_, err = b.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	_, err = b.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(b.Close(), IsNil)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(readFile(c, fs, "log"), Equals, "foobar")
+}
+
+func (s *AppenderSuite) TestRotateTimestamp(c *C) {
+	fs := memory.New()
+	a, err := billy.OpenAppender(fs, "log", &billy.AppenderOptions{MaxSize: 1})
+	c.Assert(err, IsNil)
+
+	_, err = a.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	_, err = a.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(a.Close(), IsNil)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	for _, fi := range infos {
+		if fi.Name() != "log" {
+			c.Assert(strings.HasPrefix(fi.Name(), "log."), Equals, true)
+			c.Assert(readFile(c, fs, fi.Name()), Equals, "foo")
+		}
+	}
+
+	c.Assert(readFile(c, fs, "log"), Equals, "bar")
+}
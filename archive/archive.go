@@ -0,0 +1,174 @@
+// Package archive writes the trees of the billy filesystems as tar and zip
+// archives, kept apart from the billy package so the programs not using them
+// don't link the archive and compression packages.
+package archive // import "srcd.works/go-billy.v1/archive"
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+
+	"srcd.works/go-billy.v1"
+)
+
+// WriteTar walks the tree rooted at root and writes it to w as a tar archive,
+// with the names relative to root. The modes, the modification times and the
+// symbolic links are kept, as the ownership if reported by the backend, the
+// sockets are skipped. The files with holes, reported by billy.Sparse, are
+// stored as GNU sparse files without their holes. The archive is streamed as
+// the tree is walked.
+func WriteTar(fs billy.Filesystem, w io.Writer, root string) error {
+	tw := tar.NewWriter(w)
+	err := walkArchive(fs, root, func(path, name string, info billy.FileInfo, target string) error {
+		if info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+
+		h, err := tar.FileInfoHeader(info, target)
+		if err != nil {
+			return err
+		}
+
+		h.Name = name
+		if info.Mode().IsRegular() {
+			return writeTarFile(tw, w, fs, path, h)
+		}
+
+		return tw.WriteHeader(h)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// writeTarFile writes to tw the regular file of h, as a sparse file if it
+// has holes, written directly to w, the writer under tw.
+func writeTarFile(tw *tar.Writer, w io.Writer, fs billy.Filesystem, path string, h *tar.Header) error {
+	f, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	if extents, ok := sparseExtents(f, h.Size); ok && len(h.Uname) <= 32 && len(h.Gname) <= 32 {
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		return writeSparse(w, h, f, extents)
+	}
+
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// WriteZip walks the tree rooted at root and writes it to w as a zip
+// archive, with the names relative to root and the content of the files
+// compressed. The modes, the modification times and the symbolic links are
+// kept, the other special files are skipped. The archive is streamed as the
+// tree is walked.
+func WriteZip(fs billy.Filesystem, w io.Writer, root string) error {
+	zw := zip.NewWriter(w)
+	err := walkArchive(fs, root, func(path, name string, info billy.FileInfo, target string) error {
+		isLink := info.Mode()&os.ModeSymlink != 0
+		if !info.IsDir() && !info.Mode().IsRegular() && !isLink {
+			return nil
+		}
+
+		h, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+
+		h.Name = name
+		if info.Mode().IsRegular() {
+			h.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+
+		if isLink {
+			// the zip archives keep the targets as the content of the links.
+			_, err := io.WriteString(fw, target)
+			return err
+		}
+
+		return copyArchived(fs, fw, path, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// archiveFunc is called by walkArchive for every file, with its path, its
+// name in the archive and the target of the symbolic links.
+type archiveFunc func(path, name string, info billy.FileInfo, target string) error
+
+// walkArchive walks the tree rooted at root calling fn for every file but
+// the root, with the names relative to it and slash separated, the ones of
+// the directories ending with a slash. If root is not a directory it's
+// named by its base name.
+func walkArchive(fs billy.Filesystem, root string, fn archiveFunc) error {
+	return billy.Walk(fs, root, func(path string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(filepath.Clean("/"+root), filepath.Clean("/"+path))
+		if err != nil {
+			return err
+		}
+
+		if name == "." {
+			if info.IsDir() {
+				return nil
+			}
+
+			name = info.Name()
+		}
+
+		name = filepath.ToSlash(name)
+		if info.IsDir() {
+			name += "/"
+		}
+
+		var target string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err = fs.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		return fn(path, name, info, target)
+	})
+}
+
+// copyArchived copies the content of the named file to w, if it's a regular
+// file.
+func copyArchived(fs billy.Filesystem, w io.Writer, path string, info billy.FileInfo) error {
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,147 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/archive"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ArchiveSuite struct {
+	fs    billy.Filesystem
+	mtime time.Time
+}
+
+var _ = Suite(&ArchiveSuite{})
+
+func (s *ArchiveSuite) SetUpTest(c *C) {
+	s.mtime = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.fs = memory.New()
+	writeFile(c, s.fs, "qux/foo", "foo")
+	writeFile(c, s.fs, "qux/baz/bar", "bar")
+	c.Assert(s.fs.Symlink("foo", "qux/link"), IsNil)
+	c.Assert(s.fs.Chmod("qux/foo", 0600), IsNil)
+	c.Assert(s.fs.Chtimes("qux/foo", s.mtime, s.mtime), IsNil)
+}
+
+func readTar(c *C, data []byte) (names []string, headers map[string]*tar.Header, contents map[string]string) {
+	headers = make(map[string]*tar.Header)
+	contents = make(map[string]string)
+	r := tar.NewReader(bytes.NewReader(data))
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			return
+		}
+
+		c.Assert(err, IsNil)
+		content, err := ioutil.ReadAll(r)
+		c.Assert(err, IsNil)
+		names = append(names, h.Name)
+		headers[h.Name] = h
+		contents[h.Name] = string(content)
+	}
+}
+
+func (s *ArchiveSuite) TestWriteTar(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(archive.WriteTar(s.fs, buf, "qux"), IsNil)
+
+	names, headers, contents := readTar(c, buf.Bytes())
+	c.Assert(names, DeepEquals, []string{"baz/", "baz/bar", "foo", "link"})
+	c.Assert(contents["foo"], Equals, "foo")
+	c.Assert(contents["baz/bar"], Equals, "bar")
+	c.Assert(headers["link"].Typeflag, Equals, byte(tar.TypeSymlink))
+	c.Assert(headers["link"].Linkname, Equals, "foo")
+	c.Assert(headers["foo"].FileInfo().Mode(), Equals, os.FileMode(0600))
+	c.Assert(headers["foo"].ModTime.Equal(s.mtime), Equals, true)
+
+	buf.Reset()
+	c.Assert(archive.WriteTar(s.fs, buf, "qux/foo"), IsNil)
+	names, _, contents = readTar(c, buf.Bytes())
+	c.Assert(names, DeepEquals, []string{"foo"})
+	c.Assert(contents["foo"], Equals, "foo")
+}
+
+func (s *ArchiveSuite) TestWriteTarSparse(c *C) {
+	name := strings.Repeat("a", 120)
+	f, err := s.fs.Create(name)
+	c.Assert(err, IsNil)
+	var content []byte
+	for i := int64(0); i < 30; i++ {
+		_, err = f.Seek(i<<20, io.SeekStart)
+		c.Assert(err, IsNil)
+		_, err = f.Write([]byte("foo"))
+		c.Assert(err, IsNil)
+		content = append(content, make([]byte, 1<<20)...)
+		copy(content[i<<20:], "foo")
+	}
+
+	c.Assert(f.Truncate(int64(len(content))), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(archive.WriteTar(s.fs, buf, ""), IsNil)
+	c.Assert(buf.Len() < 1<<16, Equals, true)
+
+	_, headers, contents := readTar(c, buf.Bytes())
+	c.Assert(headers[name].Size, Equals, int64(len(content)))
+	c.Assert(contents[name] == string(content), Equals, true)
+	c.Assert(contents["qux/foo"], Equals, "foo")
+	c.Assert(contents["qux/baz/bar"], Equals, "bar")
+}
+
+func (s *ArchiveSuite) TestWriteZip(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(archive.WriteZip(s.fs, buf, ""), IsNil)
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, IsNil)
+
+	files := make(map[string]*zip.File)
+	var names []string
+	for _, f := range r.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{"qux/", "qux/baz/", "qux/baz/bar", "qux/foo", "qux/link"})
+	c.Assert(files["qux/foo"].Mode(), Equals, os.FileMode(0600))
+	c.Assert(files["qux/foo"].Modified.Equal(s.mtime), Equals, true)
+	c.Assert(files["qux/link"].Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+	c.Assert(files["qux/"].Mode().IsDir(), Equals, true)
+
+	for name, content := range map[string]string{"qux/foo": "foo", "qux/link": "foo"} {
+		rc, err := files[name].Open()
+		c.Assert(err, IsNil)
+		data, err := ioutil.ReadAll(rc)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, content)
+		c.Assert(rc.Close(), IsNil)
+	}
+}
+
+func (s *ArchiveSuite) TestWriteArchiveMissing(c *C) {
+	c.Assert(archive.WriteTar(s.fs, ioutil.Discard, "missing"), NotNil)
+	c.Assert(archive.WriteZip(s.fs, ioutil.Discard, "missing"), NotNil)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
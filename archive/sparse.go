@@ -0,0 +1,202 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+const blockSize = 512
+
+// sparseExtents returns the extents holding the data of f, of the given
+// size, aligned to the blocks of the archive. ok is false if f doesn't
+// report them or has no holes of a block.
+func sparseExtents(f billy.File, size int64) (extents []billy.Extent, ok bool) {
+	s, ok := f.(billy.Sparse)
+	if !ok || size == 0 {
+		return nil, false
+	}
+
+	l, err := s.Extents()
+	if err != nil {
+		return nil, false
+	}
+
+	// the GNU tools pad every extent to a block, so they are aligned, merging
+	// the ones sharing a block. The padding is read as zeros from the holes.
+	var data, end int64
+	for _, e := range l {
+		if e.Offset < end || e.Length < 0 || e.Offset+e.Length > size {
+			// not sorted or beyond the end, changed while being read.
+			return nil, false
+		}
+
+		end = e.Offset + e.Length
+		e.Offset -= e.Offset % blockSize
+		if e.Length = end - e.Offset; end%blockSize != 0 {
+			e.Length += blockSize - end%blockSize
+		}
+
+		if e.Offset+e.Length > size {
+			e.Length = size - e.Offset
+		}
+
+		if n := len(extents); n != 0 && extents[n-1].Offset+extents[n-1].Length >= e.Offset {
+			data += e.Offset + e.Length - (extents[n-1].Offset + extents[n-1].Length)
+			extents[n-1].Length = e.Offset + e.Length - extents[n-1].Offset
+			continue
+		}
+
+		data += e.Length
+		extents = append(extents, e)
+	}
+
+	return extents, len(extents) != 0 && data != size
+}
+
+// writeSparse writes the regular file f as a GNU sparse entry with header h,
+// storing only the given extents. archive/tar can't write sparse entries,
+// so they are encoded here, w must be the writer under a flushed tar.Writer.
+// The names longer than the header field are written as GNU long names.
+func writeSparse(w io.Writer, h *tar.Header, f billy.File, extents []billy.Extent) error {
+	if len(h.Name) > 100 {
+		name := append([]byte(h.Name), 0)
+		long := &tar.Header{Name: "././@LongLink", Typeflag: tar.TypeGNULongName}
+		if err := writeBlocks(w, gnuHeader(long, int64(len(name)), nil), name); err != nil {
+			return err
+		}
+	}
+
+	// the GNU tools mark the holes at the end with an empty extent.
+	if last := extents[len(extents)-1]; last.Offset+last.Length < h.Size {
+		extents = append(extents, billy.Extent{Offset: h.Size})
+	}
+
+	var size int64
+	for _, e := range extents {
+		size += e.Length
+	}
+
+	if err := writeBlocks(w, gnuHeader(h, size, extents)); err != nil {
+		return err
+	}
+
+	for _, e := range extents {
+		if _, err := f.Seek(e.Offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.CopyN(w, f, e.Length); err != nil {
+			return err
+		}
+	}
+
+	return writePadding(w, size)
+}
+
+// gnuHeader returns the header blocks of h in the GNU format, with the given
+// size of the data. If extents is not empty, h is a sparse file of h.Size
+// and the blocks following the header hold the extents not fitting in it.
+func gnuHeader(h *tar.Header, size int64, extents []billy.Extent) []byte {
+	b := make([]byte, blockSize)
+	copy(b[0:100], h.Name)
+	formatNumber(b[100:108], int64(h.Mode))
+	formatNumber(b[108:116], int64(h.Uid))
+	formatNumber(b[116:124], int64(h.Gid))
+	formatNumber(b[124:136], size)
+	formatNumber(b[136:148], h.ModTime.Unix())
+	b[156] = h.Typeflag
+	copy(b[257:265], "ustar  \x00")
+	copy(b[265:297], h.Uname)
+	copy(b[297:329], h.Gname)
+
+	var ext []byte
+	if len(extents) != 0 {
+		b[156] = tar.TypeGNUSparse
+		formatNumber(b[483:495], h.Size)
+		extents = formatExtents(b[386:483], 4, extents)
+		for len(extents) != 0 {
+			blk := make([]byte, blockSize)
+			extents = formatExtents(blk, 21, extents)
+			ext = append(ext, blk...)
+		}
+	}
+
+	// the checksum is computed with its own field filled with spaces.
+	copy(b[148:156], "        ")
+	var sum int64
+	for _, c := range b {
+		sum += int64(c)
+	}
+
+	formatNumber(b[148:155], sum)
+	return append(b, ext...)
+}
+
+// formatExtents writes up to max extents to the sparse array b, setting the
+// extended flag following them if some are left, which are returned.
+func formatExtents(b []byte, max int, extents []billy.Extent) []billy.Extent {
+	for i := 0; i < max && len(extents) != 0; i++ {
+		formatNumber(b[i*24:i*24+12], extents[0].Offset)
+		formatNumber(b[i*24+12:i*24+24], extents[0].Length)
+		extents = extents[1:]
+	}
+
+	if len(extents) != 0 {
+		b[max*24] = 1
+	}
+
+	return extents
+}
+
+// formatNumber writes n to the field b as a NUL terminated octal number or,
+// if it doesn't fit, in the base-256 encoding of the GNU format.
+func formatNumber(b []byte, n int64) {
+	s := strconv.FormatInt(n, 8)
+	if n >= 0 && len(s) < len(b) {
+		for i := range b[:len(b)-1-len(s)] {
+			b[i] = '0'
+		}
+
+		copy(b[len(b)-1-len(s):], s)
+		b[len(b)-1] = 0
+		return
+	}
+
+	for i := len(b) - 1; i > 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+
+	b[0] = 0x80
+	if n < 0 {
+		b[0] = 0xff
+	}
+}
+
+// writeBlocks writes the given blocks to w, each one padded to a block.
+func writeBlocks(w io.Writer, blocks ...[]byte) error {
+	for _, b := range blocks {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+
+		if err := writePadding(w, int64(len(b))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writePadding writes the zeros completing the last block of n bytes.
+func writePadding(w io.Writer, n int64) error {
+	if n%blockSize == 0 {
+		return nil
+	}
+
+	_, err := w.Write(make([]byte, blockSize-n%blockSize))
+	return err
+}
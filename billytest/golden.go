@@ -0,0 +1,262 @@
+// Package billytest provides helpers for the tests of the code producing
+// trees in billy filesystems, such as generators and converters.
+//
+// MatchGolden compares the tree produced by a test with a golden tree kept
+// in the testdata directory of the package, and replaces the golden tree with
+// the produced one when the tests are run with the -billytest.update flag:
+//
+//	go test ./... -args -billytest.update
+package billytest // import "srcd.works/go-billy.v1/billytest"
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"srcd.works/go-billy.v1"
+	osfs "srcd.works/go-billy.v1/os"
+)
+
+var update = flag.Bool("billytest.update", false, "update the golden trees compared by MatchGolden")
+
+// maxQuoted is the number of bytes of the contents shown when they differ.
+const maxQuoted = 64
+
+// T is the subset of testing.TB used to report the failures, implemented by
+// *testing.T and by *check.C of gopkg.in/check.v1.
+type T interface {
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// MatchGolden compares the tree rooted at root in fs with the golden tree
+// stored in the goldenDir directory of the OS, usually under testdata,
+// reporting every difference to t. The files are compared by their type,
+// their content, the target of the symbolic links and, except on Windows,
+// the executable bit, the only permission kept by git. The empty
+// directories are ignored, since git doesn't keep them either, as are the
+// modification times.
+//
+// When the tests are run with the -billytest.update flag, the golden tree is
+// replaced with the produced one instead, and nothing is reported.
+func MatchGolden(t T, fs billy.Filesystem, root, goldenDir string) {
+	if h, ok := t.(interface {
+		Helper()
+	}); ok {
+		h.Helper()
+	}
+
+	if root != "" {
+		fs = fs.Dir(root)
+	}
+
+	if *update {
+		if err := updateGolden(fs, goldenDir); err != nil {
+			t.Fatalf("billytest: updating %s: %s", goldenDir, err)
+		}
+
+		return
+	}
+
+	got, err := readTree(fs)
+	if err != nil {
+		t.Fatalf("billytest: reading the produced tree: %s", err)
+		return
+	}
+
+	want, err := readTree(osfs.New(goldenDir))
+	if err != nil {
+		t.Fatalf("billytest: reading the golden tree %s: %s", goldenDir, err)
+		return
+	}
+
+	diffs := compareTrees(got, want)
+	if len(diffs) != 0 {
+		t.Errorf("billytest: the tree doesn't match %s, run the tests with -billytest.update to update it:\n\t%s",
+			goldenDir, strings.Join(diffs, "\n\t"),
+		)
+	}
+}
+
+// updateGolden replaces the golden tree at goldenDir with the tree of fs,
+// writing the files with the permissions 0644, or 0755 if executable.
+func updateGolden(fs billy.Filesystem, goldenDir string) error {
+	tree, err := readTree(fs)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(goldenDir); err != nil {
+		return err
+	}
+
+	golden := osfs.New(goldenDir)
+	if err := golden.MkdirAll("", 0755); err != nil {
+		return err
+	}
+
+	for path, e := range tree {
+		filename := filepath.FromSlash(path)
+		if err := golden.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+
+		switch e.typ {
+		case 0:
+			if err := writeFile(golden, filename, e); err != nil {
+				return err
+			}
+		case os.ModeSymlink:
+			if err := golden.Symlink(filepath.FromSlash(string(e.content)), filename); err != nil {
+				return err
+			}
+		default:
+			return &os.PathError{Op: "update", Path: path, Err: billy.ErrNotSupported}
+		}
+	}
+
+	return nil
+}
+
+func writeFile(fs billy.Filesystem, filename string, e *entry) error {
+	perm := os.FileMode(0644)
+	if e.exec {
+		perm = 0755
+	}
+
+	f, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(e.content); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return fs.Chmod(filename, perm)
+}
+
+// entry is a file of a tree, as compared by MatchGolden.
+type entry struct {
+	typ     os.FileMode
+	exec    bool
+	content []byte
+}
+
+// readTree returns the files and symbolic links of the tree of fs, keyed by
+// their slash separated path.
+func readTree(fs billy.Filesystem) (map[string]*entry, error) {
+	tree := make(map[string]*entry)
+	err := billy.Walk(fs, "", func(path string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		e := &entry{
+			typ:  info.Mode() & os.ModeType,
+			exec: info.Mode()&0111 != 0,
+		}
+
+		switch {
+		case e.typ == os.ModeSymlink:
+			target, err := fs.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			e.content = []byte(filepath.ToSlash(target))
+		case info.Mode().IsRegular():
+			if e.content, err = readFile(fs, path); err != nil {
+				return err
+			}
+		}
+
+		tree[filepath.ToSlash(path)] = e
+		return nil
+	})
+
+	return tree, err
+}
+
+func readFile(fs billy.Filesystem, filename string) ([]byte, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// compareTrees returns the differences between the trees, sorted by path.
+func compareTrees(got, want map[string]*entry) []string {
+	var paths []string
+	for path := range got {
+		paths = append(paths, path)
+	}
+
+	for path := range want {
+		if _, ok := got[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	var diffs []string
+	for _, path := range paths {
+		g, w := got[path], want[path]
+		switch {
+		case w == nil:
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", path, kind(g)))
+		case g == nil:
+			diffs = append(diffs, fmt.Sprintf("%s: missing %s", path, kind(w)))
+		case g.typ != w.typ:
+			diffs = append(diffs, fmt.Sprintf("%s: got a %s, want a %s", path, kind(g), kind(w)))
+		case g.typ == os.ModeSymlink && !bytes.Equal(g.content, w.content):
+			diffs = append(diffs, fmt.Sprintf("%s: got target %q, want %q", path, g.content, w.content))
+		case !bytes.Equal(g.content, w.content):
+			diffs = append(diffs, fmt.Sprintf("%s: got content %s, want %s", path, quote(g.content), quote(w.content)))
+		case g.typ == 0 && g.exec != w.exec && runtime.GOOS != "windows":
+			diffs = append(diffs, fmt.Sprintf("%s: got executable %t, want %t", path, g.exec, w.exec))
+		}
+	}
+
+	return diffs
+}
+
+func kind(e *entry) string {
+	switch e.typ {
+	case 0:
+		return "file"
+	case os.ModeSymlink:
+		return "symbolic link"
+	}
+
+	return "special file"
+}
+
+// quote returns the quoted content with its size, truncated to maxQuoted
+// bytes.
+func quote(content []byte) string {
+	if len(content) <= maxQuoted {
+		return fmt.Sprintf("%q (%d bytes)", content, len(content))
+	}
+
+	return fmt.Sprintf("%q... (%d bytes)", content[:maxQuoted], len(content))
+}
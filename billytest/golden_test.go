@@ -0,0 +1,112 @@
+package billytest_test
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	stdos "os"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/billytest"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type GoldenSuite struct {
+	fs billy.Filesystem
+}
+
+var _ = Suite(&GoldenSuite{})
+
+func (s *GoldenSuite) SetUpTest(c *C) {
+	s.fs = memory.New()
+	writeFile(c, s.fs, "out/foo", "foo\n")
+	writeFile(c, s.fs, "out/qux/bar", "bar")
+	writeFile(c, s.fs, "out/qux/run.sh", "#!/bin/sh\n")
+	c.Assert(s.fs.Chmod("out/qux/run.sh", 0755), IsNil)
+	c.Assert(s.fs.Symlink("foo", "out/link"), IsNil)
+	c.Assert(s.fs.MkdirAll("out/empty", 0755), IsNil)
+}
+
+func (s *GoldenSuite) TestMatchGolden(c *C) {
+	var t recorder
+	billytest.MatchGolden(&t, s.fs, "out", "testdata/golden")
+	c.Assert(t.failures, HasLen, 0)
+}
+
+func (s *GoldenSuite) TestMatchGoldenDifferences(c *C) {
+	writeFile(c, s.fs, "out/foo", "changed\n")
+	writeFile(c, s.fs, "out/new", "new")
+	c.Assert(s.fs.Remove("out/qux/bar"), IsNil)
+	c.Assert(s.fs.Chmod("out/qux/run.sh", 0644), IsNil)
+	c.Assert(s.fs.Remove("out/link"), IsNil)
+	c.Assert(s.fs.Symlink("new", "out/link"), IsNil)
+
+	var t recorder
+	billytest.MatchGolden(&t, s.fs, "out", "testdata/golden")
+	c.Assert(t.failures, HasLen, 1)
+	c.Assert(t.failures[0], Matches, `(?s)billytest: the tree doesn't match testdata/golden.*`)
+	c.Assert(strings.Split(t.failures[0], "\n\t")[1:], DeepEquals, []string{
+		`foo: got content "changed\n" (8 bytes), want "foo\n" (4 bytes)`,
+		`link: got target "new", want "foo"`,
+		`new: unexpected file`,
+		`qux/bar: missing file`,
+		`qux/run.sh: got executable false, want true`,
+	})
+}
+
+func (s *GoldenSuite) TestMatchGoldenMissingDir(c *C) {
+	var t recorder
+	billytest.MatchGolden(&t, s.fs, "out", "testdata/missing")
+	c.Assert(t.failures, HasLen, 1)
+	c.Assert(t.failures[0], Matches, "billytest: reading the golden tree testdata/missing: .*")
+}
+
+func (s *GoldenSuite) TestMatchGoldenUpdate(c *C) {
+	dir, err := ioutil.TempDir("", "billytest")
+	c.Assert(err, IsNil)
+	defer stdos.RemoveAll(dir)
+
+	c.Assert(ioutil.WriteFile(dir+"/stale", []byte("stale"), 0644), IsNil)
+
+	c.Assert(flag.Set("billytest.update", "true"), IsNil)
+	defer flag.Set("billytest.update", "false")
+
+	var t recorder
+	billytest.MatchGolden(&t, s.fs, "out", dir)
+	c.Assert(flag.Set("billytest.update", "false"), IsNil)
+	c.Assert(t.failures, HasLen, 0)
+
+	_, err = stdos.Stat(dir + "/stale")
+	c.Assert(stdos.IsNotExist(err), Equals, true)
+
+	billytest.MatchGolden(&t, s.fs, "out", dir)
+	c.Assert(t.failures, HasLen, 0)
+	billytest.MatchGolden(&t, s.fs, "out", "testdata/golden")
+	c.Assert(t.failures, HasLen, 0)
+}
+
+// recorder is a billytest.T recording the failures.
+type recorder struct {
+	failures []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
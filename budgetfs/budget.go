@@ -0,0 +1,304 @@
+// Package budgetfs provides a billy filesystem wrapper limiting the number of
+// operations done, so the tests can assert the access patterns of the code
+// under test, such as a lookup not doing more than a few calls to Stat.
+package budgetfs // import "srcd.works/go-billy.v1/budgetfs"
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ErrBudgetExceeded is returned by the operations exceeding the budget.
+var ErrBudgetExceeded = errors.New("operation budget exceeded")
+
+// Op is a kind of filesystem operation, named as the Op of the errors
+// returned by the os package.
+type Op string
+
+// The operations counted by Budget, opening a file is one, but reading or
+// writing it isn't.
+const (
+	Open     Op = "open"
+	Stat     Op = "stat"
+	Lstat    Op = "lstat"
+	ReadDir  Op = "readdir"
+	TempFile Op = "tempfile"
+	Rename   Op = "rename"
+	Remove   Op = "remove"
+	Symlink  Op = "symlink"
+	Readlink Op = "readlink"
+	MkdirAll Op = "mkdir"
+	Chmod    Op = "chmod"
+	Chtimes  Op = "chtimes"
+)
+
+// Ops are all the operations counted by Budget.
+var Ops = []Op{
+	Open, Stat, Lstat, ReadDir, TempFile, Rename, Remove, Symlink, Readlink,
+	MkdirAll, Chmod, Chtimes,
+}
+
+// Reporter is told of the operations exceeding the budget, testing.TB and
+// the *check.C of gopkg.in/check.v1 implement it, failing the test.
+type Reporter interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Options describes the budget of a Budget filesystem.
+type Options struct {
+	// Limits are the maximum number of calls by kind of operation, a limit
+	// of zero forbids it, and the ones not included are unlimited.
+	Limits map[Op]int
+	// Total is the maximum number of operations of any kind, zero means no
+	// limit.
+	Total int
+	// Reporter, if not nil, is told of every operation exceeding the budget.
+	Reporter Reporter
+}
+
+// Budget wraps a billy.Filesystem counting the operations done, the ones
+// exceeding the budget fail with ErrBudgetExceeded, without reaching the
+// underlying filesystem, and are reported to the Reporter. It's safe for
+// concurrent use if the underlying filesystem is.
+type Budget struct {
+	fs billy.Filesystem
+	c  *counter
+}
+
+type counter struct {
+	opts Options
+
+	m      sync.Mutex
+	counts map[Op]int
+	total  int
+}
+
+// New returns a new Budget filesystem wrapping fs. If opts is nil the
+// operations are only counted.
+func New(fs billy.Filesystem, opts *Options) *Budget {
+	c := &counter{counts: make(map[Op]int)}
+	if opts != nil {
+		c.opts = *opts
+	}
+
+	return &Budget{fs: fs, c: c}
+}
+
+// Count returns the number of operations of the given kind done, including
+// the ones exceeding the budget.
+func (fs *Budget) Count(op Op) int {
+	fs.c.m.Lock()
+	defer fs.c.m.Unlock()
+
+	return fs.c.counts[op]
+}
+
+// Total returns the number of operations done, including the ones exceeding
+// the budget.
+func (fs *Budget) Total() int {
+	fs.c.m.Lock()
+	defer fs.c.m.Unlock()
+
+	return fs.c.total
+}
+
+// Reset sets the counts back to zero, restoring the whole budget.
+func (fs *Budget) Reset() {
+	fs.c.m.Lock()
+	defer fs.c.m.Unlock()
+
+	fs.c.counts = make(map[Op]int)
+	fs.c.total = 0
+}
+
+// spend counts an operation on path, returning a *os.PathError with
+// ErrBudgetExceeded if it exceeds the budget.
+func (fs *Budget) spend(op Op, path string) error {
+	c := fs.c
+	c.m.Lock()
+	c.counts[op]++
+	c.total++
+	n, total := c.counts[op], c.total
+	c.m.Unlock()
+
+	limit, ok := c.opts.Limits[op]
+	switch {
+	case ok && n > limit:
+		fs.report("%s on %q exceeds the budget of %d %s operations", op, path, limit, op)
+	case c.opts.Total > 0 && total > c.opts.Total:
+		fs.report("%s on %q exceeds the budget of %d operations", op, path, c.opts.Total)
+	default:
+		return nil
+	}
+
+	return &os.PathError{Op: string(op), Path: path, Err: ErrBudgetExceeded}
+}
+
+func (fs *Budget) report(format string, args ...interface{}) {
+	if fs.c.opts.Reporter != nil {
+		fs.c.opts.Reporter.Errorf("budgetfs: "+format, args...)
+	}
+}
+
+// Create creates the named file, counted as an Open.
+func (fs *Budget) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *Budget) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file with the given flag and permissions.
+func (fs *Budget) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if err := fs.spend(Open, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.OpenFile(filename, flag, perm)
+}
+
+// Stat returns the FileInfo structure describing file.
+func (fs *Budget) Stat(filename string) (billy.FileInfo, error) {
+	if err := fs.spend(Stat, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Stat(filename)
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory.
+func (fs *Budget) ReadDir(path string) ([]billy.FileInfo, error) {
+	if err := fs.spend(ReadDir, path); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.ReadDir(path)
+}
+
+// TempFile creates a temporary file.
+func (fs *Budget) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.spend(TempFile, dir); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.TempFile(dir, prefix)
+}
+
+// Rename renames a file.
+func (fs *Budget) Rename(from, to string) error {
+	if err := fs.spend(Rename, from); err != nil {
+		return err
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file.
+func (fs *Budget) Remove(filename string) error {
+	if err := fs.spend(Remove, filename); err != nil {
+		return err
+	}
+
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link.
+func (fs *Budget) Symlink(target, link string) error {
+	if err := fs.spend(Symlink, link); err != nil {
+		return err
+	}
+
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Budget) Readlink(link string) (string, error) {
+	if err := fs.spend(Readlink, link); err != nil {
+		return "", err
+	}
+
+	return fs.fs.Readlink(link)
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links.
+func (fs *Budget) Lstat(filename string) (billy.FileInfo, error) {
+	if err := fs.spend(Lstat, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Lstat(filename)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *Budget) MkdirAll(path string, perm os.FileMode) error {
+	if err := fs.spend(MkdirAll, path); err != nil {
+		return err
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Budget) Chmod(name string, mode os.FileMode) error {
+	if err := fs.spend(Chmod, name); err != nil {
+		return err
+	}
+
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Budget) Chtimes(name string, atime, mtime time.Time) error {
+	if err := fs.spend(Chtimes, name); err != nil {
+		return err
+	}
+
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Ping checks the underlying filesystem, as billy.Ping, it isn't counted.
+func (fs *Budget) Ping(ctx context.Context) error {
+	_, err := billy.Ping(ctx, fs.fs)
+	return err
+}
+
+// Flush flushes the underlying filesystem, as billy.Flush, it isn't
+// counted.
+func (fs *Budget) Flush(ctx context.Context) error {
+	return billy.Flush(ctx, fs.fs)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Budget) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close, it isn't counted.
+func (fs *Budget) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Budget) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Budget filesystem rooted at the given path, sharing the
+// counts and the budget with the current one.
+func (fs *Budget) Dir(path string) billy.Filesystem {
+	return &Budget{fs: fs.fs.Dir(path), c: fs.c}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Budget) Base() string {
+	return fs.fs.Base()
+}
@@ -0,0 +1,103 @@
+package budgetfs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New(), nil)
+}
+
+type BudgetSuite struct{}
+
+var _ = Suite(&BudgetSuite{})
+
+type reporter []string
+
+func (r *reporter) Errorf(format string, args ...interface{}) {
+	*r = append(*r, fmt.Sprintf(format, args...))
+}
+
+func (s *BudgetSuite) TestLimits(c *C) {
+	var r reporter
+	fs := New(memory.New(), &Options{
+		Limits:   map[Op]int{Stat: 2, Remove: 0},
+		Reporter: &r,
+	})
+
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	for i := 0; i < 2; i++ {
+		_, err := fs.Stat("foo")
+		c.Assert(err, IsNil)
+	}
+
+	_, err = fs.Stat("foo")
+	c.Assert(err.(*os.PathError).Err, Equals, ErrBudgetExceeded)
+	err = fs.Dir("qux").Remove("bar")
+	c.Assert(err.(*os.PathError).Err, Equals, ErrBudgetExceeded)
+
+	c.Assert(r, DeepEquals, reporter{
+		`budgetfs: stat on "foo" exceeds the budget of 2 stat operations`,
+		`budgetfs: remove on "bar" exceeds the budget of 0 remove operations`,
+	})
+
+	c.Assert(fs.Count(Stat), Equals, 3)
+	c.Assert(fs.Count(Open), Equals, 1)
+	c.Assert(fs.Total(), Equals, 5)
+
+	fs.Reset()
+	c.Assert(fs.Total(), Equals, 0)
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *BudgetSuite) TestTotal(c *C) {
+	var r reporter
+	fs := New(memory.New(), &Options{Total: 3, Reporter: &r})
+	f, err := fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	_, err = fs.Lstat("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = fs.Readlink("qux/foo")
+	c.Assert(err.(*os.PathError).Err, Equals, ErrBudgetExceeded)
+	c.Assert(r, HasLen, 1)
+}
+
+func (s *BudgetSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend:  "mem://budgetfs",
+		Wrappers: []billy.WrapperConfig{{Name: "budget", Options: map[string]string{"stat": "0"}}},
+	})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err.(*os.PathError).Err, Equals, ErrBudgetExceeded)
+
+	_, err = billy.Compose(&billy.Config{
+		Backend:  "mem://budgetfs",
+		Wrappers: []billy.WrapperConfig{{Name: "budget", Options: map[string]string{"foo": "1"}}},
+	})
+	c.Assert(err, ErrorMatches, `.*unknown operation "foo"`)
+}
@@ -0,0 +1,48 @@
+package budgetfs
+
+import (
+	"fmt"
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("budget", wrap)
+}
+
+// wrap returns a Budget filesystem wrapping fs, with the limits of the
+// operations given by their names, such as "stat", and the total limit by
+// the total option.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	o := Options{Limits: make(map[Op]int)}
+	for name, v := range opts {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "total" {
+			o.Total = n
+			continue
+		}
+
+		if !isOp(Op(name)) {
+			return nil, fmt.Errorf("budgetfs: unknown operation %q", name)
+		}
+
+		o.Limits[Op(name)] = n
+	}
+
+	return New(fs, &o), nil
+}
+
+func isOp(op Op) bool {
+	for _, o := range Ops {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
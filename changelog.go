@@ -0,0 +1,65 @@
+package billy
+
+import "errors"
+
+// ErrCursorExpired is returned by ChangeLog.Changes when the changes following
+// the given cursor are no longer retained by the filesystem, a full scan is
+// required to get in sync again.
+var ErrCursorExpired = errors.New("change log cursor expired")
+
+// ChangeOp is the kind of operation recorded in a ChangeEvent.
+type ChangeOp int
+
+const (
+	// ChangeCreate is recorded when a new file or directory is created.
+	ChangeCreate ChangeOp = iota + 1
+	// ChangeWrite is recorded when the content of a file is modified.
+	ChangeWrite
+	// ChangeRemove is recorded when a file or directory is removed.
+	ChangeRemove
+	// ChangeRename is recorded when a file is renamed, OldPath holds its
+	// previous name.
+	ChangeRename
+	// ChangeMetadata is recorded when the mode or the times of a file are
+	// changed.
+	ChangeMetadata
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeCreate:
+		return "create"
+	case ChangeWrite:
+		return "write"
+	case ChangeRemove:
+		return "remove"
+	case ChangeRename:
+		return "rename"
+	case ChangeMetadata:
+		return "metadata"
+	default:
+		return "unknown"
+	}
+}
+
+// ChangeEvent is a change recorded by a ChangeLog.
+type ChangeEvent struct {
+	// Cursor is the position of the event in the log.
+	Cursor uint64
+	// Op is the kind of change.
+	Op ChangeOp
+	// Path is the name of the file changed.
+	Path string
+	// OldPath is the previous name of the file for ChangeRename events.
+	OldPath string
+}
+
+// ChangeLog is an optional interface implemented by the filesystems keeping a
+// journal of the changes made to them, allowing incremental sync tools to
+// avoid full tree scans.
+type ChangeLog interface {
+	// Changes returns the changes recorded after the given cursor, in order,
+	// and the cursor to be used to request the following ones. The cursor
+	// zero requests all the retained changes.
+	Changes(cursor uint64) ([]ChangeEvent, uint64, error)
+}
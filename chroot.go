@@ -0,0 +1,175 @@
+package billy
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// chrootFS is the filesystem returned by the chroot wrapper, rooted at a
+// directory of the wrapped one. Dir alone is not enough, the backends
+// differ on how the paths escaping their base are handled, so the paths
+// escaping the root return ErrCrossedBoundary, as the symbolic links with
+// absolute targets or targets escaping it.
+type chrootFS struct {
+	fs Filesystem
+}
+
+func chroot(fs Filesystem, opts map[string]string) (Filesystem, error) {
+	path, ok := opts["path"]
+	if !ok {
+		return nil, errors.New("missing path option")
+	}
+
+	if escapes(path) {
+		return nil, ErrCrossedBoundary
+	}
+
+	return &chrootFS{fs: fs.Dir(path)}, nil
+}
+
+func (fs *chrootFS) Create(filename string) (File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *chrootFS) Open(filename string) (File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *chrootFS) OpenFile(filename string, flag int, perm os.FileMode) (File, error) {
+	if escapes(filename) {
+		return nil, ErrCrossedBoundary
+	}
+
+	return fs.fs.OpenFile(filename, flag, perm)
+}
+
+func (fs *chrootFS) Stat(filename string) (FileInfo, error) {
+	if escapes(filename) {
+		return nil, ErrCrossedBoundary
+	}
+
+	return fs.fs.Stat(filename)
+}
+
+func (fs *chrootFS) Lstat(filename string) (FileInfo, error) {
+	if escapes(filename) {
+		return nil, ErrCrossedBoundary
+	}
+
+	return fs.fs.Lstat(filename)
+}
+
+func (fs *chrootFS) ReadDir(path string) ([]FileInfo, error) {
+	if escapes(path) {
+		return nil, ErrCrossedBoundary
+	}
+
+	return fs.fs.ReadDir(path)
+}
+
+func (fs *chrootFS) TempFile(dir, prefix string) (File, error) {
+	if escapes(dir) {
+		return nil, ErrCrossedBoundary
+	}
+
+	return fs.fs.TempFile(dir, prefix)
+}
+
+func (fs *chrootFS) Rename(from, to string) error {
+	if escapes(from) || escapes(to) {
+		return ErrCrossedBoundary
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+func (fs *chrootFS) Remove(filename string) error {
+	if escapes(filename) {
+		return ErrCrossedBoundary
+	}
+
+	return fs.fs.Remove(filename)
+}
+
+func (fs *chrootFS) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a filesystem rooted at the given directory, confined as well.
+func (fs *chrootFS) Dir(path string) Filesystem {
+	if escapes(path) {
+		path = ""
+	}
+
+	return &chrootFS{fs: fs.fs.Dir(path)}
+}
+
+func (fs *chrootFS) Base() string {
+	return fs.fs.Base()
+}
+
+func (fs *chrootFS) Symlink(target, link string) error {
+	if escapes(link) || isAbs(target) || escapes(path.Join(path.Dir(slash(link)), slash(target))) {
+		return ErrCrossedBoundary
+	}
+
+	return fs.fs.Symlink(target, link)
+}
+
+func (fs *chrootFS) Readlink(link string) (string, error) {
+	if escapes(link) {
+		return "", ErrCrossedBoundary
+	}
+
+	return fs.fs.Readlink(link)
+}
+
+func (fs *chrootFS) MkdirAll(path string, perm os.FileMode) error {
+	if escapes(path) {
+		return ErrCrossedBoundary
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+func (fs *chrootFS) Chmod(name string, mode os.FileMode) error {
+	if escapes(name) {
+		return ErrCrossedBoundary
+	}
+
+	return fs.fs.Chmod(name, mode)
+}
+
+func (fs *chrootFS) Chtimes(name string, atime, mtime time.Time) error {
+	if escapes(name) {
+		return ErrCrossedBoundary
+	}
+
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Close closes the wrapped filesystem, as Close.
+func (fs *chrootFS) Close() error {
+	return Close(fs.fs)
+}
+
+// escapes returns true if filename, relative to the root even if absolute,
+// escapes it through "..".
+func escapes(filename string) bool {
+	rel := path.Clean(strings.TrimLeft(slash(filename), "/"))
+	return rel == ".." || strings.HasPrefix(rel, "../")
+}
+
+// isAbs returns true if the target of a symbolic link is absolute, including
+// Windows drive paths.
+func isAbs(target string) bool {
+	target = slash(target)
+	return path.IsAbs(target) || (len(target) > 1 && target[1] == ':')
+}
+
+func slash(p string) string {
+	return strings.Replace(p, `\`, "/", -1)
+}
@@ -0,0 +1,58 @@
+package billy
+
+// cleanup is a stack of functions releasing the resources and undoing the
+// partial work of an operation, run in reverse order when it returns, even
+// if it panics. The functions pushed with undo are skipped once the
+// operation is marked as succeeded.
+//
+//	var c cleanup
+//	defer c.run()
+type cleanup struct {
+	fns       []cleanupFunc
+	succeeded bool
+}
+
+type cleanupFunc struct {
+	fn     func()
+	always bool
+}
+
+// close pushes fn to be run in any case, such as closing a file.
+func (c *cleanup) close(fn func()) {
+	c.fns = append(c.fns, cleanupFunc{fn: fn, always: true})
+}
+
+// undo pushes fn to be run only if the operation doesn't succeed, such as
+// removing a partially written file.
+func (c *cleanup) undo(fn func()) {
+	c.fns = append(c.fns, cleanupFunc{fn: fn})
+}
+
+// succeed marks the operation as succeeded, keeping its work.
+func (c *cleanup) succeed() {
+	c.succeeded = true
+}
+
+// run runs the pending functions, the remaining ones are still run if one of
+// them panics.
+func (c *cleanup) run() {
+	if len(c.fns) == 0 {
+		return
+	}
+
+	f := c.fns[len(c.fns)-1]
+	c.fns = c.fns[:len(c.fns)-1]
+	defer c.run()
+
+	if f.always || !c.succeeded {
+		f.fn()
+	}
+}
+
+// catchPanic recovers a panic into p, deferred by the goroutines doing work
+// for another one, which raises it again once it takes their result.
+func catchPanic(p *interface{}) {
+	if r := recover(); r != nil {
+		*p = r
+	}
+}
@@ -0,0 +1,35 @@
+package billy
+
+import "context"
+
+// Closer is an optional interface implemented by the filesystems holding
+// resources, such as connections, open archives or background goroutines,
+// and by the wrappers, closing the filesystems they wrap.
+type Closer interface {
+	// Close releases the resources of the filesystem, it must not be used
+	// afterwards. The filesystems returned by Dir share the resources of
+	// the one they were created from, closing any of them closes all.
+	Close() error
+}
+
+// Close releases the resources held by fs. The filesystems not implementing
+// Closer hold none, Close does nothing on them.
+func Close(fs Filesystem) error {
+	if c, ok := fs.(Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// Shutdown stops using fs gracefully, flushing it, as Flush, so the work
+// pending isn't lost, and then closing it, as Close, even if the flush
+// fails. The first error is returned.
+func Shutdown(ctx context.Context, fs Filesystem) error {
+	err := Flush(ctx, fs)
+	if cerr := Close(fs); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,56 @@
+package billy_test
+
+import (
+	"context"
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type CloseSuite struct{}
+
+var _ = Suite(&CloseSuite{})
+
+func (s *CloseSuite) TestClose(c *C) {
+	c.Assert(billy.Close(memory.New()), IsNil)
+
+	fs := &closer{flusher: flusher{Filesystem: memory.New()}}
+	c.Assert(billy.Close(fs), IsNil)
+	c.Assert(fs.closed, Equals, 1)
+	c.Assert(fs.flushed, Equals, 0)
+
+	fs.closeErr = errors.New("closed")
+	c.Assert(billy.Close(fs), Equals, fs.closeErr)
+}
+
+func (s *CloseSuite) TestShutdown(c *C) {
+	c.Assert(billy.Shutdown(context.Background(), memory.New()), IsNil)
+
+	fs := &closer{flusher: flusher{Filesystem: memory.New()}}
+	c.Assert(billy.Shutdown(context.Background(), fs), IsNil)
+	c.Assert(fs.flushed, Equals, 1)
+	c.Assert(fs.closed, Equals, 1)
+
+	fs.closeErr = errors.New("closed")
+	c.Assert(billy.Shutdown(context.Background(), fs), Equals, fs.closeErr)
+
+	// the filesystem is closed even if the flush fails.
+	fs.err = errors.New("pending")
+	c.Assert(billy.Shutdown(context.Background(), fs), Equals, fs.err)
+	c.Assert(fs.flushed, Equals, 3)
+	c.Assert(fs.closed, Equals, 3)
+}
+
+// closer is a filesystem counting the calls to Flush and Close.
+type closer struct {
+	flusher
+	closed   int
+	closeErr error
+}
+
+func (fs *closer) Close() error {
+	fs.closed++
+	return fs.closeErr
+}
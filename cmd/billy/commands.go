@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/archive"
+	"srcd.works/go-billy.v1/iofs"
+	"srcd.works/go-billy.v1/webdav"
+)
+
+func ls(w io.Writer, args []string) error {
+	args, err := parse(flag.NewFlagSet("ls", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	fs, path, err := resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	infos, err := fs.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range infos {
+		fmt.Fprintf(w, "%s %10d %s %s\n",
+			fi.Mode(), fi.Size(), fi.ModTime().Format(time.RFC3339), fi.Name(),
+		)
+	}
+
+	return nil
+}
+
+func cat(w io.Writer, args []string) error {
+	args, err := parse(flag.NewFlagSet("cat", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, uri := range args {
+		fs, path, err := resolve(uri)
+		if err != nil {
+			return err
+		}
+
+		f, err := fs.Open(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cp(w io.Writer, args []string) error {
+	args, err := parse(flag.NewFlagSet("cp", flag.ContinueOnError), args, 2)
+	if err != nil {
+		return err
+	}
+
+	srcfs, src, err := resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	dstfs, dst, err := resolve(args[1])
+	if err != nil {
+		return err
+	}
+
+	fi, err := srcfs.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		return billy.CopyTree(dstfs.Dir(dst), srcfs.Dir(src), nil)
+	}
+
+	return billy.CopyFile(dstfs, dst, srcfs, src)
+}
+
+func syncTrees(w io.Writer, args []string) error {
+	fset := flag.NewFlagSet("sync", flag.ContinueOnError)
+	opts := &billy.SyncOptions{}
+	fset.BoolVar(&opts.Checksum, "checksum", false, "compare the content of the files")
+	fset.BoolVar(&opts.DetectRenames, "renames", false, "detect renamed files")
+	args, err := parse(fset, args, 2)
+	if err != nil {
+		return err
+	}
+
+	src, err := resolveDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	dst, err := resolveDir(args[1])
+	if err != nil {
+		return err
+	}
+
+	return billy.Sync(dst, src, opts)
+}
+
+func du(w io.Writer, args []string) error {
+	args, err := parse(flag.NewFlagSet("du", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	fs, err := resolveDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	var size, files int64
+	err = billy.Walk(fs, "", func(path string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+			files++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d bytes in %d files\n", size, files)
+	return nil
+}
+
+func find(w io.Writer, args []string) error {
+	fset := flag.NewFlagSet("find", flag.ContinueOnError)
+	pattern := fset.String("name", "", "shell pattern matched against the base name")
+	args, err := parse(fset, args, 1)
+	if err != nil {
+		return err
+	}
+
+	fs, err := resolveDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	return billy.Walk(fs, "", func(path string, info billy.FileInfo, err error) error {
+		if err != nil || path == "" {
+			return err
+		}
+
+		if *pattern != "" {
+			if ok, err := filepath.Match(*pattern, info.Name()); err != nil || !ok {
+				return err
+			}
+		}
+
+		fmt.Fprintln(w, path)
+		return nil
+	})
+}
+
+func tarTree(w io.Writer, args []string) error {
+	args, err := parse(flag.NewFlagSet("tar", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	fs, err := resolveDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	return archive.WriteTar(fs, w, "")
+}
+
+func zipTree(w io.Writer, args []string) error {
+	args, err := parse(flag.NewFlagSet("zip", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	fs, err := resolveDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	return archive.WriteZip(fs, w, "")
+}
+
+func serve(w io.Writer, args []string) error {
+	fset := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fset.String("addr", "localhost:8080", "address to listen on")
+	dav := fset.Bool("webdav", false, "serve over WebDAV, allowing writes")
+	args, err := parse(fset, args, 1)
+	if err != nil {
+		return err
+	}
+
+	fs, err := resolveDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	var h http.Handler = http.FileServer(http.FS(iofs.New(fs)))
+	if *dav {
+		h = webdav.NewHandler(fs, "")
+	}
+
+	fmt.Fprintf(w, "serving %s on http://%s\n", args[0], *addr)
+	return http.ListenAndServe(*addr, h)
+}
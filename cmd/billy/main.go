@@ -0,0 +1,106 @@
+// Command billy exposes the billy operations on filesystems addressed by URI,
+// serving both as a tool and as a smoke test of the backends.
+//
+// Usage:
+//
+//	billy <command> [flags] <uri>...
+//
+// The URIs are the ones accepted by billy.Open, such as file:///srv/data for
+// the local filesystem or mem://name for a memory filesystem living while the
+// command runs. A path without scheme refers to the local filesystem.
+//
+// Out-of-tree backends are loaded as Go plugins from the directories listed
+// in the BILLY_PLUGINS environment variable, see plugins.LoadDir.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"srcd.works/go-billy.v1/plugins"
+)
+
+type command struct {
+	usage string
+	run   func(w io.Writer, args []string) error
+}
+
+var commands = map[string]command{
+	"ls":    {"ls <uri>: lists a directory", ls},
+	"cat":   {"cat <uri>...: prints the content of the files", cat},
+	"cp":    {"cp <src> <dst>: copies a file or a tree", cp},
+	"sync":  {"sync [-checksum] [-renames] <src> <dst>: makes dst equal to src", syncTrees},
+	"du":    {"du <uri>: prints the total size of a tree", du},
+	"find":  {"find [-name pattern] <uri>: prints the paths of a tree", find},
+	"tar":   {"tar <uri>: writes a tar archive of a tree to stdout", tarTree},
+	"zip":   {"zip <uri>: writes a zip archive of a tree to stdout", zipTree},
+	"serve": {"serve [-addr addr] [-webdav] <uri>: serves a tree over HTTP, read-only unless over WebDAV", serve},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := loadPlugins(os.Getenv("BILLY_PLUGINS")); err != nil {
+		fmt.Fprintf(os.Stderr, "billy: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := cmd.run(os.Stdout, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "billy %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// loadPlugins loads the plugins of the directories in the given list,
+// separated by os.PathListSeparator.
+func loadPlugins(list string) error {
+	for _, dir := range filepath.SplitList(list) {
+		if _, err := plugins.LoadDir(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "usage: billy <command> [flags] <uri>...")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+// parse parses the flags of a command, failing if the number of positional
+// arguments is lower than min.
+func parse(fset *flag.FlagSet, args []string, min int) ([]string, error) {
+	fset.SetOutput(ioutil.Discard)
+	if err := fset.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if fset.NArg() < min {
+		return nil, fmt.Errorf("expected at least %d argument(s)", min)
+	}
+
+	return fset.Args(), nil
+}
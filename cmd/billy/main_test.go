@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CommandsSuite struct {
+	dir string
+}
+
+var _ = Suite(&CommandsSuite{})
+
+func (s *CommandsSuite) SetUpTest(c *C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "billy-cmd")
+	c.Assert(err, IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "qux"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo.txt"), []byte("foo"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "qux", "bar.txt"), []byte("bar"), 0644), IsNil)
+}
+
+func (s *CommandsSuite) TearDownTest(c *C) {
+	c.Assert(os.RemoveAll(s.dir), IsNil)
+}
+
+func (s *CommandsSuite) run(c *C, cmd string, args ...string) string {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(commands[cmd].run(buf, args), IsNil)
+	return buf.String()
+}
+
+func (s *CommandsSuite) TestCopyAndCat(c *C) {
+	s.run(c, "cp", "file://"+s.dir, "mem://copy")
+	c.Assert(s.run(c, "cat", "mem://copy/foo.txt", "mem://copy/qux/bar.txt"), Equals, "foobar")
+	c.Assert(s.run(c, "cat", filepath.Join(s.dir, "foo.txt")), Equals, "foo")
+
+	s.run(c, "cp", "mem://copy/foo.txt", "mem://single/foo")
+	c.Assert(s.run(c, "cat", "mem://single/foo"), Equals, "foo")
+}
+
+func (s *CommandsSuite) TestSync(c *C) {
+	s.run(c, "sync", "file://"+s.dir, "mem://sync")
+	c.Assert(s.run(c, "find", "mem://sync"), Equals, "foo.txt\nqux\nqux/bar.txt\n")
+}
+
+func (s *CommandsSuite) TestFindAndDu(c *C) {
+	c.Assert(s.run(c, "find", "-name", "*.txt", s.dir), Equals, "foo.txt\nqux/bar.txt\n")
+	c.Assert(s.run(c, "du", s.dir), Equals, "6 bytes in 2 files\n")
+}
+
+func (s *CommandsSuite) TestTar(c *C) {
+	r := tar.NewReader(bytes.NewBufferString(s.run(c, "tar", s.dir)))
+
+	var names []string
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+		names = append(names, h.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{"foo.txt", "qux/", "qux/bar.txt"})
+}
+
+func (s *CommandsSuite) TestTarScheme(c *C) {
+	archive := filepath.Join(s.dir, "archive.tar")
+	data := s.run(c, "tar", filepath.Join(s.dir, "qux"))
+	c.Assert(ioutil.WriteFile(archive, []byte(data), 0644), IsNil)
+
+	c.Assert(s.run(c, "find", "tar://"+filepath.ToSlash(archive)), Equals, "bar.txt\n")
+}
+
+func (s *CommandsSuite) TestZip(c *C) {
+	data := s.run(c, "zip", s.dir)
+	r, err := zip.NewReader(bytes.NewReader([]byte(data)), int64(len(data)))
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{"foo.txt", "qux/", "qux/bar.txt"})
+}
+
+func (s *CommandsSuite) TestUnsupportedScheme(c *C) {
+	err := commands["ls"].run(ioutil.Discard, []string{"ftp://host"})
+	c.Assert(err, ErrorMatches, `billy: unknown scheme "ftp".*`)
+}
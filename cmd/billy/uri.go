@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+
+	"srcd.works/go-billy.v1"
+	_ "srcd.works/go-billy.v1/memory"
+	_ "srcd.works/go-billy.v1/os"
+	_ "srcd.works/go-billy.v1/s3fs"
+	_ "srcd.works/go-billy.v1/sftpfs"
+	_ "srcd.works/go-billy.v1/tarfs"
+)
+
+// resolve returns the filesystem holding the file addressed by uri, and its
+// name in it.
+func resolve(uri string) (billy.Filesystem, string, error) {
+	dir, name := uri, ""
+	if i := strings.LastIndex(uri, "/"); i != -1 && !strings.HasSuffix(uri[:i+1], "://") {
+		dir, name = uri[:i+1], uri[i+1:]
+	}
+
+	fs, err := billy.Open(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return fs, name, nil
+}
+
+// resolveDir returns the filesystem rooted at the directory addressed by uri.
+func resolveDir(uri string) (billy.Filesystem, error) {
+	return billy.Open(uri)
+}
@@ -0,0 +1,314 @@
+// Package coalescefs provides a billy filesystem wrapper collapsing the
+// concurrent identical reads into one request to the underlying filesystem,
+// preventing thundering herds against remote backends when many goroutines
+// read the same hot file.
+package coalescefs // import "srcd.works/go-billy.v1/coalescefs"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+var errWriteNotSupported = errors.New("write not supported")
+
+// Coalesce wraps a billy.Filesystem collapsing the concurrent calls to Stat
+// and Lstat for the same path, and to Open, into one. The files opened
+// concurrently for reading share the underlying file, if it implements
+// io.ReaderAt, and the concurrent ReadAt calls for the same range of it are
+// also collapsed. Every caller gets its own file, with its own offset, the
+// underlying one is closed with the last of them.
+type Coalesce struct {
+	fs billy.Filesystem
+	g  *group
+}
+
+// New returns a new Coalesce filesystem wrapping the given one.
+func New(fs billy.Filesystem) *Coalesce {
+	return &Coalesce{fs: fs, g: &group{}}
+}
+
+// Create creates the named file.
+func (fs *Coalesce) Create(filename string) (billy.File, error) {
+	return fs.fs.Create(filename)
+}
+
+// Open opens the named file for reading, sharing the underlying file with the
+// concurrent calls for the same path.
+func (fs *Coalesce) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, the files opened for reading only are
+// shared as by Open.
+func (fs *Coalesce) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag != os.O_RDONLY {
+		return fs.fs.OpenFile(filename, flag, perm)
+	}
+
+	v, err := fs.g.do("open\x00"+filename, func() (interface{}, error) {
+		f, err := fs.fs.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return &handle{File: f}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := v.(*handle)
+	_, shared := h.File.(io.ReaderAt)
+	if !h.acquire(shared) {
+		return fs.fs.OpenFile(filename, flag, perm)
+	}
+
+	if !shared {
+		return h.File, nil
+	}
+
+	return &file{BaseFile: billy.BaseFile{BaseFilename: h.Filename()}, h: h}, nil
+}
+
+// Stat returns the FileInfo of the named file, sharing the result with the
+// concurrent calls for the same path.
+func (fs *Coalesce) Stat(filename string) (billy.FileInfo, error) {
+	v, err := fs.g.do("stat\x00"+filename, func() (interface{}, error) {
+		return fs.fs.Stat(filename)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(billy.FileInfo), nil
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links, sharing the result with the concurrent calls for the same path.
+func (fs *Coalesce) Lstat(filename string) (billy.FileInfo, error) {
+	v, err := fs.g.do("lstat\x00"+filename, func() (interface{}, error) {
+		return fs.fs.Lstat(filename)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(billy.FileInfo), nil
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory.
+func (fs *Coalesce) ReadDir(path string) ([]billy.FileInfo, error) {
+	return fs.fs.ReadDir(path)
+}
+
+// TempFile creates a temporary file.
+func (fs *Coalesce) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.fs.TempFile(dir, prefix)
+}
+
+// Rename renames a file.
+func (fs *Coalesce) Rename(from, to string) error {
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file.
+func (fs *Coalesce) Remove(filename string) error {
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link.
+func (fs *Coalesce) Symlink(target, link string) error {
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Coalesce) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *Coalesce) MkdirAll(path string, perm os.FileMode) error {
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Coalesce) Chmod(name string, mode os.FileMode) error {
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Coalesce) Chtimes(name string, atime, mtime time.Time) error {
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Coalesce) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Coalesce filesystem rooted at the given path.
+func (fs *Coalesce) Dir(path string) billy.Filesystem {
+	return New(fs.fs.Dir(path))
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Coalesce) Base() string {
+	return fs.fs.Base()
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Coalesce) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close.
+func (fs *Coalesce) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// handle is an underlying file shared by the files opened concurrently.
+type handle struct {
+	billy.File
+	g group
+
+	m    sync.Mutex
+	refs int
+	// closed is true once the last file sharing the handle is closed, or
+	// once it's taken by a file if it can't be shared.
+	closed bool
+}
+
+// acquire adds a reference to the handle, or takes it if it can't be shared,
+// returning false if it's already closed or taken.
+func (h *handle) acquire(shared bool) bool {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	h.refs++
+	h.closed = !shared
+	return true
+}
+
+// release removes a reference to the handle, closing it with the last one.
+func (h *handle) release() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.refs--; h.refs != 0 {
+		return nil
+	}
+
+	h.closed = true
+	return h.File.Close()
+}
+
+// file is a file opened for reading sharing a handle, with its own offset.
+type file struct {
+	billy.BaseFile
+	h        *handle
+	position int64
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.position)
+	f.position += int64(n)
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// ReadAt reads from the handle, sharing the result with the concurrent calls
+// for the same range.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	v, err := f.h.g.do(fmt.Sprintf("%d/%d", off, len(p)), func() (interface{}, error) {
+		buf := make([]byte, len(p))
+		n, err := f.h.File.(io.ReaderAt).ReadAt(buf, off)
+		return buf[:n], err
+	})
+
+	return copy(p, v.([]byte)), err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.position
+	case io.SeekEnd:
+		fi, err := f.h.File.Stat()
+		if err != nil {
+			return 0, err
+		}
+
+		offset += fi.Size()
+	default:
+		return 0, fmt.Errorf("seek %s: invalid whence", f.Filename())
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("seek %s: negative offset", f.Filename())
+	}
+
+	f.position = offset
+	return offset, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, errWriteNotSupported
+}
+
+func (f *file) Truncate(size int64) error {
+	return errWriteNotSupported
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	if f.IsClosed() {
+		return nil, billy.ErrClosed
+	}
+
+	return f.h.File.Stat()
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+// Lock returns billy.ErrNotSupported, since the underlying file is shared.
+func (f *file) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, since the underlying file is shared.
+func (f *file) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+func (f *file) Close() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	f.Closed = true
+	return f.h.release()
+}
@@ -0,0 +1,134 @@
+package coalescefs
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New())
+}
+
+type CoalesceSuite struct{}
+
+var _ = Suite(&CoalesceSuite{})
+
+// slow counts the calls to Open and Stat, and to ReadAt of the files opened,
+// delaying them so the concurrent ones overlap.
+type slow struct {
+	billy.Filesystem
+	opens, stats, reads int32
+}
+
+func (fs *slow) Open(filename string) (billy.File, error) {
+	atomic.AddInt32(&fs.opens, 1)
+	time.Sleep(10 * time.Millisecond)
+	f, err := fs.Filesystem.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &slowFile{File: f, fs: fs}, nil
+}
+
+func (fs *slow) Stat(filename string) (billy.FileInfo, error) {
+	atomic.AddInt32(&fs.stats, 1)
+	time.Sleep(10 * time.Millisecond)
+	return fs.Filesystem.Stat(filename)
+}
+
+type slowFile struct {
+	billy.File
+	fs *slow
+}
+
+func (f *slowFile) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt32(&f.fs.reads, 1)
+	time.Sleep(10 * time.Millisecond)
+	return f.File.(io.ReaderAt).ReadAt(p, off)
+}
+
+func (s *CoalesceSuite) TestConcurrentReads(c *C) {
+	m := memory.New()
+	f, err := m.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	under := &slow{Filesystem: m}
+	fs := New(under)
+
+	var wg sync.WaitGroup
+	contents := make([]string, 8)
+	for i := range contents {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := fs.Stat("foo"); err != nil {
+				panic(err)
+			}
+
+			f, err := fs.Open("foo")
+			if err != nil {
+				panic(err)
+			}
+
+			defer f.Close()
+
+			content, err := ioutil.ReadAll(f)
+			if err != nil {
+				panic(err)
+			}
+
+			contents[i] = string(content)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, content := range contents {
+		c.Assert(content, Equals, "foo")
+	}
+
+	c.Assert(under.stats < 8, Equals, true)
+	c.Assert(under.opens < 8, Equals, true)
+	c.Assert(under.reads < 16, Equals, true)
+}
+
+func (s *CoalesceSuite) TestSharedHandleClosed(c *C) {
+	m := memory.New()
+	f, err := m.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fs := New(m)
+	f1, err := fs.Open("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f1.Close(), IsNil)
+	c.Assert(f1.Close(), Equals, billy.ErrClosed)
+
+	f2, err := fs.Open("foo")
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(f2)
+	c.Assert(err, IsNil)
+	c.Assert(f2.Close(), IsNil)
+}
@@ -0,0 +1,44 @@
+package coalescefs
+
+import "sync"
+
+// call is a call in flight, or finished, of a group.
+type call struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// group collapses the concurrent calls with the same key into one.
+type group struct {
+	m     sync.Mutex
+	calls map[string]*call
+}
+
+// do calls fn, unless a call with the same key is in flight, waiting for it
+// and returning its results instead.
+func (g *group) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.m.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.m.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.m.Unlock()
+
+	c.val, c.err = fn()
+
+	g.m.Lock()
+	delete(g.calls, key)
+	g.m.Unlock()
+
+	close(c.done)
+	return c.val, c.err
+}
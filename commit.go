@@ -0,0 +1,538 @@
+package billy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commitPrefix is the prefix of the staging directories used by Commit.
+const commitPrefix = ".commit-"
+
+// Commit runs fn against a staging view of fs and, if it succeeds, publishes
+// all the changes made through the view, or none of them.
+//
+// The view reads through to fs, while the written files are kept in a
+// staging directory at the root of fs, so none of the changes are visible
+// until fn returns. The changes are then published renaming the staged files
+// into place, the replaced and removed files are moved aside first, so they
+// can be restored if any rename fails. Publishing is not atomic for the
+// concurrent readers of fs, and a crash while publishing leaves the moved
+// aside files in the staging directory. The staging directory is removed
+// and the published changes reverted also if fn or the backend panic.
+func Commit(fs Filesystem, fn func(tx Filesystem) error) error {
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	tx := &commitTx{
+		fs:      fs,
+		staging: commitPrefix + token,
+		s: &commitState{
+			staged:  make(map[string]bool),
+			removed: make(map[string]bool),
+			dirs:    make(map[string]bool),
+		},
+	}
+
+	var c cleanup
+	defer c.run()
+	c.undo(func() { RemoveAll(fs, tx.staging) })
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.publish(); err != nil {
+		return err
+	}
+
+	c.succeed()
+	return RemoveAll(fs, tx.staging)
+}
+
+type commitState struct {
+	sync.Mutex
+	staged  map[string]bool
+	removed map[string]bool
+	// dirs are the directories created with MkdirAll, they are created
+	// before publishing the files and not removed on rollback.
+	dirs map[string]bool
+}
+
+// commitTx is the staging view given to the Commit callback, the paths are
+// relative to the root of fs.
+type commitTx struct {
+	fs      Filesystem
+	staging string
+	base    string
+	s       *commitState
+}
+
+// published is a change already applied to the filesystem.
+type published struct {
+	path   string
+	staged bool
+	backup bool
+}
+
+func (tx *commitTx) publish() error {
+	for path := range tx.s.dirs {
+		if err := tx.fs.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+	}
+
+	var c cleanup
+	defer c.run()
+
+	var done []published
+	c.undo(func() { tx.rollback(done) })
+	for _, path := range tx.changes() {
+		p := published{path: path, staged: tx.s.staged[path]}
+		err := tx.fs.Rename(path, tx.backup(path))
+		switch {
+		case err == nil:
+			p.backup = true
+		case !os.IsNotExist(err):
+			return err
+		}
+
+		if p.staged {
+			err = tx.fs.Rename(tx.stage(path), path)
+		}
+
+		done = append(done, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.succeed()
+	return nil
+}
+
+// rollback reverts the published changes, on a best effort basis.
+func (tx *commitTx) rollback(done []published) {
+	for i := len(done) - 1; i >= 0; i-- {
+		p := done[i]
+		if p.staged {
+			tx.fs.Remove(p.path)
+		}
+
+		if p.backup {
+			tx.fs.Rename(tx.backup(p.path), p.path)
+		}
+	}
+}
+
+// changes returns the staged and removed paths, in lexical order.
+func (tx *commitTx) changes() []string {
+	var paths []string
+	for path := range tx.s.staged {
+		paths = append(paths, path)
+	}
+
+	for path := range tx.s.removed {
+		if !tx.s.staged[path] {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func (tx *commitTx) Create(filename string) (File, error) {
+	return tx.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (tx *commitTx) Open(filename string) (File, error) {
+	return tx.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the staged copy of the named file when opened for writing,
+// the current content is copied to the staging directory first, unless it's
+// truncated.
+func (tx *commitTx) OpenFile(filename string, flag int, perm os.FileMode) (File, error) {
+	path := tx.path(filename)
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	staged, removed := tx.s.staged[path], tx.s.removed[path]
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		if removed && !staged {
+			return nil, os.ErrNotExist
+		}
+
+		return tx.open(path, staged, flag, perm)
+	}
+
+	if !staged && !removed {
+		_, err := tx.fs.Stat(path)
+		switch {
+		case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+			return nil, os.ErrExist
+		case err == nil && flag&os.O_TRUNC == 0:
+			err = CopyFile(tx.fs, tx.stage(path), tx.fs, path)
+		case os.IsNotExist(err):
+			err = nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if removed && !staged && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := tx.open(path, true, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.s.staged[path] = true
+	delete(tx.s.removed, path)
+	return f, nil
+}
+
+func (tx *commitTx) open(path string, staged bool, flag int, perm os.FileMode) (File, error) {
+	name := path
+	if staged {
+		name = tx.stage(path)
+	}
+
+	f, err := tx.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &commitFile{File: f, name: tx.name(path)}, nil
+}
+
+func (tx *commitTx) Stat(filename string) (FileInfo, error) {
+	return tx.stat(filename, tx.fs.Stat)
+}
+
+func (tx *commitTx) Lstat(filename string) (FileInfo, error) {
+	return tx.stat(filename, tx.fs.Lstat)
+}
+
+func (tx *commitTx) stat(filename string, stat func(string) (FileInfo, error)) (FileInfo, error) {
+	path := tx.path(filename)
+
+	tx.s.Lock()
+	staged, removed := tx.s.staged[path], tx.s.removed[path]
+	tx.s.Unlock()
+
+	switch {
+	case staged:
+		return stat(tx.stage(path))
+	case removed:
+		return nil, os.ErrNotExist
+	}
+
+	fi, err := stat(path)
+	if os.IsNotExist(err) {
+		// directories holding only new files exist just in the staging area.
+		return stat(tx.stage(path))
+	}
+
+	return fi, err
+}
+
+// ReadDir lists the given directory, merging the staged files with the ones
+// not modified, sorted by name.
+func (tx *commitTx) ReadDir(dir string) ([]FileInfo, error) {
+	path := tx.path(dir)
+	current, err := tx.fs.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	staged, serr := tx.fs.ReadDir(tx.stage(path))
+	if serr != nil && !os.IsNotExist(serr) {
+		return nil, serr
+	}
+
+	if err != nil && serr != nil {
+		return nil, err
+	}
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	seen := make(map[string]bool)
+	for _, fi := range staged {
+		seen[fi.Name()] = true
+	}
+
+	for _, fi := range current {
+		child := filepath.Join(path, fi.Name())
+		if seen[fi.Name()] || tx.s.removed[child] || child == tx.staging {
+			continue
+		}
+
+		staged = append(staged, fi)
+	}
+
+	sort.Sort(byName(staged))
+	return staged, nil
+}
+
+// TempFile creates a temporary file in the staging directory, it's published
+// along with the other changes unless it's removed.
+func (tx *commitTx) TempFile(dir, prefix string) (File, error) {
+	f, err := tx.fs.TempFile(tx.stage(tx.path(dir)), prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Rel(filepath.Join(tx.staging, "data"), f.Filename())
+	if err != nil {
+		return nil, err
+	}
+
+	tx.s.Lock()
+	tx.s.staged[path] = true
+	tx.s.Unlock()
+
+	return &commitFile{File: f, name: tx.name(path)}, nil
+}
+
+// Rename renames a file in the staging directory, copying it there first if
+// it was not modified yet.
+func (tx *commitTx) Rename(from, to string) error {
+	from, to = tx.path(from), tx.path(to)
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	if !tx.s.staged[from] {
+		if tx.s.removed[from] {
+			return os.ErrNotExist
+		}
+
+		if err := tx.copy(from); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.fs.Rename(tx.stage(from), tx.stage(to)); err != nil {
+		return err
+	}
+
+	delete(tx.s.staged, from)
+	tx.s.removed[from] = true
+	tx.s.staged[to] = true
+	delete(tx.s.removed, to)
+	return nil
+}
+
+// Remove removes the staged copy of the file and records the removal of the
+// current one.
+func (tx *commitTx) Remove(filename string) error {
+	path := tx.path(filename)
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	if tx.s.staged[path] {
+		if err := tx.fs.Remove(tx.stage(path)); err != nil {
+			return err
+		}
+
+		delete(tx.s.staged, path)
+		tx.s.removed[path] = true
+		return nil
+	}
+
+	if tx.s.removed[path] {
+		return os.ErrNotExist
+	}
+
+	if _, err := tx.fs.Lstat(path); err != nil {
+		return err
+	}
+
+	tx.s.removed[path] = true
+	return nil
+}
+
+// Symlink creates the symbolic link in the staging directory, a relative
+// target keeps pointing to the same file once published.
+func (tx *commitTx) Symlink(target, link string) error {
+	path := tx.path(link)
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	if tx.s.staged[path] {
+		return os.ErrExist
+	}
+
+	if !tx.s.removed[path] {
+		if _, err := tx.fs.Lstat(path); err == nil {
+			return os.ErrExist
+		}
+	}
+
+	if err := tx.fs.Symlink(target, tx.stage(path)); err != nil {
+		return err
+	}
+
+	tx.s.staged[path] = true
+	delete(tx.s.removed, path)
+	return nil
+}
+
+func (tx *commitTx) Readlink(link string) (string, error) {
+	path := tx.path(link)
+
+	tx.s.Lock()
+	staged, removed := tx.s.staged[path], tx.s.removed[path]
+	tx.s.Unlock()
+
+	switch {
+	case staged:
+		return tx.fs.Readlink(tx.stage(path))
+	case removed:
+		return "", os.ErrNotExist
+	}
+
+	return tx.fs.Readlink(path)
+}
+
+// MkdirAll creates the directory in the staging directory, it's created in fs
+// when published.
+func (tx *commitTx) MkdirAll(dir string, perm os.FileMode) error {
+	path := tx.path(dir)
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	if err := tx.fs.MkdirAll(tx.stage(path), perm); err != nil {
+		return err
+	}
+
+	tx.s.dirs[path] = true
+	return nil
+}
+
+// Chmod changes the mode of the staged copy of the file, copying it there
+// first if it was not modified yet.
+func (tx *commitTx) Chmod(name string, mode os.FileMode) error {
+	return tx.change(name, func(staged string) error {
+		return tx.fs.Chmod(staged, mode)
+	})
+}
+
+// Chtimes changes the times of the staged copy of the file, copying it there
+// first if it was not modified yet.
+func (tx *commitTx) Chtimes(name string, atime, mtime time.Time) error {
+	return tx.change(name, func(staged string) error {
+		return tx.fs.Chtimes(staged, atime, mtime)
+	})
+}
+
+// change calls fn with the name of the staged copy of the file, copying it
+// there first if it was not modified yet.
+func (tx *commitTx) change(name string, fn func(staged string) error) error {
+	path := tx.path(name)
+
+	tx.s.Lock()
+	defer tx.s.Unlock()
+
+	if !tx.s.staged[path] {
+		if tx.s.removed[path] {
+			return os.ErrNotExist
+		}
+
+		if err := tx.copy(path); err != nil {
+			return err
+		}
+
+		tx.s.staged[path] = true
+	}
+
+	return fn(tx.stage(path))
+}
+
+// copy copies the current file to the staging directory, the symbolic links
+// are copied as links.
+func (tx *commitTx) copy(path string) error {
+	fi, err := tx.fs.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return CopyFile(tx.fs, tx.stage(path), tx.fs, path)
+	}
+
+	target, err := tx.fs.Readlink(path)
+	if err != nil {
+		return err
+	}
+
+	return tx.fs.Symlink(target, tx.stage(path))
+}
+
+func (tx *commitTx) Join(elem ...string) string {
+	return tx.fs.Join(elem...)
+}
+
+func (tx *commitTx) Dir(path string) Filesystem {
+	return &commitTx{fs: tx.fs, staging: tx.staging, base: tx.path(path), s: tx.s}
+}
+
+func (tx *commitTx) Base() string {
+	return tx.fs.Join(tx.fs.Base(), tx.base)
+}
+
+// path returns the given filename relative to the root of fs.
+func (tx *commitTx) path(filename string) string {
+	return strings.TrimPrefix(filepath.Clean("/"+filepath.Join(tx.base, filename)), "/")
+}
+
+// name returns the given path relative to the base of the view.
+func (tx *commitTx) name(path string) string {
+	name, _ := filepath.Rel("/"+tx.base, "/"+path)
+	return name
+}
+
+func (tx *commitTx) stage(path string) string {
+	return tx.fs.Join(tx.staging, "data", path)
+}
+
+func (tx *commitTx) backup(path string) string {
+	return tx.fs.Join(tx.staging, "backup", path)
+}
+
+// commitFile is a file opened through the staging view, whose name is
+// relative to it.
+type commitFile struct {
+	File
+	name string
+}
+
+func (f *commitFile) Filename() string {
+	return f.name
+}
+
+func (f *commitFile) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
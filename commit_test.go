@@ -0,0 +1,171 @@
+package billy_test
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type CommitSuite struct{}
+
+var _ = Suite(&CommitSuite{})
+
+func (s *CommitSuite) TestCommit(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo")
+	writeFile(c, fs, "bar", "bar")
+	writeFile(c, fs, "qux/baz", "baz")
+
+	err := billy.Commit(fs, func(tx billy.Filesystem) error {
+		writeFile(c, tx, "foo", "new foo")
+		writeFile(c, tx, "new/file", "file")
+		c.Assert(tx.Remove("bar"), IsNil)
+		c.Assert(tx.Rename("qux/baz", "qux/renamed"), IsNil)
+
+		c.Assert(readFile(c, tx, "foo"), Equals, "new foo")
+		c.Assert(readFile(c, tx, "qux/renamed"), Equals, "baz")
+		_, err := tx.Stat("bar")
+		c.Assert(os.IsNotExist(err), Equals, true)
+
+		names := readDirNames(c, tx, "")
+		c.Assert(names, DeepEquals, []string{"foo", "new", "qux"})
+		c.Assert(readDirNames(c, tx, "qux"), DeepEquals, []string{"renamed"})
+
+		// nothing is visible before returning.
+		c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+		c.Assert(readFile(c, fs, "bar"), Equals, "bar")
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(readFile(c, fs, "foo"), Equals, "new foo")
+	c.Assert(readFile(c, fs, "new/file"), Equals, "file")
+	c.Assert(readFile(c, fs, "qux/renamed"), Equals, "baz")
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"foo", "new", "qux"})
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"renamed"})
+}
+
+func (s *CommitSuite) TestCommitError(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo")
+
+	expected := errors.New("foo")
+	err := billy.Commit(fs, func(tx billy.Filesystem) error {
+		writeFile(c, tx, "foo", "bar")
+		writeFile(c, tx, "bar", "bar")
+		return expected
+	})
+	c.Assert(err, Equals, expected)
+
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"foo"})
+}
+
+func (s *CommitSuite) TestCommitPanic(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo")
+
+	c.Assert(func() {
+		billy.Commit(fs, func(tx billy.Filesystem) error {
+			writeFile(c, tx, "foo", "bar")
+			panic("commit panicked")
+		})
+	}, PanicMatches, "commit panicked")
+
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"foo"})
+}
+
+func (s *CommitSuite) TestCommitRollback(c *C) {
+	fs := &failingRename{Filesystem: memory.New(), fail: "qux"}
+	writeFile(c, fs, "foo", "foo")
+	writeFile(c, fs, "qux", "qux")
+
+	err := billy.Commit(fs, func(tx billy.Filesystem) error {
+		writeFile(c, tx, "foo", "new foo")
+		writeFile(c, tx, "bar", "bar")
+		writeFile(c, tx, "qux", "new qux")
+		return nil
+	})
+	c.Assert(err, Equals, errRename)
+
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+	c.Assert(readFile(c, fs, "qux"), Equals, "qux")
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"foo", "qux"})
+}
+
+func (s *CommitSuite) TestCommitDir(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo", "foo")
+
+	err := billy.Commit(fs, func(tx billy.Filesystem) error {
+		dir := tx.Dir("qux")
+		f, err := dir.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+		c.Assert(err, IsNil)
+		c.Assert(f.Filename(), Equals, "foo")
+		_, err = f.Write([]byte("bar"))
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+
+		_, err = dir.OpenFile("foo", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		c.Assert(err, NotNil)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(readFile(c, fs, "qux/foo"), Equals, "foobar")
+}
+
+func (s *CommitSuite) TestCommitMkdirAll(c *C) {
+	fs := memory.New()
+
+	err := billy.Commit(fs, func(tx billy.Filesystem) error {
+		c.Assert(tx.MkdirAll("qux/empty", 0755), IsNil)
+
+		fi, err := tx.Stat("qux/empty")
+		c.Assert(err, IsNil)
+		c.Assert(fi.IsDir(), Equals, true)
+
+		_, err = fs.Stat("qux")
+		c.Assert(os.IsNotExist(err), Equals, true)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"qux"})
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"empty"})
+}
+
+var errRename = errors.New("rename failed")
+
+// failingRename fails renaming a staged file into the given path.
+type failingRename struct {
+	billy.Filesystem
+	fail string
+}
+
+func (fs *failingRename) Rename(from, to string) error {
+	if to == fs.fail && strings.HasSuffix(from, "/data/"+to) {
+		return errRename
+	}
+
+	return fs.Filesystem.Rename(from, to)
+}
+
+func readDirNames(c *C, fs billy.Filesystem, dir string) []string {
+	infos, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,116 @@
+package billy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// WrapperFactory wraps fs with a filesystem configured by the given options.
+type WrapperFactory func(fs Filesystem, opts map[string]string) (Filesystem, error)
+
+var wrappers = struct {
+	sync.RWMutex
+	names map[string]WrapperFactory
+}{names: map[string]WrapperFactory{"chroot": chroot}}
+
+// RegisterWrapper makes a wrapper available to Compose with the given name,
+// it's intended to be called from the init function of the wrapper packages.
+// If RegisterWrapper is called twice with the same name it panics.
+func RegisterWrapper(name string, f WrapperFactory) {
+	wrappers.Lock()
+	defer wrappers.Unlock()
+
+	if f == nil {
+		panic("billy: RegisterWrapper factory is nil")
+	}
+
+	if _, dup := wrappers.names[name]; dup {
+		panic("billy: RegisterWrapper called twice for wrapper " + name)
+	}
+
+	wrappers.names[name] = f
+}
+
+// Wrappers returns a sorted list of the registered wrappers.
+func Wrappers() []string {
+	wrappers.RLock()
+	defer wrappers.RUnlock()
+
+	var l []string
+	for name := range wrappers.names {
+		l = append(l, name)
+	}
+
+	sort.Strings(l)
+	return l
+}
+
+// Config describes a filesystem as a backend and a stack of wrappers, it can
+// be decoded from JSON, with ReadConfig, or from YAML.
+type Config struct {
+	// Backend is the URI of the backend, as given to Open.
+	Backend string `json:"backend" yaml:"backend"`
+	// Wrappers are applied in order, the first one wraps the backend and the
+	// last one is the filesystem returned by Compose.
+	Wrappers []WrapperConfig `json:"wrappers,omitempty" yaml:"wrappers,omitempty"`
+}
+
+// WrapperConfig describes a wrapper of a Config.
+type WrapperConfig struct {
+	// Name is the name of the wrapper, as registered with RegisterWrapper.
+	Name string `json:"name" yaml:"name"`
+	// Options configures the wrapper, their meaning depends on it.
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// ReadConfig decodes a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{}
+	if err := json.NewDecoder(r).Decode(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Compose opens the backend of cfg and wraps it with the configured
+// wrappers. The chroot wrapper, rooting the filesystem at the directory
+// given by the path option and rejecting the paths escaping it with
+// ErrCrossedBoundary, is always available, the others are registered
+// importing their packages. If a wrapper fails the backend is closed, as
+// Close.
+func Compose(cfg *Config) (Filesystem, error) {
+	if cfg.Backend == "" {
+		return nil, errors.New("billy: config without backend")
+	}
+
+	fs, err := Open(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range cfg.Wrappers {
+		wrappers.RLock()
+		f, ok := wrappers.names[w.Name]
+		wrappers.RUnlock()
+
+		if !ok {
+			Close(fs)
+			return nil, fmt.Errorf("billy: unknown wrapper %q (forgotten import?)", w.Name)
+		}
+
+		wrapped, err := f(fs, w.Options)
+		if err != nil {
+			Close(fs)
+			return nil, fmt.Errorf("billy: wrapper %q: %s", w.Name, err)
+		}
+
+		fs = wrapped
+	}
+
+	return fs, nil
+}
@@ -0,0 +1,101 @@
+package billy_test
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type ComposeSuite struct{}
+
+var _ = Suite(&ComposeSuite{})
+
+// wrapped and wrappedOpts are the filesystem and the options given to the
+// last test-compose wrapper. The wrapper is registered once, so the tests can
+// be run more than once.
+var (
+	wrapped     billy.Filesystem
+	wrappedOpts map[string]string
+)
+
+func init() {
+	billy.RegisterWrapper("test-compose", func(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+		wrapped, wrappedOpts = fs, opts
+		return fs, nil
+	})
+}
+
+func (s *ComposeSuite) TestCompose(c *C) {
+	m, err := billy.Open("mem://test-compose")
+	c.Assert(err, IsNil)
+	writeFile(c, m, "qux/foo", "foo")
+
+	cfg, err := billy.ReadConfig(strings.NewReader(`{
+		"backend": "mem://test-compose",
+		"wrappers": [
+			{"name": "chroot", "options": {"path": "qux"}},
+			{"name": "test-compose", "options": {"foo": "bar"}}
+		]
+	}`))
+	c.Assert(err, IsNil)
+
+	fs, err := billy.Compose(cfg)
+	c.Assert(err, IsNil)
+	c.Assert(fs, Equals, wrapped)
+	c.Assert(wrappedOpts["foo"], Equals, "bar")
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+
+	c.Assert(billy.Wrappers(), DeepEquals, []string{"chroot", "test-compose"})
+	c.Assert(func() { billy.RegisterWrapper("chroot", nil) }, Panics, "billy: RegisterWrapper factory is nil")
+}
+
+func (s *ComposeSuite) TestComposeChroot(c *C) {
+	m, err := billy.Open("mem://test-compose-chroot")
+	c.Assert(err, IsNil)
+	writeFile(c, m, "secret", "secret")
+	writeFile(c, m, "jail/foo", "foo")
+
+	fs, err := billy.Compose(&billy.Config{
+		Backend:  "mem://test-compose-chroot",
+		Wrappers: []billy.WrapperConfig{{Name: "chroot", Options: map[string]string{"path": "jail"}}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+
+	_, err = fs.Open("../secret")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+	_, err = fs.Dir("qux").Stat("../../secret")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+	c.Assert(fs.Rename("foo", "../foo"), Equals, billy.ErrCrossedBoundary)
+	c.Assert(fs.Symlink("/secret", "link"), Equals, billy.ErrCrossedBoundary)
+	c.Assert(fs.Symlink("../secret", "link"), Equals, billy.ErrCrossedBoundary)
+	c.Assert(fs.Symlink("foo", "link"), IsNil)
+	c.Assert(readFile(c, fs, "link"), Equals, "foo")
+	// the memory filesystems opened by URI live in the process, so the test
+	// can be run more than once.
+	c.Assert(fs.Remove("link"), IsNil)
+
+	_, err = billy.Compose(&billy.Config{
+		Backend:  "mem://test-compose-chroot",
+		Wrappers: []billy.WrapperConfig{{Name: "chroot", Options: map[string]string{"path": "../"}}},
+	})
+	c.Assert(err, ErrorMatches, `billy: wrapper "chroot": chroot boundary crossed`)
+}
+
+func (s *ComposeSuite) TestComposeErrors(c *C) {
+	_, err := billy.Compose(&billy.Config{})
+	c.Assert(err, ErrorMatches, "billy: config without backend")
+
+	_, err = billy.Compose(&billy.Config{
+		Backend:  "mem://test-compose-errors",
+		Wrappers: []billy.WrapperConfig{{Name: "unknown"}},
+	})
+	c.Assert(err, ErrorMatches, `billy: unknown wrapper "unknown".*`)
+
+	_, err = billy.Compose(&billy.Config{
+		Backend:  "mem://test-compose-errors",
+		Wrappers: []billy.WrapperConfig{{Name: "chroot"}},
+	})
+	c.Assert(err, ErrorMatches, `billy: wrapper "chroot": missing path option`)
+}
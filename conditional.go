@@ -0,0 +1,92 @@
+package billy
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrPreconditionFailed is returned by the conditional writes when the
+// precondition doesn't hold.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition describes the state a file must be in for a conditional write
+// to succeed, all the given conditions must hold.
+type Precondition struct {
+	// IfMatch, if not empty, requires the current version of the file to be
+	// equal to it.
+	IfMatch string
+	// IfNotExist requires the file to not exist.
+	IfNotExist bool
+	// IfUnmodifiedSince, if not zero, requires the file to not have been
+	// modified after it.
+	IfUnmodifiedSince time.Time
+}
+
+// Conditional is an optional interface implemented by the filesystems
+// supporting compare-and-swap writes, allowing to build safe concurrent
+// updaters. The version of a file is an opaque string, changed by the backend
+// on every modification of the file.
+type Conditional interface {
+	// ReadFileVersion returns the content of the named file and its version.
+	ReadFileVersion(filename string) ([]byte, string, error)
+	// WriteFileIf replaces the content of the named file, creating it if
+	// needed, only if the precondition holds, otherwise it returns
+	// ErrPreconditionFailed. The new version of the file is returned.
+	WriteFileIf(filename string, data []byte, cond Precondition) (string, error)
+}
+
+// ConditionalRemover is an optional interface implemented by the Conditional
+// filesystems able to remove a file only if a precondition holds.
+type ConditionalRemover interface {
+	// RemoveFileIf removes the named file only if the precondition holds,
+	// otherwise it returns ErrPreconditionFailed.
+	RemoveFileIf(filename string, cond Precondition) error
+}
+
+// UpdateFile applies fn to the content of the named file and writes back the
+// result, only if the file was not modified in between. The update is retried
+// up to attempts times, returning ErrPreconditionFailed if all of them lost the
+// race. fn receives nil if the file doesn't exist.
+func UpdateFile(c Conditional, filename string, attempts int, fn func([]byte) ([]byte, error)) error {
+	for i := 0; i < attempts; i++ {
+		cond := Precondition{IfNotExist: true}
+		data, version, err := c.ReadFileVersion(filename)
+		if err == nil {
+			cond = Precondition{IfMatch: version}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		data, err = fn(data)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.WriteFileIf(filename, data, cond)
+		if err != ErrPreconditionFailed {
+			return err
+		}
+	}
+
+	return ErrPreconditionFailed
+}
+
+// Versioned is an optional interface implemented by the FileInfo returned by
+// the filesystems keeping a version of the files, such as an ETag or a
+// revision, the same one used by Conditional.
+type Versioned interface {
+	// Version returns the version of the file.
+	Version() string
+}
+
+// FileVersion returns the version of the file described by fi, ok is false if
+// it doesn't have one.
+func FileVersion(fi FileInfo) (version string, ok bool) {
+	v, ok := fi.(Versioned)
+	if !ok {
+		return "", false
+	}
+
+	return v.Version(), true
+}
@@ -0,0 +1,69 @@
+package billy_test
+
+import (
+	"errors"
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type ConditionalSuite struct{}
+
+var _ = Suite(&ConditionalSuite{})
+
+func (s *ConditionalSuite) TestUpdateFile(c *C) {
+	fs := memory.New()
+	for i := 0; i < 3; i++ {
+		err := billy.UpdateFile(fs, "counter", 1, func(data []byte) ([]byte, error) {
+			return append(data, 'x'), nil
+		})
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(readFile(c, fs, "counter"), Equals, "xxx")
+}
+
+func (s *ConditionalSuite) TestUpdateFileRace(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo")
+
+	var calls int
+	err := billy.UpdateFile(fs, "foo", 2, func(data []byte) ([]byte, error) {
+		calls++
+		writeFile(c, fs, "foo", "bar")
+		return []byte("qux"), nil
+	})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+	c.Assert(calls, Equals, 2)
+	c.Assert(readFile(c, fs, "foo"), Equals, "bar")
+}
+
+func (s *ConditionalSuite) TestUpdateFileError(c *C) {
+	fs := memory.New()
+	expected := errors.New("foo")
+	err := billy.UpdateFile(fs, "foo", 1, func(data []byte) ([]byte, error) {
+		return nil, expected
+	})
+	c.Assert(err, Equals, expected)
+}
+
+func (s *ConditionalSuite) TestFileVersion(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo")
+
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	version, ok := billy.FileVersion(fi)
+	c.Assert(ok, Equals, true)
+
+	_, current, err := fs.ReadFileVersion("foo")
+	c.Assert(err, IsNil)
+	c.Assert(version, Equals, current)
+
+	fi, err = os.Stat(".")
+	c.Assert(err, IsNil)
+	_, ok = billy.FileVersion(fi)
+	c.Assert(ok, Equals, false)
+}
@@ -0,0 +1,383 @@
+// Package contract probes a live billy filesystem and reports how it behaves
+// compared with the semantics documented by billy, helping to pick safe
+// settings for unknown backends, such as network filesystems.
+package contract // import "srcd.works/go-billy.v1/contract"
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Result is the outcome of a probe.
+type Result struct {
+	// Name identifies the probe.
+	Name string
+	// Description of the checked behavior.
+	Description string
+	// Expected is the behavior documented by billy, true if the described
+	// behavior is expected.
+	Expected bool
+	// Observed is the behavior of the filesystem.
+	Observed bool
+	// Err is the unexpected error that prevented to complete the probe, if
+	// any, in which case Observed is false.
+	Err error
+}
+
+// Deviates returns true if the filesystem doesn't behave as documented.
+func (r *Result) Deviates() bool {
+	return r.Err != nil || r.Observed != r.Expected
+}
+
+// Report is the result of checking a filesystem.
+type Report struct {
+	// Results of the probes, in the order they were run.
+	Results []Result
+	// Interfaces lists the optional billy interfaces implemented.
+	Interfaces []string
+}
+
+// Deviations returns the results that don't match billy's semantics.
+func (r *Report) Deviations() []Result {
+	var d []Result
+	for _, res := range r.Results {
+		if res.Deviates() {
+			d = append(d, res)
+		}
+	}
+
+	return d
+}
+
+// String formats the report as a table.
+func (r *Report) String() string {
+	buf := bytes.NewBuffer(nil)
+	for _, res := range r.Results {
+		status := "ok"
+		switch {
+		case res.Err != nil:
+			status = "error: " + res.Err.Error()
+		case res.Deviates():
+			status = fmt.Sprintf("deviates, expected %t", res.Expected)
+		}
+
+		fmt.Fprintf(buf, "%-24s %-5t %s\n", res.Name, res.Observed, status)
+	}
+
+	fmt.Fprintf(buf, "interfaces: %s\n", strings.Join(r.Interfaces, ", "))
+	return buf.String()
+}
+
+type probe struct {
+	name, description string
+	expected          bool
+	run               func(fs billy.Filesystem) (bool, error)
+}
+
+var probes = []probe{
+	{"create-exclusive", "O_CREATE|O_EXCL fails with os.ErrExist on existing files", true, probeExclusive},
+	{"create-parents", "creating a file creates its parent directories", true, probeCreateParents},
+	{"rename-overwrite", "renaming onto an existing file replaces it", true, probeRenameOverwrite},
+	{"rename-onto-dir", "renaming a file onto a directory fails, keeping both", true, probeRenameOntoDir},
+	{"create-over-dir", "creating a file over a directory fails with EISDIR", true, probeCreateOverDir},
+	{"create-under-file", "creating a file under a regular file fails with ENOTDIR", true, probeCreateUnderFile},
+	{"stat-after-write", "the size reported by Stat includes unclosed writes", true, probeStatAfterWrite},
+	{"read-after-write", "a written file can be read as soon as it's closed", true, probeReadAfterWrite},
+	{"remove-open", "a removed file is still readable through an open handle", true, probeRemoveOpen},
+	{"remove-missing", "removing a missing file fails with os.ErrNotExist", true, probeRemoveMissing},
+	{"case-sensitive", "names differing only in case are different files", true, probeCaseSensitive},
+	{"seek-beyond-end", "writing beyond the end fills the gap with zeros", true, probeSeekBeyondEnd},
+}
+
+// Check runs all the probes in a scratch directory of fs, removed once done.
+func Check(fs billy.Filesystem) (*Report, error) {
+	dir, err := scratchDir()
+	if err != nil {
+		return nil, err
+	}
+
+	defer billy.RemoveAll(fs, dir)
+
+	r := &Report{Interfaces: interfaces(fs)}
+	for i, p := range probes {
+		sub := fs.Dir(fs.Join(dir, fmt.Sprint(i)))
+		observed, err := p.run(sub)
+		r.Results = append(r.Results, Result{
+			Name:        p.name,
+			Description: p.description,
+			Expected:    p.expected,
+			Observed:    observed && err == nil,
+			Err:         err,
+		})
+	}
+
+	return r, nil
+}
+
+func interfaces(fs billy.Filesystem) []string {
+	var l []string
+	if _, ok := fs.(billy.Chowner); ok {
+		l = append(l, "Chowner")
+	}
+
+	if _, ok := fs.(billy.HardLink); ok {
+		l = append(l, "HardLink")
+	}
+
+	if _, ok := fs.(billy.Special); ok {
+		l = append(l, "Special")
+	}
+
+	if _, ok := fs.(billy.Identity); ok {
+		l = append(l, "Identity")
+	}
+
+	if _, ok := fs.(billy.EntryReader); ok {
+		l = append(l, "EntryReader")
+	}
+
+	if _, ok := fs.(billy.EntryCounter); ok {
+		l = append(l, "EntryCounter")
+	}
+
+	if _, ok := fs.(billy.ChangeLog); ok {
+		l = append(l, "ChangeLog")
+	}
+
+	if _, ok := fs.(billy.Conditional); ok {
+		l = append(l, "Conditional")
+	}
+
+	if _, ok := fs.(billy.ConditionalRemover); ok {
+		l = append(l, "ConditionalRemover")
+	}
+
+	return l
+}
+
+func probeExclusive(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	f, err := fs.OpenFile("foo", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err == nil {
+		return false, f.Close()
+	}
+
+	return os.IsExist(err), nil
+}
+
+func probeCreateParents(fs billy.Filesystem) (bool, error) {
+	return writeFile(fs, "foo/bar/qux", "foo") == nil, nil
+}
+
+func probeRenameOverwrite(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	if err := writeFile(fs, "bar", "bar"); err != nil {
+		return false, err
+	}
+
+	if err := fs.Rename("foo", "bar"); err != nil {
+		return false, nil
+	}
+
+	content, err := readFile(fs, "bar")
+	return content == "foo", err
+}
+
+func probeRenameOntoDir(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	if err := fs.MkdirAll("bar", 0755); err != nil {
+		return false, err
+	}
+
+	if err := fs.Rename("foo", "bar"); err == nil {
+		return false, nil
+	}
+
+	fi, err := fs.Stat("bar")
+	if err != nil || !fi.IsDir() {
+		return false, err
+	}
+
+	content, err := readFile(fs, "foo")
+	return content == "foo", err
+}
+
+func probeCreateOverDir(fs billy.Filesystem) (bool, error) {
+	if err := fs.MkdirAll("foo", 0755); err != nil {
+		return false, err
+	}
+
+	f, err := fs.OpenFile("foo", os.O_WRONLY|os.O_CREATE, 0666)
+	if err == nil {
+		return false, f.Close()
+	}
+
+	return isErrno(err, syscall.EISDIR), nil
+}
+
+func probeCreateUnderFile(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	f, err := fs.Create("foo/bar")
+	if err == nil {
+		return false, f.Close()
+	}
+
+	return isErrno(err, syscall.ENOTDIR), nil
+}
+
+func probeStatAfterWrite(fs billy.Filesystem) (bool, error) {
+	f, err := fs.Create("foo")
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+	if _, err := f.Write([]byte("foo")); err != nil {
+		return false, err
+	}
+
+	fi, err := fs.Stat("foo")
+	if err != nil {
+		return false, nil
+	}
+
+	return fi.Size() == 3, nil
+}
+
+func probeReadAfterWrite(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	content, err := readFile(fs, "foo")
+	return content == "foo", err
+}
+
+func probeRemoveOpen(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	f, err := fs.Open("foo")
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+	if err := fs.Remove("foo"); err != nil {
+		return false, nil
+	}
+
+	content, err := ioutil.ReadAll(f)
+	return err == nil && string(content) == "foo", nil
+}
+
+func probeRemoveMissing(fs billy.Filesystem) (bool, error) {
+	err := fs.Remove("missing")
+	return os.IsNotExist(err), nil
+}
+
+func probeCaseSensitive(fs billy.Filesystem) (bool, error) {
+	if err := writeFile(fs, "foo", "foo"); err != nil {
+		return false, err
+	}
+
+	_, err := fs.Stat("FOO")
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	return os.IsNotExist(err), nil
+}
+
+func probeSeekBeyondEnd(fs billy.Filesystem) (bool, error) {
+	f, err := fs.Create("foo")
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := f.Seek(2, io.SeekStart); err != nil {
+		f.Close()
+		return false, nil
+	}
+
+	if _, err := f.Write([]byte("x")); err != nil {
+		f.Close()
+		return false, nil
+	}
+
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+
+	content, err := readFile(fs, "foo")
+	return content == "\x00\x00x", err
+}
+
+func writeFile(fs billy.Filesystem, filename, content string) error {
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write([]byte(content))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func readFile(fs billy.Filesystem, filename string) (string, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	return string(content), err
+}
+
+// isErrno returns true if err is errno, or a *os.PathError or *os.LinkError
+// wrapping it.
+func isErrno(err error, errno syscall.Errno) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	}
+
+	return err == errno
+}
+
+func scratchDir() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return ".billy-contract-" + hex.EncodeToString(b), nil
+}
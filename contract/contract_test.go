@@ -0,0 +1,60 @@
+package contract_test
+
+import (
+	"io/ioutil"
+	stdos "os"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/contract"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/os"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ContractSuite struct{}
+
+var _ = Suite(&ContractSuite{})
+
+func (s *ContractSuite) TestCheckMemory(c *C) {
+	fs := memory.New()
+	r, err := contract.Check(fs)
+	c.Assert(err, IsNil)
+	c.Assert(len(r.Results) > 0, Equals, true)
+	c.Assert(r.Deviations(), HasLen, 0, Commentf("%s", r))
+	c.Assert(r.Interfaces, DeepEquals, []string{
+		"Identity", "EntryCounter", "ChangeLog", "Conditional", "ConditionalRemover",
+	})
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 0)
+}
+
+func (s *ContractSuite) TestCheckCaseInsensitive(c *C) {
+	r, err := contract.Check(memory.NewWithOptions(memory.Options{CaseInsensitive: true}))
+	c.Assert(err, IsNil)
+
+	d := r.Deviations()
+	c.Assert(d, HasLen, 1)
+	c.Assert(d[0].Name, Equals, "case-sensitive")
+	c.Assert(strings.Contains(r.String(), "deviates, expected true"), Equals, true)
+}
+
+func (s *ContractSuite) TestCheckOS(c *C) {
+	dir, err := ioutil.TempDir("", "billy-contract")
+	c.Assert(err, IsNil)
+	defer stdos.RemoveAll(dir)
+
+	r, err := contract.Check(os.New(dir))
+	c.Assert(err, IsNil)
+	for _, res := range r.Results {
+		c.Assert(res.Err, IsNil, Commentf("probe %s", res.Name))
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 0)
+}
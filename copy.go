@@ -0,0 +1,418 @@
+package billy
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WindowsMaxPath is the maximum length of a path on Windows without the
+// extended-length prefix.
+const WindowsMaxPath = 260
+
+// CopyOptions describes how a tree is copied by CopyTree.
+type CopyOptions struct {
+	// MaxPathLength is the maximum length allowed for a path in the
+	// destination, measured including the destination base. Zero means no
+	// limit. The paths are checked before copying anything.
+	MaxPathLength int
+	// Shorten, if not nil, is used to rewrite the paths exceeding
+	// MaxPathLength, it receives the path relative to the destination and the
+	// maximum length allowed for it. When nil, CopyTree fails with a
+	// *PathLengthError reporting every offending path.
+	Shorten func(path string, max int) string
+	// PreserveTimes restores the modification times of the copied files and
+	// directories with Chtimes, the destinations not supporting it, returning
+	// ErrNotSupported, are left with the times of the copy.
+	PreserveTimes bool
+	// Owner, if not nil, is used to map the ownership of the source files,
+	// read from FileInfo.Sys, to the one set in the destination, when the
+	// destination implements Chowner.
+	Owner OwnerMap
+	// PreserveHardLinks recreates as hard links in the destination the files
+	// sharing the same inode in the source, as reported by FileInfo.Sys, when
+	// the destination implements HardLink. Otherwise their content is copied.
+	PreserveHardLinks bool
+	// Special defines what to do with the special files found in the source,
+	// by default the copy fails with ErrSpecialFile.
+	Special SpecialPolicy
+}
+
+// SpecialPolicy defines how the special files are handled by CopyTree.
+type SpecialPolicy int
+
+const (
+	// SpecialFail fails the copy returning ErrSpecialFile.
+	SpecialFail SpecialPolicy = iota
+	// SpecialSkip ignores the special files.
+	SpecialSkip
+	// SpecialRecreate creates the special files in the destination if it
+	// implements Special, otherwise ErrNotSupported is returned.
+	SpecialRecreate
+)
+
+// inode identifies a file in a device.
+type inode struct {
+	dev, ino uint64
+}
+
+// PathLengthError is returned by CopyTree and Sync when some paths exceed the
+// maximum length allowed in the destination and can't be shortened.
+type PathLengthError struct {
+	Max   int
+	Paths []string
+}
+
+func (e *PathLengthError) Error() string {
+	return fmt.Sprintf("%d path(s) exceed the maximum length of %d: %s",
+		len(e.Paths), e.Max, strings.Join(e.Paths, ", "),
+	)
+}
+
+// CopyTree copies all the files from src into dst, preserving the directory
+// structure. The symbolic links are copied as links, with the same target. If
+// the copy fails, or panics, such as in Shorten or Owner, the files created
+// in dst are removed, while the replaced ones are kept as copied. If opts is
+// nil the default options are used.
+func CopyTree(dst, src Filesystem, opts *CopyOptions) error {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+
+	var c cleanup
+	defer c.run()
+
+	var files, dirs []string
+	infos := make(map[string]FileInfo)
+	parents := make(map[string]bool)
+	err := Walk(src, "", func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, path)
+		}
+
+		infos[path] = info
+		parents[filepath.Dir(path)] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the empty directories aren't created by copying the files, so their
+	// paths are checked as the ones of the files.
+	leaves := append([]string(nil), files...)
+	for _, dir := range dirs {
+		if dir != "" && !parents[dir] {
+			leaves = append(leaves, dir)
+		}
+	}
+
+	targets, err := destinationPaths(dst, leaves, opts)
+	if err != nil {
+		return err
+	}
+
+	links := make(map[inode]string)
+	for i, path := range files {
+		if _, err := dst.Lstat(targets[i]); os.IsNotExist(err) {
+			target := targets[i]
+			c.undo(func() { dst.Remove(target) })
+		}
+
+		if infos[path].Mode()&os.ModeSymlink != 0 {
+			if err := copySymlink(dst, targets[i], src, path); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if IsSpecial(infos[path]) {
+			if err := copySpecial(dst, targets[i], infos[path], opts); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if linked, err := copyHardLink(dst, targets[i], infos[path], links, opts); err != nil {
+			return err
+		} else if linked {
+			continue
+		}
+
+		if err := CopyFile(dst, targets[i], src, path); err != nil {
+			return err
+		}
+
+		if opts.Owner != nil {
+			if err := copyOwner(dst, targets[i], infos[path], opts.Owner); err != nil {
+				return err
+			}
+		}
+
+		if opts.PreserveTimes {
+			if err := copyTimes(dst, targets[i], infos[path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	dirTargets := make(map[string]string)
+	for i, dir := range leaves[len(files):] {
+		target := targets[len(files)+i]
+		if _, err := dst.Lstat(target); os.IsNotExist(err) {
+			c.undo(func() { dst.Remove(target) })
+		}
+
+		if err := dst.MkdirAll(target, infos[dir].Mode().Perm()); err != nil {
+			return err
+		}
+
+		dirTargets[dir] = target
+	}
+
+	c.succeed()
+	if !opts.PreserveTimes {
+		return nil
+	}
+
+	// writing the children changes the mtime of the directories, so they are
+	// restored bottom-up once all the files are in place.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target := dirs[i]
+		if t, ok := dirTargets[target]; ok {
+			target = t
+		}
+
+		err := copyTimes(dst, target, infos[dirs[i]])
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copySymlink creates dst as a symbolic link with the same target as src,
+// replacing it if it already exists.
+func copySymlink(dstfs Filesystem, dst string, srcfs Filesystem, src string) error {
+	target, err := srcfs.Readlink(src)
+	if err != nil {
+		return err
+	}
+
+	if err := dstfs.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return dstfs.Symlink(target, dst)
+}
+
+func copySpecial(fs Filesystem, path string, info FileInfo, opts *CopyOptions) error {
+	switch opts.Special {
+	case SpecialSkip:
+		return nil
+	case SpecialRecreate:
+		sp, ok := fs.(Special)
+		if !ok {
+			return ErrNotSupported
+		}
+
+		return sp.Mknod(path, info.Mode(), fileDevice(info))
+	default:
+		return &os.PathError{Op: "copy", Path: path, Err: ErrSpecialFile}
+	}
+}
+
+// copyHardLink links path to a previously copied file with the same inode,
+// returns false if the file must be copied instead.
+func copyHardLink(fs Filesystem, path string, info FileInfo, links map[inode]string, opts *CopyOptions) (bool, error) {
+	l, ok := fs.(HardLink)
+	if !ok || !opts.PreserveHardLinks {
+		return false, nil
+	}
+
+	id, nlink, ok := fileInode(info)
+	if !ok || nlink < 2 {
+		return false, nil
+	}
+
+	target, ok := links[id]
+	if !ok {
+		links[id] = path
+		return false, nil
+	}
+
+	if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	return true, l.Link(target, path)
+}
+
+func copyTimes(fs Filesystem, path string, info FileInfo) error {
+	err := fs.Chtimes(path, info.ModTime(), info.ModTime())
+	if err == ErrNotSupported {
+		return nil
+	}
+
+	return err
+}
+
+func destinationPaths(dst Filesystem, files []string, opts *CopyOptions) ([]string, error) {
+	targets := make([]string, len(files))
+	copy(targets, files)
+	if opts.MaxPathLength <= 0 {
+		return targets, nil
+	}
+
+	prefix := len(dst.Join(dst.Base(), "x")) - 1
+	max := opts.MaxPathLength - prefix
+
+	var invalid []string
+	seen := make(map[string]bool, len(files))
+	for i, path := range targets {
+		if len(path) > max && opts.Shorten != nil {
+			path = opts.Shorten(path, max)
+			targets[i] = path
+		}
+
+		if len(path) > max || seen[path] {
+			invalid = append(invalid, files[i])
+		}
+
+		seen[path] = true
+	}
+
+	if len(invalid) != 0 {
+		return nil, &PathLengthError{Max: opts.MaxPathLength, Paths: invalid}
+	}
+
+	return targets, nil
+}
+
+// ShortenHash is a CopyOptions.Shorten strategy truncating the file name and
+// appending a hash of the original path to keep the names unique. The
+// extension is preserved. Paths whose directory is already too long are
+// returned untouched.
+func ShortenHash(path string, max int) string {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	hash := fmt.Sprintf("~%x", sha1.Sum([]byte(path)))[:9]
+
+	keep := max - len(dir) - len(ext) - len(hash)
+	if keep < 0 {
+		return path
+	}
+
+	base := strings.TrimSuffix(name, ext)
+	if keep < len(base) {
+		base = base[:keep]
+	}
+
+	return dir + base + hash + ext
+}
+
+// CopyFile copies the file src from srcfs to dst in dstfs, dst is created or
+// truncated if it already exists. If the source file implements Sparse only
+// the regions holding data are written, preserving the holes. If the copy
+// fails, or panics, the partially written dst is removed.
+func CopyFile(dstfs Filesystem, dst string, srcfs Filesystem, src string) error {
+	var c cleanup
+	defer c.run()
+
+	from, err := srcfs.Open(src)
+	if err != nil {
+		return err
+	}
+
+	c.close(func() { from.Close() })
+
+	to, err := dstfs.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	c.undo(func() { dstfs.Remove(dst) })
+	c.undo(func() { to.Close() })
+
+	if err := copyContent(to, from, srcfs, src); err != nil {
+		return err
+	}
+
+	c.succeed()
+	if err := to.Close(); err != nil {
+		dstfs.Remove(dst)
+		return err
+	}
+
+	return nil
+}
+
+func copyContent(to, from File, srcfs Filesystem, src string) error {
+	extents, size, ok := sparseExtents(from, srcfs, src)
+	if !ok {
+		_, err := io.Copy(to, from)
+		return err
+	}
+
+	var end int64
+	for _, e := range extents {
+		if _, err := from.Seek(e.Offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := to.Seek(e.Offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.CopyN(to, from, e.Length); err != nil {
+			return err
+		}
+
+		end = e.Offset + e.Length
+	}
+
+	if end == size {
+		return nil
+	}
+
+	// the file ends with a hole, growing it keeps the hole.
+	return to.Truncate(size)
+}
+
+// sparseExtents returns the extents of f, ok is false if the file is not
+// sparse or its extents can't be retrieved.
+func sparseExtents(f File, fs Filesystem, filename string) (extents []Extent, size int64, ok bool) {
+	s, ok := f.(Sparse)
+	if !ok {
+		return nil, 0, false
+	}
+
+	fi, err := fs.Stat(filename)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	extents, err = s.Extents()
+	if err != nil {
+		return nil, 0, false
+	}
+
+	size = fi.Size()
+	if len(extents) == 1 && extents[0].Offset == 0 && extents[0].Length == size {
+		return nil, 0, false
+	}
+
+	return extents, size, size != 0
+}
@@ -0,0 +1,217 @@
+package billy_test
+
+import (
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type CopySuite struct{}
+
+var _ = Suite(&CopySuite{})
+
+func (s *CopySuite) TestCopyTree(c *C) {
+	src := memory.New()
+	for _, name := range []string{"foo", "qux/baz", "qux/bar/foo"} {
+		writeFile(c, src, name, name)
+	}
+
+	dst := memory.New()
+	c.Assert(billy.CopyTree(dst, src, nil), IsNil)
+
+	for _, name := range []string{"foo", "qux/baz", "qux/bar/foo"} {
+		c.Assert(readFile(c, dst, name), Equals, name)
+	}
+}
+
+func (s *CopySuite) TestCopyTreeEmptyDir(c *C) {
+	src := memory.New()
+	writeFile(c, src, "qux/foo", "foo")
+	c.Assert(src.MkdirAll("qux/empty", 0700), IsNil)
+	c.Assert(src.MkdirAll("bar/baz", 0755), IsNil)
+
+	dst := memory.New()
+	c.Assert(billy.CopyTree(dst, src, nil), IsNil)
+
+	for _, name := range []string{"qux/empty", "bar/baz"} {
+		fi, err := dst.Stat(name)
+		c.Assert(err, IsNil)
+		c.Assert(fi.IsDir(), Equals, true)
+	}
+
+	fi, err := dst.Stat("qux/empty")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0700))
+}
+
+func (s *CopySuite) TestCopyTreeSymlink(c *C) {
+	src := memory.New()
+	writeFile(c, src, "qux/foo", "foo")
+	c.Assert(src.Symlink("foo", "qux/bar"), IsNil)
+	c.Assert(src.Symlink("missing", "baz"), IsNil)
+
+	dst := memory.New()
+	c.Assert(billy.CopyTree(dst, src, nil), IsNil)
+
+	target, err := dst.Readlink("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "foo")
+	c.Assert(readFile(c, dst, "qux/bar"), Equals, "foo")
+
+	target, err = dst.Readlink("baz")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "missing")
+}
+
+func (s *CopySuite) TestCopyTreeMaxPathLength(c *C) {
+	long := strings.Repeat("a", 20)
+	src := memory.New()
+	writeFile(c, src, "foo", "foo")
+	writeFile(c, src, "qux/"+long, "bar")
+	writeFile(c, src, long+"/foo", "baz")
+	c.Assert(src.MkdirAll("bar/"+long, 0755), IsNil)
+
+	dst := memory.New()
+	err := billy.CopyTree(dst, src, &billy.CopyOptions{MaxPathLength: 16})
+	c.Assert(err, FitsTypeOf, &billy.PathLengthError{})
+	c.Assert(err.(*billy.PathLengthError).Paths, DeepEquals, []string{
+		long + "/foo", "qux/" + long, "bar/" + long,
+	})
+
+	files, err := dst.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
+func (s *CopySuite) TestCopyTreeShorten(c *C) {
+	long := strings.Repeat("a", 20)
+	src := memory.New()
+	writeFile(c, src, "qux/"+long+".txt", "foo")
+	writeFile(c, src, "qux/"+long+"b.txt", "bar")
+
+	dst := memory.New()
+	err := billy.CopyTree(dst, src, &billy.CopyOptions{
+		MaxPathLength: 24,
+		Shorten:       billy.ShortenHash,
+	})
+	c.Assert(err, IsNil)
+
+	files, err := dst.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+	for _, fi := range files {
+		c.Assert(len(dst.Join("/qux", fi.Name())) <= 24, Equals, true)
+		c.Assert(strings.HasSuffix(fi.Name(), ".txt"), Equals, true)
+	}
+}
+
+func (s *CopySuite) TestCopyTreeShortenEmptyDir(c *C) {
+	long := strings.Repeat("a", 30)
+	src := memory.New()
+	c.Assert(src.MkdirAll("qux/"+long, 0700), IsNil)
+
+	dst := memory.New()
+	err := billy.CopyTree(dst, src, &billy.CopyOptions{
+		MaxPathLength: 24,
+		Shorten:       billy.ShortenHash,
+		PreserveTimes: true,
+	})
+	c.Assert(err, IsNil)
+
+	files, err := dst.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+	c.Assert(files[0].IsDir(), Equals, true)
+	c.Assert(len(dst.Join("/qux", files[0].Name())) <= 24, Equals, true)
+}
+
+func (s *CopySuite) TestCopyFileTrailingHole(c *C) {
+	fs := memory.New()
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(1<<20), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(billy.CopyFile(fs, "bar", fs, "foo"), IsNil)
+
+	fi, err := fs.Stat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(1<<20))
+
+	f, err = fs.Open("bar")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	extents, err := f.(billy.Sparse).Extents()
+	c.Assert(err, IsNil)
+	c.Assert(extents, DeepEquals, []billy.Extent{{Offset: 0, Length: 3}})
+}
+
+func (s *CopySuite) TestCopyFilePanic(c *C) {
+	src := &panicking{Filesystem: memory.New(), path: "foo"}
+	writeFile(c, src, "foo", "foo")
+
+	dst := memory.New()
+	c.Assert(func() {
+		billy.CopyFile(dst, "bar", src, "foo")
+	}, PanicMatches, "panicking on foo")
+
+	_, err := dst.Stat("bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *CopySuite) TestCopyTreePanic(c *C) {
+	src := &panicking{Filesystem: memory.New(), path: "qux/baz"}
+	for _, name := range []string{"foo", "qux/bar", "qux/baz"} {
+		writeFile(c, src, name, name)
+	}
+
+	dst := memory.New()
+	writeFile(c, dst, "foo", "old")
+	c.Assert(func() {
+		billy.CopyTree(dst, src, nil)
+	}, PanicMatches, "panicking on qux/baz")
+
+	c.Assert(readFile(c, dst, "foo"), Equals, "foo")
+	for _, name := range []string{"qux/bar", "qux/baz"} {
+		_, err := dst.Stat(name)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+}
+
+// panicking panics listing the directory or reading the file at path, as a
+// faulty backend.
+type panicking struct {
+	billy.Filesystem
+	path string
+}
+
+func (fs *panicking) ReadDir(path string) ([]billy.FileInfo, error) {
+	if path == fs.path {
+		panic("panicking on " + path)
+	}
+
+	return fs.Filesystem.ReadDir(path)
+}
+
+func (fs *panicking) Open(filename string) (billy.File, error) {
+	f, err := fs.Filesystem.Open(filename)
+	if err != nil || filename != fs.path {
+		return f, err
+	}
+
+	return &panickingFile{File: f}, nil
+}
+
+type panickingFile struct {
+	billy.File
+}
+
+func (f *panickingFile) Read(p []byte) (int, error) {
+	panic("panicking on " + f.Filename())
+}
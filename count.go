@@ -0,0 +1,36 @@
+package billy
+
+// EntryCounter is an optional interface implemented by the filesystems able to
+// count the entries of a directory without listing all of them.
+type EntryCounter interface {
+	// CountEntries returns the number of entries in the given directory,
+	// stopping at limit if it's greater than zero.
+	CountEntries(path string, limit int) (int, error)
+}
+
+// CountEntries returns the number of entries in the given directory, stopping
+// at limit if it's greater than zero. The EntryCounter implementation of fs
+// is used if available, otherwise the whole directory is read.
+func CountEntries(fs Filesystem, path string, limit int) (int, error) {
+	if c, ok := fs.(EntryCounter); ok {
+		return c.CountEntries(path, limit)
+	}
+
+	l, err := fs.ReadDir(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit > 0 && len(l) > limit {
+		return limit, nil
+	}
+
+	return len(l), nil
+}
+
+// IsEmptyDir returns true if the given directory has no entries, it stops
+// after finding the first one when fs implements EntryCounter.
+func IsEmptyDir(fs Filesystem, path string) (bool, error) {
+	n, err := CountEntries(fs, path, 1)
+	return n == 0, err
+}
@@ -0,0 +1,34 @@
+package billy_test
+
+import (
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type CountSuite struct{}
+
+var _ = Suite(&CountSuite{})
+
+func (s *CountSuite) TestCountEntries(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo", "bar", "qux/foo", "qux/bar"} {
+		writeFile(c, fs, name, name)
+	}
+
+	n, err := billy.CountEntries(fs, "", 0)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 3)
+
+	n, err = billy.CountEntries(fs, "", 2)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 2)
+
+	empty, err := billy.IsEmptyDir(fs, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(empty, Equals, false)
+
+	empty, err = billy.IsEmptyDir(memory.New(), "")
+	c.Assert(err, IsNil)
+	c.Assert(empty, Equals, true)
+}
@@ -0,0 +1,428 @@
+// Package crashfs provides a billy filesystem wrapper emulating a crash of
+// the process after a given number of operations, leaving the underlying
+// filesystem in the exact state reached until then, so the tests can check
+// the recovery of the code under test, such as a journal, at every point it
+// may be interrupted:
+//
+//	// count the operations done by a complete run.
+//	fs := crashfs.New(memory.New(), nil)
+//	run(fs)
+//
+//	for i := 0; i < fs.Count(); i++ {
+//		base := memory.New()
+//		run(crashfs.New(base, &crashfs.Options{After: i}))
+//		checkRecovery(base)
+//	}
+package crashfs // import "srcd.works/go-billy.v1/crashfs"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ErrCrashed is returned by every operation once the filesystem crashed.
+var ErrCrashed = errors.New("filesystem crashed")
+
+// Options describes when a Crash filesystem crashes.
+type Options struct {
+	// After is the number of operations changing the filesystem done before
+	// crashing, the next one fails without reaching the underlying
+	// filesystem. A negative number only crashes when calling Crash.
+	After int
+	// TornWrites makes the write crashing write the first half of its data,
+	// emulating a write interrupted halfway.
+	TornWrites bool
+}
+
+// Crash wraps a billy.Filesystem, counting the operations changing it:
+// opening files with os.O_CREATE or os.O_TRUNC, creating temporary files,
+// writing and truncating files, renaming, removing, creating links and
+// directories, and changing modes and times. Once crashed, every operation
+// fails with ErrCrashed, including the ones only reading, and closing the
+// open files only releases them. It's safe for concurrent use if the
+// underlying filesystem is, although the crashing operation then depends on
+// the scheduling.
+type Crash struct {
+	fs billy.Filesystem
+	s  *state
+}
+
+type state struct {
+	opts Options
+
+	m       sync.Mutex
+	count   int
+	crashed bool
+}
+
+// New returns a new Crash filesystem wrapping fs. If opts is nil it only
+// crashes when calling Crash.
+func New(fs billy.Filesystem, opts *Options) *Crash {
+	s := &state{opts: Options{After: -1}}
+	if opts != nil {
+		s.opts = *opts
+	}
+
+	return &Crash{fs: fs, s: s}
+}
+
+// Crash crashes the filesystem, failing all the following operations.
+func (fs *Crash) Crash() {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	fs.s.crashed = true
+}
+
+// Crashed returns true if the filesystem crashed.
+func (fs *Crash) Crashed() bool {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	return fs.s.crashed
+}
+
+// Count returns the number of operations changing the filesystem done, not
+// including the one crashing.
+func (fs *Crash) Count() int {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	return fs.s.count
+}
+
+// change counts an operation changing the filesystem, returning false if it
+// crashes, or crashed before.
+func (s *state) change() bool {
+	ok, _ := s.advance()
+	return ok
+}
+
+// advance counts an operation changing the filesystem, returning false if it
+// crashes, with crashing true, or crashed before.
+func (s *state) advance() (ok, crashing bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.crashed {
+		return false, false
+	}
+
+	if s.opts.After >= 0 && s.count >= s.opts.After {
+		s.crashed = true
+		return false, true
+	}
+
+	s.count++
+	return true, false
+}
+
+// check returns a *os.PathError with ErrCrashed if the filesystem crashed.
+func (s *state) check(op, path string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.crashed {
+		return crashed(op, path)
+	}
+
+	return nil
+}
+
+func crashed(op, path string) error {
+	return &os.PathError{Op: op, Path: path, Err: ErrCrashed}
+}
+
+// Create creates the named file, counted as a change.
+func (fs *Crash) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *Crash) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file with the given flag and permissions, counted
+// as a change with os.O_CREATE or os.O_TRUNC.
+func (fs *Crash) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		if !fs.s.change() {
+			return nil, crashed("open", filename)
+		}
+	} else if err := fs.s.check("open", filename); err != nil {
+		return nil, err
+	}
+
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, s: fs.s}, nil
+}
+
+// Stat returns the FileInfo structure describing file.
+func (fs *Crash) Stat(filename string) (billy.FileInfo, error) {
+	if err := fs.s.check("stat", filename); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Stat(filename)
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory.
+func (fs *Crash) ReadDir(path string) ([]billy.FileInfo, error) {
+	if err := fs.s.check("readdir", path); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.ReadDir(path)
+}
+
+// TempFile creates a temporary file, counted as a change.
+func (fs *Crash) TempFile(dir, prefix string) (billy.File, error) {
+	if !fs.s.change() {
+		return nil, crashed("tempfile", dir)
+	}
+
+	f, err := fs.fs.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, s: fs.s}, nil
+}
+
+// Rename renames a file.
+func (fs *Crash) Rename(from, to string) error {
+	if !fs.s.change() {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: ErrCrashed}
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file.
+func (fs *Crash) Remove(filename string) error {
+	if !fs.s.change() {
+		return crashed("remove", filename)
+	}
+
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link.
+func (fs *Crash) Symlink(target, link string) error {
+	if !fs.s.change() {
+		return crashed("symlink", link)
+	}
+
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Crash) Readlink(link string) (string, error) {
+	if err := fs.s.check("readlink", link); err != nil {
+		return "", err
+	}
+
+	return fs.fs.Readlink(link)
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links.
+func (fs *Crash) Lstat(filename string) (billy.FileInfo, error) {
+	if err := fs.s.check("lstat", filename); err != nil {
+		return nil, err
+	}
+
+	return fs.fs.Lstat(filename)
+}
+
+// MkdirAll creates a directory and its parents, counted as a single change.
+func (fs *Crash) MkdirAll(path string, perm os.FileMode) error {
+	if !fs.s.change() {
+		return crashed("mkdir", path)
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Crash) Chmod(name string, mode os.FileMode) error {
+	if !fs.s.change() {
+		return crashed("chmod", name)
+	}
+
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Crash) Chtimes(name string, atime, mtime time.Time) error {
+	if !fs.s.change() {
+		return crashed("chtimes", name)
+	}
+
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Ping checks the underlying filesystem, as billy.Ping, failing with
+// ErrCrashed once crashed.
+func (fs *Crash) Ping(ctx context.Context) error {
+	if fs.Crashed() {
+		return ErrCrashed
+	}
+
+	_, err := billy.Ping(ctx, fs.fs)
+	return err
+}
+
+// Flush flushes the underlying filesystem, as billy.Flush, failing with
+// ErrCrashed once crashed.
+func (fs *Crash) Flush(ctx context.Context) error {
+	if fs.Crashed() {
+		return ErrCrashed
+	}
+
+	return billy.Flush(ctx, fs.fs)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Crash) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close, releasing its
+// resources even once crashed, but failing with ErrCrashed then.
+func (fs *Crash) Close() error {
+	err := billy.Close(fs.fs)
+	if fs.Crashed() {
+		return ErrCrashed
+	}
+
+	return err
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Crash) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Crash filesystem rooted at the given path, sharing the
+// count and the crash with the current one.
+func (fs *Crash) Dir(path string) billy.Filesystem {
+	return &Crash{fs: fs.fs.Dir(path), s: fs.s}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Crash) Base() string {
+	return fs.fs.Base()
+}
+
+type file struct {
+	billy.File
+	s *state
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if err := f.s.check("read", f.Filename()); err != nil {
+		return 0, err
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	if err := f.s.check("read", f.Filename()); err != nil {
+		return 0, err
+	}
+
+	return r.ReadAt(p, off)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	ok, crashing := f.s.advance()
+	if ok {
+		return f.File.Write(p)
+	}
+
+	var n int
+	if crashing && f.s.opts.TornWrites {
+		n, _ = f.File.Write(p[:len(p)/2])
+	}
+
+	return n, crashed("write", f.Filename())
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if err := f.s.check("seek", f.Filename()); err != nil {
+		return 0, err
+	}
+
+	return f.File.Seek(offset, whence)
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	if err := f.s.check("stat", f.Filename()); err != nil {
+		return nil, err
+	}
+
+	return f.File.Stat()
+}
+
+func (f *file) Truncate(size int64) error {
+	if !f.s.change() {
+		return crashed("truncate", f.Filename())
+	}
+
+	return f.File.Truncate(size)
+}
+
+func (f *file) Sync() error {
+	if err := f.s.check("sync", f.Filename()); err != nil {
+		return err
+	}
+
+	return f.File.Sync()
+}
+
+func (f *file) Lock() error {
+	if err := f.s.check("lock", f.Filename()); err != nil {
+		return err
+	}
+
+	return f.File.Lock()
+}
+
+func (f *file) Unlock() error {
+	if err := f.s.check("unlock", f.Filename()); err != nil {
+		return err
+	}
+
+	return f.File.Unlock()
+}
+
+// Close closes the file, once crashed it's released, as done by the
+// operating system with the files of a killed process, but ErrCrashed is
+// returned.
+func (f *file) Close() error {
+	err := f.File.Close()
+	if err := f.s.check("close", f.Filename()); err != nil {
+		return err
+	}
+
+	return err
+}
@@ -0,0 +1,173 @@
+package crashfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New(), nil)
+}
+
+type CrashSuite struct{}
+
+var _ = Suite(&CrashSuite{})
+
+func (s *CrashSuite) TestAfter(c *C) {
+	base := memory.New()
+	fs := New(base, &Options{After: 2})
+
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Crashed(), Equals, false)
+
+	n, err := f.Write([]byte("bar"))
+	c.Assert(n, Equals, 0)
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+	c.Assert(fs.Crashed(), Equals, true)
+	c.Assert(fs.Count(), Equals, 2)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+	_, err = f.Read(make([]byte, 1))
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+	err = fs.Rename("foo", "bar")
+	c.Assert(err.(*os.LinkError).Err, Equals, ErrCrashed)
+	c.Assert(f.Close().(*os.PathError).Err, Equals, ErrCrashed)
+	c.Assert(f.IsClosed(), Equals, true)
+	c.Assert(fs.Count(), Equals, 2)
+
+	c.Assert(readFile(c, base, "foo"), Equals, "foo")
+}
+
+func (s *CrashSuite) TestTornWrites(c *C) {
+	base := memory.New()
+	fs := New(base, &Options{After: 1, TornWrites: true})
+
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	n, err := f.Write([]byte("foobar"))
+	c.Assert(n, Equals, 3)
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+
+	n, err = f.Write([]byte("qux"))
+	c.Assert(n, Equals, 0)
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+	c.Assert(f.Close().(*os.PathError).Err, Equals, ErrCrashed)
+
+	c.Assert(readFile(c, base, "foo"), Equals, "foo")
+}
+
+func (s *CrashSuite) TestCrash(c *C) {
+	base := memory.New()
+	fs := New(base, nil)
+	dir := fs.Dir("qux")
+
+	c.Assert(dir.MkdirAll("bar", 0755), IsNil)
+	fs.Crash()
+	c.Assert(fs.Crashed(), Equals, true)
+	c.Assert(fs.Ping(context.Background()), Equals, ErrCrashed)
+	c.Assert(fs.Flush(context.Background()), Equals, ErrCrashed)
+	c.Assert(fs.Close(), Equals, ErrCrashed)
+
+	_, err := dir.Create("foo")
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+	_, err = base.Stat("qux/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(fs.Count(), Equals, 1)
+}
+
+// TestEveryPoint crashes a write through a temporary file renamed into place
+// at every point, checking that the file is always either the old or the new
+// one.
+func (s *CrashSuite) TestEveryPoint(c *C) {
+	save := func(fs billy.Filesystem) error {
+		f, err := fs.TempFile("", "foo")
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.Write([]byte("new")); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		return fs.Rename(f.Filename(), "foo")
+	}
+
+	fs := New(memory.New(), nil)
+	c.Assert(save(fs), IsNil)
+	c.Assert(fs.Count(), Equals, 3)
+
+	for i := 0; i <= fs.Count(); i++ {
+		base := memory.New()
+		writeFile(c, base, "foo", "old")
+
+		err := save(New(base, &Options{After: i, TornWrites: true}))
+		if i < fs.Count() {
+			c.Assert(err, NotNil)
+			c.Assert(readFile(c, base, "foo"), Equals, "old")
+		} else {
+			c.Assert(err, IsNil)
+			c.Assert(readFile(c, base, "foo"), Equals, "new")
+		}
+	}
+}
+
+func (s *CrashSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend:  "mem://crashfs",
+		Wrappers: []billy.WrapperConfig{{Name: "crash", Options: map[string]string{"after": "0"}}},
+	})
+	c.Assert(err, IsNil)
+
+	err = fs.MkdirAll("foo", 0755)
+	c.Assert(err.(*os.PathError).Err, Equals, ErrCrashed)
+
+	_, err = billy.Compose(&billy.Config{
+		Backend:  "mem://crashfs",
+		Wrappers: []billy.WrapperConfig{{Name: "crash", Options: map[string]string{"foo": "1"}}},
+	})
+	c.Assert(err, ErrorMatches, `.*unknown option "foo"`)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return string(b)
+}
@@ -0,0 +1,36 @@
+package crashfs
+
+import (
+	"fmt"
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("crash", wrap)
+}
+
+// wrap returns a Crash filesystem wrapping fs, crashing after the number of
+// operations given by the after option, if any, and with torn writes if the
+// torn-writes option is true.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	o := Options{After: -1}
+	for name, v := range opts {
+		var err error
+		switch name {
+		case "after":
+			o.After, err = strconv.Atoi(v)
+		case "torn-writes":
+			o.TornWrites, err = strconv.ParseBool(v)
+		default:
+			err = fmt.Errorf("crashfs: unknown option %q", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return New(fs, &o), nil
+}
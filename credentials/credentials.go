@@ -0,0 +1,164 @@
+// Package credentials provides the credentials consumed by the remote
+// backends, abstracting where they come from: static values, environment
+// variables, files, callbacks or refreshable tokens, so the applications can
+// plug their own flows uniformly.
+package credentials // import "srcd.works/go-billy.v1/credentials"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials is returned by a Provider without credentials to give.
+var ErrNoCredentials = errors.New("credentials: no credentials found")
+
+// Credentials holds the secrets authenticating against a backend, each
+// backend uses the fields it needs.
+type Credentials struct {
+	// Username is the user name, or the access key id.
+	Username string `json:"username,omitempty"`
+	// Password is the password, or the secret access key.
+	Password string `json:"password,omitempty"`
+	// Token is a session or bearer token.
+	Token string `json:"token,omitempty"`
+	// Expires is the time when the credentials expire, zero if they don't.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Expired returns true if the credentials expire before the given margin from
+// now.
+func (c *Credentials) Expired(margin time.Duration) bool {
+	return !c.Expires.IsZero() && !time.Now().Add(margin).Before(c.Expires)
+}
+
+// Provider gives the credentials of a backend, the backends call Retrieve
+// every time they need to authenticate, so the providers can rotate them.
+type Provider interface {
+	// Retrieve returns the current credentials.
+	Retrieve(ctx context.Context) (*Credentials, error)
+}
+
+// ProviderFunc is an adapter to use a function as a Provider, allowing
+// callbacks to external flows such as a Vault client.
+type ProviderFunc func(ctx context.Context) (*Credentials, error)
+
+// Retrieve calls f.
+func (f ProviderFunc) Retrieve(ctx context.Context) (*Credentials, error) {
+	return f(ctx)
+}
+
+// Static returns a Provider always giving the given credentials.
+func Static(c Credentials) Provider {
+	return ProviderFunc(func(context.Context) (*Credentials, error) {
+		cc := c
+		return &cc, nil
+	})
+}
+
+// Env returns a Provider reading the credentials from the environment
+// variables <prefix>_USERNAME, <prefix>_PASSWORD and <prefix>_TOKEN, giving
+// ErrNoCredentials if none of them is set.
+func Env(prefix string) Provider {
+	return ProviderFunc(func(context.Context) (*Credentials, error) {
+		c := &Credentials{
+			Username: os.Getenv(prefix + "_USERNAME"),
+			Password: os.Getenv(prefix + "_PASSWORD"),
+			Token:    os.Getenv(prefix + "_TOKEN"),
+		}
+
+		if *c == (Credentials{}) {
+			return nil, ErrNoCredentials
+		}
+
+		return c, nil
+	})
+}
+
+// File returns a Provider reading the credentials from a JSON file, with the
+// fields username, password, token and expires, as RFC 3339. The file is read
+// on every Retrieve, picking up the rotations, a missing file gives
+// ErrNoCredentials.
+func File(path string) Provider {
+	return ProviderFunc(func(context.Context) (*Credentials, error) {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, ErrNoCredentials
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		c := &Credentials{}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, err
+		}
+
+		return c, nil
+	})
+}
+
+// Chain returns a Provider giving the credentials of the first provider not
+// returning ErrNoCredentials.
+func Chain(providers ...Provider) Provider {
+	return ProviderFunc(func(ctx context.Context) (*Credentials, error) {
+		for _, p := range providers {
+			c, err := p.Retrieve(ctx)
+			if err != ErrNoCredentials {
+				return c, err
+			}
+		}
+
+		return nil, ErrNoCredentials
+	})
+}
+
+// Refreshing wraps a Provider of expiring credentials, such as the tokens of
+// a metadata service or a workload identity, caching them until they are
+// about to expire. It's safe for concurrent use.
+type Refreshing struct {
+	p      Provider
+	margin time.Duration
+
+	m sync.Mutex
+	c *Credentials
+}
+
+// NewRefreshing returns a new Refreshing provider, retrieving new credentials
+// from p when the cached ones expire before the given margin from now.
+func NewRefreshing(p Provider, margin time.Duration) *Refreshing {
+	return &Refreshing{p: p, margin: margin}
+}
+
+// Retrieve returns the cached credentials, refreshing them if needed.
+func (r *Refreshing) Retrieve(ctx context.Context) (*Credentials, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.c != nil && !r.c.Expired(r.margin) {
+		c := *r.c
+		return &c, nil
+	}
+
+	c, err := r.p.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.c = c
+	cc := *c
+	return &cc, nil
+}
+
+// Invalidate discards the cached credentials, to be called by the backends
+// when they are rejected before their expiration.
+func (r *Refreshing) Invalidate() {
+	r.m.Lock()
+	r.c = nil
+	r.m.Unlock()
+}
@@ -0,0 +1,89 @@
+package credentials
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CredentialsSuite struct{}
+
+var _ = Suite(&CredentialsSuite{})
+
+func (s *CredentialsSuite) TestStatic(c *C) {
+	cred, err := Static(Credentials{Username: "foo"}).Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cred.Username, Equals, "foo")
+}
+
+func (s *CredentialsSuite) TestEnv(c *C) {
+	p := Env("BILLY_TEST_CREDENTIALS")
+	_, err := p.Retrieve(context.Background())
+	c.Assert(err, Equals, ErrNoCredentials)
+
+	os.Setenv("BILLY_TEST_CREDENTIALS_TOKEN", "qux")
+	defer os.Unsetenv("BILLY_TEST_CREDENTIALS_TOKEN")
+
+	cred, err := p.Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cred, DeepEquals, &Credentials{Token: "qux"})
+}
+
+func (s *CredentialsSuite) TestFileAndChain(c *C) {
+	dir, err := ioutil.TempDir("", "billy-credentials")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials.json")
+	p := Chain(File(path), Static(Credentials{Username: "static"}))
+
+	cred, err := p.Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cred.Username, Equals, "static")
+
+	data := []byte(`{"username": "foo", "password": "bar", "expires": "2030-01-01T00:00:00Z"}`)
+	c.Assert(ioutil.WriteFile(path, data, 0600), IsNil)
+
+	cred, err = p.Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(cred.Username, Equals, "foo")
+	c.Assert(cred.Password, Equals, "bar")
+	c.Assert(cred.Expires.Year(), Equals, 2030)
+}
+
+func (s *CredentialsSuite) TestRefreshing(c *C) {
+	var calls int
+	expires := time.Now().Add(time.Hour)
+	r := NewRefreshing(ProviderFunc(func(context.Context) (*Credentials, error) {
+		calls++
+		return &Credentials{Token: "token", Expires: expires}, nil
+	}), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		cred, err := r.Retrieve(context.Background())
+		c.Assert(err, IsNil)
+		c.Assert(cred.Token, Equals, "token")
+	}
+
+	c.Assert(calls, Equals, 1)
+
+	r.Invalidate()
+	_, err := r.Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 2)
+
+	expires = time.Now().Add(time.Second)
+	r.Invalidate()
+	_, err = r.Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	_, err = r.Retrieve(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 4)
+}
@@ -0,0 +1,465 @@
+// Package cryptfs provides a billy filesystem wrapper encrypting the content
+// of the files with AES-256-GCM, using a random data key per file wrapped
+// with a master key. Optionally the files are written, and read, in the
+// format of the AWS S3 client-side encryption, so the objects written
+// through billy over s3fs can be read by the AWS SDK tooling and vice versa.
+package cryptfs // import "srcd.works/go-billy.v1/cryptfs"
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// KeySize is the size of the master keys, AES-256.
+const KeySize = 32
+
+var (
+	errKeySize         = fmt.Errorf("cryptfs: the key must be of %d bytes", KeySize)
+	errIsDirectory     = syscall.EISDIR
+	errInvalidFile     = errors.New("cryptfs: invalid encrypted file")
+	errWriteNotAllowed = errors.New("write not supported")
+)
+
+// Format is the layout of the encrypted files.
+type Format int
+
+const (
+	// Native keeps the envelope of every file, its wrapped data key and IV,
+	// in a header of the file, so the files are self-contained.
+	Native Format = iota
+	// S3 keeps the content in the format of the AWS S3 client-side
+	// encryption v2, AES/GCM/NoPadding with the data key wrapped with
+	// AES/GCM, and the envelope in an instruction file next to every file,
+	// named as it with the InstructionSuffix. The AWS SDK clients supporting
+	// raw AES master keys read them, and the objects they write are read if
+	// they are configured to keep the envelope in instruction files, not in
+	// the metadata of the objects, unreachable through billy.
+	S3
+)
+
+// InstructionSuffix is the suffix of the instruction files of the S3
+// format, hidden from the listings.
+const InstructionSuffix = ".instruction"
+
+// Options holds the configuration of a Crypt filesystem.
+type Options struct {
+	// Format is the layout of the files, Native by default.
+	Format Format
+}
+
+// Crypt wraps a billy.Filesystem encrypting the content of the files. The
+// names, the directories, the symbolic links and the modes aren't encrypted.
+// The files are encrypted and decrypted as a whole: the ones opened for
+// reading are read at once, and the ones opened for writing are kept in
+// memory, and written on Sync and Close.
+type Crypt struct {
+	fs     billy.Filesystem
+	master cipher.AEAD
+	format Format
+}
+
+// New returns a new Crypt filesystem wrapping fs, with the given master key,
+// of KeySize bytes. If opts is nil the default options are used.
+func New(fs billy.Filesystem, key []byte, opts *Options) (*Crypt, error) {
+	if len(key) != KeySize {
+		return nil, errKeySize
+	}
+
+	master, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+
+	return &Crypt{fs: fs, master: master, format: o.Format}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Create creates a file and opens it with standard permissions
+// and modes O_RDWR, O_CREATE and O_TRUNC.
+func (fs *Crypt) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens a file in read-only mode.
+func (fs *Crypt) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, decrypting it if it exists.
+func (fs *Crypt) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if !isWrite(flag) {
+		return fs.openRead(filename, flag, perm)
+	}
+
+	return fs.openWrite(filename, flag, perm)
+}
+
+func (fs *Crypt) openRead(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fs.decrypt(f, filename)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	return newReader(f.Filename(), &fileInfo{fi, int64(len(content))}, content), nil
+}
+
+func (fs *Crypt) openWrite(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fi, err := fs.fs.Stat(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	exists := err == nil
+	switch {
+	case exists && fi.IsDir():
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDirectory}
+	case exists && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	}
+
+	var content []byte
+	if exists {
+		perm = fi.Mode().Perm()
+		if flag&os.O_TRUNC == 0 {
+			if content, err = fs.readFile(filename); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := fs.writeFile(filename, nil, perm); err != nil {
+		return nil, err
+	}
+
+	return newWriter(fs, filename, content, flag, perm)
+}
+
+// readFile returns the decrypted content of the named file.
+func (fs *Crypt) readFile(filename string) ([]byte, error) {
+	f, err := fs.fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	content, err := fs.decrypt(f, filename)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	return content, nil
+}
+
+// writeFile encrypts content to the named file, and writes its instruction
+// file with the S3 format.
+func (fs *Crypt) writeFile(filename string, content []byte, perm os.FileMode) error {
+	e, ciphertext, err := fs.seal(content)
+	if err != nil {
+		return err
+	}
+
+	if fs.format == Native {
+		ciphertext = append(e.header(), ciphertext...)
+	}
+
+	if err := write(fs.fs, filename, ciphertext, perm); err != nil {
+		return err
+	}
+
+	if fs.format != S3 {
+		return nil
+	}
+
+	instruction, err := e.instruction(len(content))
+	if err != nil {
+		return err
+	}
+
+	return write(fs.fs, filename+InstructionSuffix, instruction, perm)
+}
+
+// decrypt returns the decrypted content of f, opened as filename.
+func (fs *Crypt) decrypt(f billy.File, filename string) ([]byte, error) {
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var e *envelope
+	if fs.format == S3 {
+		e, err = fs.readInstruction(filename)
+	} else {
+		e, data, err = readHeader(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.open(e, data)
+}
+
+func (fs *Crypt) readInstruction(filename string) (*envelope, error) {
+	f, err := fs.fs.Open(filename + InstructionSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return readInstruction(f)
+}
+
+// Stat returns the FileInfo of the named file, with the size of its
+// decrypted content.
+func (fs *Crypt) Stat(filename string) (billy.FileInfo, error) {
+	fi, err := fs.fs.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(fi), nil
+}
+
+// Lstat returns the FileInfo of the named file, as Stat, without following
+// the symbolic links.
+func (fs *Crypt) Lstat(filename string) (billy.FileInfo, error) {
+	fi, err := fs.fs.Lstat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(fi), nil
+}
+
+// ReadDir returns the entries of the given directory, sorted by name, with
+// the sizes of the decrypted contents, and without the instruction files.
+func (fs *Crypt) ReadDir(dir string) ([]billy.FileInfo, error) {
+	l, err := fs.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]billy.FileInfo, 0, len(l))
+	for _, fi := range l {
+		if fs.isInstruction(fi) {
+			continue
+		}
+
+		infos = append(infos, fs.info(fi))
+	}
+
+	return infos, nil
+}
+
+// info returns fi with the size of the decrypted content, if it's a regular
+// file.
+func (fs *Crypt) info(fi billy.FileInfo) billy.FileInfo {
+	if !fi.Mode().IsRegular() {
+		return fi
+	}
+
+	overhead := int64(tagSize)
+	if fs.format == Native {
+		overhead += int64(headerSize)
+	}
+
+	size := fi.Size() - overhead
+	if size < 0 {
+		size = 0
+	}
+
+	return &fileInfo{fi, size}
+}
+
+func (fs *Crypt) isInstruction(fi billy.FileInfo) bool {
+	return fs.format == S3 && !fi.IsDir() && strings.HasSuffix(fi.Name(), InstructionSuffix)
+}
+
+// TempFile creates a new temporal file, with a random name starting with
+// prefix, in dir.
+func (fs *Crypt) TempFile(dir, prefix string) (billy.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := fs.Join(dir, prefix+strconv.FormatInt(rand.Int63(), 10))
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+
+		return f, err
+	}
+
+	return nil, fmt.Errorf("temp file in %s: too many attempts", dir)
+}
+
+// Rename moves a file or a directory, along with its instruction file with
+// the S3 format.
+func (fs *Crypt) Rename(from, to string) error {
+	fi, err := fs.fs.Lstat(from)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.fs.Rename(from, to); err != nil {
+		return err
+	}
+
+	if fs.format != S3 || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	err = fs.fs.Rename(from+InstructionSuffix, to+InstructionSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Remove removes a file or an empty directory, along with its instruction
+// file with the S3 format.
+func (fs *Crypt) Remove(filename string) error {
+	fi, err := fs.fs.Lstat(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.fs.Remove(filename); err != nil {
+		return err
+	}
+
+	if fs.format != S3 || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	err = fs.fs.Remove(filename + InstructionSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Symlink creates a symbolic link, its target isn't encrypted. With the S3
+// format it returns billy.ErrNotSupported, since the instruction files are
+// looked up by the names of the files, not of their targets.
+func (fs *Crypt) Symlink(target, link string) error {
+	if fs.format == S3 {
+		return billy.ErrNotSupported
+	}
+
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Crypt) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *Crypt) MkdirAll(path string, perm os.FileMode) error {
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Crypt) Chmod(name string, mode os.FileMode) error {
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Crypt) Chtimes(name string, atime, mtime time.Time) error {
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Crypt) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Crypt filesystem rooted at the given path, with the same
+// key and format.
+func (fs *Crypt) Dir(path string) billy.Filesystem {
+	return &Crypt{fs: fs.fs.Dir(path), master: fs.master, format: fs.format}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Crypt) Base() string {
+	return fs.fs.Base()
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Crypt) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Flush flushes the underlying filesystem, as billy.Flush.
+func (fs *Crypt) Flush(ctx context.Context) error {
+	return billy.Flush(ctx, fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close.
+func (fs *Crypt) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// write writes content to the named file of fs.
+func write(fs billy.Filesystem, filename string, content []byte, perm os.FileMode) error {
+	f, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+strings.Replace(p, `\`, "/", -1)), "/")
+}
@@ -0,0 +1,204 @@
+package cryptfs
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+var key = bytes.Repeat([]byte{42}, KeySize)
+
+type NativeSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&NativeSuite{})
+
+func (s *NativeSuite) SetUpTest(c *C) {
+	fs, err := New(memory.New(), key, nil)
+	c.Assert(err, IsNil)
+	s.FilesystemSuite.Fs = fs
+}
+
+type S3Suite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&S3Suite{})
+
+func (s *S3Suite) SetUpTest(c *C) {
+	fs, err := New(memory.New(), key, &Options{Format: S3})
+	c.Assert(err, IsNil)
+	s.FilesystemSuite.Fs = fs
+}
+
+type CryptSuite struct {
+	backend billy.Filesystem
+}
+
+var _ = Suite(&CryptSuite{})
+
+func (s *CryptSuite) SetUpTest(c *C) {
+	s.backend = memory.New()
+}
+
+func (s *CryptSuite) TestNew(c *C) {
+	_, err := New(s.backend, key[1:], nil)
+	c.Assert(err, Equals, errKeySize)
+}
+
+func (s *CryptSuite) TestEncrypted(c *C) {
+	fs, err := New(s.backend, key, nil)
+	c.Assert(err, IsNil)
+	writeFile(c, fs, "foo", "foo content")
+
+	content := readFile(c, s.backend, "foo")
+	c.Assert(content, HasLen, headerSize+len("foo content")+tagSize)
+	c.Assert(bytes.Contains([]byte(content), []byte("foo content")), Equals, false)
+
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(len("foo content")))
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo content")
+
+	other, err := New(s.backend, bytes.Repeat([]byte{1}, KeySize), nil)
+	c.Assert(err, IsNil)
+	_, err = other.Open("foo")
+	c.Assert(err, ErrorMatches, "open foo: .*authentication failed")
+}
+
+func (s *CryptSuite) TestS3Instruction(c *C) {
+	fs, err := New(s.backend, key, &Options{Format: S3})
+	c.Assert(err, IsNil)
+	writeFile(c, fs, "qux/foo", "foo content")
+
+	l, err := fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	c.Assert(l[0].Name(), Equals, "foo")
+	c.Assert(l[0].Size(), Equals, int64(len("foo content")))
+
+	var i map[string]string
+	c.Assert(json.Unmarshal([]byte(readFile(c, s.backend, "qux/foo.instruction")), &i), IsNil)
+	c.Assert(i["x-amz-cek-alg"], Equals, "AES/GCM/NoPadding")
+	c.Assert(i["x-amz-wrap-alg"], Equals, "AES/GCM")
+	c.Assert(i["x-amz-tag-len"], Equals, "128")
+	c.Assert(i["x-amz-unencrypted-content-length"], Equals, "11")
+
+	// decrypted as the AWS SDK does: the data key is wrapped with AES-GCM,
+	// prefixed by its nonce and authenticating the content algorithm.
+	wrapped := decode(c, i["x-amz-key-v2"])
+	dataKey, err := gcm(c, key).Open(nil, wrapped[:12], wrapped[12:], []byte("AES/GCM/NoPadding"))
+	c.Assert(err, IsNil)
+
+	ciphertext := readFile(c, s.backend, "qux/foo")
+	content, err := gcm(c, dataKey).Open(nil, decode(c, i["x-amz-iv"]), []byte(ciphertext), nil)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo content")
+
+	c.Assert(fs.Rename("qux/foo", "bar"), IsNil)
+	c.Assert(readFile(c, fs, "bar"), Equals, "foo content")
+	c.Assert(fs.Remove("bar"), IsNil)
+
+	l, err = s.backend.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 0)
+}
+
+func (s *CryptSuite) TestS3ReadSDKObject(c *C) {
+	dataKey := bytes.Repeat([]byte{7}, 32)
+	nonce, iv := bytes.Repeat([]byte{1}, 12), bytes.Repeat([]byte{2}, 12)
+	wrapped := gcm(c, key).Seal(append([]byte(nil), nonce...), nonce, dataKey, []byte("AES/GCM/NoPadding"))
+
+	writeFile(c, s.backend, "foo", string(gcm(c, dataKey).Seal(nil, iv, []byte("foo content"), nil)))
+	writeFile(c, s.backend, "foo.instruction", `{
+		"x-amz-key-v2": "`+base64.StdEncoding.EncodeToString(wrapped)+`",
+		"x-amz-iv": "`+base64.StdEncoding.EncodeToString(iv)+`",
+		"x-amz-cek-alg": "AES/GCM/NoPadding",
+		"x-amz-wrap-alg": "AES/GCM",
+		"x-amz-tag-len": "128",
+		"x-amz-matdesc": "{}"
+	}`)
+
+	fs, err := New(s.backend, key, &Options{Format: S3})
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo content")
+
+	writeFile(c, s.backend, "bar", "")
+	writeFile(c, s.backend, "bar.instruction", `{
+		"x-amz-cek-alg": "AES/CBC/PKCS5Padding",
+		"x-amz-wrap-alg": "kms+context",
+		"x-amz-tag-len": "128"
+	}`)
+
+	_, err = fs.Open("bar")
+	c.Assert(err, ErrorMatches, ".*unsupported encryption AES/CBC/PKCS5Padding with kms\\+context key wrapping")
+}
+
+func (s *CryptSuite) TestCompose(c *C) {
+	os.Setenv("CRYPTFS_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+	defer os.Unsetenv("CRYPTFS_TEST_KEY")
+
+	fs, err := billy.Compose(&billy.Config{
+		Backend: "mem://cryptfs",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "crypt",
+			Options: map[string]string{"key-env": "CRYPTFS_TEST_KEY", "format": "s3"},
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fs, FitsTypeOf, &Crypt{})
+	c.Assert(fs.(*Crypt).format, Equals, S3)
+
+	_, err = billy.Compose(&billy.Config{
+		Backend: "mem://cryptfs",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "crypt",
+			Options: map[string]string{"key-env": "CRYPTFS_TEST_KEY", "format": "foo"},
+		}},
+	})
+	c.Assert(err, ErrorMatches, `.*cryptfs: unknown format "foo"`)
+}
+
+func gcm(c *C, key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	c.Assert(err, IsNil)
+	aead, err := cipher.NewGCM(block)
+	c.Assert(err, IsNil)
+	return aead
+}
+
+func decode(c *C, s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	c.Assert(err, IsNil)
+	return b
+}
+
+func writeFile(c *C, fs billy.Filesystem, name, content string) {
+	f, err := fs.Create(name)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, name string) string {
+	f, err := fs.Open(name)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
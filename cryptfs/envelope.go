@@ -0,0 +1,150 @@
+package cryptfs
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	// cekAlg and wrapAlg are the algorithms of the content and of the key
+	// wrapping, as named by the AWS S3 client-side encryption.
+	cekAlg  = "AES/GCM/NoPadding"
+	wrapAlg = "AES/GCM"
+
+	dataKeySize = 32
+	ivSize      = 12
+	tagSize     = 16
+	// wrappedKeySize is the size of a wrapped data key: the nonce used to
+	// wrap it, followed by the encrypted key and its tag.
+	wrappedKeySize = ivSize + dataKeySize + tagSize
+
+	// magic starts the header of the Native format, followed by the wrapped
+	// data key and the IV of the content.
+	magic      = "BCR1"
+	headerSize = len(magic) + wrappedKeySize + ivSize
+)
+
+// envelope holds the data key of a file, wrapped with the master key, and
+// the IV of its content.
+type envelope struct {
+	key []byte
+	iv  []byte
+}
+
+// instruction is an instruction file of the AWS S3 client-side encryption
+// v2, it holds the same keys as the metadata of the objects.
+type instruction struct {
+	Key           string `json:"x-amz-key-v2"`
+	IV            string `json:"x-amz-iv"`
+	CEKAlg        string `json:"x-amz-cek-alg"`
+	WrapAlg       string `json:"x-amz-wrap-alg"`
+	TagLen        string `json:"x-amz-tag-len"`
+	MatDesc       string `json:"x-amz-matdesc"`
+	ContentLength string `json:"x-amz-unencrypted-content-length,omitempty"`
+}
+
+// seal encrypts content with a new data key, returning its envelope and the
+// ciphertext, followed by its tag. The data key is wrapped with the master
+// key, authenticating the name of the content algorithm, as done by the AWS
+// S3 client-side encryption.
+func (fs *Crypt) seal(content []byte) (*envelope, []byte, error) {
+	key := make([]byte, dataKeySize+ivSize+ivSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, err
+	}
+
+	key, iv, nonce := key[:dataKeySize], key[dataKeySize:dataKeySize+ivSize], key[dataKeySize+ivSize:]
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapped := append([]byte(nil), nonce...)
+	e := &envelope{
+		key: fs.master.Seal(wrapped, nonce, key, []byte(cekAlg)),
+		iv:  iv,
+	}
+
+	return e, aead.Seal(nil, iv, content, nil), nil
+}
+
+// open decrypts the ciphertext sealed with the envelope e.
+func (fs *Crypt) open(e *envelope, ciphertext []byte) ([]byte, error) {
+	if len(e.key) != wrappedKeySize || len(e.iv) != ivSize {
+		return nil, errInvalidFile
+	}
+
+	key, err := fs.master.Open(nil, e.key[:ivSize], e.key[ivSize:], []byte(cekAlg))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, e.iv, ciphertext, nil)
+}
+
+// header returns the header of e in the Native format.
+func (e *envelope) header() []byte {
+	h := make([]byte, 0, headerSize)
+	h = append(h, magic...)
+	h = append(h, e.key...)
+	return append(h, e.iv...)
+}
+
+// readHeader returns the envelope in the header of data, in the Native
+// format, and the ciphertext following it.
+func readHeader(data []byte) (*envelope, []byte, error) {
+	if len(data) < headerSize+tagSize || string(data[:len(magic)]) != magic {
+		return nil, nil, errInvalidFile
+	}
+
+	key := data[len(magic) : len(magic)+wrappedKeySize]
+	return &envelope{key: key, iv: data[len(magic)+wrappedKeySize : headerSize]}, data[headerSize:], nil
+}
+
+// instruction returns the instruction file of e, for a content of the
+// given size.
+func (e *envelope) instruction(size int) ([]byte, error) {
+	return json.Marshal(&instruction{
+		Key:           base64.StdEncoding.EncodeToString(e.key),
+		IV:            base64.StdEncoding.EncodeToString(e.iv),
+		CEKAlg:        cekAlg,
+		WrapAlg:       wrapAlg,
+		TagLen:        strconv.Itoa(tagSize * 8),
+		MatDesc:       "{}",
+		ContentLength: strconv.Itoa(size),
+	})
+}
+
+// readInstruction returns the envelope of the instruction file read from r,
+// failing if its algorithms aren't supported.
+func readInstruction(r io.Reader) (*envelope, error) {
+	var i instruction
+	if err := json.NewDecoder(r).Decode(&i); err != nil {
+		return nil, err
+	}
+
+	if i.CEKAlg != cekAlg || i.WrapAlg != wrapAlg || i.TagLen != strconv.Itoa(tagSize*8) {
+		return nil, fmt.Errorf("cryptfs: unsupported encryption %s with %s key wrapping", i.CEKAlg, i.WrapAlg)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(i.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := base64.StdEncoding.DecodeString(i.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope{key: key, iv: iv}, nil
+}
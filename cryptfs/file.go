@@ -0,0 +1,165 @@
+package cryptfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+// writer is a file open for writing, kept in memory and encrypted to the
+// wrapped filesystem on Sync and Close.
+type writer struct {
+	billy.File
+	fs       *Crypt
+	m        *memory.Memory
+	name     string
+	filename string
+	perm     os.FileMode
+}
+
+func newWriter(fs *Crypt, filename string, content []byte, flag int, perm os.FileMode) (*writer, error) {
+	name := path.Base(clean(filename))
+	m, err := staged(name, content, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := m.OpenFile(name, (flag|os.O_CREATE)&^os.O_EXCL, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writer{File: f, fs: fs, m: m, name: name, filename: filename, perm: perm}, nil
+}
+
+// staged returns a memory filesystem holding the named file, with the given
+// content, to be written through a writer.
+func staged(name string, content []byte, perm os.FileMode) (*memory.Memory, error) {
+	m := memory.New()
+	if len(content) == 0 {
+		return m, nil
+	}
+
+	return m, write(m, name, content, perm)
+}
+
+func (w *writer) Filename() string {
+	return clean(w.filename)
+}
+
+func (w *writer) ReadAt(p []byte, off int64) (int, error) {
+	return w.File.(io.ReaderAt).ReadAt(p, off)
+}
+
+func (w *writer) Lock() error   { return billy.ErrNotSupported }
+func (w *writer) Unlock() error { return billy.ErrNotSupported }
+
+// Sync encrypts the content written to the wrapped filesystem, and syncs it.
+func (w *writer) Sync() error {
+	if w.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	return w.flush()
+}
+
+// Truncate changes the size of the file, and writes it as Sync, so the new
+// size is seen right away.
+func (w *writer) Truncate(size int64) error {
+	if err := w.File.Truncate(size); err != nil {
+		return err
+	}
+
+	return w.flush()
+}
+
+func (w *writer) Close() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+
+	return w.flush()
+}
+
+func (w *writer) flush() error {
+	f, err := w.m.Open(w.name)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return w.fs.writeFile(w.filename, content, w.perm)
+}
+
+// reader is a file open for reading, decrypted at once.
+type reader struct {
+	billy.BaseFile
+	*bytes.Reader
+	fi billy.FileInfo
+}
+
+func newReader(filename string, fi billy.FileInfo, content []byte) *reader {
+	return &reader{
+		BaseFile: billy.BaseFile{BaseFilename: filename},
+		Reader:   bytes.NewReader(content),
+		fi:       fi,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return r.Reader.Read(p)
+}
+
+func (r *reader) ReadAt(p []byte, off int64) (int, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return r.Reader.ReadAt(p, off)
+}
+
+func (r *reader) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: r.Filename(), Err: errWriteNotAllowed}
+}
+
+func (r *reader) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: r.Filename(), Err: errWriteNotAllowed}
+}
+
+func (r *reader) Stat() (billy.FileInfo, error) { return r.fi, nil }
+func (r *reader) Sync() error                   { return nil }
+func (r *reader) Lock() error                   { return billy.ErrNotSupported }
+func (r *reader) Unlock() error                 { return billy.ErrNotSupported }
+
+func (r *reader) Close() error {
+	if r.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	r.Closed = true
+	return nil
+}
+
+// fileInfo is the FileInfo of an encrypted file, with the size of its
+// decrypted content.
+type fileInfo struct {
+	billy.FileInfo
+	size int64
+}
+
+func (fi *fileInfo) Size() int64 { return fi.size }
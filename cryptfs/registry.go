@@ -0,0 +1,40 @@
+package cryptfs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("crypt", wrap)
+}
+
+// wrap returns a Crypt filesystem wrapping fs, with the master key read,
+// base64 encoded, from the environment variable named by the key-env option,
+// CRYPT_KEY by default, and the format option, native or s3. The key isn't
+// taken from the options, so it's not written to the configuration files.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	env := opts["key-env"]
+	if env == "" {
+		env = "CRYPT_KEY"
+	}
+
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(env))
+	if err != nil {
+		return nil, fmt.Errorf("cryptfs: decoding %s: %s", env, err)
+	}
+
+	var o Options
+	switch opts["format"] {
+	case "", "native":
+	case "s3":
+		o.Format = S3
+	default:
+		return nil, fmt.Errorf("cryptfs: unknown format %q", opts["format"])
+	}
+
+	return New(fs, key, &o)
+}
@@ -0,0 +1,58 @@
+package billy
+
+import "os"
+
+// DirEntry is an entry read from a directory. Unlike FileInfo only the name
+// and the type of the file are known, which is cheap to obtain from most
+// backends.
+type DirEntry interface {
+	// Name returns the name of the file described by the entry.
+	Name() string
+	// IsDir reports whether the entry describes a directory.
+	IsDir() bool
+	// Type returns the type bits of the entry, a subset of os.ModeType.
+	Type() os.FileMode
+	// Info returns the FileInfo of the entry. It may be resolved lazily,
+	// calling Stat on the backend only the first time it's requested.
+	Info() (FileInfo, error)
+}
+
+// EntryReader is an optional interface implemented by the filesystems able to
+// list a directory without retrieving the full FileInfo of every entry.
+type EntryReader interface {
+	// ReadDirEntries returns the entries of the given directory.
+	ReadDirEntries(path string) ([]DirEntry, error)
+}
+
+// ReadDirEntries returns the entries of the given directory, using the
+// EntryReader implementation of fs if available or ReadDir otherwise.
+func ReadDirEntries(fs Filesystem, path string) ([]DirEntry, error) {
+	if r, ok := fs.(EntryReader); ok {
+		return r.ReadDirEntries(path)
+	}
+
+	files, err := fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirEntry, len(files))
+	for i, fi := range files {
+		entries[i] = &fileInfoEntry{fi}
+	}
+
+	return entries, nil
+}
+
+// fileInfoEntry is a DirEntry based on an already known FileInfo.
+type fileInfoEntry struct {
+	FileInfo
+}
+
+func (e *fileInfoEntry) Type() os.FileMode {
+	return e.Mode() & os.ModeType
+}
+
+func (e *fileInfoEntry) Info() (FileInfo, error) {
+	return e.FileInfo, nil
+}
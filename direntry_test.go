@@ -0,0 +1,33 @@
+package billy_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type DirEntrySuite struct{}
+
+var _ = Suite(&DirEntrySuite{})
+
+func (s *DirEntrySuite) TestReadDirEntries(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo", "foo")
+	writeFile(c, fs, "qux/bar/baz", "baz")
+
+	entries, err := billy.ReadDirEntries(fs, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+
+	found := make(map[string]billy.DirEntry)
+	for _, e := range entries {
+		found[e.Name()] = e
+	}
+
+	c.Assert(found["foo"].IsDir(), Equals, false)
+	c.Assert(found["foo"].Type(), Equals, os.FileMode(0))
+	c.Assert(found["bar"].IsDir(), Equals, true)
+	c.Assert(found["bar"].Type(), Equals, os.ModeDir)
+}
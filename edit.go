@@ -0,0 +1,178 @@
+package billy
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ErrInvalidOffset is returned by InsertAt when the offset is negative or
+// beyond the end of the file.
+var ErrInvalidOffset = errors.New("invalid offset")
+
+// editPrefix is the prefix of the temporary files written by the edits.
+const editPrefix = ".edit"
+
+// ReplaceBytes replaces the first n non-overlapping instances of old by new in
+// the named file, all of them if n is negative, as bytes.Replace, returning
+// the number of replacements. The file is only written if anything was
+// replaced.
+//
+// As every edit, the new content is written to a temporary file next to the
+// named one, which is then renamed over it, so a failure in between leaves the
+// file as it was. The permissions of the file are kept and the symbolic links
+// are followed, editing their target. The whole content is held in memory.
+func ReplaceBytes(fs Filesystem, filename string, old, new []byte, n int) (int, error) {
+	var count int
+	err := editFile(fs, filename, false, func(content []byte) ([]byte, error) {
+		count = bytes.Count(content, old)
+		if n >= 0 && count > n {
+			count = n
+		}
+
+		if count == 0 {
+			return nil, nil
+		}
+
+		return bytes.Replace(content, old, new, n), nil
+	})
+
+	return count, err
+}
+
+// InsertAt inserts data in the named file at the given offset, moving the
+// following content, offset being at most the size of the file. The file is
+// written as described by ReplaceBytes.
+func InsertAt(fs Filesystem, filename string, offset int64, data []byte) error {
+	return editFile(fs, filename, false, func(content []byte) ([]byte, error) {
+		if offset < 0 || offset > int64(len(content)) {
+			return nil, &os.PathError{Op: "insert", Path: filename, Err: ErrInvalidOffset}
+		}
+
+		result := make([]byte, 0, len(content)+len(data))
+		result = append(result, content[:offset]...)
+		result = append(result, data...)
+		return append(result, content[offset:]...), nil
+	})
+}
+
+// AppendLine appends line to the named file, followed by "\n", creating the
+// file if it doesn't exist. If the last line of the file isn't terminated a
+// "\n" is added before line. The file is written as described by
+// ReplaceBytes, unlike appending to it, so the readers never see a partial
+// line.
+func AppendLine(fs Filesystem, filename, line string) error {
+	return editFile(fs, filename, true, func(content []byte) ([]byte, error) {
+		if len(content) != 0 && content[len(content)-1] != '\n' {
+			content = append(content, '\n')
+		}
+
+		return append(append(content, line...), '\n'), nil
+	})
+}
+
+// editFile replaces the content of the named file by the result of fn, unless
+// it returns nil. If create is true the missing files are edited as empty.
+func editFile(fs Filesystem, filename string, create bool, fn func([]byte) ([]byte, error)) error {
+	filename, err := resolveLinks(fs, filename)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	perm := os.FileMode(0666)
+	fi, err := fs.Stat(filename)
+	switch {
+	case err == nil && !fi.Mode().IsRegular():
+		return &os.PathError{Op: "edit", Path: filename, Err: ErrNotSupported}
+	case err == nil:
+		perm = fi.Mode().Perm()
+		if content, err = readContent(fs, filename); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err) || !create:
+		return err
+	}
+
+	content, err = fn(content)
+	if err != nil || content == nil {
+		return err
+	}
+
+	return writeEdited(fs, filename, content, perm)
+}
+
+// writeEdited replaces the named file through a temporary file, with the
+// given permissions.
+func writeEdited(fs Filesystem, filename string, content []byte, perm os.FileMode) error {
+	f, tmpfs, err := TempFileFor(fs, nil, filename, editPrefix)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := tmpfs.Chmod(f.Filename(), perm); err != nil && err != ErrNotSupported {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := Move(tmpfs, f.Filename(), fs, filename); err != nil {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	return nil
+}
+
+func readContent(fs Filesystem, filename string) ([]byte, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// resolveLinks returns the name of the file the symbolic link filename points
+// to, following up to MaxLinks(fs) links, or filename itself if it's not a
+// link. The absolute targets are rooted at the base of fs.
+func resolveLinks(fs Filesystem, filename string) (string, error) {
+	for i, max := 0, MaxLinks(fs); i < max; i++ {
+		fi, err := fs.Lstat(filename)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return filename, nil
+		}
+
+		target, err := fs.Readlink(filename)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(target) {
+			target = fs.Join(filepath.Dir(filename), target)
+		}
+
+		filename = filepath.Clean(target)
+	}
+
+	return "", &os.PathError{Op: "edit", Path: filename, Err: ErrTooManyLinks}
+}
@@ -0,0 +1,77 @@
+package billy_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type EditSuite struct {
+	fs billy.Filesystem
+}
+
+var _ = Suite(&EditSuite{})
+
+func (s *EditSuite) SetUpTest(c *C) {
+	s.fs = memory.New()
+	writeFile(c, s.fs, "qux/foo", "foo\x00bar\x00foo")
+	c.Assert(s.fs.Chmod("qux/foo", 0640), IsNil)
+}
+
+func (s *EditSuite) TestReplaceBytes(c *C) {
+	n, err := billy.ReplaceBytes(s.fs, "qux/foo", []byte("foo"), []byte("qux"), 1)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "qux\x00bar\x00foo")
+
+	n, err = billy.ReplaceBytes(s.fs, "qux/foo", []byte("\x00"), nil, -1)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 2)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "quxbarfoo")
+
+	n, err = billy.ReplaceBytes(s.fs, "qux/foo", []byte("baz"), nil, -1)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+
+	fi, err := s.fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0640))
+
+	l, err := s.fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+
+	_, err = billy.ReplaceBytes(s.fs, "missing", []byte("foo"), nil, -1)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *EditSuite) TestInsertAt(c *C) {
+	c.Assert(billy.InsertAt(s.fs, "qux/foo", 3, []byte("baz")), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foobaz\x00bar\x00foo")
+	c.Assert(billy.InsertAt(s.fs, "qux/foo", 14, []byte("!")), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foobaz\x00bar\x00foo!")
+
+	err := billy.InsertAt(s.fs, "qux/foo", 16, []byte("!"))
+	c.Assert(err, ErrorMatches, ".*invalid offset")
+}
+
+func (s *EditSuite) TestAppendLine(c *C) {
+	c.Assert(billy.AppendLine(s.fs, "bar", "foo"), IsNil)
+	c.Assert(billy.AppendLine(s.fs, "bar", "bar"), IsNil)
+	c.Assert(readFile(c, s.fs, "bar"), Equals, "foo\nbar\n")
+
+	c.Assert(billy.AppendLine(s.fs, "qux/foo", "baz"), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foo\x00bar\x00foo\nbaz\n")
+}
+
+func (s *EditSuite) TestSymlink(c *C) {
+	c.Assert(s.fs.Symlink("qux/foo", "link"), IsNil)
+	c.Assert(billy.AppendLine(s.fs, "link", "baz"), IsNil)
+
+	fi, err := s.fs.Lstat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foo\x00bar\x00foo\nbaz\n")
+}
@@ -0,0 +1,66 @@
+package billy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MultiError is returned by the operations going on after failing on some
+// paths, such as Sweep, reporting every failure instead of only the first one.
+type MultiError struct {
+	// Errors are the errors found, in order, usually *os.PathError.
+	Errors []error
+}
+
+// Add adds err to the errors, if not nil. The errors without path, those
+// other than *os.PathError and *os.LinkError, are wrapped in an
+// *os.PathError with the given op and path.
+func (e *MultiError) Add(op, path string, err error) {
+	switch err.(type) {
+	case nil:
+		return
+	case *os.PathError, *os.LinkError:
+	default:
+		err = &os.PathError{Op: op, Path: path, Err: err}
+	}
+
+	e.Errors = append(e.Errors, err)
+}
+
+// Err returns e if it holds any error, otherwise nil, as the error to be
+// returned by the operation.
+func (e *MultiError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d errors: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// CloseAll closes all the given files, even if closing some of them fails,
+// returning a *MultiError with the failures. The nil files are skipped, so it
+// can be deferred right after opening several files.
+func CloseAll(files ...File) error {
+	var errs MultiError
+	for _, f := range files {
+		if f != nil {
+			errs.Add("close", f.Filename(), f.Close())
+		}
+	}
+
+	return errs.Err()
+}
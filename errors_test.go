@@ -0,0 +1,44 @@
+package billy_test
+
+import (
+	"errors"
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type ErrorsSuite struct{}
+
+var _ = Suite(&ErrorsSuite{})
+
+func (s *ErrorsSuite) TestMultiError(c *C) {
+	var errs billy.MultiError
+	errs.Add("remove", "foo", nil)
+	c.Assert(errs.Err(), IsNil)
+
+	errs.Add("remove", "foo", errors.New("failed"))
+	c.Assert(errs.Err(), ErrorMatches, "remove foo: failed")
+
+	pathErr := &os.PathError{Op: "open", Path: "bar", Err: os.ErrNotExist}
+	errs.Add("remove", "qux", pathErr)
+	c.Assert(errs.Errors[1], Equals, pathErr)
+	c.Assert(errs.Err(), ErrorMatches, "2 errors: remove foo: failed; open bar: .*")
+}
+
+func (s *ErrorsSuite) TestCloseAll(c *C) {
+	fs := memory.New()
+	foo, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	bar, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(billy.CloseAll(foo, nil, bar), IsNil)
+	c.Assert(foo.IsClosed(), Equals, true)
+	c.Assert(bar.IsClosed(), Equals, true)
+
+	err = billy.CloseAll(foo, bar)
+	c.Assert(err, FitsTypeOf, &billy.MultiError{})
+	c.Assert(err.(*billy.MultiError).Errors, HasLen, 2)
+}
@@ -0,0 +1,313 @@
+// Package fatfs provides a billy filesystem wrapper enforcing the constraints
+// of the FAT family of filesystems, allowing to validate a tree before writing
+// it to a SD card or a USB stick.
+package fatfs // import "srcd.works/go-billy.v1/fatfs"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ErrFileTooLarge is returned when a write exceeds the maximum file size.
+var ErrFileTooLarge = errors.New("file too large")
+
+// Variant describes the constraints of a member of the FAT family.
+type Variant struct {
+	// MaxFileSize is the maximum size of a file in bytes, zero means no
+	// limit.
+	MaxFileSize int64
+	// TimeResolution is the resolution of the modification times.
+	TimeResolution time.Duration
+}
+
+var (
+	// FAT32 limits the files to 4GiB minus one byte, with modification times
+	// of 2 seconds of resolution.
+	FAT32 = Variant{MaxFileSize: 1<<32 - 1, TimeResolution: 2 * time.Second}
+	// ExFAT has no practical limit of size, with modification times of 10
+	// milliseconds of resolution.
+	ExFAT = Variant{TimeResolution: 10 * time.Millisecond}
+)
+
+// FAT wraps a billy.Filesystem enforcing the constraints of a FAT variant:
+// the names must be valid on Windows, as reported by billy.ValidWindowsName,
+// the files can't exceed the maximum size, and the modification times are
+// reported and stored with the resolution of the variant. FAT doesn't
+// support symbolic links, ownership nor permissions, so Symlink, Chown and
+// Chmod return billy.ErrNotSupported.
+//
+// FAT is case-insensitive, but the names are passed to the underlying
+// filesystem as is, wrapping a memory filesystem created with the Windows
+// option emulates it.
+type FAT struct {
+	fs billy.Filesystem
+	v  Variant
+}
+
+// New returns a new FAT filesystem wrapping the given one.
+func New(fs billy.Filesystem, v Variant) *FAT {
+	return &FAT{fs: fs, v: v}
+}
+
+// Create creates the named file, if its name is valid.
+func (fs *FAT) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *FAT) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, the files created must have a valid name.
+func (fs *FAT) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		if err := validPath(filename); err != nil {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+		}
+	}
+
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs, append: flag&os.O_APPEND != 0}, nil
+}
+
+// Stat returns the FileInfo of the named file, with the modification time
+// truncated to the resolution of the variant.
+func (fs *FAT) Stat(filename string) (billy.FileInfo, error) {
+	fi, err := fs.fs.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(fi), nil
+}
+
+// ReadDir returns the FileInfo of the files in the given directory, as Stat.
+func (fs *FAT) ReadDir(path string) ([]billy.FileInfo, error) {
+	l, err := fs.fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, fi := range l {
+		l[i] = fs.info(fi)
+	}
+
+	return l, nil
+}
+
+// TempFile creates a temporary file, the prefix must be a valid name.
+func (fs *FAT) TempFile(dir, prefix string) (billy.File, error) {
+	if err := validPath(fs.fs.Join(dir, prefix+"x")); err != nil {
+		return nil, &os.PathError{Op: "open", Path: fs.fs.Join(dir, prefix), Err: err}
+	}
+
+	f, err := fs.fs.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+// Rename renames a file, the new name must be valid.
+func (fs *FAT) Rename(from, to string) error {
+	if err := validPath(to); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes the named file or directory.
+func (fs *FAT) Remove(filename string) error {
+	return fs.fs.Remove(filename)
+}
+
+// Chtimes changes the times of the named file, truncated to the resolution
+// of the variant.
+func (fs *FAT) Chtimes(name string, atime, mtime time.Time) error {
+	return fs.fs.Chtimes(name,
+		billy.TruncateTime(atime, fs.v.TimeResolution),
+		billy.TruncateTime(mtime, fs.v.TimeResolution),
+	)
+}
+
+// Chown returns billy.ErrNotSupported, FAT doesn't store ownership.
+func (fs *FAT) Chown(name string, uid, gid int) error {
+	return billy.ErrNotSupported
+}
+
+// Symlink returns billy.ErrNotSupported, FAT doesn't support symbolic links.
+func (fs *FAT) Symlink(target, link string) error {
+	return billy.ErrNotSupported
+}
+
+// Readlink returns the target of the named symbolic link, only links already
+// present in the underlying filesystem can exist.
+func (fs *FAT) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// Lstat returns the FileInfo of the named file without following symbolic
+// links, as Stat.
+func (fs *FAT) Lstat(filename string) (billy.FileInfo, error) {
+	fi, err := fs.fs.Lstat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(fi), nil
+}
+
+// MkdirAll creates a directory and its parents, the names must be valid.
+func (fs *FAT) MkdirAll(path string, perm os.FileMode) error {
+	if err := validPath(path); err != nil {
+		return &os.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Ping checks the underlying filesystem, as billy.Ping.
+func (fs *FAT) Ping(ctx context.Context) error {
+	_, err := billy.Ping(ctx, fs.fs)
+	return err
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *FAT) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close.
+func (fs *FAT) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// Chmod returns billy.ErrNotSupported, FAT doesn't store permissions.
+func (fs *FAT) Chmod(name string, mode os.FileMode) error {
+	return billy.ErrNotSupported
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *FAT) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new FAT filesystem rooted at the given path.
+func (fs *FAT) Dir(path string) billy.Filesystem {
+	return New(fs.fs.Dir(path), fs.v)
+}
+
+// Base returns the base path of the filesystem.
+func (fs *FAT) Base() string {
+	return fs.fs.Base()
+}
+
+func (fs *FAT) info(fi billy.FileInfo) billy.FileInfo {
+	return &fileInfo{
+		FileInfo: fi,
+		modTime:  billy.TruncateTime(fi.ModTime(), fs.v.TimeResolution),
+	}
+}
+
+// validPath returns billy.ErrInvalidName if any element of the path is not a
+// valid name. Both slashes and backslashes are considered separators.
+func validPath(path string) error {
+	path = strings.Replace(filepath.ToSlash(path), `\`, "/", -1)
+	for _, name := range strings.Split(path, "/") {
+		if name == "" || name == "." || name == ".." {
+			continue
+		}
+
+		if !billy.ValidWindowsName(name) {
+			return billy.ErrInvalidName
+		}
+	}
+
+	return nil
+}
+
+type fileInfo struct {
+	billy.FileInfo
+	modTime time.Time
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+// file is a file enforcing the maximum file size on writes.
+type file struct {
+	billy.File
+	fs     *FAT
+	append bool
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if max := f.fs.v.MaxFileSize; max > 0 {
+		end, err := f.offset()
+		if err != nil {
+			return 0, err
+		}
+
+		if end+int64(len(p)) > max {
+			return 0, ErrFileTooLarge
+		}
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return f.fs.info(fi), nil
+}
+
+func (f *file) Truncate(size int64) error {
+	if max := f.fs.v.MaxFileSize; max > 0 && size > max {
+		return ErrFileTooLarge
+	}
+
+	return f.File.Truncate(size)
+}
+
+// offset returns the offset where the next write will happen.
+func (f *file) offset() (int64, error) {
+	if !f.append {
+		return f.File.Seek(0, io.SeekCurrent)
+	}
+
+	fi, err := f.fs.fs.Stat(f.Filename())
+	if err != nil {
+		return 0, err
+	}
+
+	return fi.Size(), nil
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
@@ -0,0 +1,87 @@
+package fatfs
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FATSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FATSuite{})
+
+func (s *FATSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New(), FAT32)
+}
+
+func (s *FATSuite) TestInvalidNames(c *C) {
+	for _, name := range []string{"a:b", "foo/bar?", "con.txt/foo", "foo.", `a"b`} {
+		_, err := s.Fs.Create(name)
+		c.Assert(err, NotNil, Commentf("name %q", name))
+		c.Assert(err.(*os.PathError).Err, Equals, billy.ErrInvalidName)
+	}
+
+	_, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	err = s.Fs.Rename("foo", "foo|bar")
+	c.Assert(err.(*os.LinkError).Err, Equals, billy.ErrInvalidName)
+
+	_, err = s.Fs.TempFile("", "a*")
+	c.Assert(err, NotNil)
+}
+
+func (s *FATSuite) TestModTime(c *C) {
+	_, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+
+	fi, err := s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().UnixNano()%int64(2*time.Second), Equals, int64(0))
+
+	infos, err := s.Fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos[0].ModTime().UnixNano()%int64(2*time.Second), Equals, int64(0))
+}
+
+func (s *FATSuite) TestChown(c *C) {
+	c.Assert(s.Fs.(billy.Chowner).Chown("foo", 0, 0), Equals, billy.ErrNotSupported)
+}
+
+type SizeSuite struct{}
+
+var _ = Suite(&SizeSuite{})
+
+func (s *SizeSuite) TestMaxFileSize(c *C) {
+	fs := New(memory.New(), Variant{MaxFileSize: 8})
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+
+	_, err = f.Write([]byte("foobar"))
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("qux"))
+	c.Assert(err, Equals, ErrFileTooLarge)
+	_, err = f.Write([]byte("qu"))
+	c.Assert(err, IsNil)
+
+	_, err = f.Seek(2, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("x"))
+	c.Assert(err, Equals, ErrFileTooLarge)
+	c.Assert(f.Close(), IsNil)
+}
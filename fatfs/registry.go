@@ -0,0 +1,32 @@
+package fatfs
+
+import (
+	"fmt"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("fat", wrap)
+}
+
+var variants = map[string]Variant{
+	"fat32": FAT32,
+	"exfat": ExFAT,
+}
+
+// wrap returns a FAT filesystem wrapping fs, the variant option is fat32, the
+// default, or exfat.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	name := opts["variant"]
+	if name == "" {
+		name = "fat32"
+	}
+
+	v, ok := variants[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown FAT variant %q", name)
+	}
+
+	return New(fs, v), nil
+}
@@ -0,0 +1,26 @@
+package billy
+
+import "context"
+
+// Flusher is an optional interface implemented by the filesystems doing work
+// in the background, such as writing back buffered data, replicating or
+// prefetching, and by the wrappers, flushing the filesystems they wrap.
+type Flusher interface {
+	// Flush waits for the work pending when called to be done, writing
+	// back the buffered data, so the backend reaches a consistent state. It
+	// must return when ctx is done.
+	Flush(ctx context.Context) error
+}
+
+// Flush brings fs to a quiescent point, where the writes done before calling
+// it reached the backend and no work started before is pending, so it can be
+// safely snapshotted or shut down. The data written to files still open
+// isn't included, they must be closed or synced before. The filesystems not
+// implementing Flusher have nothing pending, Flush does nothing on them.
+func Flush(ctx context.Context, fs Filesystem) error {
+	if f, ok := fs.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package billy_test
+
+import (
+	"context"
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type FlushSuite struct{}
+
+var _ = Suite(&FlushSuite{})
+
+func (s *FlushSuite) TestFlush(c *C) {
+	c.Assert(billy.Flush(context.Background(), memory.New()), IsNil)
+
+	fs := &flusher{Filesystem: memory.New()}
+	c.Assert(billy.Flush(context.Background(), fs), IsNil)
+	c.Assert(fs.flushed, Equals, 1)
+
+	fs.err = errors.New("pending")
+	c.Assert(billy.Flush(context.Background(), fs), Equals, fs.err)
+	c.Assert(fs.flushed, Equals, 2)
+}
+
+// flusher is a filesystem counting the calls to Flush.
+type flusher struct {
+	billy.Filesystem
+	flushed int
+	err     error
+}
+
+func (fs *flusher) Flush(ctx context.Context) error {
+	fs.flushed++
+	return fs.err
+}
@@ -0,0 +1,209 @@
+package billy
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultFollowInterval is the default interval between the checks of a
+// followed file.
+const DefaultFollowInterval = time.Second
+
+// FollowOptions describes how a file is followed by Follow.
+type FollowOptions struct {
+	// Interval between the checks of the file once its end was reached, if
+	// zero DefaultFollowInterval is used.
+	Interval time.Duration
+	// FromEnd starts reading at the end of the file, instead of at the
+	// beginning, as tail -f does.
+	FromEnd bool
+}
+
+// Follow returns a reader of the named file that, once it reaches the end of
+// the file, waits for more data instead of returning io.EOF, as tail -F does.
+// The file is polled at the given interval.
+//
+// A file truncated, or replaced by a new one, as done by the log rotations,
+// is read again from the beginning. Replacements are detected by the
+// filesystems implementing Identity, otherwise only when the new file is
+// smaller than the read offset. The file may not exist yet. Read returns the
+// context error once it is done.
+func Follow(ctx context.Context, fs Filesystem, filename string, opts *FollowOptions) (io.ReadCloser, error) {
+	if opts == nil {
+		opts = &FollowOptions{}
+	}
+
+	f := &follower{ctx: ctx, fs: fs, name: filename, opts: opts}
+	if err := f.open(opts.FromEnd); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// FollowLines calls fn for every line of the named file, following it as
+// Follow. It returns the context error once it is done, or the error
+// returned by fn.
+func FollowLines(ctx context.Context, fs Filesystem, filename string, opts *FollowOptions, fn func(line string) error) error {
+	r, err := Follow(ctx, fs, filename, opts)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	return scanLines(r, fn)
+}
+
+type follower struct {
+	ctx    context.Context
+	fs     Filesystem
+	name   string
+	opts   *FollowOptions
+	f      File
+	id     string
+	offset int64
+}
+
+func (f *follower) Read(p []byte) (int, error) {
+	for {
+		if err := f.ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		if f.f == nil {
+			err := f.open(false)
+			if os.IsNotExist(err) {
+				if err := f.wait(); err != nil {
+					return 0, err
+				}
+
+				continue
+			}
+
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := f.f.Read(p)
+		f.offset += int64(n)
+		if n != 0 {
+			return n, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		changed, err := f.changed()
+		if err != nil {
+			return 0, err
+		}
+
+		if !changed {
+			if err := f.wait(); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// changed checks the file once the end was reached, returns true if there is
+// new content to read.
+func (f *follower) changed() (bool, error) {
+	fi, err := f.fs.Stat(f.name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if id, ok := f.fileID(); ok && id != f.id {
+		return true, f.reopen()
+	}
+
+	switch {
+	case fi.Size() < f.offset:
+		if _, err := f.f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+
+		f.offset = 0
+		return true, nil
+	case fi.Size() > f.offset:
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (f *follower) open(fromEnd bool) error {
+	file, err := f.fs.Open(f.name)
+	if err != nil {
+		return err
+	}
+
+	f.f, f.offset = file, 0
+	f.id, _ = f.fileID()
+	if !fromEnd {
+		return nil
+	}
+
+	f.offset, err = file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (f *follower) reopen() error {
+	err := f.f.Close()
+	f.f = nil
+	if err != nil {
+		return err
+	}
+
+	if err := f.open(false); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (f *follower) fileID() (string, bool) {
+	i, ok := f.fs.(Identity)
+	if !ok {
+		return "", false
+	}
+
+	id, err := i.FileID(f.name)
+	return id, err == nil
+}
+
+func (f *follower) wait() error {
+	interval := f.opts.Interval
+	if interval <= 0 {
+		interval = DefaultFollowInterval
+	}
+
+	t := time.NewTimer(interval)
+	defer t.Stop()
+
+	select {
+	case <-f.ctx.Done():
+		return f.ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (f *follower) Close() error {
+	if f.f == nil {
+		return nil
+	}
+
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
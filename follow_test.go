@@ -0,0 +1,106 @@
+package billy_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type FollowSuite struct{}
+
+var _ = Suite(&FollowSuite{})
+
+func (s *FollowSuite) TestFollow(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "log", "foo")
+
+	r, err := billy.Follow(context.Background(), fs, "log", &billy.FollowOptions{
+		Interval: time.Millisecond,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(readN(c, r, 3), Equals, "foo")
+
+	appendFile(c, fs, "log", "bar")
+	c.Assert(readN(c, r, 3), Equals, "bar")
+
+	// truncated
+	writeFile(c, fs, "log", "q")
+	c.Assert(readN(c, r, 1), Equals, "q")
+
+	// rotated
+	c.Assert(fs.Rename("log", "log.1"), IsNil)
+	writeFile(c, fs, "log", "new")
+	c.Assert(readN(c, r, 3), Equals, "new")
+
+	c.Assert(r.Close(), IsNil)
+}
+
+func (s *FollowSuite) TestFollowFromEnd(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "log", "foo")
+
+	r, err := billy.Follow(context.Background(), fs, "log", &billy.FollowOptions{
+		Interval: time.Millisecond,
+		FromEnd:  true,
+	})
+	c.Assert(err, IsNil)
+
+	appendFile(c, fs, "log", "bar")
+	c.Assert(readN(c, r, 3), Equals, "bar")
+	c.Assert(r.Close(), IsNil)
+}
+
+func (s *FollowSuite) TestFollowDone(c *C) {
+	fs := memory.New()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	r, err := billy.Follow(ctx, fs, "log", &billy.FollowOptions{
+		Interval: time.Millisecond,
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.Read(make([]byte, 1))
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(r.Close(), IsNil)
+}
+
+func (s *FollowSuite) TestFollowLines(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "log", "foo\nbar\nqux\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lines []string
+	err := billy.FollowLines(ctx, fs, "log", nil, func(line string) error {
+		lines = append(lines, line)
+		if len(lines) == 3 {
+			cancel()
+		}
+
+		return nil
+	})
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(lines, DeepEquals, []string{"foo", "bar", "qux"})
+}
+
+func readN(c *C, r io.Reader, n int) string {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	c.Assert(err, IsNil)
+	return string(b)
+}
+
+func appendFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
@@ -4,14 +4,27 @@ import (
 	"errors"
 	"io"
 	"os"
+	"time"
 )
 
 var (
 	ErrClosed       = errors.New("file: Writing on closed file.")
 	ErrReadOnly     = errors.New("this is a read-only filesystem")
 	ErrNotSupported = errors.New("feature not supported")
+	ErrSpecialFile  = errors.New("special file: device, named pipe or socket")
+	ErrTooManyLinks = errors.New("too many levels of symbolic links")
+	ErrInvalidName  = errors.New("invalid file name")
+	// ErrCrossedBoundary is returned when a path escapes the base of the
+	// filesystem.
+	ErrCrossedBoundary = errors.New("chroot boundary crossed")
 )
 
+// DefaultMaxLinks is the default maximum number of symbolic links followed
+// while resolving a path, exceeding it fails with ErrTooManyLinks. It's the
+// same limit used by Linux, implementations resolving symbolic links should
+// allow configuring it, reporting it with LinkLimiter.
+const DefaultMaxLinks = 40
+
 // Filesystem abstract the operations in a storage-agnostic interface.
 // It allows you to:
 // * Create files.
@@ -24,6 +37,9 @@ var (
 // * Join parts of path.
 // * Obtain a filesystem starting on a subdirectory in the current filesystem.
 // * Get the base path for the filesystem.
+// * Create and read symbolic links.
+// * Create directories.
+// * Change the permissions and times of files.
 // Each method implementation varies from implementation to implementation. Refer to
 // the specific documentation for more info.
 type Filesystem interface {
@@ -31,6 +47,8 @@ type Filesystem interface {
 	Open(filename string) (File, error)
 	OpenFile(filename string, flag int, perm os.FileMode) (File, error)
 	Stat(filename string) (FileInfo, error)
+	// ReadDir returns the entries of the directory path sorted by name, as
+	// ioutil.ReadDir, so the tools built on it are reproducible.
 	ReadDir(path string) ([]FileInfo, error)
 	TempFile(dir, prefix string) (File, error)
 	Rename(from, to string) error
@@ -38,6 +56,76 @@ type Filesystem interface {
 	Join(elem ...string) string
 	Dir(path string) Filesystem
 	Base() string
+	// Symlink creates link as a symbolic link to target, target is stored
+	// as given, a relative target is resolved from the directory of link.
+	Symlink(target, link string) error
+	// Readlink returns the target of the named symbolic link.
+	Readlink(link string) (string, error)
+	// Lstat returns the FileInfo of the named file, if it's a symbolic link
+	// the returned FileInfo describes the link itself, not its target.
+	Lstat(filename string) (FileInfo, error)
+	// MkdirAll creates the directory path and all its missing parents, it
+	// does nothing if path is already a directory.
+	MkdirAll(path string, perm os.FileMode) error
+	// Chmod changes the mode of the named file, following symbolic links.
+	Chmod(name string, mode os.FileMode) error
+	// Chtimes changes the access and modification times of the named file,
+	// following symbolic links, similar to os.Chtimes. The backends not
+	// storing access times ignore atime.
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// Chowner is an optional interface implemented by the filesystems allowing to
+// change the ownership of the files.
+type Chowner interface {
+	// Chown changes the numeric uid and gid of the named file, similar to
+	// os.Chown.
+	Chown(name string, uid, gid int) error
+}
+
+// HardLink is an optional interface implemented by the filesystems supporting
+// hard links.
+type HardLink interface {
+	// Link creates newname as a hard link to the oldname file.
+	Link(oldname, newname string) error
+}
+
+// Special is an optional interface implemented by the filesystems able to
+// create special files: device nodes, named pipes and sockets.
+type Special interface {
+	// Mknod creates the special file name, mode must include its type
+	// (os.ModeDevice, os.ModeCharDevice, os.ModeNamedPipe or os.ModeSocket)
+	// and dev is the device number used by device nodes.
+	Mknod(name string, mode os.FileMode, dev uint64) error
+}
+
+// IsSpecial returns true if the given FileInfo describes a special file,
+// device node, named pipe or socket, whose content can't be read as a regular
+// file.
+func IsSpecial(fi FileInfo) bool {
+	return fi.Mode()&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket) != 0
+}
+
+// Identity is an optional interface implemented by the filesystems able to
+// identify a file independently of its name, allowing to detect renames.
+type Identity interface {
+	// FileID returns a stable identifier of the named file, it doesn't change
+	// when the file is renamed or modified.
+	FileID(filename string) (string, error)
+}
+
+// Sparse is an optional interface implemented by the files able to report
+// which regions of their content hold data, so the holes in between can be
+// preserved when copying them.
+type Sparse interface {
+	// Extents returns the regions holding data, sorted by offset.
+	Extents() ([]Extent, error)
+}
+
+// Extent is a region of a file holding data.
+type Extent struct {
+	Offset int64
+	Length int64
 }
 
 // File implements io.Closer, io.Reader, io.Seeker, and io.Writer>
@@ -45,6 +133,22 @@ type Filesystem interface {
 type File interface {
 	Filename() string
 	IsClosed() bool
+	// Stat returns the FileInfo of the open file, even if it was renamed or
+	// removed after being opened.
+	Stat() (FileInfo, error)
+	// Truncate changes the size of the file, growing it fills the new space
+	// with zeros. The offset of the file is not changed.
+	Truncate(size int64) error
+	// Sync commits the content of the file to stable storage, on the
+	// backends without one it does nothing.
+	Sync() error
+	// Lock acquires an advisory exclusive lock on the file, blocking until
+	// it's available, and Unlock releases it, as does closing the file. The
+	// locks only exclude other locks, not reads or writes. The backends
+	// unable to lock return ErrNotSupported, the callers can fall back to
+	// other mechanisms, such as creating lock files with os.O_EXCL.
+	Lock() error
+	Unlock() error
 	io.Writer
 	io.Reader
 	io.Seeker
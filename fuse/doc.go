@@ -0,0 +1,7 @@
+// Package fuse mounts a billy filesystem as a FUSE filesystem, so its content
+// can be browsed and changed with the regular tools, which is mostly useful to
+// inspect the in-memory filesystems while debugging. Mount serves the whole
+// filesystem at a directory, New returns the bazil.org/fuse/fs.FS over a
+// filesystem, to be served with other options. It's only available on Linux,
+// macOS and FreeBSD, requiring the FUSE support of the system.
+package fuse // import "srcd.works/go-billy.v1/fuse"
@@ -0,0 +1,457 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package fuse
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+	"srcd.works/go-billy.v1"
+)
+
+// attrValid is how long the kernel caches the attributes of the files, kept
+// short since the filesystem is usually being changed by its program too.
+const attrValid = time.Second
+
+// openFlags are the flags of the open requests passed to the filesystem.
+const openFlags = os.O_RDONLY | os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_CREATE | os.O_EXCL | os.O_TRUNC
+
+// FS is a bazil.org/fuse/fs.FS over a billy.Filesystem. The files are
+// read and written through the ones of the filesystem, at the requested
+// offsets if they implement io.ReaderAt and io.WriterAt, otherwise seeking
+// them. The ownership of the files is the one reported by the backend, or
+// the one of the process.
+type FS struct {
+	fs billy.Filesystem
+
+	mu sync.Mutex
+	// nodes holds the nodes known by the kernel, by path, so a path is
+	// always served by the same node.
+	nodes map[string]*node
+}
+
+// New returns a new FS over fs.
+func New(fs billy.Filesystem) *FS {
+	return &FS{fs: fs, nodes: make(map[string]*node)}
+}
+
+// Root returns the node of the root of the filesystem.
+func (fsys *FS) Root() (fusefs.Node, error) {
+	return fsys.node(""), nil
+}
+
+// node returns the node of the given path.
+func (fsys *FS) node(path string) *node {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	n, ok := fsys.nodes[path]
+	if !ok {
+		n = &node{fsys: fsys, path: path}
+		fsys.nodes[path] = n
+	}
+
+	return n
+}
+
+// renamed moves the nodes of from and its descendants to the path to.
+func (fsys *FS) renamed(from, to string) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	delete(fsys.nodes, to)
+	for path, n := range fsys.nodes {
+		if !isDescendant(path, from) {
+			continue
+		}
+
+		delete(fsys.nodes, path)
+		n.path = to + path[len(from):]
+		fsys.nodes[n.path] = n
+	}
+}
+
+func isDescendant(path, dir string) bool {
+	if !strings.HasPrefix(path, dir) {
+		return false
+	}
+
+	rest := path[len(dir):]
+	return rest == "" || rest[0] == '/' || rest[0] == filepath.Separator
+}
+
+// node is a file of the filesystem, known by the kernel.
+type node struct {
+	fsys *FS
+	// path is guarded by the mutex of fsys, changing on renames.
+	path string
+}
+
+func (n *node) filename() string {
+	n.fsys.mu.Lock()
+	defer n.fsys.mu.Unlock()
+	return n.path
+}
+
+func (n *node) join(name string) string {
+	return n.fsys.fs.Join(n.filename(), name)
+}
+
+func (n *node) Attr(ctx context.Context, a *fuse.Attr) error {
+	fi, err := n.fsys.fs.Lstat(n.filename())
+	if err != nil {
+		return errno(err)
+	}
+
+	fillAttr(a, fi)
+	return nil
+}
+
+func (n *node) Lookup(ctx context.Context, name string) (fusefs.Node, error) {
+	filename := n.join(name)
+	if _, err := n.fsys.fs.Lstat(filename); err != nil {
+		return nil, errno(err)
+	}
+
+	return n.fsys.node(filename), nil
+}
+
+func (n *node) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	l, err := n.fsys.fs.ReadDir(n.filename())
+	if err != nil {
+		return nil, errno(err)
+	}
+
+	entries := make([]fuse.Dirent, len(l))
+	for i, fi := range l {
+		entries[i] = fuse.Dirent{Name: fi.Name(), Type: direntType(fi.Mode())}
+	}
+
+	return entries, nil
+}
+
+// Open opens the file for the given flags, the directories are their own
+// handles, listed with ReadDirAll.
+func (n *node) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fusefs.Handle, error) {
+	if req.Dir {
+		return n, nil
+	}
+
+	f, err := n.fsys.fs.OpenFile(n.filename(), int(req.Flags)&openFlags, 0)
+	if err != nil {
+		return nil, errno(err)
+	}
+
+	return &handle{f: f}, nil
+}
+
+func (n *node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fusefs.Node, fusefs.Handle, error) {
+	filename := n.join(req.Name)
+	perm := (req.Mode &^ req.Umask).Perm()
+	f, err := n.fsys.fs.OpenFile(filename, int(req.Flags)&openFlags|os.O_CREATE, perm)
+	if err != nil {
+		return nil, nil, errno(err)
+	}
+
+	return n.fsys.node(filename), &handle{f: f}, nil
+}
+
+func (n *node) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fusefs.Node, error) {
+	filename := n.join(req.Name)
+	if err := billy.Mkdir(n.fsys.fs, filename, (req.Mode &^ req.Umask).Perm()); err != nil {
+		return nil, errno(err)
+	}
+
+	return n.fsys.node(filename), nil
+}
+
+func (n *node) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fusefs.Node, error) {
+	filename := n.join(req.NewName)
+	if err := n.fsys.fs.Symlink(req.Target, filename); err != nil {
+		return nil, errno(err)
+	}
+
+	return n.fsys.node(filename), nil
+}
+
+func (n *node) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	target, err := n.fsys.fs.Readlink(n.filename())
+	return target, errno(err)
+}
+
+// Link creates a hard link, if the filesystem implements billy.HardLink.
+func (n *node) Link(ctx context.Context, req *fuse.LinkRequest, old fusefs.Node) (fusefs.Node, error) {
+	l, ok := n.fsys.fs.(billy.HardLink)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+
+	filename := n.join(req.NewName)
+	if err := l.Link(old.(*node).filename(), filename); err != nil {
+		return nil, errno(err)
+	}
+
+	return n.fsys.node(filename), nil
+}
+
+func (n *node) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	return errno(n.fsys.fs.Remove(n.join(req.Name)))
+}
+
+func (n *node) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fusefs.Node) error {
+	from, to := n.join(req.OldName), newDir.(*node).join(req.NewName)
+	if err := n.fsys.fs.Rename(from, to); err != nil {
+		return errno(err)
+	}
+
+	n.fsys.renamed(from, to)
+	return nil
+}
+
+// Setattr changes the mode, the ownership, if the filesystem implements
+// billy.Chowner, the size and the times of the file.
+func (n *node) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	filename := n.filename()
+	if req.Valid.Mode() {
+		if err := n.fsys.fs.Chmod(filename, req.Mode.Perm()); err != nil {
+			return errno(err)
+		}
+	}
+
+	if req.Valid.Uid() || req.Valid.Gid() {
+		if err := n.chown(filename, req); err != nil {
+			return errno(err)
+		}
+	}
+
+	if req.Valid.Size() {
+		if err := n.truncate(filename, int64(req.Size)); err != nil {
+			return errno(err)
+		}
+	}
+
+	if req.Valid.Atime() || req.Valid.Mtime() {
+		if err := n.chtimes(filename, req); err != nil {
+			return errno(err)
+		}
+	}
+
+	return n.Attr(ctx, &resp.Attr)
+}
+
+func (n *node) chown(filename string, req *fuse.SetattrRequest) error {
+	c, ok := n.fsys.fs.(billy.Chowner)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	uid, gid := -1, -1
+	if req.Valid.Uid() {
+		uid = int(req.Uid)
+	}
+
+	if req.Valid.Gid() {
+		gid = int(req.Gid)
+	}
+
+	return c.Chown(filename, uid, gid)
+}
+
+func (n *node) truncate(filename string, size int64) error {
+	f, err := n.fsys.fs.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// chtimes changes the times of the file, the backends only keeping the
+// modification time get it as the access time too.
+func (n *node) chtimes(filename string, req *fuse.SetattrRequest) error {
+	fi, err := n.fsys.fs.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	atime, mtime := fi.ModTime(), fi.ModTime()
+	switch {
+	case req.Valid.AtimeNow():
+		atime = time.Now()
+	case req.Valid.Atime():
+		atime = req.Atime
+	}
+
+	switch {
+	case req.Valid.MtimeNow():
+		mtime = time.Now()
+	case req.Valid.Mtime():
+		mtime = req.Mtime
+	}
+
+	return n.fsys.fs.Chtimes(filename, atime, mtime)
+}
+
+func (n *node) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	return nil
+}
+
+// Forget drops the node, once the kernel doesn't know it anymore.
+func (n *node) Forget() {
+	n.fsys.mu.Lock()
+	defer n.fsys.mu.Unlock()
+
+	if n.fsys.nodes[n.path] == n {
+		delete(n.fsys.nodes, n.path)
+	}
+}
+
+// handle is an open file.
+type handle struct {
+	// mu serializes the reads and writes of the files without ReadAt or
+	// WriteAt, seeking them.
+	mu sync.Mutex
+	f  billy.File
+}
+
+func (h *handle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	buf := make([]byte, req.Size)
+	n, err := h.readAt(buf, req.Offset)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+
+	resp.Data = buf[:n]
+	return errno(err)
+}
+
+func (h *handle) readAt(p []byte, off int64) (int, error) {
+	if r, ok := h.f.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := h.f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.ReadFull(h.f, p)
+}
+
+func (h *handle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	n, err := h.writeAt(req.Data, req.Offset)
+	resp.Size = n
+	return errno(err)
+}
+
+func (h *handle) writeAt(p []byte, off int64) (int, error) {
+	if w, ok := h.f.(io.WriterAt); ok {
+		return w.WriteAt(p, off)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := h.f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return h.f.Write(p)
+}
+
+func (h *handle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	return nil
+}
+
+func (h *handle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	return errno(h.f.Close())
+}
+
+// fillAttr fills a with the attributes of the file described by fi.
+func fillAttr(a *fuse.Attr, fi billy.FileInfo) {
+	a.Valid = attrValid
+	a.Mode = fi.Mode()
+	a.Size = uint64(fi.Size())
+	a.Blocks = (a.Size + 511) / 512
+	a.Atime, a.Mtime, a.Ctime = fi.ModTime(), fi.ModTime(), fi.ModTime()
+	a.Uid, a.Gid = uint32(os.Getuid()), uint32(os.Getgid())
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		a.Uid, a.Gid, a.Nlink = st.Uid, st.Gid, uint32(st.Nlink)
+	}
+}
+
+func direntType(mode os.FileMode) fuse.DirentType {
+	switch {
+	case mode.IsDir():
+		return fuse.DT_Dir
+	case mode&os.ModeSymlink != 0:
+		return fuse.DT_Link
+	case mode&os.ModeNamedPipe != 0:
+		return fuse.DT_FIFO
+	case mode&os.ModeSocket != 0:
+		return fuse.DT_Socket
+	case mode&os.ModeCharDevice != 0:
+		return fuse.DT_Char
+	case mode&os.ModeDevice != 0:
+		return fuse.DT_Block
+	default:
+		return fuse.DT_File
+	}
+}
+
+// errno returns the error returned to the kernel for err, with the errno of
+// the billy errors.
+func errno(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case os.IsNotExist(err):
+		return syscall.ENOENT
+	case os.IsExist(err):
+		return syscall.EEXIST
+	case os.IsPermission(err):
+		return syscall.EACCES
+	}
+
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+
+	switch err {
+	case billy.ErrReadOnly:
+		return syscall.EROFS
+	case billy.ErrNotSupported:
+		return syscall.ENOTSUP
+	case billy.ErrCrossedBoundary:
+		return syscall.EPERM
+	case billy.ErrTooManyLinks:
+		return syscall.ELOOP
+	case billy.ErrInvalidName:
+		return syscall.EINVAL
+	case billy.ErrClosed:
+		return syscall.EBADF
+	}
+
+	return err
+}
@@ -0,0 +1,182 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package fuse
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FSSuite struct {
+	fs   billy.Filesystem
+	fsys *FS
+	root *node
+}
+
+var _ = Suite(&FSSuite{})
+
+var ctx = context.Background()
+
+func (s *FSSuite) SetUpTest(c *C) {
+	s.fs = memory.New()
+	writeFile(c, s.fs, "qux/foo", "foo")
+	s.fsys = New(s.fs)
+	root, err := s.fsys.Root()
+	c.Assert(err, IsNil)
+	s.root = root.(*node)
+}
+
+func (s *FSSuite) lookup(c *C, path ...string) *node {
+	n := s.root
+	for _, name := range path {
+		next, err := n.Lookup(ctx, name)
+		c.Assert(err, IsNil)
+		n = next.(*node)
+	}
+
+	return n
+}
+
+func (s *FSSuite) TestLookup(c *C) {
+	foo := s.lookup(c, "qux", "foo")
+	c.Assert(s.lookup(c, "qux", "foo"), Equals, foo)
+
+	var a fuse.Attr
+	c.Assert(foo.Attr(ctx, &a), IsNil)
+	c.Assert(a.Size, Equals, uint64(3))
+	c.Assert(a.Mode.IsRegular(), Equals, true)
+
+	_, err := s.root.Lookup(ctx, "missing")
+	c.Assert(err, Equals, syscall.ENOENT)
+}
+
+func (s *FSSuite) TestReadDirAll(c *C) {
+	c.Assert(s.fs.Symlink("foo", "qux/link"), IsNil)
+	entries, err := s.lookup(c, "qux").ReadDirAll(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []fuse.Dirent{
+		{Name: "foo", Type: fuse.DT_File},
+		{Name: "link", Type: fuse.DT_Link},
+	})
+
+	target, err := s.lookup(c, "qux", "link").Readlink(ctx, &fuse.ReadlinkRequest{})
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "foo")
+}
+
+func (s *FSSuite) TestReadWrite(c *C) {
+	foo := s.lookup(c, "qux", "foo")
+	h, err := foo.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadWrite}, &fuse.OpenResponse{})
+	c.Assert(err, IsNil)
+
+	var wresp fuse.WriteResponse
+	err = h.(fusefs.HandleWriter).Write(ctx, &fuse.WriteRequest{Data: []byte("bar"), Offset: 3}, &wresp)
+	c.Assert(err, IsNil)
+	c.Assert(wresp.Size, Equals, 3)
+
+	var rresp fuse.ReadResponse
+	err = h.(fusefs.HandleReader).Read(ctx, &fuse.ReadRequest{Offset: 1, Size: 10}, &rresp)
+	c.Assert(err, IsNil)
+	c.Assert(string(rresp.Data), Equals, "oobar")
+	c.Assert(h.(fusefs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{}), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foobar")
+}
+
+func (s *FSSuite) TestCreate(c *C) {
+	req := &fuse.CreateRequest{Name: "bar", Flags: fuse.OpenWriteOnly, Mode: 0666, Umask: 0022}
+	n, h, err := s.lookup(c, "qux").Create(ctx, req, &fuse.CreateResponse{})
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, s.lookup(c, "qux", "bar"))
+
+	var resp fuse.WriteResponse
+	c.Assert(h.(fusefs.HandleWriter).Write(ctx, &fuse.WriteRequest{Data: []byte("bar")}, &resp), IsNil)
+	c.Assert(h.(fusefs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{}), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar")
+
+	fi, err := s.fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0644))
+}
+
+func (s *FSSuite) TestMkdirRemove(c *C) {
+	_, err := s.root.Mkdir(ctx, &fuse.MkdirRequest{Name: "bar", Mode: os.ModeDir | 0755})
+	c.Assert(err, IsNil)
+	fi, err := s.fs.Stat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	_, err = s.root.Mkdir(ctx, &fuse.MkdirRequest{Name: "bar", Mode: os.ModeDir | 0755})
+	c.Assert(err, Equals, syscall.EEXIST)
+
+	c.Assert(s.lookup(c, "qux").Remove(ctx, &fuse.RemoveRequest{Name: "foo"}), IsNil)
+	_, err = s.fs.Stat("qux/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FSSuite) TestRename(c *C) {
+	foo := s.lookup(c, "qux", "foo")
+	err := s.lookup(c, "qux").Rename(ctx, &fuse.RenameRequest{OldName: "foo", NewName: "bar"}, s.root)
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, s.fs, "bar"), Equals, "foo")
+
+	c.Assert(foo.filename(), Equals, "bar")
+	c.Assert(s.lookup(c, "bar"), Equals, foo)
+	_, err = s.fs.Stat("qux/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FSSuite) TestSetattr(c *C) {
+	foo := s.lookup(c, "qux", "foo")
+	mtime := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	req := &fuse.SetattrRequest{
+		Valid: fuse.SetattrMode | fuse.SetattrSize | fuse.SetattrMtime,
+		Mode:  0600,
+		Size:  1,
+		Mtime: mtime,
+	}
+
+	var resp fuse.SetattrResponse
+	c.Assert(foo.Setattr(ctx, req, &resp), IsNil)
+	c.Assert(resp.Attr.Size, Equals, uint64(1))
+	c.Assert(resp.Attr.Mode.Perm(), Equals, os.FileMode(0600))
+	c.Assert(resp.Attr.Mtime.Equal(mtime), Equals, true)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "f")
+}
+
+func (s *FSSuite) TestErrno(c *C) {
+	c.Assert(errno(nil), IsNil)
+	c.Assert(errno(&os.PathError{Op: "open", Path: "foo", Err: os.ErrNotExist}), Equals, syscall.ENOENT)
+	c.Assert(errno(billy.ErrReadOnly), Equals, syscall.EROFS)
+	c.Assert(errno(&os.PathError{Op: "chmod", Path: "foo", Err: billy.ErrNotSupported}), Equals, syscall.ENOTSUP)
+	c.Assert(errno(&os.LinkError{Op: "rename", Err: syscall.EXDEV}), Equals, syscall.EXDEV)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	buf := make([]byte, 1024)
+	n, _ := f.Read(buf)
+	return string(buf[:n])
+}
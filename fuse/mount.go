@@ -0,0 +1,100 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package fuse
+
+import (
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+	"srcd.works/go-billy.v1"
+)
+
+// Options describes how Mount mounts the filesystem.
+type Options struct {
+	// Name is the name of the filesystem shown in the list of mounts,
+	// "billy" by default.
+	Name string
+	// ReadOnly mounts the filesystem read-only.
+	ReadOnly bool
+	// AllowOther allows the other users to access the filesystem, by default
+	// only the user mounting it can. It requires the user_allow_other
+	// option of /etc/fuse.conf for the users other than root.
+	AllowOther bool
+}
+
+var defaultOptions = Options{
+	Name: "billy",
+}
+
+// Server is a filesystem mounted by Mount, served until unmounted.
+type Server struct {
+	dir  string
+	conn *fuse.Conn
+	done chan struct{}
+	err  error
+}
+
+// Mount mounts fs at the directory dir, which must exist, serving it in the
+// background until unmounted, with Unmount or by the system. If opts is nil
+// the default options are used, as for their zero fields.
+func Mount(fs billy.Filesystem, dir string, opts *Options) (*Server, error) {
+	o := defaultOptions
+	if opts != nil {
+		if opts.Name != "" {
+			o.Name = opts.Name
+		}
+
+		o.ReadOnly = opts.ReadOnly
+		o.AllowOther = opts.AllowOther
+	}
+
+	mountOpts := []fuse.MountOption{fuse.FSName(o.Name), fuse.Subtype("billy")}
+	if o.ReadOnly {
+		mountOpts = append(mountOpts, fuse.ReadOnly())
+	}
+
+	if o.AllowOther {
+		mountOpts = append(mountOpts, fuse.AllowOther())
+	}
+
+	conn, err := fuse.Mount(dir, mountOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{dir: dir, conn: conn, done: make(chan struct{})}
+	go s.serve(New(fs))
+
+	<-conn.Ready
+	if err := conn.MountError; err != nil {
+		conn.Close()
+		<-s.done
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Server) serve(fsys *FS) {
+	s.err = fusefs.Serve(s.conn, fsys)
+	s.conn.Close()
+	close(s.done)
+}
+
+// Unmount unmounts the filesystem, waiting for the server to stop. It fails
+// while the filesystem is in use, such as by a shell with it as its working
+// directory.
+func (s *Server) Unmount() error {
+	if err := fuse.Unmount(s.dir); err != nil {
+		return err
+	}
+
+	return s.Wait()
+}
+
+// Wait waits until the filesystem is unmounted, returning the error that
+// stopped the server, if any.
+func (s *Server) Wait() error {
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,201 @@
+package billy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// grepBinaryPeek is the length of the prefix of the files checked for NUL
+// bytes to detect the binary ones, as done by git.
+const grepBinaryPeek = 8000
+
+var errGrepStopped = errors.New("grep stopped")
+
+// GrepOptions describes the files searched by Grep and how.
+type GrepOptions struct {
+	// Parallelism is the maximum number of files searched concurrently, 8 by
+	// default.
+	Parallelism int
+	// Include, if not empty, matches only the files whose name matches the
+	// pattern, with the syntax of filepath.Match.
+	Include string
+	// Binary searches also the binary files, the ones with a NUL byte in
+	// their first 8000 bytes, which are skipped by default.
+	Binary bool
+}
+
+var defaultGrepOptions = GrepOptions{
+	Parallelism: 8,
+}
+
+// GrepMatch is a line matched by Grep.
+type GrepMatch struct {
+	// Path is the name of the file, relative to the searched filesystem.
+	Path string
+	// Line is the number of the line, starting at one.
+	Line int
+	// Text is the content of the line, without the line terminator.
+	Text string
+}
+
+// GrepFunc is the type of the function called by Grep for every line
+// matched. If it returns an error the search stops and the error is
+// returned.
+type GrepFunc func(m GrepMatch) error
+
+// Grep searches the regular files in the tree rooted at root for the lines
+// matching the regular expression pattern, with the syntax of package
+// regexp, calling fn for every one. The files are searched concurrently,
+// but fn is called from one goroutine at a time, in the lexical order of the
+// files and the order of the lines, as the matches of every file are
+// found, so fs must be safe for concurrent use if Parallelism is greater
+// than one. If opts is nil the default options are used, as for their zero
+// fields.
+func Grep(fs Filesystem, root, pattern string, fn GrepFunc, opts *GrepOptions) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	o := defaultGrepOptions
+	if opts != nil {
+		if opts.Parallelism > 0 {
+			o.Parallelism = opts.Parallelism
+		}
+
+		o.Include = opts.Include
+		o.Binary = opts.Binary
+	}
+
+	if _, err := filepath.Match(o.Include, ""); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	defer wg.Wait()
+	defer close(done)
+
+	files := make(chan *grepFile, o.Parallelism)
+	sem := make(chan struct{}, o.Parallelism)
+	var walkErr error
+	var walkPanic interface{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(files)
+		defer catchPanic(&walkPanic)
+
+		walkErr = Walk(fs, root, func(path string, info FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			if ok, _ := filepath.Match(o.Include, info.Name()); o.Include != "" && !ok {
+				return nil
+			}
+
+			f := &grepFile{path: path, done: make(chan struct{})}
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				return errGrepStopped
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f.search(fs, re, o.Binary)
+				<-sem
+			}()
+
+			select {
+			case files <- f:
+				return nil
+			case <-done:
+				return errGrepStopped
+			}
+		})
+	}()
+
+	for f := range files {
+		<-f.done
+		if f.panicked != nil {
+			panic(f.panicked)
+		}
+
+		if f.err != nil {
+			return f.err
+		}
+
+		for _, m := range f.matches {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	if walkPanic != nil {
+		panic(walkPanic)
+	}
+
+	return walkErr
+}
+
+// grepFile is a file being searched by Grep.
+type grepFile struct {
+	path    string
+	matches []GrepMatch
+	err     error
+	// panicked is the panic recovered while searching, if any.
+	panicked interface{}
+	// done is closed once the search finishes.
+	done chan struct{}
+}
+
+// search searches the file for the lines matching re, skipping it if it's
+// binary, unless binary is true.
+func (f *grepFile) search(fs Filesystem, re *regexp.Regexp, binary bool) {
+	defer close(f.done)
+	defer catchPanic(&f.panicked)
+
+	r, err := fs.Open(f.path)
+	if err != nil {
+		f.err = err
+		return
+	}
+
+	defer r.Close()
+
+	br := bufio.NewReaderSize(r, grepBinaryPeek)
+	if !binary {
+		head, err := br.Peek(grepBinaryPeek)
+		if err != nil && err != io.EOF {
+			f.err = err
+			return
+		}
+
+		if bytes.IndexByte(head, 0) != -1 {
+			return
+		}
+	}
+
+	var n int
+	f.err = scanLines(br, func(line string) error {
+		n++
+		if re.MatchString(line) {
+			f.matches = append(f.matches, GrepMatch{Path: f.path, Line: n, Text: line})
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,112 @@
+package billy_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type GrepSuite struct{}
+
+var _ = Suite(&GrepSuite{})
+
+func grep(c *C, fs billy.Filesystem, root, pattern string, opts *billy.GrepOptions) []string {
+	var l []string
+	err := billy.Grep(fs, root, pattern, func(m billy.GrepMatch) error {
+		l = append(l, fmt.Sprintf("%s:%d:%s", m.Path, m.Line, m.Text))
+		return nil
+	}, opts)
+	c.Assert(err, IsNil)
+	return l
+}
+
+func (s *GrepSuite) TestGrep(c *C) {
+	fs := memory.New()
+	for i := 0; i < 20; i++ {
+		writeFile(c, fs, fmt.Sprintf("qux/%02d", i), fmt.Sprintf("foo\nbar %d\r\nbaz %d", i, i))
+	}
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		expected = append(expected,
+			fmt.Sprintf("qux/%02d:2:bar %d", i, i),
+			fmt.Sprintf("qux/%02d:3:baz %d", i, i),
+		)
+	}
+
+	for _, n := range []int{1, 4, 32} {
+		l := grep(c, fs, "", "^ba[rz]", &billy.GrepOptions{Parallelism: n})
+		c.Assert(l, DeepEquals, expected)
+	}
+
+	c.Assert(grep(c, fs, "qux/01", "1$", nil), DeepEquals, []string{
+		"qux/01:2:bar 1",
+		"qux/01:3:baz 1",
+	})
+}
+
+func (s *GrepSuite) TestGrepBinary(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo\x00bar\nfoo")
+	writeFile(c, fs, "bar", strings.Repeat("x", 8000)+"\x00\nfoo")
+
+	c.Assert(grep(c, fs, "", "foo", nil), DeepEquals, []string{"bar:2:foo"})
+	c.Assert(grep(c, fs, "", "foo", &billy.GrepOptions{Binary: true}), DeepEquals, []string{
+		"bar:2:foo",
+		"foo:1:foo\x00bar",
+		"foo:2:foo",
+	})
+}
+
+func (s *GrepSuite) TestGrepInclude(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo.go", "foo")
+	writeFile(c, fs, "qux/foo.md", "foo")
+
+	c.Assert(grep(c, fs, "", "foo", &billy.GrepOptions{Include: "*.go"}), DeepEquals, []string{
+		"qux/foo.go:1:foo",
+	})
+
+	err := billy.Grep(fs, "", "foo", nil, &billy.GrepOptions{Include: "["})
+	c.Assert(err, NotNil)
+}
+
+func (s *GrepSuite) TestGrepErrors(c *C) {
+	fs := memory.New()
+	for i := 0; i < 20; i++ {
+		writeFile(c, fs, fmt.Sprintf("%02d", i), "foo")
+	}
+
+	err := billy.Grep(fs, "", "(", nil, nil)
+	c.Assert(err, ErrorMatches, "error parsing regexp.*")
+
+	errStop := errors.New("stop")
+	var n int
+	err = billy.Grep(fs, "", "foo", func(m billy.GrepMatch) error {
+		if n++; n == 3 {
+			return errStop
+		}
+
+		return nil
+	}, &billy.GrepOptions{Parallelism: 2})
+	c.Assert(err, Equals, errStop)
+	c.Assert(n, Equals, 3)
+
+	err = billy.Grep(fs, "missing", "foo", nil, nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *GrepSuite) TestGrepPanic(c *C) {
+	fs := &panicking{Filesystem: memory.New(), path: "qux/foo"}
+	writeFile(c, fs, "qux/foo", "foo")
+
+	c.Assert(func() {
+		billy.Grep(fs, "", "foo", func(m billy.GrepMatch) error {
+			return nil
+		}, nil)
+	}, PanicMatches, "panicking on qux/foo")
+}
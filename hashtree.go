@@ -0,0 +1,239 @@
+package billy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashCacheRacyWindow is the age below which the hashes of the files aren't
+// cached, since a later change may keep their size and their modification
+// time in the filesystems with coarse timestamps, such as FAT with 2 seconds.
+const hashCacheRacyWindow = 2 * time.Second
+
+// hashCacheFormat is the version of the encoding of the saved caches, the
+// caches saved with another one are discarded by LoadHashCache.
+const hashCacheFormat = 1
+
+// HashTreeOptions holds the configuration of HashTree.
+type HashTreeOptions struct {
+	// Cache holds the hashes of the files computed by previous calls, the
+	// files whose size, modification time and version, if any, didn't
+	// change aren't read again. If nil every file is read.
+	Cache *HashCache
+}
+
+// HashTree returns the hex encoded SHA-256 of the tree rooted at root,
+// covering the relative path, the type and the permissions of every entry,
+// the content of the regular files and the targets of the symbolic links.
+// Equal trees give the same hash in any filesystem.
+func HashTree(fs Filesystem, root string, opts *HashTreeOptions) (string, error) {
+	var cache *HashCache
+	if opts != nil {
+		cache = opts.Cache
+	}
+
+	start := time.Now()
+	seen := make(map[string]bool)
+	h := sha256.New()
+	err := Walk(fs, root, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var sum string
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if sum, err = fs.Readlink(path); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			seen[path] = true
+			if sum, err = cache.hash(fs, path, info, start); err != nil {
+				return err
+			}
+		}
+
+		_, err = fmt.Fprintf(h, "%s %04o %s\x00%s\n",
+			fileType(info.Mode()), info.Mode().Perm(), filepath.ToSlash(rel), sum,
+		)
+
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	cache.prune(root, seen)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashCache is a cache of the hashes of the files, keyed by their path, size,
+// modification time and version, used by HashTree. It can be shared by
+// concurrent calls on the same filesystem, and saved to be used by later
+// runs.
+type HashCache struct {
+	m       sync.Mutex
+	entries map[string]hashCacheEntry
+}
+
+type hashCacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Version string    `json:"version,omitempty"`
+	SHA256  string    `json:"sha256"`
+}
+
+type hashCacheFile struct {
+	Format  int                       `json:"format"`
+	Entries map[string]hashCacheEntry `json:"entries"`
+}
+
+// NewHashCache returns a new empty HashCache.
+func NewHashCache() *HashCache {
+	return &HashCache{entries: make(map[string]hashCacheEntry)}
+}
+
+// LoadHashCache reads the cache saved to the named file of fs. A missing
+// file, or one saved by an incompatible version, gives an empty cache.
+func LoadHashCache(fs Filesystem, filename string) (*HashCache, error) {
+	f, err := fs.Open(filename)
+	if os.IsNotExist(err) {
+		return NewHashCache(), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var cf hashCacheFile
+	if err := json.NewDecoder(f).Decode(&cf); err != nil {
+		return nil, err
+	}
+
+	if cf.Format != hashCacheFormat || cf.Entries == nil {
+		return NewHashCache(), nil
+	}
+
+	return &HashCache{entries: cf.Entries}, nil
+}
+
+// Save writes the cache to the named file of fs, replacing it atomically
+// through a temporary file renamed into place.
+func (c *HashCache) Save(fs Filesystem, filename string) error {
+	c.m.Lock()
+	data, err := json.Marshal(hashCacheFile{Format: hashCacheFormat, Entries: c.entries})
+	c.m.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f, tmpfs, err := TempFileFor(fs, nil, filename, ".hashcache")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	return Move(tmpfs, f.Filename(), fs, filename)
+}
+
+// Len returns the number of files in the cache.
+func (c *HashCache) Len() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return len(c.entries)
+}
+
+// hash returns the hash of the regular file path, from the cache if it
+// didn't change, reading it otherwise. A nil cache always reads it.
+func (c *HashCache) hash(fs Filesystem, path string, info FileInfo, start time.Time) (string, error) {
+	version, _ := FileVersion(info)
+	if c != nil {
+		c.m.Lock()
+		e, ok := c.entries[path]
+		c.m.Unlock()
+
+		if ok && e.Size == info.Size() && e.ModTime.Equal(info.ModTime()) && e.Version == version {
+			return e.SHA256, nil
+		}
+	}
+
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if c == nil {
+		return sum, nil
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if !info.ModTime().Before(start.Add(-hashCacheRacyWindow)) {
+		delete(c.entries, path)
+		return sum, nil
+	}
+
+	c.entries[path] = hashCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Version: version,
+		SHA256:  sum,
+	}
+
+	return sum, nil
+}
+
+// prune removes the entries under root not seen by the last walk, the files
+// removed since the previous ones.
+func (c *HashCache) prune(root string, seen map[string]bool) {
+	if c == nil {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	root = filepath.Clean(root)
+	prefix := root + string(filepath.Separator)
+	for path := range c.entries {
+		under := root == "." || path == root || strings.HasPrefix(path, prefix)
+		if under && !seen[path] {
+			delete(c.entries, path)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package billy_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type HashTreeSuite struct{}
+
+var _ = Suite(&HashTreeSuite{})
+
+func (s *HashTreeSuite) TestHashTree(c *C) {
+	a := memory.New()
+	writeFile(c, a, "qux/foo", "foo")
+	writeFile(c, a, "bar", "bar")
+
+	b := memory.New()
+	writeFile(c, b, "bar", "bar")
+	writeFile(c, b, "qux/foo", "foo")
+
+	ha, err := billy.HashTree(a, "", nil)
+	c.Assert(err, IsNil)
+	hb, err := billy.HashTree(b, "", nil)
+	c.Assert(err, IsNil)
+	c.Assert(ha, Equals, hb)
+
+	sub, err := billy.HashTree(a.Dir("qux"), "", nil)
+	c.Assert(err, IsNil)
+	hq, err := billy.HashTree(a, "qux", nil)
+	c.Assert(err, IsNil)
+	c.Assert(hq, Equals, sub)
+
+	writeFile(c, b, "qux/foo", "fo0")
+	hb, err = billy.HashTree(b, "", nil)
+	c.Assert(err, IsNil)
+	c.Assert(hb, Not(Equals), ha)
+
+	writeFile(c, b, "qux/foo", "foo")
+	c.Assert(b.Chmod("bar", 0755), IsNil)
+	hb, err = billy.HashTree(b, "", nil)
+	c.Assert(err, IsNil)
+	c.Assert(hb, Not(Equals), ha)
+}
+
+func (s *HashTreeSuite) TestHashTreeCache(c *C) {
+	fs := &countingOpen{Filesystem: memory.New()}
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"foo", "qux/bar", "qux/baz"} {
+		writeFile(c, fs, name, name)
+		c.Assert(fs.Chtimes(name, old, old), IsNil)
+	}
+
+	opts := &billy.HashTreeOptions{Cache: billy.NewHashCache()}
+	h, err := billy.HashTree(fs, "", opts)
+	c.Assert(err, IsNil)
+	c.Assert(fs.opens, Equals, 3)
+	c.Assert(opts.Cache.Len(), Equals, 3)
+
+	fs.opens = 0
+	cached, err := billy.HashTree(fs, "", opts)
+	c.Assert(err, IsNil)
+	c.Assert(cached, Equals, h)
+	c.Assert(fs.opens, Equals, 0)
+
+	writeFile(c, fs, "qux/bar", "bar")
+	c.Assert(fs.Remove("qux/baz"), IsNil)
+	changed, err := billy.HashTree(fs, "", opts)
+	c.Assert(err, IsNil)
+	c.Assert(changed, Not(Equals), h)
+	c.Assert(fs.opens, Equals, 1)
+
+	// the files modified recently aren't cached.
+	fs.opens = 0
+	_, err = billy.HashTree(fs, "", opts)
+	c.Assert(err, IsNil)
+	c.Assert(fs.opens, Equals, 1)
+	c.Assert(opts.Cache.Len(), Equals, 1)
+}
+
+func (s *HashTreeSuite) TestHashCacheSave(c *C) {
+	fs := &countingOpen{Filesystem: memory.New()}
+	old := time.Now().Add(-time.Hour)
+	writeFile(c, fs, "qux/foo", "foo")
+	c.Assert(fs.Chtimes("qux/foo", old, old), IsNil)
+
+	cache, err := billy.LoadHashCache(fs, ".hashcache")
+	c.Assert(err, IsNil)
+	c.Assert(cache.Len(), Equals, 0)
+
+	h, err := billy.HashTree(fs, "qux", &billy.HashTreeOptions{Cache: cache})
+	c.Assert(err, IsNil)
+	c.Assert(cache.Save(fs, ".hashcache"), IsNil)
+
+	cache, err = billy.LoadHashCache(fs, ".hashcache")
+	c.Assert(err, IsNil)
+	c.Assert(cache.Len(), Equals, 1)
+
+	fs.opens = 0
+	cached, err := billy.HashTree(fs, "qux", &billy.HashTreeOptions{Cache: cache})
+	c.Assert(err, IsNil)
+	c.Assert(cached, Equals, h)
+	c.Assert(fs.opens, Equals, 0)
+}
+
+// countingOpen counts the files opened.
+type countingOpen struct {
+	billy.Filesystem
+	opens int
+}
+
+func (fs *countingOpen) Open(filename string) (billy.File, error) {
+	fs.opens++
+	return fs.Filesystem.Open(filename)
+}
@@ -0,0 +1,40 @@
+package billy
+
+import (
+	"context"
+	"time"
+)
+
+// Pinger is an optional interface implemented by the filesystems able to
+// check if their backend is reachable, such as the remote ones, and by the
+// wrappers, checking the filesystems they wrap.
+type Pinger interface {
+	// Ping returns an error if the backend is not available, it must return
+	// when ctx is done.
+	Ping(ctx context.Context) error
+}
+
+// Ping checks if fs is available, returning the time taken by the check, so
+// it can be included in readiness probes. The filesystems not implementing
+// Pinger are checked with a Stat of their root, abandoned when ctx is done,
+// since it may block on a unresponsive network filesystem.
+func Ping(ctx context.Context, fs Filesystem) (time.Duration, error) {
+	start := time.Now()
+	if p, ok := fs.(Pinger); ok {
+		err := p.Ping(ctx)
+		return time.Since(start), err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := fs.Stat("")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return time.Since(start), err
+	case <-ctx.Done():
+		return time.Since(start), ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package billy_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type HealthSuite struct{}
+
+var _ = Suite(&HealthSuite{})
+
+func (s *HealthSuite) TestPing(c *C) {
+	_, err := billy.Ping(context.Background(), memory.New())
+	c.Assert(err, IsNil)
+
+	errDown := errors.New("down")
+	_, err = billy.Ping(context.Background(), &pinger{Filesystem: memory.New(), err: errDown})
+	c.Assert(err, Equals, errDown)
+}
+
+func (s *HealthSuite) TestPingTimeout(c *C) {
+	fs := &blockingStat{Filesystem: memory.New(), done: make(chan struct{})}
+	defer close(fs.done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	latency, err := billy.Ping(ctx, fs)
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(latency >= 10*time.Millisecond, Equals, true)
+}
+
+type pinger struct {
+	billy.Filesystem
+	err error
+}
+
+func (p *pinger) Ping(ctx context.Context) error {
+	return p.err
+}
+
+// blockingStat is a filesystem whose Stat blocks until done is closed.
+type blockingStat struct {
+	billy.Filesystem
+	done chan struct{}
+}
+
+func (fs *blockingStat) Stat(filename string) (billy.FileInfo, error) {
+	<-fs.done
+	return fs.Filesystem.Stat(filename)
+}
@@ -0,0 +1,386 @@
+// Package indexfs provides a billy filesystem wrapper keeping an index of the
+// paths, sizes, modes, modification times and hashes of a remote backend,
+// serving Stat, Lstat and ReadDir from it, so browsing a huge remote tree
+// doesn't wait for the network. The index is saved to a local filesystem and
+// refreshed incrementally with the billy.ChangeLog of the backend.
+package indexfs // import "srcd.works/go-billy.v1/indexfs"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Options holds the configuration of an Index filesystem.
+type Options struct {
+	// Store is the filesystem where the index is saved, usually a local one,
+	// and Filename the name of the file, ".billy-index" by default. If
+	// Store is nil the index is kept only in memory.
+	Store    billy.Filesystem
+	Filename string
+	// MaxAge is the age of the index after which the lookups refresh it
+	// first. If zero it's refreshed only by Refresh.
+	MaxAge time.Duration
+}
+
+var defaultOptions = Options{
+	Filename: ".billy-index",
+}
+
+// Index wraps a billy.Filesystem serving Stat, Lstat and ReadDir from an
+// index of its tree. The index is built by a full walk the first time, and
+// refreshed with the changes recorded since then if the filesystem is a
+// billy.ChangeLog, or walking it again otherwise, or if the changes expired.
+// The writes done through the filesystem, or the ones returned by Dir,
+// update the index right away, the ones done by others are seen after the
+// next refresh. The paths under symbolic links, and the targets of the ones
+// followed by Stat, are looked up in the filesystem.
+type Index struct {
+	fs     billy.Filesystem
+	s      *state
+	prefix string
+}
+
+// New returns a new Index filesystem wrapping fs, loading the index saved
+// to opts.Store, if any, and refreshing it. If opts is nil the default
+// options are used, as for their zero fields.
+func New(fs billy.Filesystem, opts *Options) (*Index, error) {
+	o := defaultOptions
+	if opts != nil {
+		o.Store = opts.Store
+		o.MaxAge = opts.MaxAge
+		if opts.Filename != "" {
+			o.Filename = opts.Filename
+		}
+	}
+
+	s := &state{fs: fs, opts: o}
+	s.reset()
+	if o.Store != nil {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	return &Index{fs: fs, s: s}, nil
+}
+
+// Refresh updates the index with the changes made to the filesystem since
+// the last refresh, and saves it to Options.Store.
+func (fs *Index) Refresh() error {
+	return fs.s.refresh()
+}
+
+// Save saves the index to Options.Store, including the writes done through
+// the filesystem since the last refresh.
+func (fs *Index) Save() error {
+	if fs.s.opts.Store == nil {
+		return nil
+	}
+
+	return fs.s.save()
+}
+
+// Hash returns the hex encoded SHA-256 of the content of the named file, from
+// the index if it was already computed for its current size and modification
+// time.
+func (fs *Index) Hash(filename string) (string, error) {
+	key := fs.key(filename)
+	n, err := fs.lookup(key, "hash", filename)
+	if err != nil {
+		return "", err
+	}
+
+	if n == nil || !n.Mode.IsRegular() {
+		return "", &os.PathError{Op: "hash", Path: filename, Err: billy.ErrNotSupported}
+	}
+
+	if n.SHA256 != "" {
+		return n.SHA256, nil
+	}
+
+	f, err := fs.s.fs.Open(key)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	fs.s.setHash(key, n, sum)
+	return sum, nil
+}
+
+func (fs *Index) key(filename string) string {
+	return clean(path.Join(fs.prefix, slash(filename)))
+}
+
+// lookup returns the node of key, or nil if it must be looked up in the
+// filesystem, being under a symbolic link.
+func (fs *Index) lookup(key, op, filename string) (*node, error) {
+	if err := fs.s.maybeRefresh(); err != nil {
+		return nil, err
+	}
+
+	n, ok := fs.s.get(key)
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: filename, Err: os.ErrNotExist}
+	}
+
+	return n, nil
+}
+
+// Stat returns the FileInfo of the named file from the index.
+func (fs *Index) Stat(filename string) (billy.FileInfo, error) {
+	n, err := fs.lookup(fs.key(filename), "stat", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == nil || n.Mode&os.ModeSymlink != 0 {
+		return fs.fs.Stat(filename)
+	}
+
+	return n.info(path.Base(fs.key(filename))), nil
+}
+
+// Lstat returns the FileInfo of the named file from the index, without
+// following symbolic links.
+func (fs *Index) Lstat(filename string) (billy.FileInfo, error) {
+	n, err := fs.lookup(fs.key(filename), "lstat", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == nil {
+		return fs.fs.Lstat(filename)
+	}
+
+	return n.info(path.Base(fs.key(filename))), nil
+}
+
+// ReadDir returns the entries of the given directory from the index, sorted
+// by name.
+func (fs *Index) ReadDir(dir string) ([]billy.FileInfo, error) {
+	key := fs.key(dir)
+	n, err := fs.lookup(key, "readdir", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == nil || !n.Mode.IsDir() {
+		return fs.fs.ReadDir(dir)
+	}
+
+	return fs.s.list(key), nil
+}
+
+// Create creates the named file.
+func (fs *Index) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *Index) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, the files opened for writing update the
+// index when opened, truncated, synced and closed.
+func (fs *Index) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil || !isWrite(flag) {
+		return f, err
+	}
+
+	fs.updateTarget(filename)
+	return &file{File: f, fs: fs, name: filename}, nil
+}
+
+// TempFile creates a temporary file.
+func (fs *Index) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.fs.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.update(f.Filename())
+	return &file{File: f, fs: fs, name: f.Filename()}, nil
+}
+
+// Rename renames a file, moving its entries in the index.
+func (fs *Index) Rename(from, to string) error {
+	if err := fs.fs.Rename(from, to); err != nil {
+		return err
+	}
+
+	fs.s.move(fs.key(from), fs.key(to))
+	fs.update(to)
+	fs.update(path.Dir(slash(from)))
+	return nil
+}
+
+// Remove removes a file, and its entry of the index.
+func (fs *Index) Remove(filename string) error {
+	if err := fs.fs.Remove(filename); err != nil {
+		return err
+	}
+
+	fs.update(filename)
+	fs.update(path.Dir(slash(filename)))
+	return nil
+}
+
+// Symlink creates a symbolic link.
+func (fs *Index) Symlink(target, link string) error {
+	defer fs.update(link)
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Index) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *Index) MkdirAll(path string, perm os.FileMode) error {
+	defer fs.updateTarget(path)
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Index) Chmod(name string, mode os.FileMode) error {
+	defer fs.updateTarget(name)
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Index) Chtimes(name string, atime, mtime time.Time) error {
+	defer fs.updateTarget(name)
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Index) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Index filesystem rooted at the given path, sharing the
+// index with the current one.
+func (fs *Index) Dir(p string) billy.Filesystem {
+	return &Index{
+		fs:     fs.fs.Dir(p),
+		s:      fs.s,
+		prefix: fs.key(p),
+	}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Index) Base() string {
+	return fs.fs.Base()
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Index) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close. The store of the
+// index isn't closed, it's owned by the caller.
+func (fs *Index) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// update updates the entry of filename from the filesystem, following
+// the symbolic links of its parents.
+func (fs *Index) update(filename string) {
+	fs.s.update(fs.s.resolve(fs.key(filename), false))
+}
+
+// updateTarget updates the entry of filename as update, following also
+// filename itself if it's a symbolic link.
+func (fs *Index) updateTarget(filename string) {
+	fs.s.update(fs.s.resolve(fs.key(filename), true))
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// slash converts the separators of filename to slashes, since billy
+// filenames may use any of them.
+func slash(filename string) string {
+	return strings.Replace(filename, `\`, "/", -1)
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// file is a file open for writing, updating its entry on Truncate, Sync and
+// Close, since doing it on every write would cost a request to the backend.
+type file struct {
+	billy.File
+	fs   *Index
+	name string
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
+
+func (f *file) Truncate(size int64) error {
+	defer f.fs.updateTarget(f.name)
+	return f.File.Truncate(size)
+}
+
+func (f *file) Sync() error {
+	defer f.fs.updateTarget(f.name)
+	return f.File.Sync()
+}
+
+func (f *file) Close() error {
+	defer f.fs.updateTarget(f.name)
+	return f.File.Close()
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+type byName []billy.FileInfo
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Less(i, j int) bool { return l[i].Name() < l[j].Name() }
+func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
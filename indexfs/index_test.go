@@ -0,0 +1,210 @@
+package indexfs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	fs, err := New(memory.New(), nil)
+	c.Assert(err, IsNil)
+	s.FilesystemSuite.Fs = fs
+}
+
+type IndexSuite struct {
+	remote *counting
+	store  billy.Filesystem
+}
+
+var _ = Suite(&IndexSuite{})
+
+func (s *IndexSuite) SetUpTest(c *C) {
+	s.remote = &counting{Memory: memory.New()}
+	s.store = memory.New()
+}
+
+func (s *IndexSuite) newIndex(c *C) *Index {
+	fs, err := New(s.remote, &Options{Store: s.store})
+	c.Assert(err, IsNil)
+	return fs
+}
+
+// counting counts the lookups done in a memory filesystem, keeping its
+// change log.
+type counting struct {
+	*memory.Memory
+	lookups int
+}
+
+func (fs *counting) Stat(filename string) (billy.FileInfo, error) {
+	fs.lookups++
+	return fs.Memory.Stat(filename)
+}
+
+func (fs *counting) Lstat(filename string) (billy.FileInfo, error) {
+	fs.lookups++
+	return fs.Memory.Lstat(filename)
+}
+
+func (fs *counting) ReadDir(path string) ([]billy.FileInfo, error) {
+	fs.lookups++
+	return fs.Memory.ReadDir(path)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readDirNames(c *C, fs billy.Filesystem, dir string) []string {
+	infos, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
+
+func (s *IndexSuite) TestLookups(c *C) {
+	writeFile(c, s.remote, "qux/foo", "foo")
+	writeFile(c, s.remote, "qux/bar", "bar")
+	fs := s.newIndex(c)
+
+	s.remote.lookups = 0
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"bar", "foo"})
+	fi, err := fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+	_, err = fs.Stat("qux/baz")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(s.remote.lookups, Equals, 0)
+}
+
+func (s *IndexSuite) TestRefresh(c *C) {
+	writeFile(c, s.remote, "qux/foo", "foo")
+	writeFile(c, s.remote, "qux/bar", "bar")
+	fs := s.newIndex(c)
+
+	writeFile(c, s.remote, "qux/foo", "foofoo")
+	writeFile(c, s.remote, "baz/qux", "qux")
+	c.Assert(s.remote.Rename("qux/bar", "baz/bar"), IsNil)
+	_, err := fs.Stat("baz")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	s.remote.lookups = 0
+	c.Assert(fs.Refresh(), IsNil)
+	c.Assert(s.remote.lookups < 10, Equals, true)
+
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"baz", "qux"})
+	c.Assert(readDirNames(c, fs, "baz"), DeepEquals, []string{"bar", "qux"})
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"foo"})
+	fi, err := fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(6))
+
+	c.Assert(s.remote.Remove("baz/qux"), IsNil)
+	c.Assert(fs.Refresh(), IsNil)
+	c.Assert(readDirNames(c, fs, "baz"), DeepEquals, []string{"bar"})
+}
+
+func (s *IndexSuite) TestWrites(c *C) {
+	fs := s.newIndex(c)
+	writeFile(c, fs.Dir("qux"), "foo", "foo")
+	c.Assert(fs.Rename("qux/foo", "baz/foo"), IsNil)
+	c.Assert(fs.MkdirAll("qux/empty", 0755), IsNil)
+
+	s.remote.lookups = 0
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"baz", "qux"})
+	c.Assert(readDirNames(c, fs, "baz"), DeepEquals, []string{"foo"})
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"empty"})
+	c.Assert(s.remote.lookups, Equals, 0)
+
+	c.Assert(fs.Symlink("baz", "link"), IsNil)
+	writeFile(c, fs, "link/bar", "bar")
+	c.Assert(readDirNames(c, fs, "baz"), DeepEquals, []string{"bar", "foo"})
+}
+
+func (s *IndexSuite) TestPersist(c *C) {
+	writeFile(c, s.remote, "qux/foo", "foo")
+	fs := s.newIndex(c)
+	sum, err := fs.Hash("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(sum, Equals, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	c.Assert(fs.Save(), IsNil)
+
+	writeFile(c, s.remote, "qux/bar", "bar")
+	s.remote.lookups = 0
+	fs = s.newIndex(c)
+	c.Assert(s.remote.lookups, Equals, 2)
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"bar", "foo"})
+
+	s.remote.lookups = 0
+	sum, err = fs.Hash("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(sum, Equals, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+	c.Assert(s.remote.lookups, Equals, 0)
+}
+
+func (s *IndexSuite) TestWithoutChangeLog(c *C) {
+	remote := memory.New()
+	writeFile(c, remote, "qux/foo", "foo")
+	fs, err := New(struct{ billy.Filesystem }{remote}, nil)
+	c.Assert(err, IsNil)
+
+	writeFile(c, remote, "qux/bar", "bar")
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"foo"})
+	c.Assert(fs.Refresh(), IsNil)
+	c.Assert(readDirNames(c, fs, "qux"), DeepEquals, []string{"bar", "foo"})
+}
+
+func (s *IndexSuite) TestSymlink(c *C) {
+	writeFile(c, s.remote, "qux/foo", "foo")
+	c.Assert(s.remote.Symlink("qux", "link"), IsNil)
+	fs := s.newIndex(c)
+
+	fi, err := fs.Lstat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+
+	fi, err = fs.Stat("link/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(readDirNames(c, fs, "link"), DeepEquals, []string{"foo"})
+}
+
+func (s *IndexSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend: "mem://indexfs-compose",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "index",
+			Options: map[string]string{"store": "mem://indexfs-store", "max-age": "1m"},
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fs.(*Index).s.opts.MaxAge, Equals, time.Minute)
+
+	store, err := billy.Open("mem://indexfs-store")
+	c.Assert(err, IsNil)
+	_, err = store.Stat(".billy-index")
+	c.Assert(err, IsNil)
+}
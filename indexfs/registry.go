@@ -0,0 +1,35 @@
+package indexfs
+
+import (
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("index", wrap)
+}
+
+// wrap returns an Index filesystem wrapping fs, with the store option as the
+// URI of the filesystem where the index is saved, as given to billy.Open, the
+// file option as its name and the max-age option as a duration.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	o := Options{Filename: opts["file"]}
+	if uri, ok := opts["store"]; ok {
+		store, err := billy.Open(uri)
+		if err != nil {
+			return nil, err
+		}
+
+		o.Store = store
+	}
+
+	if v, ok := opts["max-age"]; ok {
+		var err error
+		if o.MaxAge, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return New(fs, &o)
+}
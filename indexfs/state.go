@@ -0,0 +1,468 @@
+package indexfs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// indexFormat is the version of the encoding of the saved indexes, the ones
+// saved with another one are discarded, rebuilding the index.
+const indexFormat = 1
+
+// node is the entry of a path in the index.
+type node struct {
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	// SHA256 is the hash of the content of a regular file, computed on
+	// demand by Index.Hash and dropped when the file changes.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+func (n *node) info(name string) billy.FileInfo {
+	if name == "." {
+		name = "/"
+	}
+
+	return &fileInfo{name: name, size: n.Size, mode: n.Mode, modTime: n.ModTime}
+}
+
+type indexFile struct {
+	Format int              `json:"format"`
+	Cursor uint64           `json:"cursor"`
+	Nodes  map[string]*node `json:"nodes"`
+}
+
+// state is the index shared by an Index and the ones returned by its Dir,
+// with the paths relative to the filesystem given to New.
+type state struct {
+	fs   billy.Filesystem
+	opts Options
+
+	// refreshing serializes the refreshes.
+	refreshing sync.Mutex
+
+	m         sync.RWMutex
+	nodes     map[string]*node
+	children  map[string]map[string]bool
+	cursor    uint64
+	refreshed time.Time
+}
+
+// reset empties the index.
+func (s *state) reset() {
+	s.nodes = make(map[string]*node)
+	s.children = make(map[string]map[string]bool)
+	s.cursor = 0
+}
+
+// load reads the index saved to the store, if any.
+func (s *state) load() error {
+	f, err := s.opts.Store.Open(s.opts.Filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	var idx indexFile
+	if err := json.NewDecoder(f).Decode(&idx); err != nil {
+		return err
+	}
+
+	if idx.Format != indexFormat {
+		return nil
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for key, n := range idx.Nodes {
+		s.set(key, n)
+	}
+
+	s.cursor = idx.Cursor
+	return nil
+}
+
+// save writes the index to the store, replacing it through a temporary file.
+func (s *state) save() error {
+	s.m.RLock()
+	data, err := json.Marshal(indexFile{Format: indexFormat, Cursor: s.cursor, Nodes: s.nodes})
+	s.m.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	store := s.opts.Store
+	f, tmpfs, err := billy.TempFileFor(store, nil, s.opts.Filename, ".billy-index")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	return billy.Move(tmpfs, f.Filename(), store, s.opts.Filename)
+}
+
+// maybeRefresh refreshes the index if it's older than Options.MaxAge.
+func (s *state) maybeRefresh() error {
+	if s.opts.MaxAge <= 0 {
+		return nil
+	}
+
+	s.m.RLock()
+	fresh := time.Since(s.refreshed) < s.opts.MaxAge
+	s.m.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	return s.refresh()
+}
+
+// refresh applies the changes recorded since the last refresh, or rebuilds
+// the index if they aren't available, and saves it.
+func (s *state) refresh() error {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+
+	start := time.Now()
+	err := s.applyChanges()
+	if err == errRebuild {
+		err = s.rebuild()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	s.refreshed = start
+	s.m.Unlock()
+
+	if s.opts.Store == nil {
+		return nil
+	}
+
+	return s.save()
+}
+
+// errRebuild is returned by applyChanges when the index must be rebuilt.
+var errRebuild = errors.New("indexfs: rebuild required")
+
+// applyChanges updates the index with the changes recorded by the change log
+// of the filesystem after the cursor of the index.
+func (s *state) applyChanges() error {
+	cl, ok := s.fs.(billy.ChangeLog)
+	s.m.RLock()
+	cursor, empty := s.cursor, len(s.nodes) == 0
+	s.m.RUnlock()
+	if !ok || empty {
+		return errRebuild
+	}
+
+	events, next, err := cl.Changes(cursor)
+	if err == billy.ErrCursorExpired {
+		return errRebuild
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		key := clean(slash(e.Path))
+		switch e.Op {
+		case billy.ChangeWrite, billy.ChangeMetadata:
+			s.update(key)
+		case billy.ChangeRename:
+			old := clean(slash(e.OldPath))
+			s.move(old, key)
+			s.update(parent(old))
+			fallthrough
+		default:
+			s.update(key)
+			s.update(parent(key))
+		}
+	}
+
+	s.m.Lock()
+	s.cursor = next
+	s.m.Unlock()
+	return nil
+}
+
+// rebuild walks the whole filesystem, keeping the hashes of the files not
+// changed. The cursor is taken before the walk, so the changes done during
+// it are applied again by the next refresh.
+func (s *state) rebuild() error {
+	var cursor uint64
+	if cl, ok := s.fs.(billy.ChangeLog); ok {
+		// the cursor is returned along with ErrCursorExpired too, when the
+		// oldest changes were discarded.
+		var err error
+		_, cursor, err = cl.Changes(0)
+		if err != nil && err != billy.ErrCursorExpired {
+			return err
+		}
+	}
+
+	nodes := make(map[string]*node)
+	err := billy.Walk(s.fs, "", func(p string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		nodes[clean(slash(p))] = newNode(info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	old := s.nodes
+	s.reset()
+	for key, n := range nodes {
+		if o, ok := old[key]; ok && o.unchanged(n) {
+			n.SHA256 = o.SHA256
+		}
+
+		s.set(key, n)
+	}
+
+	s.cursor = cursor
+	return nil
+}
+
+// update updates the entry of key from the filesystem, removing it if it's
+// missing, and adding its parents if they were missing too. The
+// directories not indexed yet are walked.
+func (s *state) update(key string) {
+	fi, err := s.fs.Lstat(key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.m.Lock()
+			s.remove(key)
+			s.m.Unlock()
+		}
+
+		return
+	}
+
+	n := newNode(fi)
+	s.m.Lock()
+	o, known := s.nodes[key]
+	if known && o.unchanged(n) {
+		n.SHA256 = o.SHA256
+	}
+
+	s.set(key, n)
+	_, parentKnown := s.nodes[parent(key)]
+	s.m.Unlock()
+
+	if key != "" && !parentKnown {
+		s.update(parent(key))
+	}
+
+	if n.Mode.IsDir() && !known {
+		s.walk(key)
+	}
+}
+
+// maxHops is the maximum number of symbolic links followed by resolve.
+const maxHops = 40
+
+// resolve returns the key of the file reached by key, following the
+// symbolic links of its parents, and of key itself if follow is true, so the
+// writes through the links update the entries of their targets. The
+// absolute targets are relative to the root of the filesystem.
+func (s *state) resolve(key string, follow bool) string {
+	parts := split(key)
+	var resolved string
+	for hops := 0; len(parts) != 0; {
+		p := path.Join(resolved, parts[0])
+		parts = parts[1:]
+
+		s.m.RLock()
+		n, ok := s.nodes[p]
+		s.m.RUnlock()
+		if !ok || n.Mode&os.ModeSymlink == 0 || (len(parts) == 0 && !follow) {
+			resolved = p
+			continue
+		}
+
+		target, err := s.fs.Readlink(p)
+		if hops++; err != nil || hops > maxHops {
+			return key
+		}
+
+		if path.IsAbs(slash(target)) {
+			resolved = ""
+		}
+
+		parts = append(split(target), parts...)
+	}
+
+	return clean(resolved)
+}
+
+// walk adds the entries under the directory key.
+func (s *state) walk(key string) {
+	billy.Walk(s.fs, key, func(p string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		s.m.Lock()
+		s.set(clean(slash(p)), newNode(info))
+		s.m.Unlock()
+		return nil
+	})
+}
+
+// move moves the entries of from, and the ones under it, to to.
+func (s *state) move(from, to string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	moved := make(map[string]*node)
+	for key, n := range s.nodes {
+		if key == from || strings.HasPrefix(key, from+"/") {
+			moved[to+strings.TrimPrefix(key, from)] = n
+		}
+	}
+
+	s.remove(from)
+	for key, n := range moved {
+		s.set(key, n)
+	}
+}
+
+// get returns the node of key, or nil if key is under a symbolic link, and
+// false if it's not indexed.
+func (s *state) get(key string) (*node, bool) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if n, ok := s.nodes[key]; ok {
+		return n, true
+	}
+
+	for p := parent(key); ; p = parent(p) {
+		if n, ok := s.nodes[p]; ok && n.Mode&os.ModeSymlink != 0 {
+			return nil, true
+		}
+
+		if p == "" {
+			return nil, false
+		}
+	}
+}
+
+// list returns the entries of the directory key, sorted by name.
+func (s *state) list(key string) []billy.FileInfo {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	l := make([]billy.FileInfo, 0, len(s.children[key]))
+	for name := range s.children[key] {
+		l = append(l, s.nodes[path.Join(key, name)].info(name))
+	}
+
+	sort.Sort(byName(l))
+	return l
+}
+
+// setHash records the hash of the file key, unless it changed since n was
+// read.
+func (s *state) setHash(key string, n *node, sum string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if o, ok := s.nodes[key]; ok && o.unchanged(n) {
+		o.SHA256 = sum
+	}
+}
+
+// set sets the node of key, the lock must be held.
+func (s *state) set(key string, n *node) {
+	s.nodes[key] = n
+	if key == "" {
+		return
+	}
+
+	p := parent(key)
+	if s.children[p] == nil {
+		s.children[p] = make(map[string]bool)
+	}
+
+	s.children[p][path.Base(key)] = true
+}
+
+// remove removes the node of key and the ones under it, the lock must be
+// held.
+func (s *state) remove(key string) {
+	for name := range s.children[key] {
+		s.remove(path.Join(key, name))
+	}
+
+	delete(s.nodes, key)
+	delete(s.children, key)
+	if key != "" {
+		delete(s.children[parent(key)], path.Base(key))
+	}
+}
+
+func newNode(fi billy.FileInfo) *node {
+	return &node{Size: fi.Size(), Mode: fi.Mode(), ModTime: fi.ModTime()}
+}
+
+// unchanged returns true if n has the same type, size and modification time
+// as o.
+func (n *node) unchanged(o *node) bool {
+	return n.Mode.IsRegular() == o.Mode.IsRegular() && n.Size == o.Size && n.ModTime.Equal(o.ModTime)
+}
+
+func split(p string) []string {
+	var parts []string
+	for _, part := range strings.Split(slash(p), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
+func parent(key string) string {
+	if p := path.Dir(key); p != "." {
+		return p
+	}
+
+	return ""
+}
@@ -0,0 +1,209 @@
+// Package connpool provides a pool of connections for the network backends,
+// reusing the idle connections and redialing with backoff when they fail, so
+// each backend doesn't need to implement its own connection management.
+package connpool // import "srcd.works/go-billy.v1/internal/connpool"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Get when the pool is closed.
+var ErrClosed = errors.New("connpool: pool closed")
+
+// DialFunc opens a new connection.
+type DialFunc func(ctx context.Context) (io.Closer, error)
+
+// Options holds the configuration of a Pool.
+type Options struct {
+	// Size is the maximum number of connections open at once, Get blocks
+	// while all of them are in use. 4 by default.
+	Size int
+	// IdleTimeout closes the idle connections unused for longer than it,
+	// zero keeps them open.
+	IdleTimeout time.Duration
+	// Attempts is the number of times a connection is dialed before giving
+	// up, 5 by default.
+	Attempts int
+	// MinBackoff is the time waited after the first failed dial, doubled
+	// after each failure up to MaxBackoff. 100ms and 10s by default.
+	MinBackoff, MaxBackoff time.Duration
+}
+
+var defaultOptions = Options{
+	Size:       4,
+	Attempts:   5,
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// Pool is a pool of connections, safe for concurrent use.
+type Pool struct {
+	dial  DialFunc
+	opts  Options
+	slots chan struct{}
+
+	m      sync.Mutex
+	idle   []idleConn
+	closed bool
+}
+
+type idleConn struct {
+	c     io.Closer
+	since time.Time
+}
+
+// New returns a new Pool opening the connections with dial, if opts is nil
+// the default options are used, as for their zero fields.
+func New(dial DialFunc, opts *Options) *Pool {
+	o := defaultOptions
+	if opts != nil {
+		o = *opts
+		if o.Size <= 0 {
+			o.Size = defaultOptions.Size
+		}
+
+		if o.Attempts <= 0 {
+			o.Attempts = defaultOptions.Attempts
+		}
+
+		if o.MinBackoff <= 0 {
+			o.MinBackoff = defaultOptions.MinBackoff
+		}
+
+		if o.MaxBackoff <= 0 {
+			o.MaxBackoff = defaultOptions.MaxBackoff
+		}
+	}
+
+	return &Pool{
+		dial:  dial,
+		opts:  o,
+		slots: make(chan struct{}, o.Size),
+	}
+}
+
+// Get returns an idle connection, or a new one if there is none. The
+// connection must be given back with Put, or with Discard if it failed.
+func (p *Pool) Get(ctx context.Context) (io.Closer, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	c, err := p.popIdle()
+	if c != nil || err != nil {
+		if err != nil {
+			<-p.slots
+		}
+
+		return c, err
+	}
+
+	c, err = p.redial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// popIdle returns the most recently used idle connection, closing the ones
+// idle for too long.
+func (p *Pool) popIdle() (io.Closer, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	if p.opts.IdleTimeout > 0 {
+		deadline := time.Now().Add(-p.opts.IdleTimeout)
+		var i int
+		for i < len(p.idle) && p.idle[i].since.Before(deadline) {
+			p.idle[i].c.Close()
+			i++
+		}
+
+		p.idle = p.idle[i:]
+	}
+
+	n := len(p.idle)
+	if n == 0 {
+		return nil, nil
+	}
+
+	c := p.idle[n-1].c
+	p.idle = p.idle[:n-1]
+	return c, nil
+}
+
+// redial dials a new connection, retrying with exponential backoff. The
+// error of the last attempt is returned.
+func (p *Pool) redial(ctx context.Context) (io.Closer, error) {
+	backoff := p.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		c, err := p.dial(ctx)
+		if err == nil || attempt == p.opts.Attempts {
+			return c, err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		}
+
+		if backoff *= 2; backoff > p.opts.MaxBackoff {
+			backoff = p.opts.MaxBackoff
+		}
+	}
+}
+
+// Put gives back a connection obtained with Get, keeping it idle for reuse.
+func (p *Pool) Put(c io.Closer) {
+	defer func() { <-p.slots }()
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if p.closed {
+		c.Close()
+		return
+	}
+
+	p.idle = append(p.idle, idleConn{c: c, since: time.Now()})
+}
+
+// Discard closes a connection obtained with Get that failed, so a new one is
+// dialed by the next Get.
+func (p *Pool) Discard(c io.Closer) {
+	c.Close()
+	<-p.slots
+}
+
+// Close closes the idle connections, the ones in use are closed when given
+// back. Get returns ErrClosed afterwards.
+func (p *Pool) Close() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	var err error
+	for _, ic := range p.idle {
+		if cerr := ic.c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	p.idle = nil
+	p.closed = true
+	return err
+}
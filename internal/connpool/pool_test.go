@@ -0,0 +1,124 @@
+package connpool_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/internal/connpool"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PoolSuite struct{}
+
+var _ = Suite(&PoolSuite{})
+
+type conn struct {
+	id     int
+	closed bool
+}
+
+func (c *conn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// dialer returns a DialFunc failing the first failures calls.
+func dialer(failures int, dialed *int) connpool.DialFunc {
+	return func(ctx context.Context) (io.Closer, error) {
+		*dialed++
+		if *dialed <= failures {
+			return nil, errors.New("connection refused")
+		}
+
+		return &conn{id: *dialed}, nil
+	}
+}
+
+func (s *PoolSuite) TestGetPut(c *C) {
+	var dialed int
+	p := connpool.New(dialer(0, &dialed), nil)
+
+	a, err := p.Get(context.Background())
+	c.Assert(err, IsNil)
+	p.Put(a)
+
+	b, err := p.Get(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(b, Equals, a)
+	c.Assert(dialed, Equals, 1)
+
+	p.Discard(b)
+	c.Assert(b.(*conn).closed, Equals, true)
+
+	b, err = p.Get(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(b.(*conn).id, Equals, 2)
+
+	p.Put(b)
+	c.Assert(p.Close(), IsNil)
+	c.Assert(b.(*conn).closed, Equals, true)
+
+	_, err = p.Get(context.Background())
+	c.Assert(err, Equals, connpool.ErrClosed)
+}
+
+func (s *PoolSuite) TestSize(c *C) {
+	var dialed int
+	p := connpool.New(dialer(0, &dialed), &connpool.Options{Size: 1})
+
+	a, err := p.Get(context.Background())
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = p.Get(ctx)
+	c.Assert(err, Equals, context.DeadlineExceeded)
+
+	p.Put(a)
+	_, err = p.Get(context.Background())
+	c.Assert(err, IsNil)
+}
+
+func (s *PoolSuite) TestRedial(c *C) {
+	var dialed int
+	p := connpool.New(dialer(2, &dialed), &connpool.Options{
+		MinBackoff: time.Millisecond,
+	})
+
+	a, err := p.Get(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(a.(*conn).id, Equals, 3)
+
+	dialed = 0
+	p = connpool.New(dialer(10, &dialed), &connpool.Options{
+		Attempts:   3,
+		MinBackoff: time.Millisecond,
+	})
+
+	_, err = p.Get(context.Background())
+	c.Assert(err, ErrorMatches, "connection refused")
+	c.Assert(dialed, Equals, 3)
+}
+
+func (s *PoolSuite) TestIdleTimeout(c *C) {
+	var dialed int
+	p := connpool.New(dialer(0, &dialed), &connpool.Options{
+		IdleTimeout: time.Millisecond,
+	})
+
+	a, err := p.Get(context.Background())
+	c.Assert(err, IsNil)
+	p.Put(a)
+
+	time.Sleep(5 * time.Millisecond)
+	b, err := p.Get(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(b, Not(Equals), a)
+	c.Assert(a.(*conn).closed, Equals, true)
+}
@@ -0,0 +1,164 @@
+// Package dirmarker implements the policies of the object-store backends to
+// represent directories. Object stores have no directories: some tools
+// emulate them with the common prefixes of the keys, and some create
+// zero-byte objects named after the directory with a trailing slash. Mixing
+// both leaves ghost directories, so the backends read both conventions and
+// write the one configured.
+package dirmarker // import "srcd.works/go-billy.v1/internal/dirmarker"
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1/internal/listcache"
+)
+
+// Policy is a way to represent the directories.
+type Policy int
+
+const (
+	// Implicit represents the directories only as the common prefixes of
+	// the keys, so an empty directory doesn't exist.
+	Implicit Policy = iota
+	// Marker represents every directory with a marker object, so empty
+	// directories exist.
+	Marker
+)
+
+// ParsePolicy returns the Policy with the given name, "implicit" or "marker".
+func ParsePolicy(name string) (Policy, error) {
+	switch name {
+	case "implicit":
+		return Implicit, nil
+	case "marker":
+		return Marker, nil
+	default:
+		return Implicit, fmt.Errorf("dirmarker: unknown policy %q", name)
+	}
+}
+
+func (p Policy) String() string {
+	switch p {
+	case Implicit:
+		return "implicit"
+	case Marker:
+		return "marker"
+	default:
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+}
+
+// Key returns the key of the marker of dir.
+func Key(dir string) string {
+	return clean(dir) + "/"
+}
+
+// IsMarker reports whether the object with the given key and size is a
+// directory marker.
+func IsMarker(key string, size int64) bool {
+	return size == 0 && strings.HasSuffix(key, "/")
+}
+
+// MkdirAll returns the keys of the markers to create by MkdirAll(dir),
+// parents first. With the Implicit policy there are none.
+func (p Policy) MkdirAll(dir string) []string {
+	if p != Marker {
+		return nil
+	}
+
+	var keys []string
+	var parent string
+	for _, part := range strings.Split(clean(dir), "/") {
+		if part == "" {
+			continue
+		}
+
+		parent = path.Join(parent, part)
+		keys = append(keys, Key(parent))
+	}
+
+	return keys
+}
+
+// Remove returns the keys of the markers to create after removing the last
+// entry of dir, so it keeps existing. With the Implicit policy there are
+// none, the directory disappears.
+func (p Policy) Remove(dir string) []string {
+	if p != Marker || clean(dir) == "" {
+		return nil
+	}
+
+	return []string{Key(dir)}
+}
+
+// Rmdir returns the keys to delete when removing the empty directory dir.
+// The marker is deleted whatever the policy, since it may have been created
+// by other tools.
+func Rmdir(dir string) []string {
+	return []string{Key(dir)}
+}
+
+// Object is an object returned by a listing.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Listing returns the entries of dir, sorted by name, from the objects and
+// the common prefixes returned by a LIST request with delimiter. The marker
+// of dir itself is skipped, and the markers of its subdirectories are
+// merged with their common prefixes. If a file and a directory share a name
+// the directory is kept.
+func Listing(dir string, objects []Object, prefixes []string) []listcache.Entry {
+	base := Key(dir)
+	if base == "/" {
+		base = ""
+	}
+
+	entries := make(map[string]listcache.Entry)
+	for _, p := range prefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(p, base), "/")
+		if name != "" {
+			entries[name] = listcache.Entry{Name: name, Dir: true}
+		}
+	}
+
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, base)
+		if IsMarker(o.Key, o.Size) {
+			if name = strings.TrimSuffix(name, "/"); name != "" {
+				entries[name] = listcache.Entry{Name: name, Dir: true}
+			}
+
+			continue
+		}
+
+		if e, ok := entries[name]; name == "" || (ok && e.Dir) {
+			continue
+		}
+
+		entries[name] = listcache.Entry{Name: name, Size: o.Size, ModTime: o.ModTime}
+	}
+
+	l := make([]listcache.Entry, 0, len(entries))
+	for _, e := range entries {
+		l = append(l, e)
+	}
+
+	sort.Sort(byName(l))
+	return l
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+type byName []listcache.Entry
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -0,0 +1,66 @@
+package dirmarker_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/internal/dirmarker"
+	"srcd.works/go-billy.v1/internal/listcache"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type DirMarkerSuite struct{}
+
+var _ = Suite(&DirMarkerSuite{})
+
+func (s *DirMarkerSuite) TestParsePolicy(c *C) {
+	for _, p := range []dirmarker.Policy{dirmarker.Implicit, dirmarker.Marker} {
+		parsed, err := dirmarker.ParsePolicy(p.String())
+		c.Assert(err, IsNil)
+		c.Assert(parsed, Equals, p)
+	}
+
+	_, err := dirmarker.ParsePolicy("foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *DirMarkerSuite) TestMkdirAll(c *C) {
+	c.Assert(dirmarker.Implicit.MkdirAll("a/b"), HasLen, 0)
+	c.Assert(dirmarker.Marker.MkdirAll("/a/b/"), DeepEquals, []string{"a/", "a/b/"})
+}
+
+func (s *DirMarkerSuite) TestRemove(c *C) {
+	c.Assert(dirmarker.Implicit.Remove("a"), HasLen, 0)
+	c.Assert(dirmarker.Marker.Remove("a"), DeepEquals, []string{"a/"})
+	c.Assert(dirmarker.Marker.Remove(""), HasLen, 0)
+	c.Assert(dirmarker.Rmdir("a/b"), DeepEquals, []string{"a/b/"})
+}
+
+func (s *DirMarkerSuite) TestListing(c *C) {
+	entries := dirmarker.Listing("a", []dirmarker.Object{
+		{Key: "a/"},
+		{Key: "a/foo", Size: 3},
+		{Key: "a/empty/"},
+		{Key: "a/bar"},
+		{Key: "a/qux", Size: 1},
+	}, []string{"a/bar/", "a/qux/"})
+
+	c.Assert(entries, DeepEquals, []listcache.Entry{
+		{Name: "bar", Dir: true},
+		{Name: "empty", Dir: true},
+		{Name: "foo", Size: 3},
+		{Name: "qux", Dir: true},
+	})
+}
+
+func (s *DirMarkerSuite) TestListingRoot(c *C) {
+	entries := dirmarker.Listing("", []dirmarker.Object{
+		{Key: "foo", Size: 3},
+	}, []string{"bar/"})
+
+	c.Assert(entries, DeepEquals, []listcache.Entry{
+		{Name: "bar", Dir: true},
+		{Name: "foo", Size: 3},
+	})
+}
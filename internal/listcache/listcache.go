@@ -0,0 +1,284 @@
+// Package listcache provides a cache of the listings of the object-store
+// backends. Object stores have no directories, they are emulated listing the
+// keys with a delimiter, so every ReadDir and Stat is a LIST request. The
+// listings are kept in a prefix tree, one node per directory, so a walk over
+// a tree reuses them, and a single recursive listing can fill a whole
+// subtree.
+package listcache // import "srcd.works/go-billy.v1/internal/listcache"
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is an entry of a listing, an object or a common prefix.
+type Entry struct {
+	// Name is the base name of the entry.
+	Name string
+	// Size is the size of the object, zero for the directories.
+	Size int64
+	// ModTime is the modification time of the object.
+	ModTime time.Time
+	// Dir is true if the entry is a common prefix, a directory.
+	Dir bool
+}
+
+// Options holds the configuration of a Cache.
+type Options struct {
+	// TTL is the time a listing is considered valid, one minute by default.
+	TTL time.Duration
+}
+
+var defaultOptions = Options{
+	TTL: time.Minute,
+}
+
+// Stats holds the counters of a Cache, to be exposed as metrics.
+type Stats struct {
+	// Hits is the number of lookups served from the cache.
+	Hits uint64
+	// Misses is the number of lookups requiring a listing.
+	Misses uint64
+}
+
+// Cache is a cache of directory listings, the directories are given as slash
+// separated paths relative to the root of the backend, being "" the root
+// itself. It's safe for concurrent use.
+type Cache struct {
+	opts Options
+
+	m     sync.Mutex
+	root  *node
+	stats Stats
+}
+
+type node struct {
+	children map[string]*node
+	// entries is the listing, sorted by name, nil if the directory wasn't
+	// listed.
+	entries []Entry
+	listed  time.Time
+}
+
+// New returns a new Cache, if opts is nil the default options are used, as for
+// their zero fields.
+func New(opts *Options) *Cache {
+	o := defaultOptions
+	if opts != nil {
+		o = *opts
+		if o.TTL <= 0 {
+			o.TTL = defaultOptions.TTL
+		}
+	}
+
+	return &Cache{opts: o, root: &node{}}
+}
+
+// Put stores the listing of dir, as returned by a LIST request with
+// delimiter. The cached subdirectories not present in the listing are
+// dropped.
+func (c *Cache) Put(dir string, entries []Entry) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.put(dir, entries, time.Now())
+}
+
+// PutTree stores the listings of dir and all its subdirectories, as implied
+// by the objects returned by a recursive LIST request, one without delimiter.
+// The names of the objects are the keys relative to dir.
+func (c *Cache) PutTree(dir string, objects []Entry) {
+	listings := map[string]map[string]Entry{clean(dir): {}}
+	for _, o := range objects {
+		parts := strings.Split(clean(o.Name), "/")
+		parent := clean(dir)
+		for _, part := range parts[:len(parts)-1] {
+			if listings[parent] == nil {
+				listings[parent] = make(map[string]Entry)
+			}
+
+			listings[parent][part] = Entry{Name: part, Dir: true}
+			parent = path.Join(parent, part)
+		}
+
+		if listings[parent] == nil {
+			listings[parent] = make(map[string]Entry)
+		}
+
+		o.Name = parts[len(parts)-1]
+		listings[parent][o.Name] = o
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	now := time.Now()
+	for d, l := range listings {
+		entries := make([]Entry, 0, len(l))
+		for _, e := range l {
+			entries = append(entries, e)
+		}
+
+		c.put(d, entries, now)
+	}
+}
+
+func (c *Cache) put(dir string, entries []Entry, now time.Time) {
+	n := c.node(dir, true)
+	n.entries = make([]Entry, len(entries))
+	copy(n.entries, entries)
+	sort.Sort(byName(n.entries))
+	n.listed = now
+
+	for name := range n.children {
+		if e, ok := n.find(name); !ok || !e.Dir {
+			delete(n.children, name)
+		}
+	}
+}
+
+// List returns the cached listing of dir, ok is false if it isn't cached or
+// it has expired.
+func (c *Cache) List(dir string) (entries []Entry, ok bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	n := c.listed(dir)
+	if n == nil {
+		return nil, false
+	}
+
+	entries = make([]Entry, len(n.entries))
+	copy(entries, n.entries)
+	return entries, true
+}
+
+// Lookup returns the entry of name from the cached listing of its directory,
+// ok is false if the listing isn't cached, and exists is false if name is
+// not in it. The root always exists.
+func (c *Cache) Lookup(name string) (e Entry, exists, ok bool) {
+	name = clean(name)
+	if name == "" {
+		return Entry{Dir: true}, true, true
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	n := c.listed(path.Dir(name))
+	if n == nil {
+		return Entry{}, false, false
+	}
+
+	e, exists = n.find(path.Base(name))
+	return e, exists, true
+}
+
+// Invalidate drops the cached listings affected by a change of name: the
+// ones of name and its subdirectories, and the ones of its ancestors, since
+// creating or removing an object may create or remove the directories
+// implied by its key.
+func (c *Cache) Invalidate(name string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	n := c.root
+	for _, part := range split(name) {
+		n.entries = nil
+		child, ok := n.children[part]
+		if !ok {
+			return
+		}
+
+		n = child
+	}
+
+	n.entries = nil
+	n.children = nil
+}
+
+// Purge drops all the cached listings.
+func (c *Cache) Purge() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.root = &node{}
+}
+
+// Stats returns the current counters.
+func (c *Cache) Stats() Stats {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.stats
+}
+
+// listed returns the node of dir if it has a valid listing, counting the hit
+// or the miss.
+func (c *Cache) listed(dir string) *node {
+	n := c.node(dir, false)
+	if n == nil || n.entries == nil || time.Since(n.listed) > c.opts.TTL {
+		c.stats.Misses++
+		return nil
+	}
+
+	c.stats.Hits++
+	return n
+}
+
+// node returns the node of dir, creating the missing ones if create is true.
+func (c *Cache) node(dir string, create bool) *node {
+	n := c.root
+	for _, part := range split(dir) {
+		child, ok := n.children[part]
+		if !ok {
+			if !create {
+				return nil
+			}
+
+			if n.children == nil {
+				n.children = make(map[string]*node)
+			}
+
+			child = &node{}
+			n.children[part] = child
+		}
+
+		n = child
+	}
+
+	return n
+}
+
+func (n *node) find(name string) (Entry, bool) {
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return n.entries[i].Name >= name
+	})
+
+	if i < len(n.entries) && n.entries[i].Name == name {
+		return n.entries[i], true
+	}
+
+	return Entry{}, false
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+func split(p string) []string {
+	if p = clean(p); p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+type byName []Entry
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
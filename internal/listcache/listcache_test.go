@@ -0,0 +1,112 @@
+package listcache_test
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/internal/listcache"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CacheSuite struct{}
+
+var _ = Suite(&CacheSuite{})
+
+func names(entries []listcache.Entry) []string {
+	var l []string
+	for _, e := range entries {
+		l = append(l, e.Name)
+	}
+
+	return l
+}
+
+func (s *CacheSuite) TestPutList(c *C) {
+	cache := listcache.New(nil)
+	_, ok := cache.List("foo")
+	c.Assert(ok, Equals, false)
+
+	cache.Put("foo/", []listcache.Entry{
+		{Name: "qux", Size: 3},
+		{Name: "bar", Dir: true},
+	})
+
+	entries, ok := cache.List("foo")
+	c.Assert(ok, Equals, true)
+	c.Assert(names(entries), DeepEquals, []string{"bar", "qux"})
+
+	c.Assert(cache.Stats(), Equals, listcache.Stats{Hits: 1, Misses: 1})
+}
+
+func (s *CacheSuite) TestPutTree(c *C) {
+	cache := listcache.New(nil)
+	cache.PutTree("foo", []listcache.Entry{
+		{Name: "a/b/c", Size: 1},
+		{Name: "a/d", Size: 2},
+		{Name: "e", Size: 3},
+	})
+
+	entries, ok := cache.List("foo")
+	c.Assert(ok, Equals, true)
+	c.Assert(names(entries), DeepEquals, []string{"a", "e"})
+	c.Assert(entries[0].Dir, Equals, true)
+
+	entries, ok = cache.List("foo/a")
+	c.Assert(ok, Equals, true)
+	c.Assert(names(entries), DeepEquals, []string{"b", "d"})
+
+	e, exists, ok := cache.Lookup("foo/a/b/c")
+	c.Assert(ok, Equals, true)
+	c.Assert(exists, Equals, true)
+	c.Assert(e.Size, Equals, int64(1))
+
+	_, exists, ok = cache.Lookup("foo/a/missing")
+	c.Assert(ok, Equals, true)
+	c.Assert(exists, Equals, false)
+
+	_, _, ok = cache.Lookup("bar/baz")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *CacheSuite) TestPutDropsRemovedDirs(c *C) {
+	cache := listcache.New(nil)
+	cache.PutTree("", []listcache.Entry{{Name: "a/b"}, {Name: "c/d"}})
+	cache.Put("", []listcache.Entry{{Name: "a", Dir: true}})
+
+	_, ok := cache.List("a")
+	c.Assert(ok, Equals, true)
+	_, ok = cache.List("c")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *CacheSuite) TestInvalidate(c *C) {
+	cache := listcache.New(nil)
+	cache.PutTree("", []listcache.Entry{{Name: "a/b/c"}, {Name: "a/d/e"}})
+
+	cache.Invalidate("a/b")
+	for _, dir := range []string{"", "a", "a/b"} {
+		_, ok := cache.List(dir)
+		c.Assert(ok, Equals, false, Commentf(dir))
+	}
+
+	_, ok := cache.List("a/d")
+	c.Assert(ok, Equals, true)
+
+	cache.Purge()
+	_, ok = cache.List("a/d")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *CacheSuite) TestTTL(c *C) {
+	cache := listcache.New(&listcache.Options{TTL: 10 * time.Millisecond})
+	cache.Put("", []listcache.Entry{{Name: "foo"}})
+
+	_, ok := cache.List("")
+	c.Assert(ok, Equals, true)
+
+	time.Sleep(20 * time.Millisecond)
+	_, ok = cache.List("")
+	c.Assert(ok, Equals, false)
+}
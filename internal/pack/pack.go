@@ -0,0 +1,161 @@
+// Package pack provides the format used by the object-store backends to pack
+// many small files into larger segment objects. A segment is the plain
+// concatenation of the contents of its files, and an Index locates every
+// file by segment, offset and size, so a file is read with a single ranged
+// GET instead of one object per file.
+package pack // import "srcd.works/go-billy.v1/internal/pack"
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a file is not in the index.
+var ErrNotFound = errors.New("pack: file not found")
+
+// Entry locates the content of a file in a segment.
+type Entry struct {
+	// Segment is the name of the segment object.
+	Segment string `json:"segment"`
+	// Offset is the position of the content in the segment.
+	Offset int64 `json:"offset"`
+	// Size is the size of the content.
+	Size int64 `json:"size"`
+	// Mode is the mode of the file.
+	Mode os.FileMode `json:"mode"`
+	// ModTime is the modification time of the file.
+	ModTime time.Time `json:"mtime"`
+}
+
+// Writer writes the contents of the files to a segment, returning their
+// entries.
+type Writer struct {
+	w       io.Writer
+	segment string
+	offset  int64
+}
+
+// NewWriter returns a Writer writing the segment with the given name to w.
+func NewWriter(w io.Writer, segment string) *Writer {
+	return &Writer{w: w, segment: segment}
+}
+
+// Add appends the content read from r to the segment, returning its entry.
+func (w *Writer) Add(r io.Reader, modTime time.Time) (Entry, error) {
+	n, err := io.Copy(w.w, r)
+	e := Entry{Segment: w.segment, Offset: w.offset, Size: n, ModTime: modTime}
+	w.offset += n
+	return e, err
+}
+
+// Size returns the number of bytes written to the segment.
+func (w *Writer) Size() int64 {
+	return w.offset
+}
+
+// Open returns a reader of the content of e, from the segment read by r.
+func Open(r io.ReaderAt, e Entry) *io.SectionReader {
+	return io.NewSectionReader(r, e.Offset, e.Size)
+}
+
+// Index maps the names of the files to their entries. It's safe for
+// concurrent use.
+type Index struct {
+	m       sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewIndex returns an empty Index.
+func NewIndex() *Index {
+	return &Index{entries: make(map[string]Entry)}
+}
+
+// ReadIndex decodes an Index written with Encode from r.
+func ReadIndex(r io.Reader) (*Index, error) {
+	idx := NewIndex()
+	if err := json.NewDecoder(r).Decode(&idx.entries); err != nil {
+		return nil, err
+	}
+
+	if idx.entries == nil {
+		idx.entries = make(map[string]Entry)
+	}
+
+	return idx, nil
+}
+
+// Encode writes the index to w, as JSON.
+func (idx *Index) Encode(w io.Writer) error {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	return json.NewEncoder(w).Encode(idx.entries)
+}
+
+// Get returns the entry of name.
+func (idx *Index) Get(name string) (Entry, error) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	e, ok := idx.entries[name]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+
+	return e, nil
+}
+
+// Put sets the entry of name, replacing the previous one.
+func (idx *Index) Put(name string, e Entry) {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	idx.entries[name] = e
+}
+
+// Remove removes name from the index.
+func (idx *Index) Remove(name string) error {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	if _, ok := idx.entries[name]; !ok {
+		return ErrNotFound
+	}
+
+	delete(idx.entries, name)
+	return nil
+}
+
+// Names returns the sorted names of the files in the index.
+func (idx *Index) Names() []string {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	names := make([]string, 0, len(idx.entries))
+	for name := range idx.entries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Live returns the number of bytes of each segment still referenced by the
+// index. Segments with a small ratio of live bytes are candidates to be
+// repacked, and the ones not returned can be deleted.
+func (idx *Index) Live() map[string]int64 {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	live := make(map[string]int64)
+	for _, e := range idx.entries {
+		live[e.Segment] += e.Size
+	}
+
+	return live
+}
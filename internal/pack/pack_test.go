@@ -0,0 +1,73 @@
+package pack_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/internal/pack"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PackSuite struct{}
+
+var _ = Suite(&PackSuite{})
+
+func (s *PackSuite) TestWriteRead(c *C) {
+	buf := bytes.NewBuffer(nil)
+	w := pack.NewWriter(buf, "seg-1")
+	idx := pack.NewIndex()
+
+	mtime := time.Date(2015, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"foo", "qux/bar", "empty"} {
+		content := strings.TrimPrefix(name, "empty")
+		e, err := w.Add(strings.NewReader(content), mtime)
+		c.Assert(err, IsNil)
+		idx.Put(name, e)
+	}
+
+	c.Assert(w.Size(), Equals, int64(10))
+
+	e, err := idx.Get("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(e, DeepEquals, pack.Entry{Segment: "seg-1", Offset: 3, Size: 7, ModTime: mtime})
+
+	content, err := ioutil.ReadAll(pack.Open(bytes.NewReader(buf.Bytes()), e))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "qux/bar")
+
+	_, err = idx.Get("missing")
+	c.Assert(err, Equals, pack.ErrNotFound)
+}
+
+func (s *PackSuite) TestIndexEncode(c *C) {
+	idx := pack.NewIndex()
+	idx.Put("foo", pack.Entry{Segment: "seg-1", Size: 3})
+	idx.Put("bar", pack.Entry{Segment: "seg-2", Offset: 3, Size: 4})
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(idx.Encode(buf), IsNil)
+
+	idx, err := pack.ReadIndex(buf)
+	c.Assert(err, IsNil)
+	c.Assert(idx.Names(), DeepEquals, []string{"bar", "foo"})
+
+	e, err := idx.Get("bar")
+	c.Assert(err, IsNil)
+	c.Assert(e.Offset, Equals, int64(3))
+}
+
+func (s *PackSuite) TestLive(c *C) {
+	idx := pack.NewIndex()
+	idx.Put("foo", pack.Entry{Segment: "seg-1", Size: 3})
+	idx.Put("bar", pack.Entry{Segment: "seg-1", Offset: 3, Size: 4})
+	idx.Put("baz", pack.Entry{Segment: "seg-2", Size: 5})
+
+	c.Assert(idx.Remove("baz"), IsNil)
+	c.Assert(idx.Remove("baz"), Equals, pack.ErrNotFound)
+	c.Assert(idx.Live(), DeepEquals, map[string]int64{"seg-1": 7})
+}
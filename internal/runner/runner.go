@@ -0,0 +1,215 @@
+// Package runner provides the supervision of the background work of the
+// filesystems, such as evicting caches, renewing leases or dispatching
+// watches. The tasks run on a Runner, usually owned by the filesystem and
+// closed by its Close, which stops all of them at once, and their panics are
+// recovered and reported instead of crashing the process.
+package runner // import "srcd.works/go-billy.v1/internal/runner"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when starting a task once the runner is closed.
+var ErrClosed = errors.New("runner: runner closed")
+
+// PanicError is the error reported for a task that panicked.
+type PanicError struct {
+	// Task is the name of the task.
+	Task string
+	// Value is the value recovered from the panic, and Stack the stack of
+	// the goroutine when it panicked.
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("runner: task %s panicked: %v", e.Task, e.Value)
+}
+
+// Options holds the configuration of a Runner.
+type Options struct {
+	// OnError, if not nil, is called with the errors returned by the tasks,
+	// and with a *PanicError for the ones panicking. By default they are
+	// logged with the standard logger.
+	OnError func(task string, err error)
+}
+
+// Runner runs the background tasks of a filesystem. It's safe for
+// concurrent use.
+type Runner struct {
+	opts   Options
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	m      sync.Mutex
+	closed bool
+	// busy is the number of tasks doing work, the periodic ones only while
+	// running, and idle the channels closed once there are none, waited by
+	// Wait.
+	busy int
+	idle []chan struct{}
+}
+
+// New returns a new Runner, if opts is nil the default options are used, as
+// for their zero fields.
+func New(opts *Options) *Runner {
+	r := &Runner{}
+	if opts != nil {
+		r.opts = *opts
+	}
+
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r
+}
+
+// Go runs fn in a new goroutine, with a context canceled when the runner is
+// closed. The name identifies the task in the errors reported.
+func (r *Runner) Go(name string, fn func(ctx context.Context) error) error {
+	if !r.start(true) {
+		return ErrClosed
+	}
+
+	go func() {
+		defer r.wg.Done()
+		defer r.done()
+
+		r.run(name, fn)
+	}()
+
+	return nil
+}
+
+// Every runs fn every interval in a new goroutine, the first time after
+// interval, until the runner is closed. The task keeps running even if fn
+// fails or panics.
+func (r *Runner) Every(name string, interval time.Duration, fn func(ctx context.Context) error) error {
+	if !r.start(false) {
+		return ErrClosed
+	}
+
+	go func() {
+		defer r.wg.Done()
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+			case <-r.ctx.Done():
+				return
+			}
+
+			r.m.Lock()
+			r.busy++
+			r.m.Unlock()
+
+			r.run(name, fn)
+			r.done()
+		}
+	}()
+
+	return nil
+}
+
+// Wait waits for the tasks started with Go and the runs of the periodic ones
+// in progress when called to return, or for ctx to be done, returning its
+// error.
+func (r *Runner) Wait(ctx context.Context) error {
+	r.m.Lock()
+	if r.busy == 0 {
+		r.m.Unlock()
+		return nil
+	}
+
+	ch := make(chan struct{})
+	r.idle = append(r.idle, ch)
+	r.m.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close cancels the context of the tasks, stops the periodic ones and waits
+// for all of them to return. Calling it more than once does nothing.
+func (r *Runner) Close() error {
+	r.m.Lock()
+	r.closed = true
+	r.m.Unlock()
+
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
+
+// start registers a new task, busy if it starts doing work right away,
+// returning false if the runner is closed.
+func (r *Runner) start(busy bool) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.closed {
+		return false
+	}
+
+	r.wg.Add(1)
+	if busy {
+		r.busy++
+	}
+
+	return true
+}
+
+func (r *Runner) done() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.busy--
+	if r.busy != 0 {
+		return
+	}
+
+	for _, ch := range r.idle {
+		close(ch)
+	}
+
+	r.idle = nil
+}
+
+// run calls fn, reporting its error or its panic.
+func (r *Runner) run(name string, fn func(ctx context.Context) error) {
+	defer func() {
+		if v := recover(); v != nil {
+			r.report(name, &PanicError{Task: name, Value: v, Stack: debug.Stack()})
+		}
+	}()
+
+	if err := fn(r.ctx); err != nil {
+		r.report(name, err)
+	}
+}
+
+func (r *Runner) report(name string, err error) {
+	if r.opts.OnError != nil {
+		r.opts.OnError(name, err)
+		return
+	}
+
+	if _, ok := err.(*PanicError); ok {
+		log.Print(err)
+		return
+	}
+
+	log.Printf("runner: task %s: %s", name, err)
+}
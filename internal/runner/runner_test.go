@@ -0,0 +1,119 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/internal/runner"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type RunnerSuite struct{}
+
+var _ = Suite(&RunnerSuite{})
+
+// reported collects the errors reported by a Runner.
+type reported struct {
+	m    sync.Mutex
+	errs map[string][]error
+}
+
+func (r *reported) options() *runner.Options {
+	r.errs = make(map[string][]error)
+	return &runner.Options{OnError: func(task string, err error) {
+		r.m.Lock()
+		defer r.m.Unlock()
+		r.errs[task] = append(r.errs[task], err)
+	}}
+}
+
+func (r *reported) get(task string) []error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.errs[task]
+}
+
+func (s *RunnerSuite) TestGo(c *C) {
+	var rep reported
+	r := runner.New(rep.options())
+
+	release := make(chan struct{})
+	errFailed := errors.New("failed")
+	c.Assert(r.Go("foo", func(ctx context.Context) error {
+		<-release
+		return errFailed
+	}), IsNil)
+	c.Assert(r.Go("bar", func(ctx context.Context) error {
+		panic("bar")
+	}), IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.Assert(r.Wait(ctx), Equals, context.DeadlineExceeded)
+
+	close(release)
+	c.Assert(r.Wait(context.Background()), IsNil)
+	c.Assert(rep.get("foo"), DeepEquals, []error{errFailed})
+
+	errs := rep.get("bar")
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs[0], ErrorMatches, "runner: task bar panicked: bar")
+	c.Assert(errs[0].(*runner.PanicError).Stack, Not(HasLen), 0)
+
+	c.Assert(r.Close(), IsNil)
+	c.Assert(r.Go("qux", func(ctx context.Context) error { return nil }), Equals, runner.ErrClosed)
+}
+
+func (s *RunnerSuite) TestClose(c *C) {
+	r := runner.New(nil)
+
+	stopped := make(chan struct{})
+	c.Assert(r.Go("foo", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	}), IsNil)
+
+	c.Assert(r.Close(), IsNil)
+	select {
+	case <-stopped:
+	default:
+		c.Fatal("Close returned before the task")
+	}
+
+	c.Assert(r.Close(), IsNil)
+}
+
+func (s *RunnerSuite) TestEvery(c *C) {
+	var rep reported
+	r := runner.New(rep.options())
+
+	runs := make(chan int, 10)
+	var n int
+	c.Assert(r.Every("foo", time.Millisecond, func(ctx context.Context) error {
+		n++
+		runs <- n
+		if n == 1 {
+			panic("foo")
+		}
+
+		return nil
+	}), IsNil)
+
+	// the task keeps running after panicking.
+	c.Assert(<-runs, Equals, 1)
+	c.Assert(<-runs, Equals, 2)
+	c.Assert(r.Close(), IsNil)
+	c.Assert(rep.get("foo"), HasLen, 1)
+
+	count := n
+	time.Sleep(5 * time.Millisecond)
+	c.Assert(n, Equals, count)
+	c.Assert(r.Every("foo", time.Millisecond, nil), Equals, runner.ErrClosed)
+	c.Assert(r.Wait(context.Background()), IsNil)
+}
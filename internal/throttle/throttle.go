@@ -0,0 +1,244 @@
+// Package throttle provides an adaptive limiter for the object-store
+// backends: the requests rejected by throttling are retried with a jittered
+// exponential backoff, while the concurrency is reduced, so bulk jobs back
+// off instead of hammering the service until they fail.
+package throttle // import "srcd.works/go-billy.v1/internal/throttle"
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// IsThrottle reports whether err is a throttling response of the service. It
+// recognizes the errors with a StatusCode() int method returning 429 or 503,
+// and the ones with a Code() string method returning one of the codes used
+// by the object stores, such as SlowDown.
+func IsThrottle(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if e, ok := err.(interface {
+		StatusCode() int
+	}); ok {
+		if code := e.StatusCode(); code == 429 || code == 503 {
+			return true
+		}
+	}
+
+	if e, ok := err.(interface {
+		Code() string
+	}); ok {
+		return throttleCodes[e.Code()]
+	}
+
+	return false
+}
+
+// throttleCodes are the error codes of S3, GCS and Azure Blob Storage
+// signaling throttling.
+var throttleCodes = map[string]bool{
+	"SlowDown":              true,
+	"Throttling":            true,
+	"ThrottlingException":   true,
+	"RequestLimitExceeded":  true,
+	"RequestThrottled":      true,
+	"TooManyRequests":       true,
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+	"ServerBusy":            true,
+}
+
+// Options holds the configuration of a Limiter.
+type Options struct {
+	// MaxConcurrency is the maximum number of requests in flight, the limit
+	// is halved on every throttled request and increased by one after
+	// MaxConcurrency successful ones. 16 by default.
+	MaxConcurrency int
+	// Attempts is the number of times a throttled request is tried before
+	// giving up. 8 by default.
+	Attempts int
+	// MinBackoff is the base of the backoff after the first throttled
+	// attempt, doubled after each one up to MaxBackoff. The time waited is
+	// randomized between zero and the backoff. 100ms and 20s by default.
+	MinBackoff, MaxBackoff time.Duration
+	// IsThrottle reports whether an error is a throttling response,
+	// IsThrottle by default.
+	IsThrottle func(error) bool
+}
+
+var defaultOptions = Options{
+	MaxConcurrency: 16,
+	Attempts:       8,
+	MinBackoff:     100 * time.Millisecond,
+	MaxBackoff:     20 * time.Second,
+	IsThrottle:     IsThrottle,
+}
+
+// Stats holds the counters of a Limiter, to be exposed as metrics.
+type Stats struct {
+	// Requests is the number of attempts done.
+	Requests uint64
+	// Throttled is the number of attempts rejected by throttling.
+	Throttled uint64
+	// Failed is the number of requests given up after all the attempts
+	// were throttled.
+	Failed uint64
+	// Limit is the current concurrency limit.
+	Limit int
+}
+
+// Limiter limits the concurrency of the requests to a service, adapting it to
+// the throttling responses. It's safe for concurrent use.
+type Limiter struct {
+	opts Options
+
+	m         sync.Mutex
+	limit     int
+	inFlight  int
+	successes int
+	wake      chan struct{}
+	stats     Stats
+	rand      *rand.Rand
+}
+
+// New returns a new Limiter, if opts is nil the default options are used, as
+// for their zero fields.
+func New(opts *Options) *Limiter {
+	o := defaultOptions
+	if opts != nil {
+		o = *opts
+		if o.MaxConcurrency <= 0 {
+			o.MaxConcurrency = defaultOptions.MaxConcurrency
+		}
+
+		if o.Attempts <= 0 {
+			o.Attempts = defaultOptions.Attempts
+		}
+
+		if o.MinBackoff <= 0 {
+			o.MinBackoff = defaultOptions.MinBackoff
+		}
+
+		if o.MaxBackoff <= 0 {
+			o.MaxBackoff = defaultOptions.MaxBackoff
+		}
+
+		if o.IsThrottle == nil {
+			o.IsThrottle = defaultOptions.IsThrottle
+		}
+	}
+
+	return &Limiter{
+		opts:  o,
+		limit: o.MaxConcurrency,
+		wake:  make(chan struct{}),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Do calls fn once there is room under the concurrency limit, retrying it
+// while it returns a throttling error. The error of the last attempt is
+// returned, or the one of ctx if it's done while waiting.
+func (l *Limiter) Do(ctx context.Context, fn func() error) error {
+	backoff := l.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+		throttled := l.opts.IsThrottle(err)
+		l.release(throttled)
+
+		if !throttled {
+			return err
+		}
+
+		if attempt == l.opts.Attempts {
+			l.m.Lock()
+			l.stats.Failed++
+			l.m.Unlock()
+			return err
+		}
+
+		t := time.NewTimer(l.jitter(backoff))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+
+		if backoff *= 2; backoff > l.opts.MaxBackoff {
+			backoff = l.opts.MaxBackoff
+		}
+	}
+}
+
+// Stats returns the current counters.
+func (l *Limiter) Stats() Stats {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	s := l.stats
+	s.Limit = l.limit
+	return s
+}
+
+func (l *Limiter) acquire(ctx context.Context) error {
+	for {
+		l.m.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.stats.Requests++
+			l.m.Unlock()
+			return nil
+		}
+
+		wake := l.wake
+		l.m.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees the slot of a request, adapting the limit: it's halved if the
+// request was throttled, and increased by one after a window of successful
+// requests.
+func (l *Limiter) release(throttled bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.inFlight--
+	switch {
+	case throttled:
+		l.stats.Throttled++
+		l.successes = 0
+		if l.limit /= 2; l.limit < 1 {
+			l.limit = 1
+		}
+	case l.limit < l.opts.MaxConcurrency:
+		if l.successes++; l.successes >= l.opts.MaxConcurrency {
+			l.successes = 0
+			l.limit++
+		}
+	}
+
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// jitter returns a random duration between zero and d.
+func (l *Limiter) jitter(d time.Duration) time.Duration {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return time.Duration(l.rand.Int63n(int64(d) + 1))
+}
@@ -0,0 +1,107 @@
+package throttle_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/internal/throttle"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ThrottleSuite struct{}
+
+var _ = Suite(&ThrottleSuite{})
+
+type statusError int
+
+func (e statusError) Error() string   { return "status error" }
+func (e statusError) StatusCode() int { return int(e) }
+
+type codeError string
+
+func (e codeError) Error() string { return string(e) }
+func (e codeError) Code() string  { return string(e) }
+
+func (s *ThrottleSuite) TestIsThrottle(c *C) {
+	c.Assert(throttle.IsThrottle(nil), Equals, false)
+	c.Assert(throttle.IsThrottle(errors.New("foo")), Equals, false)
+	c.Assert(throttle.IsThrottle(statusError(429)), Equals, true)
+	c.Assert(throttle.IsThrottle(statusError(503)), Equals, true)
+	c.Assert(throttle.IsThrottle(statusError(404)), Equals, false)
+	c.Assert(throttle.IsThrottle(codeError("SlowDown")), Equals, true)
+	c.Assert(throttle.IsThrottle(codeError("NoSuchKey")), Equals, false)
+}
+
+func (s *ThrottleSuite) TestDoRetries(c *C) {
+	l := throttle.New(&throttle.Options{
+		MaxConcurrency: 8,
+		MinBackoff:     time.Millisecond,
+	})
+
+	var calls int
+	err := l.Do(context.Background(), func() error {
+		if calls++; calls < 3 {
+			return statusError(429)
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 3)
+
+	stats := l.Stats()
+	c.Assert(stats.Requests, Equals, uint64(3))
+	c.Assert(stats.Throttled, Equals, uint64(2))
+	c.Assert(stats.Limit, Equals, 2)
+
+	errOther := errors.New("other")
+	err = l.Do(context.Background(), func() error { return errOther })
+	c.Assert(err, Equals, errOther)
+}
+
+func (s *ThrottleSuite) TestDoGivesUp(c *C) {
+	l := throttle.New(&throttle.Options{
+		Attempts:   2,
+		MinBackoff: time.Millisecond,
+	})
+
+	err := l.Do(context.Background(), func() error { return codeError("SlowDown") })
+	c.Assert(err, Equals, codeError("SlowDown"))
+	c.Assert(l.Stats().Failed, Equals, uint64(1))
+}
+
+func (s *ThrottleSuite) TestConcurrency(c *C) {
+	l := throttle.New(&throttle.Options{MaxConcurrency: 2})
+
+	var inFlight, max int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Do(context.Background(), func() error {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+		}()
+	}
+
+	wg.Wait()
+	c.Assert(atomic.LoadInt32(&max) <= 2, Equals, true)
+}
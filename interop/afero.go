@@ -0,0 +1,299 @@
+// Package interop adapts the billy filesystems to the ones of other
+// libraries and back. NewAfero returns an afero.Fs over a billy.Filesystem and
+// FromAfero a billy.Filesystem over an afero.Fs, so the projects moving from
+// one library to the other can mix the backends of both.
+package interop // import "srcd.works/go-billy.v1/interop"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"srcd.works/go-billy.v1"
+)
+
+var errIsDirectory = errors.New("is a directory")
+
+// Afero is an afero.Fs over a billy.Filesystem, implementing also the
+// afero.Lstater and afero.Symlinker interfaces. The directories can be
+// opened to be listed, with Readdir and Readdirnames. The files implement
+// ReadAt and WriteAt if the ones of the filesystem implement io.ReaderAt and
+// io.WriterAt, otherwise they return billy.ErrNotSupported, as does Chown if
+// the filesystem doesn't implement billy.Chowner.
+type Afero struct {
+	fs billy.Filesystem
+}
+
+// NewAfero returns a new Afero over fs.
+func NewAfero(fs billy.Filesystem) *Afero {
+	return &Afero{fs: fs}
+}
+
+// Name returns the name of the filesystem.
+func (a *Afero) Name() string {
+	return "billy"
+}
+
+// Create creates the named file, truncating it if it already exists.
+func (a *Afero) Create(name string) (afero.File, error) {
+	f, err := a.fs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aferoFile{File: f, name: name}, nil
+}
+
+// Mkdir creates the named directory, failing if it already exists or its
+// parent doesn't.
+func (a *Afero) Mkdir(name string, perm os.FileMode) error {
+	return billy.Mkdir(a.fs, name, perm)
+}
+
+// MkdirAll creates the named directory and all its missing parents.
+func (a *Afero) MkdirAll(path string, perm os.FileMode) error {
+	return a.fs.MkdirAll(path, perm)
+}
+
+// Open opens the named file for reading, the directories are opened to be
+// listed.
+func (a *Afero) Open(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file with the given flag and perm, as
+// os.OpenFile. The directories can only be opened for reading.
+func (a *Afero) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	fi, err := a.fs.Stat(name)
+	if err == nil && fi.IsDir() {
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errIsDirectory}
+		}
+
+		return &aferoDir{fs: a.fs, name: name, fi: fi}, nil
+	}
+
+	f, err := a.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aferoFile{File: f, name: name}, nil
+}
+
+// Remove removes the named file or empty directory.
+func (a *Afero) Remove(name string) error {
+	return a.fs.Remove(name)
+}
+
+// RemoveAll removes path and everything it contains, it does nothing if path
+// doesn't exist.
+func (a *Afero) RemoveAll(path string) error {
+	return billy.RemoveAll(a.fs, path)
+}
+
+// Rename renames oldname to newname, replacing it if it exists.
+func (a *Afero) Rename(oldname, newname string) error {
+	return a.fs.Rename(oldname, newname)
+}
+
+// Stat returns the FileInfo of the named file, following symbolic links.
+func (a *Afero) Stat(name string) (os.FileInfo, error) {
+	return a.fs.Stat(name)
+}
+
+// LstatIfPossible returns the FileInfo of the named file, describing the
+// symbolic links themselves, as afero.Lstater. It's always possible.
+func (a *Afero) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	fi, err := a.fs.Lstat(name)
+	return fi, true, err
+}
+
+// SymlinkIfPossible creates newname as a symbolic link to oldname, as
+// afero.Linker.
+func (a *Afero) SymlinkIfPossible(oldname, newname string) error {
+	return a.fs.Symlink(oldname, newname)
+}
+
+// ReadlinkIfPossible returns the target of the named symbolic link, as
+// afero.LinkReader.
+func (a *Afero) ReadlinkIfPossible(name string) (string, error) {
+	return a.fs.Readlink(name)
+}
+
+// Chmod changes the mode of the named file.
+func (a *Afero) Chmod(name string, mode os.FileMode) error {
+	return a.fs.Chmod(name, mode)
+}
+
+// Chown changes the numeric uid and gid of the named file, if the filesystem
+// implements billy.Chowner.
+func (a *Afero) Chown(name string, uid, gid int) error {
+	c, ok := a.fs.(billy.Chowner)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return c.Chown(name, uid, gid)
+}
+
+// Chtimes changes the access and modification times of the named file.
+func (a *Afero) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return a.fs.Chtimes(name, atime, mtime)
+}
+
+// aferoFile adapts a billy.File to afero.File.
+type aferoFile struct {
+	billy.File
+	name string
+}
+
+func (f *aferoFile) Name() string { return f.name }
+
+func (f *aferoFile) Stat() (os.FileInfo, error) {
+	return f.File.Stat()
+}
+
+func (f *aferoFile) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
+
+func (f *aferoFile) WriteAt(p []byte, off int64) (int, error) {
+	w, ok := f.File.(io.WriterAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return w.WriteAt(p, off)
+}
+
+func (f *aferoFile) WriteString(s string) (int, error) {
+	return f.File.Write([]byte(s))
+}
+
+func (f *aferoFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+func (f *aferoFile) Readdirnames(n int) ([]string, error) {
+	return nil, &os.PathError{Op: "readdirent", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+// aferoDir is a directory open as an afero.File, listed on the first call to
+// Readdir or Readdirnames.
+type aferoDir struct {
+	fs   billy.Filesystem
+	name string
+	fi   billy.FileInfo
+
+	entries []os.FileInfo
+	listed  bool
+	closed  bool
+}
+
+func (d *aferoDir) Name() string { return d.name }
+
+func (d *aferoDir) Stat() (os.FileInfo, error) {
+	return d.fi, nil
+}
+
+// Readdir returns the next count entries of the directory, sorted by name,
+// or all of the remaining ones if count is zero or negative, as
+// os.File.Readdir.
+func (d *aferoDir) Readdir(count int) ([]os.FileInfo, error) {
+	if d.closed {
+		return nil, &os.PathError{Op: "readdir", Path: d.name, Err: billy.ErrClosed}
+	}
+
+	if !d.listed {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries = make([]os.FileInfo, len(entries))
+		for i, e := range entries {
+			d.entries[i] = e
+		}
+
+		d.listed = true
+	}
+
+	if count <= 0 {
+		l := d.entries
+		d.entries = nil
+		return l, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+
+	l := d.entries[:count]
+	d.entries = d.entries[count:]
+	return l, nil
+}
+
+// Readdirnames returns the names of the entries returned by Readdir.
+func (d *aferoDir) Readdirnames(n int) ([]string, error) {
+	entries, err := d.Readdir(n)
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+
+	return names, err
+}
+
+func (d *aferoDir) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *aferoDir) ReadAt(p []byte, off int64) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *aferoDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *aferoDir) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *aferoDir) WriteAt(p []byte, off int64) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *aferoDir) WriteString(s string) (int, error) {
+	return d.Write([]byte(s))
+}
+
+func (d *aferoDir) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *aferoDir) Sync() error {
+	return nil
+}
+
+func (d *aferoDir) Close() error {
+	if d.closed {
+		return &os.PathError{Op: "close", Path: d.name, Err: billy.ErrClosed}
+	}
+
+	d.closed = true
+	return nil
+}
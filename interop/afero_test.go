@@ -0,0 +1,200 @@
+package interop
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AferoSuite struct {
+	fs billy.Filesystem
+	a  *Afero
+}
+
+var _ = Suite(&AferoSuite{})
+
+var _ afero.Fs = &Afero{}
+var _ afero.Symlinker = &Afero{}
+
+func (s *AferoSuite) SetUpTest(c *C) {
+	s.fs = memory.New()
+	s.a = NewAfero(s.fs)
+	c.Assert(afero.WriteFile(s.a, "qux/foo", []byte("foo"), 0644), IsNil)
+	c.Assert(afero.WriteFile(s.a, "qux/bar", []byte("bar"), 0644), IsNil)
+}
+
+func (s *AferoSuite) TestReadWrite(c *C) {
+	data, err := afero.ReadFile(s.a, "qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo")
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar")
+
+	f, err := s.a.OpenFile("qux/foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	c.Assert(f.Name(), Equals, "qux/foo")
+	_, err = f.WriteString("bar")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foobar")
+}
+
+func (s *AferoSuite) TestReaddir(c *C) {
+	l, err := afero.ReadDir(s.a, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+	c.Assert(l[0].Name(), Equals, "bar")
+
+	d, err := s.a.Open("qux")
+	c.Assert(err, IsNil)
+	names, err := d.Readdirnames(1)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"bar"})
+	names, err = d.Readdirnames(1)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"foo"})
+	_, err = d.Readdirnames(1)
+	c.Assert(err, Equals, io.EOF)
+
+	_, err = d.Read(make([]byte, 1))
+	c.Assert(err, NotNil)
+	c.Assert(d.Close(), IsNil)
+
+	_, err = s.a.OpenFile("qux", os.O_RDWR, 0)
+	c.Assert(err, NotNil)
+}
+
+func (s *AferoSuite) TestMkdir(c *C) {
+	c.Assert(s.a.Mkdir("qux/baz", 0755), IsNil)
+	fi, err := s.fs.Stat("qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	err = s.a.Mkdir("qux/baz", 0755)
+	c.Assert(os.IsExist(err), Equals, true)
+	err = s.a.Mkdir("missing/baz", 0755)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *AferoSuite) TestRemoveAll(c *C) {
+	c.Assert(s.a.RemoveAll("qux"), IsNil)
+	_, err := s.fs.Stat("qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(s.a.RemoveAll("qux"), IsNil)
+}
+
+func (s *AferoSuite) TestSymlink(c *C) {
+	c.Assert(s.a.SymlinkIfPossible("foo", "qux/link"), IsNil)
+	target, err := s.a.ReadlinkIfPossible("qux/link")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "foo")
+
+	fi, ok, err := s.a.LstatIfPossible("qux/link")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(fi.Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+}
+
+type FromAferoSuite struct {
+	a  afero.Fs
+	fs *Filesystem
+}
+
+var _ = Suite(&FromAferoSuite{})
+
+var _ billy.Filesystem = &Filesystem{}
+var _ billy.Chowner = &Filesystem{}
+
+func (s *FromAferoSuite) SetUpTest(c *C) {
+	s.a = afero.NewMemMapFs()
+	s.fs = FromAfero(s.a)
+}
+
+func (s *FromAferoSuite) TestCreate(c *C) {
+	writeFile(c, s.fs, "qux/foo", "foo")
+	data, err := afero.ReadFile(s.a, "qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo")
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foo")
+
+	f, err := s.fs.Open("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, "qux/foo")
+	c.Assert(f.Close(), IsNil)
+	c.Assert(f.IsClosed(), Equals, true)
+	c.Assert(f.Close(), Equals, billy.ErrClosed)
+}
+
+func (s *FromAferoSuite) TestReadDir(c *C) {
+	writeFile(c, s.fs, "qux/foo", "foo")
+	writeFile(c, s.fs, "qux/bar", "bar")
+
+	l, err := s.fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+	c.Assert(l[0].Name(), Equals, "bar")
+	c.Assert(l[1].Name(), Equals, "foo")
+}
+
+func (s *FromAferoSuite) TestRename(c *C) {
+	writeFile(c, s.fs, "foo", "foo")
+	c.Assert(s.fs.Rename("foo", "qux/bar"), IsNil)
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "foo")
+	_, err := s.fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FromAferoSuite) TestTempFile(c *C) {
+	f, err := s.fs.TempFile("tmp", "foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(readFile(c, s.fs, f.Filename()), Equals, "foo")
+}
+
+func (s *FromAferoSuite) TestDir(c *C) {
+	writeFile(c, s.fs, "qux/baz/foo", "foo")
+	dir := s.fs.Dir("qux")
+	c.Assert(dir.Base(), Equals, "/qux")
+	c.Assert(readFile(c, dir, "baz/foo"), Equals, "foo")
+	c.Assert(dir.Dir("baz").Base(), Equals, "/qux/baz")
+	c.Assert(readFile(c, dir.Dir("baz"), "foo"), Equals, "foo")
+}
+
+func (s *FromAferoSuite) TestSymlinkNotSupported(c *C) {
+	c.Assert(s.fs.Symlink("foo", "bar"), Equals, billy.ErrNotSupported)
+	_, err := s.fs.Readlink("bar")
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+
+func (s *FromAferoSuite) TestLock(c *C) {
+	f, err := s.fs.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+	c.Assert(f.Lock(), Equals, billy.ErrNotSupported)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return string(data)
+}
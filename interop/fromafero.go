@@ -0,0 +1,280 @@
+package interop
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"srcd.works/go-billy.v1"
+)
+
+// Filesystem is a billy.Filesystem over an afero.Fs. The symbolic links are
+// supported if the afero.Fs implements afero.Lstater, afero.Linker and
+// afero.LinkReader, otherwise Lstat is Stat and Symlink and Readlink return
+// billy.ErrNotSupported. The files implement io.ReaderAt and io.WriterAt, as
+// the ones of afero do, but have no locks.
+type Filesystem struct {
+	fs   afero.Fs
+	base string
+}
+
+// FromAfero returns a new Filesystem over fs.
+func FromAfero(fs afero.Fs) *Filesystem {
+	return &Filesystem{fs: fs, base: string(filepath.Separator)}
+}
+
+// Create creates the named file, truncating it if it already exists, with
+// the parent directories.
+func (fs *Filesystem) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *Filesystem) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile is equivalent to standard os.OpenFile. If flag os.O_CREATE is set,
+// all parent directories will be created.
+func (fs *Filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		if err := fs.createDir(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(filename, f), nil
+}
+
+func (fs *Filesystem) createDir(filename string) error {
+	dir := filepath.Dir(filename)
+	if dir != "." {
+		if err := fs.fs.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stat returns the FileInfo of the named file, following symbolic links.
+func (fs *Filesystem) Stat(filename string) (billy.FileInfo, error) {
+	return fs.fs.Stat(filename)
+}
+
+// Lstat returns the FileInfo of the named file, if it's a symbolic link the
+// link itself is described, if supported by the afero.Fs.
+func (fs *Filesystem) Lstat(filename string) (billy.FileInfo, error) {
+	l, ok := fs.fs.(afero.Lstater)
+	if !ok {
+		return fs.fs.Stat(filename)
+	}
+
+	fi, _, err := l.LstatIfPossible(filename)
+	return fi, err
+}
+
+// ReadDir returns the FileInfo of the files in the given directory, sorted
+// by name.
+func (fs *Filesystem) ReadDir(path string) ([]billy.FileInfo, error) {
+	l, err := afero.ReadDir(fs.fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := make([]billy.FileInfo, len(l))
+	for i, fi := range l {
+		s[i] = fi
+	}
+
+	return s, nil
+}
+
+// TempFile creates a new temporal file, with a random name starting with
+// prefix, in dir.
+func (fs *Filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.fs.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := afero.TempFile(fs.fs, dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFile(f.Name(), f), nil
+}
+
+// Rename moves a file from _from_ to _to_, creating the parent directories of
+// to.
+func (fs *Filesystem) Rename(from, to string) error {
+	if err := fs.createDir(to); err != nil {
+		return err
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+// Remove deletes a file or an empty directory.
+func (fs *Filesystem) Remove(filename string) error {
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates link as a symbolic link to target, creating the parent
+// directories of link, if the afero.Fs implements afero.Linker.
+func (fs *Filesystem) Symlink(target, link string) error {
+	l, ok := fs.fs.(afero.Linker)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	if err := fs.createDir(link); err != nil {
+		return err
+	}
+
+	return l.SymlinkIfPossible(target, link)
+}
+
+// Readlink returns the target of the named symbolic link, if the afero.Fs
+// implements afero.LinkReader.
+func (fs *Filesystem) Readlink(link string) (string, error) {
+	l, ok := fs.fs.(afero.LinkReader)
+	if !ok {
+		return "", billy.ErrNotSupported
+	}
+
+	return l.ReadlinkIfPossible(link)
+}
+
+// MkdirAll creates the directory path and all its missing parents.
+func (fs *Filesystem) MkdirAll(path string, perm os.FileMode) error {
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of the named file.
+func (fs *Filesystem) Chmod(name string, mode os.FileMode) error {
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of the named file.
+func (fs *Filesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Chown changes the numeric uid and gid of the named file.
+func (fs *Filesystem) Chown(name string, uid, gid int) error {
+	return fs.fs.Chown(name, uid, gid)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Filesystem) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+// Dir returns a new Filesystem rooted at the given path, through an
+// afero.BasePathFs, so the ".." elements can't go above it.
+func (fs *Filesystem) Dir(path string) billy.Filesystem {
+	sep := string(filepath.Separator)
+	dir := strings.TrimPrefix(filepath.Join(sep, path), sep)
+	if dir == "" {
+		return fs
+	}
+
+	return &Filesystem{
+		fs:   afero.NewBasePathFs(fs.fs, dir),
+		base: filepath.Join(fs.base, dir),
+	}
+}
+
+// Base returns the path of the root of the filesystem in the afero.Fs.
+func (fs *Filesystem) Base() string {
+	return fs.base
+}
+
+// file adapts an afero.File to billy.File.
+type file struct {
+	billy.BaseFile
+	f afero.File
+}
+
+func newFile(filename string, f afero.File) *file {
+	return &file{BaseFile: billy.BaseFile{BaseFilename: filename}, f: f}
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.f.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.f.ReadAt(p, off)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.f.Write(p)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.f.WriteAt(p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.f.Seek(offset, whence)
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	return f.f.Stat()
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.f.Truncate(size)
+}
+
+func (f *file) Sync() error {
+	return f.f.Sync()
+}
+
+// Lock returns billy.ErrNotSupported, afero has no locks.
+func (f *file) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, afero has no locks.
+func (f *file) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+func (f *file) Close() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	f.Closed = true
+	return f.f.Close()
+}
@@ -0,0 +1,339 @@
+package billy
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// InventoryFormat is the encoding used by Inventory.
+type InventoryFormat int
+
+const (
+	// InventoryJSON writes one JSON object per line.
+	InventoryJSON InventoryFormat = iota
+	// InventoryCSV writes a CSV file with a header.
+	InventoryCSV
+)
+
+// InventoryEntry is a record of the report generated by Inventory.
+type InventoryEntry struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	// SHA256 is the hex encoded hash of the content of the regular files.
+	SHA256 string `json:"sha256,omitempty"`
+	// Target is the destination of the symbolic links.
+	Target string `json:"target,omitempty"`
+}
+
+var inventoryHeader = []string{"path", "type", "size", "mode", "mtime", "sha256", "target"}
+
+// Inventory walks the tree rooted at root and writes to w a record for every
+// file and directory found, in the given format. The records are written as
+// the tree is walked, so it's suitable for very large trees.
+func Inventory(fs Filesystem, root string, w io.Writer, format InventoryFormat) error {
+	enc, err := newInventoryEncoder(w, format)
+	if err != nil {
+		return err
+	}
+
+	err = Walk(fs, root, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		e, err := inventoryEntry(fs, path, info)
+		if err != nil {
+			return err
+		}
+
+		return enc(e)
+	})
+	if err != nil {
+		return err
+	}
+
+	return enc(nil)
+}
+
+// newInventoryEncoder returns a function writing the given entry, it must be
+// called with nil at the end to flush any buffered data.
+func newInventoryEncoder(w io.Writer, format InventoryFormat) (func(*InventoryEntry) error, error) {
+	switch format {
+	case InventoryJSON:
+		enc := json.NewEncoder(w)
+		return func(e *InventoryEntry) error {
+			if e == nil {
+				return nil
+			}
+
+			return enc.Encode(e)
+		}, nil
+	case InventoryCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(inventoryHeader); err != nil {
+			return nil, err
+		}
+
+		return func(e *InventoryEntry) error {
+			if e == nil {
+				cw.Flush()
+				return cw.Error()
+			}
+
+			return cw.Write([]string{
+				e.Path, e.Type, strconv.FormatInt(e.Size, 10), e.Mode,
+				e.ModTime.UTC().Format(time.RFC3339Nano), e.SHA256, e.Target,
+			})
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown inventory format %d", format)
+	}
+}
+
+// ImportInventory recreates in fs the tree described by the records read
+// from r, as written by Inventory in the given format: the directories, the
+// symbolic links and the regular files, empty, with their permissions and
+// modification times if the backend supports them. The other types of files
+// are skipped. The files already present are kept, with their permissions and
+// modification times replaced, so an interrupted import can be repeated.
+func ImportInventory(fs Filesystem, r io.Reader, format InventoryFormat) error {
+	dec, err := newInventoryDecoder(r, format)
+	if err != nil {
+		return err
+	}
+
+	var dirs []*InventoryEntry
+	for {
+		e, err := dec()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// the root of fs, when the whole tree was inventoried, already exists.
+		if e.Path == "" {
+			continue
+		}
+
+		if err := importInventoryEntry(fs, e); err != nil {
+			return err
+		}
+
+		if e.Type == "dir" {
+			dirs = append(dirs, e)
+		}
+	}
+
+	// the times of the directories are set once their entries are created,
+	// the deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := importTimes(fs, dirs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newInventoryDecoder returns a function reading the next entry, it returns
+// io.EOF once all were read.
+func newInventoryDecoder(r io.Reader, format InventoryFormat) (func() (*InventoryEntry, error), error) {
+	switch format {
+	case InventoryJSON:
+		dec := json.NewDecoder(r)
+		return func() (*InventoryEntry, error) {
+			e := &InventoryEntry{}
+			if err := dec.Decode(e); err != nil {
+				return nil, err
+			}
+
+			return e, nil
+		}, nil
+	case InventoryCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(inventoryHeader)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		for i, name := range inventoryHeader {
+			if header[i] != name {
+				return nil, fmt.Errorf("invalid inventory header %q", header)
+			}
+		}
+
+		return func() (*InventoryEntry, error) {
+			record, err := cr.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			size, err := strconv.ParseInt(record[2], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			mtime, err := time.Parse(time.RFC3339Nano, record[4])
+			if err != nil {
+				return nil, err
+			}
+
+			return &InventoryEntry{
+				Path: record[0], Type: record[1], Size: size, Mode: record[3],
+				ModTime: mtime, SHA256: record[5], Target: record[6],
+			}, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown inventory format %d", format)
+	}
+}
+
+// importInventoryEntry creates the file of e, the times of the directories
+// are left to the caller.
+func importInventoryEntry(fs Filesystem, e *InventoryEntry) error {
+	perm, err := strconv.ParseUint(e.Mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode %q of %s", e.Mode, e.Path)
+	}
+
+	mode := os.FileMode(perm).Perm()
+	switch e.Type {
+	case "dir":
+		if err := fs.MkdirAll(e.Path, mode); err != nil {
+			return err
+		}
+
+		return importMode(fs, e.Path, mode)
+	case "file":
+		if err := importParent(fs, e.Path); err != nil {
+			return err
+		}
+
+		f, err := fs.OpenFile(e.Path, os.O_WRONLY|os.O_CREATE, mode)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		if err := importMode(fs, e.Path, mode); err != nil {
+			return err
+		}
+
+		return importTimes(fs, e)
+	case "symlink":
+		if err := importParent(fs, e.Path); err != nil {
+			return err
+		}
+
+		if _, err := fs.Lstat(e.Path); err == nil || !os.IsNotExist(err) {
+			return err
+		}
+
+		return fs.Symlink(e.Target, e.Path)
+	default:
+		return nil
+	}
+}
+
+func importParent(fs Filesystem, path string) error {
+	dir := filepath.Dir(path)
+	if dir == "." {
+		return nil
+	}
+
+	return fs.MkdirAll(dir, 0755)
+}
+
+func importMode(fs Filesystem, path string, mode os.FileMode) error {
+	err := fs.Chmod(path, mode)
+	if err == ErrNotSupported {
+		return nil
+	}
+
+	return err
+}
+
+func importTimes(fs Filesystem, e *InventoryEntry) error {
+	err := fs.Chtimes(e.Path, e.ModTime, e.ModTime)
+	if err == ErrNotSupported {
+		return nil
+	}
+
+	return err
+}
+
+func inventoryEntry(fs Filesystem, path string, info FileInfo) (*InventoryEntry, error) {
+	e := &InventoryEntry{
+		Path:    path,
+		Type:    fileType(info.Mode()),
+		Size:    info.Size(),
+		Mode:    fmt.Sprintf("%04o", info.Mode().Perm()),
+		ModTime: info.ModTime(),
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := fs.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Target = target
+	case info.Mode().IsRegular():
+		f, err := fs.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+
+		e.SHA256 = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return e, nil
+}
+
+// fileType returns a name for the type of the file described by mode.
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "dir"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char-device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	case mode.IsRegular():
+		return "file"
+	default:
+		return "irregular"
+	}
+}
@@ -0,0 +1,112 @@
+package billy_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type InventorySuite struct{}
+
+var _ = Suite(&InventorySuite{})
+
+func (s *InventorySuite) TestInventoryJSON(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo", "foo")
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(billy.Inventory(fs, "", buf, billy.InventoryJSON), IsNil)
+
+	var entries []billy.InventoryEntry
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var e billy.InventoryEntry
+		c.Assert(dec.Decode(&e), IsNil)
+		entries = append(entries, e)
+	}
+
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[1].Path, Equals, "qux")
+	c.Assert(entries[1].Type, Equals, "dir")
+	c.Assert(entries[2].Path, Equals, "qux/foo")
+	c.Assert(entries[2].Type, Equals, "file")
+	c.Assert(entries[2].Size, Equals, int64(3))
+	c.Assert(entries[2].SHA256, Equals,
+		"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae")
+}
+
+func (s *InventorySuite) TestInventoryCSV(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo")
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(billy.Inventory(fs, "", buf, billy.InventoryCSV), IsNil)
+
+	records, err := csv.NewReader(buf).ReadAll()
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Assert(records[0][0], Equals, "path")
+	c.Assert(records[2][0], Equals, "foo")
+	c.Assert(records[2][1], Equals, "file")
+	c.Assert(records[2][2], Equals, "3")
+}
+
+func (s *InventorySuite) TestImportInventory(c *C) {
+	mtime := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	src := memory.New()
+	writeFile(c, src, "qux/foo", "foo")
+	writeFile(c, src, "qux/baz/bar", "bar")
+	c.Assert(src.Symlink("foo", "qux/link"), IsNil)
+	c.Assert(src.Chmod("qux/foo", 0600), IsNil)
+	c.Assert(src.Chmod("qux/baz", 0700), IsNil)
+	for _, path := range []string{"qux/foo", "qux/baz/bar", "qux/baz", "qux"} {
+		c.Assert(src.Chtimes(path, mtime, mtime), IsNil)
+	}
+
+	for i, format := range []billy.InventoryFormat{billy.InventoryJSON, billy.InventoryCSV} {
+		buf := bytes.NewBuffer(nil)
+		c.Assert(billy.Inventory(src, []string{"", "qux"}[i], buf, format), IsNil)
+
+		fs := memory.New()
+		data := buf.String()
+		c.Assert(billy.ImportInventory(fs, strings.NewReader(data), format), IsNil)
+		c.Assert(billy.ImportInventory(fs, strings.NewReader(data), format), IsNil)
+
+		fi, err := fs.Stat("qux/foo")
+		c.Assert(err, IsNil)
+		c.Assert(fi.Size(), Equals, int64(0))
+		c.Assert(fi.Mode(), Equals, os.FileMode(0600))
+		c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+		fi, err = fs.Stat("qux/baz")
+		c.Assert(err, IsNil)
+		c.Assert(fi.Mode(), Equals, os.ModeDir|0700)
+		c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+		_, err = fs.Stat("qux/baz/bar")
+		c.Assert(err, IsNil)
+
+		target, err := fs.Readlink("qux/link")
+		c.Assert(err, IsNil)
+		c.Assert(target, Equals, "foo")
+	}
+}
+
+func (s *InventorySuite) TestImportInventoryErrors(c *C) {
+	fs := memory.New()
+	err := billy.ImportInventory(fs, strings.NewReader("foo,bar\n"), billy.InventoryCSV)
+	c.Assert(err, NotNil)
+
+	err = billy.ImportInventory(fs, strings.NewReader(`{"path":"foo","type":"file","mode":"x"}`), billy.InventoryJSON)
+	c.Assert(err, ErrorMatches, `invalid mode "x" of foo`)
+
+	err = billy.ImportInventory(fs, strings.NewReader(""), billy.InventoryFormat(42))
+	c.Assert(err, ErrorMatches, "unknown inventory format 42")
+}
@@ -0,0 +1,279 @@
+//go:build go1.16
+// +build go1.16
+
+package iofs
+
+import (
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+var errIsDirectory = errors.New("is a directory")
+
+// Filesystem is a read-only filesystem over an io/fs.FS, any operation writing to it
+// returns billy.ErrReadOnly. The io/fs.FS has no symbolic links, so Lstat
+// is Stat and Readlink returns billy.ErrNotSupported. The files are seekable
+// and implement io.ReaderAt if the ones of the io/fs.FS do, as the ones of an
+// embed.FS.
+type Filesystem struct {
+	fsys iofs.FS
+	// base is the name of the root of the filesystem in fsys.
+	base string
+}
+
+// FromFS returns a new Filesystem over fsys.
+func FromFS(fsys iofs.FS) *Filesystem {
+	return &Filesystem{fsys: fsys, base: "."}
+}
+
+// Create returns billy.ErrReadOnly.
+func (fs *Filesystem) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// Open opens the named file for reading.
+func (fs *Filesystem) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file for reading, returns billy.ErrReadOnly if
+// flag requests any kind of write access.
+func (fs *Filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if isWrite(flag) {
+		return nil, billy.ErrReadOnly
+	}
+
+	f, err := fs.fsys.Open(fs.name(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		f.Close()
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDirectory}
+	}
+
+	return &file{BaseFile: billy.BaseFile{BaseFilename: filename}, f: f, fi: fi}, nil
+}
+
+// Stat returns the FileInfo of the named file.
+func (fs *Filesystem) Stat(filename string) (billy.FileInfo, error) {
+	fi, err := iofs.Stat(fs.fsys, fs.name(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	return fi, nil
+}
+
+// Lstat returns the FileInfo of the named file, as Stat.
+func (fs *Filesystem) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.Stat(filename)
+}
+
+// ReadDir returns the FileInfo of the files in the given directory, sorted
+// by name.
+func (fs *Filesystem) ReadDir(dir string) ([]billy.FileInfo, error) {
+	entries, err := iofs.ReadDir(fs.fsys, fs.name(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]billy.FileInfo, len(entries))
+	for i, e := range entries {
+		if l[i], err = e.Info(); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// ReadDirEntries returns the entries of the given directory sorted by name,
+// without calling Stat for each one of them.
+func (fs *Filesystem) ReadDirEntries(dir string) ([]billy.DirEntry, error) {
+	entries, err := iofs.ReadDir(fs.fsys, fs.name(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]billy.DirEntry, len(entries))
+	for i, e := range entries {
+		l[i] = &dirEntry{e}
+	}
+
+	return l, nil
+}
+
+// TempFile returns billy.ErrReadOnly.
+func (fs *Filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// Rename returns billy.ErrReadOnly.
+func (fs *Filesystem) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+// Remove returns billy.ErrReadOnly.
+func (fs *Filesystem) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+// Symlink returns billy.ErrReadOnly.
+func (fs *Filesystem) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+// Readlink returns billy.ErrNotSupported, io/fs.FS has no symbolic links.
+func (fs *Filesystem) Readlink(link string) (string, error) {
+	return "", billy.ErrNotSupported
+}
+
+// MkdirAll returns billy.ErrReadOnly.
+func (fs *Filesystem) MkdirAll(path string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Chmod returns billy.ErrReadOnly.
+func (fs *Filesystem) Chmod(name string, mode os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Chtimes returns billy.ErrReadOnly.
+func (fs *Filesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return billy.ErrReadOnly
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Filesystem) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Dir returns a new Filesystem rooted at the given path. The path is
+// rooted at the base of fs, so the ".." elements can't go above it.
+func (fs *Filesystem) Dir(p string) billy.Filesystem {
+	return &Filesystem{fsys: fs.fsys, base: fs.name(p)}
+}
+
+// Base returns the path of the root of the filesystem in the io/fs.FS.
+func (fs *Filesystem) Base() string {
+	return path.Join("/", fs.base)
+}
+
+// name returns the name in fsys of the given filename, as required by
+// io/fs: slash separated, unrooted and without ".." elements.
+func (fs *Filesystem) name(filename string) string {
+	rel := strings.Trim(path.Clean("/"+strings.Replace(filename, `\`, "/", -1)), "/")
+	if rel == "" {
+		return fs.base
+	}
+
+	return path.Join(fs.base, rel)
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// dirEntry adapts an io/fs.DirEntry to billy.DirEntry.
+type dirEntry struct {
+	iofs.DirEntry
+}
+
+func (e *dirEntry) Info() (billy.FileInfo, error) {
+	return e.DirEntry.Info()
+}
+
+// file is a file of the io/fs.FS open for reading.
+type file struct {
+	billy.BaseFile
+	f  iofs.File
+	fi iofs.FileInfo
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.f.Read(p)
+}
+
+// ReadAt reads from the given offset, if the underlying file implements
+// io.ReaderAt, otherwise it returns billy.ErrNotSupported.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	r, ok := f.f.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
+
+// Seek sets the offset for the next Read, if the underlying file implements
+// io.Seeker, otherwise it returns billy.ErrNotSupported.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	s, ok := f.f.(io.Seeker)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return s.Seek(offset, whence)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, billy.ErrReadOnly
+}
+
+func (f *file) Truncate(size int64) error {
+	return billy.ErrReadOnly
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	return f.fi, nil
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+// Lock returns billy.ErrNotSupported, io/fs.FS has no locks.
+func (f *file) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, io/fs.FS has no locks.
+func (f *file) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+func (f *file) Close() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	f.Closed = true
+	return f.f.Close()
+}
@@ -0,0 +1,150 @@
+//go:build go1.16
+// +build go1.16
+
+package iofs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	fs *Filesystem
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+var mtime = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.fs = FromFS(fstest.MapFS{
+		"qux/foo":     {Data: []byte("foo"), Mode: 0600, ModTime: mtime},
+		"qux/baz/bar": {Data: []byte("bar")},
+		"empty":       {Mode: os.ModeDir | 0700},
+	})
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(data)
+}
+
+func readDirNames(c *C, fs billy.Filesystem, dir string) []string {
+	infos, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
+
+func (s *FilesystemSuite) TestReadDir(c *C) {
+	c.Assert(readDirNames(c, s.fs, ""), DeepEquals, []string{"empty", "qux"})
+	c.Assert(readDirNames(c, s.fs, "/qux/"), DeepEquals, []string{"baz", "foo"})
+
+	entries, err := billy.ReadDirEntries(s.fs, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].IsDir(), Equals, true)
+	fi, err := entries[1].Info()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	_, err = s.fs.ReadDir("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestStat(c *C) {
+	fi, err := s.fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(fi.Mode(), Equals, os.FileMode(0600))
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+	fi, err = s.fs.Lstat("empty")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	_, err = s.fs.Stat("../qux/missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestRead(c *C) {
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foo")
+
+	f, err := s.fs.Open("qux/baz/bar")
+	c.Assert(err, IsNil)
+	pos, err := f.Seek(1, io.SeekStart)
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(1))
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "ar")
+
+	buf := make([]byte, 2)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 0)
+	c.Assert(err, IsNil)
+	c.Assert(string(buf[:n]), Equals, "ba")
+
+	fi, err := f.Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "bar")
+	c.Assert(f.Close(), IsNil)
+	c.Assert(f.Close(), Equals, billy.ErrClosed)
+
+	_, err = s.fs.Open("qux")
+	c.Assert(err, NotNil)
+}
+
+func (s *FilesystemSuite) TestReadOnly(c *C) {
+	_, err := s.fs.Create("foo")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	_, err = s.fs.OpenFile("qux/foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	_, err = s.fs.TempFile("", "foo")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Rename("qux/foo", "foo"), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Remove("qux/foo"), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.MkdirAll("foo", 0755), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Symlink("qux", "foo"), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Chmod("qux/foo", 0644), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Chtimes("qux/foo", mtime, mtime), Equals, billy.ErrReadOnly)
+	_, err = s.fs.Readlink("qux/foo")
+	c.Assert(err, Equals, billy.ErrNotSupported)
+
+	f, err := s.fs.Open("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, Equals, billy.ErrReadOnly)
+}
+
+func (s *FilesystemSuite) TestDir(c *C) {
+	fs := s.fs.Dir("qux")
+	c.Assert(fs.Base(), Equals, "/qux")
+	c.Assert(readDirNames(c, fs, ".."), DeepEquals, []string{"baz", "foo"})
+	c.Assert(readFile(c, fs.Dir("baz"), "bar"), Equals, "bar")
+}
+
+func (s *FilesystemSuite) TestCopyTree(c *C) {
+	dst := memory.New()
+	c.Assert(billy.CopyTree(dst, s.fs.Dir("qux"), nil), IsNil)
+	c.Assert(readFile(c, dst, "foo"), Equals, "foo")
+	c.Assert(readFile(c, dst, "baz/bar"), Equals, "bar")
+}
@@ -0,0 +1,234 @@
+//go:build go1.16
+// +build go1.16
+
+// Package iofs adapts the billy filesystems to io/fs and back. New returns an
+// io/fs.FS over a billy.Filesystem, so the backends can be given to
+// html/template.ParseFS, http.FS or fstest.TestFS, and FromFS returns a
+// read-only billy.Filesystem over an io/fs.FS, such as the embed.FS of the
+// files embedded with go:embed.
+package iofs // import "srcd.works/go-billy.v1/iofs"
+
+import (
+	"io"
+	iofs "io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"srcd.works/go-billy.v1"
+)
+
+// FS is an io/fs.FS over a billy.Filesystem, implementing also
+// io/fs.ReadDirFS, io/fs.StatFS and io/fs.SubFS. The symbolic links are
+// followed, as done by os.DirFS. The files are seekable and implement
+// io.ReaderAt if the ones of the filesystem do.
+type FS struct {
+	fs billy.Filesystem
+}
+
+// New returns a new FS over fs.
+func New(fs billy.Filesystem) *FS {
+	return &FS{fs: fs}
+}
+
+// Open opens the named file, the directories are opened as
+// io/fs.ReadDirFile.
+func (fsys *FS) Open(name string) (iofs.File, error) {
+	filename, err := fsys.filename("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fsys.fs.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return &dir{fsys: fsys, name: name, filename: filename, fi: fi}, nil
+	}
+
+	f, err := fsys.fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ioFile{File: f, name: name}, nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (fsys *FS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	filename, err := fsys.filename("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fsys.readDir(filename)
+}
+
+func (fsys *FS) readDir(filename string) ([]iofs.DirEntry, error) {
+	entries, err := billy.ReadDirEntries(fsys.fs, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]iofs.DirEntry, len(entries))
+	for i, e := range entries {
+		l[i] = &ioDirEntry{e}
+	}
+
+	sort.Slice(l, func(i, j int) bool { return l[i].Name() < l[j].Name() })
+	return l, nil
+}
+
+// Stat returns the FileInfo of the named file.
+func (fsys *FS) Stat(name string) (iofs.FileInfo, error) {
+	filename, err := fsys.filename("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fsys.fs.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{FileInfo: fi, name: path.Base(name)}, nil
+}
+
+// Sub returns an FS rooted at the named directory, using Dir of the
+// filesystem.
+func (fsys *FS) Sub(dir string) (iofs.FS, error) {
+	filename, err := fsys.filename("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "." {
+		return fsys, nil
+	}
+
+	return New(fsys.fs.Dir(filename)), nil
+}
+
+// filename returns the filename in the filesystem of the given io/fs name,
+// the invalid names return an *io/fs.PathError for op.
+func (fsys *FS) filename(op, name string) (string, error) {
+	if !iofs.ValidPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+
+	if name == "." {
+		return "", nil
+	}
+
+	return filepath.FromSlash(name), nil
+}
+
+// fileInfo is the FileInfo of a file, with its name in the FS.
+type fileInfo struct {
+	billy.FileInfo
+	name string
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+
+// ioDirEntry adapts a billy.DirEntry to io/fs.DirEntry.
+type ioDirEntry struct {
+	billy.DirEntry
+}
+
+func (e *ioDirEntry) Info() (iofs.FileInfo, error) {
+	return e.DirEntry.Info()
+}
+
+// ioFile adapts a billy.File to io/fs.File.
+type ioFile struct {
+	billy.File
+	name string
+}
+
+func (f *ioFile) Stat() (iofs.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{FileInfo: fi, name: path.Base(f.name)}, nil
+}
+
+// ReadAt reads from the given offset, if the underlying file implements
+// io.ReaderAt, otherwise it returns billy.ErrNotSupported.
+func (f *ioFile) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
+
+// dir is a directory open as an io/fs.ReadDirFile, listed on the first call
+// to ReadDir.
+type dir struct {
+	fsys     *FS
+	name     string
+	filename string
+	fi       billy.FileInfo
+
+	entries []iofs.DirEntry
+	listed  bool
+	closed  bool
+}
+
+func (d *dir) Stat() (iofs.FileInfo, error) {
+	return &fileInfo{FileInfo: d.fi, name: path.Base(d.name)}, nil
+}
+
+func (d *dir) Read(p []byte) (int, error) {
+	return 0, &iofs.PathError{Op: "read", Path: d.name, Err: errIsDirectory}
+}
+
+// ReadDir returns the next n entries of the directory, or all of the
+// remaining ones if n is zero or negative, as io/fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if d.closed {
+		return nil, &iofs.PathError{Op: "readdir", Path: d.name, Err: iofs.ErrClosed}
+	}
+
+	if !d.listed {
+		entries, err := d.fsys.readDir(d.filename)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries, d.listed = entries, true
+	}
+
+	if n <= 0 {
+		l := d.entries
+		d.entries = nil
+		return l, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	l := d.entries[:n]
+	d.entries = d.entries[n:]
+	return l, nil
+}
+
+func (d *dir) Close() error {
+	if d.closed {
+		return &iofs.PathError{Op: "close", Path: d.name, Err: iofs.ErrClosed}
+	}
+
+	d.closed = true
+	return nil
+}
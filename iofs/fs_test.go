@@ -0,0 +1,116 @@
+//go:build go1.16
+// +build go1.16
+
+package iofs
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing/fstest"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type FSSuite struct {
+	fsys *FS
+}
+
+var _ = Suite(&FSSuite{})
+
+func (s *FSSuite) SetUpTest(c *C) {
+	m := memory.New()
+	writeFile(c, m, "qux/foo.html", "foo {{.}}")
+	writeFile(c, m, "qux/baz/bar", "bar")
+	writeFile(c, m, "README", "readme")
+	c.Assert(m.MkdirAll("empty", 0755), IsNil)
+	c.Assert(m.Symlink("qux/foo.html", "link"), IsNil)
+	s.fsys = New(m)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FSSuite) TestFSTest(c *C) {
+	err := fstest.TestFS(s.fsys, "README", "qux/foo.html", "qux/baz/bar", "empty", "link")
+	c.Assert(err, IsNil)
+
+	sub, err := fs.Sub(s.fsys, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(fstest.TestFS(sub, "foo.html", "baz/bar"), IsNil)
+}
+
+func (s *FSSuite) TestReadDir(c *C) {
+	entries, err := fs.ReadDir(s.fsys, ".")
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	c.Assert(names, DeepEquals, []string{"README", "empty", "link", "qux"})
+	c.Assert(entries[1].IsDir(), Equals, true)
+
+	f, err := s.fsys.Open("qux")
+	c.Assert(err, IsNil)
+	d := f.(fs.ReadDirFile)
+	entries, err = d.ReadDir(1)
+	c.Assert(err, IsNil)
+	c.Assert(entries[0].Name(), Equals, "baz")
+	entries, err = d.ReadDir(5)
+	c.Assert(err, IsNil)
+	c.Assert(entries[0].Name(), Equals, "foo.html")
+	_, err = d.ReadDir(1)
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(d.Close(), IsNil)
+}
+
+func (s *FSSuite) TestOpen(c *C) {
+	data, err := fs.ReadFile(s.fsys, "link")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo {{.}}")
+
+	fi, err := fs.Stat(s.fsys, "link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(fi.Mode().IsRegular(), Equals, true)
+
+	for _, name := range []string{"/README", "../README", "qux/"} {
+		_, err = s.fsys.Open(name)
+		c.Assert(err, FitsTypeOf, &fs.PathError{})
+		c.Assert(err.(*fs.PathError).Err, Equals, fs.ErrInvalid)
+	}
+
+	_, err = s.fsys.Open("missing")
+	c.Assert(err, NotNil)
+}
+
+func (s *FSSuite) TestStdlib(c *C) {
+	t, err := template.ParseFS(s.fsys, "qux/*.html")
+	c.Assert(err, IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(t.Execute(buf, "bar"), IsNil)
+	c.Assert(buf.String(), Equals, "foo bar")
+
+	srv := httptest.NewServer(http.FileServer(http.FS(s.fsys)))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/qux/baz/bar")
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(res.Body)
+	c.Assert(err, IsNil)
+	c.Assert(res.Body.Close(), IsNil)
+	c.Assert(string(data), Equals, "bar")
+}
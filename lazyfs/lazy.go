@@ -0,0 +1,536 @@
+// Package lazyfs provides a billy filesystem whose files are copied from a
+// source filesystem the first time they are accessed.
+package lazyfs // import "srcd.works/go-billy.v1/lazyfs"
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Lazy is a filesystem holding a shallow copy of a source filesystem: the
+// files are represented by stubs, with the metadata of the source files, and
+// their content is copied to the destination filesystem, hydrated, the first
+// time they are opened.
+type Lazy struct {
+	src, dst billy.Filesystem
+	base     string
+	s        *stubs
+}
+
+type stubs struct {
+	sync.Mutex
+	files map[string]*stub
+	// hydrating are the stubs being hydrated, the channels are closed once
+	// done. The copies are done without holding the lock.
+	hydrating map[string]chan struct{}
+}
+
+// stub is a file not hydrated yet, src is the name of the file in the source
+// filesystem and target the target of the symbolic links.
+type stub struct {
+	info   billy.FileInfo
+	src    string
+	target string
+}
+
+// Checkout creates stubs for all the files in src and returns a Lazy
+// filesystem storing the hydrated files in dst. Only the metadata of src is
+// read.
+func Checkout(dst, src billy.Filesystem) (*Lazy, error) {
+	s := &stubs{
+		files:     make(map[string]*stub),
+		hydrating: make(map[string]chan struct{}),
+	}
+	err := billy.Walk(src, "", func(path string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		st := &stub{info: info, src: path}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if st.target, err = src.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		s.files[clean(path)] = st
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lazy{src: src, dst: dst, s: s}, nil
+}
+
+// IsStub returns true if the named file has not been hydrated yet.
+func (fs *Lazy) IsStub(filename string) bool {
+	fs.s.Lock()
+	defer fs.s.Unlock()
+
+	_, ok := fs.s.files[fs.path(filename)]
+	return ok
+}
+
+// Create creates the named file, discarding any stub.
+func (fs *Lazy) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading, hydrating it if needed.
+func (fs *Lazy) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, hydrating it first if it's a stub. The
+// content of a stub is not copied when opened with os.O_TRUNC. Opening a
+// symbolic link hydrates the link and its target.
+func (fs *Lazy) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	path := fs.path(filename)
+	if err := fs.hydrate(path, flag&os.O_TRUNC != 0, 0); err != nil {
+		return nil, err
+	}
+
+	f, err := fs.dst.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: fs.name(path)}, nil
+}
+
+// hydrate copies the stub of path to the destination filesystem, if any, and
+// the targets of the symbolic links. The content is not copied if truncate
+// is true. The stub is kept while being copied, the other operations on it
+// waiting for the copy, but not the ones on other files.
+func (fs *Lazy) hydrate(path string, truncate bool, links int) error {
+	st := fs.claim(path)
+	if st == nil {
+		return nil
+	}
+
+	var err error
+	switch {
+	case st.target == "" && !truncate:
+		err = billy.CopyFile(fs.dst, path, fs.src, st.src)
+	case st.target == "":
+	default:
+		if links++; links > fs.MaxLinks() {
+			err = billy.ErrTooManyLinks
+		} else {
+			err = fs.dst.Symlink(st.target, path)
+		}
+	}
+
+	fs.release(path, err == nil)
+	if err != nil || st.target == "" || filepath.IsAbs(st.target) {
+		// absolute targets are outside of the checkout.
+		return err
+	}
+
+	return fs.hydrate(clean(filepath.Join(filepath.Dir(path), st.target)), truncate, links)
+}
+
+// claim returns the stub of path, if any, marking it as being hydrated. It
+// waits for the hydration of path already in progress.
+func (fs *Lazy) claim(path string) *stub {
+	fs.s.Lock()
+	defer fs.s.Unlock()
+
+	fs.wait(path)
+	st, ok := fs.s.files[path]
+	if !ok {
+		return nil
+	}
+
+	fs.s.hydrating[path] = make(chan struct{})
+	return st
+}
+
+// release ends the hydration of path, removing its stub if it's done.
+func (fs *Lazy) release(path string, done bool) {
+	fs.s.Lock()
+	defer fs.s.Unlock()
+
+	if done {
+		delete(fs.s.files, path)
+	}
+
+	close(fs.s.hydrating[path])
+	delete(fs.s.hydrating, path)
+}
+
+// wait waits for the hydration of path in progress, if any. It must be
+// called holding the lock of the stubs, released while waiting.
+func (fs *Lazy) wait(path string) {
+	for {
+		done, ok := fs.s.hydrating[path]
+		if !ok {
+			return
+		}
+
+		fs.s.Unlock()
+		<-done
+		fs.s.Lock()
+	}
+}
+
+// Stat returns the FileInfo of the named file, for stubs the one of the
+// source file is returned. The symbolic links are followed.
+func (fs *Lazy) Stat(filename string) (billy.FileInfo, error) {
+	return fs.stat(fs.path(filename), true, 0)
+}
+
+// Lstat returns the FileInfo of the named file without following symbolic
+// links, for stubs the one of the source file is returned.
+func (fs *Lazy) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.stat(fs.path(filename), false, 0)
+}
+
+func (fs *Lazy) stat(path string, follow bool, links int) (billy.FileInfo, error) {
+	fs.s.Lock()
+	st, ok := fs.s.files[path]
+	isDir := fs.hasStubsUnder(path)
+	fs.s.Unlock()
+
+	switch {
+	case ok && follow && st.target != "" && !filepath.IsAbs(st.target):
+		if links++; links > fs.MaxLinks() {
+			return nil, billy.ErrTooManyLinks
+		}
+
+		return fs.stat(clean(filepath.Join(filepath.Dir(path), st.target)), follow, links)
+	case ok:
+		return st.info, nil
+	}
+
+	stat, srcStat := fs.dst.Stat, fs.src.Stat
+	if !follow {
+		stat, srcStat = fs.dst.Lstat, fs.src.Lstat
+	}
+
+	fi, err := stat(path)
+	if err == nil || !isDir {
+		return fi, err
+	}
+
+	return fs.dirInfo(path, srcStat), nil
+}
+
+// dirInfo returns the FileInfo of the directory path, holding stubs but
+// missing from the destination filesystem: the one of the source directory,
+// or a new one if the stubs were renamed into a directory that the source
+// doesn't have.
+func (fs *Lazy) dirInfo(path string, stat func(string) (billy.FileInfo, error)) billy.FileInfo {
+	if fi, err := stat(path); err == nil && fi.IsDir() {
+		return fi
+	}
+
+	return &stubDirInfo{name: filepath.Base("/" + path)}
+}
+
+// ReadDir lists the given directory, merging the hydrated files and the
+// stubs, sorted by name.
+func (fs *Lazy) ReadDir(dir string) ([]billy.FileInfo, error) {
+	path := fs.path(dir)
+	l, err := fs.dst.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, fi := range l {
+		seen[fi.Name()] = true
+	}
+
+	prefix := path + "/"
+	if path == "" {
+		prefix = ""
+	}
+
+	// the directories holding only stubs are stat'ed in the source without
+	// holding the lock.
+	var dirs []string
+	fs.s.Lock()
+	for name, st := range fs.s.files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		rel := name[len(prefix):]
+		if i := strings.Index(rel, "/"); i != -1 {
+			if rel = rel[:i]; !seen[rel] {
+				dirs = append(dirs, rel)
+				seen[rel] = true
+			}
+
+			continue
+		}
+
+		if !seen[rel] {
+			l = append(l, st.info)
+			seen[rel] = true
+		}
+	}
+	fs.s.Unlock()
+
+	for _, rel := range dirs {
+		l = append(l, fs.dirInfo(filepath.Join(path, rel), fs.src.Stat))
+	}
+
+	if len(l) == 0 && err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byName(l))
+	return l, nil
+}
+
+// TempFile creates a temporary file in the destination filesystem.
+func (fs *Lazy) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.dst.TempFile(fs.path(dir), prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: fs.name(f.Filename())}, nil
+}
+
+// Rename renames a file, stubs are renamed without being hydrated.
+func (fs *Lazy) Rename(from, to string) error {
+	from, to = fs.path(from), fs.path(to)
+
+	fs.s.Lock()
+	defer fs.s.Unlock()
+
+	fs.wait(from)
+	fs.wait(to)
+
+	st, ok := fs.s.files[from]
+	if !ok {
+		delete(fs.s.files, to)
+		return fs.dst.Rename(from, to)
+	}
+
+	// the stub keeps pointing to the content of the original source file.
+	if err := fs.dst.Remove(to); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	delete(fs.s.files, from)
+	fs.s.files[to] = &stub{
+		info:   &renamedInfo{FileInfo: st.info, name: filepath.Base(to)},
+		src:    st.src,
+		target: st.target,
+	}
+
+	return nil
+}
+
+// Remove removes a file, or its stub.
+func (fs *Lazy) Remove(filename string) error {
+	path := fs.path(filename)
+
+	fs.s.Lock()
+	defer fs.s.Unlock()
+
+	fs.wait(path)
+	if _, ok := fs.s.files[path]; ok {
+		delete(fs.s.files, path)
+		return nil
+	}
+
+	return fs.dst.Remove(path)
+}
+
+// Symlink creates a symbolic link in the destination filesystem.
+func (fs *Lazy) Symlink(target, link string) error {
+	path := fs.path(link)
+
+	fs.s.Lock()
+	defer fs.s.Unlock()
+
+	fs.wait(path)
+	if _, ok := fs.s.files[path]; ok {
+		return os.ErrExist
+	}
+
+	return fs.dst.Symlink(target, path)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Lazy) Readlink(link string) (string, error) {
+	path := fs.path(link)
+
+	fs.s.Lock()
+	st, ok := fs.s.files[path]
+	fs.s.Unlock()
+
+	switch {
+	case ok && st.target != "":
+		return st.target, nil
+	case ok:
+		// not a link, the source filesystem reports the error.
+		return fs.src.Readlink(st.src)
+	}
+
+	return fs.dst.Readlink(path)
+}
+
+// MkdirAll creates a directory and its parents in the destination
+// filesystem.
+func (fs *Lazy) MkdirAll(path string, perm os.FileMode) error {
+	return fs.dst.MkdirAll(fs.path(path), perm)
+}
+
+// Chmod changes the mode of a file, hydrating it first if it's a stub.
+func (fs *Lazy) Chmod(name string, mode os.FileMode) error {
+	path := fs.path(name)
+	if err := fs.hydrate(path, false, 0); err != nil {
+		return err
+	}
+
+	return fs.dst.Chmod(path, mode)
+}
+
+// Chtimes changes the times of a file, hydrating it first if it's a stub.
+func (fs *Lazy) Chtimes(name string, atime, mtime time.Time) error {
+	path := fs.path(name)
+	if err := fs.hydrate(path, false, 0); err != nil {
+		return err
+	}
+
+	return fs.dst.Chtimes(path, atime, mtime)
+}
+
+// Ping checks both, the source and the destination filesystems, as
+// billy.Ping, since the stubs are hydrated from the source.
+func (fs *Lazy) Ping(ctx context.Context) error {
+	if _, err := billy.Ping(ctx, fs.dst); err != nil {
+		return err
+	}
+
+	_, err := billy.Ping(ctx, fs.src)
+	return err
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the destination filesystem, as billy.MaxLinks, where the
+// links of the stubs are created.
+func (fs *Lazy) MaxLinks() int {
+	return billy.MaxLinks(fs.dst)
+}
+
+// Close closes both, the source and the destination filesystems, as
+// billy.Close, returning the first error.
+func (fs *Lazy) Close() error {
+	err := billy.Close(fs.dst)
+	if serr := billy.Close(fs.src); err == nil {
+		err = serr
+	}
+
+	return err
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Lazy) Join(elem ...string) string {
+	return fs.dst.Join(elem...)
+}
+
+// Dir returns a new Lazy filesystem rooted at the given path, sharing the
+// stubs with the current one.
+func (fs *Lazy) Dir(path string) billy.Filesystem {
+	return &Lazy{src: fs.src, dst: fs.dst, base: fs.path(path), s: fs.s}
+}
+
+// Base returns the base path of the filesystem.
+func (fs *Lazy) Base() string {
+	return fs.dst.Join(fs.dst.Base(), fs.base)
+}
+
+// path returns the key of the given filename, relative to the root of the
+// source and destination filesystems.
+func (fs *Lazy) path(filename string) string {
+	return clean(filepath.Join(fs.base, filename))
+}
+
+// name returns the given path relative to the filesystem base.
+func (fs *Lazy) name(path string) string {
+	name, _ := filepath.Rel("/"+fs.base, "/"+path)
+	return name
+}
+
+func (fs *Lazy) hasStubsUnder(path string) bool {
+	prefix := path + "/"
+	for name := range fs.s.files {
+		if path == "" || strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func clean(path string) string {
+	return strings.TrimPrefix(filepath.Clean("/"+path), "/")
+}
+
+// renamedInfo is the FileInfo of a stub renamed before being hydrated.
+type renamedInfo struct {
+	billy.FileInfo
+	name string
+}
+
+func (fi *renamedInfo) Name() string {
+	return fi.name
+}
+
+// stubDirInfo is the FileInfo of a directory holding only stubs renamed into
+// it, missing from the source and destination filesystems.
+type stubDirInfo struct {
+	name string
+}
+
+func (fi *stubDirInfo) Name() string       { return fi.name }
+func (fi *stubDirInfo) Size() int64        { return 0 }
+func (fi *stubDirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (fi *stubDirInfo) ModTime() time.Time { return time.Time{} }
+func (fi *stubDirInfo) IsDir() bool        { return true }
+func (fi *stubDirInfo) Sys() interface{}   { return nil }
+
+type byName []billy.FileInfo
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// file is a hydrated file, whose name is relative to the Lazy filesystem.
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Filename() string {
+	return f.name
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
@@ -0,0 +1,189 @@
+package lazyfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type LazySuite struct {
+	src, dst *memory.Memory
+	fs       *Lazy
+}
+
+var _ = Suite(&LazySuite{})
+
+func (s *LazySuite) SetUpTest(c *C) {
+	s.src = memory.New()
+	s.dst = memory.New()
+	writeFile(c, s.src, "foo", "foo")
+	writeFile(c, s.src, "qux/bar", "bar")
+	writeFile(c, s.src, "qux/baz/qux", "qux")
+
+	var err error
+	s.fs, err = Checkout(s.dst, s.src)
+	c.Assert(err, IsNil)
+}
+
+func (s *LazySuite) TestCheckout(c *C) {
+	l, err := s.dst.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 0)
+
+	l, err = s.fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+
+	l, err = s.fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+
+	fi, err := s.fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	fi, err = s.fs.Stat("qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+}
+
+func (s *LazySuite) TestOpenHydrates(c *C) {
+	c.Assert(s.fs.IsStub("qux/bar"), Equals, true)
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar")
+	c.Assert(s.fs.IsStub("qux/bar"), Equals, false)
+	c.Assert(readFile(c, s.dst, "qux/bar"), Equals, "bar")
+
+	writeFile(c, s.src, "qux/bar", "changed")
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar")
+}
+
+func (s *LazySuite) TestWriteStub(c *C) {
+	f, err := s.fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "foobar")
+	c.Assert(readFile(c, s.src, "foo"), Equals, "foo")
+}
+
+func (s *LazySuite) TestRenameAndRemoveStub(c *C) {
+	c.Assert(s.fs.Rename("foo", "qux/foo"), IsNil)
+	c.Assert(s.fs.IsStub("qux/foo"), Equals, true)
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foo")
+
+	c.Assert(s.fs.Remove("qux/bar"), IsNil)
+	_, err := s.fs.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = s.src.Stat("qux/bar")
+	c.Assert(err, IsNil)
+}
+
+func (s *LazySuite) TestRenameStubToNewDir(c *C) {
+	c.Assert(s.fs.Rename("foo", "new/foo"), IsNil)
+
+	l, err := s.fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+	c.Assert(l[0].Name(), Equals, "new")
+	c.Assert(l[0].IsDir(), Equals, true)
+
+	fi, err := s.fs.Stat("new")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	l, err = s.fs.ReadDir("new")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	c.Assert(l[0].Name(), Equals, "foo")
+	c.Assert(readFile(c, s.fs, "new/foo"), Equals, "foo")
+}
+
+func (s *LazySuite) TestHydrateWithoutLock(c *C) {
+	src := &blockingFS{Memory: s.src, open: make(chan struct{}), done: make(chan struct{})}
+	fs, err := Checkout(memory.New(), src)
+	c.Assert(err, IsNil)
+
+	opened := make(chan error)
+	go func() {
+		f, err := fs.Open("foo")
+		if err == nil {
+			err = f.Close()
+		}
+
+		opened <- err
+	}()
+
+	<-src.open
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(readFile(c, fs, "qux/bar"), Equals, "bar")
+	c.Assert(fs.IsStub("foo"), Equals, true)
+
+	close(src.done)
+	c.Assert(<-opened, IsNil)
+	c.Assert(fs.IsStub("foo"), Equals, false)
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+}
+
+func (s *LazySuite) TestDir(c *C) {
+	qux := s.fs.Dir("qux")
+	f, err := qux.Open("baz/qux")
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, "baz/qux")
+	c.Assert(f.Close(), IsNil)
+	c.Assert(s.fs.IsStub("qux/baz/qux"), Equals, false)
+}
+
+type EmptySuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&EmptySuite{})
+
+func (s *EmptySuite) SetUpTest(c *C) {
+	fs, err := Checkout(memory.New(), memory.New())
+	c.Assert(err, IsNil)
+	s.FilesystemSuite.Fs = fs
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
+
+// blockingFS blocks the opening of foo until done is closed.
+type blockingFS struct {
+	*memory.Memory
+	open, done chan struct{}
+}
+
+func (fs *blockingFS) Open(filename string) (billy.File, error) {
+	if filename == "foo" {
+		close(fs.open)
+		<-fs.done
+	}
+
+	return fs.Memory.Open(filename)
+}
@@ -0,0 +1,268 @@
+package billy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrLeaseHeld is returned by AcquireLease when the lease is held by
+	// another owner and it has not expired.
+	ErrLeaseHeld = errors.New("lease held by another owner")
+	// ErrLeaseLost is returned by Lease methods when the lease expired and was
+	// taken by another owner.
+	ErrLeaseLost = errors.New("lease lost")
+)
+
+// LeaseGrace is the time a lock file that can't be parsed, such as one still
+// being written by the worker acquiring it, is considered held since it was
+// last modified.
+const LeaseGrace = 10 * time.Second
+
+// Lease is a time limited claim over a path, held while a lock file exists
+// containing the owner token and the expiration time. It allows coordinating
+// workers sharing a backend without native locks.
+//
+// On the filesystems implementing Conditional the lock file is written with
+// compare-and-swap writes, so acquiring, taking over an expired lease and
+// renewing it are atomic, as releasing it on the ones implementing
+// ConditionalRemover. On the rest the lock file is created with
+// O_CREATE|O_EXCL, so only one owner can acquire a free lease, but taking
+// over an expired lease and renewing one are not atomic. In both cases the
+// expiration times are compared against the local clock, so the TTL must be
+// much larger than the clock skew between the workers.
+type Lease struct {
+	fs      Filesystem
+	name    string
+	token   string
+	expires time.Time
+	// version is the version of the lock file, on Conditional filesystems.
+	version string
+}
+
+// AcquireLease acquires the lease represented by the lock file name for the
+// given duration. If the lease is held by another owner ErrLeaseHeld is
+// returned, unless it has expired.
+func AcquireLease(fs Filesystem, name string, ttl time.Duration) (*Lease, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lease{fs: fs, name: name, token: token}
+	err = l.create(ttl, Precondition{IfNotExist: true})
+	if err != ErrLeaseHeld {
+		if err != nil {
+			return nil, err
+		}
+
+		return l, nil
+	}
+
+	_, expires, version, err := readLease(fs, name)
+	switch {
+	case os.IsNotExist(err):
+		// released in between.
+		version = ""
+	case err != nil:
+		return nil, err
+	case time.Now().Before(expires):
+		return nil, ErrLeaseHeld
+	}
+
+	if err := l.takeOver(ttl, version); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Expires returns the expiration time of the lease.
+func (l *Lease) Expires() time.Time {
+	return l.expires
+}
+
+// Renew extends the lease for the given duration from now.
+func (l *Lease) Renew(ttl time.Duration) error {
+	if err := l.check(); err != nil {
+		return err
+	}
+
+	if _, ok := l.fs.(Conditional); ok {
+		if err := l.create(ttl, Precondition{IfMatch: l.version}); err != nil {
+			if err == ErrLeaseHeld {
+				return ErrLeaseLost
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	f, tmpfs, err := TempFileFor(l.fs, nil, l.name, ".lease")
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(ttl)
+	if err := writeLease(f, l.token, expires); err != nil {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := l.fs.Rename(f.Filename(), l.name); err != nil {
+		return err
+	}
+
+	l.expires = expires
+	return nil
+}
+
+// Release gives up the lease, removing the lock file.
+func (l *Lease) Release() error {
+	if r, ok := l.fs.(ConditionalRemover); ok && l.version != "" {
+		err := r.RemoveFileIf(l.name, Precondition{IfMatch: l.version})
+		if err == ErrPreconditionFailed || os.IsNotExist(err) {
+			return ErrLeaseLost
+		}
+
+		return err
+	}
+
+	if err := l.check(); err != nil {
+		return err
+	}
+
+	return l.fs.Remove(l.name)
+}
+
+// takeOver replaces the expired lock file with the given version, empty if
+// it doesn't exist or the filesystem doesn't implement Conditional.
+func (l *Lease) takeOver(ttl time.Duration, version string) error {
+	if _, ok := l.fs.(Conditional); ok && version != "" {
+		return l.create(ttl, Precondition{IfMatch: version})
+	}
+
+	if err := l.fs.Remove(l.name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return l.create(ttl, Precondition{IfNotExist: true})
+}
+
+// create writes the lock file if cond holds, otherwise it returns
+// ErrLeaseHeld. Without Conditional only IfNotExist is honored.
+func (l *Lease) create(ttl time.Duration, cond Precondition) error {
+	expires := time.Now().Add(ttl)
+	if c, ok := l.fs.(Conditional); ok {
+		version, err := c.WriteFileIf(l.name, leaseContent(l.token, expires), cond)
+		if err == ErrPreconditionFailed {
+			return ErrLeaseHeld
+		}
+
+		if err != nil {
+			return err
+		}
+
+		l.expires, l.version = expires, version
+		return nil
+	}
+
+	f, err := l.fs.OpenFile(l.name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return ErrLeaseHeld
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeLease(f, l.token, expires); err != nil {
+		l.fs.Remove(l.name)
+		return err
+	}
+
+	l.expires = expires
+	return nil
+}
+
+// check returns ErrLeaseLost if the lock file is missing or owned by other.
+func (l *Lease) check() error {
+	token, _, _, err := readLease(l.fs, l.name)
+	if os.IsNotExist(err) || (err == nil && token != l.token) {
+		return ErrLeaseLost
+	}
+
+	return err
+}
+
+func leaseContent(token string, expires time.Time) []byte {
+	return []byte(fmt.Sprintf("%s\n%d\n", token, expires.UnixNano()))
+}
+
+func writeLease(f File, token string, expires time.Time) error {
+	_, err := f.Write(leaseContent(token, expires))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// readLease returns the owner token and the expiration time of the lock file
+// name, and its version on the filesystems implementing Conditional.
+func readLease(fs Filesystem, name string) (token string, expires time.Time, version string, err error) {
+	var content []byte
+	if c, ok := fs.(Conditional); ok {
+		content, version, err = c.ReadFileVersion(name)
+	} else {
+		content, err = readFile(fs, name)
+	}
+
+	if err != nil {
+		return "", time.Time{}, "", err
+	}
+
+	parts := bytes.Split(content, []byte("\n"))
+	if len(parts) >= 3 {
+		nsec, err := strconv.ParseInt(string(parts[1]), 10, 64)
+		if err == nil {
+			return string(parts[0]), time.Unix(0, nsec), version, nil
+		}
+	}
+
+	// a partially written lock file is held for a grace period.
+	fi, err := fs.Stat(name)
+	if err != nil {
+		return "", time.Time{}, "", err
+	}
+
+	return "", fi.ModTime().Add(LeaseGrace), version, nil
+}
+
+func readFile(fs Filesystem, name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
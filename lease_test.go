@@ -0,0 +1,145 @@
+package billy_test
+
+import (
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type LeaseSuite struct{}
+
+var _ = Suite(&LeaseSuite{})
+
+func (s *LeaseSuite) TestAcquireAndRelease(c *C) {
+	fs := memory.New()
+	l, err := billy.AcquireLease(fs, "foo.lock", time.Minute)
+	c.Assert(err, IsNil)
+
+	_, err = billy.AcquireLease(fs, "foo.lock", time.Minute)
+	c.Assert(err, Equals, billy.ErrLeaseHeld)
+
+	c.Assert(l.Release(), IsNil)
+	_, err = fs.Stat("foo.lock")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = billy.AcquireLease(fs, "foo.lock", time.Minute)
+	c.Assert(err, IsNil)
+}
+
+func (s *LeaseSuite) TestAcquireExpired(c *C) {
+	fs := memory.New()
+	old, err := billy.AcquireLease(fs, "foo.lock", -time.Second)
+	c.Assert(err, IsNil)
+
+	l, err := billy.AcquireLease(fs, "foo.lock", time.Minute)
+	c.Assert(err, IsNil)
+
+	c.Assert(old.Renew(time.Minute), Equals, billy.ErrLeaseLost)
+	c.Assert(old.Release(), Equals, billy.ErrLeaseLost)
+	c.Assert(l.Release(), IsNil)
+}
+
+func (s *LeaseSuite) TestReleaseTakenOver(c *C) {
+	fs := &takeOver{Memory: memory.New()}
+	old, err := billy.AcquireLease(fs, "foo.lock", -time.Second)
+	c.Assert(err, IsNil)
+
+	// the expired lease is taken over right before being removed.
+	fs.next = func() {
+		_, err := billy.AcquireLease(fs.Memory, "foo.lock", time.Minute)
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(old.Release(), Equals, billy.ErrLeaseLost)
+	_, err = fs.Stat("foo.lock")
+	c.Assert(err, IsNil)
+}
+
+func (s *LeaseSuite) TestRenew(c *C) {
+	fs := memory.New()
+	l, err := billy.AcquireLease(fs, "qux/foo.lock", time.Second)
+	c.Assert(err, IsNil)
+
+	expires := l.Expires()
+	c.Assert(l.Renew(time.Hour), IsNil)
+	c.Assert(l.Expires().After(expires), Equals, true)
+
+	_, err = billy.AcquireLease(fs, "qux/foo.lock", time.Minute)
+	c.Assert(err, Equals, billy.ErrLeaseHeld)
+	c.Assert(l.Release(), IsNil)
+}
+
+func (s *LeaseSuite) TestAcquireExpiredNotConditional(c *C) {
+	// the embedded interface hides the Conditional methods of memory.
+	fs := struct{ billy.Filesystem }{memory.New()}
+	old, err := billy.AcquireLease(fs, "foo.lock", -time.Second)
+	c.Assert(err, IsNil)
+
+	l, err := billy.AcquireLease(fs, "foo.lock", time.Minute)
+	c.Assert(err, IsNil)
+
+	_, err = billy.AcquireLease(fs, "foo.lock", time.Minute)
+	c.Assert(err, Equals, billy.ErrLeaseHeld)
+
+	c.Assert(old.Renew(time.Minute), Equals, billy.ErrLeaseLost)
+	c.Assert(l.Renew(time.Minute), IsNil)
+	c.Assert(l.Release(), IsNil)
+}
+
+func (s *LeaseSuite) TestAcquirePartial(c *C) {
+	for _, fs := range []billy.Filesystem{
+		memory.New(),
+		struct{ billy.Filesystem }{memory.New()},
+	} {
+		writeFile(c, fs, "foo.lock", "0123")
+
+		_, err := billy.AcquireLease(fs, "foo.lock", time.Minute)
+		c.Assert(err, Equals, billy.ErrLeaseHeld)
+
+		mtime := time.Now().Add(-billy.LeaseGrace - time.Second)
+		c.Assert(fs.Chtimes("foo.lock", mtime, mtime), IsNil)
+
+		l, err := billy.AcquireLease(fs, "foo.lock", time.Minute)
+		c.Assert(err, IsNil)
+		c.Assert(l.Release(), IsNil)
+	}
+}
+
+func (s *LeaseSuite) TestAcquireError(c *C) {
+	l, err := billy.AcquireLease(readOnlyFS{memory.New()}, "foo.lock", time.Minute)
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	c.Assert(l, IsNil)
+}
+
+type readOnlyFS struct{ billy.Filesystem }
+
+func (readOnlyFS) OpenFile(string, int, os.FileMode) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// takeOver is a memory filesystem calling next, once, before removing a
+// file.
+type takeOver struct {
+	*memory.Memory
+	next func()
+}
+
+func (fs *takeOver) before() {
+	if next := fs.next; next != nil {
+		fs.next = nil
+		next()
+	}
+}
+
+func (fs *takeOver) Remove(filename string) error {
+	fs.before()
+	return fs.Memory.Remove(filename)
+}
+
+func (fs *takeOver) RemoveFileIf(filename string, cond billy.Precondition) error {
+	fs.before()
+	return fs.Memory.RemoveFileIf(filename, cond)
+}
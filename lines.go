@@ -0,0 +1,62 @@
+package billy
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ScanLines calls fn for every line of the named file, without the line
+// terminator, either "\n" or "\r\n". The last line is passed even if it's not
+// terminated. If fn returns an error the scan stops and the error is
+// returned. Unlike bufio.Scanner, there is no limit in the length of a line.
+func ScanLines(fs Filesystem, filename string, fn func(line string) error) error {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	return scanLines(f, fn)
+}
+
+// ReadLines returns all the lines of the named file, as ScanLines.
+func ReadLines(fs Filesystem, filename string) ([]string, error) {
+	var lines []string
+	err := ScanLines(fs, filename, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+func scanLines(r io.Reader, fn func(line string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if err == io.EOF && line == "" {
+			return nil
+		}
+
+		if ferr := fn(trimLine(line)); ferr != nil {
+			return ferr
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func trimLine(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
@@ -0,0 +1,55 @@
+package billy_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type LinesSuite struct{}
+
+var _ = Suite(&LinesSuite{})
+
+func (s *LinesSuite) TestReadLines(c *C) {
+	fs := memory.New()
+	long := strings.Repeat("x", 128*1024)
+	writeFile(c, fs, "foo", "foo\r\nbar\n\n"+long+"\nqux")
+
+	lines, err := billy.ReadLines(fs, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(lines, DeepEquals, []string{"foo", "bar", "", long, "qux"})
+}
+
+func (s *LinesSuite) TestReadLinesEmpty(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "")
+
+	lines, err := billy.ReadLines(fs, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(lines, HasLen, 0)
+
+	_, err = billy.ReadLines(fs, "bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *LinesSuite) TestScanLinesStop(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo", "foo\nbar\nqux\n")
+
+	stop := errors.New("stop")
+	var lines []string
+	err := billy.ScanLines(fs, "foo", func(line string) error {
+		lines = append(lines, line)
+		if line == "bar" {
+			return stop
+		}
+
+		return nil
+	})
+	c.Assert(err, Equals, stop)
+	c.Assert(lines, DeepEquals, []string{"foo", "bar"})
+}
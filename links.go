@@ -0,0 +1,23 @@
+package billy
+
+// LinkLimiter is an optional interface implemented by the filesystems with a
+// configurable maximum number of symbolic links followed while resolving a
+// path, and by the wrappers, reporting the one of the filesystem they wrap.
+type LinkLimiter interface {
+	// MaxLinks returns the maximum number of symbolic links followed while
+	// resolving a path, exceeding it fails with ErrTooManyLinks.
+	MaxLinks() int
+}
+
+// MaxLinks returns the maximum number of symbolic links fs follows while
+// resolving a path, DefaultMaxLinks if it doesn't implement LinkLimiter. The
+// code resolving symbolic links on top of fs should honor it.
+func MaxLinks(fs Filesystem) int {
+	if l, ok := fs.(LinkLimiter); ok {
+		if n := l.MaxLinks(); n > 0 {
+			return n
+		}
+	}
+
+	return DefaultMaxLinks
+}
@@ -0,0 +1,21 @@
+package billy_test
+
+import (
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type LinksSuite struct{}
+
+var _ = Suite(&LinksSuite{})
+
+func (s *LinksSuite) TestMaxLinks(c *C) {
+	fs := memory.NewWithOptions(memory.Options{MaxLinks: 8})
+	c.Assert(billy.MaxLinks(fs), Equals, 8)
+	c.Assert(billy.MaxLinks(memory.New()), Equals, billy.DefaultMaxLinks)
+
+	// the embedded interface hides the MaxLinks method of memory.
+	hidden := struct{ billy.Filesystem }{fs}
+	c.Assert(billy.MaxLinks(hidden), Equals, billy.DefaultMaxLinks)
+}
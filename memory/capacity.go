@@ -0,0 +1,57 @@
+package memory
+
+import "math"
+
+// full returns true if no more files can be created in the storage.
+func (s *storage) full() bool {
+	return s.maxFiles > 0 && len(s.files) >= s.maxFiles
+}
+
+// free returns the number of bytes c can allocate within the capacity of the
+// storage.
+func (s *storage) free(c *content) int64 {
+	if s.maxSize <= 0 || c.released {
+		return math.MaxInt64
+	}
+
+	return s.maxSize - s.used
+}
+
+// fit returns the part of p fitting in the storage when written at off in c,
+// and whether all of it fits.
+func (s *storage) fit(c *content, off int64, p []byte) ([]byte, bool) {
+	n := c.fitting(off, int64(len(p)), s.free(c))
+	return p[:n], n == int64(len(p))
+}
+
+// writeAt writes p at off in c, accounting its growth.
+func (s *storage) writeAt(c *content, p []byte, off int64) (int, error) {
+	prev := c.allocated()
+	n, err := c.WriteAt(p, off)
+	s.account(c, prev)
+	return n, err
+}
+
+// resize truncates or extends c to size, accounting the change.
+func (s *storage) resize(c *content, size int64) {
+	prev := c.allocated()
+	c.Truncate(size)
+	s.account(c, prev)
+}
+
+// account adds to the space used the bytes allocated by c since prev, the
+// holes don't take space.
+func (s *storage) account(c *content, prev int64) {
+	if !c.released {
+		s.used += c.allocated() - prev
+	}
+}
+
+// release discounts c, once removed from the storage, the writes to the
+// files still open aren't accounted either.
+func (s *storage) release(c *content) {
+	if !c.released {
+		s.used -= c.allocated()
+		c.released = true
+	}
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"os"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+)
+
+type CapacitySuite struct{}
+
+var _ = Suite(&CapacitySuite{})
+
+func (s *CapacitySuite) TestMaxSize(c *C) {
+	fs := NewWithOptions(Options{MaxSize: 8})
+	writeFile(c, fs, "foo", "foo")
+
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+	n, err := f.Write([]byte("barbaz"))
+	c.Assert(n, Equals, 5)
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.ENOSPC)
+
+	n, err = f.Write([]byte("qux"))
+	c.Assert(n, Equals, 0)
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.ENOSPC)
+
+	// growing leaves a hole, which doesn't take space.
+	c.Assert(f.Truncate(6), IsNil)
+
+	// overwriting doesn't need more space.
+	_, err = f.Seek(0, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("BAR"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	data, _, err := fs.ReadFileVersion("bar")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "BARba\x00")
+
+	// the space is freed by removing and truncating the files.
+	c.Assert(fs.Remove("foo"), IsNil)
+	writeFile(c, fs, "qux", "qux")
+	writeFile(c, fs, "bar", "")
+	writeFile(c, fs, "baz", "baz")
+
+	_, err = fs.Create("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Rename("baz", "bar"), IsNil)
+	writeFile(c, fs, "foo", "12345")
+}
+
+func (s *CapacitySuite) TestMaxSizeRemovedOpen(c *C) {
+	fs := NewWithOptions(Options{MaxSize: 3})
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Remove("foo"), IsNil)
+
+	// the files removed while open no longer take space.
+	_, err = f.Write([]byte("foobar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	writeFile(c, fs, "bar", "bar")
+}
+
+func (s *CapacitySuite) TestMaxFiles(c *C) {
+	fs := NewWithOptions(Options{MaxFiles: 2})
+	writeFile(c, fs, "foo", "foo")
+	c.Assert(fs.Symlink("foo", "link"), IsNil)
+
+	_, err := fs.Create("bar")
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.ENOSPC)
+	err = fs.Symlink("foo", "other")
+	c.Assert(err.(*os.LinkError).Err, Equals, syscall.ENOSPC)
+
+	// the existing files can still be written and the directories made.
+	writeFile(c, fs, "foo", "bar")
+	c.Assert(fs.MkdirAll("qux", 0755), IsNil)
+
+	c.Assert(fs.Remove("link"), IsNil)
+	writeFile(c, fs, "bar", "bar")
+}
+
+func (s *CapacitySuite) TestCloneCapacity(c *C) {
+	fs := NewWithOptions(Options{MaxSize: 6})
+	writeFile(c, fs, "foo", "foo")
+
+	clone := fs.Clone()
+	writeFile(c, clone, "bar", "bar")
+	f, err := clone.Create("baz")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("baz"))
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.ENOSPC)
+	c.Assert(f.Close(), IsNil)
+
+	writeFile(c, fs, "baz", "baz")
+}
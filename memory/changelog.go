@@ -0,0 +1,70 @@
+package memory
+
+import "srcd.works/go-billy.v1"
+
+var maxChanges = 1024 * 16
+
+// journal holds the latest changes made to a storage, with full paths.
+type journal struct {
+	events []billy.ChangeEvent
+	cursor uint64
+}
+
+func (s *storage) record(op billy.ChangeOp, path, oldpath string) {
+	j := &s.changes
+	if n := len(j.events); op == billy.ChangeWrite && n != 0 {
+		last := j.events[n-1]
+		if last.Path == path && (last.Op == billy.ChangeWrite || last.Op == billy.ChangeCreate) {
+			return
+		}
+	}
+
+	if len(j.events) >= maxChanges {
+		// the oldest quarter is discarded at once, to amortize the copy.
+		j.events = append(j.events[:0], j.events[maxChanges/4:]...)
+	}
+
+	j.cursor++
+	j.events = append(j.events, billy.ChangeEvent{
+		Cursor:  j.cursor,
+		Op:      op,
+		Path:    path,
+		OldPath: oldpath,
+	})
+}
+
+// Changes returns the changes made to the files under the filesystem base
+// after the given cursor. Renames crossing the base are reported as creations
+// or removals. The directories are reported when created with Mkdir or
+// MkdirAll and removed, not the ones existing only while holding files. At
+// most the latest 16384 changes of the whole storage are retained,
+// billy.ErrCursorExpired is returned for older cursors, except zero.
+func (fs *Memory) Changes(cursor uint64) ([]billy.ChangeEvent, uint64, error) {
+	j := &fs.s.changes
+	if cursor != 0 && len(j.events) != 0 && j.events[0].Cursor > cursor+1 {
+		return nil, j.cursor, billy.ErrCursorExpired
+	}
+
+	var changes []billy.ChangeEvent
+	for _, e := range j.events {
+		if e.Cursor <= cursor {
+			continue
+		}
+
+		path, inside := fs.relative(e.Path)
+		oldpath, oldInside := fs.relative(e.OldPath)
+		switch {
+		case e.Op == billy.ChangeRename && inside && !oldInside:
+			e.Op, oldpath = billy.ChangeCreate, ""
+		case e.Op == billy.ChangeRename && !inside && oldInside:
+			e.Op, path, oldpath = billy.ChangeRemove, oldpath, ""
+		case !inside:
+			continue
+		}
+
+		e.Path, e.OldPath = path, oldpath
+		changes = append(changes, e)
+	}
+
+	return changes, j.cursor, nil
+}
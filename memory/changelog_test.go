@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type ChangeLogSuite struct{}
+
+var _ = Suite(&ChangeLogSuite{})
+
+func (s *ChangeLogSuite) TestChanges(c *C) {
+	fs := New()
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	changes, cursor, err := fs.Changes(0)
+	c.Assert(err, IsNil)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Cursor: 1, Op: billy.ChangeCreate, Path: "foo"},
+	})
+
+	c.Assert(fs.Rename("foo", "qux/bar"), IsNil)
+	c.Assert(fs.Remove("qux/bar"), IsNil)
+
+	changes, cursor, err = fs.Changes(cursor)
+	c.Assert(err, IsNil)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Cursor: 2, Op: billy.ChangeRename, Path: "qux/bar", OldPath: "foo"},
+		{Cursor: 3, Op: billy.ChangeRemove, Path: "qux/bar"},
+	})
+
+	changes, _, err = fs.Changes(cursor)
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 0)
+}
+
+func (s *ChangeLogSuite) TestChangesDir(c *C) {
+	fs := New()
+	qux := fs.Dir("qux").(*Memory)
+
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(fs.Rename("foo", "qux/foo"), IsNil)
+	c.Assert(fs.Rename("qux/foo", "bar"), IsNil)
+
+	changes, _, err := qux.Changes(0)
+	c.Assert(err, IsNil)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Cursor: 2, Op: billy.ChangeCreate, Path: "foo"},
+		{Cursor: 3, Op: billy.ChangeRemove, Path: "foo"},
+	})
+}
+
+func (s *ChangeLogSuite) TestChangesExpired(c *C) {
+	fs := New()
+	for i := 0; i < maxChanges+1; i++ {
+		f, err := fs.Create("foo")
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+		c.Assert(fs.Remove("foo"), IsNil)
+	}
+
+	_, _, err := fs.Changes(1)
+	c.Assert(err, Equals, billy.ErrCursorExpired)
+
+	changes, cursor, err := fs.Changes(0)
+	c.Assert(err, IsNil)
+	c.Assert(len(changes) > 0, Equals, true)
+	c.Assert(changes[len(changes)-1].Cursor, Equals, cursor)
+}
+
+func (s *ChangeLogSuite) TestChangesDirectories(c *C) {
+	fs := New()
+	c.Assert(fs.MkdirAll("foo/bar", 0755), IsNil)
+	c.Assert(fs.MkdirAll("foo/bar", 0755), IsNil)
+	c.Assert(fs.Mkdir("foo/qux", 0755), IsNil)
+	c.Assert(fs.Remove("foo/qux"), IsNil)
+
+	changes, _, err := fs.Changes(0)
+	c.Assert(err, IsNil)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Cursor: 1, Op: billy.ChangeCreate, Path: "foo"},
+		{Cursor: 2, Op: billy.ChangeCreate, Path: "foo/bar"},
+		{Cursor: 3, Op: billy.ChangeCreate, Path: "foo/qux"},
+		{Cursor: 4, Op: billy.ChangeRemove, Path: "foo/qux"},
+	})
+}
+
+func (s *ChangeLogSuite) TestChangesMetadata(c *C) {
+	fs := New()
+	f, err := fs.Create("foo/bar")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, cursor, err := fs.Changes(0)
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Chmod("foo/bar", 0600), IsNil)
+	c.Assert(fs.Chtimes("foo", time.Now(), time.Now()), IsNil)
+
+	changes, _, err := fs.Changes(cursor)
+	c.Assert(err, IsNil)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Cursor: cursor + 1, Op: billy.ChangeMetadata, Path: "foo/bar"},
+		{Cursor: cursor + 2, Op: billy.ChangeMetadata, Path: "foo"},
+	})
+}
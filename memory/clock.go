@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// StepClock returns a clock, to be used as Options.Clock, starting at start
+// and advancing by the given steps in turn, cycling through them. Every
+// modification time taken from the clock consumes a step, the first one
+// being the time of the root, taken once by NewWithOptions. So a zero step
+// gives tied timestamps and a negative one out-of-order timestamps, as the
+// ones produced by a clock adjusted backwards. Without steps the clock is
+// stopped at start.
+func StepClock(start time.Time, steps ...time.Duration) func() time.Time {
+	var m sync.Mutex
+	now, next := start, 0
+	return func() time.Time {
+		m.Lock()
+		defer m.Unlock()
+
+		t := now
+		if len(steps) != 0 {
+			now = now.Add(steps[next])
+			next = (next + 1) % len(steps)
+		}
+
+		return t
+	}
+}
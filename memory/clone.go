@@ -0,0 +1,92 @@
+package memory
+
+import "srcd.works/go-billy.v1"
+
+// Clone returns a new Memory filesystem holding a copy of the whole storage
+// of fs, with the same base and options, independent of fs: the changes made
+// to any of them aren't seen by the other. The contents of the files are
+// shared, and only copied when written, so cloning costs a copy of the
+// index of the tree, proportional to its number of files and directories but
+// not to their size. The open files and the locks held aren't cloned, and
+// the changes recorded are, so the cursors taken from fs are valid in the
+// clone.
+func (fs *Memory) Clone() *Memory {
+	return &Memory{
+		base:      fs.base,
+		s:         fs.s.clone(),
+		tempCount: fs.tempCount,
+		opts:      fs.opts,
+	}
+}
+
+// Snapshot is an immutable copy of the tree of a Memory filesystem, taken
+// with Memory.Snapshot, from which any number of filesystems can be cloned,
+// such as a fixture prepared once and cloned by every test. It's safe for
+// concurrent use.
+type Snapshot struct {
+	fs *Memory
+}
+
+// Snapshot returns a Snapshot of the current tree of fs, as done by Clone.
+func (fs *Memory) Snapshot() *Snapshot {
+	return &Snapshot{fs: fs.Clone()}
+}
+
+// Clone returns a new Memory filesystem holding the tree of the snapshot,
+// as Memory.Clone.
+func (s *Snapshot) Clone() *Memory {
+	return s.fs.Clone()
+}
+
+// clone returns a copy of the storage sharing the contents of the files and
+// the names of the directories, marked as shared in both. A storage whose
+// entries are all shared, as the one of a Snapshot, is only read.
+func (s *storage) clone() *storage {
+	c := &storage{
+		files:       make(map[string]*file, len(s.files)),
+		dirs:        make(map[string]*directory, len(s.dirs)),
+		lastID:      s.lastID,
+		lastVersion: s.lastVersion,
+		resolution:  s.resolution,
+		clock:       s.clock,
+		skew:        s.skew,
+		rootTime:    s.rootTime,
+		used:        s.used,
+		maxSize:     s.maxSize,
+		maxFiles:    s.maxFiles,
+	}
+
+	c.changes.cursor = s.changes.cursor
+	c.changes.events = append([]billy.ChangeEvent(nil), s.changes.events...)
+
+	for key, f := range s.files {
+		if !f.content.shared {
+			f.content.shared = true
+		}
+
+		content := *f.content
+		c.files[key] = &file{
+			BaseFile: billy.BaseFile{BaseFilename: f.BaseFilename},
+			s:        c,
+			id:       f.id,
+			path:     f.path,
+			content:  &content,
+			flag:     f.flag,
+			target:   f.target,
+		}
+	}
+
+	for key, d := range s.dirs {
+		// the tombstones are dropped first, they aren't shared.
+		d.compact()
+		if !d.shared {
+			d.sort()
+			d.shared = true
+		}
+
+		dir := *d
+		c.dirs[key] = &dir
+	}
+
+	return c
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"os"
+	"sync"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type CloneSuite struct{}
+
+var _ = Suite(&CloneSuite{})
+
+func (s *CloneSuite) fixture(c *C) *Memory {
+	fs := New()
+	writeFile(c, fs, "foo", "foo")
+	writeFile(c, fs, "qux/bar", "bar")
+	writeFile(c, fs, "qux/baz", "baz")
+	c.Assert(fs.MkdirAll("empty", 0700), IsNil)
+	c.Assert(fs.Symlink("qux/bar", "link"), IsNil)
+	return fs
+}
+
+func (s *CloneSuite) TestClone(c *C) {
+	fs := s.fixture(c)
+	clone := fs.Clone()
+	c.Assert(tree(c, clone), DeepEquals, tree(c, fs))
+
+	f, err := clone.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	writeFile(c, clone, "new", "new")
+	c.Assert(clone.Rename("qux/baz", "qux/renamed"), IsNil)
+	c.Assert(clone.Remove("empty"), IsNil)
+	c.Assert(clone.Chmod("qux/bar", 0600), IsNil)
+
+	c.Assert(tree(c, fs), DeepEquals, map[string]string{
+		"empty/":  "",
+		"foo":     "foo",
+		"link":    "-> qux/bar",
+		"qux/":    "",
+		"qux/bar": "bar",
+		"qux/baz": "baz",
+	})
+
+	c.Assert(tree(c, clone), DeepEquals, map[string]string{
+		"foo":         "foobar",
+		"link":        "-> qux/bar",
+		"new":         "new",
+		"qux/":        "",
+		"qux/bar":     "bar",
+		"qux/renamed": "baz",
+	})
+
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.FileMode(0666))
+}
+
+func (s *CloneSuite) TestCloneOriginalChanged(c *C) {
+	fs := s.fixture(c)
+	f, err := fs.OpenFile("qux/bar", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+
+	clone := fs.Dir("qux").(*Memory).Clone()
+	c.Assert(clone.Base(), Equals, "/qux")
+
+	_, err = f.Write([]byte("BAR"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(1), IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(fs.Remove("qux/baz"), IsNil)
+	writeFile(c, fs, "qux/new", "new")
+
+	c.Assert(tree(c, clone), DeepEquals, map[string]string{
+		"bar": "bar",
+		"baz": "baz",
+	})
+
+	c.Assert(tree(c, fs.Dir("qux")), DeepEquals, map[string]string{
+		"bar": "B",
+		"new": "new",
+	})
+}
+
+func (s *CloneSuite) TestCloneChanges(c *C) {
+	fs := s.fixture(c)
+	_, cursor, err := fs.Changes(0)
+	c.Assert(err, IsNil)
+
+	clone := fs.Clone()
+	writeFile(c, clone, "new", "new")
+	writeFile(c, fs, "other", "other")
+
+	changes, _, err := clone.Changes(cursor)
+	c.Assert(err, IsNil)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Cursor: cursor + 1, Op: billy.ChangeCreate, Path: "new"},
+	})
+
+	id, err := fs.FileID("foo")
+	c.Assert(err, IsNil)
+	cloneID, err := clone.FileID("foo")
+	c.Assert(err, IsNil)
+	c.Assert(cloneID, Equals, id)
+}
+
+func (s *CloneSuite) TestSnapshot(c *C) {
+	fs := s.fixture(c)
+	snapshot := fs.Snapshot()
+	want := tree(c, fs)
+
+	writeFile(c, fs, "foo", "changed")
+	c.Assert(fs.Remove("link"), IsNil)
+
+	var wg sync.WaitGroup
+	clones := make([]*Memory, 4)
+	for i := range clones {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clones[i] = snapshot.Clone()
+			f, _ := clones[i].Create("qux/bar")
+			f.Write([]byte{byte('0' + i)})
+			f.Close()
+		}(i)
+	}
+
+	wg.Wait()
+	for i, clone := range clones {
+		want["qux/bar"] = string(rune('0' + i))
+		c.Assert(tree(c, clone), DeepEquals, want)
+	}
+
+	c.Assert(tree(c, snapshot.Clone())["qux/bar"], Equals, "bar")
+}
+
+// tree returns the files of fs and their contents, the targets of the
+// symbolic links and the directories, with a trailing slash.
+func tree(c *C, fs billy.Filesystem) map[string]string {
+	files := make(map[string]string)
+	err := billy.Walk(fs, "", func(path string, info billy.FileInfo, err error) error {
+		c.Assert(err, IsNil)
+		switch {
+		case path == "":
+		case info.IsDir():
+			files[path+"/"] = ""
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := fs.Readlink(path)
+			c.Assert(err, IsNil)
+			files[path] = "-> " + target
+		default:
+			data, _, err := fs.(*Memory).ReadFileVersion(path)
+			c.Assert(err, IsNil)
+			files[path] = string(data)
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return files
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"os"
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ReadFileVersion returns the content of the named file and its version, a
+// counter of the storage increased on every modification of any file, so a
+// version is never repeated, even by a file removed and created again.
+func (fs *Memory) ReadFileVersion(filename string) ([]byte, string, error) {
+	fs.s.conditional.Lock()
+	defer fs.s.conditional.Unlock()
+
+	f, err := fs.lookup(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := make([]byte, f.content.Len())
+	f.content.ReadAt(data, 0)
+	return data, f.content.Version(), nil
+}
+
+// WriteFileIf replaces the content of the named file if the precondition
+// holds. IfUnmodifiedSince is compared against the stored modification time,
+// truncated to the time resolution, it fails if the file doesn't exist. The
+// conditional operations are atomic between them, not with the rest.
+func (fs *Memory) WriteFileIf(filename string, data []byte, cond billy.Precondition) (string, error) {
+	fs.s.conditional.Lock()
+	defer fs.s.conditional.Unlock()
+
+	f, err := fs.lookup(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if !holds(f, cond) {
+		return "", billy.ErrPreconditionFailed
+	}
+
+	nf, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := nf.Write(data); err != nil {
+		nf.Close()
+		return "", err
+	}
+
+	if err := nf.Close(); err != nil {
+		return "", err
+	}
+
+	return nf.(*file).content.Version(), nil
+}
+
+// RemoveFileIf removes the named file if the precondition holds, the
+// symbolic links are removed, not their targets.
+func (fs *Memory) RemoveFileIf(filename string, cond billy.Precondition) error {
+	fs.s.conditional.Lock()
+	defer fs.s.conditional.Unlock()
+
+	fullpath, err := fs.resolve(filename, false)
+	if err != nil {
+		return err
+	}
+
+	f := fs.s.files[fs.key(fullpath)]
+	if !holds(f, cond) {
+		return billy.ErrPreconditionFailed
+	}
+
+	return fs.Remove(filename)
+}
+
+// holds returns true if cond holds for f, nil if the file doesn't exist.
+func holds(f *file, cond billy.Precondition) bool {
+	switch {
+	case f != nil && cond.IfNotExist:
+		return false
+	case cond.IfMatch != "" && (f == nil || f.content.Version() != cond.IfMatch):
+		return false
+	case !cond.IfUnmodifiedSince.IsZero() && (f == nil || f.content.modTime.After(cond.IfUnmodifiedSince)):
+		return false
+	}
+
+	return true
+}
+
+// Version returns the current version of the content.
+func (c *content) Version() string {
+	return strconv.FormatUint(c.version, 10)
+}
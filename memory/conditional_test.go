@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type ConditionalSuite struct{}
+
+var _ = Suite(&ConditionalSuite{})
+
+func (s *ConditionalSuite) TestWriteFileIf(c *C) {
+	fs := New()
+	v1, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{IfNotExist: true})
+	c.Assert(err, IsNil)
+
+	_, err = fs.WriteFileIf("foo", []byte("bar"), billy.Precondition{IfNotExist: true})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+
+	data, version, err := fs.ReadFileVersion("foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo")
+	c.Assert(version, Equals, v1)
+
+	v2, err := fs.WriteFileIf("foo", []byte("bar"), billy.Precondition{IfMatch: v1})
+	c.Assert(err, IsNil)
+	c.Assert(v2, Not(Equals), v1)
+
+	_, err = fs.WriteFileIf("foo", []byte("qux"), billy.Precondition{IfMatch: v1})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+
+	data, version, err = fs.ReadFileVersion("foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "bar")
+	c.Assert(version, Equals, v2)
+}
+
+func (s *ConditionalSuite) TestWriteFileIfVersionChangesOnWrite(c *C) {
+	fs := New()
+	v1, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{})
+	c.Assert(err, IsNil)
+
+	f, err := fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = fs.WriteFileIf("foo", []byte("qux"), billy.Precondition{IfMatch: v1})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+}
+
+func (s *ConditionalSuite) TestWriteFileIfMatchNotExist(c *C) {
+	fs := New()
+	_, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{IfMatch: "1"})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+}
+
+func (s *ConditionalSuite) TestWriteFileIfUnmodifiedSince(c *C) {
+	fs := New()
+	since := time.Now()
+	_, err := fs.WriteFileIf("foo", nil, billy.Precondition{IfUnmodifiedSince: since})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+
+	_, err = fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{})
+	c.Assert(err, IsNil)
+	mtime := since.Add(-time.Hour)
+	c.Assert(fs.Chtimes("foo", mtime, mtime), IsNil)
+
+	_, err = fs.WriteFileIf("foo", []byte("bar"), billy.Precondition{IfUnmodifiedSince: since})
+	c.Assert(err, IsNil)
+
+	_, err = fs.WriteFileIf("foo", []byte("qux"), billy.Precondition{IfUnmodifiedSince: mtime})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+
+	data, _, err := fs.ReadFileVersion("foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "bar")
+}
+
+func (s *ConditionalSuite) TestWriteFileIfRecreated(c *C) {
+	fs := New()
+	v1, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{IfNotExist: true})
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Remove("foo"), IsNil)
+	v2, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{IfNotExist: true})
+	c.Assert(err, IsNil)
+	c.Assert(v2, Not(Equals), v1)
+
+	_, err = fs.WriteFileIf("foo", []byte("bar"), billy.Precondition{IfMatch: v1})
+	c.Assert(err, Equals, billy.ErrPreconditionFailed)
+}
+
+func (s *ConditionalSuite) TestStatVersion(c *C) {
+	fs := New()
+	v1, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{})
+	c.Assert(err, IsNil)
+
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	version, ok := billy.FileVersion(fi)
+	c.Assert(ok, Equals, true)
+	c.Assert(version, Equals, v1)
+
+	v2, err := fs.WriteFileIf("foo", []byte("bar"), billy.Precondition{IfMatch: version})
+	c.Assert(err, IsNil)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	version, _ = billy.FileVersion(infos[0])
+	c.Assert(version, Equals, v2)
+}
+
+func (s *ConditionalSuite) TestRemoveFileIf(c *C) {
+	fs := New()
+	v1, err := fs.WriteFileIf("foo", []byte("foo"), billy.Precondition{IfNotExist: true})
+	c.Assert(err, IsNil)
+	_, err = fs.WriteFileIf("foo", []byte("bar"), billy.Precondition{IfMatch: v1})
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.RemoveFileIf("foo", billy.Precondition{IfMatch: v1}), Equals, billy.ErrPreconditionFailed)
+	_, v2, err := fs.ReadFileVersion("foo")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.RemoveFileIf("foo", billy.Precondition{IfMatch: v2}), IsNil)
+	_, err = fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(fs.RemoveFileIf("foo", billy.Precondition{IfMatch: v2}), Equals, billy.ErrPreconditionFailed)
+}
+
+func (s *ConditionalSuite) TestWriteFileIfConcurrent(c *C) {
+	fs := New()
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- billy.UpdateFile(fs, "counter", 100, func(data []byte) ([]byte, error) {
+				n, _ := strconv.Atoi(string(data))
+				return []byte(strconv.Itoa(n + 1)), nil
+			})
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		c.Assert(err, IsNil)
+	}
+
+	data, _, err := fs.ReadFileVersion("counter")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "10")
+}
@@ -0,0 +1,228 @@
+package memory
+
+import (
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// defaultDirPerm are the permissions of the directories created implicitly,
+// and of the root.
+const defaultDirPerm os.FileMode = 0755
+
+// directory holds the names of the entries of a directory, sorted lazily the
+// first time they are listed after an insertion out of order, so creating
+// many files and listing them once costs a single sort. The deleted names are
+// kept as tombstones, dropped at once when listed or when they are the half
+// of the names, so deleting many files doesn't cost a copy of the names each.
+type directory struct {
+	// name is the name of the directory, as first created.
+	name   string
+	names  []string
+	sorted bool
+	// removed holds the names deleted, still in names.
+	removed map[string]bool
+	// files is the number of files and explicit directories in the
+	// subtree, including itself, the directory exists while it's not zero.
+	files int
+	// explicit is true if the directory was created with Mkdir or MkdirAll,
+	// so it exists even while empty.
+	explicit bool
+	perm     os.FileMode
+	// modTime is updated when an entry is added or deleted.
+	modTime time.Time
+	// shared is true if names may be shared with a clone, always sorted, it's
+	// copied before being changed.
+	shared bool
+}
+
+func newDirectory(fullpath string, perm os.FileMode, modTime time.Time) *directory {
+	return &directory{name: path.Base(fullpath), sorted: true, perm: perm, modTime: modTime}
+}
+
+// info returns the FileInfo of the directory, with the given name.
+func (d *directory) info(name string) *fileInfo {
+	return &fileInfo{
+		name:    name,
+		size:    d.len(),
+		isDir:   true,
+		mode:    d.perm,
+		modTime: d.modTime,
+	}
+}
+
+func (d *directory) insert(name string) {
+	if d.removed[name] {
+		delete(d.removed, name)
+		return
+	}
+
+	d.unshare()
+	if n := len(d.names); n != 0 && d.names[n-1] > name {
+		d.sorted = false
+	}
+
+	d.names = append(d.names, name)
+}
+
+func (d *directory) delete(name string) {
+	if d.removed == nil {
+		d.removed = make(map[string]bool)
+	}
+
+	d.removed[name] = true
+	if len(d.removed)*2 >= len(d.names) {
+		d.compact()
+	}
+}
+
+// len returns the number of entries.
+func (d *directory) len() int {
+	return len(d.names) - len(d.removed)
+}
+
+// list returns the names of the entries, sorted.
+func (d *directory) list() []string {
+	d.compact()
+	d.sort()
+	return d.names
+}
+
+// compact drops the deleted names, keeping the order of the others.
+func (d *directory) compact() {
+	if len(d.removed) == 0 {
+		return
+	}
+
+	d.unshare()
+	names := d.names[:0]
+	for _, name := range d.names {
+		if !d.removed[name] {
+			names = append(names, name)
+		}
+	}
+
+	d.names, d.removed = names, nil
+}
+
+// unshare copies the names if they are shared, before changing them.
+func (d *directory) unshare() {
+	if d.shared {
+		d.names = append([]string(nil), d.names...)
+		d.shared = false
+	}
+}
+
+func (d *directory) sort() {
+	if !d.sorted {
+		sort.Strings(d.names)
+		d.sorted = true
+	}
+}
+
+// add stores the file f with the given key, adding its name to the parent
+// directories.
+func (s *storage) add(key string, f *file) {
+	listed := s.exists(key)
+	s.files[key] = f
+	s.link(key, f.path, listed)
+}
+
+// mkdir stores the explicit directory with the given key, adding its name to
+// the parent directories. The permissions of an existing directory are not
+// changed.
+func (s *storage) mkdir(key, fullpath string, perm os.FileMode) {
+	listed := s.exists(key)
+	d, ok := s.dirs[key]
+	if !ok {
+		d = newDirectory(fullpath, perm, s.now())
+		s.dirs[key] = d
+	}
+
+	if d.explicit {
+		return
+	}
+
+	d.explicit = true
+	d.files++
+	s.link(key, fullpath, listed)
+	if !listed {
+		s.record(billy.ChangeCreate, fullpath, "")
+	}
+}
+
+// link counts a new entry with the given key in the parent directories,
+// creating them if needed, its name is inserted unless already listed.
+func (s *storage) link(key, name string, listed bool) {
+	for child := key; child != string(separator); {
+		parent, parentName := path.Dir(child), path.Dir(name)
+		parentListed := s.exists(parent)
+
+		d, ok := s.dirs[parent]
+		if !ok {
+			d = newDirectory(parentName, defaultDirPerm, s.now())
+			s.dirs[parent] = d
+		}
+
+		if !listed {
+			d.insert(path.Base(name))
+			d.modTime = s.now()
+		}
+
+		d.files++
+		listed, child, name = parentListed, parent, parentName
+	}
+}
+
+// remove deletes the file with the given key, the parent directories left
+// empty are removed.
+func (s *storage) remove(key string) {
+	name := path.Base(s.files[key].path)
+	delete(s.files, key)
+	s.unlink(key, name)
+}
+
+// rmdir deletes the explicit directory with the given key, which must be
+// empty.
+func (s *storage) rmdir(key string) {
+	d := s.dirs[key]
+	d.explicit = false
+	if d.files--; d.files == 0 {
+		delete(s.dirs, key)
+	}
+
+	s.unlink(key, d.name)
+}
+
+// unlink discounts the entry removed with the given key from the parent
+// directories, the ones left empty are removed.
+func (s *storage) unlink(key, name string) {
+	gone := !s.exists(key)
+	for child := key; child != string(separator); {
+		parent := path.Dir(child)
+		d := s.dirs[parent]
+		if gone {
+			d.delete(name)
+			d.modTime = s.now()
+		}
+
+		if d.files--; d.files == 0 {
+			delete(s.dirs, parent)
+		}
+
+		gone, child, name = !s.exists(parent), parent, d.name
+	}
+}
+
+// exists returns true if there is a file or a directory with the given key.
+func (s *storage) exists(key string) bool {
+	if _, ok := s.files[key]; ok {
+		return true
+	}
+
+	_, ok := s.dirs[key]
+	return ok
+}
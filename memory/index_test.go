@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	. "gopkg.in/check.v1"
+)
+
+type IndexSuite struct{}
+
+var _ = Suite(&IndexSuite{})
+
+func (s *IndexSuite) TestReadDirSorted(c *C) {
+	fs := New()
+	var names []string
+	for i := 1000; i > 0; i-- {
+		name := fmt.Sprintf("%04d", i*7%1000)
+		_, err := fs.Create("qux/" + name)
+		c.Assert(err, IsNil)
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	infos, err := fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, len(names))
+	for i, fi := range infos {
+		c.Assert(fi.Name(), Equals, names[i])
+	}
+}
+
+func (s *IndexSuite) TestRemoveEmptiesDirectories(c *C) {
+	fs := New()
+	_, err := fs.Create("foo/bar/baz")
+	c.Assert(err, IsNil)
+	_, err = fs.Create("foo/qux")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Remove("foo/bar/baz"), IsNil)
+	_, err = fs.Stat("foo/bar")
+	c.Assert(err, NotNil)
+	c.Assert(fs.s.dirs, HasLen, 2)
+
+	c.Assert(fs.Rename("foo/qux", "qux"), IsNil)
+	c.Assert(fs.s.dirs, HasLen, 1)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name(), Equals, "qux")
+}
+
+func (s *IndexSuite) TestRenameOverwrite(c *C) {
+	fs := New()
+	_, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Rename("foo", "bar"), IsNil)
+
+	n, err := fs.CountEntries("", 0)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+}
+
+func (s *IndexSuite) TestMkdirAllExplicit(c *C) {
+	fs := New()
+	c.Assert(fs.MkdirAll("foo/bar", 0755), IsNil)
+	_, err := fs.Create("foo/bar/baz")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Remove("foo/bar/baz"), IsNil)
+	c.Assert(fs.s.dirs, HasLen, 3)
+
+	c.Assert(fs.Remove("foo"), NotNil)
+	c.Assert(fs.Remove("foo/bar"), IsNil)
+	c.Assert(fs.Remove("foo"), IsNil)
+	c.Assert(fs.s.dirs, HasLen, 0)
+
+	c.Assert(fs.MkdirAll("", 0755), IsNil)
+	c.Assert(fs.s.dirs, HasLen, 0)
+}
+
+func (s *IndexSuite) TestRemoveMany(c *C) {
+	fs := New()
+	for i := 0; i < 1000; i++ {
+		_, err := fs.Create(fmt.Sprintf("qux/%04d", i))
+		c.Assert(err, IsNil)
+	}
+
+	for i := 0; i < 1000; i += 4 {
+		c.Assert(fs.Remove(fmt.Sprintf("qux/%04d", i)), IsNil)
+	}
+
+	_, err := fs.Create("qux/0000")
+	c.Assert(err, IsNil)
+
+	d := fs.s.dirs["/qux"]
+	c.Assert(d.names, HasLen, 1000)
+	c.Assert(d.removed, HasLen, 249)
+
+	n, err := fs.CountEntries("qux", 0)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 751)
+
+	infos, err := fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 751)
+	c.Assert(infos[0].Name(), Equals, "0000")
+	c.Assert(infos[1].Name(), Equals, "0001")
+	c.Assert(infos[750].Name(), Equals, "0999")
+	c.Assert(d.removed, HasLen, 0)
+}
+
+func (s *IndexSuite) TestRemoveClone(c *C) {
+	fs := New()
+	for _, name := range []string{"foo", "bar", "baz", "qux"} {
+		_, err := fs.Create(name)
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(fs.Remove("foo"), IsNil)
+	clone := fs.Clone()
+	c.Assert(fs.Remove("bar"), IsNil)
+	c.Assert(clone.Remove("qux"), IsNil)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].Name(), Equals, "baz")
+	c.Assert(infos[1].Name(), Equals, "qux")
+
+	infos, err = clone.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].Name(), Equals, "bar")
+	c.Assert(infos[1].Name(), Equals, "baz")
+}
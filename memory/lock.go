@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"sync"
+
+	"srcd.works/go-billy.v1"
+)
+
+// locks is the table of the locks held on the contents of a storage, the
+// channel of a content is closed when its lock is released.
+type locks struct {
+	sync.Mutex
+	held map[*content]chan struct{}
+}
+
+func (l *locks) lock(c *content) {
+	for {
+		l.Lock()
+		released, ok := l.held[c]
+		if !ok {
+			if l.held == nil {
+				l.held = make(map[*content]chan struct{})
+			}
+
+			l.held[c] = make(chan struct{})
+			l.Unlock()
+			return
+		}
+
+		l.Unlock()
+		<-released
+	}
+}
+
+func (l *locks) unlock(c *content) {
+	l.Lock()
+	defer l.Unlock()
+
+	close(l.held[c])
+	delete(l.held, c)
+}
+
+// Lock acquires an exclusive lock on the file, blocking until the lock held
+// by any other open file of the same filesystem is released. Locking a file
+// already locked by f does nothing.
+func (f *file) Lock() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	if f.locked {
+		return nil
+	}
+
+	f.s.locks.lock(f.content)
+	f.locked = true
+	return nil
+}
+
+// Unlock releases the lock held by f, if any.
+func (f *file) Unlock() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	if f.locked {
+		f.s.locks.unlock(f.content)
+		f.locked = false
+	}
+
+	return nil
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"srcd.works/go-billy.v1"
@@ -15,24 +18,43 @@ import (
 
 const separator = '/'
 
-// Memory a very convenient filesystem based on memory files
+var (
+	errNotLink        = errors.New("not a symbolic link")
+	errNotDirectory   = syscall.ENOTDIR
+	errIsDirectory    = syscall.EISDIR
+	errNotEmpty       = errors.New("directory not empty")
+	errNegativeSize   = errors.New("negative size")
+	errNegativeOffset = errors.New("negative offset")
+	errInvalidWhence  = errors.New("invalid whence")
+)
+
+// Memory a very convenient filesystem based on memory files. The files are
+// sparse, the holes left writing past the end or growing them with Truncate
+// aren't allocated, and are reported by the Extents method of the files, as
+// billy.Sparse.
 type Memory struct {
 	base      string
 	s         *storage
 	tempCount int
+	opts      Options
 }
 
-//New returns a new Memory filesystem
+// New returns a new Memory filesystem
 func New() *Memory {
 	return &Memory{
 		base: "/",
-		s:    &storage{make(map[string]*file, 0)},
+		s: &storage{
+			files:    make(map[string]*file, 0),
+			dirs:     make(map[string]*directory, 0),
+			clock:    time.Now,
+			rootTime: time.Now(),
+		},
 	}
 }
 
 // Create returns a new file in memory from a given filename.
 func (fs *Memory) Create(filename string) (billy.File, error) {
-	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0)
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 }
 
 // Open returns a readonly file from a given name.
@@ -41,20 +63,55 @@ func (fs *Memory) Open(filename string) (billy.File, error) {
 }
 
 // OpenFile returns the file from a given name with given flag and permits.
+// The symbolic links are followed, except the last element of the path when
+// created with os.O_EXCL. Opening a directory for writing fails with
+// syscall.EISDIR, and creating a file under a regular file with
+// syscall.ENOTDIR.
 func (fs *Memory) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
-	fullpath := fs.Join(fs.base, filename)
-	f, ok := fs.s.files[fullpath]
+	fullpath, err := fs.resolve(filename, !(isCreate(flag) && isExclusive(flag)))
+	if err != nil {
+		return nil, err
+	}
+
+	key := fs.key(fullpath)
+	f, ok := fs.s.files[key]
 
 	if !ok && !isCreate(flag) {
 		return nil, os.ErrNotExist
 	}
 
+	if ok && isCreate(flag) && isExclusive(flag) {
+		return nil, os.ErrExist
+	}
+
+	if _, isDir := fs.s.dirs[key]; isDir || fs.isRoot(key) {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDirectory}
+	}
+
 	if f == nil {
-		fs.s.files[fullpath] = newFile(fs.base, fullpath, flag)
-		return fs.s.files[fullpath], nil
+		if err := fs.validate(fullpath); err != nil {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+		}
+
+		if fs.underFile(key) {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: errNotDirectory}
+		}
+
+		if fs.s.full() {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.ENOSPC}
+		}
+
+		f = newFile(fs, fullpath, flag)
+		f.content.perm = perm.Perm()
+		fs.s.lastID++
+		f.id = fs.s.lastID
+		fs.s.touch(f.content)
+		fs.s.add(key, f)
+		fs.s.record(billy.ChangeCreate, fullpath, "")
+		return f, nil
 	}
 
-	n := newFile(fs.base, fullpath, flag)
+	n := newFile(fs, f.path, flag)
 	n.content = f.content
 
 	if isAppend(flag) {
@@ -62,111 +119,356 @@ func (fs *Memory) OpenFile(filename string, flag int, perm os.FileMode) (billy.F
 	}
 
 	if isTruncate(flag) {
-		n.content.Truncate()
+		fs.s.resize(n.content, 0)
+		fs.s.touch(n.content)
+		fs.s.record(billy.ChangeWrite, f.path, "")
 	}
 
 	return n, nil
 }
 
-// Stat returns a billy.FileInfo with the information of the requested file.
+// Stat returns a billy.FileInfo with the information of the requested file,
+// following the symbolic links.
 func (fs *Memory) Stat(filename string) (billy.FileInfo, error) {
-	fullpath := fs.Join(fs.base, filename)
+	fullpath, err := fs.resolve(filename, true)
+	if err != nil {
+		return nil, err
+	}
 
-	if _, ok := fs.s.files[filename]; ok {
-		return newFileInfo(fs.base, fullpath, fs.s.files[filename].content.Len()), nil
+	return fs.stat(fullpath)
+}
+
+func (fs *Memory) stat(fullpath string) (billy.FileInfo, error) {
+	key := fs.key(fullpath)
+
+	if f, ok := fs.s.files[key]; ok {
+		return f.info(path.Base(f.path)), nil
 	}
 
-	info, err := fs.ReadDir(filename)
-	if err == nil && len(info) != 0 {
-		return newFileInfo(fs.base, fullpath, len(info)), nil
+	d, ok := fs.s.dirs[key]
+	if !ok && !fs.isRoot(key) {
+		return nil, os.ErrNotExist
 	}
 
-	return nil, os.ErrNotExist
+	if ok {
+		return d.info(d.name), nil
+	}
+
+	return newDirectory(fullpath, defaultDirPerm, fs.s.rootTime).info(path.Base(fullpath)), nil
 }
 
-// ReadDir returns a list of billy.FileInfo in the given directory.
-func (fs *Memory) ReadDir(base string) (entries []billy.FileInfo, err error) {
-	base = fs.Join(fs.base, base)
+// ReadDir returns a list of billy.FileInfo in the given directory, sorted by
+// name.
+func (fs *Memory) ReadDir(dir string) (entries []billy.FileInfo, err error) {
+	fullpath, err := fs.resolve(dir, true)
+	if err != nil {
+		return nil, err
+	}
 
-	appendedDirs := make(map[string]bool, 0)
-	for fullpath, f := range fs.s.files {
-		if !strings.HasPrefix(fullpath, base) {
-			continue
+	base := fs.key(fullpath)
+	d, ok := fs.s.dirs[base]
+	if !ok {
+		// directories only exist while they contain files, except the root.
+		if !fs.isRoot(base) {
+			return nil, os.ErrNotExist
 		}
 
-		fullpath, _ = filepath.Rel(base, fullpath)
-		parts := strings.Split(fullpath, string(separator))
+		return nil, nil
+	}
 
-		if len(parts) == 1 {
-			entries = append(entries, &fileInfo{name: parts[0], size: f.content.Len()})
+	for _, name := range d.list() {
+		key := fs.key(path.Join(base, name))
+		if f, ok := fs.s.files[key]; ok {
+			entries = append(entries, f.info(name))
 			continue
 		}
 
-		if _, ok := appendedDirs[parts[0]]; ok {
-			continue
+		entries = append(entries, fs.s.dirs[key].info(name))
+	}
+
+	return
+}
+
+// CountEntries returns the number of entries in the given directory, stopping
+// at limit if it's greater than zero.
+func (fs *Memory) CountEntries(dir string, limit int) (int, error) {
+	fullpath, err := fs.resolve(dir, true)
+	if err != nil {
+		return 0, err
+	}
+
+	base := fs.key(fullpath)
+	d, ok := fs.s.dirs[base]
+	if !ok {
+		if !fs.isRoot(base) {
+			return 0, os.ErrNotExist
 		}
 
-		entries = append(entries, &fileInfo{name: parts[0], isDir: true})
-		appendedDirs[parts[0]] = true
+		return 0, nil
 	}
 
-	return
+	n := d.len()
+	if limit > 0 && n > limit {
+		n = limit
+	}
+
+	return n, nil
 }
 
 var maxTempFiles = 1024 * 4
 
 // TempFile creates a new temporary file.
 func (fs *Memory) TempFile(dir, prefix string) (billy.File, error) {
-	var fullpath string
+	var filename string
 	for {
 		if fs.tempCount >= maxTempFiles {
 			return nil, errors.New("max. number of tempfiles reached")
 		}
 
-		fullpath = fs.getTempFilename(dir, prefix)
-		if _, ok := fs.s.files[fullpath]; !ok {
+		filename = fs.getTempFilename(dir, prefix)
+		if _, ok := fs.s.files[fs.key(fs.fullpath(filename))]; !ok {
 			break
 		}
 	}
 
-	return fs.Create(fullpath)
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 }
 
 func (fs *Memory) getTempFilename(dir, prefix string) string {
 	fs.tempCount++
 	filename := fmt.Sprintf("%s_%d_%d", prefix, fs.tempCount, time.Now().UnixNano())
-	return fs.Join(fs.base, dir, filename)
+	return fs.Join(dir, filename)
 }
 
-// Rename moves a the `from` file to the `to` file.
+// Rename moves a the `from` file to the `to` file, symbolic links are renamed
+// themselves, not their targets.
 func (fs *Memory) Rename(from, to string) error {
-	from = fs.Join(fs.base, from)
-	to = fs.Join(fs.base, to)
+	from, err := fs.resolve(from, false)
+	if err != nil {
+		return err
+	}
 
-	if _, ok := fs.s.files[from]; !ok {
+	to, err = fs.resolve(to, false)
+	if err != nil {
+		return err
+	}
+
+	f, ok := fs.s.files[fs.key(from)]
+	if !ok {
 		return os.ErrNotExist
 	}
 
-	fs.s.files[to] = fs.s.files[from]
-	fs.s.files[to].BaseFilename = to
-	delete(fs.s.files, from)
+	if err := fs.validate(to); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	key := fs.key(to)
+	if _, isDir := fs.s.dirs[key]; isDir || fs.isRoot(key) {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: errIsDirectory}
+	}
+
+	if fs.underFile(key) {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: errNotDirectory}
+	}
+
+	from = f.path
+	fs.s.remove(fs.key(from))
+	if replaced, ok := fs.s.files[fs.key(to)]; ok {
+		fs.s.release(replaced.content)
+		fs.s.remove(fs.key(to))
+	}
+
+	f.BaseFilename = fs.name(to)
+	f.path = to
+	fs.s.add(fs.key(to), f)
+	fs.s.record(billy.ChangeRename, to, from)
 
 	return nil
 }
 
-// Remove deletes a given file from storage.
+// Remove deletes a given file from storage, symbolic links are removed
+// themselves, not their targets. Only empty directories created with Mkdir
+// or MkdirAll can be removed, the others exist while they contain files.
 func (fs *Memory) Remove(filename string) error {
-	fullpath := fs.Join(fs.base, filename)
-	if _, ok := fs.s.files[fullpath]; !ok {
+	fullpath, err := fs.resolve(filename, false)
+	if err != nil {
+		return err
+	}
+
+	key := fs.key(fullpath)
+	if d, ok := fs.s.dirs[key]; ok {
+		if d.len() != 0 || !d.explicit {
+			return &os.PathError{Op: "remove", Path: filename, Err: errNotEmpty}
+		}
+
+		fs.s.rmdir(key)
+		fs.s.record(billy.ChangeRemove, fullpath, "")
+		return nil
+	}
+
+	f, ok := fs.s.files[key]
+	if !ok {
 		return os.ErrNotExist
 	}
 
-	delete(fs.s.files, fullpath)
+	fs.s.release(f.content)
+	fs.s.remove(key)
+	fs.s.record(billy.ChangeRemove, f.path, "")
+	return nil
+}
+
+// Chmod changes the permissions of the named file, following the symbolic
+// links. Only the permission bits of mode are stored.
+func (fs *Memory) Chmod(name string, mode os.FileMode) error {
+	fullpath, err := fs.resolve(name, true)
+	if err != nil {
+		return err
+	}
+
+	key := fs.key(fullpath)
+	if f, ok := fs.s.files[key]; ok {
+		f.content.perm = mode.Perm()
+		fs.s.record(billy.ChangeMetadata, f.path, "")
+		return nil
+	}
+
+	if d, ok := fs.s.dirs[key]; ok {
+		d.perm = mode.Perm()
+		fs.s.record(billy.ChangeMetadata, fullpath, "")
+		return nil
+	}
+
+	if fs.isRoot(key) {
+		return &os.PathError{Op: "chmod", Path: name, Err: billy.ErrNotSupported}
+	}
+
+	return os.ErrNotExist
+}
+
+// Chtimes changes the modification time of the named file, following the
+// symbolic links, truncated to the time resolution. The access times are not
+// stored, atime is ignored.
+func (fs *Memory) Chtimes(name string, atime, mtime time.Time) error {
+	fullpath, err := fs.resolve(name, true)
+	if err != nil {
+		return err
+	}
+
+	mtime = billy.TruncateTime(mtime, fs.s.resolution)
+	key := fs.key(fullpath)
+	if f, ok := fs.s.files[key]; ok {
+		f.content.modTime = mtime
+		fs.s.record(billy.ChangeMetadata, f.path, "")
+		return nil
+	}
+
+	if d, ok := fs.s.dirs[key]; ok {
+		d.modTime = mtime
+		fs.s.record(billy.ChangeMetadata, fullpath, "")
+		return nil
+	}
+
+	if fs.isRoot(key) {
+		return &os.PathError{Op: "chtimes", Path: name, Err: billy.ErrNotSupported}
+	}
+
+	return os.ErrNotExist
+}
+
+// MkdirAll creates the directory path and all its parents, the directories
+// created exist even while empty.
+func (fs *Memory) MkdirAll(filename string, perm os.FileMode) error {
+	fullpath, err := fs.resolve(filename, true)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.validate(fullpath); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filename, Err: err}
+	}
+
+	dir := string(separator)
+	for _, part := range split(fullpath) {
+		dir = path.Join(dir, part)
+		key := fs.key(dir)
+		if fs.isRoot(key) {
+			continue
+		}
+
+		if _, ok := fs.s.files[key]; ok {
+			return &os.PathError{Op: "mkdir", Path: filename, Err: errNotDirectory}
+		}
+
+		fs.s.mkdir(key, dir, perm.Perm())
+	}
+
+	return nil
+}
+
+// Mkdir creates the named directory, empty, failing if it already exists or
+// its parent doesn't, as billy.Mkdirer. The directory exists until removed,
+// even while empty, as the ones created with MkdirAll.
+func (fs *Memory) Mkdir(filename string, perm os.FileMode) error {
+	fullpath, err := fs.resolve(filename, false)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.validate(fullpath); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filename, Err: err}
+	}
+
+	key := fs.key(fullpath)
+	if fs.isRoot(key) || fs.s.exists(key) {
+		return &os.PathError{Op: "mkdir", Path: filename, Err: os.ErrExist}
+	}
+
+	if parent := path.Dir(key); !fs.isRoot(parent) {
+		if _, ok := fs.s.files[parent]; ok {
+			return &os.PathError{Op: "mkdir", Path: filename, Err: errNotDirectory}
+		}
+
+		if _, ok := fs.s.dirs[parent]; !ok {
+			return &os.PathError{Op: "mkdir", Path: filename, Err: os.ErrNotExist}
+		}
+	}
+
+	fs.s.mkdir(key, fullpath, perm.Perm())
 	return nil
 }
 
+// FileID returns an identifier of the named file, unique in the storage and
+// preserved on renames.
+func (fs *Memory) FileID(filename string) (string, error) {
+	f, err := fs.lookup(filename)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(f.id, 10), nil
+}
+
+// lookup returns the named file, following the symbolic links.
+func (fs *Memory) lookup(filename string) (*file, error) {
+	fullpath, err := fs.resolve(filename, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := fs.s.files[fs.key(fullpath)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return f, nil
+}
+
 // Join concatenatess part of a path together.
 func (fs *Memory) Join(elem ...string) string {
+	if fs.opts.Windows {
+		return joinWindows(elem...)
+	}
+
 	return filepath.Join(elem...)
 }
 
@@ -174,38 +476,80 @@ func (fs *Memory) Join(elem ...string) string {
 // filesystem.
 func (fs *Memory) Dir(path string) billy.Filesystem {
 	return &Memory{
-		base: fs.Join(fs.base, path),
+		base: fs.fullpath(path),
 		s:    fs.s,
+		opts: fs.opts,
 	}
 }
 
 // Base returns the base path for the filesystem.
 func (fs *Memory) Base() string {
+	if fs.opts.Windows {
+		return baseWindows(fs.base)
+	}
+
 	return fs.base
 }
 
 type file struct {
 	billy.BaseFile
 
+	s        *storage
+	id       uint64
+	path     string
 	content  *content
 	position int64
 	flag     int
+	// target is the target of the entry if it's a symbolic link, links have
+	// no content.
+	target string
+	// locked is true if f holds the lock of the content.
+	locked bool
 }
 
-func newFile(base, fullpath string, flag int) *file {
-	filename, _ := filepath.Rel(base, fullpath)
-
+func newFile(fs *Memory, fullpath string, flag int) *file {
 	return &file{
-		BaseFile: billy.BaseFile{BaseFilename: filename},
+		BaseFile: billy.BaseFile{BaseFilename: fs.name(fullpath)},
+		s:        fs.s,
+		path:     fullpath,
 		content:  &content{},
 		flag:     flag,
 	}
 }
 
+// info returns the FileInfo of the entry, with the given name.
+func (f *file) info(name string) *fileInfo {
+	if f.target != "" {
+		return &fileInfo{
+			name:    name,
+			size:    len(f.target),
+			mode:    os.ModeSymlink | 0777,
+			modTime: f.content.modTime,
+		}
+	}
+
+	return &fileInfo{
+		name:    name,
+		size:    f.content.Len(),
+		mode:    f.content.perm,
+		version: f.content.Version(),
+		modTime: f.content.modTime,
+	}
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	if f.IsClosed() {
+		return nil, billy.ErrClosed
+	}
+
+	return f.info(path.Base(f.path)), nil
+}
+
 func (f *file) Read(b []byte) (int, error) {
 	n, err := f.ReadAt(b, f.position)
-	if err != nil {
-		return 0, err
+	f.position += int64(n)
+	if err == io.EOF && n != 0 {
+		err = nil
 	}
 
 	return n, err
@@ -220,10 +564,11 @@ func (f *file) ReadAt(b []byte, off int64) (int, error) {
 		return 0, errors.New("read not supported")
 	}
 
-	n, err := f.content.ReadAt(b, off)
-	f.position += int64(n)
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: f.Filename(), Err: errNegativeOffset}
+	}
 
-	return n, err
+	return f.content.ReadAt(b, off)
 }
 
 func (f *file) Seek(offset int64, whence int) (int64, error) {
@@ -233,13 +578,19 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 
 	switch whence {
 	case io.SeekCurrent:
-		f.position += offset
+		offset += f.position
 	case io.SeekStart:
-		f.position = offset
 	case io.SeekEnd:
-		f.position = int64(f.content.Len()) - offset
+		offset += int64(f.content.Len())
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.Filename(), Err: errInvalidWhence}
 	}
 
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.Filename(), Err: errNegativeOffset}
+	}
+
+	f.position = offset
 	return f.position, nil
 }
 
@@ -252,17 +603,61 @@ func (f *file) Write(p []byte) (int, error) {
 		return 0, errors.New("write not supported")
 	}
 
-	n, err := f.content.WriteAt(p, f.position)
+	p, fits := f.s.fit(f.content, f.position, p)
+	if !fits && len(p) == 0 {
+		return 0, &os.PathError{Op: "write", Path: f.Filename(), Err: syscall.ENOSPC}
+	}
+
+	n, err := f.s.writeAt(f.content, p, f.position)
+	if err == nil && !fits {
+		err = &os.PathError{Op: "write", Path: f.Filename(), Err: syscall.ENOSPC}
+	}
+
 	f.position += int64(n)
+	f.s.touch(f.content)
+	f.s.record(billy.ChangeWrite, f.path, "")
 
 	return n, err
 }
 
+func (f *file) Truncate(size int64) error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	if !isReadAndWrite(f.flag) && !isWriteOnly(f.flag) {
+		return errors.New("truncate not supported")
+	}
+
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.Filename(), Err: errNegativeSize}
+	}
+
+	f.s.resize(f.content, size)
+	f.s.touch(f.content)
+	f.s.record(billy.ChangeWrite, f.path, "")
+	return nil
+}
+
+// Sync does nothing, the content is kept in memory.
+func (f *file) Sync() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	return nil
+}
+
 func (f *file) Close() error {
 	if f.IsClosed() {
 		return errors.New("file already closed")
 	}
 
+	if f.locked {
+		f.s.locks.unlock(f.content)
+		f.locked = false
+	}
+
 	f.Closed = true
 	return nil
 }
@@ -273,18 +668,12 @@ func (f *file) Open() error {
 }
 
 type fileInfo struct {
-	name  string
-	size  int
-	isDir bool
-}
-
-func newFileInfo(base, fullpath string, size int) *fileInfo {
-	filename, _ := filepath.Rel(base, fullpath)
-
-	return &fileInfo{
-		name: filename,
-		size: size,
-	}
+	name    string
+	size    int
+	isDir   bool
+	mode    os.FileMode
+	version string
+	modTime time.Time
 }
 
 func (fi *fileInfo) Name() string {
@@ -296,11 +685,21 @@ func (fi *fileInfo) Size() int64 {
 }
 
 func (fi *fileInfo) Mode() os.FileMode {
-	return os.FileMode(0)
+	if fi.isDir {
+		return os.ModeDir | fi.mode
+	}
+
+	return fi.mode
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
 }
 
-func (*fileInfo) ModTime() time.Time {
-	return time.Now()
+// Version returns the version of the file, the same used by WriteFileIf,
+// directories have no version.
+func (fi *fileInfo) Version() string {
+	return fi.version
 }
 
 func (fi *fileInfo) IsDir() bool {
@@ -312,50 +711,178 @@ func (*fileInfo) Sys() interface{} {
 }
 
 type storage struct {
-	files map[string]*file
-}
-
+	files   map[string]*file
+	dirs    map[string]*directory
+	changes journal
+	lastID  uint64
+	// lastVersion is the last version given to a content, shared by all of
+	// them, so a file removed and created again never repeats a version.
+	lastVersion uint64
+	locks       locks
+	// conditional serializes the conditional operations, so the check of
+	// the precondition and the write are atomic.
+	conditional sync.Mutex
+	// resolution of the modification times.
+	resolution time.Duration
+	// clock returns the current time, skewed by skew.
+	clock func() time.Time
+	skew  time.Duration
+	// rootTime is the modification time of the roots, taken once when the
+	// storage is created.
+	rootTime time.Time
+	// used is the size of the contents of the files, limited by maxSize, and
+	// maxFiles the limit of the number of files.
+	used     int64
+	maxSize  int64
+	maxFiles int
+}
+
+// touch sets the modification time of c to the current time and gives it a
+// new version.
+func (s *storage) touch(c *content) {
+	s.lastVersion++
+	c.version = s.lastVersion
+	c.modTime = s.now()
+}
+
+// now returns the current time of the clock, skewed and truncated to the
+// resolution.
+func (s *storage) now() time.Time {
+	return billy.TruncateTime(s.clock().Add(s.skew), s.resolution)
+}
+
+// content is the content of a file, sparse: only the regions written are
+// held, as extents, the holes in between and up to size read as zeros.
 type content struct {
-	bytes []byte
+	extents []extent
+	size    int64
+	version uint64
+	modTime time.Time
+	perm    os.FileMode
+	// shared is true if the extents may be shared with a clone, they are
+	// copied before being changed.
+	shared bool
+	// released is true once removed, its size is no longer accounted in the
+	// storage.
+	released bool
+}
+
+// unshare copies the extents if they are shared, before changing them.
+func (c *content) unshare() {
+	if !c.shared {
+		return
+	}
+
+	extents := make([]extent, len(c.extents))
+	for i, e := range c.extents {
+		extents[i] = extent{off: e.off, data: append([]byte(nil), e.data...)}
+	}
+
+	c.extents = extents
+	c.shared = false
 }
 
+// WriteAt writes p at off, merging it with the extents it overlaps or
+// touches, a write past the end leaves a hole before it.
 func (c *content) WriteAt(p []byte, off int64) (int, error) {
-	prev := len(c.bytes)
-	c.bytes = append(c.bytes[:off], p...)
-	if len(c.bytes) < prev {
-		c.bytes = c.bytes[:prev]
+	c.unshare()
+	end := off + int64(len(p))
+	if end > c.size {
+		c.size = end
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	i, j := c.overlapping(off, end)
+	if i == j {
+		c.extents = append(c.extents, extent{})
+		copy(c.extents[i+1:], c.extents[i:])
+		c.extents[i] = extent{off: off, data: append([]byte(nil), p...)}
+		return len(p), nil
+	}
+
+	first, last := c.extents[i], c.extents[j-1]
+	if first.off <= off && first.end() >= end {
+		copy(first.data[off-first.off:], p)
+		return len(p), nil
+	}
+
+	merged := extent{off: off, data: append([]byte(nil), p...)}
+	if first.off <= off {
+		merged = extent{off: first.off, data: append(first.data[:off-first.off], p...)}
+	}
+
+	if last.end() > end {
+		merged.data = append(merged.data, last.data[end-last.off:]...)
 	}
 
+	c.extents[i] = merged
+	c.extents = append(c.extents[:i+1], c.extents[j:]...)
 	return len(p), nil
 }
 
+// ReadAt reads the content at off, the holes read as zeros.
 func (c *content) ReadAt(b []byte, off int64) (int, error) {
-	size := int64(len(c.bytes))
-	if off >= size {
+	if off >= c.size {
 		return 0, io.EOF
 	}
 
-	l := int64(len(b))
-	if off+l > size {
-		l = size - off
+	n := len(b)
+	l := int64(n)
+	if off+l > c.size {
+		l = c.size - off
 	}
 
-	n := copy(b, c.bytes[off:off+l])
-	return n, nil
+	b = b[:l]
+	for k := range b {
+		b[k] = 0
+	}
+
+	i, j := c.overlapping(off, off+l)
+	for _, e := range c.extents[i:j] {
+		if e.off >= off {
+			copy(b[e.off-off:], e.data)
+		} else if e.end() > off {
+			copy(b, e.data[off-e.off:])
+		}
+	}
+
+	if int(l) < n {
+		return int(l), io.EOF
+	}
+
+	return int(l), nil
 }
 
-func (c *content) Truncate() {
-	c.bytes = make([]byte, 0)
+// Truncate resizes the content to size bytes, growing it leaves a hole.
+func (c *content) Truncate(size int64) {
+	c.unshare()
+	c.size = size
+
+	i, _ := c.overlapping(size, size)
+	if i < len(c.extents) && c.extents[i].off < size {
+		c.extents[i].data = c.extents[i].data[:size-c.extents[i].off]
+		i++
+	}
+
+	c.extents = c.extents[:i]
 }
 
+// Len returns the logical size of the content, including the holes.
 func (c *content) Len() int {
-	return len(c.bytes)
+	return int(c.size)
 }
 
 func isCreate(flag int) bool {
 	return flag&os.O_CREATE != 0
 }
 
+func isExclusive(flag int) bool {
+	return flag&os.O_EXCL != 0
+}
+
 func isAppend(flag int) bool {
 	return flag&os.O_APPEND != 0
 }
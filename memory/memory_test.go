@@ -1,9 +1,14 @@
 package memory
 
 import (
+	"fmt"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
 	"srcd.works/go-billy.v1/test"
 )
 
@@ -31,3 +36,257 @@ func (s *MemorySuite) TestTempFileMaxTempFiles(c *C) {
 	c.Assert(err, NotNil)
 	c.Assert(f, IsNil)
 }
+
+func (s *MemorySuite) TestModTime(c *C) {
+	fs := NewWithOptions(Options{TimeResolution: 2 * time.Second})
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	mtime := fi.ModTime()
+	c.Assert(mtime.UnixNano()%int64(2*time.Second), Equals, int64(0))
+	c.Assert(mtime.After(time.Now()), Equals, false)
+
+	time.Sleep(time.Millisecond)
+	fi, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos[0].ModTime().Before(mtime), Equals, false)
+	c.Assert(infos[0].ModTime().UnixNano()%int64(2*time.Second), Equals, int64(0))
+}
+
+func (s *MemorySuite) TestClockOutOfOrder(c *C) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	fs := NewWithOptions(Options{Clock: StepClock(start, -time.Second)})
+	writeFile(c, fs, "foo", "foo")
+	writeFile(c, fs, "bar", "bar")
+
+	foo, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	bar, err := fs.Stat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(foo.ModTime().After(start), Equals, false)
+	c.Assert(bar.ModTime().Before(foo.ModTime()), Equals, true)
+}
+
+func (s *MemorySuite) TestClockTies(c *C) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	fs := NewWithOptions(Options{
+		Clock:          StepClock(start, time.Millisecond),
+		TimeResolution: time.Second,
+	})
+	writeFile(c, fs, "foo", "foo")
+	writeFile(c, fs, "bar", "bar")
+
+	foo, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	bar, err := fs.Stat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(foo.ModTime().Equal(start), Equals, true)
+	c.Assert(bar.ModTime().Equal(start), Equals, true)
+}
+
+func (s *MemorySuite) TestClockSkew(c *C) {
+	fs := NewWithOptions(Options{ClockSkew: time.Hour})
+	writeFile(c, fs, "foo", "foo")
+
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().After(time.Now().Add(59*time.Minute)), Equals, true)
+
+	mtime := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.Assert(fs.Chtimes("foo", mtime, mtime), IsNil)
+	fi, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+}
+
+func (s *MemorySuite) TestStepClock(c *C) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := StepClock(start, time.Second, -2*time.Second, 0)
+
+	var got []time.Time
+	for i := 0; i < 5; i++ {
+		got = append(got, clock())
+	}
+
+	c.Assert(got, DeepEquals, []time.Time{
+		start,
+		start.Add(time.Second),
+		start.Add(-time.Second),
+		start.Add(-time.Second),
+		start,
+	})
+
+	clock = StepClock(start)
+	c.Assert(clock(), Equals, start)
+	c.Assert(clock(), Equals, start)
+}
+
+func (s *MemorySuite) TestRootModTime(c *C) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	fs := NewWithOptions(Options{Clock: StepClock(start, time.Second)})
+	for i := 0; i < 3; i++ {
+		fi, err := fs.Stat("")
+		c.Assert(err, IsNil)
+		c.Assert(fi.ModTime().Equal(start), Equals, true)
+	}
+
+	writeFile(c, fs, "foo", "foo")
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().After(start), Equals, true)
+}
+
+func (s *MemorySuite) TestDirModTime(c *C) {
+	fs := New()
+	c.Assert(fs.MkdirAll("qux", 0755), IsNil)
+
+	fi, err := fs.Stat("qux")
+	c.Assert(err, IsNil)
+	mtime := fi.ModTime()
+	c.Assert(mtime.IsZero(), Equals, false)
+
+	time.Sleep(time.Millisecond)
+	fi, err = fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+	_, err = fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	fi, err = fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().After(mtime), Equals, true)
+}
+
+func (s *MemorySuite) TestOpenFileDirectory(c *C) {
+	fs := New()
+	c.Assert(fs.MkdirAll("qux/bar", 0755), IsNil)
+
+	_, err := fs.OpenFile("qux/bar", os.O_WRONLY|os.O_CREATE, 0666)
+	c.Assert(err, DeepEquals, &os.PathError{Op: "open", Path: "qux/bar", Err: syscall.EISDIR})
+
+	_, err = fs.Create("")
+	c.Assert(err, DeepEquals, &os.PathError{Op: "open", Path: "", Err: syscall.EISDIR})
+
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+}
+
+func (s *MemorySuite) TestCreateUnderFile(c *C) {
+	fs := New()
+	f, err := fs.Create("qux")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = fs.Create("qux/bar/foo")
+	c.Assert(err, DeepEquals, &os.PathError{Op: "open", Path: "qux/bar/foo", Err: syscall.ENOTDIR})
+
+	err = fs.Symlink("qux", "qux/link")
+	c.Assert(err, DeepEquals, &os.LinkError{Op: "symlink", Old: "qux", New: "qux/link", Err: syscall.ENOTDIR})
+
+	fi, err := fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, false)
+}
+
+func (s *MemorySuite) TestRenameOntoDirectory(c *C) {
+	fs := New()
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(fs.MkdirAll("qux", 0755), IsNil)
+
+	err = fs.Rename("foo", "qux")
+	c.Assert(err, DeepEquals, &os.LinkError{Op: "rename", Old: "/foo", New: "/qux", Err: syscall.EISDIR})
+
+	err = fs.Rename("foo", "foo/bar")
+	c.Assert(err, DeepEquals, &os.LinkError{Op: "rename", Old: "/foo", New: "/foo/bar", Err: syscall.ENOTDIR})
+
+	fi, err := fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *MemorySuite) TestSymlinkTooManyLinks(c *C) {
+	fs := New()
+	for i := 0; i <= billy.DefaultMaxLinks; i++ {
+		c.Assert(fs.Symlink(fmt.Sprintf("link%d", i+1), fmt.Sprintf("link%d", i)), IsNil)
+	}
+
+	f, err := fs.Create(fmt.Sprintf("link%d", billy.DefaultMaxLinks+1))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = fs.Stat("link1")
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("link0")
+	c.Assert(err, Equals, billy.ErrTooManyLinks)
+}
+
+func (s *MemorySuite) TestSymlinkMaxLinks(c *C) {
+	fs := NewWithOptions(Options{MaxLinks: 2})
+	c.Assert(billy.MaxLinks(fs.Dir("qux")), Equals, 2)
+	for i := 0; i < 3; i++ {
+		c.Assert(fs.Symlink(fmt.Sprintf("link%d", i+1), fmt.Sprintf("link%d", i)), IsNil)
+	}
+
+	f, err := fs.Create("link3")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = fs.Stat("link1")
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("link0")
+	c.Assert(err, Equals, billy.ErrTooManyLinks)
+}
+
+func (s *MemorySuite) TestSymlinkAbsoluteDir(c *C) {
+	fs := New()
+	f, err := fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	dir := fs.Dir("bar")
+	c.Assert(dir.Symlink("/qux/foo", "link"), IsNil)
+
+	_, err = dir.Stat("link")
+	c.Assert(err, IsNil)
+}
+
+func (s *MemorySuite) TestDirDotDot(c *C) {
+	fs := New()
+	writeFile(c, fs, "secret", "secret")
+
+	dir := fs.Dir("jail")
+	_, err := dir.Open("../secret")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	writeFile(c, dir, "../foo", "foo")
+	_, err = fs.Stat("jail/foo")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Dir("../jail").Base(), Equals, dir.Base())
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
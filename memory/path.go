@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+	"srcd.works/go-billy.v1"
+)
+
+// Options configures a Memory filesystem.
+type Options struct {
+	// Windows emulates the Windows path semantics: both backslashes and
+	// slashes are accepted as separators and Join uses backslashes, the paths
+	// may start with a drive letter, being C: the drive of the root, and the
+	// names are case-insensitive but case-preserving. Absolute paths with a
+	// drive letter are resolved from the root of the storage, even on the
+	// filesystems returned by Dir.
+	Windows bool
+	// CaseInsensitive makes the names case-insensitive but case-preserving,
+	// it's implied by Windows.
+	CaseInsensitive bool
+	// NormalizationInsensitive makes the names equivalent under Unicode
+	// canonical equivalence refer to the same file, as in APFS, preserving
+	// the form used to create them.
+	NormalizationInsensitive bool
+	// ValidName, if not nil, is called for every component of the path of
+	// the created and renamed files, returning false if it's not allowed, in
+	// which case billy.ErrInvalidName is returned.
+	ValidName func(name string) bool
+	// TimeResolution, if greater than zero, truncates the modification times
+	// stored to a multiple of it, emulating the filesystems with coarse
+	// timestamps, such as ext3 with 1 second or FAT with 2 seconds.
+	TimeResolution time.Duration
+	// Clock, if not nil, returns the current time used for the modification
+	// times instead of time.Now, such as a StepClock producing out-of-order
+	// or tied timestamps, to test the code relying on them.
+	Clock func() time.Time
+	// ClockSkew is added to the current time used for the modification
+	// times, emulating a filesystem whose clock is ahead, if positive, or
+	// behind the one of the process, as the network filesystems served by
+	// another host. The times given to Chtimes aren't skewed.
+	ClockSkew time.Duration
+	// MaxSize, if greater than zero, is the capacity in bytes of the
+	// storage, shared by the contents of all the files, the holes of the
+	// sparse files don't take space. The writes exceeding it write what fits
+	// and fail with syscall.ENOSPC, as on a full disk.
+	MaxSize int64
+	// MaxFiles, if greater than zero, is the maximum number of files and
+	// symbolic links in the storage, creating more fails with
+	// syscall.ENOSPC, as when a disk runs out of inodes.
+	MaxFiles int
+	// MaxLinks, if greater than zero, is the maximum number of symbolic
+	// links followed while resolving a path, billy.DefaultMaxLinks by
+	// default. Exceeding it fails with billy.ErrTooManyLinks.
+	MaxLinks int
+}
+
+// NewWithOptions returns a new Memory filesystem configured with the given
+// options.
+func NewWithOptions(opts Options) *Memory {
+	fs := New()
+	fs.opts = opts
+	fs.s.resolution = opts.TimeResolution
+	fs.s.skew = opts.ClockSkew
+	fs.s.maxSize, fs.s.maxFiles = opts.MaxSize, opts.MaxFiles
+	if opts.Clock != nil {
+		fs.s.clock = opts.Clock
+	}
+
+	fs.s.rootTime = fs.s.now()
+	if opts.Windows {
+		fs.base = "/C:"
+	}
+
+	return fs
+}
+
+// fullpath returns the absolute path of filename, always using slashes. The
+// filename is cleaned before being joined to the base, so ".." can't escape
+// it.
+func (fs *Memory) fullpath(filename string) string {
+	if !fs.opts.Windows {
+		return path.Join(fs.base, path.Clean("/"+filename))
+	}
+
+	filename = strings.Replace(filename, `\`, "/", -1)
+	if isDrive(filename) {
+		return path.Join("/"+strings.ToUpper(filename[:2]), filename[2:])
+	}
+
+	return path.Join(fs.base, path.Clean("/"+filename))
+}
+
+// key returns the key used in the storage for the given absolute path.
+func (fs *Memory) key(fullpath string) string {
+	if fs.opts.NormalizationInsensitive {
+		fullpath = norm.NFC.String(fullpath)
+	}
+
+	if fs.opts.Windows || fs.opts.CaseInsensitive {
+		fullpath = strings.ToLower(fullpath)
+	}
+
+	return fullpath
+}
+
+// validate returns billy.ErrInvalidName if any component of fullpath is not a valid
+// name, the drive letters are not validated.
+func (fs *Memory) validate(fullpath string) error {
+	if fs.opts.ValidName == nil {
+		return nil
+	}
+
+	parts := split(fullpath)
+	if fs.opts.Windows && len(parts) != 0 {
+		parts = parts[1:]
+	}
+
+	for _, part := range parts {
+		if !fs.opts.ValidName(part) {
+			return billy.ErrInvalidName
+		}
+	}
+
+	return nil
+}
+
+// isRoot returns true if key is the root of the storage, or a drive.
+func (fs *Memory) isRoot(key string) bool {
+	return key == string(separator) || (fs.opts.Windows && path.Dir(key) == string(separator))
+}
+
+// underFile returns true if any of the parents of key is a regular file, the
+// symbolic links in the path being already resolved.
+func (fs *Memory) underFile(key string) bool {
+	for dir := path.Dir(key); !fs.isRoot(dir) && dir != string(separator); dir = path.Dir(dir) {
+		if _, ok := fs.s.files[dir]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// relative returns fullpath relative to the filesystem base, using the
+// separator of the path style, and if it's inside of it.
+func (fs *Memory) relative(fullpath string) (string, bool) {
+	if fullpath == "" {
+		return "", false
+	}
+
+	base := split(fs.key(fs.base))
+	parts := split(fullpath)
+	if len(parts) < len(base) {
+		return "", false
+	}
+
+	for i, part := range base {
+		if fs.key(parts[i]) != part {
+			return "", false
+		}
+	}
+
+	if len(parts) == len(base) {
+		return ".", true
+	}
+
+	sep := "/"
+	if fs.opts.Windows {
+		sep = `\`
+	}
+
+	return strings.Join(parts[len(base):], sep), true
+}
+
+// name returns the name of fullpath, relative to the filesystem base if it's
+// inside of it.
+func (fs *Memory) name(fullpath string) string {
+	if rel, ok := fs.relative(fullpath); ok {
+		return rel
+	}
+
+	return fullpath
+}
+
+func split(fullpath string) []string {
+	if fullpath == string(separator) {
+		return nil
+	}
+
+	return strings.Split(fullpath[1:], string(separator))
+}
+
+func isDrive(p string) bool {
+	if len(p) < 2 || p[1] != ':' {
+		return false
+	}
+
+	c := p[0] | 0x20
+	return c >= 'a' && c <= 'z'
+}
+
+func joinWindows(elem ...string) string {
+	parts := make([]string, len(elem))
+	for i, e := range elem {
+		parts[i] = strings.Replace(e, `\`, "/", -1)
+	}
+
+	return strings.Replace(path.Join(parts...), "/", `\`, -1)
+}
+
+func baseWindows(fullpath string) string {
+	base := strings.Replace(fullpath[1:], "/", `\`, -1)
+	if len(base) == 2 {
+		base += `\`
+	}
+
+	return base
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/test"
+)
+
+type WindowsSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&WindowsSuite{})
+
+func (s *WindowsSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = NewWithOptions(Options{Windows: true})
+}
+
+func (s *WindowsSuite) TestCreateDepth(c *C) {
+	f, err := s.Fs.Create(`bar\foo`)
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, `bar\foo`)
+}
+
+func (s *WindowsSuite) TestCreateDepthAbsolute(c *C) {
+	f, err := s.Fs.Create(`C:\bar\foo`)
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, `bar\foo`)
+}
+
+func (s *WindowsSuite) TestJoin(c *C) {
+	c.Assert(s.Fs.Join("foo", "bar"), Equals, `foo\bar`)
+	c.Assert(s.Fs.Join(`C:\`, "foo/bar"), Equals, `C:\foo\bar`)
+}
+
+func (s *WindowsSuite) TestBase(c *C) {
+	c.Assert(s.Fs.Base(), Equals, `C:\`)
+	c.Assert(s.Fs.Dir(`foo\bar`).Base(), Equals, `C:\foo\bar`)
+}
+
+func (s *WindowsSuite) TestCaseInsensitive(c *C) {
+	f, err := s.Fs.Create(`Foo\Bar.txt`)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fi, err := s.Fs.Stat(`FOO\bar.TXT`)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "Bar.txt")
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	fi, err = s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "Foo")
+	c.Assert(fi.IsDir(), Equals, true)
+
+	_, err = s.Fs.Create(`FOO\BAR.TXT`)
+	c.Assert(err, IsNil)
+
+	infos, err := s.Fs.ReadDir("/")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name(), Equals, "Foo")
+
+	infos, err = s.Fs.ReadDir("foo")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name(), Equals, "Bar.txt")
+	c.Assert(infos[0].Size(), Equals, int64(0))
+
+	c.Assert(s.Fs.Rename(`foo\bar.txt`, `foo\BAR.txt`), IsNil)
+	infos, err = s.Fs.ReadDir("Foo")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name(), Equals, "BAR.txt")
+
+	c.Assert(s.Fs.Remove(`FOO/bar.txt`), IsNil)
+	_, err = s.Fs.Stat("foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *WindowsSuite) TestDrives(c *C) {
+	_, err := s.Fs.Create(`D:\foo`)
+	c.Assert(err, IsNil)
+
+	_, err = s.Fs.Stat("foo")
+	c.Assert(err, NotNil)
+
+	_, err = s.Fs.Stat(`d:\foo`)
+	c.Assert(err, IsNil)
+
+	d := s.Fs.Dir(`D:\`)
+	c.Assert(d.Base(), Equals, `D:\`)
+	_, err = d.Stat("foo")
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"srcd.works/go-billy.v1"
+)
+
+// maxNameLength is the maximum length in bytes of a name in ext4 and APFS.
+const maxNameLength = 255
+
+var (
+	// LinuxExt4 emulates ext4 on Linux: case-sensitive names of at most 255
+	// bytes, not containing NUL, and nanosecond timestamps.
+	LinuxExt4 = Options{
+		ValidName:      validExt4Name,
+		TimeResolution: time.Nanosecond,
+	}
+
+	// MacAPFS emulates APFS on macOS with its default settings: case and
+	// normalization insensitive names of at most 255 UTF-8 bytes, and
+	// nanosecond timestamps.
+	MacAPFS = Options{
+		CaseInsensitive:          true,
+		NormalizationInsensitive: true,
+		ValidName:                validAPFSName,
+		TimeResolution:           time.Nanosecond,
+	}
+
+	// WindowsNTFS emulates NTFS on Windows: Windows path semantics, names not
+	// containing reserved characters, not being reserved device names nor
+	// ending with a dot or a space, and timestamps with a resolution of 100
+	// nanoseconds.
+	WindowsNTFS = Options{
+		Windows:        true,
+		ValidName:      billy.ValidWindowsName,
+		TimeResolution: 100 * time.Nanosecond,
+	}
+)
+
+func validExt4Name(name string) bool {
+	return len(name) <= maxNameLength && strings.IndexByte(name, 0) == -1
+}
+
+func validAPFSName(name string) bool {
+	return validExt4Name(name) && utf8.ValidString(name)
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type PresetsSuite struct{}
+
+var _ = Suite(&PresetsSuite{})
+
+func (s *PresetsSuite) TestLinuxExt4(c *C) {
+	fs := NewWithOptions(LinuxExt4)
+	_, err := fs.Create("Foo")
+	c.Assert(err, IsNil)
+	_, err = fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = fs.Create("a\x00b")
+	c.Assert(err.(*os.PathError).Err, Equals, billy.ErrInvalidName)
+
+	_, err = fs.Create(strings.Repeat("x", 256))
+	c.Assert(err, NotNil)
+	_, err = fs.Create(`con:a?b\c`)
+	c.Assert(err, IsNil)
+}
+
+func (s *PresetsSuite) TestMacAPFS(c *C) {
+	fs := NewWithOptions(MacAPFS)
+	// "é" precomposed and decomposed.
+	_, err := fs.Create("Caf\u00e9")
+	c.Assert(err, IsNil)
+
+	fi, err := fs.Stat("cafe\u0301")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "Caf\u00e9")
+
+	_, err = fs.Create("foo/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Join("foo", "bar"), Equals, "foo/bar")
+}
+
+func (s *PresetsSuite) TestWindowsNTFS(c *C) {
+	fs := NewWithOptions(WindowsNTFS)
+	for _, name := range []string{"a:b", "a?", "con", "NUL.txt", "lpt1 .log", "foo.", "foo ", "a\tb"} {
+		_, err := fs.Create(`dir\` + name)
+		c.Assert(err, NotNil, Commentf("name %q", name))
+	}
+
+	_, err := fs.Create(`C:\dir\console.txt`)
+	c.Assert(err, IsNil)
+
+	err = fs.Rename(`dir\console.txt`, `dir\aux`)
+	c.Assert(err.(*os.LinkError).Err, Equals, billy.ErrInvalidName)
+
+	_, err = fs.Stat(`DIR\Console.TXT`)
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.Register("mem", open)
+}
+
+var presets = map[string]Options{
+	"ext4": LinuxExt4,
+	"apfs": MacAPFS,
+	"ntfs": WindowsNTFS,
+}
+
+// instances holds the filesystems created by open, by name.
+var instances = struct {
+	sync.Mutex
+	fs map[string]*Memory
+}{fs: make(map[string]*Memory)}
+
+// open returns the filesystem addressed by a mem URI, such as mem://name/dir.
+// The filesystems are shared by all the URIs with the same name in the
+// process. The options are read from the query string when the filesystem is
+// created: preset, being ext4, apfs or ntfs, time-resolution and clock-skew,
+// as durations, and max-size, max-files and max-links, as integers.
+func open(u *url.URL) (billy.Filesystem, error) {
+	instances.Lock()
+	defer instances.Unlock()
+
+	fs, ok := instances.fs[u.Host]
+	if !ok {
+		opts, err := parseOptions(u.Query())
+		if err != nil {
+			return nil, err
+		}
+
+		fs = NewWithOptions(opts)
+		instances.fs[u.Host] = fs
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		return fs, nil
+	}
+
+	return fs.Dir(u.Path), nil
+}
+
+func parseOptions(q url.Values) (Options, error) {
+	var opts Options
+	if name := q.Get("preset"); name != "" {
+		p, ok := presets[name]
+		if !ok {
+			return opts, fmt.Errorf("unknown memory preset %q", name)
+		}
+
+		opts = p
+	}
+
+	if res := q.Get("time-resolution"); res != "" {
+		d, err := time.ParseDuration(res)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.TimeResolution = d
+	}
+
+	if skew := q.Get("clock-skew"); skew != "" {
+		d, err := time.ParseDuration(skew)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.ClockSkew = d
+	}
+
+	if size := q.Get("max-size"); size != "" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.MaxSize = n
+	}
+
+	if files := q.Get("max-files"); files != "" {
+		n, err := strconv.Atoi(files)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.MaxFiles = n
+	}
+
+	if links := q.Get("max-links"); links != "" {
+		n, err := strconv.Atoi(links)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.MaxLinks = n
+	}
+
+	return opts, nil
+}
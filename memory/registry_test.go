@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type RegistrySuite struct{}
+
+var _ = Suite(&RegistrySuite{})
+
+func (s *RegistrySuite) TestOpen(c *C) {
+	fs, err := billy.Open("mem://registry-test/foo")
+	c.Assert(err, IsNil)
+	_, err = fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	root, err := billy.Open("mem://registry-test")
+	c.Assert(err, IsNil)
+	_, err = root.Stat("foo/bar")
+	c.Assert(err, IsNil)
+
+	other, err := billy.Open("mem://registry-other")
+	c.Assert(err, IsNil)
+	_, err = other.Stat("foo/bar")
+	c.Assert(err, NotNil)
+}
+
+func (s *RegistrySuite) TestOpenOptions(c *C) {
+	fs, err := billy.Open("mem://registry-ntfs?preset=ntfs&time-resolution=2s")
+	c.Assert(err, IsNil)
+
+	m := fs.(*Memory)
+	c.Assert(m.opts.Windows, Equals, true)
+	c.Assert(m.opts.TimeResolution, Equals, 2*time.Second)
+
+	fs, err = billy.Open("mem://registry-skew?clock-skew=-1h")
+	c.Assert(err, IsNil)
+	c.Assert(fs.(*Memory).opts.ClockSkew, Equals, -time.Hour)
+
+	fs, err = billy.Open("mem://registry-capacity?max-size=1024&max-files=10")
+	c.Assert(err, IsNil)
+	c.Assert(fs.(*Memory).opts.MaxSize, Equals, int64(1024))
+	c.Assert(fs.(*Memory).opts.MaxFiles, Equals, 10)
+
+	_, err = billy.Open("mem://registry-invalid?preset=fat")
+	c.Assert(err, ErrorMatches, `unknown memory preset "fat"`)
+}
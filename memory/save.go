@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1/archive"
+)
+
+// Save writes the tree of fs to w as a tar archive, a portable format that
+// can be inspected with the usual tools, with the names relative to the base
+// of fs. The modes, the modification times and the targets of the symbolic
+// links are kept, the versions, the identifiers and the changes of the files
+// aren't.
+func (fs *Memory) Save(w io.Writer) error {
+	return archive.WriteTar(fs, w, "")
+}
+
+// Load reads the tar archive written by Save, or by any other tool, from r
+// into fs, relative to its base, replacing the files already present. The
+// directories are created as by MkdirAll, so they exist even while empty.
+// The entries other than regular files, directories and symbolic links are
+// skipped, and the names leaving the base of fs are rejected.
+func (fs *Memory) Load(r io.Reader) error {
+	type dirTime struct {
+		name    string
+		modTime time.Time
+	}
+
+	var dirs []dirTime
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(h.Name)
+		if name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("memory: invalid name in archive %q", h.Name)
+		}
+
+		perm := h.FileInfo().Mode().Perm()
+		switch {
+		case name == ".":
+			// the root, whose mode and times can't be changed.
+		case h.Typeflag == tar.TypeDir:
+			if err := fs.MkdirAll(name, perm); err != nil {
+				return err
+			}
+
+			if err := fs.Chmod(name, perm); err != nil {
+				return err
+			}
+
+			dirs = append(dirs, dirTime{name, h.ModTime})
+		case h.Typeflag == tar.TypeReg:
+			if err := fs.loadFile(name, perm, h.ModTime, tr); err != nil {
+				return err
+			}
+		case h.Typeflag == tar.TypeSymlink:
+			if err := fs.removeFile(name); err != nil {
+				return err
+			}
+
+			if err := fs.Symlink(h.Linkname, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the times of the directories are restored last, since adding their
+	// entries changes them.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := fs.Chtimes(dirs[i].name, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *Memory) loadFile(name string, perm os.FileMode, modTime time.Time, r io.Reader) error {
+	// an existing symbolic link is replaced, instead of writing its target.
+	if fi, err := fs.Lstat(name); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := fs.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := fs.Chmod(name, perm); err != nil {
+		return err
+	}
+
+	return fs.Chtimes(name, modTime, modTime)
+}
+
+// removeFile removes the named file if it exists and isn't a directory.
+func (fs *Memory) removeFile(name string) error {
+	fi, err := fs.Lstat(name)
+	if err != nil || fi.IsDir() {
+		return nil
+	}
+
+	return fs.Remove(name)
+}
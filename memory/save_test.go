@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type SaveSuite struct{}
+
+var _ = Suite(&SaveSuite{})
+
+func (s *SaveSuite) TestSaveLoad(c *C) {
+	fs := New()
+	writeFile(c, fs, "foo", "foo")
+	writeFile(c, fs, "qux/bar", "bar")
+	c.Assert(fs.Chmod("qux/bar", 0755), IsNil)
+	c.Assert(fs.MkdirAll("empty", 0700), IsNil)
+	c.Assert(fs.Symlink("qux/bar", "link"), IsNil)
+
+	mtime := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"foo", "qux/bar", "qux", "empty"} {
+		c.Assert(fs.Chtimes(name, mtime, mtime), IsNil)
+	}
+
+	var buf bytes.Buffer
+	c.Assert(fs.Save(&buf), IsNil)
+
+	loaded := New()
+	c.Assert(loaded.Load(&buf), IsNil)
+	c.Assert(tree(c, loaded), DeepEquals, tree(c, fs))
+
+	for _, name := range []string{"foo", "qux/bar", "qux", "empty"} {
+		want, err := fs.Lstat(name)
+		c.Assert(err, IsNil)
+		fi, err := loaded.Lstat(name)
+		c.Assert(err, IsNil)
+		c.Assert(fi.Mode(), Equals, want.Mode(), Commentf(name))
+		c.Assert(fi.ModTime().Equal(mtime), Equals, true, Commentf(name))
+	}
+
+	// the empty directory was created as by MkdirAll.
+	c.Assert(loaded.Remove("empty"), IsNil)
+}
+
+func (s *SaveSuite) TestLoadReplace(c *C) {
+	src := New()
+	writeFile(c, src, "foo", "new")
+	writeFile(c, src, "link", "file")
+	c.Assert(src.Symlink("foo", "qux"), IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(src.Save(&buf), IsNil)
+
+	fs := New()
+	writeFile(c, fs, "foo", "old")
+	writeFile(c, fs, "target", "target")
+	c.Assert(fs.Symlink("target", "link"), IsNil)
+	writeFile(c, fs, "qux", "qux")
+	writeFile(c, fs, "other", "other")
+
+	c.Assert(fs.Load(&buf), IsNil)
+	c.Assert(tree(c, fs), DeepEquals, map[string]string{
+		"foo":    "new",
+		"link":   "file",
+		"other":  "other",
+		"qux":    "-> foo",
+		"target": "target",
+	})
+}
+
+func (s *SaveSuite) TestLoadDir(c *C) {
+	src := New()
+	writeFile(c, src, "qux/foo", "foo")
+
+	var buf bytes.Buffer
+	c.Assert(src.Dir("qux").(*Memory).Save(&buf), IsNil)
+
+	fs := New()
+	c.Assert(fs.Dir("bar").(*Memory).Load(&buf), IsNil)
+	c.Assert(tree(c, fs), DeepEquals, map[string]string{
+		"bar/":    "",
+		"bar/foo": "foo",
+	})
+}
+
+func (s *SaveSuite) TestLoadInvalidName(c *C) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	c.Assert(tw.WriteHeader(&tar.Header{Name: "../foo", Mode: 0644, Typeflag: tar.TypeReg}), IsNil)
+	c.Assert(tw.Close(), IsNil)
+
+	fs := New()
+	err := fs.Dir("qux").(*Memory).Load(&buf)
+	c.Assert(err, ErrorMatches, `memory: invalid name in archive "../foo"`)
+
+	_, err = fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
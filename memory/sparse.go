@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"sort"
+
+	"srcd.works/go-billy.v1"
+)
+
+// extent is a region of a content holding data.
+type extent struct {
+	off  int64
+	data []byte
+}
+
+func (e extent) end() int64 {
+	return e.off + int64(len(e.data))
+}
+
+// overlapping returns the range of the extents overlapping or touching the
+// region from off to end, the extents never touch each other, since they are
+// merged when written.
+func (c *content) overlapping(off, end int64) (i, j int) {
+	i = sort.Search(len(c.extents), func(k int) bool {
+		return c.extents[k].end() >= off
+	})
+
+	for j = i; j < len(c.extents) && c.extents[j].off <= end; j++ {
+	}
+
+	return i, j
+}
+
+// allocated returns the number of bytes held by the extents, the holes don't
+// take space.
+func (c *content) allocated() int64 {
+	var n int64
+	for _, e := range c.extents {
+		n += int64(len(e.data))
+	}
+
+	return n
+}
+
+// fitting returns how many of the n bytes written at off fit allocating at
+// most free bytes, the ones overwriting data don't need space.
+func (c *content) fitting(off, n, free int64) int64 {
+	end := off + n
+	pos := off
+	i, j := c.overlapping(off, end)
+	for _, e := range c.extents[i:j] {
+		if gap := e.off - pos; gap > 0 {
+			if gap > free {
+				return pos + free - off
+			}
+
+			free -= gap
+		}
+
+		if pos = e.end(); pos >= end {
+			return n
+		}
+	}
+
+	if end-pos > free {
+		return pos + free - off
+	}
+
+	return n
+}
+
+// Extents returns the regions of the file holding data, the ones written,
+// the holes left writing past the end or growing it with Truncate are
+// skipped.
+func (f *file) Extents() ([]billy.Extent, error) {
+	if f.IsClosed() {
+		return nil, billy.ErrClosed
+	}
+
+	extents := make([]billy.Extent, len(f.content.extents))
+	for i, e := range f.content.extents {
+		extents[i] = billy.Extent{Offset: e.off, Length: int64(len(e.data))}
+	}
+
+	return extents, nil
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type SparseSuite struct{}
+
+var _ = Suite(&SparseSuite{})
+
+func (s *SparseSuite) TestHole(c *C) {
+	fs := NewWithOptions(Options{MaxSize: 6})
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	_, err = f.Seek(1<<40, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+
+	fi, err := fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(1<<40+3))
+
+	extents, err := f.(billy.Sparse).Extents()
+	c.Assert(err, IsNil)
+	c.Assert(extents, DeepEquals, []billy.Extent{
+		{Offset: 0, Length: 3},
+		{Offset: 1 << 40, Length: 3},
+	})
+
+	b := []byte("xxxxxx")
+	n, err := f.(io.ReaderAt).ReadAt(b, 1<<40-3)
+	c.Assert(n, Equals, 6)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "\x00\x00\x00bar")
+
+	n, err = f.(io.ReaderAt).ReadAt(b, 1<<40)
+	c.Assert(n, Equals, 3)
+	c.Assert(err, Equals, io.EOF)
+
+	// the hole doesn't take space, the storage is full with the data.
+	_, err = f.Write([]byte("qux"))
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.ENOSPC)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *SparseSuite) TestTruncate(c *C) {
+	fs := New()
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foobar"))
+	c.Assert(err, IsNil)
+
+	c.Assert(f.Truncate(1<<40), IsNil)
+	c.Assert(f.Truncate(3), IsNil)
+	c.Assert(f.Truncate(5), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	data, _, err := fs.ReadFileVersion("foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo\x00\x00")
+}
+
+func (s *SparseSuite) TestCopyFile(c *C) {
+	src := New()
+	f, err := src.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(1<<30), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	dst := NewWithOptions(Options{MaxSize: 4})
+	c.Assert(billy.CopyFile(dst, "foo", src, "foo"), IsNil)
+
+	fi, err := dst.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(1<<30))
+}
+
+// TestWriteAt checks random writes and truncations against a plain slice.
+func (s *SparseSuite) TestWriteAt(c *C) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		var model []byte
+		ct := &content{}
+		for j := 0; j < 20; j++ {
+			off := r.Int63n(64)
+			if r.Intn(5) == 0 {
+				ct.Truncate(off)
+				model = append(model, make([]byte, 64)...)[:off]
+				continue
+			}
+
+			p := make([]byte, r.Intn(16))
+			for k := range p {
+				p[k] = byte('a' + r.Intn(26))
+			}
+
+			ct.WriteAt(p, off)
+			if end := off + int64(len(p)); end > int64(len(model)) {
+				model = append(model, make([]byte, end-int64(len(model)))...)
+			}
+
+			copy(model[off:], p)
+		}
+
+		data := make([]byte, ct.Len())
+		ct.ReadAt(data, 0)
+		c.Assert(string(data), Equals, string(model))
+
+		for k, e := range ct.extents {
+			c.Assert(e.data, Not(HasLen), 0)
+			if k != 0 {
+				c.Assert(e.off > ct.extents[k-1].end(), Equals, true)
+			}
+		}
+	}
+}
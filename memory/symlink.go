@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Symlink creates link as a symbolic link to target. The target is stored as
+// given, an absolute target is resolved from the root of the storage, even
+// on the filesystems returned by Dir.
+func (fs *Memory) Symlink(target, link string) error {
+	fullpath, err := fs.resolve(link, false)
+	if err != nil {
+		return err
+	}
+
+	key := fs.key(fullpath)
+	if fs.s.exists(key) || fs.isRoot(key) {
+		return os.ErrExist
+	}
+
+	if err := fs.validate(fullpath); err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: err}
+	}
+
+	if fs.underFile(key) {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: errNotDirectory}
+	}
+
+	if fs.s.full() {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: syscall.ENOSPC}
+	}
+
+	f := newFile(fs, fullpath, os.O_RDONLY)
+	f.target = target
+	fs.s.lastID++
+	f.id = fs.s.lastID
+	fs.s.touch(f.content)
+	fs.s.add(key, f)
+	fs.s.record(billy.ChangeCreate, fullpath, "")
+	return nil
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Memory) Readlink(link string) (string, error) {
+	fullpath, err := fs.resolve(link, false)
+	if err != nil {
+		return "", err
+	}
+
+	f, ok := fs.s.files[fs.key(fullpath)]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+
+	if f.target == "" {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: errNotLink}
+	}
+
+	return f.target, nil
+}
+
+// Lstat returns a billy.FileInfo with the information of the requested file,
+// if it's a symbolic link the link itself is described.
+func (fs *Memory) Lstat(filename string) (billy.FileInfo, error) {
+	fullpath, err := fs.resolve(filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.stat(fullpath)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed while
+// resolving a path, as set in the options.
+func (fs *Memory) MaxLinks() int {
+	if fs.opts.MaxLinks > 0 {
+		return fs.opts.MaxLinks
+	}
+
+	return billy.DefaultMaxLinks
+}
+
+// resolve returns the full path of filename with all the symbolic links in it
+// resolved, the last element is only resolved if follow is true. A path
+// requiring more than MaxLinks links to be resolved returns
+// billy.ErrTooManyLinks.
+func (fs *Memory) resolve(filename string, follow bool) (string, error) {
+	parts := split(fs.fullpath(filename))
+	resolved := string(separator)
+	for links := 0; len(parts) != 0; {
+		fullpath := path.Join(resolved, parts[0])
+		parts = parts[1:]
+
+		f, ok := fs.s.files[fs.key(fullpath)]
+		if !ok || f.target == "" || (len(parts) == 0 && !follow) {
+			resolved = fullpath
+			continue
+		}
+
+		if links++; links > fs.MaxLinks() {
+			return "", billy.ErrTooManyLinks
+		}
+
+		parts = append(split(fs.target(resolved, f.target)), parts...)
+		resolved = string(separator)
+	}
+
+	return resolved, nil
+}
+
+// target returns the full path of the target of a symbolic link contained in
+// the directory dir.
+func (fs *Memory) target(dir, target string) string {
+	if fs.opts.Windows {
+		target = strings.Replace(target, `\`, "/", -1)
+		switch {
+		case isDrive(target):
+			return path.Join("/"+strings.ToUpper(target[:2]), target[2:])
+		case path.IsAbs(target):
+			return path.Join("/"+split(dir)[0], target)
+		}
+	}
+
+	if path.IsAbs(target) {
+		return path.Clean(target)
+	}
+
+	return path.Join(dir, target)
+}
@@ -0,0 +1,237 @@
+// Package mirrorfs provides a billy filesystem reading from a fallback
+// replica when the primary one fails.
+package mirrorfs // import "srcd.works/go-billy.v1/mirrorfs"
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Options holds the configuration of a Mirror filesystem.
+type Options struct {
+	// Heal copies back to the primary filesystem the files successfully
+	// opened from the fallback, replacing the ones failing to be read.
+	Heal bool
+}
+
+// Mirror is a filesystem where the write operations are done on a primary
+// filesystem and the read operations failing on it are retried on a fallback
+// replica. The files not found in the primary filesystem are not, so the
+// ones removed aren't served, or healed, from the replica.
+type Mirror struct {
+	primary  billy.Filesystem
+	fallback billy.Filesystem
+	opts     Options
+}
+
+// New returns a new Mirror filesystem, if opts is nil the default options are
+// used.
+func New(primary, fallback billy.Filesystem, opts *Options) *Mirror {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	return &Mirror{
+		primary:  primary,
+		fallback: fallback,
+		opts:     *opts,
+	}
+}
+
+// Create creates the named file in the primary filesystem.
+func (fs *Mirror) Create(filename string) (billy.File, error) {
+	return fs.primary.Create(filename)
+}
+
+// Open opens the named file for reading, from the fallback filesystem if it
+// can't be opened from the primary one.
+func (fs *Mirror) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, when opened for reading only and it fails in
+// the primary filesystem the fallback one is used.
+func (fs *Mirror) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := fs.primary.OpenFile(filename, flag, perm)
+	if !failover(err) || flag != os.O_RDONLY {
+		return f, err
+	}
+
+	f, ferr := fs.fallback.OpenFile(filename, flag, perm)
+	if ferr != nil {
+		return nil, err
+	}
+
+	if fs.opts.Heal {
+		fs.heal(filename)
+	}
+
+	return f, nil
+}
+
+// failover returns true if a read failing with err in the primary filesystem
+// is retried in the fallback one, the files not found are not.
+func failover(err error) bool {
+	return err != nil && !os.IsNotExist(err)
+}
+
+// heal copies the file from the fallback to the primary filesystem, it's done
+// on a best effort basis, a failure doesn't affect the read.
+func (fs *Mirror) heal(filename string) {
+	if err := billy.CopyFile(fs.primary, filename, fs.fallback, filename); err != nil {
+		fs.primary.Remove(filename)
+	}
+}
+
+// Stat returns the FileInfo of the named file, from the fallback filesystem
+// if it fails in the primary one.
+func (fs *Mirror) Stat(filename string) (billy.FileInfo, error) {
+	fi, err := fs.primary.Stat(filename)
+	if !failover(err) {
+		return fi, err
+	}
+
+	if fi, ferr := fs.fallback.Stat(filename); ferr == nil {
+		return fi, nil
+	}
+
+	return nil, err
+}
+
+// ReadDir lists the given directory, from the fallback filesystem if it fails
+// in the primary one.
+func (fs *Mirror) ReadDir(path string) ([]billy.FileInfo, error) {
+	l, err := fs.primary.ReadDir(path)
+	if !failover(err) {
+		return l, err
+	}
+
+	if l, ferr := fs.fallback.ReadDir(path); ferr == nil {
+		return l, nil
+	}
+
+	return nil, err
+}
+
+// TempFile creates a temporary file in the primary filesystem.
+func (fs *Mirror) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.primary.TempFile(dir, prefix)
+}
+
+// Rename renames a file in the primary filesystem.
+func (fs *Mirror) Rename(from, to string) error {
+	return fs.primary.Rename(from, to)
+}
+
+// Remove removes a file from the primary filesystem.
+func (fs *Mirror) Remove(filename string) error {
+	return fs.primary.Remove(filename)
+}
+
+// Symlink creates a symbolic link in the primary filesystem.
+func (fs *Mirror) Symlink(target, link string) error {
+	return fs.primary.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link, from the fallback
+// filesystem if it fails in the primary one.
+func (fs *Mirror) Readlink(link string) (string, error) {
+	target, err := fs.primary.Readlink(link)
+	if !failover(err) {
+		return target, err
+	}
+
+	if target, ferr := fs.fallback.Readlink(link); ferr == nil {
+		return target, nil
+	}
+
+	return "", err
+}
+
+// Lstat returns the FileInfo of the named file without following symbolic
+// links, from the fallback filesystem if it fails in the primary one.
+func (fs *Mirror) Lstat(filename string) (billy.FileInfo, error) {
+	fi, err := fs.primary.Lstat(filename)
+	if !failover(err) {
+		return fi, err
+	}
+
+	if fi, ferr := fs.fallback.Lstat(filename); ferr == nil {
+		return fi, nil
+	}
+
+	return nil, err
+}
+
+// MkdirAll creates a directory and its parents in the primary filesystem.
+func (fs *Mirror) MkdirAll(path string, perm os.FileMode) error {
+	return fs.primary.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file in the primary filesystem.
+func (fs *Mirror) Chmod(name string, mode os.FileMode) error {
+	return fs.primary.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file in the primary filesystem.
+func (fs *Mirror) Chtimes(name string, atime, mtime time.Time) error {
+	return fs.primary.Chtimes(name, atime, mtime)
+}
+
+// Ping checks both, the primary and the fallback filesystems, as billy.Ping.
+// An unavailable fallback is reported too, since the reads lose their
+// redundancy.
+func (fs *Mirror) Ping(ctx context.Context) error {
+	if _, err := billy.Ping(ctx, fs.primary); err != nil {
+		return err
+	}
+
+	_, err := billy.Ping(ctx, fs.fallback)
+	return err
+}
+
+// Flush flushes both, the primary and the fallback filesystems, as
+// billy.Flush.
+func (fs *Mirror) Flush(ctx context.Context) error {
+	if err := billy.Flush(ctx, fs.primary); err != nil {
+		return err
+	}
+
+	return billy.Flush(ctx, fs.fallback)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the primary filesystem, as billy.MaxLinks.
+func (fs *Mirror) MaxLinks() int {
+	return billy.MaxLinks(fs.primary)
+}
+
+// Close closes both, the primary and the fallback filesystems, as
+// billy.Close, returning the first error.
+func (fs *Mirror) Close() error {
+	err := billy.Close(fs.primary)
+	if ferr := billy.Close(fs.fallback); err == nil {
+		err = ferr
+	}
+
+	return err
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Mirror) Join(elem ...string) string {
+	return fs.primary.Join(elem...)
+}
+
+// Dir returns a new Mirror filesystem rooted at the given path of both, the
+// primary and the fallback filesystems.
+func (fs *Mirror) Dir(path string) billy.Filesystem {
+	return New(fs.primary.Dir(path), fs.fallback.Dir(path), &fs.opts)
+}
+
+// Base returns the base path of the primary filesystem.
+func (fs *Mirror) Base() string {
+	return fs.primary.Base()
+}
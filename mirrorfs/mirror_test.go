@@ -0,0 +1,195 @@
+package mirrorfs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MirrorSuite struct {
+	primary, fallback *memory.Memory
+}
+
+var _ = Suite(&MirrorSuite{})
+
+func (s *MirrorSuite) SetUpTest(c *C) {
+	s.primary = memory.New()
+	s.fallback = memory.New()
+	writeFile(c, s.primary, "foo", "primary")
+	writeFile(c, s.fallback, "foo", "fallback")
+	writeFile(c, s.fallback, "qux/bar", "fallback")
+}
+
+func (s *MirrorSuite) TestOpen(c *C) {
+	fs := New(&failing{Filesystem: s.primary, path: "qux"}, s.fallback, nil)
+	c.Assert(readFile(c, fs, "foo"), Equals, "primary")
+	c.Assert(readFile(c, fs, "qux/bar"), Equals, "fallback")
+
+	_, err := s.primary.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = fs.Open("non-existent")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MirrorSuite) TestOpenNotExist(c *C) {
+	fs := New(s.primary, s.fallback, &Options{Heal: true})
+	_, err := fs.Open("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	c.Assert(fs.Remove("foo"), IsNil)
+	_, err = fs.Open("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = s.primary.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MirrorSuite) TestOpenHeal(c *C) {
+	writeFile(c, s.primary, "qux/bar", "corrupted")
+	fs := New(&failing{Filesystem: s.primary, path: "qux"}, s.fallback, &Options{Heal: true})
+	c.Assert(readFile(c, fs, "qux/bar"), Equals, "fallback")
+	c.Assert(readFile(c, s.primary, "qux/bar"), Equals, "fallback")
+}
+
+func (s *MirrorSuite) TestStatAndReadDir(c *C) {
+	fs := New(&failing{Filesystem: s.primary, path: "qux"}, s.fallback, nil)
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "bar")
+
+	l, err := fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+}
+
+func (s *MirrorSuite) TestWrite(c *C) {
+	fs := New(s.primary, s.fallback, nil)
+	writeFile(c, fs, "qux/baz", "baz")
+
+	_, err := s.primary.Stat("qux/baz")
+	c.Assert(err, IsNil)
+	_, err = s.fallback.Stat("qux/baz")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MirrorSuite) TestPing(c *C) {
+	fs := New(s.primary, s.fallback, nil)
+	c.Assert(fs.Ping(context.Background()), IsNil)
+
+	errDown := errors.New("down")
+	fs = New(s.primary, &down{Filesystem: s.fallback, err: errDown}, nil)
+	c.Assert(fs.Ping(context.Background()), Equals, errDown)
+}
+
+func (s *MirrorSuite) TestFlush(c *C) {
+	fs := New(s.primary, s.fallback, nil)
+	c.Assert(fs.Flush(context.Background()), IsNil)
+
+	errDown := errors.New("down")
+	fs = New(s.primary, &down{Filesystem: s.fallback, err: errDown}, nil)
+	c.Assert(fs.Flush(context.Background()), Equals, errDown)
+}
+
+func (s *MirrorSuite) TestClose(c *C) {
+	errDown := errors.New("down")
+	primary := &down{Filesystem: s.primary, err: errDown}
+	fallback := &down{Filesystem: s.fallback}
+	fs := New(primary, fallback, nil)
+
+	// the fallback is closed even if closing the primary fails.
+	c.Assert(fs.Close(), Equals, errDown)
+	c.Assert(primary.closed, Equals, true)
+	c.Assert(fallback.closed, Equals, true)
+}
+
+// down is a filesystem whose backend is not available.
+type down struct {
+	billy.Filesystem
+	err    error
+	closed bool
+}
+
+func (fs *down) Ping(ctx context.Context) error {
+	return fs.err
+}
+
+func (fs *down) Flush(ctx context.Context) error {
+	return fs.err
+}
+
+func (fs *down) Close() error {
+	fs.closed = true
+	return fs.err
+}
+
+// failing is a filesystem failing to read the files under path.
+type failing struct {
+	billy.Filesystem
+	path string
+}
+
+func (fs *failing) fail(op, filename string) error {
+	if filename != fs.path && !strings.HasPrefix(filename, fs.path+"/") {
+		return nil
+	}
+
+	return &os.PathError{Op: op, Path: filename, Err: syscall.EIO}
+}
+
+func (fs *failing) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *failing) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if err := fs.fail("open", filename); err != nil && flag == os.O_RDONLY {
+		return nil, err
+	}
+
+	return fs.Filesystem.OpenFile(filename, flag, perm)
+}
+
+func (fs *failing) Stat(filename string) (billy.FileInfo, error) {
+	if err := fs.fail("stat", filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Filesystem.Stat(filename)
+}
+
+func (fs *failing) ReadDir(path string) ([]billy.FileInfo, error) {
+	if err := fs.fail("readdir", path); err != nil {
+		return nil, err
+	}
+
+	return fs.Filesystem.ReadDir(path)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
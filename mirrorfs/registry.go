@@ -0,0 +1,36 @@
+package mirrorfs
+
+import (
+	"errors"
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("mirror", wrap)
+}
+
+// wrap returns a Mirror filesystem using fs as primary and the URI given by
+// the fallback option, opened with billy.Open, as fallback. The heal option
+// enables Options.Heal.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	uri, ok := opts["fallback"]
+	if !ok {
+		return nil, errors.New("missing fallback option")
+	}
+
+	fallback, err := billy.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var o Options
+	if heal, ok := opts["heal"]; ok {
+		if o.Heal, err = strconv.ParseBool(heal); err != nil {
+			return nil, err
+		}
+	}
+
+	return New(fs, fallback, &o), nil
+}
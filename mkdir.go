@@ -0,0 +1,45 @@
+package billy
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+)
+
+// Mkdirer is an optional interface implemented by the filesystems able to
+// create a single directory, failing if it already exists, as a single
+// operation.
+type Mkdirer interface {
+	// Mkdir creates the named directory, empty, failing with an error
+	// satisfying os.IsExist if it already exists, and os.IsNotExist if its
+	// parent doesn't.
+	Mkdir(name string, perm os.FileMode) error
+}
+
+// Mkdir creates the named directory in fs, failing if it already exists or
+// its parent doesn't, as os.Mkdir. The filesystems not implementing Mkdirer
+// are checked with Lstat and Stat before calling MkdirAll, so two callers
+// creating the same directory concurrently may both succeed.
+func Mkdir(fs Filesystem, name string, perm os.FileMode) error {
+	if m, ok := fs.(Mkdirer); ok {
+		return m.Mkdir(name, perm)
+	}
+
+	if _, err := fs.Lstat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	if dir := path.Dir(path.Clean("/" + filepath.ToSlash(name))); dir != "/" {
+		fi, err := fs.Stat(dir[1:])
+		if err != nil {
+			return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+		}
+
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+	}
+
+	return fs.MkdirAll(name, perm)
+}
@@ -0,0 +1,32 @@
+package billy_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type MkdirSuite struct{}
+
+var _ = Suite(&MkdirSuite{})
+
+func (s *MkdirSuite) TestMkdirEmulated(c *C) {
+	// the embedded interface hides the Mkdir method of memory.
+	fs := struct{ billy.Filesystem }{memory.New()}
+	writeFile(c, fs, "qux/foo", "foo")
+
+	c.Assert(billy.Mkdir(fs, "qux/bar", 0700), IsNil)
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0700))
+
+	err = billy.Mkdir(fs, "qux/bar", 0755)
+	c.Assert(os.IsExist(err), Equals, true)
+	err = billy.Mkdir(fs, "/missing/bar", 0755)
+	c.Assert(os.IsNotExist(err), Equals, true)
+	err = billy.Mkdir(fs, "qux/foo/bar", 0755)
+	c.Assert(err, ErrorMatches, "mkdir qux/foo/bar: not a directory")
+}
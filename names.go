@@ -0,0 +1,63 @@
+package billy
+
+import "strings"
+
+// maxWindowsName is the maximum length of a name in NTFS and FAT, in UTF-16
+// code units.
+const maxWindowsName = 255
+
+// ValidWindowsName returns true if name is a valid file name on Windows: not
+// longer than 255 UTF-16 code units, not containing control characters nor
+// any of <>:"/\|?*, not being a reserved device name, such as CON or LPT1,
+// with or without extension, and not ending with a dot or a space.
+func ValidWindowsName(name string) bool {
+	if name == "" || utf16Len(name) > maxWindowsName {
+		return false
+	}
+
+	if strings.ContainsAny(name, `<>:"/\|?*`) {
+		return false
+	}
+
+	for _, r := range name {
+		if r < 32 {
+			return false
+		}
+	}
+
+	if last := name[len(name)-1]; last == '.' || last == ' ' {
+		return name == "." || name == ".."
+	}
+
+	return !isReservedName(name)
+}
+
+// isReservedName returns true if name is a Windows device name, with or
+// without extension.
+func isReservedName(name string) bool {
+	if i := strings.IndexByte(name, '.'); i != -1 {
+		name = name[:i]
+	}
+
+	switch strings.ToUpper(strings.TrimRight(name, " ")) {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+
+	return false
+}
+
+func utf16Len(s string) int {
+	var n int
+	for _, r := range s {
+		if r >= 0x10000 {
+			n++
+		}
+
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,41 @@
+//go:build go1.16
+// +build go1.16
+
+package os
+
+import (
+	"os"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ReadDirEntries returns the entries of the given directory sorted by name,
+// without calling stat for each one of them.
+func (fs *OS) ReadDirEntries(path string) ([]billy.DirEntry, error) {
+	fullpath, err := fs.abs(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := os.ReadDir(fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var s = make([]billy.DirEntry, len(l))
+	for i, e := range l {
+		s[i] = &dirEntry{e}
+	}
+
+	return s, nil
+}
+
+// dirEntry adapts an os.DirEntry to billy.DirEntry, the FileInfo is only
+// retrieved when Info is called.
+type dirEntry struct {
+	os.DirEntry
+}
+
+func (e *dirEntry) Info() (billy.FileInfo, error) {
+	return e.DirEntry.Info()
+}
@@ -0,0 +1,33 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package os
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"srcd.works/go-billy.v1"
+)
+
+// FileID returns an identifier of the named file, based on its device and
+// inode numbers, which is preserved on renames within the same device.
+func (fs *OS) FileID(filename string) (string, error) {
+	fullpath, err := fs.abs(filename, true)
+	if err != nil {
+		return "", err
+	}
+
+	fi, err := os.Stat(fullpath)
+	if err != nil {
+		return "", err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", billy.ErrNotSupported
+	}
+
+	return fmt.Sprintf("%d:%d", uint64(st.Dev), uint64(st.Ino)), nil
+}
@@ -0,0 +1,34 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package os_test
+
+import (
+	stdos "os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+func (s *OSSuite) TestCopyTreePreserveHardLinks(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	c.Assert(s.Fs.(billy.HardLink).Link("qux/foo", "qux/baz/bar"), IsNil)
+	s.writeFile(c, "qux/other", "foo")
+
+	dst := s.Fs.Dir("dst")
+	err := billy.CopyTree(dst, s.Fs.Dir("qux"), &billy.CopyOptions{
+		PreserveHardLinks: true,
+	})
+	c.Assert(err, IsNil)
+
+	foo, err := stdos.Stat(filepath.Join(s.path, "dst", "foo"))
+	c.Assert(err, IsNil)
+	bar, err := stdos.Stat(filepath.Join(s.path, "dst", "baz", "bar"))
+	c.Assert(err, IsNil)
+	other, err := stdos.Stat(filepath.Join(s.path, "dst", "other"))
+	c.Assert(err, IsNil)
+
+	c.Assert(stdos.SameFile(foo, bar), Equals, true)
+	c.Assert(stdos.SameFile(foo, other), Equals, false)
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!windows
+
+package os
+
+import (
+	"os"
+
+	"srcd.works/go-billy.v1"
+)
+
+func lock(f *os.File) error {
+	return billy.ErrNotSupported
+}
+
+func unlock(f *os.File) error {
+	return billy.ErrNotSupported
+}
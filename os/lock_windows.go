@@ -0,0 +1,41 @@
+package os
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const lockfileExclusiveLock = 0x2
+
+// allBytes is the length of the locked range, the maximum one, so it covers
+// the whole file.
+const allBytes = ^uint32(0)
+
+func lock(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock, 0,
+		uintptr(allBytes), uintptr(allBytes), uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return &os.PathError{Op: "LockFileEx", Path: f.Name(), Err: err}
+	}
+
+	return nil
+}
+
+func unlock(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0,
+		uintptr(allBytes), uintptr(allBytes), uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return &os.PathError{Op: "UnlockFileEx", Path: f.Name(), Err: err}
+	}
+
+	return nil
+}
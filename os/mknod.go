@@ -0,0 +1,42 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package os
+
+import (
+	"os"
+	"syscall"
+)
+
+// Mknod creates a device node, named pipe or socket file, with the type given
+// in mode. The device number dev is only used by device nodes.
+func (fs *OS) Mknod(name string, mode os.FileMode, dev uint64) error {
+	fullpath, err := fs.abs(name, false)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.createDir(fullpath); err != nil {
+		return err
+	}
+
+	m := uint32(mode.Perm())
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		m |= syscall.S_IFIFO
+	case mode&os.ModeSocket != 0:
+		m |= syscall.S_IFSOCK
+	case mode&os.ModeCharDevice != 0:
+		m |= syscall.S_IFCHR
+	case mode&os.ModeDevice != 0:
+		m |= syscall.S_IFBLK
+	default:
+		return &os.PathError{Op: "mknod", Path: fullpath, Err: syscall.EINVAL}
+	}
+
+	if err := syscall.Mknod(fullpath, m, int(dev)); err != nil {
+		return &os.PathError{Op: "mknod", Path: fullpath, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package os_test
+
+import (
+	stdos "os"
+	"path/filepath"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+func (s *OSSuite) TestCopyTreeSpecial(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	c.Assert(syscall.Mkfifo(filepath.Join(s.path, "qux", "fifo"), 0644), IsNil)
+
+	fi, err := s.Fs.Stat("qux/fifo")
+	c.Assert(err, IsNil)
+	c.Assert(billy.IsSpecial(fi), Equals, true)
+
+	err = billy.CopyTree(s.Fs.Dir("fail"), s.Fs.Dir("qux"), nil)
+	c.Assert(err, NotNil)
+	c.Assert(err.(*stdos.PathError).Err, Equals, billy.ErrSpecialFile)
+
+	skip := s.Fs.Dir("skip")
+	err = billy.CopyTree(skip, s.Fs.Dir("qux"), &billy.CopyOptions{
+		Special: billy.SpecialSkip,
+	})
+	c.Assert(err, IsNil)
+	_, err = skip.Stat("fifo")
+	c.Assert(stdos.IsNotExist(err), Equals, true)
+
+	recreate := s.Fs.Dir("recreate")
+	err = billy.CopyTree(recreate, s.Fs.Dir("qux"), &billy.CopyOptions{
+		Special: billy.SpecialRecreate,
+	})
+	c.Assert(err, IsNil)
+	fi, err = recreate.Stat("fifo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&stdos.ModeNamedPipe, Not(Equals), stdos.FileMode(0))
+}
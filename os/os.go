@@ -2,10 +2,12 @@
 package os // import "srcd.works/go-billy.v1/os"
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"srcd.works/go-billy.v1"
 )
@@ -13,6 +15,19 @@ import (
 // OS is a filesystem based on the os filesystem
 type OS struct {
 	base string
+	// dir is the directory of the filesystems returned by Dir, relative to
+	// base, so their paths are still resolved against it.
+	dir  string
+	opts Options
+}
+
+// Options holds the configuration of an OS filesystem.
+type Options struct {
+	// MaxLinks, if greater than zero, is the maximum number of symbolic
+	// links followed while resolving a path, billy.DefaultMaxLinks by
+	// default. The links are resolved by the filesystem, to keep them within
+	// its base, so this bounds every operation following them.
+	MaxLinks int
 }
 
 // New returns a new OS filesystem
@@ -22,6 +37,15 @@ func New(baseDir string) *OS {
 	}
 }
 
+// NewWithOptions returns a new OS filesystem configured with the given
+// options.
+func NewWithOptions(baseDir string, opts Options) *OS {
+	return &OS{
+		base: baseDir,
+		opts: opts,
+	}
+}
+
 // Create creates a file and opens it with standard permissions
 // and modes O_RDWR, O_CREATE and O_TRUNC.
 func (fs *OS) Create(filename string) (billy.File, error) {
@@ -31,7 +55,12 @@ func (fs *OS) Create(filename string) (billy.File, error) {
 // OpenFile is equivalent to standard os.OpenFile.
 // If flag os.O_CREATE is set, all parent directories will be created.
 func (fs *OS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
-	fullpath := path.Join(fs.base, filename)
+	// a new file, created exclusively, doesn't follow a link in its place.
+	follow := flag&(os.O_CREATE|os.O_EXCL) != os.O_CREATE|os.O_EXCL
+	fullpath, err := fs.abs(filename, follow)
+	if err != nil {
+		return nil, err
+	}
 
 	if flag&os.O_CREATE != 0 {
 		if err := fs.createDir(fullpath); err != nil {
@@ -44,7 +73,7 @@ func (fs *OS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File,
 		return nil, err
 	}
 
-	filename, err = filepath.Rel(fs.base, fullpath)
+	filename, err = clean(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +95,10 @@ func (fs *OS) createDir(fullpath string) error {
 // ReadDir returns the filesystem info for all the archives under the specified
 // path.
 func (ofs *OS) ReadDir(path string) ([]billy.FileInfo, error) {
-	fullpath := ofs.Join(ofs.base, path)
+	fullpath, err := ofs.abs(path, true)
+	if err != nil {
+		return nil, err
+	}
 
 	l, err := ioutil.ReadDir(fullpath)
 	if err != nil {
@@ -81,10 +113,40 @@ func (ofs *OS) ReadDir(path string) ([]billy.FileInfo, error) {
 	return s, nil
 }
 
+// CountEntries returns the number of entries in the given directory, reading
+// at most limit entries if it's greater than zero.
+func (fs *OS) CountEntries(path string, limit int) (int, error) {
+	fullpath, err := fs.abs(path, true)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(fullpath)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	names, err := f.Readdirnames(limit)
+	if err == io.EOF {
+		err = nil
+	}
+
+	return len(names), err
+}
+
 // Rename moves a file in disk from _from_ to _to_.
 func (fs *OS) Rename(from, to string) error {
-	from = fs.Join(fs.base, from)
-	to = fs.Join(fs.base, to)
+	from, err := fs.abs(from, false)
+	if err != nil {
+		return err
+	}
+
+	to, err = fs.abs(to, false)
+	if err != nil {
+		return err
+	}
 
 	if err := fs.createDir(to); err != nil {
 		return err
@@ -100,19 +162,31 @@ func (fs *OS) Open(filename string) (billy.File, error) {
 
 // Stat returns the FileInfo structure describing file.
 func (fs *OS) Stat(filename string) (billy.FileInfo, error) {
-	fullpath := fs.Join(fs.base, filename)
+	fullpath, err := fs.abs(filename, true)
+	if err != nil {
+		return nil, err
+	}
+
 	return os.Stat(fullpath)
 }
 
 // Remove deletes a file in disk.
 func (fs *OS) Remove(filename string) error {
-	fullpath := fs.Join(fs.base, filename)
+	fullpath, err := fs.abs(filename, false)
+	if err != nil {
+		return err
+	}
+
 	return os.Remove(fullpath)
 }
 
 // TempFile creates a new temporal file.
 func (fs *OS) TempFile(dir, prefix string) (billy.File, error) {
-	fullpath := fs.Join(fs.base, dir)
+	fullpath, err := fs.abs(dir, true)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := fs.createDir(fullpath + string(os.PathSeparator)); err != nil {
 		return nil, err
 	}
@@ -127,28 +201,246 @@ func (fs *OS) TempFile(dir, prefix string) (billy.File, error) {
 		return nil, err
 	}
 
-	filename, err := filepath.Rel(fs.base, fs.Join(fullpath, s.Name()))
+	dir, err = clean(dir)
 	if err != nil {
 		return nil, err
 	}
 
+	filename := fs.Join(dir, s.Name())
+
 	return newOSFile(filename, f), nil
 }
 
+// Chtimes changes the access and modification times of the named file.
+func (fs *OS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fullpath, err := fs.abs(name, true)
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(fullpath, atime, mtime)
+}
+
+// Chown changes the numeric uid and gid of the named file.
+func (fs *OS) Chown(name string, uid, gid int) error {
+	fullpath, err := fs.abs(name, true)
+	if err != nil {
+		return err
+	}
+
+	return os.Chown(fullpath, uid, gid)
+}
+
+// Link creates newname as a hard link to the oldname file.
+func (fs *OS) Link(oldname, newname string) error {
+	oldname, err := fs.abs(oldname, false)
+	if err != nil {
+		return err
+	}
+
+	newname, err = fs.abs(newname, false)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.createDir(newname); err != nil {
+		return err
+	}
+
+	return os.Link(oldname, newname)
+}
+
+// Symlink creates link as a symbolic link to target, creating the parent
+// directories of link. The target is stored as given, so an absolute target
+// is not relative to the filesystem base.
+func (fs *OS) Symlink(target, link string) error {
+	link, err := fs.abs(link, false)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.createDir(link); err != nil {
+		return err
+	}
+
+	return os.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *OS) Readlink(link string) (string, error) {
+	fullpath, err := fs.abs(link, false)
+	if err != nil {
+		return "", err
+	}
+
+	return os.Readlink(fullpath)
+}
+
+// Lstat returns the FileInfo structure describing file, if it's a symbolic
+// link the link itself is described.
+func (fs *OS) Lstat(filename string) (billy.FileInfo, error) {
+	fullpath, err := fs.abs(filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Lstat(fullpath)
+}
+
+// Chmod changes the mode of the named file, as os.Chmod.
+func (fs *OS) Chmod(name string, mode os.FileMode) error {
+	fullpath, err := fs.abs(name, true)
+	if err != nil {
+		return err
+	}
+
+	return os.Chmod(fullpath, mode)
+}
+
+// MkdirAll creates the directory path and all its parents, as os.MkdirAll.
+func (fs *OS) MkdirAll(path string, perm os.FileMode) error {
+	fullpath, err := fs.abs(path, true)
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(fullpath, perm)
+}
+
+// Mkdir creates the named directory, as os.Mkdir.
+func (fs *OS) Mkdir(path string, perm os.FileMode) error {
+	fullpath, err := fs.abs(path, false)
+	if err != nil {
+		return err
+	}
+
+	return os.Mkdir(fullpath, perm)
+}
+
 // Join joins the specified elements using the filesystem separator.
 func (fs *OS) Join(elem ...string) string {
 	return filepath.Join(elem...)
 }
 
 // Dir returns a new Filesystem from the same type of fs using as baseDir the
-// given path
+// given path. The path is rooted at the base of fs, so the ".." elements
+// can't go above it, and its paths are resolved against the base of fs, so
+// the symbolic links can't either.
 func (fs *OS) Dir(path string) billy.Filesystem {
-	return New(fs.Join(fs.base, path))
+	return &OS{
+		base: fs.base,
+		dir:  fs.Join(fs.dir, strings.TrimLeft(filepath.Clean(string(filepath.Separator)+path), string(filepath.Separator))),
+		opts: fs.opts,
+	}
+}
+
+// MaxLinks returns the maximum number of symbolic links followed while
+// resolving a path, as set in the options.
+func (fs *OS) MaxLinks() int {
+	if fs.opts.MaxLinks > 0 {
+		return fs.opts.MaxLinks
+	}
+
+	return billy.DefaultMaxLinks
+}
+
+// abs returns the path in the host of the given filename. The filenames are
+// relative to the base, even the absolute ones, and the ones escaping it
+// return billy.ErrCrossedBoundary. The symbolic links in the path are
+// resolved one element at a time against the base, the last element only if
+// follow is true, so a link can't escape the base either: the relative
+// targets are resolved from the directory of the link, and the absolute ones
+// must be under the base. Resolving more than MaxLinks links returns
+// billy.ErrTooManyLinks.
+//
+// The path is resolved before being used, so a link changed concurrently by
+// another process, in between, isn't confined.
+func (fs *OS) abs(filename string, follow bool) (string, error) {
+	rel, err := clean(filename)
+	if err != nil {
+		return "", err
+	}
+
+	parts := split(filepath.Join(fs.dir, rel))
+	resolved := ""
+	for links := 0; len(parts) != 0; {
+		next := filepath.Join(resolved, parts[0])
+		parts = parts[1:]
+
+		fi, err := os.Lstat(fs.Join(fs.base, next))
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 || (len(parts) == 0 && !follow) {
+			resolved = next
+			continue
+		}
+
+		if links++; links > fs.MaxLinks() {
+			return "", billy.ErrTooManyLinks
+		}
+
+		target, err := fs.target(resolved, next)
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(split(target), parts...)
+		resolved = ""
+	}
+
+	return fs.Join(fs.base, resolved), nil
+}
+
+// target returns the target of the symbolic link, contained in the directory
+// dir, relative to the base.
+func (fs *OS) target(dir, link string) (string, error) {
+	target, err := os.Readlink(fs.Join(fs.base, link))
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(target) {
+		return clean(filepath.Join(dir, target))
+	}
+
+	base, err := filepath.Abs(fs.base)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return "", billy.ErrCrossedBoundary
+	}
+
+	return clean(rel)
+}
+
+// clean returns the given filename relative to the base, even if it's
+// absolute, failing with billy.ErrCrossedBoundary if it escapes it.
+func clean(filename string) (string, error) {
+	rel := filepath.Clean(strings.TrimLeft(filepath.FromSlash(filename), string(filepath.Separator)))
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", billy.ErrCrossedBoundary
+	}
+
+	return rel, nil
+}
+
+// split returns the elements of the cleaned relative path rel.
+func split(rel string) []string {
+	if rel == "." {
+		return nil
+	}
+
+	return strings.Split(rel, string(filepath.Separator))
 }
 
 // Base returns the base path of the filesytem
 func (fs *OS) Base() string {
-	return fs.base
+	if fs.dir == "" {
+		return fs.base
+	}
+
+	return fs.Join(fs.base, fs.dir)
 }
 
 // osFile represents a file in the os filesystem
@@ -176,6 +468,26 @@ func (f *osFile) Write(p []byte) (int, error) {
 	return f.file.Write(p)
 }
 
+func (f *osFile) Stat() (billy.FileInfo, error) {
+	return f.file.Stat()
+}
+
+func (f *osFile) Truncate(size int64) error {
+	return f.file.Truncate(size)
+}
+
+func (f *osFile) Sync() error {
+	return f.file.Sync()
+}
+
+func (f *osFile) Lock() error {
+	return lock(f.file)
+}
+
+func (f *osFile) Unlock() error {
+	return unlock(f.file)
+}
+
 func (f *osFile) Close() error {
 	f.BaseFile.Closed = true
 
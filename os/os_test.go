@@ -1,12 +1,19 @@
 package os_test
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
 	stdos "os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
 	"srcd.works/go-billy.v1/os"
 	"srcd.works/go-billy.v1/test"
 )
@@ -35,3 +42,327 @@ func (s *OSSuite) TestOpenDoesNotCreateDir(c *C) {
 	_, err = stdos.Stat(filepath.Join(s.path, "dir"))
 	c.Assert(stdos.IsNotExist(err), Equals, true)
 }
+
+func (s *OSSuite) TestSnapshot(c *C) {
+	fs := s.Fs.(*os.OS)
+	s.writeFile(c, "qux/foo", "foo")
+	s.writeFile(c, "qux/baz/bar", "bar")
+
+	snap, err := fs.Snapshot("qux")
+	c.Assert(err, IsNil)
+
+	s.writeFile(c, "qux/foo", "changed")
+	c.Assert(fs.Remove("qux/baz/bar"), IsNil)
+
+	f, err := snap.Open("foo")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+
+	_, err = snap.Stat("baz/bar")
+	c.Assert(err, IsNil)
+
+	_, err = snap.Create("new")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+}
+
+func (s *OSSuite) writeFile(c *C, filename, content string) {
+	f, err := s.Fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *OSSuite) TestCopyTreePreserveTimes(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	s.writeFile(c, "qux/baz/bar", "bar")
+
+	mtime := time.Date(2015, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"qux/baz/bar", "qux/baz", "qux/foo", "qux"} {
+		c.Assert(s.Fs.Chtimes(name, mtime, mtime), IsNil)
+	}
+
+	dst := s.Fs.Dir("dst")
+	err := billy.CopyTree(dst, s.Fs.Dir("qux"), &billy.CopyOptions{
+		PreserveTimes: true,
+	})
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"baz/bar", "baz", "foo", ""} {
+		fi, err := dst.Stat(name)
+		c.Assert(err, IsNil)
+		c.Assert(fi.ModTime().Equal(mtime), Equals, true, Commentf(name))
+	}
+}
+
+func (s *OSSuite) TestReadDirEntries(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	s.writeFile(c, "qux/bar/baz", "baz")
+
+	entries, err := billy.ReadDirEntries(s.Fs, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Name(), Equals, "bar")
+	c.Assert(entries[0].IsDir(), Equals, true)
+	c.Assert(entries[1].Name(), Equals, "foo")
+	c.Assert(entries[1].Type(), Equals, stdos.FileMode(0))
+}
+
+func (s *OSSuite) TestWalkDirInfo(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+
+	var size int64
+	err := billy.WalkDir(s.Fs, "qux", func(path string, d billy.DirEntry, err error) error {
+		c.Assert(err, IsNil)
+		if d.IsDir() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		c.Assert(err, IsNil)
+		size = fi.Size()
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(3))
+}
+
+func (s *OSSuite) TestCleanTemp(c *C) {
+	old, err := s.Fs.TempFile("tmp", "old")
+	c.Assert(err, IsNil)
+	c.Assert(old.Close(), IsNil)
+
+	recent, err := s.Fs.TempFile("tmp", "recent")
+	c.Assert(err, IsNil)
+	c.Assert(recent.Close(), IsNil)
+
+	mtime := time.Now().Add(-2 * time.Hour)
+	c.Assert(s.Fs.Chtimes(old.Filename(), mtime, mtime), IsNil)
+
+	r, err := billy.CleanTemp(s.Fs, "tmp", time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(r.Files, DeepEquals, []string{old.Filename()})
+
+	_, err = s.Fs.Stat(recent.Filename())
+	c.Assert(err, IsNil)
+}
+
+func (s *OSSuite) TestCountEntries(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	s.writeFile(c, "qux/bar", "bar")
+	c.Assert(stdos.Mkdir(filepath.Join(s.path, "empty"), 0755), IsNil)
+
+	n, err := billy.CountEntries(s.Fs, "qux", 1)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+
+	n, err = billy.CountEntries(s.Fs, "qux", 0)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 2)
+
+	empty, err := billy.IsEmptyDir(s.Fs, "empty")
+	c.Assert(err, IsNil)
+	c.Assert(empty, Equals, true)
+}
+
+func (s *OSSuite) TestCommit(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	s.writeFile(c, "bar", "bar")
+
+	err := billy.Commit(s.Fs, func(tx billy.Filesystem) error {
+		f, err := tx.Create("qux/foo")
+		c.Assert(err, IsNil)
+		_, err = f.Write([]byte("new foo"))
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+
+		return tx.Remove("bar")
+	})
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(s.path, "qux/foo"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "new foo")
+
+	infos, err := ioutil.ReadDir(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].Name(), Equals, "qux")
+}
+
+func (s *OSSuite) TestAppenderConcurrent(c *C) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		a, err := billy.OpenAppender(s.Fs, "log", nil)
+		c.Assert(err, IsNil)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer a.Close()
+
+			line := strings.Repeat(strconv.Itoa(i), 512) + "\n"
+			for j := 0; j < 100; j++ {
+				if _, err := a.Write([]byte(line)); err != nil {
+					panic(err)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	content, err := ioutil.ReadFile(filepath.Join(s.path, "log"))
+	c.Assert(err, IsNil)
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	c.Assert(lines, HasLen, 800)
+	for _, line := range lines {
+		c.Assert(line, HasLen, 512)
+		c.Assert(strings.Count(line, line[:1]), Equals, 512)
+	}
+}
+
+func (s *OSSuite) TestFollow(c *C) {
+	r, err := billy.Follow(context.Background(), s.Fs, "log", &billy.FollowOptions{
+		Interval: time.Millisecond,
+	})
+	c.Assert(err, IsNil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ioutil.WriteFile(filepath.Join(s.path, "log"), []byte("foo"), 0644)
+	}()
+
+	b := make([]byte, 3)
+	_, err = io.ReadFull(r, b)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+	c.Assert(r.Close(), IsNil)
+}
+
+func (s *OSSuite) TestOpenURI(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+
+	fs, err := billy.Open("file://" + filepath.ToSlash(filepath.Join(s.path, "qux")))
+	c.Assert(err, IsNil)
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+
+	fs, err = billy.Open(s.path)
+	c.Assert(err, IsNil)
+	_, err = fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+
+	_, err = billy.Open("file://remote/foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *OSSuite) TestCrossedBoundary(c *C) {
+	for _, name := range []string{"../foo", "qux/../../foo", "/../foo"} {
+		_, err := s.Fs.Create(name)
+		c.Assert(err, Equals, billy.ErrCrossedBoundary, Commentf(name))
+
+		_, err = s.Fs.Stat(name)
+		c.Assert(err, Equals, billy.ErrCrossedBoundary, Commentf(name))
+	}
+
+	s.writeFile(c, "foo", "foo")
+	c.Assert(s.Fs.Rename("foo", "../foo"), Equals, billy.ErrCrossedBoundary)
+
+	_, err := s.Fs.Stat("qux/../foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *OSSuite) TestSymlinkCrossedBoundary(c *C) {
+	outside := filepath.Join(filepath.Dir(s.path), "foo")
+	for _, target := range []string{"../../foo", "../..", outside} {
+		c.Assert(s.Fs.Symlink(target, "qux/link"), IsNil)
+
+		_, err := s.Fs.Stat("qux/link")
+		c.Assert(err, Equals, billy.ErrCrossedBoundary, Commentf(target))
+
+		_, err = s.Fs.Create("qux/link/foo")
+		c.Assert(err, Equals, billy.ErrCrossedBoundary, Commentf(target))
+
+		_, err = s.Fs.Lstat("qux/link")
+		c.Assert(err, IsNil)
+		c.Assert(s.Fs.Remove("qux/link"), IsNil)
+	}
+
+	_, err := stdos.Lstat(outside)
+	c.Assert(stdos.IsNotExist(err), Equals, true)
+}
+
+func (s *OSSuite) TestSymlinkInsideBase(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	c.Assert(s.Fs.Symlink("../qux", "bar/link"), IsNil)
+	c.Assert(s.Fs.Symlink(filepath.Join(s.path, "qux", "foo"), "abs"), IsNil)
+
+	for _, name := range []string{"bar/link/foo", "abs"} {
+		fi, err := s.Fs.Stat(name)
+		c.Assert(err, IsNil, Commentf(name))
+		c.Assert(fi.Size(), Equals, int64(3))
+	}
+
+	f, err := s.Fs.Create("bar/link/bar")
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, filepath.Join("bar", "link", "bar"))
+	c.Assert(f.Close(), IsNil)
+
+	_, err = s.Fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+}
+
+func (s *OSSuite) TestSymlinkMaxLinks(c *C) {
+	fs := os.NewWithOptions(s.path, os.Options{MaxLinks: 2})
+	s.writeFile(c, "foo", "foo")
+	c.Assert(fs.Symlink("foo", "a"), IsNil)
+	c.Assert(fs.Symlink("a", "b"), IsNil)
+	c.Assert(fs.Symlink("b", "c"), IsNil)
+
+	_, err := fs.Stat("b")
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("c")
+	c.Assert(err, Equals, billy.ErrTooManyLinks)
+
+	_, err = fs.Lstat("c")
+	c.Assert(err, IsNil)
+}
+
+func (s *OSSuite) TestDirSymlinkCrossedBoundary(c *C) {
+	outside := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644), IsNil)
+	c.Assert(s.Fs.Symlink(outside, "link"), IsNil)
+
+	_, err := s.Fs.Open("link/secret")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+
+	fs := s.Fs.Dir("link")
+	c.Assert(fs.Base(), Equals, filepath.Join(s.path, "link"))
+	_, err = fs.Open("secret")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+
+	_, err = fs.Dir("qux").Stat("secret")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+
+	s.writeFile(c, "qux/foo", "foo")
+	c.Assert(s.Fs.Symlink("qux", "inside"), IsNil)
+	fs = s.Fs.Dir("inside")
+	f, err := fs.Open("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *OSSuite) TestDirCrossedBoundary(c *C) {
+	fs := s.Fs.Dir("../../qux")
+	c.Assert(fs.Base(), Equals, filepath.Join(s.path, "qux"))
+
+	_, err := fs.Create("../foo")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+}
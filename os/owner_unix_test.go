@@ -0,0 +1,67 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package os_test
+
+import (
+	stdos "os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/owner"
+)
+
+func (s *OSSuite) owner(c *C, filename string) (int, int) {
+	fi, err := stdos.Stat(filepath.Join(s.path, filename))
+	c.Assert(err, IsNil)
+
+	st := fi.Sys().(*syscall.Stat_t)
+	return int(st.Uid), int(st.Gid)
+}
+
+func (s *OSSuite) TestCopyTreeOwner(c *C) {
+	s.writeFile(c, "qux/foo", "foo")
+	uid, gid := s.owner(c, "qux/foo")
+
+	var seen [][2]int
+	err := billy.CopyTree(s.Fs.Dir("dst"), s.Fs.Dir("qux"), &billy.CopyOptions{
+		Owner: func(uid, gid int) (int, int, error) {
+			seen = append(seen, [2]int{uid, gid})
+			return billy.OwnerSquash(uid, gid)
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(seen, DeepEquals, [][2]int{{uid, gid}})
+
+	uid, gid = s.owner(c, "dst/foo")
+	c.Assert(uid, Equals, stdos.Getuid())
+	c.Assert(gid, Equals, stdos.Getgid())
+}
+
+func (s *OSSuite) TestCopyTreeOwnerByName(c *C) {
+	u, err := user.Current()
+	if err != nil {
+		c.Skip(err.Error())
+	}
+
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		c.Skip(err.Error())
+	}
+
+	s.writeFile(c, "qux/foo", "foo")
+	uid, gid := s.owner(c, "qux/foo")
+
+	err = billy.CopyTree(s.Fs.Dir("dst"), s.Fs.Dir("qux"), &billy.CopyOptions{
+		Owner: owner.ByName(map[int]string{uid: u.Username}, map[int]string{gid: g.Name}),
+	})
+	c.Assert(err, IsNil)
+
+	uid, gid = s.owner(c, "dst/foo")
+	c.Assert(strconv.Itoa(uid), Equals, u.Uid)
+	c.Assert(strconv.Itoa(gid), Equals, u.Gid)
+}
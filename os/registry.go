@@ -0,0 +1,34 @@
+package os
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.Register("file", open)
+}
+
+// open creates an OS filesystem from a file URI, such as file:///srv/data,
+// relative paths are resolved against the working directory.
+func open(u *url.URL) (billy.Filesystem, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("file URI with remote host %q", u.Host)
+	}
+
+	path := u.Path
+	// file:///C:/foo on Windows.
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+
+	abs, err := filepath.Abs(filepath.FromSlash(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return New(abs), nil
+}
@@ -0,0 +1,20 @@
+package os
+
+import (
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/readonlyfs"
+)
+
+// Snapshot returns a read-only Filesystem with the content of the given path
+// as it was at the moment of the call, so long-running readers are not
+// affected by concurrent writers. The whole tree is copied into memory, so it
+// is intended only for small trees.
+func (fs *OS) Snapshot(path string) (billy.Filesystem, error) {
+	m := memory.NewWithOptions(memory.Options{MaxLinks: fs.MaxLinks()})
+	if err := billy.CopyTree(m, fs.Dir(path), nil); err != nil {
+		return nil, err
+	}
+
+	return readonlyfs.New(m), nil
+}
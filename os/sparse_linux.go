@@ -0,0 +1,56 @@
+package os
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"srcd.works/go-billy.v1"
+)
+
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// Extents returns the regions of the file holding data, using SEEK_DATA and
+// SEEK_HOLE. The current offset of the file is preserved.
+func (f *osFile) Extents() ([]billy.Extent, error) {
+	pos, err := f.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.file.Seek(pos, io.SeekStart)
+
+	var extents []billy.Extent
+	var off int64
+	for {
+		data, err := f.file.Seek(off, seekData)
+		if err != nil {
+			if isENXIO(err) {
+				return extents, nil
+			}
+
+			return nil, err
+		}
+
+		hole, err := f.file.Seek(data, seekHole)
+		if err != nil {
+			return nil, err
+		}
+
+		extents = append(extents, billy.Extent{Offset: data, Length: hole - data})
+		off = hole
+	}
+}
+
+// isENXIO reports whether err is the error returned by SEEK_DATA when there is
+// no more data after the given offset.
+func isENXIO(err error) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+
+	return err == syscall.ENXIO
+}
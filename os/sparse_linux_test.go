@@ -0,0 +1,46 @@
+package os_test
+
+import (
+	"io"
+	stdos "os"
+	"path/filepath"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+func (s *OSSuite) TestCopyFileSparse(c *C) {
+	f, err := s.Fs.Create("sparse")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	_, err = f.Seek(8<<20, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(stdos.Truncate(filepath.Join(s.path, "sparse"), 16<<20), IsNil)
+
+	c.Assert(billy.CopyFile(s.Fs, "copy", s.Fs, "sparse"), IsNil)
+
+	fi, err := stdos.Stat(filepath.Join(s.path, "copy"))
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(16<<20))
+
+	orig, err := stdos.Stat(filepath.Join(s.path, "sparse"))
+	c.Assert(err, IsNil)
+	if orig.Sys().(*syscall.Stat_t).Blocks*512 >= orig.Size() {
+		c.Skip("filesystem without sparse files support")
+	}
+
+	c.Assert(fi.Sys().(*syscall.Stat_t).Blocks*512 < fi.Size(), Equals, true)
+
+	f, err = s.Fs.Open("copy")
+	c.Assert(err, IsNil)
+	b := make([]byte, 3)
+	_, err = f.(io.ReaderAt).ReadAt(b, 8<<20)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "bar")
+	c.Assert(f.Close(), IsNil)
+}
@@ -0,0 +1,99 @@
+package os
+
+import (
+	"io"
+	"sync"
+
+	"srcd.works/go-billy.v1"
+)
+
+var maxWatchChanges = 1024 * 16
+
+// Watcher is a billy.ChangeLog of the changes made, by any process, to the
+// files of an OS filesystem since it started watching them. It must be closed
+// once no longer used.
+type Watcher struct {
+	m       sync.Mutex
+	events  []billy.ChangeEvent
+	cursor  uint64
+	lost    uint64
+	watcher io.Closer
+}
+
+// Watch starts watching the files of the filesystem, returning the Watcher
+// reporting their changes. It's only supported on Linux, using inotify, on
+// other systems billy.ErrNotSupported is returned.
+func (fs *OS) Watch() (*Watcher, error) {
+	root, err := fs.abs("", true)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{}
+	if w.watcher, err = watch(root, w); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Watcher) record(op billy.ChangeOp, path, oldpath string) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if n := len(w.events); op == billy.ChangeWrite && n != 0 {
+		last := w.events[n-1]
+		if last.Path == path && (last.Op == billy.ChangeWrite || last.Op == billy.ChangeCreate) {
+			return
+		}
+	}
+
+	if len(w.events) >= maxWatchChanges {
+		// the oldest quarter is discarded at once, to amortize the copy.
+		w.events = append(w.events[:0], w.events[maxWatchChanges/4:]...)
+	}
+
+	w.cursor++
+	w.events = append(w.events, billy.ChangeEvent{
+		Cursor:  w.cursor,
+		Op:      op,
+		Path:    path,
+		OldPath: oldpath,
+	})
+}
+
+// overflow expires every cursor given so far, the system lost some changes.
+func (w *Watcher) overflow() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.cursor++
+	w.lost = w.cursor
+}
+
+// Changes returns the changes made after the given cursor. At most the latest
+// 16384 changes are retained, billy.ErrCursorExpired is returned for older
+// cursors, except zero, and for the ones given before the system dropped some
+// changes.
+func (w *Watcher) Changes(cursor uint64) ([]billy.ChangeEvent, uint64, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if cursor != 0 && (cursor < w.lost || len(w.events) != 0 && w.events[0].Cursor > cursor+1) {
+		return nil, w.cursor, billy.ErrCursorExpired
+	}
+
+	var changes []billy.ChangeEvent
+	for _, e := range w.events {
+		if e.Cursor > cursor {
+			changes = append(changes, e)
+		}
+	}
+
+	return changes, w.cursor, nil
+}
+
+// Close stops watching the files, the changes recorded are still returned.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
@@ -0,0 +1,209 @@
+package os
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"srcd.works/go-billy.v1"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW
+
+// inotify watches every directory under root, recording their changes in w.
+type inotify struct {
+	root string
+	w    *Watcher
+	fd   int
+	f    *os.File
+	// dirs holds the paths, relative to root, of the watched directories.
+	dirs map[int]string
+}
+
+func watch(root string, w *Watcher) (io.Closer, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	in := &inotify{
+		root: root,
+		w:    w,
+		fd:   fd,
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int]string),
+	}
+
+	if err := in.add("", false); err != nil {
+		in.f.Close()
+		return nil, err
+	}
+
+	go in.read()
+	return in.f, nil
+}
+
+// add watches the directory path and its subdirectories, the files already
+// in them are recorded as created if created is true, since they may have
+// been created before the directory was watched. The ones created meanwhile
+// may be recorded twice.
+func (in *inotify) add(path string, created bool) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, filepath.Join(in.root, path), watchMask)
+	if err != nil {
+		if path != "" && (err == syscall.ENOENT || err == syscall.ENOTDIR) {
+			// removed or replaced before being watched.
+			return nil
+		}
+
+		return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+
+	in.dirs[wd] = path
+	l, err := ioutil.ReadDir(filepath.Join(in.root, path))
+	if err != nil {
+		if path != "" && os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, fi := range l {
+		name := filepath.Join(path, fi.Name())
+		if created {
+			in.w.record(billy.ChangeCreate, name, "")
+		}
+
+		if fi.IsDir() {
+			if err := in.add(name, created); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// read records the events of the watched directories until the watcher is
+// closed.
+func (in *inotify) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := in.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		in.handle(buf[:n])
+	}
+}
+
+// handle records the events in b. A rename is reported by the system as a
+// pair of events sharing a cookie, the ones split across reads, or moving
+// files in or out of root, are recorded as a removal and a creation.
+func (in *inotify) handle(b []byte) {
+	var from *syscall.InotifyEvent
+	var fromPath string
+	moved := func() {
+		in.w.record(billy.ChangeRemove, fromPath, "")
+		if from.Mask&syscall.IN_ISDIR != 0 {
+			in.forget(fromPath)
+		}
+
+		from = nil
+	}
+
+	for len(b) >= syscall.SizeofInotifyEvent {
+		e := (*syscall.InotifyEvent)(unsafe.Pointer(&b[0]))
+		size := syscall.SizeofInotifyEvent + int(e.Len)
+		name := string(b[syscall.SizeofInotifyEvent:size])
+		for len(name) != 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+
+		b = b[size:]
+		if e.Mask&syscall.IN_Q_OVERFLOW != 0 {
+			in.w.overflow()
+			continue
+		}
+
+		if e.Mask&syscall.IN_IGNORED != 0 {
+			delete(in.dirs, int(e.Wd))
+			continue
+		}
+
+		dir, ok := in.dirs[int(e.Wd)]
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if from != nil && (e.Mask&syscall.IN_MOVED_TO == 0 || e.Cookie != from.Cookie) {
+			moved()
+		}
+
+		isDir := e.Mask&syscall.IN_ISDIR != 0
+		switch {
+		case e.Mask&syscall.IN_CREATE != 0:
+			in.w.record(billy.ChangeCreate, path, "")
+			if isDir {
+				in.add(path, true)
+			}
+		case e.Mask&syscall.IN_MODIFY != 0:
+			in.w.record(billy.ChangeWrite, path, "")
+		case e.Mask&syscall.IN_ATTRIB != 0:
+			in.w.record(billy.ChangeMetadata, path, "")
+		case e.Mask&syscall.IN_DELETE != 0:
+			in.w.record(billy.ChangeRemove, path, "")
+		case e.Mask&syscall.IN_MOVED_FROM != 0:
+			from, fromPath = e, path
+		case e.Mask&syscall.IN_MOVED_TO != 0 && from != nil:
+			in.w.record(billy.ChangeRename, path, fromPath)
+			if isDir {
+				in.move(fromPath, path)
+			}
+
+			from = nil
+		case e.Mask&syscall.IN_MOVED_TO != 0:
+			in.w.record(billy.ChangeCreate, path, "")
+			if isDir {
+				in.add(path, true)
+			}
+		}
+	}
+
+	if from != nil {
+		moved()
+	}
+}
+
+// move updates the paths of the directory renamed from oldpath to path and
+// its subdirectories.
+func (in *inotify) move(oldpath, path string) {
+	prefix := oldpath + string(filepath.Separator)
+	for wd, dir := range in.dirs {
+		switch {
+		case dir == oldpath:
+			in.dirs[wd] = path
+		case len(dir) > len(prefix) && dir[:len(prefix)] == prefix:
+			in.dirs[wd] = filepath.Join(path, dir[len(prefix):])
+		}
+	}
+}
+
+// forget stops watching the directory path, moved out of root, and its
+// subdirectories.
+func (in *inotify) forget(path string) {
+	prefix := path + string(filepath.Separator)
+	for wd, dir := range in.dirs {
+		if dir == path || len(dir) > len(prefix) && dir[:len(prefix)] == prefix {
+			syscall.InotifyRmWatch(in.fd, uint32(wd))
+			delete(in.dirs, wd)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package os_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/os"
+)
+
+// waitChanges returns the changes after cursor, without their cursors, once
+// there are n of them, and the following cursor.
+func waitChanges(c *C, w *os.Watcher, cursor uint64, n int) ([]billy.ChangeEvent, uint64) {
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		changes, next, err := w.Changes(cursor)
+		c.Assert(err, IsNil)
+		if len(changes) >= n || time.Now().After(deadline) {
+			for i := range changes {
+				changes[i].Cursor = 0
+			}
+
+			return changes, next
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *OSSuite) TestWatch(c *C) {
+	w, err := s.Fs.(*os.OS).Watch()
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	s.writeFile(c, "foo", "foo")
+	changes, cursor := waitChanges(c, w, 0, 1)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Op: billy.ChangeCreate, Path: "foo"},
+	})
+
+	c.Assert(s.Fs.Rename("foo", "bar"), IsNil)
+	c.Assert(s.Fs.(*os.OS).Chmod("bar", 0600), IsNil)
+	c.Assert(s.Fs.Remove("bar"), IsNil)
+
+	changes, _ = waitChanges(c, w, cursor, 3)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Op: billy.ChangeRename, Path: "bar", OldPath: "foo"},
+		{Op: billy.ChangeMetadata, Path: "bar"},
+		{Op: billy.ChangeRemove, Path: "bar"},
+	})
+}
+
+func (s *OSSuite) TestWatchDirectories(c *C) {
+	w, err := s.Fs.(*os.OS).Watch()
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	c.Assert(s.Fs.(*os.OS).MkdirAll("qux", 0755), IsNil)
+	changes, cursor := waitChanges(c, w, 0, 1)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Op: billy.ChangeCreate, Path: "qux"},
+	})
+
+	// the files created while the new directory starts being watched may be
+	// reported twice, by the scan of the directory and by the system.
+	time.Sleep(50 * time.Millisecond)
+	s.writeFile(c, "qux/foo", "foo")
+	changes, cursor = waitChanges(c, w, cursor, 1)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Op: billy.ChangeCreate, Path: "qux/foo"},
+	})
+
+	c.Assert(s.Fs.Rename("qux", "baz"), IsNil)
+	s.writeFile(c, "baz/bar", "bar")
+	c.Assert(s.Fs.Remove("baz/foo"), IsNil)
+
+	changes, _ = waitChanges(c, w, cursor, 3)
+	c.Assert(changes, DeepEquals, []billy.ChangeEvent{
+		{Op: billy.ChangeRename, Path: "baz", OldPath: "qux"},
+		{Op: billy.ChangeCreate, Path: "baz/bar"},
+		{Op: billy.ChangeRemove, Path: "baz/foo"},
+	})
+}
+
+func (s *OSSuite) TestWatchClosed(c *C) {
+	w, err := s.Fs.(*os.OS).Watch()
+	c.Assert(err, IsNil)
+
+	s.writeFile(c, "foo", "foo")
+	_, cursor := waitChanges(c, w, 0, 1)
+	c.Assert(w.Close(), IsNil)
+
+	s.writeFile(c, "bar", "bar")
+	time.Sleep(50 * time.Millisecond)
+
+	changes, _, err := w.Changes(cursor)
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package os
+
+import (
+	"io"
+
+	"srcd.works/go-billy.v1"
+)
+
+func watch(root string, w *Watcher) (io.Closer, error) {
+	return nil, billy.ErrNotSupported
+}
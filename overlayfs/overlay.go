@@ -0,0 +1,494 @@
+// Package overlayfs provides a billy filesystem layering a writable upper
+// filesystem over read-only lower ones, as a union mount. The writes are only
+// done in the upper layer, so the lower ones are never modified.
+package overlayfs // import "srcd.works/go-billy.v1/overlayfs"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+var (
+	errNotDirectory = errors.New("not a directory")
+	errNotEmpty     = errors.New("directory not empty")
+)
+
+// Overlay is a filesystem merging an upper layer, where the writes are done,
+// and lower layers, only read. A file is read from the topmost layer
+// containing it, and it's copied to the upper layer when opened for writing
+// or when its metadata is changed. Removing a file present in the lower
+// layers creates a whiteout in the upper one, a file named as the removed
+// one prefixed with ".wh.", hiding it. A directory recreated after being
+// removed is marked as opaque, with a ".wh..wh..opq" file, so the lower
+// layers are not merged in it. The names with the ".wh." prefix are
+// reserved, creating them returns billy.ErrInvalidName.
+type Overlay struct {
+	upper  billy.Filesystem
+	lowers []billy.Filesystem
+}
+
+// New returns a new Overlay filesystem, the lower layers are given from top
+// to bottom.
+func New(upper billy.Filesystem, lowers ...billy.Filesystem) *Overlay {
+	return &Overlay{upper: upper, lowers: lowers}
+}
+
+// NewCopyOnWrite returns a new Overlay filesystem reading from base, where
+// the first write to any file copies it to a private memory filesystem, so
+// base is never modified. It's intended for dry runs over a real checkout.
+func NewCopyOnWrite(base billy.Filesystem) *Overlay {
+	return New(memory.New(), base)
+}
+
+// Upper returns the upper layer, containing the files written and the
+// whiteouts of the removed ones.
+func (fs *Overlay) Upper() billy.Filesystem {
+	return fs.upper
+}
+
+// Create creates the named file in the upper layer.
+func (fs *Overlay) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading, from the topmost layer containing
+// it.
+func (fs *Overlay) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, if flag requests any kind of write access
+// the file is opened in the upper layer, copying it from the lower layers if
+// needed.
+func (fs *Overlay) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	l, fi, err := fs.lookup(filename)
+	if !isWrite(flag) {
+		if err != nil {
+			return nil, err
+		}
+
+		return l.OpenFile(filename, flag, perm)
+	}
+
+	if err := validName(filename); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case err == nil && isCreate(flag) && isExclusive(flag):
+		return nil, os.ErrExist
+	case err == nil && l != fs.upper && isTruncate(flag):
+		flag |= os.O_CREATE
+		perm = fi.Mode().Perm()
+	case err == nil && l != fs.upper:
+		if err := fs.copyUp(filename); err != nil {
+			return nil, err
+		}
+	case err == nil:
+	case os.IsNotExist(err) && isCreate(flag):
+		if err := fs.unwhiteout(filename, false); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	return fs.upper.OpenFile(filename, flag, perm)
+}
+
+// Stat returns the FileInfo of the named file, from the topmost layer
+// containing it. The symbolic links are followed in that layer.
+func (fs *Overlay) Stat(filename string) (billy.FileInfo, error) {
+	l, _, err := fs.lookup(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Stat(filename)
+}
+
+// Lstat returns the FileInfo of the named file, from the topmost layer
+// containing it, without following symbolic links.
+func (fs *Overlay) Lstat(filename string) (billy.FileInfo, error) {
+	_, fi, err := fs.lookup(filename)
+	return fi, err
+}
+
+// ReadDir returns the merged entries of the directory in all the layers,
+// sorted by name. An entry present in several layers is taken from the
+// topmost one.
+func (fs *Overlay) ReadDir(dir string) ([]billy.FileInfo, error) {
+	fi, err := fs.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: errNotDirectory}
+	}
+
+	_, opaque := fs.hidden(dir)
+	seen := make(map[string]bool)
+	var entries []billy.FileInfo
+	for i, l := range append([]billy.Filesystem{fs.upper}, fs.lowers...) {
+		if i != 0 && opaque {
+			break
+		}
+
+		infos, err := l.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fi := range infos {
+			name := fi.Name()
+			if i == 0 && isWhiteout(name) {
+				if name == opaqueName {
+					opaque = true
+				}
+
+				seen[name[len(whiteoutPrefix):]] = true
+				continue
+			}
+
+			if !seen[name] {
+				seen[name] = true
+				entries = append(entries, fi)
+			}
+		}
+	}
+
+	sort.Sort(byName(entries))
+	return entries, nil
+}
+
+// TempFile creates a temporary file in the upper layer.
+func (fs *Overlay) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.unwhiteout(dir, true); err != nil {
+		return nil, err
+	}
+
+	return fs.upper.TempFile(dir, prefix)
+}
+
+// Rename renames a file, copying it to the upper layer if needed, and
+// creating a whiteout for the old name if it's present in the lower layers.
+// The directories present in the lower layers can't be renamed, returning
+// billy.ErrNotSupported.
+func (fs *Overlay) Rename(from, to string) error {
+	_, fi, err := fs.lookup(from)
+	if err != nil {
+		return err
+	}
+
+	if err := validName(to); err != nil {
+		return err
+	}
+
+	lower := fs.inLowers(from)
+	if fi.IsDir() && lower {
+		return billy.ErrNotSupported
+	}
+
+	if err := fs.copyUp(from); err != nil {
+		return err
+	}
+
+	if err := fs.unwhiteout(to, fi.IsDir()); err != nil {
+		return err
+	}
+
+	if err := fs.upper.Rename(from, to); err != nil {
+		return err
+	}
+
+	if lower {
+		return fs.whiteout(from)
+	}
+
+	return nil
+}
+
+// Remove removes the named file or empty directory, creating a whiteout if
+// it's present in the lower layers.
+func (fs *Overlay) Remove(filename string) error {
+	l, fi, err := fs.lookup(filename)
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		entries, err := fs.ReadDir(filename)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) != 0 {
+			return &os.PathError{Op: "remove", Path: filename, Err: errNotEmpty}
+		}
+	}
+
+	lower := fs.inLowers(filename)
+	if l == fs.upper {
+		if err := fs.removeUpper(filename, fi.IsDir()); err != nil {
+			return err
+		}
+	}
+
+	if lower {
+		return fs.whiteout(filename)
+	}
+
+	return nil
+}
+
+// removeUpper removes the named file from the upper layer, with the
+// whiteouts it contains if it's a directory.
+func (fs *Overlay) removeUpper(filename string, dir bool) error {
+	if dir {
+		infos, err := fs.upper.ReadDir(filename)
+		if err != nil {
+			return err
+		}
+
+		for _, fi := range infos {
+			if err := fs.upper.Remove(fs.upper.Join(filename, fi.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	err := fs.upper.Remove(filename)
+	if dir && os.IsNotExist(err) {
+		// backends with implicit directories remove them with their
+		// last entry.
+		return nil
+	}
+
+	return err
+}
+
+// Symlink creates a symbolic link in the upper layer.
+func (fs *Overlay) Symlink(target, link string) error {
+	if _, _, err := fs.lookup(link); err == nil {
+		return os.ErrExist
+	}
+
+	if err := validName(link); err != nil {
+		return err
+	}
+
+	if err := fs.unwhiteout(link, false); err != nil {
+		return err
+	}
+
+	return fs.upper.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link, from the topmost
+// layer containing it.
+func (fs *Overlay) Readlink(link string) (string, error) {
+	l, _, err := fs.lookup(link)
+	if err != nil {
+		return "", err
+	}
+
+	return l.Readlink(link)
+}
+
+// MkdirAll creates a directory and its parents in the upper layer, unless
+// it's already present in any layer.
+func (fs *Overlay) MkdirAll(path string, perm os.FileMode) error {
+	if _, fi, err := fs.lookup(path); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+
+		return &os.PathError{Op: "mkdir", Path: path, Err: errNotDirectory}
+	}
+
+	if err := validName(path); err != nil {
+		return err
+	}
+
+	if err := fs.unwhiteout(path, true); err != nil {
+		return err
+	}
+
+	return fs.upper.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file, copying it to the upper layer if needed.
+func (fs *Overlay) Chmod(name string, mode os.FileMode) error {
+	if err := fs.copyUp(name); err != nil {
+		return err
+	}
+
+	return fs.upper.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file, copying it to the upper layer if
+// needed.
+func (fs *Overlay) Chtimes(name string, atime, mtime time.Time) error {
+	if err := fs.copyUp(name); err != nil {
+		return err
+	}
+
+	return fs.upper.Chtimes(name, atime, mtime)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Overlay) Join(elem ...string) string {
+	return fs.upper.Join(elem...)
+}
+
+// Dir returns a new Overlay filesystem rooted at the given path of every
+// layer. The whiteouts and opaque directories above the path are not
+// considered.
+func (fs *Overlay) Dir(path string) billy.Filesystem {
+	lowers := make([]billy.Filesystem, len(fs.lowers))
+	for i, l := range fs.lowers {
+		lowers[i] = l.Dir(path)
+	}
+
+	return New(fs.upper.Dir(path), lowers...)
+}
+
+// Base returns the base path of the upper layer.
+func (fs *Overlay) Base() string {
+	return fs.upper.Base()
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the upper layer, as billy.MaxLinks.
+func (fs *Overlay) MaxLinks() int {
+	return billy.MaxLinks(fs.upper)
+}
+
+// Close closes every layer, from the upper to the bottom one, as
+// billy.Close, returning the first error.
+func (fs *Overlay) Close() error {
+	err := billy.Close(fs.upper)
+	for _, l := range fs.lowers {
+		if lerr := billy.Close(l); err == nil {
+			err = lerr
+		}
+	}
+
+	return err
+}
+
+// lookup returns the topmost layer containing the named file, and its
+// FileInfo, without following symbolic links.
+func (fs *Overlay) lookup(filename string) (billy.Filesystem, billy.FileInfo, error) {
+	hidden, opaque := fs.hidden(filename)
+	if hidden {
+		return nil, nil, os.ErrNotExist
+	}
+
+	layers := []billy.Filesystem{fs.upper}
+	if !opaque {
+		layers = append(layers, fs.lowers...)
+	}
+
+	for _, l := range layers {
+		fi, err := l.Lstat(filename)
+		if err == nil {
+			return l, fi, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+
+	return nil, nil, os.ErrNotExist
+}
+
+// inLowers returns true if the named file is present in the lower layers, and
+// not hidden by an opaque directory.
+func (fs *Overlay) inLowers(filename string) bool {
+	if _, opaque := fs.hidden(filename); opaque {
+		return false
+	}
+
+	for _, l := range fs.lowers {
+		if _, err := l.Lstat(filename); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyUp copies the named file from the topmost layer containing it to the
+// upper one, the directories are created and the symbolic links recreated.
+func (fs *Overlay) copyUp(filename string) error {
+	l, fi, err := fs.lookup(filename)
+	if err != nil || l == fs.upper {
+		return err
+	}
+
+	switch {
+	case fi.IsDir():
+		return fs.upper.MkdirAll(filename, fi.Mode().Perm())
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := l.Readlink(filename)
+		if err != nil {
+			return err
+		}
+
+		return fs.upper.Symlink(target, filename)
+	}
+
+	src, err := l.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, err := fs.upper.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+func isCreate(flag int) bool {
+	return flag&os.O_CREATE != 0
+}
+
+func isExclusive(flag int) bool {
+	return flag&os.O_EXCL != 0
+}
+
+func isTruncate(flag int) bool {
+	return flag&os.O_TRUNC != 0
+}
+
+type byName []billy.FileInfo
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Less(i, j int) bool { return l[i].Name() < l[j].Name() }
+func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -0,0 +1,186 @@
+package overlayfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New(), memory.New())
+}
+
+type OverlaySuite struct {
+	upper, lower *memory.Memory
+	fs           *Overlay
+}
+
+var _ = Suite(&OverlaySuite{})
+
+func (s *OverlaySuite) SetUpTest(c *C) {
+	s.upper = memory.New()
+	s.lower = memory.New()
+	writeFile(c, s.lower, "foo", "foo")
+	writeFile(c, s.lower, "qux/bar", "bar")
+	writeFile(c, s.lower, "qux/baz", "baz")
+
+	s.fs = New(s.upper, s.lower)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
+
+func readDirNames(c *C, fs billy.Filesystem, dir string) []string {
+	infos, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
+
+func (s *OverlaySuite) TestRead(c *C) {
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar")
+	c.Assert(readDirNames(c, s.fs, ""), DeepEquals, []string{"foo", "qux"})
+}
+
+func (s *OverlaySuite) TestCopyUp(c *C) {
+	f, err := s.fs.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(" bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "foo bar")
+	c.Assert(readFile(c, s.upper, "foo"), Equals, "foo bar")
+	c.Assert(readFile(c, s.lower, "foo"), Equals, "foo")
+}
+
+func (s *OverlaySuite) TestMergeDir(c *C) {
+	writeFile(c, s.fs, "qux/new", "new")
+	writeFile(c, s.fs, "qux/bar", "changed")
+
+	c.Assert(readDirNames(c, s.fs, "qux"), DeepEquals, []string{"bar", "baz", "new"})
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "changed")
+	c.Assert(readDirNames(c, s.lower, "qux"), DeepEquals, []string{"bar", "baz"})
+}
+
+func (s *OverlaySuite) TestRemove(c *C) {
+	c.Assert(s.fs.Remove("qux/bar"), IsNil)
+
+	_, err := s.fs.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(readDirNames(c, s.fs, "qux"), DeepEquals, []string{"baz"})
+	c.Assert(readFile(c, s.lower, "qux/bar"), Equals, "bar")
+
+	writeFile(c, s.fs, "qux/bar", "new")
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "new")
+	c.Assert(readDirNames(c, s.fs, "qux"), DeepEquals, []string{"bar", "baz"})
+}
+
+func (s *OverlaySuite) TestRemoveDirOpaque(c *C) {
+	c.Assert(s.fs.Remove("qux"), NotNil)
+	c.Assert(s.fs.Remove("qux/bar"), IsNil)
+	c.Assert(s.fs.Remove("qux/baz"), IsNil)
+	c.Assert(s.fs.Remove("qux"), IsNil)
+	c.Assert(readDirNames(c, s.fs, ""), DeepEquals, []string{"foo"})
+
+	writeFile(c, s.fs, "qux/new", "new")
+	c.Assert(readDirNames(c, s.fs, "qux"), DeepEquals, []string{"new"})
+
+	_, err := s.fs.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *OverlaySuite) TestRename(c *C) {
+	c.Assert(s.fs.Rename("foo", "qux/foo"), IsNil)
+
+	c.Assert(readFile(c, s.fs, "qux/foo"), Equals, "foo")
+	c.Assert(readDirNames(c, s.fs, ""), DeepEquals, []string{"qux"})
+	c.Assert(readFile(c, s.lower, "foo"), Equals, "foo")
+
+	c.Assert(s.fs.Rename("qux", "bar"), Equals, billy.ErrNotSupported)
+}
+
+func (s *OverlaySuite) TestChmod(c *C) {
+	c.Assert(s.fs.Chmod("foo", 0600), IsNil)
+
+	fi, err := s.fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0600))
+	c.Assert(readFile(c, s.upper, "foo"), Equals, "foo")
+}
+
+func (s *OverlaySuite) TestReservedName(c *C) {
+	_, err := s.fs.Create("qux/.wh.bar")
+	c.Assert(err, Equals, billy.ErrInvalidName)
+}
+
+func (s *OverlaySuite) TestCompose(c *C) {
+	lower, err := billy.Open("mem://overlayfs-lower")
+	c.Assert(err, IsNil)
+	writeFile(c, lower, "bar", "bar")
+
+	fs, err := billy.Compose(&billy.Config{
+		Backend: "mem://overlayfs-upper",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "overlay",
+			Options: map[string]string{"lower": "mem://overlayfs-lower"},
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, fs, "bar"), Equals, "bar")
+}
+
+func (s *OverlaySuite) TestCopyOnWrite(c *C) {
+	fs := NewCopyOnWrite(s.lower)
+	writeFile(c, fs, "foo", "changed")
+	c.Assert(fs.Remove("qux/bar"), IsNil)
+
+	c.Assert(readFile(c, fs, "foo"), Equals, "changed")
+	c.Assert(readFile(c, fs.Upper(), "foo"), Equals, "changed")
+	c.Assert(readFile(c, s.lower, "foo"), Equals, "foo")
+	c.Assert(readFile(c, s.lower, "qux/bar"), Equals, "bar")
+
+	cow, err := billy.Compose(&billy.Config{
+		Backend:  "mem://overlayfs-cow",
+		Wrappers: []billy.WrapperConfig{{Name: "cow"}},
+	})
+	c.Assert(err, IsNil)
+	writeFile(c, cow, "foo", "foo")
+
+	base, err := billy.Open("mem://overlayfs-cow")
+	c.Assert(err, IsNil)
+	_, err = base.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
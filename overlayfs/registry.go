@@ -0,0 +1,41 @@
+package overlayfs
+
+import (
+	"errors"
+	"strings"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("overlay", wrap)
+	billy.RegisterWrapper("cow", wrapCopyOnWrite)
+}
+
+// wrap returns an Overlay filesystem using fs as upper layer and the URIs
+// given by the lower option, separated by spaces from top to bottom and
+// opened with billy.Open, as lower layers.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	uris := strings.Fields(opts["lower"])
+	if len(uris) == 0 {
+		return nil, errors.New("missing lower option")
+	}
+
+	lowers := make([]billy.Filesystem, len(uris))
+	for i, uri := range uris {
+		l, err := billy.Open(uri)
+		if err != nil {
+			return nil, err
+		}
+
+		lowers[i] = l
+	}
+
+	return New(fs, lowers...), nil
+}
+
+// wrapCopyOnWrite returns a copy-on-write Overlay filesystem over fs, it
+// takes no options.
+func wrapCopyOnWrite(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	return NewCopyOnWrite(fs), nil
+}
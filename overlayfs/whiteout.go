@@ -0,0 +1,113 @@
+package overlayfs
+
+import (
+	"path"
+	"strings"
+
+	"srcd.works/go-billy.v1"
+)
+
+const (
+	// whiteoutPrefix prefixes the name of a removed file in its whiteout.
+	whiteoutPrefix = ".wh."
+	// opaqueName is the name of the file marking a directory as opaque.
+	opaqueName = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+func isWhiteout(name string) bool {
+	return strings.HasPrefix(name, whiteoutPrefix)
+}
+
+// validName returns billy.ErrInvalidName if the base name of filename is
+// reserved for the whiteouts.
+func validName(filename string) error {
+	if isWhiteout(path.Base(clean(filename))) {
+		return billy.ErrInvalidName
+	}
+
+	return nil
+}
+
+// hidden returns whether the named file, or any of its parents, has a
+// whiteout, and whether any of its parents is opaque, hiding the lower
+// layers.
+func (fs *Overlay) hidden(filename string) (hidden, opaque bool) {
+	var dir string
+	for _, part := range split(filename) {
+		if fs.exists(path.Join(dir, opaqueName)) {
+			opaque = true
+		}
+
+		if fs.exists(path.Join(dir, whiteoutPrefix+part)) {
+			return true, opaque
+		}
+
+		dir = path.Join(dir, part)
+	}
+
+	return false, opaque
+}
+
+// whiteout creates the whiteout of the named file in the upper layer.
+func (fs *Overlay) whiteout(filename string) error {
+	filename = clean(filename)
+	f, err := fs.upper.Create(path.Join(path.Dir(filename), whiteoutPrefix+path.Base(filename)))
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// unwhiteout removes the whiteouts of the named file and its parents, before
+// creating it. The parents with a whiteout are made opaque, as is the file
+// if dir is true, so the removed content of the lower layers doesn't
+// reappear.
+func (fs *Overlay) unwhiteout(filename string, dir bool) error {
+	parts := split(filename)
+	var parent string
+	for i, part := range parts {
+		wh := path.Join(parent, whiteoutPrefix+part)
+		parent = path.Join(parent, part)
+		if !fs.exists(wh) {
+			continue
+		}
+
+		if err := fs.upper.Remove(wh); err != nil {
+			return err
+		}
+
+		if i == len(parts)-1 && !dir {
+			continue
+		}
+
+		f, err := fs.upper.Create(path.Join(parent, opaqueName))
+		if err != nil {
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exists returns true if the named file is present in the upper layer.
+func (fs *Overlay) exists(filename string) bool {
+	_, err := fs.upper.Lstat(filename)
+	return err == nil
+}
+
+func clean(filename string) string {
+	return strings.Trim(path.Clean("/"+filename), "/")
+}
+
+func split(filename string) []string {
+	if filename = clean(filename); filename == "" {
+		return nil
+	}
+
+	return strings.Split(filename, "/")
+}
@@ -0,0 +1,52 @@
+package billy
+
+import "os"
+
+// OwnerMap maps the numeric user and group ids of a file in the source of a
+// copy to the ones used in the destination.
+type OwnerMap func(uid, gid int) (int, int, error)
+
+// OwnerPassthrough keeps the numeric ids untouched.
+func OwnerPassthrough(uid, gid int) (int, int, error) {
+	return uid, gid, nil
+}
+
+// OwnerSquash maps every file to the current user and group.
+func OwnerSquash(uid, gid int) (int, int, error) {
+	return os.Getuid(), os.Getgid(), nil
+}
+
+// OwnerTable returns an OwnerMap translating the ids using the given tables,
+// the ids not present in them are passed through.
+func OwnerTable(users, groups map[int]int) OwnerMap {
+	return func(uid, gid int) (int, int, error) {
+		if id, ok := users[uid]; ok {
+			uid = id
+		}
+
+		if id, ok := groups[gid]; ok {
+			gid = id
+		}
+
+		return uid, gid, nil
+	}
+}
+
+func copyOwner(fs Filesystem, path string, info FileInfo, m OwnerMap) error {
+	c, ok := fs.(Chowner)
+	if !ok {
+		return nil
+	}
+
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+
+	uid, gid, err := m(uid, gid)
+	if err != nil {
+		return err
+	}
+
+	return c.Chown(path, uid, gid)
+}
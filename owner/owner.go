@@ -0,0 +1,43 @@
+// Package owner provides the billy.OwnerMap translating the ownership by
+// name, kept apart from the billy package since looking up the user
+// database requires cgo on some platforms.
+package owner // import "srcd.works/go-billy.v1/owner"
+
+import (
+	"os/user"
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ByName returns a billy.OwnerMap translating the source ids to names, using
+// the given tables built from the user database of the source system, and
+// looking up those names in the local user database. The ids not present in
+// the tables are passed through.
+func ByName(users, groups map[int]string) billy.OwnerMap {
+	return func(uid, gid int) (int, int, error) {
+		if name, ok := users[uid]; ok {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return -1, -1, err
+			}
+
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, err
+			}
+		}
+
+		if name, ok := groups[gid]; ok {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return -1, -1, err
+			}
+
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, err
+			}
+		}
+
+		return uid, gid, nil
+	}
+}
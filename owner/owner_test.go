@@ -0,0 +1,27 @@
+package owner
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type OwnerSuite struct{}
+
+var _ = Suite(&OwnerSuite{})
+
+func (s *OwnerSuite) TestByNameUnknown(c *C) {
+	m := ByName(map[int]string{1000: "non-existent-billy-user"}, nil)
+	_, _, err := m(1000, 100)
+	c.Assert(err, NotNil)
+}
+
+func (s *OwnerSuite) TestByNamePassthrough(c *C) {
+	m := ByName(nil, nil)
+	uid, gid, err := m(1000, 100)
+	c.Assert(err, IsNil)
+	c.Assert(uid, Equals, 1000)
+	c.Assert(gid, Equals, 100)
+}
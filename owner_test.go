@@ -0,0 +1,33 @@
+package billy_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type OwnerSuite struct{}
+
+var _ = Suite(&OwnerSuite{})
+
+func (s *OwnerSuite) TestOwnerTable(c *C) {
+	m := billy.OwnerTable(map[int]int{1000: 1001}, map[int]int{100: 101})
+
+	uid, gid, err := m(1000, 100)
+	c.Assert(err, IsNil)
+	c.Assert(uid, Equals, 1001)
+	c.Assert(gid, Equals, 101)
+
+	uid, gid, err = m(42, 43)
+	c.Assert(err, IsNil)
+	c.Assert(uid, Equals, 42)
+	c.Assert(gid, Equals, 43)
+}
+
+func (s *OwnerSuite) TestOwnerSquash(c *C) {
+	uid, gid, err := billy.OwnerSquash(1000, 100)
+	c.Assert(err, IsNil)
+	c.Assert(uid, Equals, os.Getuid())
+	c.Assert(gid, Equals, os.Getgid())
+}
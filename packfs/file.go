@@ -0,0 +1,113 @@
+package packfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/pack"
+)
+
+var errWriteNotAllowed = errors.New("write not supported")
+
+// file is a staged file, named as opened.
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Filename() string {
+	return f.name
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return f.File.(io.ReaderAt).ReadAt(p, off)
+}
+
+// writer is a staged file open for writing, stored or left pending to be
+// packed once closed.
+type writer struct {
+	file
+	s   *state
+	key string
+	// shadows is set when the file was stored when opened, so the stored
+	// version is removed if it ends up packed.
+	shadows bool
+}
+
+func (w *writer) Close() error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+
+	return w.s.closed(w)
+}
+
+// reader is a packed file open for reading, its content is read at once.
+type reader struct {
+	billy.BaseFile
+	*bytes.Reader
+	fi billy.FileInfo
+}
+
+func newReader(filename string, fi billy.FileInfo, content []byte) *reader {
+	return &reader{
+		BaseFile: billy.BaseFile{BaseFilename: filename},
+		Reader:   bytes.NewReader(content),
+		fi:       fi,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return r.Reader.Read(p)
+}
+
+func (r *reader) ReadAt(p []byte, off int64) (int, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return r.Reader.ReadAt(p, off)
+}
+
+func (r *reader) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: r.Filename(), Err: errWriteNotAllowed}
+}
+
+func (r *reader) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: r.Filename(), Err: errWriteNotAllowed}
+}
+
+func (r *reader) Stat() (billy.FileInfo, error) { return r.fi, nil }
+func (r *reader) Sync() error                   { return nil }
+func (r *reader) Lock() error                   { return billy.ErrNotSupported }
+func (r *reader) Unlock() error                 { return billy.ErrNotSupported }
+
+func (r *reader) Close() error {
+	if r.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	r.Closed = true
+	return nil
+}
+
+// entryInfo is the FileInfo of a packed file.
+type entryInfo struct {
+	name string
+	e    pack.Entry
+}
+
+func (fi *entryInfo) Name() string       { return fi.name }
+func (fi *entryInfo) Size() int64        { return fi.e.Size }
+func (fi *entryInfo) Mode() os.FileMode  { return fi.e.Mode }
+func (fi *entryInfo) ModTime() time.Time { return fi.e.ModTime }
+func (fi *entryInfo) IsDir() bool        { return false }
+func (fi *entryInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,341 @@
+// Package packfs provides a billy filesystem wrapper for the object-store
+// backends, such as s3fs, packing the small files into larger segment objects
+// with an index, in the format of internal/pack. The workloads with thousands
+// of tiny files, such as git repositories, are prohibitively slow with one
+// object per file, packed they are written with a request per segment and
+// read with a single ranged request each.
+package packfs // import "srcd.works/go-billy.v1/packfs"
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/pack"
+)
+
+// Options holds the configuration of a Pack filesystem.
+type Options struct {
+	// Threshold is the maximum size of the files packed, the larger ones
+	// are stored as files of the wrapped filesystem, 64KiB by default.
+	Threshold int64
+	// SegmentSize is the size of the small files written after which they
+	// are packed into a segment, without waiting for Flush, 8MiB by
+	// default.
+	SegmentSize int64
+	// Dir is the directory of the wrapped filesystem holding the segments
+	// and the index, ".pack" by default. It's hidden from the listings of
+	// the root, and must not be used otherwise.
+	Dir string
+}
+
+var defaultOptions = Options{
+	Threshold:   64 << 10,
+	SegmentSize: 8 << 20,
+	Dir:         ".pack",
+}
+
+// Pack wraps a billy.Filesystem, usually an object-store backend, packing
+// the files up to Options.Threshold into segments. The directories and the
+// larger files are the ones of the wrapped filesystem, which must keep the
+// empty directories.
+//
+// The small files written are kept in memory, and read from there, until
+// they are packed into a segment by Flush or Close, or once
+// Options.SegmentSize bytes are pending, when the index is saved. They, and
+// the changes to the packed files, are lost if the program crashes before.
+// Reading a packed file reads its range of the segment at once. Renaming a
+// packed file only changes the index, and removing it leaves its content in
+// the segment until all the files of the segment are gone, when the segment
+// is deleted. The index is owned by a single Pack, the filesystem must not
+// be written by others concurrently. Symbolic links aren't supported.
+type Pack struct {
+	fs     billy.Filesystem
+	s      *state
+	prefix string
+}
+
+// New returns a new Pack filesystem wrapping fs, loading the index saved in
+// it, if any. If opts is nil the default options are used, as for their zero
+// fields.
+func New(fs billy.Filesystem, opts *Options) (*Pack, error) {
+	o := defaultOptions
+	if opts != nil {
+		if opts.Threshold > 0 {
+			o.Threshold = opts.Threshold
+		}
+
+		if opts.SegmentSize > 0 {
+			o.SegmentSize = opts.SegmentSize
+		}
+
+		if opts.Dir != "" {
+			o.Dir = clean(opts.Dir)
+		}
+	}
+
+	s, err := newState(fs, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pack{fs: fs, s: s}, nil
+}
+
+func (fs *Pack) key(filename string) string {
+	return clean(path.Join(fs.prefix, slash(filename)))
+}
+
+// Create creates a file and opens it with standard permissions
+// and modes O_RDWR, O_CREATE and O_TRUNC.
+func (fs *Pack) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens a file in read-only mode.
+func (fs *Pack) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, if flag os.O_CREATE is set its parent
+// directories are created. The files opened for writing are kept in memory
+// until closed, the ones not packed yet are copied there before.
+func (fs *Pack) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	key := fs.key(filename)
+	if !isWrite(flag) {
+		return fs.s.openRead(fs.fs, key, filename)
+	}
+
+	return fs.s.openWrite(fs.fs, key, filename, flag, perm)
+}
+
+// Stat returns the FileInfo of the named file or directory.
+func (fs *Pack) Stat(filename string) (billy.FileInfo, error) {
+	fs.s.m.RLock()
+	defer fs.s.m.RUnlock()
+
+	_, fi, err := fs.s.lookup(fs.fs, fs.key(filename), filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fi, nil
+}
+
+// Lstat returns the FileInfo of the named file, as Stat, since there are no
+// symbolic links.
+func (fs *Pack) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.Stat(filename)
+}
+
+// ReadDir returns the entries of the given directory, sorted by name: the
+// ones of the wrapped filesystem, and the files packed and pending.
+func (fs *Pack) ReadDir(dir string) ([]billy.FileInfo, error) {
+	fs.s.m.RLock()
+	defer fs.s.m.RUnlock()
+
+	key := fs.key(dir)
+	l, err := fs.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]billy.FileInfo, len(l))
+	for _, fi := range l {
+		if key == "" && fi.Name() == fs.s.opts.Dir {
+			continue
+		}
+
+		entries[fi.Name()] = fi
+	}
+
+	parent := key
+	if parent == "" {
+		parent = "."
+	}
+
+	for _, name := range fs.s.idx.Names() {
+		if path.Dir(name) == parent {
+			e, _ := fs.s.idx.Get(name)
+			entries[path.Base(name)] = &entryInfo{name: path.Base(name), e: e}
+		}
+	}
+
+	if l, err := fs.s.staged.ReadDir(key); err == nil {
+		for _, fi := range l {
+			if !fi.IsDir() {
+				entries[fi.Name()] = fi
+			}
+		}
+	}
+
+	infos := make([]billy.FileInfo, 0, len(entries))
+	for _, fi := range entries {
+		infos = append(infos, fi)
+	}
+
+	sort.Sort(byName(infos))
+	return infos, nil
+}
+
+// TempFile creates a new temporal file, with a random name starting with
+// prefix, in dir.
+func (fs *Pack) TempFile(dir, prefix string) (billy.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := fs.Join(dir, prefix+strconv.FormatInt(rand.Int63(), 10))
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+
+		return f, err
+	}
+
+	return nil, fmt.Errorf("temp file in %s: too many attempts", dir)
+}
+
+// Rename moves a file or a directory from _from_ to _to_. The packed files
+// are moved only in the index.
+func (fs *Pack) Rename(from, to string) error {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	return fs.s.rename(fs.fs, fs.key(from), fs.key(to), from, to)
+}
+
+// Remove removes a file or an empty directory.
+func (fs *Pack) Remove(filename string) error {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	return fs.s.remove(fs.fs, fs.key(filename), filename)
+}
+
+// Symlink returns billy.ErrNotSupported.
+func (fs *Pack) Symlink(target, link string) error {
+	return billy.ErrNotSupported
+}
+
+// Readlink returns billy.ErrNotSupported.
+func (fs *Pack) Readlink(link string) (string, error) {
+	return "", billy.ErrNotSupported
+}
+
+// MkdirAll creates a directory and its parents in the wrapped filesystem.
+func (fs *Pack) MkdirAll(path string, perm os.FileMode) error {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	if err := fs.s.checkParents("mkdir", fs.key(path), path); err != nil {
+		return err
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of the named file.
+func (fs *Pack) Chmod(name string, mode os.FileMode) error {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	return fs.s.change(fs.fs, fs.key(name), name, func(e *pack.Entry) {
+		e.Mode = e.Mode&^os.ModePerm | mode.Perm()
+	}, func(fs billy.Filesystem, name string) error {
+		return fs.Chmod(name, mode)
+	})
+}
+
+// Chtimes changes the modification time of the named file, the access time
+// of the packed ones is ignored.
+func (fs *Pack) Chtimes(name string, atime, mtime time.Time) error {
+	fs.s.m.Lock()
+	defer fs.s.m.Unlock()
+
+	return fs.s.change(fs.fs, fs.key(name), name, func(e *pack.Entry) {
+		e.ModTime = mtime
+	}, func(fs billy.Filesystem, name string) error {
+		return fs.Chtimes(name, atime, mtime)
+	})
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Pack) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Pack filesystem rooted at the given path, sharing the
+// index and the pending files with the current one.
+func (fs *Pack) Dir(p string) billy.Filesystem {
+	return &Pack{
+		fs:     fs.fs.Dir(p),
+		s:      fs.s,
+		prefix: fs.key(p),
+	}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Pack) Base() string {
+	return fs.fs.Base()
+}
+
+// Flush packs the pending files into a segment, saves the index and deletes
+// the segments no longer used, then flushes the wrapped filesystem, as
+// billy.Flush. The files still open aren't packed.
+func (fs *Pack) Flush(ctx context.Context) error {
+	fs.s.m.Lock()
+	err := fs.s.pack()
+	fs.s.m.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return billy.Flush(ctx, fs.s.fs)
+}
+
+// Close packs the pending files, as Flush, and closes the wrapped
+// filesystem, as billy.Close, even if packing fails.
+func (fs *Pack) Close() error {
+	fs.s.m.Lock()
+	err := fs.s.pack()
+	fs.s.m.Unlock()
+
+	if cerr := billy.Close(fs.s.fs); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// slash converts the separators of filename to slashes, since billy
+// filenames may use any of them.
+func slash(filename string) string {
+	return strings.Replace(filename, `\`, "/", -1)
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+var (
+	errIsDirectory  = syscall.EISDIR
+	errNotDirectory = syscall.ENOTDIR
+	errNotEmpty     = syscall.ENOTEMPTY
+)
+
+type byName []billy.FileInfo
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Less(i, j int) bool { return l[i].Name() < l[j].Name() }
+func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -0,0 +1,191 @@
+package packfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	osfs "srcd.works/go-billy.v1/os"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	fs, err := New(memory.New(), &Options{Threshold: 16, SegmentSize: 64})
+	c.Assert(err, IsNil)
+	s.FilesystemSuite.Fs = fs
+}
+
+type PackSuite struct {
+	backend billy.Filesystem
+	fs      *Pack
+}
+
+var _ = Suite(&PackSuite{})
+
+func (s *PackSuite) SetUpTest(c *C) {
+	s.backend = memory.New()
+	s.fs = s.open(c)
+}
+
+func (s *PackSuite) open(c *C) *Pack {
+	fs, err := New(s.backend, &Options{Threshold: 8})
+	c.Assert(err, IsNil)
+	return fs
+}
+
+func (s *PackSuite) TestPack(c *C) {
+	writeFile(c, s.fs, "foo", "foo")
+	writeFile(c, s.fs, "qux/bar", "bar")
+	writeFile(c, s.fs, "qux/large", "large content")
+
+	_, err := s.backend.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "foo")
+
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+	c.Assert(names(c, s.backend, ""), DeepEquals, []string{".pack", "qux"})
+	c.Assert(names(c, s.backend, "qux"), DeepEquals, []string{"large"})
+	c.Assert(names(c, s.backend, ".pack"), HasLen, 2)
+
+	fs := s.open(c)
+	c.Assert(names(c, fs, ""), DeepEquals, []string{"foo", "qux"})
+	c.Assert(names(c, fs, "qux"), DeepEquals, []string{"bar", "large"})
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+	c.Assert(readFile(c, fs, "qux/bar"), Equals, "bar")
+	c.Assert(readFile(c, fs, "qux/large"), Equals, "large content")
+
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(fi.Mode(), Equals, os.FileMode(0666))
+}
+
+func (s *PackSuite) TestRenamePacked(c *C) {
+	dir, err := ioutil.TempDir("", "billy-packfs")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	// memory doesn't rename directories.
+	s.backend = osfs.New(dir)
+	s.fs = s.open(c)
+
+	writeFile(c, s.fs, "qux/foo", "foo")
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+
+	c.Assert(s.fs.Rename("qux", "baz"), IsNil)
+	c.Assert(s.fs.Rename("baz/foo", "bar"), IsNil)
+	c.Assert(s.fs.Close(), IsNil)
+
+	fs := s.open(c)
+	c.Assert(names(c, fs, ""), DeepEquals, []string{"bar", "baz"})
+	c.Assert(readFile(c, fs, "bar"), Equals, "foo")
+}
+
+func (s *PackSuite) TestRemoveSegment(c *C) {
+	writeFile(c, s.fs, "foo", "foo")
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+	segments := names(c, s.backend, ".pack")
+
+	writeFile(c, s.fs, "bar", "bar")
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+	c.Assert(names(c, s.backend, ".pack"), HasLen, 3)
+
+	c.Assert(s.fs.Remove("foo"), IsNil)
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+
+	l := names(c, s.backend, ".pack")
+	c.Assert(l, HasLen, 2)
+	c.Assert(l, Not(DeepEquals), segments)
+	c.Assert(names(c, s.open(c), ""), DeepEquals, []string{"bar"})
+}
+
+func (s *PackSuite) TestOverwrite(c *C) {
+	writeFile(c, s.fs, "foo", "large content")
+	writeFile(c, s.fs, "bar", "bar")
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+
+	writeFile(c, s.fs, "foo", "foo")
+	writeFile(c, s.fs, "bar", "large content")
+	c.Assert(s.fs.Flush(context.Background()), IsNil)
+
+	c.Assert(names(c, s.backend, ""), DeepEquals, []string{".pack", "bar"})
+
+	fs := s.open(c)
+	c.Assert(readFile(c, fs, "foo"), Equals, "foo")
+	c.Assert(readFile(c, fs, "bar"), Equals, "large content")
+}
+
+func (s *PackSuite) TestSegmentSize(c *C) {
+	fs, err := New(s.backend, &Options{SegmentSize: 6})
+	c.Assert(err, IsNil)
+
+	writeFile(c, fs, "foo", "foo")
+	_, err = s.backend.Stat(".pack")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	writeFile(c, fs, "bar", "bar")
+	c.Assert(names(c, s.backend, ".pack"), HasLen, 2)
+}
+
+func (s *PackSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend: "mem://packfs",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "pack",
+			Options: map[string]string{"threshold": "8", "dir": "segments"},
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fs, FitsTypeOf, &Pack{})
+	c.Assert(fs.(*Pack).s.opts.Dir, Equals, "segments")
+
+	_, err = billy.Compose(&billy.Config{
+		Backend: "mem://packfs",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "pack",
+			Options: map[string]string{"segment-size": "foo"},
+		}},
+	})
+	c.Assert(err, NotNil)
+}
+
+func writeFile(c *C, fs billy.Filesystem, name, content string) {
+	f, err := fs.Create(name)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, name string) string {
+	f, err := fs.Open(name)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
+
+func names(c *C, fs billy.Filesystem, dir string) []string {
+	l, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range l {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
@@ -0,0 +1,34 @@
+package packfs
+
+import (
+	"strconv"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("pack", wrap)
+}
+
+// wrap returns a Pack filesystem wrapping fs, with the threshold and
+// segment-size options, in bytes, and the dir option.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	var o Options
+	for name, n := range map[string]*int64{
+		"threshold":    &o.Threshold,
+		"segment-size": &o.SegmentSize,
+	} {
+		v, ok := opts[name]
+		if !ok {
+			continue
+		}
+
+		var err error
+		if *n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	o.Dir = opts["dir"]
+	return New(fs, &o)
+}
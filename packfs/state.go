@@ -0,0 +1,677 @@
+package packfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/pack"
+	"srcd.works/go-billy.v1/memory"
+)
+
+// indexName is the name of the index in Options.Dir.
+const indexName = "index"
+
+// The locations of a file, looked up in this order, so the pending files
+// shadow the packed ones, and these the stored ones.
+const (
+	missing = iota
+	// pending files are kept in memory until packed.
+	pending
+	// packed files are in a segment.
+	packed
+	// stored files are files of the wrapped filesystem.
+	stored
+)
+
+// state is shared by a Pack and the filesystems returned by its Dir. The
+// keys are the paths relative to the root of the wrapped filesystem.
+type state struct {
+	m    sync.RWMutex
+	fs   billy.Filesystem
+	opts Options
+	idx  *pack.Index
+	// dirty is set when idx has changes not saved.
+	dirty bool
+	// staged holds the pending files, and the files open for writing.
+	staged *memory.Memory
+	open   map[*writer]struct{}
+	// size is the size of the pending files closed since the last pack.
+	size int64
+}
+
+func newState(fs billy.Filesystem, opts Options) (*state, error) {
+	s := &state{
+		fs:     fs,
+		opts:   opts,
+		staged: memory.New(),
+		open:   make(map[*writer]struct{}),
+	}
+
+	f, err := fs.Open(path.Join(opts.Dir, indexName))
+	if os.IsNotExist(err) {
+		s.idx = pack.NewIndex()
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	if s.idx, err = pack.ReadIndex(f); err != nil {
+		return nil, fmt.Errorf("packfs: reading index: %s", err)
+	}
+
+	return s, nil
+}
+
+// lookup returns the location and the FileInfo of key, named filename in fs.
+// The errors of the missing files satisfy os.IsNotExist.
+func (s *state) lookup(fs billy.Filesystem, key, filename string) (int, billy.FileInfo, error) {
+	if fi, err := s.staged.Stat(key); err == nil && !fi.IsDir() {
+		return pending, fi, nil
+	}
+
+	if e, err := s.idx.Get(key); err == nil {
+		return packed, &entryInfo{name: path.Base(key), e: e}, nil
+	}
+
+	fi, err := fs.Stat(filename)
+	if err != nil {
+		return missing, nil, err
+	}
+
+	return stored, fi, nil
+}
+
+func (s *state) openRead(fs billy.Filesystem, key, filename string) (billy.File, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	where, fi, err := s.lookup(fs, key, filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	switch where {
+	case pending:
+		f, err := s.staged.Open(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return &file{File: f, name: clean(slash(filename))}, nil
+	case packed:
+		content, err := s.read(fi.(*entryInfo).e)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+		}
+
+		return newReader(clean(slash(filename)), fi, content), nil
+	}
+
+	return fs.Open(filename)
+}
+
+func (s *state) openWrite(fs billy.Filesystem, key, filename string, flag int, perm os.FileMode) (billy.File, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	where, fi, err := s.lookup(fs, key, filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	switch {
+	case where != missing && fi.IsDir():
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDirectory}
+	case where != missing && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	case where == missing && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	case where == missing:
+		if err := s.mkdirParents("open", key, filename); err != nil {
+			return nil, err
+		}
+	case where == packed || where == stored:
+		var content []byte
+		if flag&os.O_TRUNC == 0 {
+			if content, err = s.readFile(fs, where, fi, filename); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.stage(key, content, fi); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := s.staged.OpenFile(key, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &writer{
+		file:    file{File: f, name: clean(slash(filename))},
+		s:       s,
+		key:     key,
+		shadows: where == stored,
+	}
+
+	s.open[w] = struct{}{}
+	return w, nil
+}
+
+// readFile returns the content of the packed or stored file.
+func (s *state) readFile(fs billy.Filesystem, where int, fi billy.FileInfo, filename string) ([]byte, error) {
+	if where == packed {
+		return s.read(fi.(*entryInfo).e)
+	}
+
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// read returns the content of e, reading its range of the segment.
+func (s *state) read(e pack.Entry) ([]byte, error) {
+	f, err := s.fs.Open(path.Join(s.opts.Dir, e.Segment))
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var r io.Reader = f
+	if ra, ok := f.(io.ReaderAt); ok {
+		r = pack.Open(ra, e)
+	} else if _, err := f.Seek(e.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	content := make([]byte, e.Size)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// stage writes key to the staged files, with the mode and modification time
+// of fi.
+func (s *state) stage(key string, content []byte, fi billy.FileInfo) error {
+	f, err := s.staged.OpenFile(key, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return s.staged.Chtimes(key, fi.ModTime(), fi.ModTime())
+}
+
+// unstage removes key from the staged files, along with its parents left
+// empty, so they don't clash with the files created later.
+func (s *state) unstage(key string) {
+	if s.staged.Remove(key) != nil {
+		return
+	}
+
+	s.prune(path.Dir(key))
+}
+
+// prune removes dir from the staged files, and its parents, while empty.
+func (s *state) prune(dir string) {
+	for ; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if s.staged.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// closed stores the file written by w once all the writers of its key are
+// closed, if it's larger than Options.Threshold, or leaves it pending to be
+// packed otherwise.
+func (s *state) closed(w *writer) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.open, w)
+	if s.isOpen(w.key) {
+		return nil
+	}
+
+	fi, err := s.staged.Stat(w.key)
+	if err != nil || fi.IsDir() {
+		// removed while open.
+		return nil
+	}
+
+	if fi.Size() > s.opts.Threshold {
+		return s.store(w.key, fi)
+	}
+
+	if w.shadows {
+		if err := s.fs.Remove(w.key); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	s.size += fi.Size()
+	if s.size >= s.opts.SegmentSize {
+		return s.pack()
+	}
+
+	return nil
+}
+
+func (s *state) isOpen(key string) bool {
+	for w := range s.open {
+		if w.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// store moves the staged key to the wrapped filesystem, removing its packed
+// version.
+func (s *state) store(key string, fi billy.FileInfo) error {
+	src, err := s.staged.Open(key)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, err := s.fs.OpenFile(key, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	s.unstage(key)
+	if s.idx.Remove(key) != nil {
+		return nil
+	}
+
+	s.dirty = true
+	return s.save()
+}
+
+// pack packs the pending files, but the open ones, into a new segment, and
+// saves the index.
+func (s *state) pack() error {
+	var keys []string
+	err := billy.Walk(s.staged, "", func(p string, fi billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !fi.IsDir() && !s.isOpen(p) {
+			keys = append(keys, p)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return s.save()
+	}
+
+	var buf bytes.Buffer
+	name := segmentName()
+	w := pack.NewWriter(&buf, name)
+	entries := make([]pack.Entry, len(keys))
+	for i, key := range keys {
+		if entries[i], err = s.add(w, key); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeFile(name, buf.Bytes()); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		s.idx.Put(key, entries[i])
+		s.unstage(key)
+	}
+
+	s.size = 0
+	s.dirty = true
+	return s.save()
+}
+
+// add appends the content of the staged key to the segment written by w.
+func (s *state) add(w *pack.Writer, key string) (pack.Entry, error) {
+	f, err := s.staged.Open(key)
+	if err != nil {
+		return pack.Entry{}, err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return pack.Entry{}, err
+	}
+
+	e, err := w.Add(f, fi.ModTime())
+	e.Mode = fi.Mode().Perm()
+	return e, err
+}
+
+// save writes the index if it changed, and deletes the segments no longer
+// referenced by it.
+func (s *state) save() error {
+	if !s.dirty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := s.idx.Encode(&buf); err != nil {
+		return err
+	}
+
+	if err := s.writeFile(indexName, buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.dirty = false
+
+	segments, err := s.fs.ReadDir(s.opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	live := s.idx.Live()
+	for _, fi := range segments {
+		if _, ok := live[fi.Name()]; ok || fi.Name() == indexName {
+			continue
+		}
+
+		if err := s.fs.Remove(path.Join(s.opts.Dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes the named file of Options.Dir.
+func (s *state) writeFile(name string, content []byte) error {
+	if err := s.fs.MkdirAll(s.opts.Dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := s.fs.Create(path.Join(s.opts.Dir, name))
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// segmentName returns a new name for a segment.
+func segmentName() string {
+	return fmt.Sprintf("%016x-%08x", time.Now().UnixNano(), rand.Uint32())
+}
+
+// mkdirParents creates the parents of key, failing if any of them is a
+// pending or packed file.
+func (s *state) mkdirParents(op, key, filename string) error {
+	dir := path.Dir(key)
+	if dir == "." {
+		return nil
+	}
+
+	if err := s.checkParents(op, dir, filename); err != nil {
+		return err
+	}
+
+	return s.fs.MkdirAll(dir, 0755)
+}
+
+// checkParents fails if key, or any of its parents, is a pending or packed
+// file.
+func (s *state) checkParents(op, key, filename string) error {
+	for ; key != "." && key != ""; key = path.Dir(key) {
+		_, err := s.idx.Get(key)
+		if fi, serr := s.staged.Stat(key); err == nil || serr == nil && !fi.IsDir() {
+			return &os.PathError{Op: op, Path: filename, Err: errNotDirectory}
+		}
+	}
+
+	return nil
+}
+
+// drop removes the file key from all the locations.
+func (s *state) drop(key string) error {
+	if fi, err := s.staged.Stat(key); err == nil && !fi.IsDir() {
+		s.unstage(key)
+	}
+
+	if s.idx.Remove(key) == nil {
+		s.dirty = true
+	}
+
+	fi, err := s.fs.Stat(key)
+	if os.IsNotExist(err) || err == nil && fi.IsDir() {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.fs.Remove(key)
+}
+
+func (s *state) remove(fs billy.Filesystem, key, filename string) error {
+	_, fi, err := s.lookup(fs, key, filename)
+	if os.IsNotExist(err) {
+		return &os.PathError{Op: "remove", Path: filename, Err: os.ErrNotExist}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return s.drop(key)
+	}
+
+	if s.hasFiles(key) {
+		return &os.PathError{Op: "remove", Path: filename, Err: errNotEmpty}
+	}
+
+	return fs.Remove(filename)
+}
+
+// hasFiles returns true if there are pending or packed files under dir.
+func (s *state) hasFiles(dir string) bool {
+	if l, err := s.staged.ReadDir(dir); err == nil && len(l) != 0 {
+		return true
+	}
+
+	prefix := dir + "/"
+	for _, name := range s.idx.Names() {
+		if dir == "" || strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *state) rename(fs billy.Filesystem, fk, tk, from, to string) error {
+	where, fi, err := s.lookup(fs, fk, from)
+	if os.IsNotExist(err) {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrNotExist}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fk == tk {
+		return nil
+	}
+
+	if fi.IsDir() {
+		return s.renameDir(fs, fk, tk, from, to)
+	}
+
+	twhere, tfi, err := s.lookup(fs, tk, to)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if twhere != missing && tfi.IsDir() {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: errIsDirectory}
+	}
+
+	if err := s.mkdirParents("rename", tk, to); err != nil {
+		return err
+	}
+
+	switch where {
+	case pending:
+		if err := s.drop(tk); err != nil {
+			return err
+		}
+
+		if err := s.staged.Rename(fk, tk); err != nil {
+			return err
+		}
+
+		s.prune(path.Dir(fk))
+		s.moveWriters(fk, tk)
+	case packed:
+		if err := s.drop(tk); err != nil {
+			return err
+		}
+
+		s.idx.Put(tk, fi.(*entryInfo).e)
+		s.idx.Remove(fk)
+		s.dirty = true
+	default:
+		if twhere != stored {
+			if err := s.drop(tk); err != nil {
+				return err
+			}
+		}
+
+		return fs.Rename(from, to)
+	}
+
+	return nil
+}
+
+// renameDir renames the directory fk in the wrapped filesystem, and moves
+// the pending and packed files under it.
+func (s *state) renameDir(fs billy.Filesystem, fk, tk, from, to string) error {
+	if err := fs.Rename(from, to); err != nil {
+		return err
+	}
+
+	prefix := fk + "/"
+	for _, name := range s.idx.Names() {
+		if strings.HasPrefix(name, prefix) {
+			e, _ := s.idx.Get(name)
+			s.idx.Put(tk+"/"+strings.TrimPrefix(name, prefix), e)
+			s.idx.Remove(name)
+			s.dirty = true
+		}
+	}
+
+	var keys []string
+	billy.Walk(s.staged, fk, func(p string, fi billy.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			keys = append(keys, p)
+		}
+
+		return nil
+	})
+
+	for _, key := range keys {
+		if err := s.staged.Rename(key, tk+strings.TrimPrefix(key, fk)); err != nil {
+			return err
+		}
+
+		s.prune(path.Dir(key))
+	}
+
+	s.moveWriters(fk, tk)
+	return nil
+}
+
+// moveWriters moves the writers of src, or under it, to dst.
+func (s *state) moveWriters(src, dst string) {
+	for w := range s.open {
+		if w.key == src || strings.HasPrefix(w.key, src+"/") {
+			w.key = dst + strings.TrimPrefix(w.key, src)
+		}
+	}
+}
+
+// change changes the packed file key with fn, or the pending or stored one
+// with change.
+func (s *state) change(fs billy.Filesystem, key, name string, fn func(*pack.Entry), change func(billy.Filesystem, string) error) error {
+	where, fi, err := s.lookup(fs, key, name)
+	if err != nil {
+		return err
+	}
+
+	switch where {
+	case pending:
+		return change(s.staged, key)
+	case packed:
+		e := fi.(*entryInfo).e
+		fn(&e)
+		s.idx.Put(key, e)
+		s.dirty = true
+		return nil
+	}
+
+	return change(fs, name)
+}
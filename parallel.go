@@ -0,0 +1,173 @@
+package billy
+
+import (
+	"context"
+	"io"
+)
+
+// ParallelOptions describes how ReadParallel splits a read.
+type ParallelOptions struct {
+	// Parts is the maximum number of ranges read concurrently, 4 by default.
+	Parts int
+	// PartSize is the size of every range, the last one may be smaller. 8MiB
+	// by default.
+	PartSize int64
+}
+
+var defaultParallelOptions = ParallelOptions{
+	Parts:    4,
+	PartSize: 8 << 20,
+}
+
+type parallelPart struct {
+	buf      []byte
+	err      error
+	panicked interface{}
+}
+
+// ReadParallel copies the first size bytes of src to dst, reading ranges of
+// it concurrently with ReadAt. On remote backends, where every ReadAt is a
+// ranged request, it improves the throughput of high-latency links. If dst
+// implements io.WriterAt every range is written at its offset as soon as
+// it's read, otherwise they are written in order, buffering at most Parts
+// ranges. If opts is nil the default options are used, as for their zero
+// fields.
+func ReadParallel(ctx context.Context, dst io.Writer, src io.ReaderAt, size int64, opts *ParallelOptions) error {
+	o := defaultParallelOptions
+	if opts != nil {
+		if opts.Parts > 0 {
+			o.Parts = opts.Parts
+		}
+
+		if opts.PartSize > 0 {
+			o.PartSize = opts.PartSize
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wa, direct := dst.(io.WriterAt)
+	results := make([]chan parallelPart, (size+o.PartSize-1)/o.PartSize)
+	for i := range results {
+		results[i] = make(chan parallelPart, 1)
+	}
+
+	sem := make(chan struct{}, o.Parts)
+	go func() {
+		for i := range results {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(i int) {
+				var p parallelPart
+				defer func() { results[i] <- p }()
+				defer catchPanic(&p.panicked)
+
+				off := int64(i) * o.PartSize
+				p = readPart(src, off, size-off, o.PartSize)
+				if p.err == nil && direct {
+					_, p.err = wa.WriteAt(p.buf, off)
+					p.buf = nil
+				}
+			}(i)
+		}
+	}()
+
+	for _, r := range results {
+		var p parallelPart
+		select {
+		case p = <-r:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if p.panicked != nil {
+			panic(p.panicked)
+		}
+
+		if p.err != nil {
+			return p.err
+		}
+
+		if !direct {
+			if _, err := dst.Write(p.buf); err != nil {
+				return err
+			}
+		}
+
+		<-sem
+	}
+
+	return nil
+}
+
+// readPart reads the range of src starting at off, of partSize bytes or the
+// remaining ones if fewer.
+func readPart(src io.ReaderAt, off, remaining, partSize int64) parallelPart {
+	if remaining < partSize {
+		partSize = remaining
+	}
+
+	p := parallelPart{buf: make([]byte, partSize)}
+	n, err := src.ReadAt(p.buf, off)
+	switch {
+	case n == len(p.buf):
+	case err == io.EOF || err == nil:
+		p.err = io.ErrUnexpectedEOF
+	default:
+		p.err = err
+	}
+
+	return p
+}
+
+// CopyFileParallel copies the file src of srcfs to dst in dstfs, with
+// ReadParallel if the file opened by srcfs implements io.ReaderAt, otherwise
+// with io.Copy. If the copy fails, or panics, the partially written dst is
+// removed.
+func CopyFileParallel(ctx context.Context, dstfs Filesystem, dst string, srcfs Filesystem, src string, opts *ParallelOptions) error {
+	var c cleanup
+	defer c.run()
+
+	r, err := srcfs.Open(src)
+	if err != nil {
+		return err
+	}
+
+	c.close(func() { r.Close() })
+
+	fi, err := r.Stat()
+	if err != nil {
+		return err
+	}
+
+	w, err := dstfs.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	c.undo(func() { dstfs.Remove(dst) })
+	c.undo(func() { w.Close() })
+
+	if ra, ok := r.(io.ReaderAt); ok {
+		err = ReadParallel(ctx, w, ra, fi.Size(), opts)
+	} else {
+		_, err = io.Copy(w, r)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.succeed()
+	if err := w.Close(); err != nil {
+		dstfs.Remove(dst)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package billy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type ParallelSuite struct{}
+
+var _ = Suite(&ParallelSuite{})
+
+func (s *ParallelSuite) TestReadParallel(c *C) {
+	content := strings.Repeat("0123456789", 100)
+	buf := bytes.NewBuffer(nil)
+
+	err := billy.ReadParallel(context.Background(), buf, strings.NewReader(content),
+		int64(len(content)), &billy.ParallelOptions{Parts: 3, PartSize: 7})
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, content)
+}
+
+func (s *ParallelSuite) TestReadParallelWriterAt(c *C) {
+	content := strings.Repeat("0123456789", 100)
+	w := &writerAt{}
+
+	err := billy.ReadParallel(context.Background(), w, strings.NewReader(content),
+		int64(len(content)), &billy.ParallelOptions{Parts: 3, PartSize: 7})
+	c.Assert(err, IsNil)
+	c.Assert(string(w.buf), Equals, content)
+}
+
+func (s *ParallelSuite) TestReadParallelShort(c *C) {
+	err := billy.ReadParallel(context.Background(), bytes.NewBuffer(nil),
+		strings.NewReader("foo"), 10, &billy.ParallelOptions{PartSize: 2})
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+}
+
+func (s *ParallelSuite) TestReadParallelError(c *C) {
+	errRead := errors.New("read")
+	err := billy.ReadParallel(context.Background(), bytes.NewBuffer(nil),
+		failingReaderAt{errRead}, 10, &billy.ParallelOptions{PartSize: 2})
+	c.Assert(err, Equals, errRead)
+}
+
+func (s *ParallelSuite) TestCopyFileParallel(c *C) {
+	fs := memory.New()
+	content := strings.Repeat("foo", 1000)
+	writeFile(c, fs, "foo", content)
+
+	err := billy.CopyFileParallel(context.Background(), fs, "bar", fs, "foo",
+		&billy.ParallelOptions{PartSize: 100})
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, fs, "bar"), Equals, content)
+}
+
+func (s *ParallelSuite) TestReadParallelPanic(c *C) {
+	c.Assert(func() {
+		billy.ReadParallel(context.Background(), bytes.NewBuffer(nil),
+			panickingReaderAt{}, 10, &billy.ParallelOptions{PartSize: 2})
+	}, PanicMatches, "ReadAt panicked")
+}
+
+type writerAt struct {
+	sync.Mutex
+	buf []byte
+}
+
+func (w *writerAt) Write(p []byte) (int, error) {
+	panic("Write called on an io.WriterAt")
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+
+	return copy(w.buf[off:], p), nil
+}
+
+type failingReaderAt struct {
+	err error
+}
+
+func (r failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return 0, r.err
+}
+
+type panickingReaderAt struct{}
+
+func (panickingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	panic("ReadAt panicked")
+}
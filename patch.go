@@ -0,0 +1,656 @@
+package billy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrBinaryPatch is returned by ApplyPatch for the patches of binary files.
+var ErrBinaryPatch = errors.New("binary patches not supported")
+
+// PatchOptions describes how ApplyPatch applies a patch.
+type PatchOptions struct {
+	// Strip is the number of leading elements removed from the paths of the
+	// patch, as the -p option of patch, 1 by default, removing the a/ and b/
+	// prefixes of git. A negative value removes none. The paths of the
+	// renames of git have no prefix, they are never stripped.
+	Strip int
+	// DryRun checks that the patch applies and reports the changes, without
+	// writing them.
+	DryRun bool
+	// Rejects applies the hunks that apply, writing the others to a file
+	// named as the patched one with the .rej suffix, instead of failing
+	// without changing anything.
+	Rejects bool
+}
+
+var defaultPatchOptions = PatchOptions{
+	Strip: 1,
+}
+
+// PatchReport lists the paths changed by ApplyPatch. The renamed files are
+// reported as the deletion of the old path and the creation of the new one.
+type PatchReport struct {
+	Created  []string
+	Modified []string
+	Deleted  []string
+	// Rejected are the files with hunks not applied, written to their .rej
+	// files.
+	Rejected []string
+}
+
+// PatchConflictError is returned by ApplyPatch when some hunks don't apply,
+// without Rejects.
+type PatchConflictError struct {
+	Paths []string
+}
+
+func (e *PatchConflictError) Error() string {
+	return fmt.Sprintf("patch doesn't apply to %d file(s): %s",
+		len(e.Paths), strings.Join(e.Paths, ", "),
+	)
+}
+
+// ApplyPatch applies to fs the unified diff read from r, as written by diff -u
+// or git diff. The creations, deletions, renames and mode changes of the
+// extended headers of git are applied too, the text before and between the
+// patches, such as a commit message, is ignored. The hunks are located by
+// their context, which must match exactly, even if the lines moved. The
+// files are only changed once the whole patch is known to apply, unless
+// Rejects is set. If opts is nil the default options are used, as for their
+// zero fields.
+func ApplyPatch(fs Filesystem, r io.Reader, opts *PatchOptions) (*PatchReport, error) {
+	o := defaultPatchOptions
+	if opts != nil {
+		if opts.Strip != 0 {
+			o.Strip = opts.Strip
+		}
+
+		o.DryRun = opts.DryRun
+		o.Rejects = opts.Rejects
+	}
+
+	patches, err := parsePatch(r, o.Strip)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*patchResult
+	var conflicts []string
+	for _, p := range patches {
+		res, err := p.apply(fs)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res.rejected) != 0 {
+			conflicts = append(conflicts, p.path())
+		}
+
+		results = append(results, res)
+	}
+
+	if len(conflicts) != 0 && !o.Rejects {
+		return nil, &PatchConflictError{Paths: conflicts}
+	}
+
+	report := &PatchReport{}
+	for _, res := range results {
+		res.report(report)
+		if o.DryRun {
+			continue
+		}
+
+		if err := res.write(fs); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// filePatch is the patch of a file.
+type filePatch struct {
+	// oldPath and newPath are the paths before and after the patch, empty for
+	// the created and deleted files.
+	oldPath, newPath string
+	oldMode, newMode os.FileMode
+	hunks            []*hunk
+	binary           bool
+	// created and deleted are set by the extended headers of git, which are
+	// the only information for the empty files, as renamed.
+	created, deleted, renamed bool
+}
+
+func (p *filePatch) path() string {
+	if p.newPath != "" {
+		return p.newPath
+	}
+
+	return p.oldPath
+}
+
+// hunk is a hunk of a unified diff.
+type hunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	// header is the @@ line and lines the following ones, keeping their
+	// prefix and line terminator, without the "\ No newline" markers.
+	header string
+	lines  []string
+	// oldEOF and newEOF are set when the old or new content lack the final
+	// line terminator.
+	oldEOF, newEOF bool
+}
+
+// old and new return the lines of the hunk before and after applying it,
+// without prefix.
+func (h *hunk) old() []string { return h.side('-', h.oldEOF) }
+func (h *hunk) new() []string { return h.side('+', h.newEOF) }
+
+func (h *hunk) side(op byte, noEOL bool) []string {
+	var l []string
+	for _, line := range h.lines {
+		if line[0] == ' ' || line[0] == op {
+			l = append(l, line[1:])
+		}
+	}
+
+	if noEOL && len(l) != 0 {
+		l[len(l)-1] = strings.TrimSuffix(l[len(l)-1], "\n")
+	}
+
+	return l
+}
+
+// parsePatch reads the patches of the files from r.
+func parsePatch(r io.Reader, strip int) ([]*filePatch, error) {
+	br := bufio.NewReader(r)
+	var patches []*filePatch
+	var p *filePatch
+	// git is set while reading the extended headers of a git patch.
+	var git bool
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if line == "" {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			p, git = &filePatch{}, true
+			p.oldPath, p.newPath = parseGitPaths(line, strip)
+			patches = append(patches, p)
+		case strings.HasPrefix(line, "--- ") && (p == nil || !git || p.hunks != nil):
+			p, git = &filePatch{}, false
+			patches = append(patches, p)
+			fallthrough
+		case strings.HasPrefix(line, "--- ") && p != nil:
+			p.oldPath = patchPath(line[4:], strip)
+		case strings.HasPrefix(line, "+++ ") && p != nil && p.hunks == nil:
+			p.newPath = patchPath(line[4:], strip)
+			git = false
+		case strings.HasPrefix(line, "@@ ") && p != nil:
+			h, read, err := parseHunk(br, line)
+			if err != nil {
+				return nil, fmt.Errorf("patch line %d: %s", n, err)
+			}
+
+			n += read
+			p.hunks = append(p.hunks, h)
+			git = false
+		case git:
+			if err := parseGitHeader(p, line); err != nil {
+				return nil, fmt.Errorf("patch line %d: %s", n, err)
+			}
+		}
+	}
+
+	for _, p := range patches {
+		if p.created {
+			p.oldPath = ""
+		}
+
+		if p.deleted {
+			p.newPath = ""
+		}
+	}
+
+	return patches, nil
+}
+
+// parseGitHeader parses an extended header line of a git patch.
+func parseGitHeader(p *filePatch, line string) error {
+	line = strings.TrimRight(line, "\r\n")
+	parseMode := func(prefix string) (os.FileMode, error) {
+		m, err := strconv.ParseUint(strings.TrimPrefix(line, prefix), 8, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid mode in %q", line)
+		}
+
+		return os.FileMode(m).Perm(), nil
+	}
+
+	var err error
+	switch {
+	case strings.HasPrefix(line, "old mode "):
+		p.oldMode, err = parseMode("old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		p.newMode, err = parseMode("new mode ")
+	case strings.HasPrefix(line, "new file mode "):
+		p.newMode, err = parseMode("new file mode ")
+		p.created = true
+	case strings.HasPrefix(line, "deleted file mode "):
+		p.oldMode, err = parseMode("deleted file mode ")
+		p.deleted = true
+	case strings.HasPrefix(line, "rename from "):
+		p.oldPath = strings.TrimPrefix(line, "rename from ")
+		p.renamed = true
+	case strings.HasPrefix(line, "rename to "):
+		p.newPath = strings.TrimPrefix(line, "rename to ")
+	case strings.HasPrefix(line, "GIT binary patch"), strings.HasPrefix(line, "Binary files "):
+		p.binary = true
+	}
+
+	return err
+}
+
+// parseGitPaths returns the paths of a "diff --git a/x b/x" line, the paths
+// with spaces are only parsed correctly if both are equal.
+func parseGitPaths(line string, strip int) (string, string) {
+	paths := strings.TrimRight(strings.TrimPrefix(line, "diff --git "), "\r\n")
+	if len(paths)%2 == 1 {
+		half := len(paths) / 2
+		if paths[half] == ' ' && stripPath(paths[:half], strip) == stripPath(paths[half+1:], strip) {
+			return stripPath(paths[:half], strip), stripPath(paths[half+1:], strip)
+		}
+	}
+
+	parts := strings.SplitN(paths, " ", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+
+	return stripPath(parts[0], strip), stripPath(parts[1], strip)
+}
+
+// patchPath returns the path of a --- or +++ line, without the timestamp
+// written by diff, empty for /dev/null.
+func patchPath(s string, strip int) string {
+	s = strings.TrimRight(s, "\r\n")
+	if i := strings.IndexByte(s, '\t'); i != -1 {
+		s = s[:i]
+	}
+
+	if s == "/dev/null" {
+		return ""
+	}
+
+	return stripPath(s, strip)
+}
+
+// stripPath removes the first n elements of p.
+func stripPath(p string, n int) string {
+	for ; n > 0; n-- {
+		i := strings.IndexByte(p, '/')
+		if i == -1 {
+			break
+		}
+
+		p = p[i+1:]
+	}
+
+	return p
+}
+
+// parseHunk reads the lines of the hunk with the given @@ header, returning
+// the number of lines read.
+func parseHunk(br *bufio.Reader, header string) (*hunk, int, error) {
+	h := &hunk{header: header}
+	var err error
+	fields := strings.Fields(header)
+	if len(fields) < 3 || fields[0] != "@@" {
+		return nil, 0, fmt.Errorf("invalid hunk header %q", header)
+	}
+
+	if h.oldStart, h.oldLines, err = parseRange(fields[1], "-"); err != nil {
+		return nil, 0, err
+	}
+
+	if h.newStart, h.newLines, err = parseRange(fields[2], "+"); err != nil {
+		return nil, 0, err
+	}
+
+	var read int
+	oldLeft, newLeft := h.oldLines, h.newLines
+	for oldLeft > 0 || newLeft > 0 || isNoEOL(br) {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, read, fmt.Errorf("truncated hunk %q", strings.TrimSpace(header))
+		}
+
+		read++
+		if line == "\n" || line == "\r\n" {
+			// some tools strip the trailing spaces of the empty context
+			// lines.
+			line = " " + line
+		}
+
+		switch line[0] {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		case '\\':
+			h.markNoEOL()
+			continue
+		default:
+			return nil, read, fmt.Errorf("invalid line in hunk %q", strings.TrimSpace(header))
+		}
+
+		if oldLeft < 0 || newLeft < 0 {
+			return nil, read, fmt.Errorf("hunk %q longer than its header", strings.TrimSpace(header))
+		}
+
+		h.lines = append(h.lines, line)
+	}
+
+	return h, read, nil
+}
+
+// isNoEOL returns true if the next line read by br is a "\ No newline"
+// marker.
+func isNoEOL(br *bufio.Reader) bool {
+	b, err := br.Peek(1)
+	return err == nil && b[0] == '\\'
+}
+
+// markNoEOL records that the side of the last line lacks the final line
+// terminator.
+func (h *hunk) markNoEOL() {
+	if len(h.lines) == 0 {
+		return
+	}
+
+	switch h.lines[len(h.lines)-1][0] {
+	case ' ':
+		h.oldEOF, h.newEOF = true, true
+	case '-':
+		h.oldEOF = true
+	case '+':
+		h.newEOF = true
+	}
+}
+
+// parseRange parses a range of a hunk header, such as -1,3 or +1.
+func parseRange(s, prefix string) (start, lines int, err error) {
+	if !strings.HasPrefix(s, prefix) {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", s)
+	}
+
+	parts := strings.SplitN(s[1:], ",", 2)
+	if start, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk range %q", s)
+	}
+
+	lines = 1
+	if len(parts) == 2 {
+		if lines, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("invalid hunk range %q", s)
+		}
+	}
+
+	return start, lines, nil
+}
+
+// patchResult is the result of applying the patch of a file.
+type patchResult struct {
+	p        *filePatch
+	content  []byte
+	rejected []*hunk
+}
+
+// apply applies the hunks of the patch to the current content of the file.
+func (p *filePatch) apply(fs Filesystem) (*patchResult, error) {
+	if p.binary {
+		return nil, &os.PathError{Op: "patch", Path: p.path(), Err: ErrBinaryPatch}
+	}
+
+	if !p.renamed && p.oldPath != "" && p.newPath != "" && p.oldPath != p.newPath {
+		// the paths of the diffs not written by git differ, such as foo.orig
+		// and foo, the file patched is the one existing, as done by patch.
+		if _, err := fs.Lstat(p.newPath); err == nil {
+			p.oldPath = p.newPath
+		} else {
+			p.newPath = p.oldPath
+		}
+	}
+
+	res := &patchResult{p: p}
+	var lines []string
+	if p.oldPath != "" {
+		f, err := fs.Open(p.oldPath)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		lines = splitLines(data)
+	} else if _, err := fs.Lstat(p.newPath); err == nil {
+		// the file to create exists, the patch doesn't apply.
+		res.rejected = p.hunks
+		if len(p.hunks) == 0 {
+			return nil, &os.PathError{Op: "patch", Path: p.newPath, Err: os.ErrExist}
+		}
+
+		return res, nil
+	}
+
+	lines, res.rejected = applyHunks(lines, p.hunks)
+	res.content = []byte(strings.Join(lines, ""))
+	if p.newPath == "" && len(res.content) != 0 && len(res.rejected) == 0 {
+		// the deletion must remove the whole content.
+		res.rejected = p.hunks
+	}
+
+	return res, nil
+}
+
+// applyHunks applies the hunks to lines, returning the result and the hunks
+// not applied. Every hunk is searched from the position expected by its
+// header, corrected by the offset of the previous one, moving away from it.
+func applyHunks(lines []string, hunks []*hunk) ([]string, []*hunk) {
+	var out []string
+	var rejected []*hunk
+	var pos, offset int
+	for _, h := range hunks {
+		old := h.old()
+		expected := h.oldStart - 1 + offset
+		if h.oldLines == 0 {
+			// the insertions are after the line of oldStart.
+			expected++
+		}
+
+		at := findLines(lines, old, pos, expected)
+		if at == -1 {
+			rejected = append(rejected, h)
+			continue
+		}
+
+		out = append(out, lines[pos:at]...)
+		out = append(out, h.new()...)
+		offset = at - (h.oldStart - 1)
+		if h.oldLines == 0 {
+			offset--
+		}
+
+		pos = at + len(old)
+	}
+
+	return append(out, lines[pos:]...), rejected
+}
+
+// findLines returns the index of lines, not before min, where the lines of
+// old start, searching from expected, or -1 if not found.
+func findLines(lines, old []string, min, expected int) int {
+	max := len(lines) - len(old)
+	for d := 0; expected-d >= min || expected+d <= max; d++ {
+		if i := expected - d; i >= min && i <= max && matchLines(lines[i:], old) {
+			return i
+		}
+
+		if i := expected + d; d != 0 && i >= min && i <= max && matchLines(lines[i:], old) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func matchLines(lines, old []string) bool {
+	for i, line := range old {
+		if lines[i] != line {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitLines splits data in lines, keeping their terminators.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) != 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+
+	return lines
+}
+
+func (res *patchResult) report(r *PatchReport) {
+	p := res.p
+	if len(res.rejected) != 0 {
+		r.Rejected = append(r.Rejected, p.path())
+	}
+
+	switch {
+	case p.oldPath == "":
+		if len(res.rejected) == 0 {
+			r.Created = append(r.Created, p.newPath)
+		}
+	case p.newPath == "":
+		if len(res.rejected) == 0 {
+			r.Deleted = append(r.Deleted, p.oldPath)
+		}
+	case p.oldPath != p.newPath:
+		r.Deleted = append(r.Deleted, p.oldPath)
+		r.Created = append(r.Created, p.newPath)
+	default:
+		r.Modified = append(r.Modified, p.newPath)
+	}
+}
+
+// write writes the result to fs, along with the rejected hunks.
+func (res *patchResult) write(fs Filesystem) error {
+	p := res.p
+	if len(res.rejected) != 0 {
+		if err := writeRejects(fs, p, res.rejected); err != nil {
+			return err
+		}
+
+		if p.oldPath == "" || p.newPath == "" {
+			return nil
+		}
+	}
+
+	if p.newPath == "" {
+		return fs.Remove(p.oldPath)
+	}
+
+	if err := writeContent(fs, p.newPath, res.content); err != nil {
+		return err
+	}
+
+	if p.newMode != 0 {
+		err := fs.Chmod(p.newPath, p.newMode)
+		if err != nil && err != ErrNotSupported {
+			return err
+		}
+	}
+
+	if p.oldPath != "" && p.oldPath != p.newPath {
+		return fs.Remove(p.oldPath)
+	}
+
+	return nil
+}
+
+// writeContent writes content to the named file, created or truncated if it
+// already exists.
+func writeContent(fs Filesystem, filename string, content []byte) error {
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeRejects writes the hunks to the .rej file of the patched file.
+func writeRejects(fs Filesystem, p *filePatch, hunks []*hunk) error {
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", rejectPath(p.oldPath), rejectPath(p.newPath))
+	for _, h := range hunks {
+		buf.WriteString(h.header)
+		for i, line := range h.lines {
+			buf.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				buf.WriteString("\n")
+			}
+
+			if i == len(h.lines)-1 && (h.oldEOF || h.newEOF) {
+				buf.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return writeContent(fs, p.path()+".rej", buf.Bytes())
+}
+
+func rejectPath(p string) string {
+	if p == "" {
+		return "/dev/null"
+	}
+
+	return p
+}
@@ -0,0 +1,162 @@
+package billy_test
+
+import (
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type PatchSuite struct {
+	fs billy.Filesystem
+}
+
+var _ = Suite(&PatchSuite{})
+
+func (s *PatchSuite) SetUpTest(c *C) {
+	s.fs = memory.New()
+	writeFile(c, s.fs, "foo", "1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+	writeFile(c, s.fs, "qux/bar", "bar\n")
+}
+
+const fooPatch = `diff --git a/foo b/foo
+index 1111111..2222222 100644
+--- a/foo
++++ b/foo
+@@ -1,3 +1,3 @@
+-1
++one
+ 2
+ 3
+@@ -7,3 +7,4 @@
+ 7
+ 8
+ 9
++10
+`
+
+func (s *PatchSuite) TestApplyPatch(c *C) {
+	r, err := billy.ApplyPatch(s.fs, strings.NewReader(fooPatch), nil)
+	c.Assert(err, IsNil)
+	c.Assert(r.Modified, DeepEquals, []string{"foo"})
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+}
+
+func (s *PatchSuite) TestApplyPatchOffset(c *C) {
+	writeFile(c, s.fs, "foo", "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+	_, err := billy.ApplyPatch(s.fs, strings.NewReader(fooPatch), nil)
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "0\none\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+}
+
+func (s *PatchSuite) TestApplyPatchCreateDelete(c *C) {
+	patch := `Subject: create and delete
+
+diff --git a/qux/bar b/qux/bar
+deleted file mode 100644
+--- a/qux/bar
++++ /dev/null
+@@ -1 +0,0 @@
+-bar
+diff --git a/baz b/baz
+new file mode 100755
+--- /dev/null
++++ b/baz
+@@ -0,0 +1,2 @@
++#!/bin/sh
++true
+\ No newline at end of file
+diff --git a/empty b/empty
+new file mode 100644
+`
+
+	r, err := billy.ApplyPatch(s.fs, strings.NewReader(patch), nil)
+	c.Assert(err, IsNil)
+	c.Assert(r.Created, DeepEquals, []string{"baz", "empty"})
+	c.Assert(r.Deleted, DeepEquals, []string{"qux/bar"})
+
+	_, err = s.fs.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(readFile(c, s.fs, "baz"), Equals, "#!/bin/sh\ntrue")
+	c.Assert(readFile(c, s.fs, "empty"), Equals, "")
+
+	fi, err := s.fs.Stat("baz")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0755))
+}
+
+func (s *PatchSuite) TestApplyPatchModeRename(c *C) {
+	patch := `diff --git a/foo b/foo
+old mode 100644
+new mode 100755
+diff --git a/qux/bar b/baz
+similarity index 100%
+rename from qux/bar
+rename to baz
+`
+
+	r, err := billy.ApplyPatch(s.fs, strings.NewReader(patch), nil)
+	c.Assert(err, IsNil)
+	c.Assert(r.Modified, DeepEquals, []string{"foo"})
+	c.Assert(r.Created, DeepEquals, []string{"baz"})
+	c.Assert(r.Deleted, DeepEquals, []string{"qux/bar"})
+
+	fi, err := s.fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0755))
+	c.Assert(readFile(c, s.fs, "baz"), Equals, "bar\n")
+	_, err = s.fs.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *PatchSuite) TestApplyPatchPlainDiff(c *C) {
+	patch := "--- qux/bar.orig\t2017-01-01 00:00:00\n+++ qux/bar\t2017-01-02 00:00:00\n" +
+		"@@ -1 +1 @@\n-bar\n+baz\n"
+
+	_, err := billy.ApplyPatch(s.fs, strings.NewReader(patch), &billy.PatchOptions{Strip: -1})
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "baz\n")
+}
+
+func (s *PatchSuite) TestApplyPatchConflict(c *C) {
+	writeFile(c, s.fs, "foo", "1\n2\n3\n4\n5\n6\n7\n8\nnine\n")
+	patch := fooPatch + "--- a/qux/bar\n+++ b/qux/bar\n@@ -1 +1 @@\n-bar\n+baz\n"
+
+	_, err := billy.ApplyPatch(s.fs, strings.NewReader(patch), nil)
+	c.Assert(err, FitsTypeOf, &billy.PatchConflictError{})
+	c.Assert(err.(*billy.PatchConflictError).Paths, DeepEquals, []string{"foo"})
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "1\n2\n3\n4\n5\n6\n7\n8\nnine\n")
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar\n")
+
+	r, err := billy.ApplyPatch(s.fs, strings.NewReader(patch), &billy.PatchOptions{Rejects: true})
+	c.Assert(err, IsNil)
+	c.Assert(r.Modified, DeepEquals, []string{"foo", "qux/bar"})
+	c.Assert(r.Rejected, DeepEquals, []string{"foo"})
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "one\n2\n3\n4\n5\n6\n7\n8\nnine\n")
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "baz\n")
+	c.Assert(readFile(c, s.fs, "foo.rej"), Equals,
+		"--- foo\n+++ foo\n@@ -7,3 +7,4 @@\n 7\n 8\n 9\n+10\n",
+	)
+}
+
+func (s *PatchSuite) TestApplyPatchDryRun(c *C) {
+	r, err := billy.ApplyPatch(s.fs, strings.NewReader(fooPatch), &billy.PatchOptions{DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(r.Modified, DeepEquals, []string{"foo"})
+	c.Assert(readFile(c, s.fs, "foo"), Equals, "1\n2\n3\n4\n5\n6\n7\n8\n9\n")
+}
+
+func (s *PatchSuite) TestApplyPatchErrors(c *C) {
+	_, err := billy.ApplyPatch(s.fs, strings.NewReader("--- a/foo\n+++ b/foo\n@@ -1,3 +1,3 @@\n 1\n"), nil)
+	c.Assert(err, ErrorMatches, `patch line 3: truncated hunk .*`)
+
+	patch := "diff --git a/bin b/bin\nindex 1..2 100644\nGIT binary patch\nliteral 0\n"
+	_, err = billy.ApplyPatch(s.fs, strings.NewReader(patch), nil)
+	c.Assert(err, ErrorMatches, `.*binary patches not supported`)
+
+	patch = "--- /dev/null\n+++ b/foo\n@@ -0,0 +1 @@\n+foo\n"
+	_, err = billy.ApplyPatch(s.fs, strings.NewReader(patch), nil)
+	c.Assert(err, FitsTypeOf, &billy.PatchConflictError{})
+}
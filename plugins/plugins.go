@@ -0,0 +1,46 @@
+// Package plugins loads out-of-tree backends and wrappers built as Go
+// plugins, kept apart from the billy package so the programs not loading
+// plugins don't link the plugin package and its dependencies.
+package plugins // import "srcd.works/go-billy.v1/plugins"
+
+import (
+	"fmt"
+	"path/filepath"
+	"plugin"
+	"sort"
+)
+
+// Ext is the extension of the plugins loaded by LoadDir.
+const Ext = ".so"
+
+// Load loads the Go plugin at path, built with -buildmode=plugin. The plugin
+// registers its backends and wrappers from its init functions, with
+// billy.Register and billy.RegisterWrapper, so the core doesn't import their
+// dependencies. Plugins are only supported on the platforms supported by the
+// plugin package, and must be built against the same version of billy.
+func Load(path string) error {
+	if _, err := plugin.Open(path); err != nil {
+		return fmt.Errorf("billy: loading plugin %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// LoadDir loads, in lexical order, all the plugins in dir with the Ext
+// extension, stopping at the first failure. It returns the paths of the
+// plugins loaded.
+func LoadDir(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+Ext))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	for i, path := range paths {
+		if err := Load(path); err != nil {
+			return paths[:i], err
+		}
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,33 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PluginsSuite struct{}
+
+var _ = Suite(&PluginsSuite{})
+
+func (s *PluginsSuite) TestLoadDir(c *C) {
+	dir, err := ioutil.TempDir("", "billy-plugins")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	paths, err := LoadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(paths, HasLen, 0)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "README"), nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "invalid.so"), []byte("foo"), 0644), IsNil)
+
+	paths, err = LoadDir(dir)
+	c.Assert(err, ErrorMatches, "billy: loading plugin .*invalid.so: .*")
+	c.Assert(paths, HasLen, 0)
+}
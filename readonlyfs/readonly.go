@@ -0,0 +1,232 @@
+// Package readonlyfs provides a billy filesystem wrapper rejecting any write.
+package readonlyfs // import "srcd.works/go-billy.v1/readonlyfs"
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// ReadOnly wraps a billy.Filesystem allowing only read operations, any other
+// operation returns billy.ErrReadOnly. The read-only mode can be toggled at
+// runtime with SetReadOnly, to fence the writes during maintenance windows.
+type ReadOnly struct {
+	fs       billy.Filesystem
+	readOnly *int32
+}
+
+// New returns a new ReadOnly filesystem wrapping the given one, the read-only
+// mode is enabled.
+func New(fs billy.Filesystem) *ReadOnly {
+	ro := int32(1)
+	return &ReadOnly{fs: fs, readOnly: &ro}
+}
+
+// SetReadOnly enables or disables the read-only mode. It affects also the
+// filesystems returned by Dir and the files already open, any write done
+// while enabled returns billy.ErrReadOnly. It's safe for concurrent use.
+func (fs *ReadOnly) SetReadOnly(readOnly bool) {
+	var v int32
+	if readOnly {
+		v = 1
+	}
+
+	atomic.StoreInt32(fs.readOnly, v)
+}
+
+// IsReadOnly returns true if the read-only mode is enabled.
+func (fs *ReadOnly) IsReadOnly() bool {
+	return atomic.LoadInt32(fs.readOnly) == 1
+}
+
+// Create creates the named file, unless in read-only mode.
+func (fs *ReadOnly) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *ReadOnly) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file, returns billy.ErrReadOnly if flag requests any kind
+// of write access while in read-only mode.
+func (fs *ReadOnly) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if isWrite(flag) && fs.IsReadOnly() {
+		return nil, billy.ErrReadOnly
+	}
+
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil || !isWrite(flag) {
+		return f, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+// Stat returns the FileInfo structure describing file.
+func (fs *ReadOnly) Stat(filename string) (billy.FileInfo, error) {
+	return fs.fs.Stat(filename)
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory.
+func (fs *ReadOnly) ReadDir(path string) ([]billy.FileInfo, error) {
+	return fs.fs.ReadDir(path)
+}
+
+// TempFile creates a temporary file, unless in read-only mode.
+func (fs *ReadOnly) TempFile(dir, prefix string) (billy.File, error) {
+	if fs.IsReadOnly() {
+		return nil, billy.ErrReadOnly
+	}
+
+	f, err := fs.fs.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+// Rename renames a file, unless in read-only mode.
+func (fs *ReadOnly) Rename(from, to string) error {
+	if fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file, unless in read-only mode.
+func (fs *ReadOnly) Remove(filename string) error {
+	if fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link, unless in read-only mode.
+func (fs *ReadOnly) Symlink(target, link string) error {
+	if fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *ReadOnly) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links.
+func (fs *ReadOnly) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.fs.Lstat(filename)
+}
+
+// MkdirAll creates a directory and its parents, unless in read-only mode.
+func (fs *ReadOnly) MkdirAll(path string, perm os.FileMode) error {
+	if fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file, unless in read-only mode.
+func (fs *ReadOnly) Chmod(name string, mode os.FileMode) error {
+	if fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file, unless in read-only mode.
+func (fs *ReadOnly) Chtimes(name string, atime, mtime time.Time) error {
+	if fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Ping checks the underlying filesystem, as billy.Ping.
+func (fs *ReadOnly) Ping(ctx context.Context) error {
+	_, err := billy.Ping(ctx, fs.fs)
+	return err
+}
+
+// Flush flushes the underlying filesystem, as billy.Flush.
+func (fs *ReadOnly) Flush(ctx context.Context) error {
+	return billy.Flush(ctx, fs.fs)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *ReadOnly) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close.
+func (fs *ReadOnly) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *ReadOnly) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new ReadOnly filesystem rooted at the given path, sharing the
+// read-only mode with the current one.
+func (fs *ReadOnly) Dir(path string) billy.Filesystem {
+	return &ReadOnly{fs: fs.fs.Dir(path), readOnly: fs.readOnly}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *ReadOnly) Base() string {
+	return fs.fs.Base()
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// file is a file open for writing, whose writes are rejected while the
+// filesystem is in read-only mode.
+type file struct {
+	billy.File
+	fs *ReadOnly
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.fs.IsReadOnly() {
+		return 0, billy.ErrReadOnly
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *file) Truncate(size int64) error {
+	if f.fs.IsReadOnly() {
+		return billy.ErrReadOnly
+	}
+
+	return f.File.Truncate(size)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
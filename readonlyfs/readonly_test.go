@@ -0,0 +1,120 @@
+package readonlyfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ReadOnlySuite struct {
+	fs *ReadOnly
+}
+
+var _ = Suite(&ReadOnlySuite{})
+
+func (s *ReadOnlySuite) SetUpTest(c *C) {
+	m := memory.New()
+	f, err := m.Create("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	s.fs = New(m)
+}
+
+func (s *ReadOnlySuite) TestRead(c *C) {
+	f, err := s.fs.Open("qux/foo")
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+
+	info, err := s.fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(info, HasLen, 1)
+}
+
+func (s *ReadOnlySuite) TestWrite(c *C) {
+	_, err := s.fs.Create("bar")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+
+	_, err = s.fs.OpenFile("qux/foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, Equals, billy.ErrReadOnly)
+
+	_, err = s.fs.TempFile("", "bar")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+
+	c.Assert(s.fs.Rename("qux/foo", "bar"), Equals, billy.ErrReadOnly)
+	c.Assert(s.fs.Remove("qux/foo"), Equals, billy.ErrReadOnly)
+}
+
+func (s *ReadOnlySuite) TestDir(c *C) {
+	qux := s.fs.Dir("qux")
+	_, err := qux.Create("bar")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+
+	f, err := qux.Open("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *ReadOnlySuite) TestMaxLinks(c *C) {
+	fs := New(memory.NewWithOptions(memory.Options{MaxLinks: 8}))
+	c.Assert(billy.MaxLinks(fs), Equals, 8)
+	c.Assert(billy.MaxLinks(s.fs), Equals, billy.DefaultMaxLinks)
+}
+
+func (s *ReadOnlySuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend:  "mem://readonlyfs",
+		Wrappers: []billy.WrapperConfig{{Name: "readonly"}},
+	})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Create("bar")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+}
+
+func (s *ReadOnlySuite) TestSetReadOnly(c *C) {
+	c.Assert(s.fs.IsReadOnly(), Equals, true)
+	s.fs.SetReadOnly(false)
+	c.Assert(s.fs.IsReadOnly(), Equals, false)
+
+	f, err := s.fs.Create("bar")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+
+	s.fs.SetReadOnly(true)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	c.Assert(f.Close(), IsNil)
+
+	qux := s.fs.Dir("qux")
+	c.Assert(qux.Remove("foo"), Equals, billy.ErrReadOnly)
+
+	s.fs.SetReadOnly(false)
+	c.Assert(qux.Remove("foo"), IsNil)
+}
+
+type WritableSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&WritableSuite{})
+
+func (s *WritableSuite) SetUpTest(c *C) {
+	fs := New(memory.New())
+	fs.SetReadOnly(false)
+	s.FilesystemSuite.Fs = fs
+}
@@ -0,0 +1,74 @@
+package billy
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory creates the filesystem addressed by a URI, rooted at its path. The
+// options of the backend are given in the query string.
+type Factory func(u *url.URL) (Filesystem, error)
+
+var factories = struct {
+	sync.RWMutex
+	schemes map[string]Factory
+}{schemes: make(map[string]Factory)}
+
+// Register makes a backend available to Open with the given scheme, it's
+// intended to be called from the init function of the backend packages. If
+// Register is called twice with the same scheme it panics.
+func Register(scheme string, f Factory) {
+	factories.Lock()
+	defer factories.Unlock()
+
+	if f == nil {
+		panic("billy: Register factory is nil")
+	}
+
+	if _, dup := factories.schemes[scheme]; dup {
+		panic("billy: Register called twice for scheme " + scheme)
+	}
+
+	factories.schemes[scheme] = f
+}
+
+// Schemes returns a sorted list of the registered schemes.
+func Schemes() []string {
+	factories.RLock()
+	defer factories.RUnlock()
+
+	var l []string
+	for scheme := range factories.schemes {
+		l = append(l, scheme)
+	}
+
+	sort.Strings(l)
+	return l
+}
+
+// Open returns the filesystem addressed by uri, such as file:///srv/data or
+// mem://name, using the backend registered for its scheme. A uri without
+// scheme is a path of the local filesystem, handled by the file backend.
+// The backends are registered importing their packages.
+func Open(uri string) (Filesystem, error) {
+	u := &url.URL{Scheme: "file", Path: uri}
+	if strings.Contains(uri, "://") {
+		var err error
+		if u, err = url.Parse(uri); err != nil {
+			return nil, err
+		}
+	}
+
+	factories.RLock()
+	f, ok := factories.schemes[u.Scheme]
+	factories.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("billy: unknown scheme %q (forgotten import?)", u.Scheme)
+	}
+
+	return f(u)
+}
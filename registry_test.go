@@ -0,0 +1,41 @@
+package billy_test
+
+import (
+	"net/url"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type RegistrySuite struct{}
+
+var _ = Suite(&RegistrySuite{})
+
+// opened is the URL of the last test-registry filesystem opened. The scheme
+// is registered once, so the tests can be run more than once.
+var opened *url.URL
+
+func init() {
+	billy.Register("test-registry", func(u *url.URL) (billy.Filesystem, error) {
+		opened = u
+		return memory.New(), nil
+	})
+}
+
+func (s *RegistrySuite) TestOpen(c *C) {
+	fs, err := billy.Open("test-registry://host/foo?bar=qux")
+	c.Assert(err, IsNil)
+	c.Assert(fs, NotNil)
+	c.Assert(opened.Host, Equals, "host")
+	c.Assert(opened.Path, Equals, "/foo")
+	c.Assert(opened.Query().Get("bar"), Equals, "qux")
+
+	c.Assert(billy.Schemes(), DeepEquals, []string{"mem", "test-registry"})
+	c.Assert(func() { billy.Register("test-registry", nil) }, Panics, "billy: Register factory is nil")
+}
+
+func (s *RegistrySuite) TestOpenUnknown(c *C) {
+	_, err := billy.Open("unknown://foo")
+	c.Assert(err, ErrorMatches, `billy: unknown scheme "unknown".*`)
+}
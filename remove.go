@@ -0,0 +1,38 @@
+package billy
+
+import "os"
+
+// RemoveAll removes path and everything it contains, as os.RemoveAll, it
+// does nothing if path doesn't exist. The symbolic links are removed, not
+// followed. It stops at the first error.
+func RemoveAll(fs Filesystem, path string) error {
+	fi, err := fs.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		entries, err := fs.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := RemoveAll(fs, fs.Join(path, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	// some backends have no empty directories, removing them with their
+	// last entry.
+	if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
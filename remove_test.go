@@ -0,0 +1,49 @@
+package billy_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type RemoveSuite struct{}
+
+var _ = Suite(&RemoveSuite{})
+
+func (s *RemoveSuite) TestRemoveAll(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo", "foo")
+	writeFile(c, fs, "qux/bar/baz", "baz")
+	writeFile(c, fs, "foo", "foo")
+	c.Assert(fs.MkdirAll("qux/empty", 0755), IsNil)
+	c.Assert(fs.Symlink("../foo", "qux/link"), IsNil)
+
+	c.Assert(billy.RemoveAll(fs, "qux"), IsNil)
+	_, err := fs.Lstat("qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+
+	c.Assert(billy.RemoveAll(fs, "foo"), IsNil)
+	c.Assert(billy.RemoveAll(fs, "missing"), IsNil)
+
+	l, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 0)
+}
+
+func (s *RemoveSuite) TestRemoveAllSymlink(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo", "foo")
+	c.Assert(fs.Symlink("qux", "link"), IsNil)
+
+	c.Assert(billy.RemoveAll(fs, "link"), IsNil)
+	_, err := fs.Lstat("link")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+}
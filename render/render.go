@@ -0,0 +1,236 @@
+// Package render renders trees of text/template templates from a billy
+// filesystem to another, as project skeletons, kept apart from the billy
+// package so the programs not using it don't link the template packages.
+package render // import "srcd.works/go-billy.v1/render"
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"srcd.works/go-billy.v1"
+)
+
+// Options describes how Tree renders the templates.
+type Options struct {
+	// Suffix, if not empty, renders only the files whose name ends with it,
+	// such as ".tmpl", removing it from their names, the other files are
+	// copied as is. By default every regular file is rendered.
+	Suffix string
+	// Names renders also the names of the files and directories as
+	// templates, with the same data, so they can depend on it.
+	Names bool
+	// Funcs are the functions available to the templates.
+	Funcs template.FuncMap
+	// LeftDelim and RightDelim are the delimiters of the actions, "{{" and
+	// "}}" by default.
+	LeftDelim, RightDelim string
+	// MissingKeyError fails the rendering of a template using a key missing
+	// from a map, instead of rendering "<no value>".
+	MissingKeyError bool
+}
+
+// Error is returned by Tree when a template fails to be parsed or executed.
+type Error struct {
+	// Path is the path of the template in the source.
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return "render " + e.Path + ": " + e.Err.Error()
+}
+
+// Tree renders the templates of src, with the syntax of package text/template,
+// executing them with data and writing the results to dst, preserving the
+// directory structure and the permissions. The symbolic links are copied as
+// links, with the same target, and the special files are skipped. The files
+// already present in dst are replaced. If opts is nil the default options are
+// used.
+func Tree(dst, src billy.Filesystem, data interface{}, opts *Options) error {
+	return renderWalk(dst, src, data, opts, func(f *renderedFile) error {
+		switch {
+		case f.info.IsDir():
+			if err := dst.MkdirAll(f.target, f.info.Mode().Perm()); err != nil {
+				return err
+			}
+		case f.info.Mode()&os.ModeSymlink != 0:
+			return copySymlink(dst, f.target, src, f.path)
+		case f.template:
+			if err := writeRendered(dst, f.target, f.content); err != nil {
+				return err
+			}
+		default:
+			if err := billy.CopyFile(dst, f.target, src, f.path); err != nil {
+				return err
+			}
+		}
+
+		return renderMode(dst, f.target, f.info)
+	})
+}
+
+// renderedFile is a directory, symbolic link or regular file of the source
+// of renderWalk.
+type renderedFile struct {
+	// path is the path in the source and target the one in the destination.
+	path, target string
+	info         billy.FileInfo
+	// template is true for the templates, and content the result of
+	// rendering them.
+	template bool
+	content  []byte
+}
+
+// renderWalk walks src rendering the names and the templates as described by
+// opts, calling fn for every file. The special files are skipped.
+func renderWalk(dst, src billy.Filesystem, data interface{}, opts *Options, fn func(*renderedFile) error) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	// dirs holds the paths of the directories in dst, by their path in src.
+	dirs := map[string]string{".": ""}
+	return billy.Walk(src, "", func(path string, info billy.FileInfo, err error) error {
+		if err != nil || path == "" {
+			return err
+		}
+
+		mode := info.Mode()
+		if !info.IsDir() && mode&os.ModeSymlink == 0 && !mode.IsRegular() {
+			return nil
+		}
+
+		name := info.Name()
+		isTemplate := mode.IsRegular() && strings.HasSuffix(name, opts.Suffix)
+		if isTemplate {
+			name = strings.TrimSuffix(name, opts.Suffix)
+		}
+
+		name, err = renderName(path, name, data, opts)
+		if err != nil {
+			return err
+		}
+
+		target := dst.Join(dirs[filepath.Dir(path)], name)
+		if info.IsDir() {
+			dirs[path] = target
+		}
+
+		f := &renderedFile{path: path, target: target, info: info, template: isTemplate}
+		if isTemplate {
+			if f.content, err = renderFile(src, path, data, opts); err != nil {
+				return err
+			}
+		}
+
+		return fn(f)
+	})
+}
+
+// renderMode sets the permissions of the file rendered from info, if
+// supported by fs.
+func renderMode(fs billy.Filesystem, path string, info billy.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	err := fs.Chmod(path, info.Mode().Perm())
+	if err == billy.ErrNotSupported {
+		return nil
+	}
+
+	return err
+}
+
+// renderName returns the name of the file in the destination, rendering it
+// if Options.Names is set.
+func renderName(path, name string, data interface{}, opts *Options) (string, error) {
+	if !opts.Names {
+		return name, nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := executeTemplate(buf, path, name, data, opts); err != nil {
+		return "", err
+	}
+
+	rendered := buf.String()
+	if rendered == "" || rendered == "." || rendered == ".." || strings.ContainsAny(rendered, `/\`) {
+		return "", &Error{Path: path, Err: billy.ErrInvalidName}
+	}
+
+	return rendered, nil
+}
+
+// renderFile returns the result of rendering the template src of srcfs.
+func renderFile(srcfs billy.Filesystem, src string, data interface{}, opts *Options) ([]byte, error) {
+	f, err := srcfs.Open(src)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := executeTemplate(buf, src, string(text), data, opts); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeRendered writes content to the named file, created or truncated if it
+// already exists.
+func writeRendered(fs billy.Filesystem, filename string, content []byte) error {
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func executeTemplate(buf *bytes.Buffer, path, text string, data interface{}, opts *Options) error {
+	t := template.New(path).Funcs(opts.Funcs).Delims(opts.LeftDelim, opts.RightDelim)
+	if opts.MissingKeyError {
+		t.Option("missingkey=error")
+	}
+
+	if _, err := t.Parse(text); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+
+	if err := t.Execute(buf, data); err != nil {
+		return &Error{Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// copySymlink creates dst as a symbolic link with the target of src,
+// replacing it if it exists.
+func copySymlink(dstfs billy.Filesystem, dst string, srcfs billy.Filesystem, src string) error {
+	target, err := srcfs.Readlink(src)
+	if err != nil {
+		return err
+	}
+
+	if err := dstfs.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return dstfs.Symlink(target, dst)
+}
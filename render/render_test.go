@@ -0,0 +1,106 @@
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type RenderSuite struct{}
+
+var _ = Suite(&RenderSuite{})
+
+type project struct {
+	Name    string
+	Authors []string
+}
+
+func (s *RenderSuite) TestRenderTree(c *C) {
+	src := memory.New()
+	writeFile(c, src, "README.md", "# {{.Name}}\n{{range .Authors}}- {{.}}\n{{end}}")
+	writeFile(c, src, "{{.Name}}/main.go", "package {{.Name}}")
+	writeFile(c, src, "bin/run", "#!/bin/sh\necho {{.Name}}")
+	c.Assert(src.Chmod("bin/run", 0755), IsNil)
+	c.Assert(src.Symlink("README.md", "link"), IsNil)
+
+	dst := memory.New()
+	writeFile(c, dst, "README.md", "old content, longer than the new one")
+	data := project{Name: "foo", Authors: []string{"bar", "baz"}}
+	c.Assert(Tree(dst, src, data, nil), IsNil)
+	c.Assert(readFile(c, dst, "README.md"), Equals, "# foo\n- bar\n- baz\n")
+	c.Assert(readFile(c, dst, "{{.Name}}/main.go"), Equals, "package foo")
+	c.Assert(readFile(c, dst, "link"), Equals, "# foo\n- bar\n- baz\n")
+
+	fi, err := dst.Stat("bin/run")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.FileMode(0755))
+
+	dst = memory.New()
+	c.Assert(Tree(dst, src, data, &Options{Names: true}), IsNil)
+	c.Assert(readFile(c, dst, "foo/main.go"), Equals, "package foo")
+}
+
+func (s *RenderSuite) TestRenderTreeSuffix(c *C) {
+	src := memory.New()
+	writeFile(c, src, "qux/foo.go.tmpl", "package [[.]]")
+	writeFile(c, src, "qux/bar.go", "package {{.}}")
+	writeFile(c, src, "qux/baz.tmpl", "[[upper .]]")
+
+	dst := memory.New()
+	c.Assert(Tree(dst, src, "qux", &Options{
+		Suffix:    ".tmpl",
+		Funcs:     template.FuncMap{"upper": strings.ToUpper},
+		LeftDelim: "[[", RightDelim: "]]",
+	}), IsNil)
+	c.Assert(readFile(c, dst, "qux/foo.go"), Equals, "package qux")
+	c.Assert(readFile(c, dst, "qux/bar.go"), Equals, "package {{.}}")
+	c.Assert(readFile(c, dst, "qux/baz"), Equals, "QUX")
+	_, err := dst.Stat("qux/foo.go.tmpl")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *RenderSuite) TestRenderTreeErrors(c *C) {
+	src := memory.New()
+	writeFile(c, src, "foo", "{{.Missing}}")
+
+	dst := memory.New()
+	writeFile(c, dst, "foo", "foo")
+	err := Tree(dst, src, map[string]string{}, &Options{MissingKeyError: true})
+	c.Assert(err, FitsTypeOf, &Error{})
+	c.Assert(err.(*Error).Path, Equals, "foo")
+	c.Assert(readFile(c, dst, "foo"), Equals, "foo")
+
+	writeFile(c, src, "foo", "{{")
+	err = Tree(dst, src, nil, nil)
+	c.Assert(err, ErrorMatches, "render foo: .*")
+
+	src = memory.New()
+	writeFile(c, src, "{{.}}", "foo")
+	err = Tree(dst, src, "bar/baz", &Options{Names: true})
+	c.Assert(err.(*Error).Err, Equals, billy.ErrInvalidName)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
@@ -0,0 +1,318 @@
+package render
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"srcd.works/go-billy.v1"
+)
+
+// skeletonStateFormat is the version of the encoding of the state saved by
+// ApplySkeleton, the states saved with another one are ignored.
+const skeletonStateFormat = 1
+
+// SkeletonOptions describes how ApplySkeleton updates the destination.
+type SkeletonOptions struct {
+	// Render describes how the skeleton is rendered, as for Tree.
+	Render *Options
+	// DryRun reports the changes without writing them.
+	DryRun bool
+	// Overwrite writes also the conflicting files, replacing the changes done
+	// in the destination. The files of another type, such as a directory in
+	// the place of a file, are never replaced.
+	Overwrite bool
+	// State, if not empty, is the name of the file of the destination where
+	// the hashes of the files written are saved, so the ones not changed in
+	// the destination since the last apply can be updated without conflicts.
+	State string
+}
+
+// SkeletonReport lists the paths of the destination found by ApplySkeleton.
+type SkeletonReport struct {
+	// Created are the files and directories missing from the destination.
+	Created []string
+	// Updated are the files whose content or permissions were changed.
+	Updated []string
+	// Unchanged are the files already equal to the rendered ones.
+	Unchanged []string
+	// Conflicts are the files changed in the destination that differ from
+	// the rendered ones, they are only written with Overwrite. Without a
+	// state every existing file that differs is a conflict.
+	Conflicts []string
+}
+
+type skeletonState struct {
+	Format int `json:"format"`
+	// Files holds the hex encoded SHA-1 of the files written, by path.
+	Files map[string]string `json:"files"`
+}
+
+// ApplySkeleton renders the skeleton src with data, as Tree, and
+// compares the result with the tree of dst, writing only the files created or
+// changed. The files of dst differing from the rendered ones are conflicts,
+// left as they are unless Overwrite is set, except the ones recorded by the
+// state as written by a previous apply and not changed since. The files of
+// dst missing from the skeleton are ignored. If opts is nil the default
+// options are used.
+func ApplySkeleton(dst, src billy.Filesystem, data interface{}, opts *SkeletonOptions) (*SkeletonReport, error) {
+	if opts == nil {
+		opts = &SkeletonOptions{}
+	}
+
+	state, err := loadSkeletonState(dst, opts.State)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &skeletonApply{dst: dst, src: src, opts: opts, state: state, blocked: make(map[string]bool)}
+	if err := renderWalk(dst, src, data, opts.Render, a.apply); err != nil {
+		return nil, err
+	}
+
+	if opts.State != "" && !opts.DryRun {
+		if err := saveSkeletonState(dst, opts.State, state); err != nil {
+			return nil, err
+		}
+	}
+
+	return &a.report, nil
+}
+
+// skeletonApply holds the state of an ApplySkeleton.
+type skeletonApply struct {
+	dst, src billy.Filesystem
+	opts     *SkeletonOptions
+	state    *skeletonState
+	report   SkeletonReport
+	// blocked are the directories in conflict, their entries are conflicts
+	// too.
+	blocked map[string]bool
+}
+
+func (a *skeletonApply) apply(f *renderedFile) error {
+	if a.blocked[filepath.Dir(f.target)] {
+		a.conflict(f)
+		return nil
+	}
+
+	current, err := a.dst.Lstat(f.target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	switch {
+	case current == nil:
+		a.report.Created = append(a.report.Created, f.target)
+		return a.write(f)
+	case current.IsDir() != f.info.IsDir() || isSymlink(current) != isSymlink(f.info):
+		a.conflict(f)
+		return nil
+	case f.info.IsDir():
+		a.report.Unchanged = append(a.report.Unchanged, f.target)
+		return nil
+	case isSymlink(f.info):
+		return a.applySymlink(f)
+	case !current.Mode().IsRegular():
+		a.conflict(f)
+		return nil
+	}
+
+	content, err := a.content(f)
+	if err != nil {
+		return err
+	}
+
+	sum, err := hashFile(a.dst, f.target)
+	if err != nil {
+		return err
+	}
+
+	hexSum, newSum := hex.EncodeToString([]byte(sum)), skeletonHash(content)
+	switch {
+	case hexSum == newSum && current.Mode().Perm() == f.info.Mode().Perm():
+		a.state.Files[f.target] = newSum
+		a.report.Unchanged = append(a.report.Unchanged, f.target)
+		return nil
+	case hexSum == newSum:
+		a.state.Files[f.target] = newSum
+		a.report.Updated = append(a.report.Updated, f.target)
+		if a.opts.DryRun {
+			return nil
+		}
+
+		return renderMode(a.dst, f.target, f.info)
+	case a.state.Files[f.target] == hexSum:
+		a.report.Updated = append(a.report.Updated, f.target)
+	default:
+		a.report.Conflicts = append(a.report.Conflicts, f.target)
+		if !a.opts.Overwrite {
+			return nil
+		}
+	}
+
+	return a.write(f)
+}
+
+// applySymlink applies the symbolic link f, present in the destination.
+func (a *skeletonApply) applySymlink(f *renderedFile) error {
+	current, err := a.dst.Readlink(f.target)
+	if err != nil {
+		return err
+	}
+
+	target, err := a.src.Readlink(f.path)
+	if err != nil {
+		return err
+	}
+
+	if current == target {
+		a.report.Unchanged = append(a.report.Unchanged, f.target)
+		return nil
+	}
+
+	a.report.Conflicts = append(a.report.Conflicts, f.target)
+	if !a.opts.Overwrite {
+		return nil
+	}
+
+	return a.write(f)
+}
+
+// conflict records f as a conflict, blocking its entries if it's a
+// directory.
+func (a *skeletonApply) conflict(f *renderedFile) {
+	a.report.Conflicts = append(a.report.Conflicts, f.target)
+	if f.info.IsDir() {
+		a.blocked[f.target] = true
+	}
+}
+
+// write writes f to the destination, unless in dry run.
+func (a *skeletonApply) write(f *renderedFile) error {
+	if a.opts.DryRun {
+		return nil
+	}
+
+	switch {
+	case f.info.IsDir():
+		return a.dst.MkdirAll(f.target, f.info.Mode().Perm())
+	case isSymlink(f.info):
+		return copySymlink(a.dst, f.target, a.src, f.path)
+	}
+
+	content, err := a.content(f)
+	if err != nil {
+		return err
+	}
+
+	if err := writeRendered(a.dst, f.target, content); err != nil {
+		return err
+	}
+
+	a.state.Files[f.target] = skeletonHash(content)
+	return renderMode(a.dst, f.target, f.info)
+}
+
+// content returns the content of the regular file f, rendered if it's a
+// template.
+func (a *skeletonApply) content(f *renderedFile) ([]byte, error) {
+	if f.template {
+		return f.content, nil
+	}
+
+	r, err := a.src.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func isSymlink(fi billy.FileInfo) bool {
+	return fi.Mode()&os.ModeSymlink != 0
+}
+
+// hashFile returns the SHA-1 of the content of the named file.
+func hashFile(fs billy.Filesystem, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return string(h.Sum(nil)), nil
+}
+
+func skeletonHash(content []byte) string {
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadSkeletonState reads the state saved to the named file of fs, if any.
+func loadSkeletonState(fs billy.Filesystem, filename string) (*skeletonState, error) {
+	state := &skeletonState{Format: skeletonStateFormat, Files: make(map[string]string)}
+	if filename == "" {
+		return state, nil
+	}
+
+	f, err := fs.Open(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var saved skeletonState
+	if err := json.NewDecoder(f).Decode(&saved); err != nil {
+		return nil, err
+	}
+
+	if saved.Format == skeletonStateFormat && saved.Files != nil {
+		state.Files = saved.Files
+	}
+
+	return state, nil
+}
+
+// saveSkeletonState writes the state to the named file of fs, replacing it
+// through a temporary file.
+func saveSkeletonState(fs billy.Filesystem, filename string, state *skeletonState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	f, tmpfs, err := billy.TempFileFor(fs, nil, filename, ".skeleton")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		tmpfs.Remove(f.Filename())
+		return err
+	}
+
+	return billy.Move(tmpfs, f.Filename(), fs, filename)
+}
@@ -0,0 +1,104 @@
+package render
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type SkeletonSuite struct {
+	src billy.Filesystem
+}
+
+var _ = Suite(&SkeletonSuite{})
+
+func (s *SkeletonSuite) SetUpTest(c *C) {
+	s.src = memory.New()
+	writeFile(c, s.src, "README.md.tmpl", "# {{.}}")
+	writeFile(c, s.src, "qux/main.go.tmpl", "package {{.}}")
+	writeFile(c, s.src, "qux/LICENSE", "MIT")
+	c.Assert(s.src.Symlink("README.md", "link"), IsNil)
+}
+
+func (s *SkeletonSuite) apply(c *C, dst billy.Filesystem, data string, opts *SkeletonOptions) *SkeletonReport {
+	if opts == nil {
+		opts = &SkeletonOptions{}
+	}
+
+	opts.Render = &Options{Suffix: ".tmpl"}
+	r, err := ApplySkeleton(dst, s.src, data, opts)
+	c.Assert(err, IsNil)
+	return r
+}
+
+func (s *SkeletonSuite) TestApplySkeleton(c *C) {
+	dst := memory.New()
+	r := s.apply(c, dst, "foo", nil)
+	c.Assert(r.Created, DeepEquals, []string{"README.md", "link", "qux", "qux/LICENSE", "qux/main.go"})
+	c.Assert(readFile(c, dst, "qux/main.go"), Equals, "package foo")
+	c.Assert(readFile(c, dst, "link"), Equals, "# foo")
+
+	r = s.apply(c, dst, "foo", nil)
+	c.Assert(r.Created, HasLen, 0)
+	c.Assert(r.Unchanged, HasLen, 5)
+
+	writeFile(c, dst, "qux/LICENSE", "BSD")
+	c.Assert(dst.Chmod("README.md", 0600), IsNil)
+	r = s.apply(c, dst, "bar", nil)
+	c.Assert(r.Updated, DeepEquals, []string(nil))
+	c.Assert(r.Conflicts, DeepEquals, []string{"README.md", "qux/LICENSE", "qux/main.go"})
+	c.Assert(readFile(c, dst, "qux/main.go"), Equals, "package foo")
+	c.Assert(readFile(c, dst, "qux/LICENSE"), Equals, "BSD")
+
+	r = s.apply(c, dst, "bar", &SkeletonOptions{Overwrite: true})
+	c.Assert(r.Conflicts, HasLen, 3)
+	c.Assert(readFile(c, dst, "README.md"), Equals, "# bar")
+	c.Assert(readFile(c, dst, "qux/LICENSE"), Equals, "MIT")
+	fi, err := dst.Stat("README.md")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.FileMode(0666))
+}
+
+func (s *SkeletonSuite) TestApplySkeletonState(c *C) {
+	dst := memory.New()
+	opts := &SkeletonOptions{State: ".skeleton"}
+	s.apply(c, dst, "foo", opts)
+
+	writeFile(c, dst, "qux/LICENSE", "BSD")
+	r := s.apply(c, dst, "bar", opts)
+	c.Assert(r.Updated, DeepEquals, []string{"README.md", "qux/main.go"})
+	c.Assert(r.Unchanged, DeepEquals, []string{"link", "qux"})
+	c.Assert(r.Conflicts, DeepEquals, []string{"qux/LICENSE"})
+	c.Assert(readFile(c, dst, "qux/main.go"), Equals, "package bar")
+	c.Assert(readFile(c, dst, "qux/LICENSE"), Equals, "BSD")
+
+	r = s.apply(c, dst, "bar", opts)
+	c.Assert(r.Conflicts, DeepEquals, []string{"qux/LICENSE"})
+
+	writeFile(c, dst, "qux/LICENSE", "MIT")
+	r = s.apply(c, dst, "bar", opts)
+	c.Assert(r.Conflicts, HasLen, 0)
+	c.Assert(r.Unchanged, HasLen, 5)
+}
+
+func (s *SkeletonSuite) TestApplySkeletonDryRun(c *C) {
+	dst := memory.New()
+	r := s.apply(c, dst, "foo", &SkeletonOptions{DryRun: true, State: ".skeleton"})
+	c.Assert(r.Created, HasLen, 5)
+	infos, err := dst.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 0)
+}
+
+func (s *SkeletonSuite) TestApplySkeletonTypeConflict(c *C) {
+	dst := memory.New()
+	writeFile(c, dst, "qux", "qux")
+	c.Assert(dst.MkdirAll("README.md", 0755), IsNil)
+
+	r := s.apply(c, dst, "foo", &SkeletonOptions{Overwrite: true})
+	c.Assert(r.Created, DeepEquals, []string{"link"})
+	c.Assert(r.Conflicts, DeepEquals, []string{"README.md", "qux", "qux/LICENSE", "qux/main.go"})
+	c.Assert(readFile(c, dst, "qux"), Equals, "qux")
+}
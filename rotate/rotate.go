@@ -0,0 +1,197 @@
+// Package rotate provides size and age based rotation of log files, with
+// compression of the rotated files and retention policies, working on any
+// billy filesystem.
+package rotate // import "srcd.works/go-billy.v1/rotate"
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// timeFormat is the format of the time appended to the rotated files.
+const timeFormat = "20060102T150405.000000000"
+
+// compressedExt is the extension of the compressed rotated files.
+const compressedExt = ".gz"
+
+// Options describes when a file is rotated and how many of the rotated files,
+// the backups, are retained.
+type Options struct {
+	// MaxSize, if greater than zero, rotates the file before a write that
+	// would make it exceed this size.
+	MaxSize int64
+	// MaxAge, if greater than zero, rotates the file once it was opened for
+	// more than this duration.
+	MaxAge time.Duration
+	// Compress compresses the backups with gzip.
+	Compress bool
+	// MaxBackups, if greater than zero, is the number of backups retained,
+	// the oldest ones are removed.
+	MaxBackups int
+	// MaxBackupAge, if greater than zero, removes the backups rotated more
+	// than this duration ago.
+	MaxBackupAge time.Duration
+}
+
+// Backup is a rotated file.
+type Backup struct {
+	// Path is the path of the backup in the filesystem.
+	Path string
+	// Time is the time when the file was rotated.
+	Time time.Time
+	// Compressed is true if the backup is compressed with gzip.
+	Compressed bool
+}
+
+// Open returns an Appender writing to the given path, rotated following
+// opts. If opts is nil the file is never rotated.
+func Open(fs billy.Filesystem, path string, opts *Options) (*billy.Appender, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	return billy.OpenAppender(fs, path, &billy.AppenderOptions{
+		MaxSize: opts.MaxSize,
+		MaxAge:  opts.MaxAge,
+		Rotate: func(fs billy.Filesystem, path string) error {
+			return Rotate(fs, path, opts)
+		},
+	})
+}
+
+// Rotate renames the given file appending the current time to its name,
+// compresses it if requested and removes the backups exceeding the retention
+// policies. A missing file is not rotated, but the retention policies are
+// still applied.
+func Rotate(fs billy.Filesystem, path string, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	backup := path + "." + time.Now().UTC().Format(timeFormat)
+	err := fs.Rename(path, backup)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil && opts.Compress {
+		if err := compress(fs, backup); err != nil {
+			return err
+		}
+	}
+
+	return prune(fs, path, opts)
+}
+
+// Backups returns the backups of the given file, the most recent first.
+func Backups(fs billy.Filesystem, path string) ([]Backup, error) {
+	dir := filepath.Dir(path)
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(path) + "."
+	var backups []Backup
+	for _, fi := range infos {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		b := Backup{Path: fs.Join(dir, name)}
+		stamp := strings.TrimPrefix(name, prefix)
+		if strings.HasSuffix(stamp, compressedExt) {
+			stamp = strings.TrimSuffix(stamp, compressedExt)
+			b.Compressed = true
+		}
+
+		if b.Time, err = time.Parse(timeFormat, stamp); err != nil {
+			continue
+		}
+
+		backups = append(backups, b)
+	}
+
+	sort.Sort(byTime(backups))
+	return backups, nil
+}
+
+func prune(fs billy.Filesystem, path string, opts *Options) error {
+	if opts.MaxBackups <= 0 && opts.MaxBackupAge <= 0 {
+		return nil
+	}
+
+	backups, err := Backups(fs, path)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range backups {
+		expired := opts.MaxBackupAge > 0 && time.Since(b.Time) > opts.MaxBackupAge
+		if !expired && (opts.MaxBackups <= 0 || i < opts.MaxBackups) {
+			continue
+		}
+
+		if err := fs.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compress replaces the given file with a gzip compressed copy, the copy is
+// written to a temporary file and renamed, so a backup is never partially
+// compressed.
+func compress(fs billy.Filesystem, path string) error {
+	src, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, tmpfs, err := billy.TempFileFor(fs, nil, path, ".rotate")
+	if err != nil {
+		return err
+	}
+
+	if err := gzipCopy(dst, src); err != nil {
+		tmpfs.Remove(dst.Filename())
+		return err
+	}
+
+	if err := billy.Move(tmpfs, dst.Filename(), fs, path+compressedExt); err != nil {
+		return err
+	}
+
+	return fs.Remove(path)
+}
+
+func gzipCopy(dst billy.File, src io.Reader) error {
+	w := gzip.NewWriter(dst)
+	_, err := io.Copy(w, src)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+type byTime []Backup
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Less(i, j int) bool { return s[i].Time.After(s[j].Time) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,110 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type RotateSuite struct{}
+
+var _ = Suite(&RotateSuite{})
+
+func (s *RotateSuite) TestOpen(c *C) {
+	fs := memory.New()
+	a, err := Open(fs, "logs/app.log", &Options{MaxSize: 4})
+	c.Assert(err, IsNil)
+
+	for _, r := range []string{"foo\n", "bar\n", "qux\n"} {
+		_, err = a.Write([]byte(r))
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(a.Close(), IsNil)
+
+	backups, err := Backups(fs, "logs/app.log")
+	c.Assert(err, IsNil)
+	c.Assert(backups, HasLen, 2)
+	c.Assert(readFile(c, fs, backups[0].Path), Equals, "bar\n")
+	c.Assert(readFile(c, fs, backups[1].Path), Equals, "foo\n")
+	c.Assert(readFile(c, fs, "logs/app.log"), Equals, "qux\n")
+}
+
+func (s *RotateSuite) TestRotateCompress(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "app.log", "foo")
+
+	c.Assert(Rotate(fs, "app.log", &Options{Compress: true}), IsNil)
+
+	backups, err := Backups(fs, "app.log")
+	c.Assert(err, IsNil)
+	c.Assert(backups, HasLen, 1)
+	c.Assert(backups[0].Compressed, Equals, true)
+
+	f, err := fs.Open(backups[0].Path)
+	c.Assert(err, IsNil)
+	r, err := gzip.NewReader(f)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+
+	infos, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+}
+
+func (s *RotateSuite) TestRotateMaxBackups(c *C) {
+	fs := memory.New()
+	for _, content := range []string{"foo", "bar", "qux"} {
+		writeFile(c, fs, "app.log", content)
+		c.Assert(Rotate(fs, "app.log", &Options{MaxBackups: 2}), IsNil)
+	}
+
+	backups, err := Backups(fs, "app.log")
+	c.Assert(err, IsNil)
+	c.Assert(backups, HasLen, 2)
+	c.Assert(readFile(c, fs, backups[0].Path), Equals, "qux")
+	c.Assert(readFile(c, fs, backups[1].Path), Equals, "bar")
+}
+
+func (s *RotateSuite) TestRotateMaxBackupAge(c *C) {
+	fs := memory.New()
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(timeFormat)
+	writeFile(c, fs, "app.log."+old+".gz", "foo")
+	writeFile(c, fs, "app.log.other", "bar")
+
+	c.Assert(Rotate(fs, "app.log", &Options{MaxBackupAge: 24 * time.Hour}), IsNil)
+
+	backups, err := Backups(fs, "app.log")
+	c.Assert(err, IsNil)
+	c.Assert(backups, HasLen, 0)
+
+	_, err = fs.Stat("app.log.other")
+	c.Assert(err, IsNil)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
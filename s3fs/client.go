@@ -0,0 +1,88 @@
+package s3fs
+
+import (
+	"io"
+	"time"
+
+	"srcd.works/go-billy.v1/internal/listcache"
+)
+
+// Client is the subset of the S3 API used by an S3 filesystem, on a single
+// bucket. The errors for missing keys must satisfy os.IsNotExist. It's
+// implemented by HTTPClient, and can be implemented on top of any SDK.
+type Client interface {
+	// Head returns the object with the given key.
+	Head(key string) (*Object, error)
+	// Get returns the content of the object with the given key, from off
+	// and of length bytes, or up to its end if length is negative.
+	Get(key string, off, length int64) (io.ReadCloser, error)
+	// Put creates or replaces the object with the given key, reading its
+	// size bytes of content from r.
+	Put(key string, r io.Reader, size int64, meta map[string]string) error
+	// Copy copies the object src to dst, with the given metadata, or the
+	// one of src if it's nil.
+	Copy(dst, src string, meta map[string]string) error
+	// Delete deletes the object with the given key, deleting a missing
+	// object doesn't fail.
+	Delete(key string) error
+	// List returns the objects whose keys start with prefix, sorted by key.
+	// If delimiter isn't empty the keys with it after the prefix are
+	// grouped into the common prefixes returned, as directories. At most max
+	// objects and prefixes are returned if max is greater than zero.
+	List(prefix, delimiter string, max int) ([]Object, []string, error)
+
+	// CreateMultipartUpload starts a multipart upload of the object with
+	// the given key, returning its id.
+	CreateMultipartUpload(key string, meta map[string]string) (string, error)
+	// UploadPart uploads the part with the given number, starting at one,
+	// of a multipart upload, returning its ETag. All the parts but the last
+	// must be at least of 5MiB.
+	UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error)
+	// CompleteMultipartUpload creates the object from the parts uploaded.
+	CompleteMultipartUpload(key, uploadID string, parts []Part) error
+	// AbortMultipartUpload discards a multipart upload and its parts.
+	AbortMultipartUpload(key, uploadID string) error
+}
+
+// Object describes an object of a bucket.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	// Meta holds the user metadata of the object, without the x-amz-meta-
+	// prefix. Only returned by Head.
+	Meta map[string]string
+}
+
+// Part is a part uploaded by a multipart upload.
+type Part struct {
+	Number int
+	ETag   string
+}
+
+// cachedClient is a Client invalidating the listings cached of the keys it
+// changes, and of their directories.
+type cachedClient struct {
+	Client
+	cache *listcache.Cache
+}
+
+func (c *cachedClient) Put(key string, r io.Reader, size int64, meta map[string]string) error {
+	defer c.cache.Invalidate(key)
+	return c.Client.Put(key, r, size, meta)
+}
+
+func (c *cachedClient) Copy(dst, src string, meta map[string]string) error {
+	defer c.cache.Invalidate(dst)
+	return c.Client.Copy(dst, src, meta)
+}
+
+func (c *cachedClient) Delete(key string) error {
+	defer c.cache.Invalidate(key)
+	return c.Client.Delete(key)
+}
+
+func (c *cachedClient) CompleteMultipartUpload(key, uploadID string, parts []Part) error {
+	defer c.cache.Invalidate(key)
+	return c.Client.CompleteMultipartUpload(key, uploadID, parts)
+}
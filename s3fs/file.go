@@ -0,0 +1,575 @@
+package s3fs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+var (
+	errStreamed        = errors.New("content already uploaded")
+	errReadNotAllowed  = errors.New("read not supported")
+	errWriteNotAllowed = errors.New("write not supported")
+	errNegativeOffset  = errors.New("negative offset")
+	errInvalidWhence   = errors.New("invalid whence")
+)
+
+// reader is a file opened for reading, read with ranged requests from its
+// offset, reusing the response while the reads are sequential.
+type reader struct {
+	billy.BaseFile
+	fs   *S3
+	key  string
+	fi   *fileInfo
+	pos  int64
+	body io.ReadCloser
+	// bodyPos is the offset of the next byte of body.
+	bodyPos int64
+}
+
+func newReader(fs *S3, key string, fi *fileInfo) *reader {
+	return &reader{
+		BaseFile: billy.BaseFile{BaseFilename: fs.rel(key)},
+		fs:       fs,
+		key:      key,
+		fi:       fi,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	if r.pos >= r.fi.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil || r.bodyPos != r.pos {
+		r.closeBody()
+		body, err := r.fs.c.Get(r.key, r.pos, -1)
+		if err != nil {
+			return 0, err
+		}
+
+		r.body, r.bodyPos = body, r.pos
+	}
+
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	r.bodyPos += int64(n)
+	if err == io.EOF {
+		r.closeBody()
+		if n != 0 {
+			err = nil
+		}
+	}
+
+	return n, err
+}
+
+func (r *reader) ReadAt(p []byte, off int64) (int, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: r.Filename(), Err: errNegativeOffset}
+	}
+
+	if off >= r.fi.size {
+		return 0, io.EOF
+	}
+
+	length := int64(len(p))
+	if off+length > r.fi.size {
+		length = r.fi.size - off
+	}
+
+	body, err := r.fs.c.Get(r.key, off, length)
+	if err != nil {
+		return 0, err
+	}
+
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:length])
+	if err == nil && int(length) < len(p) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	if r.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	pos, err := seek(r.pos, r.fi.size, offset, whence)
+	if err != nil {
+		return 0, &os.PathError{Op: "seek", Path: r.Filename(), Err: err}
+	}
+
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *reader) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: r.Filename(), Err: errWriteNotAllowed}
+}
+
+func (r *reader) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: r.Filename(), Err: errWriteNotAllowed}
+}
+
+func (r *reader) Stat() (billy.FileInfo, error) {
+	return r.fi, nil
+}
+
+func (r *reader) Sync() error {
+	return nil
+}
+
+// Lock returns billy.ErrNotSupported, object stores have no locks.
+func (r *reader) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, object stores have no locks.
+func (r *reader) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+func (r *reader) Close() error {
+	if r.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	r.Closed = true
+	r.closeBody()
+	return nil
+}
+
+func (r *reader) closeBody() {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+}
+
+// writer is a file opened for writing, holding its content until it's
+// uploaded on Close, or streamed in parts while it grows sequentially.
+type writer struct {
+	billy.BaseFile
+	fs   *S3
+	flag int
+
+	m     sync.Mutex
+	key   string
+	mode  os.FileMode
+	mtime time.Time
+	// content is the content not uploaded yet, from offset base, the one
+	// before is already uploaded in parts.
+	content []byte
+	base    int64
+	pos     int64
+	// upload is the id of the multipart upload, if started.
+	upload  string
+	parts   []Part
+	dirty   bool
+	removed bool
+}
+
+func newWriter(fs *S3, key string, fi *fileInfo, flag int) *writer {
+	w := &writer{
+		BaseFile: billy.BaseFile{BaseFilename: fs.rel(key)},
+		fs:       fs,
+		flag:     flag,
+		key:      key,
+		mode:     fi.mode.Perm(),
+		mtime:    fi.modTime,
+		dirty:    fi.modTime.IsZero() || flag&os.O_TRUNC != 0,
+	}
+
+	if w.dirty {
+		w.mtime = time.Now()
+	}
+
+	return w
+}
+
+// download reads the current content of the object.
+func (w *writer) download() error {
+	body, err := w.fs.c.Get(w.key, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	w.content, err = ioutil.ReadAll(body)
+	return err
+}
+
+func (w *writer) Read(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	n, err := w.readAt(p, w.pos)
+	w.pos += int64(n)
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (w *writer) ReadAt(p []byte, off int64) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: w.Filename(), Err: errNegativeOffset}
+	}
+
+	return w.readAt(p, off)
+}
+
+func (w *writer) readAt(p []byte, off int64) (int, error) {
+	if w.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	if w.flag&os.O_RDWR == 0 {
+		return 0, &os.PathError{Op: "read", Path: w.Filename(), Err: errReadNotAllowed}
+	}
+
+	if off < w.base {
+		return 0, &os.PathError{Op: "read", Path: w.Filename(), Err: errStreamed}
+	}
+
+	if off >= w.size() {
+		return 0, io.EOF
+	}
+
+	n := copy(p, w.content[off-w.base:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	if w.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &os.PathError{Op: "write", Path: w.Filename(), Err: errWriteNotAllowed}
+	}
+
+	if w.flag&os.O_APPEND != 0 {
+		w.pos = w.size()
+	}
+
+	if w.pos < w.base {
+		return 0, &os.PathError{Op: "write", Path: w.Filename(), Err: errStreamed}
+	}
+
+	end := w.pos - w.base + int64(len(p))
+	if end > int64(len(w.content)) {
+		w.resize(end)
+	}
+
+	n := copy(w.content[w.pos-w.base:], p)
+	w.pos += int64(n)
+	w.touch()
+
+	if w.pos == w.size() {
+		if err := w.stream(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// stream uploads the content in parts of Options.PartSize while there is
+// more than one part buffered, keeping the last one so it can be changed.
+func (w *writer) stream() error {
+	partSize := w.fs.opts.PartSize
+	for int64(len(w.content)) > 2*partSize {
+		if w.upload == "" {
+			id, err := w.fs.c.CreateMultipartUpload(w.key, metadata(w.mode, w.mtime))
+			if err != nil {
+				return err
+			}
+
+			w.upload = id
+		}
+
+		if err := w.uploadPart(w.content[:partSize]); err != nil {
+			return err
+		}
+
+		w.content = append([]byte(nil), w.content[partSize:]...)
+		w.base += partSize
+	}
+
+	return nil
+}
+
+func (w *writer) uploadPart(p []byte) error {
+	number := len(w.parts) + 1
+	etag, err := w.fs.c.UploadPart(w.key, w.upload, number, bytes.NewReader(p), int64(len(p)))
+	if err != nil {
+		return err
+	}
+
+	w.parts = append(w.parts, Part{Number: number, ETag: etag})
+	return nil
+}
+
+func (w *writer) Seek(offset int64, whence int) (int64, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	pos, err := seek(w.pos, w.size(), offset, whence)
+	if err != nil {
+		return 0, &os.PathError{Op: "seek", Path: w.Filename(), Err: err}
+	}
+
+	w.pos = pos
+	return pos, nil
+}
+
+func (w *writer) Truncate(size int64) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	if size < w.base {
+		return &os.PathError{Op: "truncate", Path: w.Filename(), Err: errStreamed}
+	}
+
+	w.resize(size - w.base)
+	w.touch()
+	return nil
+}
+
+// resize changes the size of the content not uploaded, growing it with
+// zeros.
+func (w *writer) resize(size int64) {
+	if size <= int64(len(w.content)) {
+		w.content = w.content[:size]
+		return
+	}
+
+	w.content = append(w.content, make([]byte, size-int64(len(w.content)))...)
+}
+
+func (w *writer) touch() {
+	w.dirty = true
+	w.mtime = time.Now()
+}
+
+func (w *writer) Stat() (billy.FileInfo, error) {
+	return w.info(), nil
+}
+
+// Sync uploads the content of the file, unless it's being streamed, then
+// the content is uploaded on Close.
+func (w *writer) Sync() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	if w.upload != "" || !w.dirty || w.removed {
+		return nil
+	}
+
+	if err := w.put(); err != nil {
+		return err
+	}
+
+	w.dirty = false
+	return nil
+}
+
+// Lock returns billy.ErrNotSupported, object stores have no locks.
+func (w *writer) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, object stores have no locks.
+func (w *writer) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+// Close uploads the content of the file, completing the multipart upload if
+// it was started.
+func (w *writer) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	w.Closed = true
+	defer w.fs.open.close(w)
+
+	switch {
+	case w.removed:
+		if w.upload != "" {
+			return w.fs.c.AbortMultipartUpload(w.key, w.upload)
+		}
+
+		return nil
+	case w.upload != "":
+		return w.complete()
+	case w.dirty:
+		return w.put()
+	}
+
+	return nil
+}
+
+func (w *writer) put() error {
+	return w.fs.c.Put(w.key, bytes.NewReader(w.content), int64(len(w.content)), metadata(w.mode, w.mtime))
+}
+
+func (w *writer) complete() error {
+	err := w.uploadPart(w.content)
+	if err == nil {
+		err = w.fs.c.CompleteMultipartUpload(w.key, w.upload, w.parts)
+	}
+
+	if err != nil {
+		w.fs.c.AbortMultipartUpload(w.key, w.upload)
+	}
+
+	return err
+}
+
+// snapshot returns a file opened for reading with the current content.
+func (w *writer) snapshot(filename string) (billy.File, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.base != 0 {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errStreamed}
+	}
+
+	return &snapshot{
+		BaseFile: billy.BaseFile{BaseFilename: filename},
+		Reader:   bytes.NewReader(append([]byte(nil), w.content...)),
+		fi:       w.infoLocked(),
+	}, nil
+}
+
+func (w *writer) info() *fileInfo {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	return w.infoLocked()
+}
+
+func (w *writer) infoLocked() *fileInfo {
+	return &fileInfo{
+		name:    path.Base(w.key),
+		size:    w.size(),
+		mode:    w.mode,
+		modTime: w.mtime,
+	}
+}
+
+// size returns the size of the file, the caller must hold the lock.
+func (w *writer) size() int64 {
+	return w.base + int64(len(w.content))
+}
+
+func (w *writer) setMeta(mode os.FileMode, mtime time.Time) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.mode, w.mtime, w.dirty = mode, mtime, true
+}
+
+func (w *writer) rename(key string) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.key = key
+}
+
+// snapshot is a file opened for reading while it's open for writing.
+type snapshot struct {
+	billy.BaseFile
+	*bytes.Reader
+	fi *fileInfo
+}
+
+func (s *snapshot) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: s.Filename(), Err: errWriteNotAllowed}
+}
+
+func (s *snapshot) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: s.Filename(), Err: errWriteNotAllowed}
+}
+
+func (s *snapshot) Stat() (billy.FileInfo, error) { return s.fi, nil }
+func (s *snapshot) Sync() error                   { return nil }
+func (s *snapshot) Lock() error                   { return billy.ErrNotSupported }
+func (s *snapshot) Unlock() error                 { return billy.ErrNotSupported }
+
+func (s *snapshot) Close() error {
+	if s.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	s.Closed = true
+	return nil
+}
+
+// seek returns the offset resulting of seeking a file of the given size from
+// pos.
+func seek(pos, size, offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pos
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, errInvalidWhence
+	}
+
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+
+	return offset, nil
+}
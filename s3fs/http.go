@@ -0,0 +1,561 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1/credentials"
+	"srcd.works/go-billy.v1/internal/throttle"
+)
+
+const (
+	metaHeader      = "X-Amz-Meta-"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+)
+
+// HTTPClient is a Client using the S3 REST API, with path-style URLs and
+// requests signed with AWS Signature Version 4. The payloads aren't signed,
+// so the content is streamed, the endpoint should use HTTPS. The requests
+// throttled by the service are retried with an adaptive backoff, reducing
+// the requests in flight, as done by internal/throttle.
+type HTTPClient struct {
+	// Endpoint is the URL of the service, such as https://s3.amazonaws.com.
+	Endpoint string
+	// Region is the region of the bucket, us-east-1 if empty.
+	Region string
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Credentials gives the access key id as Username, the secret access
+	// key as Password and the session token, if any, as Token. The requests
+	// are anonymous if it's nil.
+	Credentials credentials.Provider
+	// Client is the HTTP client used, http.DefaultClient if nil.
+	Client *http.Client
+
+	once    sync.Once
+	limiter *throttle.Limiter
+}
+
+// NewHTTPClient returns a new HTTPClient for the given bucket.
+func NewHTTPClient(endpoint, region, bucket string, creds credentials.Provider) *HTTPClient {
+	return &HTTPClient{
+		Endpoint:    strings.TrimSuffix(endpoint, "/"),
+		Region:      region,
+		Bucket:      bucket,
+		Credentials: creds,
+	}
+}
+
+// Error is an error response of the service. It's recognized by the
+// throttling of the backends through its StatusCode and Code methods.
+type Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *Error) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("s3: %s (%d)", e.code, e.status)
+	}
+
+	return fmt.Sprintf("s3: %s: %s (%d)", e.code, e.message, e.status)
+}
+
+// StatusCode returns the HTTP status code of the response.
+func (e *Error) StatusCode() int {
+	return e.status
+}
+
+// Code returns the S3 error code, such as NoSuchKey or SlowDown, or the
+// HTTP status text if the response had no body.
+func (e *Error) Code() string {
+	return e.code
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// Head returns the object with the given key, with its metadata.
+func (c *HTTPClient) Head(key string) (*Object, error) {
+	res, err := c.do("HEAD", key, nil, nil, nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body.Close()
+	o := &Object{Key: key, Size: res.ContentLength}
+	o.LastModified, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	for name := range res.Header {
+		if strings.HasPrefix(name, metaHeader) {
+			if o.Meta == nil {
+				o.Meta = make(map[string]string)
+			}
+
+			o.Meta[strings.ToLower(name[len(metaHeader):])] = res.Header.Get(name)
+		}
+	}
+
+	return o, nil
+}
+
+// Get returns the content of the object with the given key with a ranged
+// request.
+func (c *HTTPClient) Get(key string, off, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	h := make(http.Header)
+	switch {
+	case length > 0:
+		h.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	case off > 0:
+		h.Set("Range", fmt.Sprintf("bytes=%d-", off))
+	}
+
+	res, err := c.do("GET", key, nil, h, nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// Put uploads the object with the given key.
+func (c *HTTPClient) Put(key string, r io.Reader, size int64, meta map[string]string) error {
+	return c.discard(c.do("PUT", key, nil, metaHeaders(meta), r, size))
+}
+
+// Copy copies the object src to dst with a server-side copy, replacing the
+// metadata if meta isn't nil.
+func (c *HTTPClient) Copy(dst, src string, meta map[string]string) error {
+	h := metaHeaders(meta)
+	h.Set("X-Amz-Copy-Source", "/"+c.Bucket+"/"+escape(src, false))
+	h.Set("X-Amz-Metadata-Directive", "COPY")
+	if meta != nil {
+		h.Set("X-Amz-Metadata-Directive", "REPLACE")
+	}
+
+	res, err := c.do("PUT", dst, nil, h, nil, -1)
+	if err != nil {
+		return err
+	}
+
+	// the copies may fail after the 200 OK, reporting the error in the body.
+	return decode(res, &struct {
+		XMLName xml.Name `xml:"CopyObjectResult"`
+	}{})
+}
+
+// Delete deletes the object with the given key.
+func (c *HTTPClient) Delete(key string) error {
+	err := c.discard(c.do("DELETE", key, nil, nil, nil, -1))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+type listResponse struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List lists the objects with ListObjectsV2, following the continuation
+// tokens until max entries are returned.
+func (c *HTTPClient) List(prefix, delimiter string, max int) ([]Object, []string, error) {
+	var objects []Object
+	var prefixes []string
+	q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		q.Set("delimiter", delimiter)
+	}
+
+	for {
+		if max > 0 {
+			q.Set("max-keys", strconv.Itoa(max-len(objects)-len(prefixes)))
+		}
+
+		res, err := c.do("GET", "", q, nil, nil, -1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var l listResponse
+		if err := decode(res, &l); err != nil {
+			return nil, nil, err
+		}
+
+		for _, o := range l.Contents {
+			objects = append(objects, Object{Key: o.Key, Size: o.Size, LastModified: o.LastModified})
+		}
+
+		for _, p := range l.CommonPrefixes {
+			prefixes = append(prefixes, p.Prefix)
+		}
+
+		if !l.IsTruncated || (max > 0 && len(objects)+len(prefixes) >= max) {
+			return objects, prefixes, nil
+		}
+
+		q.Set("continuation-token", l.NextContinuationToken)
+	}
+}
+
+// CreateMultipartUpload starts a multipart upload.
+func (c *HTTPClient) CreateMultipartUpload(key string, meta map[string]string) (string, error) {
+	res, err := c.do("POST", key, url.Values{"uploads": {""}}, metaHeaders(meta), nil, -1)
+	if err != nil {
+		return "", err
+	}
+
+	var r struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	if err := decode(res, &r); err != nil {
+		return "", err
+	}
+
+	return r.UploadID, nil
+}
+
+// UploadPart uploads a part of a multipart upload.
+func (c *HTTPClient) UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	q := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	res, err := c.do("PUT", key, q, nil, r, size)
+	if err != nil {
+		return "", err
+	}
+
+	res.Body.Close()
+	return res.Header.Get("ETag"), nil
+}
+
+type completeRequest struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+type completePart struct {
+	PartNumber int
+	ETag       string
+}
+
+// CompleteMultipartUpload completes a multipart upload.
+func (c *HTTPClient) CompleteMultipartUpload(key, uploadID string, parts []Part) error {
+	var req completeRequest
+	for _, p := range parts {
+		req.Parts = append(req.Parts, completePart{p.Number, p.ETag})
+	}
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{"uploadId": {uploadID}}
+	res, err := c.do("POST", key, q, nil, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+
+	// as the copies, the completions may fail after the 200 OK.
+	return decode(res, &struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	}{})
+}
+
+// AbortMultipartUpload aborts a multipart upload.
+func (c *HTTPClient) AbortMultipartUpload(key, uploadID string) error {
+	return c.discard(c.do("DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil, -1))
+}
+
+// do sends a signed request on the given key, or on the bucket if key is
+// empty, returning an error for the responses other than 2xx. The errors for
+// missing keys are os.ErrNotExist. The throttled requests are retried, the
+// ones with a body only if it's an io.Seeker, rewound to where it started.
+func (c *HTTPClient) do(method, key string, q url.Values, h http.Header, body io.Reader, size int64) (*http.Response, error) {
+	c.once.Do(func() {
+		if c.limiter == nil {
+			c.limiter = throttle.New(nil)
+		}
+	})
+
+	var start int64
+	seeker, rewind := body.(io.Seeker)
+	if rewind {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+
+	var res *http.Response
+	var attempts int
+	err := c.limiter.Do(context.Background(), func() error {
+		if attempts++; attempts > 1 && rewind {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		res, err = c.send(method, key, q, h, body, size)
+		if err != nil && body != nil && !rewind {
+			return &notRetried{err}
+		}
+
+		return err
+	})
+
+	if e, ok := err.(*notRetried); ok {
+		err = e.err
+	}
+
+	return res, err
+}
+
+// notRetried wraps the error of a request that can't be retried, hiding it
+// from the limiter.
+type notRetried struct {
+	err error
+}
+
+func (e *notRetried) Error() string {
+	return e.err.Error()
+}
+
+func (c *HTTPClient) send(method, key string, q url.Values, h http.Header, body io.Reader, size int64) (*http.Response, error) {
+	p := "/" + c.Bucket
+	if key != "" {
+		p += "/" + escape(key, false)
+	}
+
+	u := c.Endpoint + p
+	if len(q) != 0 {
+		u += "?" + canonicalQuery(q)
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, v := range h {
+		req.Header[name] = v
+	}
+
+	if size >= 0 {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = nil
+		}
+	}
+
+	if err := c.sign(req, p, q, time.Now()); err != nil {
+		return nil, err
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode/100 == 2 {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+	e := &Error{status: res.StatusCode, code: http.StatusText(res.StatusCode)}
+	var r errorResponse
+	if err := xml.NewDecoder(res.Body).Decode(&r); err == nil {
+		e.code, e.message = r.Code, r.Message
+	}
+
+	if res.StatusCode == http.StatusNotFound && e.code != "NoSuchBucket" {
+		return nil, &os.PathError{Op: strings.ToLower(method), Path: key, Err: os.ErrNotExist}
+	}
+
+	return nil, e
+}
+
+func (c *HTTPClient) discard(res *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	io.Copy(ioutil.Discard, res.Body)
+	return res.Body.Close()
+}
+
+// decode decodes the XML body of res into v, or returns the error reported
+// by it.
+func decode(res *http.Response, v interface{}) error {
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var r errorResponse
+	if xml.Unmarshal(data, &r) == nil {
+		return &Error{status: res.StatusCode, code: r.Code, message: r.Message}
+	}
+
+	return xml.Unmarshal(data, v)
+}
+
+// sign signs req, with the escaped path p and the query q, with AWS Signature
+// Version 4 on the host and the x-amz-* headers, leaving the payload unsigned.
+func (c *HTTPClient) sign(req *http.Request, p string, q url.Values, now time.Time) error {
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	if c.Credentials == nil {
+		return nil
+	}
+
+	creds, err := c.Credentials.Retrieve(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now = now.UTC()
+	date := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", date)
+	if creds.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if l := strings.ToLower(name); strings.HasPrefix(l, "x-amz-") {
+			headers[l] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var canonical bytes.Buffer
+	fmt.Fprintf(&canonical, "%s\n%s\n%s\n", req.Method, p, canonicalQuery(q))
+	for _, name := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", name, headers[name])
+	}
+
+	signed := strings.Join(names, ";")
+	fmt.Fprintf(&canonical, "\n%s\n%s", signed, unsignedPayload)
+
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hexSHA256(canonical.Bytes())
+
+	key := []byte("AWS4" + creds.Password)
+	for _, s := range []string{now.Format("20060102"), region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.Username, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign)),
+	))
+
+	return nil
+}
+
+func metaHeaders(meta map[string]string) http.Header {
+	h := make(http.Header)
+	for k, v := range meta {
+		h.Set(metaHeader+k, v)
+	}
+
+	return h
+}
+
+// canonicalQuery returns the query string sorted by key, with the encoding
+// required by the signatures.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// escape percent-encodes s as required by the signatures, every byte but the
+// unreserved characters, and the slashes unless encodeSlash is true.
+func escape(s string, encodeSlash bool) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+
+	return b.String()
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
@@ -0,0 +1,299 @@
+package s3fs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1/credentials"
+	"srcd.works/go-billy.v1/internal/throttle"
+	"srcd.works/go-billy.v1/test"
+)
+
+type HTTPSuite struct {
+	test.FilesystemSuite
+	client *fakeClient
+	server *httptest.Server
+}
+
+var _ = Suite(&HTTPSuite{})
+
+// SetUpTest starts a fake S3 service, storing the objects of the bucket
+// "bucket" in a fakeClient.
+func (s *HTTPSuite) SetUpTest(c *C) {
+	s.client = newFakeClient()
+	s.server = httptest.NewServer(&fakeService{bucket: "bucket", c: s.client})
+
+	s.FilesystemSuite.Fs = New(s.newClient(), "base", nil)
+}
+
+func (s *HTTPSuite) newClient() *HTTPClient {
+	creds := credentials.Static(credentials.Credentials{Username: "key", Password: "secret", Token: "token"})
+	return NewHTTPClient(s.server.URL, "", "bucket", creds)
+}
+
+func (s *HTTPSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *HTTPSuite) TestObjects(c *C) {
+	fs := New(s.newClient(), "base", &Options{PartSize: 8})
+	f, err := fs.Create("qux/foo bar")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.client.uploaded, Equals, 4)
+	c.Assert(s.client.content("base/qux/foo bar"), Equals, "0123456789abcdefghijklmnopqrstuvwxyz")
+
+	c.Assert(fs.Chmod("qux/foo bar", 0600), IsNil)
+	o, err := s.client.Head("base/qux/foo bar")
+	c.Assert(err, IsNil)
+	c.Assert(o.Meta[modeMeta], Equals, "600")
+}
+
+func (s *HTTPSuite) TestThrottle(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>")
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL, "", "bucket", nil)
+	client.limiter = throttle.New(&throttle.Options{Attempts: 3, MinBackoff: time.Millisecond})
+	_, err := client.Get("foo", 0, -1)
+	c.Assert(err, ErrorMatches, `s3: SlowDown: Please reduce your request rate. \(503\)`)
+	c.Assert(throttle.IsThrottle(err), Equals, true)
+	c.Assert(client.limiter.Stats().Requests, Equals, uint64(3))
+}
+
+func (s *HTTPSuite) TestThrottleRetry(c *C) {
+	var requests int
+	service := &fakeService{bucket: "bucket", c: s.client}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 {
+			ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "<Error><Code>SlowDown</Code></Error>")
+			return
+		}
+
+		service.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client := s.newClient()
+	client.Endpoint = server.URL
+	client.limiter = throttle.New(&throttle.Options{MinBackoff: time.Millisecond})
+	c.Assert(client.Put("foo", strings.NewReader("foo"), 3, nil), IsNil)
+	c.Assert(requests, Equals, 2)
+	c.Assert(s.client.content("foo"), Equals, "foo")
+	c.Assert(client.limiter.Stats().Throttled, Equals, uint64(1))
+}
+
+// fakeService serves the subset of the S3 REST API used by HTTPClient,
+// checking the requests are signed.
+type fakeService struct {
+	bucket string
+	c      *fakeClient
+}
+
+func (s *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Security-Token") != "token" {
+		s.error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	if r.URL.Path == "/"+s.bucket {
+		s.list(w, r)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+s.bucket+"/")
+	q := r.URL.Query()
+	var err error
+	switch {
+	case r.Method == "HEAD":
+		err = s.head(w, key)
+	case r.Method == "GET":
+		err = s.get(w, r, key)
+	case r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "":
+		err = s.copy(w, r, key)
+	case r.Method == "PUT" && q.Get("uploadId") != "":
+		var etag string
+		number, _ := strconv.Atoi(q.Get("partNumber"))
+		etag, err = s.c.UploadPart(key, q.Get("uploadId"), number, r.Body, r.ContentLength)
+		w.Header().Set("ETag", etag)
+	case r.Method == "PUT":
+		err = s.c.Put(key, r.Body, r.ContentLength, meta(r.Header))
+	case r.Method == "POST" && q.Get("uploadId") != "":
+		err = s.complete(w, r, key)
+	case r.Method == "POST":
+		var id string
+		id, err = s.c.CreateMultipartUpload(key, meta(r.Header))
+		s.xml(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			UploadID string   `xml:"UploadId"`
+		}{UploadID: id})
+	case r.Method == "DELETE" && q.Get("uploadId") != "":
+		err = s.c.AbortMultipartUpload(key, q.Get("uploadId"))
+	case r.Method == "DELETE":
+		err = s.c.Delete(key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	switch {
+	case os.IsNotExist(err):
+		s.error(w, http.StatusNotFound, "NoSuchKey")
+	case err != nil:
+		s.error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *fakeService) head(w http.ResponseWriter, key string) error {
+	o, err := s.c.Head(key)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range o.Meta {
+		w.Header().Set(metaHeader+k, v)
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(o.Size, 10))
+	w.Header().Set("Last-Modified", o.LastModified.UTC().Format(http.TimeFormat))
+	return nil
+}
+
+func (s *fakeService) get(w http.ResponseWriter, r *http.Request, key string) error {
+	var off, end int64 = 0, -1
+	if rng := r.Header.Get("Range"); rng != "" {
+		fmt.Sscanf(rng, "bytes=%d-%d", &off, &end)
+	}
+
+	length := int64(-1)
+	if end >= 0 {
+		length = end - off + 1
+	}
+
+	body, err := s.c.Get(key, off, length)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func (s *fakeService) copy(w http.ResponseWriter, r *http.Request, key string) error {
+	src, err := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"+s.bucket+"/"))
+	if err != nil {
+		return err
+	}
+
+	var m map[string]string
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		m = meta(r.Header)
+	}
+
+	if err := s.c.Copy(key, src, m); err != nil {
+		return err
+	}
+
+	s.xml(w, struct {
+		XMLName xml.Name `xml:"CopyObjectResult"`
+	}{})
+	return nil
+}
+
+func (s *fakeService) complete(w http.ResponseWriter, r *http.Request, key string) error {
+	var req completeRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	var parts []Part
+	for _, p := range req.Parts {
+		parts = append(parts, Part{Number: p.PartNumber, ETag: p.ETag})
+	}
+
+	if err := s.c.CompleteMultipartUpload(key, r.URL.Query().Get("uploadId"), parts); err != nil {
+		return err
+	}
+
+	s.xml(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	}{})
+	return nil
+}
+
+type listResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified string
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+func (s *fakeService) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	max, _ := strconv.Atoi(q.Get("max-keys"))
+	objects, prefixes, err := s.c.List(q.Get("prefix"), q.Get("delimiter"), max)
+	if err != nil {
+		s.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var l listResult
+	for _, o := range objects {
+		l.Contents = append(l.Contents, struct {
+			Key          string
+			Size         int64
+			LastModified string
+		}{o.Key, o.Size, o.LastModified.UTC().Format("2006-01-02T15:04:05.000Z")})
+	}
+
+	for _, p := range prefixes {
+		l.CommonPrefixes = append(l.CommonPrefixes, struct{ Prefix string }{p})
+	}
+
+	s.xml(w, l)
+}
+
+func (s *fakeService) xml(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (s *fakeService) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	s.xml(w, errorResponse{Code: code})
+}
+
+func meta(h http.Header) map[string]string {
+	m := make(map[string]string)
+	for name := range h {
+		if strings.HasPrefix(name, metaHeader) {
+			m[strings.ToLower(name[len(metaHeader):])] = h.Get(name)
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,63 @@
+package s3fs
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/credentials"
+)
+
+func init() {
+	billy.Register("s3", open)
+}
+
+// open returns the filesystem addressed by an s3 URI, such as
+// s3://bucket/prefix. The options are read from the query string: endpoint,
+// the URL of the service, by default the AWS one of the region, region,
+// part-size, in bytes, implicit-dirs, as a boolean, and list-ttl, as a
+// duration. The credentials are read from the variables S3_USERNAME,
+// S3_PASSWORD and S3_TOKEN.
+func open(u *url.URL) (billy.Filesystem, error) {
+	q := u.Query()
+	region := q.Get("region")
+	endpoint := q.Get("endpoint")
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+		if region != "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+	}
+
+	var opts Options
+	if v := q.Get("part-size"); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.PartSize = size
+	}
+
+	if v := q.Get("implicit-dirs"); v != "" {
+		implicit, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.ImplicitDirs = implicit
+	}
+
+	if v := q.Get("list-ttl"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.ListTTL = ttl
+	}
+
+	c := NewHTTPClient(endpoint, region, u.Host, credentials.Env("S3"))
+	return New(c, u.Path, &opts), nil
+}
@@ -0,0 +1,819 @@
+// Package s3fs provides a billy filesystem over the S3-compatible object
+// stores, such as AWS S3 or MinIO. Object stores have no directories, they
+// are emulated with the common prefixes of the keys and, unless disabled,
+// with marker objects, as done by internal/dirmarker. The modes and the
+// modification times of the files are kept as user metadata of the objects.
+package s3fs // import "srcd.works/go-billy.v1/s3fs"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/dirmarker"
+	"srcd.works/go-billy.v1/internal/listcache"
+)
+
+const (
+	// modeMeta and mtimeMeta are the keys of the user metadata holding the
+	// permissions, in octal, and the modification time, in RFC 3339.
+	modeMeta  = "mode"
+	mtimeMeta = "mtime"
+
+	defaultFileMode = 0644
+	defaultDirMode  = 0755
+)
+
+var (
+	errIsDirectory  = errors.New("is a directory")
+	errNotDirectory = errors.New("not a directory")
+	errNotEmpty     = errors.New("directory not empty")
+)
+
+// Options holds the configuration of an S3 filesystem.
+type Options struct {
+	// PartSize is the size of the parts of the multipart uploads, the files
+	// written sequentially are streamed in parts once they grow past twice
+	// it. S3 requires at least 5MiB, 8MiB by default.
+	PartSize int64
+	// ImplicitDirs represents the directories only as the common prefixes
+	// of the keys, without marker objects, so the empty directories don't
+	// exist and the directories have no mode nor modification time.
+	ImplicitDirs bool
+	// ListTTL is the time the listings of the directories are kept, reused
+	// by ReadDir, and by Stat to tell the missing files without requests.
+	// The changes done by other clients are seen once they expire. One
+	// minute by default.
+	ListTTL time.Duration
+}
+
+var defaultOptions = Options{
+	PartSize: 8 << 20,
+}
+
+// S3 is a filesystem over a bucket of an S3-compatible object store, the
+// paths are relative to a prefix of the keys.
+//
+// The files opened for writing are uploaded on Close, and seen by the
+// filesystem, but not by other clients, until then. The ones written
+// sequentially past twice Options.PartSize are streamed with a multipart upload,
+// after that the content already uploaded can't be read nor changed through
+// the file. Rename is emulated with a copy and a delete of every object, so
+// renaming directories isn't atomic. ReadDir reports the time of the last
+// upload and the default mode of the files, since the listings don't include
+// the metadata, Stat reports the ones set.
+type S3 struct {
+	c      Client
+	base   string
+	opts   Options
+	policy dirmarker.Policy
+	open   *openFiles
+	cache  *listcache.Cache
+}
+
+// New returns a new S3 filesystem using the given client, rooted at the
+// prefix base of the keys, "" being the whole bucket. If opts is nil the
+// default options are used, as for their zero fields.
+func New(c Client, base string, opts *Options) *S3 {
+	o := defaultOptions
+	if opts != nil {
+		if opts.PartSize > 0 {
+			o.PartSize = opts.PartSize
+		}
+
+		o.ImplicitDirs = opts.ImplicitDirs
+		o.ListTTL = opts.ListTTL
+	}
+
+	policy := dirmarker.Marker
+	if o.ImplicitDirs {
+		policy = dirmarker.Implicit
+	}
+
+	cache := listcache.New(&listcache.Options{TTL: o.ListTTL})
+	return &S3{
+		c:      &cachedClient{Client: c, cache: cache},
+		base:   clean(base),
+		opts:   o,
+		policy: policy,
+		open:   &openFiles{files: make(map[string]*writer)},
+		cache:  cache,
+	}
+}
+
+// Create creates a file and opens it with standard permissions
+// and modes O_RDWR, O_CREATE and O_TRUNC.
+func (fs *S3) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens a file in read-only mode.
+func (fs *S3) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, if flag os.O_CREATE is set the markers of
+// its parent directories are created. The files opened for reading are read
+// with ranged requests, the ones opened for writing without os.O_TRUNC are
+// downloaded.
+func (fs *S3) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	key, err := fs.key(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.stat(key)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	exists := err == nil
+	if exists && fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDirectory}
+	}
+
+	if !isWrite(flag) {
+		if !exists {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+		}
+
+		if w := fs.open.get(key); w != nil {
+			return w.snapshot(fs.rel(key))
+		}
+
+		return newReader(fs, key, fi), nil
+	}
+
+	switch {
+	case exists && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	case !exists:
+		if err := fs.mkdirAll(path.Dir(key), defaultDirMode); err != nil {
+			return nil, err
+		}
+
+		fi = &fileInfo{name: path.Base(key), mode: perm.Perm()}
+	}
+
+	w := newWriter(fs, key, fi, flag)
+	if exists && flag&os.O_TRUNC == 0 {
+		if err := w.download(); err != nil {
+			return nil, err
+		}
+	}
+
+	fs.open.add(w)
+	return w, nil
+}
+
+// Stat returns the FileInfo of the named file or directory.
+func (fs *S3) Stat(filename string) (billy.FileInfo, error) {
+	key, err := fs.key(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.stat(key)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: filename, Err: err}
+	}
+
+	return fi, nil
+}
+
+// Lstat returns the FileInfo of the named file, as Stat, since there are no
+// symbolic links.
+func (fs *S3) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.Stat(filename)
+}
+
+// stat returns the FileInfo of key: a file open for writing, an object, a
+// directory marker or a common prefix, in that order. The errors for a missing
+// key are os.ErrNotExist, the ones missing from the cached listing of their
+// directory are told without requests.
+func (fs *S3) stat(key string) (*fileInfo, error) {
+	if key == fs.base {
+		return &fileInfo{name: "/", mode: os.ModeDir | defaultDirMode}, nil
+	}
+
+	if w := fs.open.get(key); w != nil {
+		return w.info(), nil
+	}
+
+	e, exists, cached := fs.cache.Lookup(key)
+	if cached && !exists && !fs.open.under(key) {
+		return nil, os.ErrNotExist
+	}
+
+	o, err := fs.c.Head(key)
+	if err == nil {
+		return objectInfo(o), nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	o, err = fs.c.Head(dirmarker.Key(key))
+	if err == nil {
+		fi := objectInfo(o)
+		fi.name = path.Base(key)
+		fi.mode = os.ModeDir | fi.mode
+		return fi, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if cached && e.Dir {
+		return &fileInfo{name: path.Base(key), mode: os.ModeDir | defaultDirMode}, nil
+	}
+
+	objects, prefixes, err := fs.c.List(dirmarker.Key(key), "/", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objects) != 0 || len(prefixes) != 0 || fs.open.under(key) {
+		return &fileInfo{name: path.Base(key), mode: os.ModeDir | defaultDirMode}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// ReadDir returns the entries of the given directory, sorted by name. The
+// listing is cached, along with the files open for writing.
+func (fs *S3) ReadDir(dir string) ([]billy.FileInfo, error) {
+	key, err := fs.key(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := dirmarker.Key(key)
+	if key == "" {
+		prefix = ""
+	}
+
+	entries, err := fs.list(key, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// the files open for writing, and the directories implied by them.
+	var l []dirmarker.Object
+	var prefixes []string
+	for _, fi := range fs.open.list(prefix) {
+		name := strings.TrimPrefix(fi.key, prefix)
+		if i := strings.Index(name, "/"); i != -1 {
+			prefixes = append(prefixes, prefix+name[:i+1])
+			continue
+		}
+
+		l = append(l, dirmarker.Object{Key: fi.key, Size: fi.size, ModTime: fi.modTime})
+	}
+
+	if len(l) != 0 || len(prefixes) != 0 {
+		entries = merge(entries, dirmarker.Listing(key, l, prefixes))
+	}
+
+	if len(entries) == 0 && key != fs.base {
+		fi, err := fs.stat(key)
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: dir, Err: err}
+		}
+
+		if !fi.IsDir() {
+			return nil, &os.PathError{Op: "readdir", Path: dir, Err: errNotDirectory}
+		}
+	}
+
+	infos := make([]billy.FileInfo, len(entries))
+	for i, e := range entries {
+		fi := &fileInfo{name: e.Name, size: e.Size, mode: defaultFileMode, modTime: e.ModTime}
+		if e.Dir {
+			fi.mode = os.ModeDir | defaultDirMode
+		}
+
+		infos[i] = fi
+	}
+
+	return infos, nil
+}
+
+// list returns the entries of the directory key, listing the objects under
+// prefix, unless its listing is cached.
+func (fs *S3) list(key, prefix string) ([]listcache.Entry, error) {
+	if l, ok := fs.cache.List(key); ok {
+		return l, nil
+	}
+
+	objects, prefixes, err := fs.c.List(prefix, "/", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]dirmarker.Object, len(objects))
+	for i, o := range objects {
+		l[i] = dirmarker.Object{Key: o.Key, Size: o.Size, ModTime: o.LastModified}
+	}
+
+	entries := dirmarker.Listing(key, l, prefixes)
+	fs.cache.Put(key, entries)
+	return entries, nil
+}
+
+// merge returns the entries of l, sorted by name, with the ones of open,
+// the files open for writing and their directories, in place of the ones
+// with the same name, but for the directories.
+func merge(l, open []listcache.Entry) []listcache.Entry {
+	entries := make(map[string]listcache.Entry, len(l)+len(open))
+	for _, e := range l {
+		entries[e.Name] = e
+	}
+
+	for _, e := range open {
+		if prev, ok := entries[e.Name]; ok && prev.Dir && !e.Dir {
+			continue
+		}
+
+		entries[e.Name] = e
+	}
+
+	merged := make([]listcache.Entry, 0, len(entries))
+	for _, e := range entries {
+		merged = append(merged, e)
+	}
+
+	sort.Sort(byEntryName(merged))
+	return merged
+}
+
+type byEntryName []listcache.Entry
+
+func (l byEntryName) Len() int           { return len(l) }
+func (l byEntryName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l byEntryName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// TempFile creates a new temporal file, with a random name starting with
+// prefix, in dir.
+func (fs *S3) TempFile(dir, prefix string) (billy.File, error) {
+	for i := 0; i < 10000; i++ {
+		name := fs.Join(dir, prefix+strconv.FormatInt(rand.Int63(), 10))
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+
+		return f, err
+	}
+
+	return nil, fmt.Errorf("temp file in %s: too many attempts", dir)
+}
+
+// Rename moves a file or a directory from _from_ to _to_, copying and
+// deleting every object. The files open for writing are moved with it.
+func (fs *S3) Rename(from, to string) error {
+	fkey, err := fs.key(from)
+	if err != nil {
+		return err
+	}
+
+	tkey, err := fs.key(to)
+	if err != nil {
+		return err
+	}
+
+	fi, err := fs.stat(fkey)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+	}
+
+	if fkey == tkey {
+		return nil
+	}
+
+	if err := fs.mkdirAll(path.Dir(tkey), defaultDirMode); err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		if err := fs.move(fkey, tkey); err != nil {
+			return err
+		}
+	} else {
+		objects, _, err := fs.c.List(dirmarker.Key(fkey), "", 0)
+		if err != nil {
+			return err
+		}
+
+		for _, o := range objects {
+			if err := fs.move(o.Key, tkey+strings.TrimPrefix(o.Key, fkey)); err != nil {
+				return err
+			}
+		}
+
+		fs.open.rename(dirmarker.Key(fkey), dirmarker.Key(tkey))
+	}
+
+	return fs.keepDir(path.Dir(fkey))
+}
+
+// move moves the object src to dst, along with the file open for writing
+// with its key.
+func (fs *S3) move(src, dst string) error {
+	if fs.open.rename(src, dst) {
+		// not uploaded yet, or uploaded and about to be replaced.
+		if _, err := fs.c.Head(src); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	if err := fs.c.Copy(dst, src, nil); err != nil {
+		return err
+	}
+
+	return fs.c.Delete(src)
+}
+
+// Remove removes a file or an empty directory.
+func (fs *S3) Remove(filename string) error {
+	key, err := fs.key(filename)
+	if err != nil {
+		return err
+	}
+
+	fi, err := fs.stat(key)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: filename, Err: err}
+	}
+
+	if !fi.IsDir() {
+		fs.open.remove(key)
+		if err := fs.c.Delete(key); err != nil {
+			return err
+		}
+
+		return fs.keepDir(path.Dir(key))
+	}
+
+	if key == fs.base {
+		return &os.PathError{Op: "remove", Path: filename, Err: errNotEmpty}
+	}
+
+	objects, _, err := fs.c.List(dirmarker.Key(key), "", 2)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objects {
+		if o.Key != dirmarker.Key(key) {
+			return &os.PathError{Op: "remove", Path: filename, Err: errNotEmpty}
+		}
+	}
+
+	if fs.open.under(key) {
+		return &os.PathError{Op: "remove", Path: filename, Err: errNotEmpty}
+	}
+
+	for _, k := range dirmarker.Rmdir(key) {
+		if err := fs.c.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return fs.keepDir(path.Dir(key))
+}
+
+// keepDir creates the marker of dir if the policy requires one after
+// removing its last entry, so it keeps existing.
+func (fs *S3) keepDir(dir string) error {
+	if dir == fs.base || dir == "." {
+		return nil
+	}
+
+	for _, k := range fs.policy.Remove(dir) {
+		if _, err := fs.c.Head(k); !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := fs.putMarker(k, defaultDirMode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Symlink returns billy.ErrNotSupported, object stores have no symbolic
+// links.
+func (fs *S3) Symlink(target, link string) error {
+	return billy.ErrNotSupported
+}
+
+// Readlink returns billy.ErrNotSupported, object stores have no symbolic
+// links.
+func (fs *S3) Readlink(link string) (string, error) {
+	return "", billy.ErrNotSupported
+}
+
+// MkdirAll creates the markers of the directory path and all its parents,
+// with the given permissions. With Options.ImplicitDirs it does nothing.
+func (fs *S3) MkdirAll(path string, perm os.FileMode) error {
+	key, err := fs.key(path)
+	if err != nil {
+		return err
+	}
+
+	return fs.mkdirAll(key, perm)
+}
+
+func (fs *S3) mkdirAll(key string, perm os.FileMode) error {
+	if key == fs.base || key == "." {
+		return nil
+	}
+
+	for _, k := range fs.policy.MkdirAll(key) {
+		dir := strings.TrimSuffix(k, "/")
+		if !strings.HasPrefix(k, fs.base) || len(dir) <= len(fs.base) {
+			continue
+		}
+
+		if _, err := fs.c.Head(dir); err == nil {
+			return &os.PathError{Op: "mkdir", Path: fs.rel(dir), Err: errNotDirectory}
+		}
+
+		if _, err := fs.c.Head(k); !os.IsNotExist(err) {
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := fs.putMarker(k, perm); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fs.c.Head(key); err == nil {
+		return &os.PathError{Op: "mkdir", Path: fs.rel(key), Err: errNotDirectory}
+	}
+
+	return nil
+}
+
+func (fs *S3) putMarker(key string, perm os.FileMode) error {
+	return fs.c.Put(key, bytes.NewReader(nil), 0, metadata(perm, time.Now()))
+}
+
+// Chmod changes the mode of the named file, replacing the metadata of its
+// object or marker. The directories without marker can't be changed.
+func (fs *S3) Chmod(name string, mode os.FileMode) error {
+	return fs.setMeta("chmod", name, func(fi *fileInfo) {
+		fi.mode = fi.mode&os.ModeDir | mode.Perm()
+	})
+}
+
+// Chtimes changes the modification time of the named file, replacing the
+// metadata of its object or marker, atime is ignored. The directories
+// without marker can't be changed.
+func (fs *S3) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.setMeta("chtimes", name, func(fi *fileInfo) {
+		fi.modTime = mtime
+	})
+}
+
+func (fs *S3) setMeta(op, name string, fn func(*fileInfo)) error {
+	key, err := fs.key(name)
+	if err != nil {
+		return err
+	}
+
+	fi, err := fs.stat(key)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	fn(fi)
+	if w := fs.open.get(key); w != nil {
+		w.setMeta(fi.mode.Perm(), fi.modTime)
+		return nil
+	}
+
+	if fi.IsDir() {
+		key = dirmarker.Key(key)
+		if _, err := fs.c.Head(key); os.IsNotExist(err) {
+			if fs.policy != dirmarker.Marker {
+				return billy.ErrNotSupported
+			}
+
+			return fs.c.Put(key, bytes.NewReader(nil), 0, metadata(fi.mode, fi.modTime))
+		}
+	}
+
+	return fs.c.Copy(key, key, metadata(fi.mode, fi.modTime))
+}
+
+// Join joins the specified elements using slashes.
+func (fs *S3) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Dir returns a new S3 filesystem rooted at the given path, sharing the files
+// open for writing and the cached listings with fs. The path is rooted at the
+// base of fs, so the ".." elements can't go above it.
+func (fs *S3) Dir(p string) billy.Filesystem {
+	return &S3{
+		c:      fs.c,
+		base:   path.Join(fs.base, clean(p)),
+		opts:   fs.opts,
+		policy: fs.policy,
+		open:   fs.open,
+		cache:  fs.cache,
+	}
+}
+
+// Base returns the prefix of the keys.
+func (fs *S3) Base() string {
+	return fs.base
+}
+
+// key returns the key of the given filename. The filenames are relative to
+// the base, even the absolute ones, and the ones escaping it return
+// billy.ErrCrossedBoundary.
+func (fs *S3) key(filename string) (string, error) {
+	rel := path.Clean(strings.TrimLeft(strings.Replace(filename, `\`, "/", -1), "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", billy.ErrCrossedBoundary
+	}
+
+	if rel == "." {
+		return fs.base, nil
+	}
+
+	return path.Join(fs.base, rel), nil
+}
+
+// rel returns the filename of key, relative to the base.
+func (fs *S3) rel(key string) string {
+	return strings.TrimLeft(strings.TrimPrefix(key, fs.base), "/")
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+func metadata(mode os.FileMode, mtime time.Time) map[string]string {
+	return map[string]string{
+		modeMeta:  strconv.FormatUint(uint64(mode.Perm()), 8),
+		mtimeMeta: mtime.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// objectInfo returns the FileInfo of a file from its object, the mode and
+// the modification time are read from the metadata if present.
+func objectInfo(o *Object) *fileInfo {
+	fi := &fileInfo{
+		name:    path.Base(o.Key),
+		size:    o.Size,
+		mode:    defaultFileMode,
+		modTime: o.LastModified,
+	}
+
+	if m, err := strconv.ParseUint(o.Meta[modeMeta], 8, 32); err == nil {
+		fi.mode = os.FileMode(m).Perm()
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, o.Meta[mtimeMeta]); err == nil {
+		fi.modTime = t
+	}
+
+	return fi
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// openFiles holds the files open for writing, by key, shared by the
+// filesystems returned by Dir.
+type openFiles struct {
+	m     sync.Mutex
+	files map[string]*writer
+}
+
+func (o *openFiles) add(w *writer) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	o.files[w.key] = w
+}
+
+func (o *openFiles) get(key string) *writer {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	return o.files[key]
+}
+
+// close removes w, unless it was replaced by another file.
+func (o *openFiles) close(w *writer) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	if o.files[w.key] == w {
+		delete(o.files, w.key)
+	}
+}
+
+func (o *openFiles) remove(key string) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	if w, ok := o.files[key]; ok {
+		w.removed = true
+		delete(o.files, key)
+	}
+}
+
+// rename moves the files with key src, or under it if it ends with a slash,
+// to dst. It returns true if any was moved.
+func (o *openFiles) rename(src, dst string) bool {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	var moved []*writer
+	for key, w := range o.files {
+		if key == src || (strings.HasSuffix(src, "/") && strings.HasPrefix(key, src)) {
+			delete(o.files, key)
+			moved = append(moved, w)
+		}
+	}
+
+	for _, w := range moved {
+		w.rename(dst + strings.TrimPrefix(w.key, src))
+		o.files[w.key] = w
+	}
+
+	return len(moved) != 0
+}
+
+// under returns true if there are files open under the directory dir.
+func (o *openFiles) under(dir string) bool {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	for key := range o.files {
+		if strings.HasPrefix(key, dirmarker.Key(dir)) || dir == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+type openInfo struct {
+	*fileInfo
+	key string
+}
+
+// list returns the files open with keys starting with prefix.
+func (o *openFiles) list(prefix string) []openInfo {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	var l []openInfo
+	for key, w := range o.files {
+		if strings.HasPrefix(key, prefix) {
+			l = append(l, openInfo{w.info(), key})
+		}
+	}
+
+	return l
+}
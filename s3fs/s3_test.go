@@ -0,0 +1,391 @@
+package s3fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+	client *fakeClient
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.client = newFakeClient()
+	s.FilesystemSuite.Fs = New(s.client, "base", nil)
+}
+
+func (s *FilesystemSuite) TestObjects(c *C) {
+	f, err := s.Fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	_, err = s.client.Head("base/qux/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.client.keys(), DeepEquals, []string{"base/qux/", "base/qux/foo"})
+	o, err := s.client.Head("base/qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(o.Size, Equals, int64(3))
+	c.Assert(o.Meta[modeMeta], Equals, "666")
+}
+
+func (s *FilesystemSuite) TestStatOpenFile(c *C) {
+	f, err := s.Fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	fi, err := s.Fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	l, err := s.Fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	c.Assert(l[0].Name(), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestMultipart(c *C) {
+	fs := New(s.client, "", &Options{PartSize: 4})
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("0123456789"))
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("abcdef"))
+	c.Assert(err, IsNil)
+
+	c.Assert(s.client.uploaded, Equals, 2)
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("x"))
+	c.Assert(err, ErrorMatches, ".*content already uploaded")
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.client.uploads, HasLen, 0)
+	c.Assert(s.client.content("foo"), Equals, "0123456789abcdef")
+}
+
+func (s *FilesystemSuite) TestMultipartRemove(c *C) {
+	fs := New(s.client, "", &Options{PartSize: 4})
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("0123456789"))
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Remove("foo"), IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(s.client.uploads, HasLen, 0)
+	c.Assert(s.client.keys(), HasLen, 0)
+}
+
+func (s *FilesystemSuite) TestImplicitDirs(c *C) {
+	fs := New(s.client, "", &Options{ImplicitDirs: true})
+	c.Assert(fs.MkdirAll("qux", 0755), IsNil)
+	c.Assert(s.client.keys(), HasLen, 0)
+
+	c.Assert(s.client.Put("qux/bar/foo", bytes.NewReader(nil), 0, nil), IsNil)
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	c.Assert(fs.Rename("qux", "baz"), IsNil)
+	c.Assert(s.client.keys(), DeepEquals, []string{"baz/bar/foo"})
+	c.Assert(fs.Chmod("baz", 0700), Equals, billy.ErrNotSupported)
+}
+
+func (s *FilesystemSuite) TestListCache(c *C) {
+	f, err := s.Fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	l, err := s.Fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+
+	lists, heads := s.client.lists, s.client.heads
+	l, err = s.Fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+
+	_, err = s.Fs.Stat("qux/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(s.client.lists, Equals, lists)
+	c.Assert(s.client.heads, Equals, heads)
+
+	f, err = s.Fs.Create("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	l, err = s.Fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+	c.Assert(s.client.lists, Equals, lists+1)
+
+	c.Assert(s.Fs.Remove("qux/foo"), IsNil)
+	_, err = s.Fs.Stat("qux/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	l, err = s.Fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	c.Assert(l[0].Name(), Equals, "bar")
+}
+
+func (s *FilesystemSuite) TestCrossedBoundary(c *C) {
+	_, err := s.Fs.Create("../foo")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+}
+
+// fakeClient is a Client keeping the objects in memory.
+type fakeClient struct {
+	sync.Mutex
+	objects map[string]*object
+	uploads map[string]*upload
+	// uploaded is the number of parts uploaded.
+	uploaded int
+	ids      int
+	// heads and lists are the number of Head and List requests.
+	heads, lists int
+}
+
+type object struct {
+	data  []byte
+	mtime time.Time
+	meta  map[string]string
+}
+
+type upload struct {
+	key   string
+	meta  map[string]string
+	parts map[int][]byte
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		objects: make(map[string]*object),
+		uploads: make(map[string]*upload),
+	}
+}
+
+func notExist(key string) error {
+	return &os.PathError{Op: "fake", Path: key, Err: os.ErrNotExist}
+}
+
+func (c *fakeClient) keys() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	keys := make([]string, 0, len(c.objects))
+	for k := range c.objects {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *fakeClient) content(key string) string {
+	c.Lock()
+	defer c.Unlock()
+
+	if o, ok := c.objects[key]; ok {
+		return string(o.data)
+	}
+
+	return ""
+}
+
+func (c *fakeClient) Head(key string) (*Object, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.heads++
+	o, ok := c.objects[key]
+	if !ok {
+		return nil, notExist(key)
+	}
+
+	return &Object{Key: key, Size: int64(len(o.data)), LastModified: o.mtime, Meta: o.meta}, nil
+}
+
+func (c *fakeClient) Get(key string, off, length int64) (io.ReadCloser, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	o, ok := c.objects[key]
+	if !ok {
+		return nil, notExist(key)
+	}
+
+	data := o.data
+	if off > int64(len(data)) {
+		off = int64(len(data))
+	}
+
+	data = data[off:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c *fakeClient) Put(key string, r io.Reader, size int64, meta map[string]string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if int64(len(data)) != size {
+		return fmt.Errorf("put %s: size %d, read %d", key, size, len(data))
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.objects[key] = &object{data: data, mtime: time.Now(), meta: meta}
+	return nil
+}
+
+func (c *fakeClient) Copy(dst, src string, meta map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	o, ok := c.objects[src]
+	if !ok {
+		return notExist(src)
+	}
+
+	if meta == nil {
+		meta = o.meta
+	}
+
+	c.objects[dst] = &object{data: o.data, mtime: time.Now(), meta: meta}
+	return nil
+}
+
+func (c *fakeClient) Delete(key string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.objects, key)
+	return nil
+}
+
+func (c *fakeClient) List(prefix, delimiter string, max int) ([]Object, []string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.lists++
+	keys := make([]string, 0, len(c.objects))
+	for k := range c.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	var objects []Object
+	var prefixes []string
+	for _, k := range keys {
+		if max > 0 && len(objects)+len(prefixes) >= max {
+			break
+		}
+
+		rest := k[len(prefix):]
+		if i := strings.Index(rest, delimiter); delimiter != "" && i != -1 {
+			p := prefix + rest[:i+len(delimiter)]
+			if len(prefixes) == 0 || prefixes[len(prefixes)-1] != p {
+				prefixes = append(prefixes, p)
+			}
+
+			continue
+		}
+
+		o := c.objects[k]
+		objects = append(objects, Object{Key: k, Size: int64(len(o.data)), LastModified: o.mtime})
+	}
+
+	return objects, prefixes, nil
+}
+
+func (c *fakeClient) CreateMultipartUpload(key string, meta map[string]string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.ids++
+	id := fmt.Sprintf("upload-%d", c.ids)
+	c.uploads[id] = &upload{key: key, meta: meta, parts: make(map[int][]byte)}
+	return id, nil
+}
+
+func (c *fakeClient) UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	u, ok := c.uploads[uploadID]
+	if !ok || u.key != key {
+		return "", notExist(uploadID)
+	}
+
+	u.parts[number] = data
+	c.uploaded++
+	return fmt.Sprintf(`"%d"`, number), nil
+}
+
+func (c *fakeClient) CompleteMultipartUpload(key, uploadID string, parts []Part) error {
+	c.Lock()
+	defer c.Unlock()
+
+	u, ok := c.uploads[uploadID]
+	if !ok || u.key != key {
+		return notExist(uploadID)
+	}
+
+	var data []byte
+	for _, p := range parts {
+		if p.ETag != fmt.Sprintf(`"%d"`, p.Number) {
+			return errors.New("invalid part")
+		}
+
+		data = append(data, u.parts[p.Number]...)
+	}
+
+	delete(c.uploads, uploadID)
+	c.objects[key] = &object{data: data, mtime: time.Now(), meta: u.meta}
+	return nil
+}
+
+func (c *fakeClient) AbortMultipartUpload(key, uploadID string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.uploads, uploadID)
+	return nil
+}
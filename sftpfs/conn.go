@@ -0,0 +1,114 @@
+package sftpfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"srcd.works/go-billy.v1/internal/connpool"
+)
+
+// DialFunc opens a new SFTP client.
+type DialFunc func(ctx context.Context) (*sftp.Client, error)
+
+// Options holds the configuration of the pool of clients of a SFTP
+// filesystem.
+type Options struct {
+	// PoolSize is the maximum number of clients used at once, the operations
+	// wait while all of them are in use. 4 by default.
+	PoolSize int
+	// IdleTimeout closes the clients unused for longer than it, zero keeps
+	// them open.
+	IdleTimeout time.Duration
+	// Attempts is the number of times a client is dialed before giving up,
+	// 5 by default.
+	Attempts int
+}
+
+func (o *Options) pool() *connpool.Options {
+	if o == nil {
+		return nil
+	}
+
+	return &connpool.Options{
+		Size:        o.PoolSize,
+		IdleTimeout: o.IdleTimeout,
+		Attempts:    o.Attempts,
+	}
+}
+
+// dialer returns the connpool.DialFunc of the clients opened by dial, closing
+// them when discarded only if owned.
+func dialer(dial DialFunc, owned bool) connpool.DialFunc {
+	return func(ctx context.Context) (io.Closer, error) {
+		c, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		cn := &conn{Client: c, close: c.Close}
+		if !owned {
+			cn.close = func() error { return nil }
+		}
+
+		return cn, nil
+	}
+}
+
+// conn is a client of the pool. The files opened with it keep it open, so
+// it's closed, when discarded or on Close, once the last of them is closed.
+type conn struct {
+	*sftp.Client
+	close func() error
+
+	m      sync.Mutex
+	files  int
+	closed bool
+}
+
+func (c *conn) acquire() {
+	c.m.Lock()
+	c.files++
+	c.m.Unlock()
+}
+
+func (c *conn) release() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.files--; c.files == 0 && c.closed {
+		c.close()
+	}
+}
+
+func (c *conn) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	if c.files != 0 {
+		return nil
+	}
+
+	return c.close()
+}
+
+// lost returns true if err is caused by the loss of the connection of a
+// client.
+func lost(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	}
+
+	return err == sftp.ErrSSHFxConnectionLost
+}
@@ -0,0 +1,182 @@
+package sftpfs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/credentials"
+)
+
+func init() {
+	billy.Register("sftp", open)
+}
+
+// open returns the filesystem addressed by a sftp URI, such as
+// sftp://user@host/data, with the path relative to the home of the user, or
+// sftp://user@host:2222//srv/data, with an absolute one. The options are
+// read from the query string: known-hosts, the file with the keys of the
+// hosts, ~/.ssh/known_hosts by default, identity, the file of a private key,
+// pool-size, idle-timeout, as a duration, and attempts. The user, if not in
+// the URI, and the password are read from the variables SFTP_USERNAME and
+// SFTP_PASSWORD.
+func open(u *url.URL) (billy.Filesystem, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("sftpfs: missing host in %s", u)
+	}
+
+	q := u.Query()
+	opts, err := poolOptions(q)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := clientConfig(u, q)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	base := u.Path
+	if len(base) > 0 {
+		base = base[1:]
+	}
+
+	return NewPool(func(ctx context.Context) (*sftp.Client, error) {
+		return dial(ctx, addr, config)
+	}, base, opts), nil
+}
+
+func poolOptions(q url.Values) (*Options, error) {
+	var opts Options
+	if v := q.Get("pool-size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.PoolSize = size
+	}
+
+	if v := q.Get("idle-timeout"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.IdleTimeout = timeout
+	}
+
+	if v := q.Get("attempts"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Attempts = attempts
+	}
+
+	return &opts, nil
+}
+
+// clientConfig returns the configuration of the SSH clients of u. The
+// credentials are retrieved once, they are used by every client dialed.
+func clientConfig(u *url.URL, q url.Values) (*ssh.ClientConfig, error) {
+	c, err := credentials.Env("SFTP").Retrieve(context.Background())
+	if err != nil && err != credentials.ErrNoCredentials {
+		return nil, err
+	}
+
+	if c == nil {
+		c = &credentials.Credentials{}
+	}
+
+	user := u.User.Username()
+	if user == "" {
+		user = c.Username
+	}
+
+	var auth []ssh.AuthMethod
+	if v := q.Get("identity"); v != "" {
+		key, err := ioutil.ReadFile(v)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+
+	knownHosts := q.Get("known-hosts")
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	hostKey, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+	}, nil
+}
+
+// dial opens a SFTP client over a new SSH connection to addr, the connection
+// is closed with the client.
+func dial(ctx context.Context, addr string, config *ssh.ClientConfig) (*sftp.Client, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, chans, reqs, err := ssh.NewClientConn(nc, addr, config)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	conn := ssh.NewClient(sc, chans, reqs)
+	c, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go func() {
+		c.Wait()
+		conn.Close()
+	}()
+
+	return c, nil
+}
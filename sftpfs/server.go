@@ -0,0 +1,315 @@
+package sftpfs
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"srcd.works/go-billy.v1"
+)
+
+// NewServer returns a server of the SFTP protocol serving fs over rw, such
+// as the channel of an SSH session requesting the "sftp" subsystem. The
+// server is run with Serve.
+func NewServer(rw io.ReadWriteCloser, fs billy.Filesystem) *sftp.RequestServer {
+	return sftp.NewRequestServer(rw, Handlers(fs))
+}
+
+// Handlers returns the handlers of a sftp.RequestServer serving fs, with the
+// paths of the requests relative to the root of fs. The files are created,
+// and the directories made, only in existing directories, and the renames
+// fail if the destination exists, unless requested with the
+// posix-rename@openssh.com extension. The new files and directories get the
+// default permissions, 0666 and 0755, the clients change them with Setstat.
+func Handlers(fs billy.Filesystem) sftp.Handlers {
+	h := &handler{fs: fs}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+type handler struct {
+	fs billy.Filesystem
+}
+
+// Fileread opens the file of the request for reading.
+func (h *handler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return h.OpenFile(r)
+}
+
+// Filewrite opens the file of the request for writing.
+func (h *handler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return h.OpenFile(r)
+}
+
+// OpenFile opens the file of the request with its flags. The append flag is
+// ignored, since the clients write at the offsets they choose.
+func (h *handler) OpenFile(r *sftp.Request) (sftp.WriterAtReaderAt, error) {
+	filename := h.filename(r.Filepath)
+	p := r.Pflags()
+
+	flag := os.O_RDONLY
+	switch {
+	case p.Read && p.Write:
+		flag = os.O_RDWR
+	case p.Write:
+		flag = os.O_WRONLY
+	}
+
+	if p.Creat {
+		flag |= os.O_CREATE
+		if err := h.checkParent("open", r.Filepath); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Trunc {
+		flag |= os.O_TRUNC
+	}
+
+	if p.Excl {
+		flag |= os.O_EXCL
+	}
+
+	if fi, err := h.fs.Stat(filename); err == nil && fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: r.Filepath, Err: os.ErrInvalid}
+	}
+
+	f, err := h.fs.OpenFile(filename, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileAt{File: f}, nil
+}
+
+// Filecmd runs the commands changing the filesystem.
+func (h *handler) Filecmd(r *sftp.Request) error {
+	filename := h.filename(r.Filepath)
+	switch r.Method {
+	case "Setstat":
+		return h.setstat(filename, r)
+	case "Rename":
+		if _, err := h.fs.Lstat(h.filename(r.Target)); err == nil {
+			return &os.LinkError{Op: "rename", Old: r.Filepath, New: r.Target, Err: os.ErrExist}
+		}
+
+		return h.PosixRename(r)
+	case "Rmdir":
+		return h.rmdir(filename, r)
+	case "Remove":
+		return h.fs.Remove(filename)
+	case "Mkdir":
+		if _, err := h.fs.Lstat(filename); err == nil {
+			return &os.PathError{Op: "mkdir", Path: r.Filepath, Err: os.ErrExist}
+		}
+
+		if err := h.checkParent("mkdir", r.Filepath); err != nil {
+			return err
+		}
+
+		return h.fs.MkdirAll(filename, 0755)
+	case "Link":
+		l, ok := h.fs.(billy.HardLink)
+		if !ok {
+			return sftp.ErrSSHFxOpUnsupported
+		}
+
+		return l.Link(filename, h.filename(r.Target))
+	case "Symlink":
+		// the target is kept as given, r.Target is the link.
+		return h.fs.Symlink(filepath.FromSlash(r.Filepath), h.filename(r.Target))
+	}
+
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// PosixRename renames the file of the request, replacing the destination if
+// it exists.
+func (h *handler) PosixRename(r *sftp.Request) error {
+	return h.fs.Rename(h.filename(r.Filepath), h.filename(r.Target))
+}
+
+func (h *handler) setstat(filename string, r *sftp.Request) error {
+	flags, attrs := r.AttrFlags(), r.Attributes()
+	if flags.Size {
+		f, err := h.fs.OpenFile(filename, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Truncate(int64(attrs.Size)); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if flags.Permissions {
+		if err := h.fs.Chmod(filename, attrs.FileMode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if flags.UidGid {
+		c, ok := h.fs.(billy.Chowner)
+		if !ok {
+			return sftp.ErrSSHFxOpUnsupported
+		}
+
+		if err := c.Chown(filename, int(attrs.UID), int(attrs.GID)); err != nil {
+			return err
+		}
+	}
+
+	if flags.Acmodtime {
+		return h.fs.Chtimes(filename, attrs.AccessTime(), attrs.ModTime())
+	}
+
+	return nil
+}
+
+func (h *handler) rmdir(filename string, r *sftp.Request) error {
+	fi, err := h.fs.Lstat(filename)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return &os.PathError{Op: "rmdir", Path: r.Filepath, Err: os.ErrInvalid}
+	}
+
+	return h.fs.Remove(filename)
+}
+
+// Filelist lists the directory, or stats the file, of the request.
+func (h *handler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	filename := h.filename(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := h.fs.ReadDir(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		l := make(listerAt, len(entries))
+		for i, e := range entries {
+			l[i] = e
+		}
+
+		return l, nil
+	case "Stat":
+		fi, err := h.fs.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return listerAt{fi}, nil
+	}
+
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// Lstat stats the file of the request, without following symbolic links.
+func (h *handler) Lstat(r *sftp.Request) (sftp.ListerAt, error) {
+	fi, err := h.fs.Lstat(h.filename(r.Filepath))
+	if err != nil {
+		return nil, err
+	}
+
+	return listerAt{fi}, nil
+}
+
+// Readlink returns the target of the named symbolic link.
+func (h *handler) Readlink(name string) (string, error) {
+	target, err := h.fs.Readlink(h.filename(name))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(target), nil
+}
+
+// filename returns the filename in the filesystem of the given SFTP path,
+// empty for the root.
+func (h *handler) filename(name string) string {
+	rel := strings.TrimPrefix(path.Clean("/"+name), "/")
+	return filepath.FromSlash(rel)
+}
+
+// checkParent checks that the parent of the named file is a directory.
+func (h *handler) checkParent(op, name string) error {
+	parent := h.filename(path.Dir(path.Clean("/" + name)))
+	if parent == "" {
+		return nil
+	}
+
+	fi, err := h.fs.Stat(parent)
+	if err != nil || !fi.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	return nil
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// fileAt adapts a billy.File to io.ReaderAt and io.WriterAt, seeking before
+// every read and write if the file doesn't implement them.
+type fileAt struct {
+	billy.File
+	m sync.Mutex
+}
+
+func (f *fileAt) ReadAt(p []byte, off int64) (int, error) {
+	if r, ok := f.File.(io.ReaderAt); ok {
+		return r.ReadAt(p, off)
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if _, err := f.File.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(f.File, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+func (f *fileAt) WriteAt(p []byte, off int64) (int, error) {
+	if w, ok := f.File.(io.WriterAt); ok {
+		return w.WriteAt(p, off)
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if _, err := f.File.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return f.File.Write(p)
+}
@@ -0,0 +1,110 @@
+package sftpfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/sftp"
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+type ServerSuite struct {
+	test.FilesystemSuite
+	fs     billy.Filesystem
+	client *sftp.Client
+	server *sftp.RequestServer
+}
+
+var _ = Suite(&ServerSuite{})
+
+// SetUpTest connects a client with a server serving a memory filesystem, as
+// done by FilesystemSuite with the local one.
+func (s *ServerSuite) SetUpTest(c *C) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+
+	s.fs = memory.New()
+	s.server = NewServer(pipe{sr, sw}, s.fs)
+	go s.server.Serve()
+
+	var err error
+	s.client, err = sftp.NewClientPipe(cr, cw)
+	c.Assert(err, IsNil)
+	s.FilesystemSuite.Fs = New(s.client, "/")
+}
+
+func (s *ServerSuite) TearDownTest(c *C) {
+	c.Assert(s.server.Close(), IsNil)
+	s.client.Close()
+}
+
+// TestFileStat is skipped, sftp.RequestServer stats the open files by their
+// path, so they can't be found once renamed.
+func (s *ServerSuite) TestFileStat(c *C) {
+	c.Skip("stat of renamed open files not supported")
+}
+
+// TestOpenFileWriteOnly is skipped, sftp.RequestServer takes the reads of a
+// file opened only for writing as empty writes.
+func (s *ServerSuite) TestOpenFileWriteOnly(c *C) {
+	c.Skip("reads of write-only files not rejected")
+}
+
+func (s *ServerSuite) TestServerFile(c *C) {
+	f, err := s.client.Create("/foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	r, err := s.fs.Open("foo")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(r.Close(), IsNil)
+
+	_, err = s.client.Create("/missing/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *ServerSuite) TestServerMkdir(c *C) {
+	c.Assert(s.client.Mkdir("/qux"), IsNil)
+	fi, err := s.fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	c.Assert(s.client.Mkdir("/qux"), NotNil)
+	c.Assert(s.client.Mkdir("/missing/qux"), NotNil)
+	c.Assert(s.client.RemoveDirectory("/qux"), IsNil)
+	_, err = s.fs.Stat("qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *ServerSuite) TestServerRename(c *C) {
+	for _, name := range []string{"foo", "bar"} {
+		f, err := s.fs.Create(name)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	c.Assert(s.client.Rename("/foo", "/bar"), NotNil)
+	c.Assert(s.client.PosixRename("/foo", "/bar"), IsNil)
+	_, err := s.fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *ServerSuite) TestServerSymlink(c *C) {
+	c.Assert(s.client.Symlink("foo", "/bar"), IsNil)
+	target, err := s.fs.Readlink("bar")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "foo")
+
+	target, err = s.client.ReadLink("/bar")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "foo")
+}
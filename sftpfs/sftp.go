@@ -0,0 +1,557 @@
+// Package sftpfs provides a billy filesystem for remote hosts over SFTP, and
+// the handlers of a SFTP server serving any billy filesystem.
+package sftpfs // import "srcd.works/go-billy.v1/sftpfs"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/connpool"
+)
+
+const (
+	posixRenameExtension = "posix-rename@openssh.com"
+	hardlinkExtension    = "hardlink@openssh.com"
+	fsyncExtension       = "fsync@openssh.com"
+)
+
+// maxTempAttempts is the number of names tried by TempFile before failing.
+const maxTempAttempts = 10000
+
+// SFTP is a filesystem based on a remote host reached through a pool of SFTP
+// clients, the paths are relative to a base directory in the host.
+type SFTP struct {
+	pool *connpool.Pool
+	base string
+}
+
+// New returns a new SFTP filesystem using the given client, rooted at the
+// directory baseDir of the remote host. The client is not closed by the
+// filesystem, nor redialed if the connection is lost.
+func New(c *sftp.Client, baseDir string) *SFTP {
+	dial := func(context.Context) (*sftp.Client, error) { return c, nil }
+	return &SFTP{
+		pool: connpool.New(dialer(dial, false), nil),
+		base: baseDir,
+	}
+}
+
+// NewPool returns a new SFTP filesystem rooted at the directory baseDir of
+// the remote host, using the clients opened with dial. The clients are
+// pooled, the idle ones are reused and the ones losing their connection are
+// replaced, dialing again with backoff, as configured by opts. The clients
+// are closed by Close.
+func NewPool(dial DialFunc, baseDir string, opts *Options) *SFTP {
+	return &SFTP{
+		pool: connpool.New(dialer(dial, true), opts.pool()),
+		base: baseDir,
+	}
+}
+
+// do runs fn with a client of the pool, discarding it if its connection is
+// lost, so the next operation dials a new one.
+func (fs *SFTP) do(fn func(c *conn) error) error {
+	c, err := fs.pool.Get(context.Background())
+	if err != nil {
+		return err
+	}
+
+	err = fn(c.(*conn))
+	if lost(err) {
+		fs.pool.Discard(c)
+	} else {
+		fs.pool.Put(c)
+	}
+
+	return err
+}
+
+// Create creates a file and opens it with standard permissions
+// and modes O_RDWR, O_CREATE and O_TRUNC.
+func (fs *SFTP) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens a file in read-only mode.
+func (fs *SFTP) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile is equivalent to standard os.OpenFile. If flag os.O_CREATE is set,
+// all parent directories will be created, and perm is applied to the file if
+// it didn't exist.
+func (fs *SFTP) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fullpath, err := fs.abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var f billy.File
+	return f, fs.do(func(c *conn) error {
+		var created bool
+		if flag&os.O_CREATE != 0 {
+			if err := createDir(c, fullpath); err != nil {
+				return err
+			}
+
+			_, err := c.Lstat(fullpath)
+			created = os.IsNotExist(err)
+		}
+
+		sf, err := openFile(c, fullpath, flag)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			if err := sf.Chmod(perm); err != nil {
+				sf.Close()
+				return err
+			}
+		}
+
+		f = newFile(fs.rel(fullpath), sf, c, flag)
+		return nil
+	})
+}
+
+func openFile(c *conn, fullpath string, flag int) (*sftp.File, error) {
+	f, err := c.OpenFile(fullpath, flag)
+	if err != nil && flag&os.O_EXCL != 0 && !os.IsExist(err) {
+		// SFTP has no error for the existing files, they are told from the
+		// other failures by looking for them.
+		if _, serr := c.Lstat(fullpath); serr == nil {
+			return nil, &os.PathError{Op: "open", Path: fullpath, Err: os.ErrExist}
+		}
+	}
+
+	return f, err
+}
+
+func createDir(c *conn, fullpath string) error {
+	dir := path.Dir(fullpath)
+	if dir != "." {
+		if err := c.MkdirAll(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stat returns the FileInfo structure describing file.
+func (fs *SFTP) Stat(filename string) (billy.FileInfo, error) {
+	fullpath, err := fs.abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var fi billy.FileInfo
+	return fi, fs.do(func(c *conn) (err error) {
+		fi, err = c.Stat(fullpath)
+		return err
+	})
+}
+
+// Lstat returns the FileInfo structure describing file, if it's a symbolic
+// link the link itself is described.
+func (fs *SFTP) Lstat(filename string) (billy.FileInfo, error) {
+	fullpath, err := fs.abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var fi billy.FileInfo
+	return fi, fs.do(func(c *conn) (err error) {
+		fi, err = c.Lstat(fullpath)
+		return err
+	})
+}
+
+// ReadDir returns the filesystem info for all the entries under the specified
+// path, sorted by name.
+func (fs *SFTP) ReadDir(path string) ([]billy.FileInfo, error) {
+	fullpath, err := fs.abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []os.FileInfo
+	if err := fs.do(func(c *conn) (err error) {
+		l, err = c.ReadDir(fullpath)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	var s = make([]billy.FileInfo, len(l))
+	for i, f := range l {
+		s[i] = f
+	}
+
+	sort.Sort(byName(s))
+	return s, nil
+}
+
+// TempFile creates a new temporal file, with a random name starting with
+// prefix, in dir.
+func (fs *SFTP) TempFile(dir, prefix string) (billy.File, error) {
+	fullpath, err := fs.abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var f billy.File
+	return f, fs.do(func(c *conn) error {
+		if err := c.MkdirAll(fullpath); err != nil {
+			return err
+		}
+
+		for i := 0; i < maxTempAttempts; i++ {
+			name := path.Join(fullpath, fmt.Sprintf("%s%d", prefix, rand.Int63()))
+			flag := os.O_RDWR | os.O_CREATE | os.O_EXCL
+			sf, err := openFile(c, name, flag)
+			if os.IsExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if err := sf.Chmod(0600); err != nil {
+				sf.Close()
+				return err
+			}
+
+			f = newFile(fs.rel(name), sf, c, flag)
+			return nil
+		}
+
+		return fmt.Errorf("temp file in %s: too many attempts", dir)
+	})
+}
+
+// Rename moves a file from _from_ to _to_, replacing it if it exists. The
+// servers without the posix-rename@openssh.com extension fail if to exists,
+// as required by the SFTP protocol.
+func (fs *SFTP) Rename(from, to string) error {
+	from, err := fs.abs(from)
+	if err != nil {
+		return err
+	}
+
+	to, err = fs.abs(to)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		if err := createDir(c, to); err != nil {
+			return err
+		}
+
+		if _, ok := c.HasExtension(posixRenameExtension); ok {
+			return c.PosixRename(from, to)
+		}
+
+		return c.Rename(from, to)
+	})
+}
+
+// Remove deletes a file or an empty directory.
+func (fs *SFTP) Remove(filename string) error {
+	fullpath, err := fs.abs(filename)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		return c.Remove(fullpath)
+	})
+}
+
+// Symlink creates link as a symbolic link to target, creating the parent
+// directories of link. The target is stored as given, so an absolute target
+// is not relative to the filesystem base.
+func (fs *SFTP) Symlink(target, link string) error {
+	link, err := fs.abs(link)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		if err := createDir(c, link); err != nil {
+			return err
+		}
+
+		return c.Symlink(target, link)
+	})
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *SFTP) Readlink(link string) (string, error) {
+	fullpath, err := fs.abs(link)
+	if err != nil {
+		return "", err
+	}
+
+	var target string
+	return target, fs.do(func(c *conn) (err error) {
+		target, err = c.ReadLink(fullpath)
+		return err
+	})
+}
+
+// Link creates newname as a hard link to the oldname file, it requires the
+// server to support the hardlink@openssh.com extension, returning
+// billy.ErrNotSupported otherwise.
+func (fs *SFTP) Link(oldname, newname string) error {
+	oldname, err := fs.abs(oldname)
+	if err != nil {
+		return err
+	}
+
+	newname, err = fs.abs(newname)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		if _, ok := c.HasExtension(hardlinkExtension); !ok {
+			return billy.ErrNotSupported
+		}
+
+		if err := createDir(c, newname); err != nil {
+			return err
+		}
+
+		return c.Link(oldname, newname)
+	})
+}
+
+// MkdirAll creates the directory path and all its parents, applying perm to
+// the directories created.
+func (fs *SFTP) MkdirAll(path string, perm os.FileMode) error {
+	fullpath, err := fs.abs(path)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		return mkdirAll(c, fullpath, perm)
+	})
+}
+
+func mkdirAll(c *conn, fullpath string, perm os.FileMode) error {
+	fi, err := c.Stat(fullpath)
+	if err == nil {
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: fullpath, Err: os.ErrExist}
+		}
+
+		return nil
+	}
+
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	if dir := path.Dir(fullpath); dir != fullpath {
+		if err := mkdirAll(c, dir, perm); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mkdir(fullpath); err != nil {
+		// created concurrently.
+		if fi, serr := c.Stat(fullpath); serr == nil && fi.IsDir() {
+			return nil
+		}
+
+		return err
+	}
+
+	return c.Chmod(fullpath, perm)
+}
+
+// Chmod changes the mode of the named file.
+func (fs *SFTP) Chmod(name string, mode os.FileMode) error {
+	fullpath, err := fs.abs(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		return c.Chmod(fullpath, mode)
+	})
+}
+
+// Chtimes changes the access and modification times of the named file.
+func (fs *SFTP) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fullpath, err := fs.abs(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		return c.Chtimes(fullpath, atime, mtime)
+	})
+}
+
+// Chown changes the numeric uid and gid of the named file.
+func (fs *SFTP) Chown(name string, uid, gid int) error {
+	fullpath, err := fs.abs(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.do(func(c *conn) error {
+		return c.Chown(fullpath, uid, gid)
+	})
+}
+
+// Join joins the specified elements using slashes, the separator of SFTP.
+func (fs *SFTP) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Dir returns a new Filesystem from the same type of fs using as baseDir the
+// given path, sharing its clients. The path is rooted at the base of fs, so
+// the ".." elements can't go above it.
+func (fs *SFTP) Dir(p string) billy.Filesystem {
+	return &SFTP{pool: fs.pool, base: path.Join(fs.base, path.Clean("/"+p))}
+}
+
+// Close closes the clients of the pool, the ones of the open files are
+// closed with the last of them.
+func (fs *SFTP) Close() error {
+	return fs.pool.Close()
+}
+
+// Base returns the base path of the filesytem in the remote host.
+func (fs *SFTP) Base() string {
+	return fs.base
+}
+
+// abs returns the path in the remote host of the given filename. The
+// filenames are relative to the base, even the absolute ones, and the ones
+// escaping it return billy.ErrCrossedBoundary.
+func (fs *SFTP) abs(filename string) (string, error) {
+	rel := path.Clean(strings.TrimLeft(strings.Replace(filename, `\`, "/", -1), "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", billy.ErrCrossedBoundary
+	}
+
+	return path.Join(fs.base, rel), nil
+}
+
+// rel returns the filename of the given path in the remote host, relative to
+// the base.
+func (fs *SFTP) rel(fullpath string) string {
+	return strings.TrimLeft(strings.TrimPrefix(fullpath, path.Clean(fs.base)), "/")
+}
+
+type byName []billy.FileInfo
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// file is a file in the remote host, keeping open the client it was opened
+// with until it's closed.
+type file struct {
+	billy.BaseFile
+	f *sftp.File
+	c *conn
+	// append is true for the files opened with os.O_APPEND, which the
+	// servers may ignore.
+	append bool
+}
+
+func newFile(filename string, f *sftp.File, c *conn, flag int) billy.File {
+	c.acquire()
+	return &file{
+		BaseFile: billy.BaseFile{BaseFilename: filename},
+		f:        f,
+		c:        c,
+		append:   flag&os.O_APPEND != 0,
+	}
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	return f.f.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return f.f.ReadAt(p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return f.f.Seek(offset, whence)
+}
+
+// Write writes p at the offset of the file, or at its end if it was opened
+// with os.O_APPEND.
+func (f *file) Write(p []byte) (int, error) {
+	if f.append {
+		if _, err := f.f.Seek(0, io.SeekEnd); err != nil {
+			return 0, err
+		}
+	}
+
+	return f.f.Write(p)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return f.f.WriteAt(p, off)
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	return f.f.Stat()
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.f.Truncate(size)
+}
+
+// Sync commits the content of the file to stable storage if the server
+// supports the fsync@openssh.com extension, otherwise it does nothing, since
+// the writes are already acknowledged by the server.
+func (f *file) Sync() error {
+	if _, ok := f.c.HasExtension(fsyncExtension); !ok {
+		return nil
+	}
+
+	return f.f.Sync()
+}
+
+// Lock returns billy.ErrNotSupported, SFTP has no locks.
+func (f *file) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, SFTP has no locks.
+func (f *file) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+func (f *file) Close() error {
+	if f.BaseFile.Closed {
+		return f.f.Close()
+	}
+
+	f.BaseFile.Closed = true
+	defer f.c.release()
+
+	return f.f.Close()
+}
@@ -0,0 +1,140 @@
+package sftpfs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+	path   string
+	client *sftp.Client
+	server *sftp.Server
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+type pipe struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// SetUpTest connects a client with a server running in-process, serving the
+// local filesystem, through a pair of pipes.
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+
+	var err error
+	s.server, err = sftp.NewServer(pipe{sr, sw})
+	c.Assert(err, IsNil)
+	go s.server.Serve()
+
+	s.client, err = sftp.NewClientPipe(cr, cw)
+	c.Assert(err, IsNil)
+
+	s.path = c.MkDir()
+	s.FilesystemSuite.Fs = New(s.client, s.path)
+}
+
+func (s *FilesystemSuite) TearDownTest(c *C) {
+	c.Assert(s.server.Close(), IsNil)
+	s.client.Close()
+}
+
+func (s *FilesystemSuite) TestRemoteFile(c *C) {
+	f, err := s.Fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Filename(), Equals, "qux/foo")
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(s.path, "qux", "foo"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+}
+
+func (s *FilesystemSuite) TestLink(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.Fs.(billy.HardLink).Link("foo", "qux/bar"), IsNil)
+
+	fi, err := os.Stat(filepath.Join(s.path, "qux", "bar"))
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().IsRegular(), Equals, true)
+}
+
+func (s *FilesystemSuite) TestCrossedBoundary(c *C) {
+	for _, name := range []string{"../foo", "qux/../../foo", "/../foo"} {
+		_, err := s.Fs.Create(name)
+		c.Assert(err, Equals, billy.ErrCrossedBoundary, Commentf(name))
+	}
+
+	fs := s.Fs.Dir("../../qux")
+	c.Assert(fs.Base(), Equals, filepath.Join(s.path, "qux"))
+}
+
+func (s *FilesystemSuite) TestPoolRedial(c *C) {
+	var servers []*sftp.Server
+	fs := NewPool(func(ctx context.Context) (*sftp.Client, error) {
+		cr, sw := io.Pipe()
+		sr, cw := io.Pipe()
+
+		server, err := sftp.NewServer(pipe{sr, sw})
+		if err != nil {
+			return nil, err
+		}
+
+		go server.Serve()
+		servers = append(servers, server)
+		return sftp.NewClientPipe(cr, cw)
+	}, s.path, &Options{PoolSize: 1})
+
+	f, err := fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(servers, HasLen, 1)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(servers, HasLen, 1)
+
+	servers[0].Close()
+	_, err = fs.Stat("foo")
+	c.Assert(err, NotNil)
+
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(servers, HasLen, 2)
+
+	c.Assert(servers[1].Close(), IsNil)
+	c.Assert(fs.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestOpenURI(c *C) {
+	_, err := billy.Open("sftp:///foo")
+	c.Assert(err, ErrorMatches, "sftpfs: missing host in sftp:///foo")
+
+	_, err = billy.Open("sftp://host/foo?known-hosts=" + filepath.Join(s.path, "known_hosts"))
+	c.Assert(err, NotNil)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.path, "known_hosts"), nil, 0644), IsNil)
+	fs, err := billy.Open("sftp://host//foo?pool-size=2&known-hosts=" + filepath.Join(s.path, "known_hosts"))
+	c.Assert(err, IsNil)
+	c.Assert(fs.(*SFTP).Base(), Equals, "/foo")
+	c.Assert(billy.Close(fs), IsNil)
+}
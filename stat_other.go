@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package billy
+
+func fileOwner(info FileInfo) (uid, gid int, ok bool) {
+	return -1, -1, false
+}
+
+func fileInode(info FileInfo) (id inode, nlink uint64, ok bool) {
+	return inode{}, 0, false
+}
+
+func fileDevice(info FileInfo) uint64 {
+	return 0
+}
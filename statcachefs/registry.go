@@ -0,0 +1,41 @@
+package statcachefs
+
+import (
+	"strconv"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.RegisterWrapper("statcache", wrap)
+}
+
+// wrap returns a StatCache filesystem wrapping fs, with the ttl and
+// negative-ttl options as durations and the prefetch option as a boolean.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	var o Options
+	for name, d := range map[string]*time.Duration{
+		"ttl":          &o.TTL,
+		"negative-ttl": &o.NegativeTTL,
+	} {
+		v, ok := opts[name]
+		if !ok {
+			continue
+		}
+
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v, ok := opts["prefetch"]; ok {
+		var err error
+		if o.Prefetch, err = strconv.ParseBool(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return New(fs, &o), nil
+}
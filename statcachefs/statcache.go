@@ -0,0 +1,494 @@
+// Package statcachefs provides a billy filesystem wrapper caching the results
+// of Stat and Lstat, including the lookups of missing files, which dominate
+// the requests to remote backends when probing for optional files such as
+// configuration lookup chains or .gitignore discovery.
+package statcachefs // import "srcd.works/go-billy.v1/statcachefs"
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/internal/runner"
+)
+
+// Options holds the configuration of a StatCache filesystem.
+type Options struct {
+	// TTL is the time the FileInfo of an existing file is cached, five
+	// seconds by default.
+	TTL time.Duration
+	// NegativeTTL is the time a file is remembered as missing, five seconds
+	// by default.
+	NegativeTTL time.Duration
+	// Prefetch enables listing in the background the directory of every file
+	// opened for reading, caching the FileInfo of its entries, since the
+	// siblings of a file are usually the next ones accessed. The wrapped
+	// filesystem must be safe for concurrent use.
+	Prefetch bool
+}
+
+var defaultOptions = Options{
+	TTL:         5 * time.Second,
+	NegativeTTL: 5 * time.Second,
+}
+
+// StatCache wraps a billy.Filesystem caching the results of Stat, Lstat and
+// ReadDir, both the FileInfo of the existing files and the os.ErrNotExist
+// errors of the missing ones, the entries listed by ReadDir are cached as
+// well. The writes done through the filesystem, or the ones
+// returned by Dir, invalidate the entries of the path written, its parents
+// and its children, the changes done by others, or to the targets of the
+// symbolic links, are seen once the entries expire.
+type StatCache struct {
+	fs     billy.Filesystem
+	c      *cache
+	prefix string
+}
+
+// New returns a new StatCache filesystem wrapping the given one, if opts is
+// nil the default options are used, as for their zero fields.
+func New(fs billy.Filesystem, opts *Options) *StatCache {
+	o := defaultOptions
+	if opts != nil {
+		if opts.TTL > 0 {
+			o.TTL = opts.TTL
+		}
+
+		if opts.NegativeTTL > 0 {
+			o.NegativeTTL = opts.NegativeTTL
+		}
+
+		o.Prefetch = opts.Prefetch
+	}
+
+	return &StatCache{fs: fs, c: &cache{
+		opts:        o,
+		entries:     make(map[string]*entry),
+		prefetching: make(map[string]bool),
+		r:           runner.New(nil),
+	}}
+}
+
+// Purge drops all the cached entries.
+func (fs *StatCache) Purge() {
+	fs.c.purge()
+}
+
+func (fs *StatCache) key(filename string) string {
+	return clean(path.Join(fs.prefix, slash(filename)))
+}
+
+func (fs *StatCache) invalidate(filename string) {
+	fs.c.invalidate(fs.key(filename))
+}
+
+// Create creates the named file.
+func (fs *StatCache) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *StatCache) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, the files opened for writing invalidate
+// their entries on every write.
+func (fs *StatCache) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if !isWrite(flag) {
+		f, err := fs.fs.OpenFile(filename, flag, perm)
+		if err == nil && fs.c.opts.Prefetch {
+			fs.prefetch(path.Dir(slash(filename)))
+		}
+
+		return f, err
+	}
+
+	fs.invalidate(filename)
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs, name: filename}, nil
+}
+
+// Stat returns the FileInfo of the named file, from the cache if present.
+func (fs *StatCache) Stat(filename string) (billy.FileInfo, error) {
+	return fs.c.stat(fs.key(filename), false, func() (billy.FileInfo, error) {
+		return fs.fs.Stat(filename)
+	})
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links, from the cache if present.
+func (fs *StatCache) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.c.stat(fs.key(filename), true, func() (billy.FileInfo, error) {
+		return fs.fs.Lstat(filename)
+	})
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory, from the
+// cache if present.
+func (fs *StatCache) ReadDir(path string) ([]billy.FileInfo, error) {
+	return fs.c.readDir(fs.key(path), func() ([]billy.FileInfo, error) {
+		return fs.fs.ReadDir(path)
+	})
+}
+
+// prefetch lists dir in the background, unless it's already cached or being
+// listed, resolving the symbolic links found in it.
+func (fs *StatCache) prefetch(dir string) {
+	key := fs.key(dir)
+	if !fs.c.startPrefetch(key) {
+		return
+	}
+
+	err := fs.c.r.Go("prefetch "+key, func(ctx context.Context) error {
+		defer fs.c.endPrefetch(key)
+
+		entries, err := fs.ReadDir(dir)
+		if err != nil {
+			return nil
+		}
+
+		for _, fi := range entries {
+			if fi.Mode()&os.ModeSymlink != 0 {
+				fs.Stat(path.Join(dir, fi.Name()))
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		fs.c.endPrefetch(key)
+	}
+}
+
+// TempFile creates a temporary file.
+func (fs *StatCache) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.fs.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.invalidate(f.Filename())
+	return &file{File: f, fs: fs, name: f.Filename()}, nil
+}
+
+// Rename renames a file, invalidating both paths.
+func (fs *StatCache) Rename(from, to string) error {
+	defer fs.invalidate(to)
+	defer fs.invalidate(from)
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file.
+func (fs *StatCache) Remove(filename string) error {
+	defer fs.invalidate(filename)
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link.
+func (fs *StatCache) Symlink(target, link string) error {
+	defer fs.invalidate(link)
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *StatCache) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *StatCache) MkdirAll(path string, perm os.FileMode) error {
+	defer fs.invalidate(path)
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *StatCache) Chmod(name string, mode os.FileMode) error {
+	defer fs.invalidate(name)
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *StatCache) Chtimes(name string, atime, mtime time.Time) error {
+	defer fs.invalidate(name)
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Flush waits for the directories being prefetched, then flushes the
+// underlying filesystem, as billy.Flush.
+func (fs *StatCache) Flush(ctx context.Context) error {
+	if err := fs.c.r.Wait(ctx); err != nil {
+		return err
+	}
+
+	return billy.Flush(ctx, fs.fs)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *StatCache) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close stops the prefetching, waiting for the directories being listed,
+// then closes the underlying filesystem, as billy.Close.
+func (fs *StatCache) Close() error {
+	fs.c.r.Close()
+	return billy.Close(fs.fs)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *StatCache) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new StatCache filesystem rooted at the given path, sharing
+// the cache with the current one.
+func (fs *StatCache) Dir(p string) billy.Filesystem {
+	return &StatCache{
+		fs:     fs.fs.Dir(p),
+		c:      fs.c,
+		prefix: fs.key(p),
+	}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *StatCache) Base() string {
+	return fs.fs.Base()
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// slash converts the separators of filename to slashes, since billy
+// filenames may use any of them.
+func slash(filename string) string {
+	return strings.Replace(filename, `\`, "/", -1)
+}
+
+func clean(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// file is a file open for writing, invalidating its entries on every write.
+type file struct {
+	billy.File
+	fs   *StatCache
+	name string
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, billy.ErrNotSupported
+	}
+
+	return r.ReadAt(p, off)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	defer f.fs.invalidate(f.name)
+	return f.File.Write(p)
+}
+
+func (f *file) Truncate(size int64) error {
+	defer f.fs.invalidate(f.name)
+	return f.File.Truncate(size)
+}
+
+func (f *file) Close() error {
+	defer f.fs.invalidate(f.name)
+	return f.File.Close()
+}
+
+// cache holds the entries of the paths, relative to the root of the
+// filesystem passed to New.
+type cache struct {
+	opts Options
+
+	m       sync.Mutex
+	entries map[string]*entry
+	// gen is incremented on every invalidation, so the results of the calls
+	// started before it are not cached.
+	gen uint64
+	// prefetching holds the directories being listed in the background, by
+	// the tasks of r.
+	prefetching map[string]bool
+	r           *runner.Runner
+}
+
+// entry holds the results of Stat, Lstat and ReadDir of a path.
+type entry struct {
+	stat, lstat *result
+	dir         *listing
+}
+
+type result struct {
+	fi      billy.FileInfo
+	err     error
+	expires time.Time
+}
+
+type listing struct {
+	entries []billy.FileInfo
+	expires time.Time
+}
+
+func (c *cache) entry(key string) *entry {
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry{}
+		c.entries[key] = e
+	}
+
+	return e
+}
+
+// stat returns the cached result for key, or the one of fn, caching it if
+// it's a FileInfo or an os.ErrNotExist error.
+func (c *cache) stat(key string, lstat bool, fn func() (billy.FileInfo, error)) (billy.FileInfo, error) {
+	c.m.Lock()
+	if e, ok := c.entries[key]; ok {
+		r := e.stat
+		if lstat {
+			r = e.lstat
+		}
+
+		if r != nil && time.Now().Before(r.expires) {
+			c.m.Unlock()
+			return r.fi, r.err
+		}
+	}
+
+	gen := c.gen
+	c.m.Unlock()
+
+	fi, err := fn()
+	var ttl time.Duration
+	switch {
+	case err == nil:
+		ttl = c.opts.TTL
+	case os.IsNotExist(err):
+		ttl = c.opts.NegativeTTL
+	default:
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if gen != c.gen {
+		return fi, err
+	}
+
+	e := c.entry(key)
+	r := &result{fi: fi, err: err, expires: time.Now().Add(ttl)}
+	if lstat {
+		e.lstat = r
+	} else {
+		e.stat = r
+	}
+
+	return fi, err
+}
+
+// readDir returns the cached listing of key, or the one of fn, caching it
+// along with the FileInfo of its entries.
+func (c *cache) readDir(key string, fn func() ([]billy.FileInfo, error)) ([]billy.FileInfo, error) {
+	c.m.Lock()
+	if e, ok := c.entries[key]; ok && e.dir != nil && time.Now().Before(e.dir.expires) {
+		entries := append([]billy.FileInfo(nil), e.dir.entries...)
+		c.m.Unlock()
+		return entries, nil
+	}
+
+	gen := c.gen
+	c.m.Unlock()
+
+	entries, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if gen != c.gen {
+		return entries, nil
+	}
+
+	expires := time.Now().Add(c.opts.TTL)
+	c.entry(key).dir = &listing{
+		entries: append([]billy.FileInfo(nil), entries...),
+		expires: expires,
+	}
+
+	for _, fi := range entries {
+		e := c.entry(clean(path.Join(key, fi.Name())))
+		r := &result{fi: fi, expires: expires}
+		e.lstat = r
+		if fi.Mode()&os.ModeSymlink == 0 {
+			e.stat = r
+		}
+	}
+
+	return entries, nil
+}
+
+// startPrefetch marks key as being listed in the background, returning false
+// if it's already cached or being listed.
+func (c *cache) startPrefetch(key string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.prefetching[key] {
+		return false
+	}
+
+	if e, ok := c.entries[key]; ok && e.dir != nil && time.Now().Before(e.dir.expires) {
+		return false
+	}
+
+	c.prefetching[key] = true
+	return true
+}
+
+func (c *cache) endPrefetch(key string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	delete(c.prefetching, key)
+}
+
+// invalidate drops the entries of key, its parents and its children.
+func (c *cache) invalidate(key string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.gen++
+	for k := range c.entries {
+		if k == key || strings.HasPrefix(key, k+"/") || strings.HasPrefix(k, key+"/") ||
+			k == "" || key == "" {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *cache) purge() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.gen++
+	c.entries = make(map[string]*entry)
+}
@@ -0,0 +1,228 @@
+package statcachefs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FilesystemSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&FilesystemSuite{})
+
+func (s *FilesystemSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New(), nil)
+}
+
+type StatCacheSuite struct {
+	under *counting
+	fs    *StatCache
+}
+
+var _ = Suite(&StatCacheSuite{})
+
+func (s *StatCacheSuite) SetUpTest(c *C) {
+	s.under = &counting{Filesystem: memory.New()}
+	s.fs = New(s.under, nil)
+}
+
+// counting counts the calls to Stat and ReadDir.
+type counting struct {
+	billy.Filesystem
+	stats, readDirs int
+}
+
+func (fs *counting) Stat(filename string) (billy.FileInfo, error) {
+	fs.stats++
+	return fs.Filesystem.Stat(filename)
+}
+
+func (fs *counting) ReadDir(path string) ([]billy.FileInfo, error) {
+	fs.readDirs++
+	return fs.Filesystem.ReadDir(path)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *StatCacheSuite) assertNotExist(c *C, fs billy.Filesystem, filename string) {
+	_, err := fs.Stat(filename)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *StatCacheSuite) TestStat(c *C) {
+	f, err := s.fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	for i := 0; i < 3; i++ {
+		fi, err := s.fs.Stat("foo")
+		c.Assert(err, IsNil)
+		c.Assert(fi.Name(), Equals, "foo")
+	}
+
+	c.Assert(s.under.stats, Equals, 1)
+}
+
+func (s *StatCacheSuite) TestStatNotExist(c *C) {
+	for i := 0; i < 3; i++ {
+		s.assertNotExist(c, s.fs, "foo")
+	}
+
+	c.Assert(s.under.stats, Equals, 1)
+}
+
+func (s *StatCacheSuite) TestCreateInvalidates(c *C) {
+	s.assertNotExist(c, s.fs, "qux/foo")
+	s.assertNotExist(c, s.fs, "qux")
+
+	f, err := s.fs.Create("qux/foo")
+	c.Assert(err, IsNil)
+
+	fi, err := s.fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(0))
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fi, err = s.fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	fi, err = s.fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+}
+
+func (s *StatCacheSuite) TestRemoveInvalidates(c *C) {
+	c.Assert(s.fs.MkdirAll("qux/bar", 0755), IsNil)
+	_, err := s.fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.fs.Remove("qux/bar"), IsNil)
+	s.assertNotExist(c, s.fs, "qux/bar")
+}
+
+func (s *StatCacheSuite) TestNegativeTTL(c *C) {
+	fs := New(s.under, &Options{NegativeTTL: 10 * time.Millisecond})
+	s.assertNotExist(c, fs, "foo")
+
+	f, err := s.under.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	s.assertNotExist(c, fs, "foo")
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(s.under.stats, Equals, 2)
+}
+
+func (s *StatCacheSuite) TestDir(c *C) {
+	s.assertNotExist(c, s.fs, "qux/foo")
+
+	f, err := s.fs.Dir("qux").Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = s.fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *StatCacheSuite) TestReadDir(c *C) {
+	writeFile(c, s.under, "qux/foo")
+	writeFile(c, s.under, "qux/bar")
+
+	for i := 0; i < 3; i++ {
+		entries, err := s.fs.ReadDir("qux")
+		c.Assert(err, IsNil)
+		c.Assert(entries, HasLen, 2)
+	}
+
+	fi, err := s.fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "foo")
+	c.Assert(s.under.readDirs, Equals, 1)
+	c.Assert(s.under.stats, Equals, 0)
+
+	writeFile(c, s.fs, "qux/baz")
+	entries, err := s.fs.ReadDir("qux")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+}
+
+func (s *StatCacheSuite) TestPrefetch(c *C) {
+	writeFile(c, s.under, "qux/foo")
+	writeFile(c, s.under, "qux/bar")
+	c.Assert(s.under.Symlink("bar", "qux/link"), IsNil)
+
+	fs := New(s.under, &Options{Prefetch: true})
+	for i := 0; i < 3; i++ {
+		f, err := fs.Open("qux/foo")
+		c.Assert(err, IsNil)
+		c.Assert(fs.Flush(context.Background()), IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	c.Assert(s.under.readDirs, Equals, 1)
+	c.Assert(s.under.stats, Equals, 1)
+
+	fi, err := fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "bar")
+
+	fi, err = fs.Stat("qux/link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.FileMode(0))
+	c.Assert(s.under.stats, Equals, 1)
+}
+
+func (s *StatCacheSuite) TestPrefetchClosed(c *C) {
+	writeFile(c, s.under, "qux/foo")
+
+	fs := New(s.under, &Options{Prefetch: true})
+	c.Assert(fs.Close(), IsNil)
+
+	f, err := fs.Dir("qux").Open("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Flush(context.Background()), IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(s.under.readDirs, Equals, 0)
+}
+
+func (s *StatCacheSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend: "mem://statcachefs",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "statcache",
+			Options: map[string]string{"negative-ttl": "1s", "prefetch": "true"},
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fs, FitsTypeOf, &StatCache{})
+
+	_, err = billy.Compose(&billy.Config{
+		Backend: "mem://statcachefs",
+		Wrappers: []billy.WrapperConfig{{
+			Name:    "statcache",
+			Options: map[string]string{"ttl": "foo"},
+		}},
+	})
+	c.Assert(err, NotNil)
+}
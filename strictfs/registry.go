@@ -0,0 +1,12 @@
+package strictfs
+
+import "srcd.works/go-billy.v1"
+
+func init() {
+	billy.RegisterWrapper("strict", wrap)
+}
+
+// wrap returns a Strict filesystem wrapping fs, it takes no options.
+func wrap(fs billy.Filesystem, opts map[string]string) (billy.Filesystem, error) {
+	return New(fs), nil
+}
@@ -0,0 +1,159 @@
+// Package strictfs provides a billy filesystem wrapper rejecting the calls
+// whose behavior differs between platforms and backends, catching under test
+// the code relying on the leniency of one of them.
+package strictfs // import "srcd.works/go-billy.v1/strictfs"
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+// accessModes are the bits of the flags of OpenFile holding the access mode.
+const accessModes = os.O_RDONLY | os.O_WRONLY | os.O_RDWR
+
+// Strict wraps a billy.Filesystem validating the flags given to OpenFile,
+// the invalid combinations return a *os.PathError with syscall.EINVAL, as
+// some systems do, instead of being ignored or interpreted by the backend:
+//
+//   - both os.O_WRONLY and os.O_RDWR;
+//   - os.O_TRUNC or os.O_APPEND without write access;
+//   - os.O_EXCL without os.O_CREATE.
+type Strict struct {
+	fs billy.Filesystem
+}
+
+// New returns a new Strict filesystem wrapping the given one.
+func New(fs billy.Filesystem) *Strict {
+	return &Strict{fs: fs}
+}
+
+// Create creates the named file, truncating it if it already exists.
+func (fs *Strict) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open opens the named file for reading.
+func (fs *Strict) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file, if the combination of flags is valid.
+func (fs *Strict) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if !ValidFlag(flag) {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EINVAL}
+	}
+
+	return fs.fs.OpenFile(filename, flag, perm)
+}
+
+// ValidFlag returns true if the flags of OpenFile are a valid combination, as
+// described by Strict.
+func ValidFlag(flag int) bool {
+	access := flag & accessModes
+	if access == accessModes {
+		return false
+	}
+
+	if access == os.O_RDONLY && flag&(os.O_TRUNC|os.O_APPEND) != 0 {
+		return false
+	}
+
+	return flag&os.O_EXCL == 0 || flag&os.O_CREATE != 0
+}
+
+// Stat returns the FileInfo structure describing file.
+func (fs *Strict) Stat(filename string) (billy.FileInfo, error) {
+	return fs.fs.Stat(filename)
+}
+
+// ReadDir returns a list of billy.FileInfo in the given directory.
+func (fs *Strict) ReadDir(path string) ([]billy.FileInfo, error) {
+	return fs.fs.ReadDir(path)
+}
+
+// TempFile creates a temporary file.
+func (fs *Strict) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.fs.TempFile(dir, prefix)
+}
+
+// Rename renames a file.
+func (fs *Strict) Rename(from, to string) error {
+	return fs.fs.Rename(from, to)
+}
+
+// Remove removes a file.
+func (fs *Strict) Remove(filename string) error {
+	return fs.fs.Remove(filename)
+}
+
+// Symlink creates a symbolic link.
+func (fs *Strict) Symlink(target, link string) error {
+	return fs.fs.Symlink(target, link)
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Strict) Readlink(link string) (string, error) {
+	return fs.fs.Readlink(link)
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links.
+func (fs *Strict) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.fs.Lstat(filename)
+}
+
+// MkdirAll creates a directory and its parents.
+func (fs *Strict) MkdirAll(path string, perm os.FileMode) error {
+	return fs.fs.MkdirAll(path, perm)
+}
+
+// Chmod changes the mode of a file.
+func (fs *Strict) Chmod(name string, mode os.FileMode) error {
+	return fs.fs.Chmod(name, mode)
+}
+
+// Chtimes changes the times of a file.
+func (fs *Strict) Chtimes(name string, atime, mtime time.Time) error {
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Ping checks the underlying filesystem, as billy.Ping.
+func (fs *Strict) Ping(ctx context.Context) error {
+	_, err := billy.Ping(ctx, fs.fs)
+	return err
+}
+
+// Flush flushes the underlying filesystem, as billy.Flush.
+func (fs *Strict) Flush(ctx context.Context) error {
+	return billy.Flush(ctx, fs.fs)
+}
+
+// MaxLinks returns the maximum number of symbolic links followed resolving a
+// path, the one of the underlying filesystem, as billy.MaxLinks.
+func (fs *Strict) MaxLinks() int {
+	return billy.MaxLinks(fs.fs)
+}
+
+// Close closes the underlying filesystem, as billy.Close.
+func (fs *Strict) Close() error {
+	return billy.Close(fs.fs)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Strict) Join(elem ...string) string {
+	return fs.fs.Join(elem...)
+}
+
+// Dir returns a new Strict filesystem rooted at the given path.
+func (fs *Strict) Dir(path string) billy.Filesystem {
+	return &Strict{fs: fs.fs.Dir(path)}
+}
+
+// Base returns the base path of the underlying filesystem.
+func (fs *Strict) Base() string {
+	return fs.fs.Base()
+}
@@ -0,0 +1,68 @@
+package strictfs
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+	"srcd.works/go-billy.v1/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StrictSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = Suite(&StrictSuite{})
+
+func (s *StrictSuite) SetUpTest(c *C) {
+	s.FilesystemSuite.Fs = New(memory.New())
+}
+
+func (s *StrictSuite) TestInvalidFlags(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	for _, flag := range []int{
+		os.O_WRONLY | os.O_RDWR,
+		os.O_RDONLY | os.O_TRUNC,
+		os.O_RDONLY | os.O_APPEND,
+		os.O_WRONLY | os.O_EXCL,
+		os.O_RDWR | os.O_TRUNC | os.O_EXCL,
+	} {
+		_, err := s.Fs.OpenFile("foo", flag, 0666)
+		c.Assert(err, NotNil, Commentf("flag %#x", flag))
+		c.Assert(err.(*os.PathError).Err, Equals, syscall.EINVAL)
+	}
+
+	_, err = s.Fs.Dir("qux").OpenFile("bar", os.O_RDONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.EINVAL)
+}
+
+func (s *StrictSuite) TestValidFlag(c *C) {
+	for _, flag := range []int{
+		os.O_RDONLY,
+		os.O_RDONLY | os.O_CREATE,
+		os.O_WRONLY | os.O_APPEND,
+		os.O_RDWR | os.O_CREATE | os.O_TRUNC,
+		os.O_WRONLY | os.O_CREATE | os.O_EXCL,
+	} {
+		c.Assert(ValidFlag(flag), Equals, true, Commentf("flag %#x", flag))
+	}
+}
+
+func (s *StrictSuite) TestCompose(c *C) {
+	fs, err := billy.Compose(&billy.Config{
+		Backend:  "mem://strictfs",
+		Wrappers: []billy.WrapperConfig{{Name: "strict"}},
+	})
+	c.Assert(err, IsNil)
+
+	_, err = fs.OpenFile("foo", os.O_RDONLY|os.O_TRUNC, 0)
+	c.Assert(err.(*os.PathError).Err, Equals, syscall.EINVAL)
+}
@@ -0,0 +1,97 @@
+package billy
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// SweepOptions describes the files removed by Sweep, a file must match all the
+// given criteria.
+type SweepOptions struct {
+	// OlderThan, if not zero, matches only the files modified before this
+	// duration ago.
+	OlderThan time.Duration
+	// Pattern, if not empty, matches only the files whose name matches the
+	// pattern, with the syntax of filepath.Match.
+	Pattern string
+	// DryRun reports the matching files without removing them.
+	DryRun bool
+}
+
+// SweepReport describes the files removed by Sweep, or the ones that would be
+// removed on dry-run mode.
+type SweepReport struct {
+	// Files are the paths of the matching files, in lexical order.
+	Files []string
+	// Size is the total size of the matching files.
+	Size int64
+}
+
+// Sweep removes the files under dir matching the given options, directories
+// are not removed. A report of the matching files is returned. The sweep goes
+// on when a file can't be removed or a directory listed, returning a
+// *MultiError with every failure, and the report includes only the files
+// removed.
+func Sweep(fs Filesystem, dir string, opts *SweepOptions) (*SweepReport, error) {
+	if opts == nil {
+		opts = &SweepOptions{}
+	}
+
+	var deadline time.Time
+	if opts.OlderThan != 0 {
+		deadline = time.Now().Add(-opts.OlderThan)
+	}
+
+	r := &SweepReport{}
+	var errs MultiError
+	err := Walk(fs, dir, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			errs.Add("sweep", path, err)
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		ok, err := sweepMatch(info, deadline, opts.Pattern)
+		if err != nil || !ok {
+			return err
+		}
+
+		if !opts.DryRun {
+			if err := fs.Remove(path); err != nil {
+				errs.Add("remove", path, err)
+				return nil
+			}
+		}
+
+		r.Files = append(r.Files, path)
+		r.Size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	return r, errs.Err()
+}
+
+func sweepMatch(info FileInfo, deadline time.Time, pattern string) (bool, error) {
+	if !deadline.IsZero() && !info.ModTime().Before(deadline) {
+		return false, nil
+	}
+
+	if pattern == "" {
+		return true, nil
+	}
+
+	return filepath.Match(pattern, info.Name())
+}
+
+// CleanTemp removes the files under dir not modified in the last olderThan
+// duration, intended to clean the temporary files left behind by crashed
+// processes in a dedicated temporary directory.
+func CleanTemp(fs Filesystem, dir string, olderThan time.Duration) (*SweepReport, error) {
+	return Sweep(fs, dir, &SweepOptions{OlderThan: olderThan})
+}
@@ -0,0 +1,75 @@
+package billy_test
+
+import (
+	"errors"
+	"os"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type SweepSuite struct{}
+
+var _ = Suite(&SweepSuite{})
+
+func (s *SweepSuite) TestSweepPattern(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "tmp/foo.tmp", "foo")
+	writeFile(c, fs, "tmp/qux/bar.tmp", "bar")
+	writeFile(c, fs, "tmp/baz", "baz")
+
+	r, err := billy.Sweep(fs, "tmp", &billy.SweepOptions{Pattern: "*.tmp"})
+	c.Assert(err, IsNil)
+	c.Assert(r.Files, DeepEquals, []string{"tmp/foo.tmp", "tmp/qux/bar.tmp"})
+	c.Assert(r.Size, Equals, int64(6))
+
+	_, err = fs.Stat("tmp/foo.tmp")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = fs.Stat("tmp/baz")
+	c.Assert(err, IsNil)
+}
+
+func (s *SweepSuite) TestSweepDryRun(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "tmp/foo", "foo")
+
+	r, err := billy.Sweep(fs, "tmp", &billy.SweepOptions{DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(r.Files, DeepEquals, []string{"tmp/foo"})
+
+	_, err = fs.Stat("tmp/foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *SweepSuite) TestSweepErrors(c *C) {
+	fs := &failingRemove{Filesystem: memory.New(), fail: map[string]bool{
+		"tmp/bar": true, "tmp/qux/baz": true,
+	}}
+
+	writeFile(c, fs, "tmp/bar", "bar")
+	writeFile(c, fs, "tmp/foo", "foo")
+	writeFile(c, fs, "tmp/qux/baz", "baz")
+
+	r, err := billy.Sweep(fs, "tmp", nil)
+	c.Assert(r.Files, DeepEquals, []string{"tmp/foo"})
+	c.Assert(err, FitsTypeOf, &billy.MultiError{})
+	c.Assert(err.(*billy.MultiError).Errors, HasLen, 2)
+	c.Assert(err, ErrorMatches, "2 errors: remove tmp/bar: remove failed; remove tmp/qux/baz: remove failed")
+}
+
+var errRemove = errors.New("remove failed")
+
+// failingRemove fails removing the given paths.
+type failingRemove struct {
+	billy.Filesystem
+	fail map[string]bool
+}
+
+func (fs *failingRemove) Remove(filename string) error {
+	if fs.fail[filename] {
+		return errRemove
+	}
+
+	return fs.Filesystem.Remove(filename)
+}
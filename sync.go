@@ -0,0 +1,400 @@
+package billy
+
+import (
+	"crypto/sha1"
+	"io"
+	"os"
+	"sort"
+)
+
+// SyncOptions describes how Sync updates the destination.
+type SyncOptions struct {
+	// Checksum compares the content of the files, instead of their size and
+	// modification time, to decide if they changed.
+	Checksum bool
+	// DetectRenames renames the files to be removed from the destination
+	// whose content matches a new file from the source, instead of removing
+	// them and copying the new one. With State, the files keeping their
+	// identity in the source are renamed without reading their content.
+	DetectRenames bool
+	// PreserveTimes restores the modification times of the copied files, so
+	// they are considered unchanged in the next Sync without Checksum.
+	PreserveTimes bool
+	// MaxPathLength is the maximum length allowed for a path in the
+	// destination, as in CopyOptions. The new paths are checked before
+	// changing anything, Sync fails with a *PathLengthError if any exceeds
+	// it.
+	MaxPathLength int
+	// State, if not nil, records the identity of the synced files, when
+	// both filesystems implement Identity. It's updated by every Sync given
+	// it, and can be persisted in between.
+	State *SyncState
+}
+
+// SyncState is the identity of the files synced by the previous Sync, so the
+// renames in the source can be detected by the FileIDs instead of comparing
+// the content of the files.
+type SyncState struct {
+	// IDs are the FileIDs of the synced files by path, in the source and in
+	// the destination.
+	IDs map[string][2]string `json:"ids"`
+}
+
+// Sync makes the files in dst equal to the ones in src, copying the new and
+// changed files and removing the ones not present in src. The symbolic links
+// are copied as links, and considered changed when their target differs. If
+// opts is nil the default options are used.
+func Sync(dst, src Filesystem, opts *SyncOptions) error {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	srcFiles, err := listFiles(src)
+	if err != nil {
+		return err
+	}
+
+	dstFiles, err := listFiles(dst)
+	if err != nil {
+		return err
+	}
+
+	var added, changed, removed []string
+	for path, info := range srcFiles {
+		dinfo, ok := dstFiles[path]
+		if !ok {
+			added = append(added, path)
+			continue
+		}
+
+		equal, err := syncEqual(dst, src, path, dinfo, info, opts)
+		if err != nil {
+			return err
+		}
+
+		if !equal {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range dstFiles {
+		if _, ok := srcFiles[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	if _, err := destinationPaths(dst, added, &CopyOptions{MaxPathLength: opts.MaxPathLength}); err != nil {
+		return err
+	}
+
+	if opts.DetectRenames {
+		var modified []string
+		added, removed, modified, err = syncRenames(dst, src, added, removed, srcFiles, dstFiles, opts)
+		if err != nil {
+			return err
+		}
+
+		changed = append(changed, modified...)
+	}
+
+	for _, path := range append(added, changed...) {
+		if isSymlink(srcFiles[path]) {
+			if err := copySymlink(dst, path, src, path); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := CopyFile(dst, path, src, path); err != nil {
+			return err
+		}
+
+		if opts.PreserveTimes {
+			if err := copyTimes(dst, path, srcFiles[path]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range removed {
+		if err := dst.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if opts.State == nil {
+		return nil
+	}
+
+	return syncState(dst, src, srcFiles, opts.State)
+}
+
+// syncState records in state the FileIDs of the files synced, if both
+// filesystems implement Identity. The symbolic links are not recorded.
+func syncState(dst, src Filesystem, srcFiles map[string]FileInfo, state *SyncState) error {
+	state.IDs = nil
+	si, ok := src.(Identity)
+	di, dok := dst.(Identity)
+	if !ok || !dok {
+		return nil
+	}
+
+	ids := make(map[string][2]string, len(srcFiles))
+	for path, info := range srcFiles {
+		if isSymlink(info) {
+			continue
+		}
+
+		sid, err := si.FileID(path)
+		if err != nil {
+			return err
+		}
+
+		did, err := di.FileID(path)
+		if err != nil {
+			return err
+		}
+
+		ids[path] = [2]string{sid, did}
+	}
+
+	state.IDs = ids
+	return nil
+}
+
+func listFiles(fs Filesystem) (map[string]FileInfo, error) {
+	files := make(map[string]FileInfo)
+	err := Walk(fs, "", func(path string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			files[path] = info
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+func syncEqual(dst, src Filesystem, path string, dinfo, sinfo FileInfo, opts *SyncOptions) (bool, error) {
+	if dinfo.Size() != sinfo.Size() || isSymlink(dinfo) != isSymlink(sinfo) {
+		return false, nil
+	}
+
+	if isSymlink(sinfo) {
+		return symlinkEqual(dst, src, path)
+	}
+
+	if !opts.Checksum {
+		return dinfo.ModTime().Equal(sinfo.ModTime()), nil
+	}
+
+	dh, err := hashFile(dst, path)
+	if err != nil {
+		return false, err
+	}
+
+	sh, err := hashFile(src, path)
+	if err != nil {
+		return false, err
+	}
+
+	return dh == sh, nil
+}
+
+func symlinkEqual(dst, src Filesystem, path string) (bool, error) {
+	dt, err := dst.Readlink(path)
+	if err != nil {
+		return false, err
+	}
+
+	st, err := src.Readlink(path)
+	if err != nil {
+		return false, err
+	}
+
+	return dt == st, nil
+}
+
+func isSymlink(fi FileInfo) bool {
+	return fi.Mode()&os.ModeSymlink != 0
+}
+
+// syncRenames renames in dst the removed files matching an added one,
+// returning the added and removed files still pending, and the renamed ones
+// whose content changed. The symbolic links are not considered.
+func syncRenames(
+	dst, src Filesystem, added, removed []string, srcFiles, dstFiles map[string]FileInfo, opts *SyncOptions,
+) (pending, remaining, modified []string, err error) {
+	moved, err := syncMoved(dst, src, removed, opts.State)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	candidates := make(map[int64][]string)
+	for _, path := range removed {
+		if isSymlink(dstFiles[path]) {
+			continue
+		}
+
+		size := dstFiles[path].Size()
+		candidates[size] = append(candidates[size], path)
+	}
+
+	hashes := make(map[string]string)
+	renamed := make(map[string]bool)
+	for _, path := range added {
+		if isSymlink(srcFiles[path]) {
+			pending = append(pending, path)
+			continue
+		}
+
+		from, err := syncFindMoved(src, path, moved, renamed)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// the files matched by their identity may have been modified too,
+		// unlike the ones matched by their content.
+		byID := from != ""
+		if !byID {
+			from, err = syncFindRename(dst, src, path, candidates[srcFiles[path].Size()], hashes, renamed)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+
+		if from == "" {
+			pending = append(pending, path)
+			continue
+		}
+
+		if err := dst.Rename(from, path); err != nil {
+			return nil, nil, nil, err
+		}
+
+		renamed[from] = true
+		if !byID {
+			continue
+		}
+
+		equal, err := syncEqual(dst, src, path, dstFiles[from], srcFiles[path], opts)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if !equal {
+			modified = append(modified, path)
+		}
+	}
+
+	for _, path := range removed {
+		if !renamed[path] {
+			remaining = append(remaining, path)
+		}
+	}
+
+	return pending, remaining, modified, nil
+}
+
+// syncMoved returns the removed files, by the FileID in the source recorded
+// in state, not changed in dst since. It returns nil if state is nil or any
+// of the filesystems doesn't implement Identity.
+func syncMoved(dst, src Filesystem, removed []string, state *SyncState) (map[string]string, error) {
+	_, ok := src.(Identity)
+	di, dok := dst.(Identity)
+	if state == nil || !ok || !dok {
+		return nil, nil
+	}
+
+	moved := make(map[string]string)
+	for _, path := range removed {
+		ids, ok := state.IDs[path]
+		if !ok {
+			continue
+		}
+
+		id, err := di.FileID(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if id == ids[1] {
+			moved[ids[0]] = path
+		}
+	}
+
+	return moved, nil
+}
+
+// syncFindMoved returns the removed file with the identity of path in the
+// source, as recorded in the previous Sync.
+func syncFindMoved(src Filesystem, path string, moved map[string]string, renamed map[string]bool) (string, error) {
+	if len(moved) == 0 {
+		return "", nil
+	}
+
+	id, err := src.(Identity).FileID(path)
+	if err != nil {
+		return "", err
+	}
+
+	if from, ok := moved[id]; ok && !renamed[from] {
+		return from, nil
+	}
+
+	return "", nil
+}
+
+func syncFindRename(
+	dst, src Filesystem, path string, candidates []string, hashes map[string]string, renamed map[string]bool,
+) (string, error) {
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	h, err := hashFile(src, path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range candidates {
+		if renamed[c] {
+			continue
+		}
+
+		if _, ok := hashes[c]; !ok {
+			if hashes[c], err = hashFile(dst, c); err != nil {
+				return "", err
+			}
+		}
+
+		if hashes[c] == h {
+			return c, nil
+		}
+	}
+
+	return "", nil
+}
+
+func hashFile(fs Filesystem, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return string(h.Sum(nil)), nil
+}
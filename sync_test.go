@@ -0,0 +1,108 @@
+package billy_test
+
+import (
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type SyncSuite struct{}
+
+var _ = Suite(&SyncSuite{})
+
+func (s *SyncSuite) TestSync(c *C) {
+	src := memory.New()
+	writeFile(c, src, "foo", "foo")
+	writeFile(c, src, "qux/bar", "bar")
+
+	dst := memory.New()
+	writeFile(c, dst, "foo", "old")
+	writeFile(c, dst, "baz", "baz")
+
+	c.Assert(billy.Sync(dst, src, &billy.SyncOptions{Checksum: true}), IsNil)
+
+	c.Assert(readFile(c, dst, "foo"), Equals, "foo")
+	c.Assert(readFile(c, dst, "qux/bar"), Equals, "bar")
+	_, err := dst.Stat("baz")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *SyncSuite) TestSyncDetectRenames(c *C) {
+	src := memory.New()
+	writeFile(c, src, "qux/moved", "large content")
+	writeFile(c, src, "new", "new content")
+
+	dst := memory.New()
+	writeFile(c, dst, "original", "large content")
+	writeFile(c, dst, "other", "other content")
+	id, err := dst.FileID("original")
+	c.Assert(err, IsNil)
+
+	err = billy.Sync(dst, src, &billy.SyncOptions{DetectRenames: true})
+	c.Assert(err, IsNil)
+
+	moved, err := dst.FileID("qux/moved")
+	c.Assert(err, IsNil)
+	c.Assert(moved, Equals, id)
+	c.Assert(readFile(c, dst, "new"), Equals, "new content")
+
+	for _, name := range []string{"original", "other"} {
+		_, err = dst.Stat(name)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+}
+
+func (s *SyncSuite) TestSyncStateRenames(c *C) {
+	src := memory.New()
+	writeFile(c, src, "foo", "foo")
+	writeFile(c, src, "bar", "bar")
+
+	dst := memory.New()
+	state := &billy.SyncState{}
+	opts := &billy.SyncOptions{DetectRenames: true, State: state}
+	c.Assert(billy.Sync(dst, src, opts), IsNil)
+	c.Assert(state.IDs, HasLen, 2)
+
+	id, err := dst.FileID("foo")
+	c.Assert(err, IsNil)
+
+	c.Assert(src.Rename("foo", "qux/foo"), IsNil)
+	f, err := src.OpenFile("qux/foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(billy.Sync(dst, src, opts), IsNil)
+
+	moved, err := dst.FileID("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(moved, Equals, id)
+	c.Assert(readFile(c, dst, "qux/foo"), Equals, "foobar")
+	_, err = dst.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(state.IDs, HasLen, 2)
+	c.Assert(state.IDs["qux/foo"][1], Equals, id)
+}
+
+func (s *SyncSuite) TestSyncMaxPathLength(c *C) {
+	long := strings.Repeat("a", 20)
+	src := memory.New()
+	writeFile(c, src, "foo", "foo")
+	writeFile(c, src, "qux/"+long, "bar")
+
+	dst := memory.New()
+	writeFile(c, dst, "bar", "bar")
+
+	err := billy.Sync(dst, src, &billy.SyncOptions{MaxPathLength: 16})
+	c.Assert(err, FitsTypeOf, &billy.PathLengthError{})
+	c.Assert(err.(*billy.PathLengthError).Paths, DeepEquals, []string{"qux/" + long})
+
+	files, err := dst.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+	c.Assert(files[0].Name(), Equals, "bar")
+}
@@ -0,0 +1,239 @@
+package tarfs
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"srcd.works/go-billy.v1"
+)
+
+var (
+	errNegativeOffset = errors.New("negative offset")
+	errInvalidWhence  = errors.New("invalid whence")
+)
+
+// content is the content of a file in the archive.
+type content interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+// file is a file of the archive open for reading.
+type file struct {
+	billy.BaseFile
+	e *entry
+	c content
+}
+
+func newFile(fs *Tar, filename string, e *entry) *file {
+	f := &file{BaseFile: billy.BaseFile{BaseFilename: filename}, e: e}
+	if fs.gzip {
+		f.c = &gzipContent{fs: fs, offset: e.offset, size: e.size}
+	} else {
+		f.c = nopCloser{io.NewSectionReader(fs.r, e.offset, e.size)}
+	}
+
+	return f
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	return f.c.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: f.Filename(), Err: errNegativeOffset}
+	}
+
+	return f.c.ReadAt(p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.IsClosed() {
+		return 0, billy.ErrClosed
+	}
+
+	pos, err := f.c.Seek(offset, whence)
+	if err != nil {
+		return 0, &os.PathError{Op: "seek", Path: f.Filename(), Err: err}
+	}
+
+	return pos, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, billy.ErrReadOnly
+}
+
+func (f *file) Truncate(size int64) error {
+	return billy.ErrReadOnly
+}
+
+func (f *file) Stat() (billy.FileInfo, error) {
+	return f.e, nil
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+// Lock returns billy.ErrNotSupported, the archives have no locks.
+func (f *file) Lock() error {
+	return billy.ErrNotSupported
+}
+
+// Unlock returns billy.ErrNotSupported, the archives have no locks.
+func (f *file) Unlock() error {
+	return billy.ErrNotSupported
+}
+
+func (f *file) Close() error {
+	if f.IsClosed() {
+		return billy.ErrClosed
+	}
+
+	f.Closed = true
+	return f.c.Close()
+}
+
+type nopCloser struct {
+	*io.SectionReader
+}
+
+func (nopCloser) Close() error { return nil }
+
+// gzipContent is the content of a file of a compressed archive, read
+// decompressing the archive from its start, skipping the content before the
+// file. The stream is kept while the reads go forward, and restarted when
+// they go backwards.
+type gzipContent struct {
+	fs     *Tar
+	offset int64
+	size   int64
+
+	m   sync.Mutex
+	pos int64
+	z   *gzip.Reader
+	// zpos is the offset in the file of the next byte of z.
+	zpos int64
+}
+
+func (c *gzipContent) Read(p []byte) (int, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	n, err := c.readAt(p, c.pos)
+	c.pos += int64(n)
+	return n, err
+}
+
+func (c *gzipContent) ReadAt(p []byte, off int64) (int, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	n, err := c.readAt(p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// readAt reads from off up to the end of the file, the lock must be held.
+func (c *gzipContent) readAt(p []byte, off int64) (int, error) {
+	if off >= c.size {
+		return 0, io.EOF
+	}
+
+	if err := c.skipTo(off); err != nil {
+		return 0, err
+	}
+
+	if rest := c.size - off; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+
+	n, err := io.ReadFull(c.z, p)
+	c.zpos += int64(n)
+	if err == io.EOF {
+		// the archive is truncated.
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// skipTo positions the stream at the offset off of the file, restarting it
+// if it's already past it.
+func (c *gzipContent) skipTo(off int64) error {
+	if c.z != nil && c.zpos > off {
+		c.z.Close()
+		c.z = nil
+	}
+
+	if c.z == nil {
+		z, err := gzip.NewReader(io.NewSectionReader(c.fs.r, 0, c.fs.size))
+		if err != nil {
+			return err
+		}
+
+		c.z, c.zpos = z, -c.offset
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, c.z, off-c.zpos); err != nil {
+		c.z.Close()
+		c.z = nil
+		return err
+	}
+
+	c.zpos = off
+	return nil
+}
+
+func (c *gzipContent) Seek(offset int64, whence int) (int64, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.pos
+	case io.SeekEnd:
+		offset += c.size
+	default:
+		return 0, errInvalidWhence
+	}
+
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+
+	c.pos = offset
+	return offset, nil
+}
+
+func (c *gzipContent) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.z == nil {
+		return nil
+	}
+
+	err := c.z.Close()
+	c.z = nil
+	return err
+}
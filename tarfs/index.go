@@ -0,0 +1,162 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// entry is the entry of a path in the index, and its FileInfo.
+type entry struct {
+	name    string
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+	// offset is the offset of the content of a regular file in the archive,
+	// once decompressed.
+	offset int64
+	// target is the target of a symbolic link.
+	target string
+	// sparse is true for the sparse files, whose content isn't stored as is,
+	// so it can't be read from its offset.
+	sparse bool
+}
+
+func (e *entry) Name() string       { return e.name }
+func (e *entry) Size() int64        { return e.size }
+func (e *entry) Mode() os.FileMode  { return e.mode }
+func (e *entry) ModTime() time.Time { return e.modTime }
+func (e *entry) IsDir() bool        { return e.mode.IsDir() }
+func (e *entry) Sys() interface{}   { return nil }
+
+// rename returns a copy of e with the given name.
+func (e *entry) rename(name string) *entry {
+	c := *e
+	c.name = name
+	return &c
+}
+
+// index holds the entries of an archive by their cleaned path, relative to
+// the root of the archive, which is "".
+type index struct {
+	entries  map[string]*entry
+	children map[string][]string
+}
+
+// offsetFunc returns the offset of the stream read by a tar.Reader, once
+// decompressed.
+type offsetFunc func() (int64, error)
+
+// buildIndex reads the headers of the archive read by tr, skipping the
+// content of the files. The later entries replace the earlier ones with the
+// same path, as when extracting the archive, and the directories missing from
+// the archive are added.
+func buildIndex(tr *tar.Reader, offset offsetFunc) (*index, error) {
+	idx := &index{
+		entries:  map[string]*entry{"": {name: "/", mode: os.ModeDir | defaultDirMode}},
+		children: make(map[string][]string),
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		off, err := offset()
+		if err != nil {
+			return nil, err
+		}
+
+		key := clean(hdr.Name)
+		e := &entry{
+			name:    path.Base(key),
+			mode:    hdr.FileInfo().Mode(),
+			size:    hdr.Size,
+			modTime: hdr.ModTime,
+			offset:  off,
+			sparse:  isSparse(hdr),
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			e.size, e.target = int64(len(hdr.Linkname)), hdr.Linkname
+		case tar.TypeLink:
+			target, ok := idx.entries[clean(hdr.Linkname)]
+			if !ok || !target.mode.IsRegular() {
+				continue
+			}
+
+			e = target.rename(e.name)
+		case tar.TypeDir:
+			e.size = 0
+		}
+
+		if key == "" {
+			e.name = "/"
+			if !e.mode.IsDir() {
+				continue
+			}
+		}
+
+		idx.add(key, e)
+	}
+
+	for _, names := range idx.children {
+		sort.Strings(names)
+	}
+
+	return idx, nil
+}
+
+// add sets the entry of key, adding its missing parents.
+func (idx *index) add(key string, e *entry) {
+	if _, ok := idx.entries[key]; !ok && key != "" {
+		p := parent(key)
+		if _, ok := idx.entries[p]; !ok {
+			idx.add(p, &entry{name: path.Base(p), mode: os.ModeDir | defaultDirMode})
+		}
+
+		idx.children[p] = append(idx.children[p], e.name)
+	}
+
+	idx.entries[key] = e
+}
+
+// isSparse returns true if hdr is the header of a sparse file, in the GNU
+// format or in its PAX variants.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clean returns the key of the name of an entry, relative to the root, the
+// names of the archives are often prefixed by "./" or "/".
+func clean(name string) string {
+	return strings.Trim(path.Clean("/"+strings.Replace(name, `\`, "/", -1)), "/")
+}
+
+func parent(key string) string {
+	if p := path.Dir(key); p != "." {
+		return p
+	}
+
+	return ""
+}
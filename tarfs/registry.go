@@ -0,0 +1,38 @@
+package tarfs
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"srcd.works/go-billy.v1"
+)
+
+func init() {
+	billy.Register("tar", open)
+}
+
+// open creates a Tar filesystem from a tar URI, such as
+// tar:///srv/backup.tar.gz, over an archive of the local filesystem. The
+// archive is kept open until the filesystem is closed.
+func open(u *url.URL) (billy.Filesystem, error) {
+	f, err := os.Open(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	fs, err := New(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	fs.c = f
+	return fs, nil
+}
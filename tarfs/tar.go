@@ -0,0 +1,345 @@
+// Package tarfs provides a read-only billy filesystem over a tar archive,
+// optionally compressed with gzip. The headers of the archive are read once,
+// building an index in memory, and the files are read from their offsets in
+// the archive, so large archives can be browsed without extracting them.
+package tarfs // import "srcd.works/go-billy.v1/tarfs"
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"srcd.works/go-billy.v1"
+)
+
+const defaultDirMode = 0755
+
+var (
+	errIsDirectory  = errors.New("is a directory")
+	errNotDirectory = errors.New("not a directory")
+	errNotLink      = errors.New("not a symbolic link")
+)
+
+// gzipMagic are the first bytes of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Tar is a read-only filesystem over a tar archive, any operation writing
+// to it returns billy.ErrReadOnly. The symbolic links are followed, the
+// absolute targets are relative to the root of the archive, and the hard
+// links are files with the content of their targets. The sparse files are
+// listed but can't be opened, returning billy.ErrNotSupported.
+//
+// The files of an uncompressed archive are read directly from their
+// offsets, the ones of a compressed archive are decompressed from the start
+// of the archive, skipping the content before them, so seeking backwards is
+// expensive. It's safe for concurrent use, as long as the archive is.
+type Tar struct {
+	r    io.ReaderAt
+	size int64
+	gzip bool
+	idx  *index
+	// base is the key of the root of the filesystem.
+	base string
+	// c closes the archive, if opened by the filesystem.
+	c    io.Closer
+	opts Options
+}
+
+// Options holds the configuration of a Tar filesystem.
+type Options struct {
+	// MaxLinks, if greater than zero, is the maximum number of symbolic
+	// links followed while resolving a path, billy.DefaultMaxLinks by
+	// default. Exceeding it fails with billy.ErrTooManyLinks.
+	MaxLinks int
+}
+
+// New returns a new Tar filesystem over the archive of the given size read
+// from r, detecting if it's compressed with gzip, after reading all its
+// headers.
+func New(r io.ReaderAt, size int64) (*Tar, error) {
+	return NewWithOptions(r, size, Options{})
+}
+
+// NewWithOptions returns a new Tar filesystem as New, configured with the
+// given options.
+func NewWithOptions(r io.ReaderAt, size int64, opts Options) (*Tar, error) {
+	fs := &Tar{r: r, size: size, opts: opts}
+
+	magic := make([]byte, len(gzipMagic))
+	if n, _ := r.ReadAt(magic, 0); n == len(magic) && bytes.Equal(magic, gzipMagic) {
+		fs.gzip = true
+	}
+
+	sr := io.NewSectionReader(r, 0, size)
+	var tr *tar.Reader
+	var offset offsetFunc
+	if fs.gzip {
+		z, err := gzip.NewReader(sr)
+		if err != nil {
+			return nil, err
+		}
+
+		defer z.Close()
+
+		cr := &countingReader{r: z}
+		tr = tar.NewReader(cr)
+		offset = func() (int64, error) { return cr.n, nil }
+	} else {
+		// the section reader is seeked by tar.Reader to skip the content of
+		// the files.
+		tr = tar.NewReader(sr)
+		offset = func() (int64, error) { return sr.Seek(0, io.SeekCurrent) }
+	}
+
+	idx, err := buildIndex(tr, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.idx = idx
+	return fs, nil
+}
+
+// Create returns billy.ErrReadOnly.
+func (fs *Tar) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// Open opens the named file for reading.
+func (fs *Tar) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named regular file for reading, returns
+// billy.ErrReadOnly if flag requests any kind of write access.
+func (fs *Tar) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if isWrite(flag) {
+		return nil, billy.ErrReadOnly
+	}
+
+	e, err := fs.lookup("open", filename, true)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case e.IsDir():
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDirectory}
+	case !e.mode.IsRegular():
+		return nil, &os.PathError{Op: "open", Path: filename, Err: billy.ErrSpecialFile}
+	case e.sparse:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: billy.ErrNotSupported}
+	}
+
+	return newFile(fs, filename, e.rename(path.Base(clean(filename)))), nil
+}
+
+// Stat returns the FileInfo of the named file, following the symbolic links.
+func (fs *Tar) Stat(filename string) (billy.FileInfo, error) {
+	e, err := fs.lookup("stat", filename, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := clean(filename); key != "" {
+		return e.rename(path.Base(key)), nil
+	}
+
+	return e, nil
+}
+
+// Lstat returns the FileInfo of the named file, if it's a symbolic link the
+// link itself is described.
+func (fs *Tar) Lstat(filename string) (billy.FileInfo, error) {
+	return fs.lookup("lstat", filename, false)
+}
+
+// ReadDir returns the FileInfo of the files in the given directory, sorted
+// by name, following the symbolic links to the directory.
+func (fs *Tar) ReadDir(dir string) ([]billy.FileInfo, error) {
+	e, err := fs.lookup("readdir", dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if !e.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: errNotDirectory}
+	}
+
+	key, _ := fs.resolve(fs.key(dir), true)
+	names := fs.idx.children[key]
+	l := make([]billy.FileInfo, len(names))
+	for i, name := range names {
+		l[i] = fs.idx.entries[path.Join(key, name)]
+	}
+
+	return l, nil
+}
+
+// TempFile returns billy.ErrReadOnly.
+func (fs *Tar) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// Rename returns billy.ErrReadOnly.
+func (fs *Tar) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+// Remove returns billy.ErrReadOnly.
+func (fs *Tar) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+// Symlink returns billy.ErrReadOnly.
+func (fs *Tar) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+// Readlink returns the target of the named symbolic link.
+func (fs *Tar) Readlink(link string) (string, error) {
+	e, err := fs.lookup("readlink", link, false)
+	if err != nil {
+		return "", err
+	}
+
+	if e.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: errNotLink}
+	}
+
+	return e.target, nil
+}
+
+// MkdirAll returns billy.ErrReadOnly.
+func (fs *Tar) MkdirAll(path string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Chmod returns billy.ErrReadOnly.
+func (fs *Tar) Chmod(name string, mode os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Chtimes returns billy.ErrReadOnly.
+func (fs *Tar) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return billy.ErrReadOnly
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *Tar) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Dir returns a new Tar filesystem rooted at the given path, sharing the
+// index with fs. The path is rooted at the base of fs, so the ".." elements
+// can't go above it.
+func (fs *Tar) Dir(p string) billy.Filesystem {
+	return &Tar{r: fs.r, size: fs.size, gzip: fs.gzip, idx: fs.idx, base: fs.key(p), c: fs.c, opts: fs.opts}
+}
+
+// MaxLinks returns the maximum number of symbolic links followed while
+// resolving a path, as set in the options.
+func (fs *Tar) MaxLinks() int {
+	if fs.opts.MaxLinks > 0 {
+		return fs.opts.MaxLinks
+	}
+
+	return billy.DefaultMaxLinks
+}
+
+// Base returns the path of the root of the filesystem in the archive.
+func (fs *Tar) Base() string {
+	return path.Join("/", fs.base)
+}
+
+// Close closes the archive if it was opened by the filesystem, as the ones
+// opened from a tar URI, the readers given to New are owned by the caller.
+func (fs *Tar) Close() error {
+	if fs.c == nil {
+		return nil
+	}
+
+	return fs.c.Close()
+}
+
+// lookup returns the entry of the named file, following its symbolic link
+// if follow is true, the errors are *os.PathError for the given operation.
+func (fs *Tar) lookup(op, filename string, follow bool) (*entry, error) {
+	key, err := fs.resolve(fs.key(filename), follow)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: filename, Err: err}
+	}
+
+	e, ok := fs.idx.entries[key]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: filename, Err: os.ErrNotExist}
+	}
+
+	return e, nil
+}
+
+// resolve returns the key of the entry reached by key, following the
+// symbolic links of its parents, and of key itself if follow is true.
+func (fs *Tar) resolve(key string, follow bool) (string, error) {
+	parts := split(key)
+	var resolved string
+	for hops := 0; len(parts) != 0; {
+		p := path.Join(resolved, parts[0])
+		parts = parts[1:]
+
+		e, ok := fs.idx.entries[p]
+		if !ok || e.mode&os.ModeSymlink == 0 || (len(parts) == 0 && !follow) {
+			resolved = p
+			continue
+		}
+
+		if hops++; hops > fs.MaxLinks() {
+			return "", billy.ErrTooManyLinks
+		}
+
+		target := e.target
+		if !path.IsAbs(target) {
+			target = path.Join(parent(p), target)
+		}
+
+		resolved = ""
+		parts = append(split(clean(target)), parts...)
+	}
+
+	return clean(resolved), nil
+}
+
+// key returns the key of the given filename, relative to the base.
+func (fs *Tar) key(filename string) string {
+	return clean(path.Join(fs.base, clean(filename)))
+}
+
+func split(key string) []string {
+	if key == "" {
+		return nil
+	}
+
+	return strings.Split(key, "/")
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,276 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type TarSuite struct {
+	gzip bool
+	data []byte
+}
+
+var _ = Suite(&TarSuite{})
+var _ = Suite(&TarSuite{gzip: true})
+
+var mtime = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func (s *TarSuite) SetUpTest(c *C) {
+	s.data = s.archive(c, []*tar.Header{
+		{Name: "./qux/", Typeflag: tar.TypeDir, Mode: 0700, ModTime: mtime},
+		{Name: "./qux/foo", Typeflag: tar.TypeReg, Mode: 0644, Size: 3, ModTime: mtime},
+		{Name: "./qux/bar", Typeflag: tar.TypeReg, Mode: 0600, Size: 3000, ModTime: mtime},
+		{Name: "baz/qux/deep", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		{Name: "./qux/foo", Typeflag: tar.TypeReg, Mode: 0644, Size: 6, ModTime: mtime},
+		{Name: "rel", Typeflag: tar.TypeSymlink, Linkname: "qux/foo"},
+		{Name: "baz/up", Typeflag: tar.TypeSymlink, Linkname: "../qux"},
+		{Name: "baz/abs", Typeflag: tar.TypeSymlink, Linkname: "/qux/bar"},
+		{Name: "loop", Typeflag: tar.TypeSymlink, Linkname: "loop"},
+		{Name: "hard", Typeflag: tar.TypeLink, Linkname: "./qux/bar"},
+		{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644},
+	})
+}
+
+// archive returns an archive with the given headers, the content of the
+// regular files is their name repeated up to their size.
+func (s *TarSuite) archive(c *C, headers []*tar.Header) []byte {
+	buf := bytes.NewBuffer(nil)
+	var w io.WriteCloser = nopWriteCloser{buf}
+	if s.gzip {
+		w = gzip.NewWriter(buf)
+	}
+
+	tw := tar.NewWriter(w)
+	for _, hdr := range headers {
+		c.Assert(tw.WriteHeader(hdr), IsNil)
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write(fileContent(hdr.Name, hdr.Size))
+			c.Assert(err, IsNil)
+		}
+	}
+
+	c.Assert(tw.Close(), IsNil)
+	c.Assert(w.Close(), IsNil)
+	return buf.Bytes()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func fileContent(name string, size int64) []byte {
+	return []byte(strings.Repeat(name, int(size)/len(name)+1)[:size])
+}
+
+func (s *TarSuite) newTar(c *C) *Tar {
+	fs, err := New(bytes.NewReader(s.data), int64(len(s.data)))
+	c.Assert(err, IsNil)
+	c.Assert(fs.gzip, Equals, s.gzip)
+	return fs
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) []byte {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return data
+}
+
+func readDirNames(c *C, fs billy.Filesystem, dir string) []string {
+	infos, err := fs.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
+
+func (s *TarSuite) TestReadDir(c *C) {
+	fs := s.newTar(c)
+	c.Assert(readDirNames(c, fs, ""), DeepEquals, []string{"baz", "fifo", "hard", "loop", "qux", "rel"})
+	c.Assert(readDirNames(c, fs, "/qux"), DeepEquals, []string{"bar", "foo"})
+	c.Assert(readDirNames(c, fs, "baz"), DeepEquals, []string{"abs", "qux", "up"})
+	c.Assert(readDirNames(c, fs, "baz/up"), DeepEquals, []string{"bar", "foo"})
+
+	_, err := fs.ReadDir("qux/foo")
+	c.Assert(err, NotNil)
+	_, err = fs.ReadDir("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *TarSuite) TestStat(c *C) {
+	fs := s.newTar(c)
+	fi, err := fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.ModeDir|0700)
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+	fi, err = fs.Stat("baz")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.ModeDir|0755)
+
+	fi, err = fs.Stat("qux/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(6))
+
+	fi, err = fs.Stat("rel")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "rel")
+	c.Assert(fi.Size(), Equals, int64(6))
+
+	fi, err = fs.Lstat("rel")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Not(Equals), os.FileMode(0))
+
+	fi, err = fs.Stat("fifo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeNamedPipe, Not(Equals), os.FileMode(0))
+
+	_, err = fs.Stat("loop")
+	c.Assert(err, NotNil)
+	c.Assert(err.(*os.PathError).Err, Equals, billy.ErrTooManyLinks)
+
+	_, err = fs.Stat("qux/missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *TarSuite) TestRead(c *C) {
+	fs := s.newTar(c)
+	c.Assert(readFile(c, fs, "qux/foo"), DeepEquals, fileContent("./qux/foo", 6))
+	c.Assert(readFile(c, fs, "baz/qux/deep"), DeepEquals, fileContent("baz/qux/deep", 4))
+	c.Assert(readFile(c, fs, "qux/bar"), DeepEquals, fileContent("./qux/bar", 3000))
+
+	f, err := fs.Open("qux/bar")
+	c.Assert(err, IsNil)
+	fi, err := f.Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3000))
+
+	expected := fileContent("./qux/bar", 3000)
+	buf := make([]byte, 10)
+	for _, off := range []int64{2000, 10, 2995} {
+		pos, err := f.Seek(off, io.SeekStart)
+		c.Assert(err, IsNil)
+		c.Assert(pos, Equals, off)
+
+		n, err := f.Read(buf)
+		c.Assert(err, IsNil)
+		c.Assert(buf[:n], DeepEquals, expected[off:off+int64(n)])
+	}
+
+	_, err = f.Read(buf)
+	c.Assert(err, Equals, io.EOF)
+
+	n, err := f.(io.ReaderAt).ReadAt(buf, 5)
+	c.Assert(err, IsNil)
+	c.Assert(buf[:n], DeepEquals, expected[5:15])
+	n, err = f.(io.ReaderAt).ReadAt(buf, 2996)
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(buf[:n], DeepEquals, expected[2996:])
+
+	c.Assert(f.Close(), IsNil)
+	_, err = f.Read(buf)
+	c.Assert(err, Equals, billy.ErrClosed)
+}
+
+func (s *TarSuite) TestLinks(c *C) {
+	fs := s.newTar(c)
+	c.Assert(readFile(c, fs, "rel"), DeepEquals, fileContent("./qux/foo", 6))
+	c.Assert(readFile(c, fs, "baz/up/foo"), DeepEquals, fileContent("./qux/foo", 6))
+	c.Assert(readFile(c, fs, "baz/abs"), DeepEquals, fileContent("./qux/bar", 3000))
+	c.Assert(readFile(c, fs, "hard"), DeepEquals, fileContent("./qux/bar", 3000))
+
+	target, err := fs.Readlink("baz/up")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "../qux")
+	_, err = fs.Readlink("hard")
+	c.Assert(err, NotNil)
+}
+
+func (s *TarSuite) TestOpenErrors(c *C) {
+	fs := s.newTar(c)
+	_, err := fs.Open("qux")
+	c.Assert(err, NotNil)
+	_, err = fs.Open("fifo")
+	c.Assert(err.(*os.PathError).Err, Equals, billy.ErrSpecialFile)
+	_, err = fs.Open("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *TarSuite) TestReadOnly(c *C) {
+	fs := s.newTar(c)
+	_, err := fs.Create("foo")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	_, err = fs.OpenFile("qux/foo", os.O_RDWR, 0)
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	_, err = fs.TempFile("", "foo")
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	c.Assert(fs.Rename("qux/foo", "foo"), Equals, billy.ErrReadOnly)
+	c.Assert(fs.Remove("qux/foo"), Equals, billy.ErrReadOnly)
+	c.Assert(fs.MkdirAll("foo", 0755), Equals, billy.ErrReadOnly)
+	c.Assert(fs.Symlink("qux", "foo"), Equals, billy.ErrReadOnly)
+	c.Assert(fs.Chmod("qux/foo", 0600), Equals, billy.ErrReadOnly)
+	c.Assert(fs.Chtimes("qux/foo", mtime, mtime), Equals, billy.ErrReadOnly)
+
+	f, err := fs.Open("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, Equals, billy.ErrReadOnly)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *TarSuite) TestDir(c *C) {
+	fs := s.newTar(c).Dir("baz")
+	c.Assert(fs.Base(), Equals, "/baz")
+	c.Assert(readDirNames(c, fs, "/"), DeepEquals, []string{"abs", "qux", "up"})
+	c.Assert(readFile(c, fs, "qux/deep"), DeepEquals, fileContent("baz/qux/deep", 4))
+	c.Assert(readFile(c, fs, "../qux/deep"), DeepEquals, fileContent("baz/qux/deep", 4))
+	c.Assert(readFile(c, fs.Dir("qux"), "deep"), DeepEquals, fileContent("baz/qux/deep", 4))
+}
+
+func (s *TarSuite) TestCorrupted(c *C) {
+	_, err := New(bytes.NewReader(s.data[:len(s.data)/2]), int64(len(s.data)/2))
+	c.Assert(err, NotNil)
+}
+
+func (s *TarSuite) TestRegistry(c *C) {
+	filename := filepath.Join(c.MkDir(), "archive.tar")
+	c.Assert(ioutil.WriteFile(filename, s.data, 0644), IsNil)
+
+	fs, err := billy.Open("tar://" + filepath.ToSlash(filename))
+	c.Assert(err, IsNil)
+	c.Assert(readFile(c, fs, "qux/foo"), DeepEquals, fileContent("./qux/foo", 6))
+
+	c.Assert(billy.Close(fs.Dir("qux")), IsNil)
+	f, err := fs.Open("qux/foo")
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(f)
+	c.Assert(err, NotNil)
+}
+
+func (s *TarSuite) TestCloseNotOwned(c *C) {
+	fs, err := New(bytes.NewReader(s.data), int64(len(s.data)))
+	c.Assert(err, IsNil)
+	c.Assert(fs.Close(), IsNil)
+	c.Assert(readFile(c, fs, "qux/foo"), DeepEquals, fileContent("./qux/foo", 6))
+}
@@ -0,0 +1,63 @@
+package billy
+
+import "path/filepath"
+
+// TempPolicy decides where the temporary file used to write the destination
+// dst, in the filesystem fs, is placed. It returns the filesystem and the
+// directory where the temporary file should be created. An atomic rename of
+// the temporary file into dst is only possible when the returned filesystem
+// is fs itself, and some backends require also the same directory.
+type TempPolicy func(fs Filesystem, dst string) (Filesystem, string)
+
+// TempSameDir places the temporary files next to their destination.
+func TempSameDir(fs Filesystem, dst string) (Filesystem, string) {
+	return fs, filepath.Dir(dst)
+}
+
+// TempSubtree returns a TempPolicy placing all the temporary files in a
+// dedicated directory of the same filesystem.
+func TempSubtree(dir string) TempPolicy {
+	return func(fs Filesystem, dst string) (Filesystem, string) {
+		return fs, dir
+	}
+}
+
+// TempFilesystem returns a TempPolicy placing all the temporary files in the
+// given directory of a separate filesystem.
+func TempFilesystem(tmp Filesystem, dir string) TempPolicy {
+	return func(fs Filesystem, dst string) (Filesystem, string) {
+		return tmp, dir
+	}
+}
+
+// TempFileFor creates a new temporary file, intended to be moved later to dst,
+// following the given policy. The filesystem where the file was created is
+// returned along with the file. If policy is nil TempSameDir is used.
+func TempFileFor(fs Filesystem, policy TempPolicy, dst, prefix string) (File, Filesystem, error) {
+	if policy == nil {
+		policy = TempSameDir
+	}
+
+	tmpfs, dir := policy(fs, dst)
+	f, err := tmpfs.TempFile(dir, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, tmpfs, nil
+}
+
+// Move moves the file src from the filesystem srcfs to dst in dstfs. When both
+// are the same filesystem the file is renamed, otherwise it is copied and the
+// source removed, so the operation is not atomic.
+func Move(srcfs Filesystem, src string, dstfs Filesystem, dst string) error {
+	if srcfs == dstfs {
+		return srcfs.Rename(src, dst)
+	}
+
+	if err := CopyFile(dstfs, dst, srcfs, src); err != nil {
+		return err
+	}
+
+	return srcfs.Remove(src)
+}
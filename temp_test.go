@@ -0,0 +1,56 @@
+package billy_test
+
+import (
+	"io/ioutil"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type TempSuite struct{}
+
+var _ = Suite(&TempSuite{})
+
+func (s *TempSuite) TestTempFileForSameDir(c *C) {
+	fs := memory.New()
+	f, tmpfs, err := billy.TempFileFor(fs, nil, "qux/foo", "tmp")
+	c.Assert(err, IsNil)
+	c.Assert(tmpfs, Equals, billy.Filesystem(fs))
+	c.Assert(strings.HasPrefix(f.Filename(), "qux/tmp"), Equals, true)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *TempSuite) TestTempFileForSubtree(c *C) {
+	fs := memory.New()
+	f, tmpfs, err := billy.TempFileFor(fs, billy.TempSubtree(".tmp"), "qux/foo", "tmp")
+	c.Assert(err, IsNil)
+	c.Assert(tmpfs, Equals, billy.Filesystem(fs))
+	c.Assert(strings.HasPrefix(f.Filename(), ".tmp/tmp"), Equals, true)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *TempSuite) TestTempFileForFilesystemAndMove(c *C) {
+	fs := memory.New()
+	tmp := memory.New()
+
+	f, tmpfs, err := billy.TempFileFor(fs, billy.TempFilesystem(tmp, ""), "qux/foo", "tmp")
+	c.Assert(err, IsNil)
+	c.Assert(tmpfs, Equals, billy.Filesystem(tmp))
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(billy.Move(tmpfs, f.Filename(), fs, "qux/foo"), IsNil)
+
+	_, err = tmp.Stat(f.Filename())
+	c.Assert(err, NotNil)
+
+	r, err := fs.Open("qux/foo")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+}
@@ -1,4 +1,21 @@
-package test
+// Package test provides the conformance suite of the billy filesystems. The
+// implementations, including the ones outside of this repository, validate
+// their compliance embedding FilesystemSuite in a gocheck suite and setting
+// its Fs field:
+//
+//	type MySuite struct {
+//		test.FilesystemSuite
+//	}
+//
+//	var _ = Suite(&MySuite{})
+//
+//	func (s *MySuite) SetUpTest(c *C) {
+//		s.FilesystemSuite.Fs = myfs.New()
+//	}
+//
+// The tests of the optional operations, such as symbolic links, are skipped
+// when they return billy.ErrNotSupported.
+package test // import "srcd.works/go-billy.v1/test"
 
 import (
 	"fmt"
@@ -7,6 +24,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"bytes"
 
@@ -16,6 +34,9 @@ import (
 
 func Test(t *testing.T) { TestingT(t) }
 
+// FilesystemSuite is the conformance suite of a Filesystem, every test
+// starts with Fs empty, so it should be set from the SetUpTest method of the
+// embedding suite.
 type FilesystemSuite struct {
 	Fs Filesystem
 }
@@ -173,6 +194,15 @@ func (s *FilesystemSuite) TestOpenFile(c *C) {
 	s.testReadClose(c, f, "bar")
 }
 
+func (s *FilesystemSuite) TestOpenFileExclusive(c *C) {
+	f, err := s.Fs.OpenFile("foo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = s.Fs.OpenFile("foo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	c.Assert(os.IsExist(err), Equals, true)
+}
+
 func (s *FilesystemSuite) testWriteClose(c *C, f File, content string) {
 	written, err := f.Write([]byte(content))
 	c.Assert(written, Equals, len(content))
@@ -228,6 +258,26 @@ func (s *FilesystemSuite) TestFileOpenReadSeek(c *C) {
 	c.Assert(f.Close(), IsNil)
 }
 
+func (s *FilesystemSuite) TestSeekBeyondEndAndWrite(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	p, err := f.Seek(6, io.SeekStart)
+	c.Assert(err, IsNil)
+	c.Assert(int(p), Equals, 6)
+
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = s.Fs.Open("foo")
+	c.Assert(err, IsNil)
+	s.testReadClose(c, f, "foo\x00\x00\x00bar")
+}
+
 func (s *FilesystemSuite) TestFileCloseTwice(c *C) {
 	f, err := s.Fs.Create("foo")
 	c.Assert(err, IsNil)
@@ -258,6 +308,30 @@ func (s *FilesystemSuite) TestReadDirAndDir(c *C) {
 	c.Assert(info, HasLen, 2)
 }
 
+func (s *FilesystemSuite) TestReadDirSorted(c *C) {
+	files := []string{"qux", "foo", "b/foo", "bar", "a", "baz"}
+	for _, name := range files {
+		f, err := s.Fs.Create(name)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	info, err := s.Fs.ReadDir("/")
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range info {
+		names = append(names, fi.Name())
+	}
+
+	c.Assert(names, DeepEquals, []string{"a", "b", "bar", "baz", "foo", "qux"})
+}
+
+func (s *FilesystemSuite) TestReadDirNonExistent(c *C) {
+	_, err := s.Fs.ReadDir("non-existent")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
 func (s *FilesystemSuite) TestReadDirFileInfo(c *C) {
 	f, err := s.Fs.Create("foo")
 	c.Assert(err, IsNil)
@@ -318,6 +392,32 @@ func (s *FilesystemSuite) TestDirStat(c *C) {
 	c.Assert(fi.Name(), Equals, "baz")
 }
 
+func (s *FilesystemSuite) TestStatDeep(c *C) {
+	files := []string{"qux/baz", "quxx"}
+	for _, name := range files {
+		f, err := s.Fs.Create(name)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	fi, err := s.Fs.Stat("qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "baz")
+	c.Assert(fi.IsDir(), Equals, false)
+
+	fi, err = s.Fs.Stat("qux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "qux")
+	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(fi.Mode().IsDir(), Equals, true)
+}
+
+func (s *FilesystemSuite) TestStatRoot(c *C) {
+	fi, err := s.Fs.Stat("/")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+}
+
 func (s *FilesystemSuite) TestCreateInDir(c *C) {
 	dir := s.Fs.Dir("foo")
 	f, err := dir.Create("bar")
@@ -477,3 +577,557 @@ func (s *FilesystemSuite) TestReadWriteLargeFile(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(len(b), Equals, size)
 }
+
+func (s *FilesystemSuite) TestFileIDRename(c *C) {
+	i, ok := s.Fs.(Identity)
+	if !ok {
+		c.Skip("Identity not supported")
+	}
+
+	for _, name := range []string{"foo", "bar"} {
+		f, err := s.Fs.Create(name)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	foo, err := i.FileID("foo")
+	c.Assert(err, IsNil)
+	bar, err := i.FileID("bar")
+	c.Assert(err, IsNil)
+	c.Assert(foo, Not(Equals), bar)
+
+	c.Assert(s.Fs.Rename("foo", "qux/baz"), IsNil)
+	id, err := i.FileID("qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, foo)
+
+	_, err = i.FileID("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestSymlink(c *C) {
+	s.writeFile(c, "dir/file", "foo")
+
+	err := s.Fs.Symlink("file", "dir/link")
+	if err == ErrNotSupported {
+		c.Skip("symlinks not supported")
+	}
+
+	c.Assert(err, IsNil)
+
+	fi, err := s.Fs.Lstat("dir/link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(fi.Mode()&os.ModeSymlink != 0, Equals, true)
+
+	fi, err = s.Fs.Stat("dir/link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.FileMode(0))
+
+	f, err := s.Fs.Open("dir/link")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+
+	target, err := s.Fs.Readlink("dir/link")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "file")
+}
+
+func (s *FilesystemSuite) TestSymlinkToDir(c *C) {
+	s.writeFile(c, "dir/file", "foo")
+
+	err := s.Fs.Symlink("dir", "link")
+	if err == ErrNotSupported {
+		c.Skip("symlinks not supported")
+	}
+
+	c.Assert(err, IsNil)
+
+	fi, err := s.Fs.Stat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	s.writeFile(c, "link/new", "bar")
+	_, err = s.Fs.Stat("dir/new")
+	c.Assert(err, IsNil)
+
+	l, err := s.Fs.ReadDir("link")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 2)
+}
+
+func (s *FilesystemSuite) TestSymlinkDangling(c *C) {
+	err := s.Fs.Symlink("missing", "link")
+	if err == ErrNotSupported {
+		c.Skip("symlinks not supported")
+	}
+
+	c.Assert(err, IsNil)
+
+	_, err = s.Fs.Stat("link")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = s.Fs.Lstat("link")
+	c.Assert(err, IsNil)
+
+	err = s.Fs.Symlink("other", "link")
+	c.Assert(err, NotNil)
+
+	s.writeFile(c, "link", "foo")
+	_, err = s.Fs.Stat("missing")
+	c.Assert(err, IsNil)
+}
+
+func (s *FilesystemSuite) TestSymlinkLoop(c *C) {
+	err := s.Fs.Symlink("bar", "foo")
+	if err == ErrNotSupported {
+		c.Skip("symlinks not supported")
+	}
+
+	c.Assert(err, IsNil)
+	c.Assert(s.Fs.Symlink("foo", "bar"), IsNil)
+
+	_, err = s.Fs.Stat("foo")
+	c.Assert(err, NotNil)
+	_, err = s.Fs.Open("foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *FilesystemSuite) TestRemoveSymlink(c *C) {
+	s.writeFile(c, "file", "foo")
+
+	err := s.Fs.Symlink("file", "link")
+	if err == ErrNotSupported {
+		c.Skip("symlinks not supported")
+	}
+
+	c.Assert(err, IsNil)
+	c.Assert(s.Fs.Remove("link"), IsNil)
+
+	_, err = s.Fs.Lstat("link")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = s.Fs.Stat("file")
+	c.Assert(err, IsNil)
+}
+
+func (s *FilesystemSuite) TestRenameSymlink(c *C) {
+	s.writeFile(c, "file", "foo")
+
+	err := s.Fs.Symlink("file", "link")
+	if err == ErrNotSupported {
+		c.Skip("symlinks not supported")
+	}
+
+	c.Assert(err, IsNil)
+	c.Assert(s.Fs.Rename("link", "renamed"), IsNil)
+
+	target, err := s.Fs.Readlink("renamed")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, "file")
+
+	_, err = s.Fs.Lstat("link")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestReadlinkNotLink(c *C) {
+	s.writeFile(c, "file", "foo")
+
+	_, err := s.Fs.Readlink("file")
+	c.Assert(err, NotNil)
+}
+
+func (s *FilesystemSuite) TestMkdirAll(c *C) {
+	c.Assert(s.Fs.MkdirAll("foo/bar", 0755), IsNil)
+
+	fi, err := s.Fs.Stat("foo/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	l, err := s.Fs.ReadDir("foo")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	c.Assert(l[0].Name(), Equals, "bar")
+	c.Assert(l[0].IsDir(), Equals, true)
+
+	c.Assert(s.Fs.MkdirAll("foo/bar", 0755), IsNil)
+
+	s.writeFile(c, "foo/bar/qux", "qux")
+	c.Assert(s.Fs.Remove("foo/bar/qux"), IsNil)
+
+	_, err = s.Fs.Stat("foo/bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.Fs.Remove("foo/bar"), IsNil)
+	_, err = s.Fs.Stat("foo/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *FilesystemSuite) TestMkdir(c *C) {
+	c.Assert(Mkdir(s.Fs, "foo", 0755), IsNil)
+
+	fi, err := s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	l, err := s.Fs.ReadDir("foo")
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 0)
+
+	err = Mkdir(s.Fs, "foo", 0755)
+	c.Assert(os.IsExist(err), Equals, true)
+	err = Mkdir(s.Fs, "bar/foo", 0755)
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = s.Fs.Stat("bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	s.writeFile(c, "qux", "qux")
+	c.Assert(Mkdir(s.Fs, "qux", 0755), NotNil)
+	c.Assert(Mkdir(s.Fs, "qux/foo", 0755), NotNil)
+
+	c.Assert(Mkdir(s.Fs, "foo/bar", 0755), IsNil)
+	c.Assert(s.Fs.Remove("foo/bar"), IsNil)
+	c.Assert(s.Fs.Remove("foo"), IsNil)
+}
+
+func (s *FilesystemSuite) TestMkdirAllOnFile(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	err := s.Fs.MkdirAll("foo/bar", 0755)
+	c.Assert(err, NotNil)
+}
+
+func (s *FilesystemSuite) TestRemoveNotEmptyDir(c *C) {
+	s.writeFile(c, "foo/bar", "bar")
+
+	err := s.Fs.Remove("foo")
+	c.Assert(err, NotNil)
+
+	_, err = s.Fs.Stat("foo/bar")
+	c.Assert(err, IsNil)
+}
+
+func (s *FilesystemSuite) TestOpenFilePerm(c *C) {
+	f, err := s.Fs.OpenFile("foo", os.O_CREATE|os.O_WRONLY, 0640)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fi, err := s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0640))
+
+	c.Assert(s.Fs.MkdirAll("dir", 0750), IsNil)
+	fi, err = s.Fs.Stat("dir")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0750))
+	c.Assert(fi.Mode().IsDir(), Equals, true)
+}
+
+func (s *FilesystemSuite) TestChmod(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	err := s.Fs.Chmod("foo", 0600)
+	if err == ErrNotSupported {
+		c.Skip("chmod not supported")
+	}
+
+	c.Assert(err, IsNil)
+
+	fi, err := s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0600))
+	c.Assert(fi.Mode().IsRegular(), Equals, true)
+
+	c.Assert(s.Fs.MkdirAll("dir", 0755), IsNil)
+	c.Assert(s.Fs.Chmod("dir", 0700), IsNil)
+	fi, err = s.Fs.Stat("dir")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0700))
+
+	err = s.Fs.Chmod("missing", 0600)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestChtimes(c *C) {
+	s.writeFile(c, "dir/foo", "foo")
+
+	mtime := time.Date(2015, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"dir/foo", "dir"} {
+		c.Assert(s.Fs.Chtimes(name, mtime, mtime), IsNil)
+
+		fi, err := s.Fs.Stat(name)
+		c.Assert(err, IsNil)
+		c.Assert(fi.ModTime().Equal(mtime), Equals, true, Commentf(name))
+	}
+
+	err := s.Fs.Chtimes("missing", mtime, mtime)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestTruncate(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo bar"))
+	c.Assert(err, IsNil)
+
+	c.Assert(f.Truncate(3), IsNil)
+	fi, err := s.Fs.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	c.Assert(f.Truncate(5), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = s.Fs.Open("foo")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(content, DeepEquals, []byte("foo\x00\x00"))
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestSync(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Sync(), IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestLock(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	f1, err := s.Fs.OpenFile("foo", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	defer f1.Close()
+
+	f2, err := s.Fs.OpenFile("foo", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	defer f2.Close()
+
+	err = f1.Lock()
+	if err == ErrNotSupported {
+		c.Skip("locks not supported")
+	}
+	c.Assert(err, IsNil)
+
+	locked := make(chan error)
+	go func() { locked <- f2.Lock() }()
+
+	select {
+	case <-locked:
+		c.Fatal("lock acquired twice")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	c.Assert(f1.Unlock(), IsNil)
+	c.Assert(<-locked, IsNil)
+	c.Assert(f2.Unlock(), IsNil)
+}
+
+func (s *FilesystemSuite) TestLockReleasedOnClose(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	f, err := s.Fs.OpenFile("foo", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	err = f.Lock()
+	if err == ErrNotSupported {
+		c.Skip("locks not supported")
+	}
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = s.Fs.OpenFile("foo", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	c.Assert(f.Lock(), IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestFileStat(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	fi, err := f.Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "foo")
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(fi.IsDir(), Equals, false)
+
+	c.Assert(s.Fs.Rename("foo", "bar"), IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, IsNil)
+
+	fi, err = f.Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(6))
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestSeek(c *C) {
+	f, err := s.Fs.Create("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("0123456789"))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		offset   int64
+		whence   int
+		position int64
+		next     string
+	}{
+		{2, io.SeekStart, 2, "2"},
+		{3, io.SeekCurrent, 6, "6"},
+		{-2, io.SeekCurrent, 5, "5"},
+		{-2, io.SeekEnd, 8, "8"},
+		{0, io.SeekEnd, 10, ""},
+	} {
+		p, err := f.Seek(t.offset, t.whence)
+		c.Assert(err, IsNil)
+		c.Assert(p, Equals, t.position)
+
+		b := make([]byte, 1)
+		n, _ := f.Read(b)
+		c.Assert(string(b[:n]), Equals, t.next)
+	}
+
+	_, err = f.Seek(-1, io.SeekStart)
+	c.Assert(err, NotNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestReadAtDoesNotMoveOffset(c *C) {
+	s.writeFile(c, "foo", "0123456789")
+
+	f, err := s.Fs.Open("foo")
+	c.Assert(err, IsNil)
+	rf, ok := f.(io.ReaderAt)
+	c.Assert(ok, Equals, true)
+
+	b := make([]byte, 4)
+	n, err := rf.ReadAt(b, 8)
+	c.Assert(n, Equals, 2)
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(string(b[:n]), Equals, "89")
+
+	p, err := f.Seek(0, io.SeekCurrent)
+	c.Assert(err, IsNil)
+	c.Assert(p, Equals, int64(0))
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) TestOpenFileWriteOnly(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	f, err := s.Fs.OpenFile("foo", os.O_WRONLY, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Read(make([]byte, 1))
+	c.Assert(err, NotNil)
+	_, err = f.Write([]byte("b"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.readFile(c, "foo"), Equals, "boo")
+}
+
+func (s *FilesystemSuite) TestOpenReadOnlyWrite(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	f, err := s.Fs.Open("foo")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, NotNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.readFile(c, "foo"), Equals, "foo")
+}
+
+func (s *FilesystemSuite) TestOpenFileCreateTruncate(c *C) {
+	s.writeFile(c, "foo", "foo bar")
+
+	f, err := s.Fs.OpenFile("foo", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("qux"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.readFile(c, "foo"), Equals, "qux")
+}
+
+func (s *FilesystemSuite) TestRenameOverwrite(c *C) {
+	s.writeFile(c, "foo", "foo")
+	s.writeFile(c, "bar", "bar")
+
+	c.Assert(s.Fs.Rename("foo", "bar"), IsNil)
+	c.Assert(s.readFile(c, "bar"), Equals, "foo")
+
+	_, err := s.Fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestRenameToNewDir(c *C) {
+	s.writeFile(c, "foo", "foo")
+
+	c.Assert(s.Fs.Rename("foo", "qux/bar"), IsNil)
+	c.Assert(s.readFile(c, "qux/bar"), Equals, "foo")
+}
+
+func (s *FilesystemSuite) TestRenameNonExistent(c *C) {
+	err := s.Fs.Rename("foo", "bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *FilesystemSuite) TestReadDirOrder(c *C) {
+	for _, name := range []string{"qux", "foo", "bar/baz", "baz"} {
+		s.writeFile(c, name, name)
+	}
+
+	infos, err := s.Fs.ReadDir("")
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	c.Assert(names, DeepEquals, []string{"bar", "baz", "foo", "qux"})
+}
+
+func (s *FilesystemSuite) TestTempFileUnique(c *C) {
+	names := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		f, err := s.Fs.TempFile("tmp", "foo")
+		c.Assert(err, IsNil)
+		c.Assert(names[f.Filename()], Equals, false)
+		names[f.Filename()] = true
+		c.Assert(f.Close(), IsNil)
+	}
+
+	infos, err := s.Fs.ReadDir("tmp")
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 10)
+}
+
+func (s *FilesystemSuite) writeFile(c *C, filename, content string) {
+	f, err := s.Fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *FilesystemSuite) readFile(c *C, filename string) string {
+	f, err := s.Fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
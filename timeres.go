@@ -0,0 +1,14 @@
+package billy
+
+import "time"
+
+// TruncateTime rounds t down to a multiple of the given resolution, as done
+// by the filesystems storing coarse timestamps, such as FAT with 2 seconds.
+// A resolution of zero or less leaves t untouched.
+func TruncateTime(t time.Time, resolution time.Duration) time.Time {
+	if resolution <= 0 {
+		return t
+	}
+
+	return t.Truncate(resolution)
+}
@@ -0,0 +1,19 @@
+package billy_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+)
+
+type TimeResolutionSuite struct{}
+
+var _ = Suite(&TimeResolutionSuite{})
+
+func (s *TimeResolutionSuite) TestTruncateTime(c *C) {
+	t := time.Date(2017, 3, 4, 10, 20, 31, 500, time.UTC)
+	c.Assert(billy.TruncateTime(t, 0), Equals, t)
+	c.Assert(billy.TruncateTime(t, time.Second), Equals, time.Date(2017, 3, 4, 10, 20, 31, 0, time.UTC))
+	c.Assert(billy.TruncateTime(t, 2*time.Second), Equals, time.Date(2017, 3, 4, 10, 20, 30, 0, time.UTC))
+}
@@ -0,0 +1,197 @@
+// Package treestats reports the statistics of the files of a tree, by
+// extension and by MIME type, kept apart from the billy package so the
+// programs not using it don't link the mime and net/http packages.
+package treestats // import "srcd.works/go-billy.v1/treestats"
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"srcd.works/go-billy.v1"
+)
+
+// sniffLen is the length of the prefix of the files read to detect
+// their type, as used by http.DetectContentType.
+const sniffLen = 512
+
+// Options describes how Collect classifies the files.
+type Options struct {
+	// Largest is the number of largest files kept, overall and by group, 10
+	// by default.
+	Largest int
+	// DetectTypes detects the type of the files from their first 512 bytes,
+	// with http.DetectContentType, instead of from their extension, with
+	// mime.TypeByExtension, so every file is opened.
+	DetectTypes bool
+	// Types, if not empty, matches only the files of the given MIME types,
+	// such as "text/plain", or of the given families, such as "image/*".
+	Types []string
+}
+
+var defaultOptions = Options{
+	Largest: 10,
+}
+
+// FileSize is the size of a file reported by Collect.
+type FileSize struct {
+	Path string
+	Size int64
+}
+
+// Group holds the statistics of a group of files.
+type Group struct {
+	// Files is the number of files and Size their total size.
+	Files int
+	Size  int64
+	// Largest are the largest files, by decreasing size.
+	Largest []FileSize
+}
+
+// Report holds the statistics of the files of a tree, overall, by
+// extension and by MIME type. The files without extension are grouped under
+// "", the ones with an unknown type under "application/octet-stream".
+type Report struct {
+	Group
+	// Dirs is the number of directories, including the root.
+	Dirs        int
+	ByExtension map[string]*Group
+	ByType      map[string]*Group
+}
+
+// Collect walks the tree rooted at root and returns the statistics of its
+// regular files. Only the largest files are kept, so the memory used doesn't
+// grow with the size of the tree. If opts is nil the default options are
+// used, as for their zero fields.
+func Collect(fs billy.Filesystem, root string, opts *Options) (*Report, error) {
+	o := defaultOptions
+	if opts != nil {
+		if opts.Largest > 0 {
+			o.Largest = opts.Largest
+		}
+
+		o.DetectTypes = opts.DetectTypes
+		o.Types = opts.Types
+	}
+
+	r := &Report{
+		ByExtension: make(map[string]*Group),
+		ByType:      make(map[string]*Group),
+	}
+
+	err := billy.Walk(fs, root, func(path string, info billy.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			r.Dirs++
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		typ, err := fileMIMEType(fs, path, o.DetectTypes)
+		if err != nil {
+			return err
+		}
+
+		if !matchMIMEType(typ, o.Types) {
+			return nil
+		}
+
+		f := FileSize{Path: path, Size: info.Size()}
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		r.add(f, o.Largest)
+		group(r.ByExtension, ext).add(f, o.Largest)
+		group(r.ByType, typ).add(f, o.Largest)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func group(m map[string]*Group, key string) *Group {
+	g, ok := m[key]
+	if !ok {
+		g = &Group{}
+		m[key] = g
+	}
+
+	return g
+}
+
+// add adds f to the group, keeping the largest n files.
+func (g *Group) add(f FileSize, n int) {
+	g.Files++
+	g.Size += f.Size
+
+	i := len(g.Largest)
+	for i > 0 && g.Largest[i-1].Size < f.Size {
+		i--
+	}
+
+	if i >= n {
+		return
+	}
+
+	if len(g.Largest) < n {
+		g.Largest = append(g.Largest, FileSize{})
+	}
+
+	copy(g.Largest[i+1:], g.Largest[i:])
+	g.Largest[i] = f
+}
+
+// fileMIMEType returns the MIME type of the named file, without parameters,
+// from its content if detect is true or from its extension otherwise.
+func fileMIMEType(fs billy.Filesystem, path string, detect bool) (string, error) {
+	var typ string
+	if detect {
+		f, err := fs.Open(path)
+		if err != nil {
+			return "", err
+		}
+
+		defer f.Close()
+
+		buf := make([]byte, sniffLen)
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+
+		typ = http.DetectContentType(buf[:n])
+	} else {
+		typ = mime.TypeByExtension(filepath.Ext(path))
+	}
+
+	if t, _, err := mime.ParseMediaType(typ); err == nil {
+		return t, nil
+	}
+
+	return "application/octet-stream", nil
+}
+
+// matchMIMEType returns true if typ is one of types, or of one of their
+// families, or if types is empty.
+func matchMIMEType(typ string, types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, t := range types {
+		if t == typ || (strings.HasSuffix(t, "/*") && strings.HasPrefix(typ, t[:len(t)-1])) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package treestats
+
+import (
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CollectSuite struct{}
+
+var _ = Suite(&CollectSuite{})
+
+func (s *CollectSuite) TestCollect(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "qux/foo.html", "<html>foo</html>")
+	writeFile(c, fs, "qux/bar.HTML", "<html>bar</html>!")
+	writeFile(c, fs, "qux/baz.png", "\x89PNG\r\n\x1a\nbaz")
+	writeFile(c, fs, "README", "readme")
+	c.Assert(fs.MkdirAll("empty", 0755), IsNil)
+
+	r, err := Collect(fs, "", &Options{Largest: 2})
+	c.Assert(err, IsNil)
+	c.Assert(r.Dirs, Equals, 3)
+	c.Assert(r.Files, Equals, 4)
+	c.Assert(r.Size, Equals, int64(50))
+	c.Assert(r.Largest, DeepEquals, []FileSize{
+		{Path: "qux/bar.HTML", Size: 17},
+		{Path: "qux/foo.html", Size: 16},
+	})
+
+	c.Assert(r.ByExtension, HasLen, 3)
+	c.Assert(*r.ByExtension[".html"], DeepEquals, Group{
+		Files: 2,
+		Size:  33,
+		Largest: []FileSize{
+			{Path: "qux/bar.HTML", Size: 17},
+			{Path: "qux/foo.html", Size: 16},
+		},
+	})
+	c.Assert(r.ByExtension[""].Files, Equals, 1)
+
+	c.Assert(r.ByType, HasLen, 3)
+	c.Assert(r.ByType["text/html"].Files, Equals, 2)
+	c.Assert(r.ByType["image/png"].Files, Equals, 1)
+	c.Assert(r.ByType["application/octet-stream"].Largest, DeepEquals, []FileSize{
+		{Path: "README", Size: 6},
+	})
+}
+
+func (s *CollectSuite) TestCollectDetectTypes(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo.bin", "<html>foo</html>")
+	writeFile(c, fs, "bar.txt", "\x89PNG\r\n\x1a\nbar")
+	writeFile(c, fs, "baz", strings.Repeat("baz\n", 1000))
+
+	r, err := Collect(fs, "", &Options{DetectTypes: true})
+	c.Assert(err, IsNil)
+	c.Assert(r.ByType, HasLen, 3)
+	c.Assert(r.ByType["text/html"].Largest[0].Path, Equals, "foo.bin")
+	c.Assert(r.ByType["image/png"].Largest[0].Path, Equals, "bar.txt")
+	c.Assert(r.ByType["text/plain"].Size, Equals, int64(4000))
+}
+
+func (s *CollectSuite) TestCollectTypes(c *C) {
+	fs := memory.New()
+	writeFile(c, fs, "foo.png", "foo")
+	writeFile(c, fs, "bar.gif", "bar")
+	writeFile(c, fs, "baz.html", "baz")
+
+	r, err := Collect(fs, "", &Options{Types: []string{"image/*"}})
+	c.Assert(err, IsNil)
+	c.Assert(r.Files, Equals, 2)
+	c.Assert(r.ByType, HasLen, 2)
+
+	r, err = Collect(fs, "", &Options{Types: []string{"text/html", "image/gif"}})
+	c.Assert(err, IsNil)
+	c.Assert(r.Files, Equals, 2)
+	c.Assert(r.ByExtension, HasLen, 2)
+	c.Assert(r.ByExtension[".png"], IsNil)
+}
+
+func (s *CollectSuite) TestCollectLargest(c *C) {
+	fs := memory.New()
+	for i, size := range []int{0, 3, 1, 4, 3} {
+		writeFile(c, fs, string(rune('a'+i)), strings.Repeat("x", size))
+	}
+
+	r, err := Collect(fs, "", &Options{Largest: 3})
+	c.Assert(err, IsNil)
+	c.Assert(r.Largest, DeepEquals, []FileSize{
+		{Path: "d", Size: 4},
+		{Path: "b", Size: 3},
+		{Path: "e", Size: 3},
+	})
+
+	_, err = Collect(fs, "missing", nil)
+	c.Assert(err, NotNil)
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
@@ -0,0 +1,358 @@
+package billy
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// SkipDir is used as a return value from a WalkFunc to indicate that the
+// directory named in the call is to be skipped.
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc is the type of the function called for each file or directory
+// visited by Walk. The path argument is relative to the walked filesystem.
+type WalkFunc func(path string, info FileInfo, err error) error
+
+// Walk walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, in lexical order. It follows the
+// same semantics as filepath.Walk, the symbolic links are not followed.
+func Walk(fs Filesystem, root string, fn WalkFunc) error {
+	info, err := fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fs, root, info, fn)
+	}
+
+	if err == SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+func walk(fs Filesystem, path string, info FileInfo, fn WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	files, err := fs.ReadDir(path)
+	err1 := fn(path, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	sort.Sort(byName(files))
+	for _, fi := range files {
+		filename := filepath.Join(path, fi.Name())
+		if err := walk(fs, filename, fi, fn); err != nil {
+			if !fi.IsDir() || err != SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WalkParallelOptions describes how WalkParallel lists the directories.
+type WalkParallelOptions struct {
+	// Parallelism is the maximum number of directories listed concurrently,
+	// 8 by default.
+	Parallelism int
+}
+
+var defaultWalkParallelOptions = WalkParallelOptions{
+	Parallelism: 8,
+}
+
+// WalkParallel walks the file tree rooted at root as Walk, but listing the
+// directories concurrently ahead of the walk, for the backends where the
+// latency of ReadDir dominates. The results are the same as the ones of Walk:
+// fn is called from one goroutine at a time, in lexical order, so it doesn't
+// need to be safe for concurrent use, but fs does. The listing goroutines are
+// stopped also if fn panics, and the panics of fs while listing are raised
+// again in the walk. If opts is nil the default options are used, as for
+// their zero fields.
+func WalkParallel(fs Filesystem, root string, fn WalkFunc, opts *WalkParallelOptions) error {
+	o := defaultWalkParallelOptions
+	if opts != nil && opts.Parallelism > 0 {
+		o.Parallelism = opts.Parallelism
+	}
+
+	var c cleanup
+	defer c.run()
+
+	info, err := fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		w := newParallelWalker(fs, root, info, o.Parallelism)
+		c.close(w.stop)
+		err = w.walk(root, info, fn)
+	}
+
+	if err == SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+// parallelWalker lists the directories of a tree with a pool of goroutines,
+// in depth-first order, so the listings are usually ready when the walk
+// reaches them.
+type parallelWalker struct {
+	fs Filesystem
+	wg sync.WaitGroup
+
+	m    sync.Mutex
+	cond *sync.Cond
+	// pending is the stack of directories to list, listings holds the ones
+	// pending or listed, until the walk takes them.
+	pending  []string
+	listings map[string]*dirListing
+	skipped  map[string]bool
+	stopped  bool
+}
+
+type dirListing struct {
+	done     chan struct{}
+	files    []FileInfo
+	err      error
+	panicked interface{}
+}
+
+func newParallelWalker(fs Filesystem, root string, info FileInfo, n int) *parallelWalker {
+	w := &parallelWalker{
+		fs:       fs,
+		listings: make(map[string]*dirListing),
+		skipped:  make(map[string]bool),
+	}
+
+	if info.IsDir() {
+		w.push(root)
+	}
+
+	w.cond = sync.NewCond(&w.m)
+	w.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go w.work()
+	}
+
+	return w
+}
+
+func (w *parallelWalker) walk(path string, info FileInfo, fn WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	files, err := w.list(path)
+	err1 := fn(path, info, err)
+	if err != nil || err1 != nil {
+		if err1 == SkipDir {
+			w.skip(path)
+		}
+
+		return err1
+	}
+
+	for _, fi := range files {
+		filename := filepath.Join(path, fi.Name())
+		if err := w.walk(filename, fi, fn); err != nil {
+			if !fi.IsDir() || err != SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// push adds the directory path to the ones to list, the caller must hold
+// the lock.
+func (w *parallelWalker) push(path string) {
+	w.listings[path] = &dirListing{done: make(chan struct{})}
+	w.pending = append(w.pending, path)
+}
+
+// list waits for the listing of the directory path, sorted by name.
+func (w *parallelWalker) list(path string) ([]FileInfo, error) {
+	w.m.Lock()
+	l := w.listings[path]
+	w.m.Unlock()
+
+	<-l.done
+
+	w.m.Lock()
+	delete(w.listings, path)
+	w.m.Unlock()
+
+	if l.panicked != nil {
+		panic(l.panicked)
+	}
+
+	return l.files, l.err
+}
+
+// skip stops listing the directories under path.
+func (w *parallelWalker) skip(path string) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.skipped[path] = true
+}
+
+// isSkipped returns true if path or any of its parents was skipped, the
+// caller must hold the lock.
+func (w *parallelWalker) isSkipped(path string) bool {
+	for {
+		if w.skipped[path] {
+			return true
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path || parent == "." {
+			return false
+		}
+
+		path = parent
+	}
+}
+
+func (w *parallelWalker) work() {
+	defer w.wg.Done()
+
+	for {
+		w.m.Lock()
+		for len(w.pending) == 0 && !w.stopped {
+			w.cond.Wait()
+		}
+
+		if w.stopped {
+			w.m.Unlock()
+			return
+		}
+
+		path := w.pending[len(w.pending)-1]
+		w.pending = w.pending[:len(w.pending)-1]
+		l := w.listings[path]
+		if w.isSkipped(path) {
+			delete(w.listings, path)
+			w.m.Unlock()
+			close(l.done)
+			continue
+		}
+
+		w.m.Unlock()
+
+		w.read(path, l)
+
+		w.m.Lock()
+		// pushed in reverse order, so the first one is listed first.
+		for i := len(l.files) - 1; i >= 0; i-- {
+			if l.files[i].IsDir() {
+				w.push(filepath.Join(path, l.files[i].Name()))
+			}
+		}
+
+		w.cond.Broadcast()
+		w.m.Unlock()
+		close(l.done)
+	}
+}
+
+// read lists the directory path into l, recovering the panics of ReadDir.
+func (w *parallelWalker) read(path string, l *dirListing) {
+	defer catchPanic(&l.panicked)
+
+	l.files, l.err = w.fs.ReadDir(path)
+	sort.Sort(byName(l.files))
+}
+
+// stop stops the workers, waiting for them to return.
+func (w *parallelWalker) stop() {
+	w.m.Lock()
+	w.stopped = true
+	w.cond.Broadcast()
+	w.m.Unlock()
+
+	w.wg.Wait()
+}
+
+type byName []FileInfo
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// WalkDirFunc is the type of the function called for each file or directory
+// visited by WalkDir. The path argument is relative to the walked filesystem.
+type WalkDirFunc func(path string, d DirEntry, err error) error
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, in lexical order. Unlike Walk, the
+// directories are listed with ReadDirEntries, so the cost of a Stat is only
+// paid when fn calls DirEntry.Info. It follows the same semantics as
+// filepath.WalkDir, the symbolic links are not followed.
+func WalkDir(fs Filesystem, root string, fn WalkDirFunc) error {
+	info, err := fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(fs, root, &fileInfoEntry{info}, fn)
+	}
+
+	if err == SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+func walkDir(fs Filesystem, path string, d DirEntry, fn WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == SkipDir && d.IsDir() {
+			err = nil
+		}
+
+		return err
+	}
+
+	entries, err := ReadDirEntries(fs, path)
+	if err != nil {
+		// second call, to report the ReadDirEntries error.
+		err = fn(path, d, err)
+		if err != nil {
+			if err == SkipDir && d.IsDir() {
+				err = nil
+			}
+
+			return err
+		}
+	}
+
+	sort.Sort(entriesByName(entries))
+	for _, e := range entries {
+		filename := filepath.Join(path, e.Name())
+		if err := walkDir(fs, filename, e, fn); err != nil {
+			// SkipDir returned for a file skips the rest of its directory.
+			if err == SkipDir {
+				break
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+type entriesByName []DirEntry
+
+func (s entriesByName) Len() int           { return len(s) }
+func (s entriesByName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }
+func (s entriesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,204 @@
+package billy_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+type WalkSuite struct{}
+
+var _ = Suite(&WalkSuite{})
+
+func (s *WalkSuite) TestWalk(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo", "qux/baz", "qux/bar/foo", "quxx"} {
+		writeFile(c, fs, name, name)
+	}
+
+	var paths []string
+	err := billy.Walk(fs, "", func(path string, info billy.FileInfo, err error) error {
+		c.Assert(err, IsNil)
+		paths = append(paths, path)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{
+		"", "foo", "qux", "qux/bar", "qux/bar/foo", "qux/baz", "quxx",
+	})
+}
+
+func (s *WalkSuite) TestWalkSkipDir(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo", "qux/baz", "qux/bar/foo"} {
+		writeFile(c, fs, name, name)
+	}
+
+	var paths []string
+	err := billy.Walk(fs, "", func(path string, info billy.FileInfo, err error) error {
+		c.Assert(err, IsNil)
+		if path == "qux/bar" {
+			return billy.SkipDir
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"", "foo", "qux", "qux/baz"})
+}
+
+func (s *WalkSuite) TestWalkDir(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo", "qux/baz", "qux/bar/foo", "quxx"} {
+		writeFile(c, fs, name, name)
+	}
+
+	var paths []string
+	err := billy.WalkDir(fs, "", func(path string, d billy.DirEntry, err error) error {
+		c.Assert(err, IsNil)
+		if path == "qux/bar" {
+			return billy.SkipDir
+		}
+
+		paths = append(paths, path)
+		if path == "qux/baz" {
+			fi, err := d.Info()
+			c.Assert(err, IsNil)
+			c.Assert(fi.Size(), Equals, int64(7))
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"", "foo", "qux", "qux/baz", "quxx"})
+}
+
+func (s *WalkSuite) TestWalkDirSkipDirFile(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo", "qux/bar", "qux/baz", "qux/qux", "quxx"} {
+		writeFile(c, fs, name, name)
+	}
+
+	var paths []string
+	err := billy.WalkDir(fs, "", func(path string, d billy.DirEntry, err error) error {
+		c.Assert(err, IsNil)
+		paths = append(paths, path)
+		if path == "qux/baz" {
+			return billy.SkipDir
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{
+		"", "foo", "qux", "qux/bar", "qux/baz", "quxx",
+	})
+}
+
+func (s *WalkSuite) TestWalkParallel(c *C) {
+	fs := memory.New()
+	for i := 0; i < 20; i++ {
+		for _, name := range []string{"foo", "qux/baz", "qux/bar/foo", "quxx"} {
+			writeFile(c, fs, fmt.Sprintf("%02d/%s", i, name), name)
+		}
+	}
+
+	walk := func(walk func(billy.WalkFunc) error) []string {
+		var paths []string
+		err := walk(func(path string, info billy.FileInfo, err error) error {
+			c.Assert(err, IsNil)
+			if strings.HasSuffix(path, "/bar") {
+				return billy.SkipDir
+			}
+
+			paths = append(paths, path)
+			return nil
+		})
+		c.Assert(err, IsNil)
+		return paths
+	}
+
+	expected := walk(func(fn billy.WalkFunc) error {
+		return billy.Walk(fs, "", fn)
+	})
+
+	for _, n := range []int{1, 2, 16} {
+		paths := walk(func(fn billy.WalkFunc) error {
+			return billy.WalkParallel(fs, "", fn, &billy.WalkParallelOptions{Parallelism: n})
+		})
+		c.Assert(paths, DeepEquals, expected)
+	}
+}
+
+func (s *WalkSuite) TestWalkParallelError(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo/bar", "qux/baz", "quxx"} {
+		writeFile(c, fs, name, name)
+	}
+
+	var paths []string
+	err := billy.WalkParallel(fs, "", func(path string, info billy.FileInfo, err error) error {
+		paths = append(paths, path)
+		if path == "qux" {
+			return errors.New("foo")
+		}
+
+		return nil
+	}, nil)
+	c.Assert(err, ErrorMatches, "foo")
+	c.Assert(paths, DeepEquals, []string{"", "foo", "foo/bar", "qux"})
+
+	err = billy.WalkParallel(fs, "missing", func(path string, info billy.FileInfo, err error) error {
+		c.Assert(os.IsNotExist(err), Equals, true)
+		return nil
+	}, nil)
+	c.Assert(err, IsNil)
+}
+
+func (s *WalkSuite) TestWalkParallelPanic(c *C) {
+	fs := memory.New()
+	for _, name := range []string{"foo", "qux/baz", "qux/bar/foo"} {
+		writeFile(c, fs, name, name)
+	}
+
+	c.Assert(func() {
+		billy.WalkParallel(fs, "", func(path string, info billy.FileInfo, err error) error {
+			if path == "qux/bar" {
+				panic("walk panicked")
+			}
+
+			return nil
+		}, nil)
+	}, PanicMatches, "walk panicked")
+
+	c.Assert(func() {
+		billy.WalkParallel(&panicking{Filesystem: fs, path: "qux/bar"}, "",
+			func(path string, info billy.FileInfo, err error) error {
+				return nil
+			}, nil)
+	}, PanicMatches, "panicking on qux/bar")
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	return string(content)
+}
@@ -0,0 +1,211 @@
+// Package webdav exposes the billy filesystems over WebDAV, adapting them to
+// the webdav.FileSystem of golang.org/x/net/webdav. NewHandler returns the
+// http.Handler serving a filesystem, New the webdav.FileSystem to be used
+// with other options, such as another webdav.LockSystem.
+package webdav // import "srcd.works/go-billy.v1/webdav"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/webdav"
+	"srcd.works/go-billy.v1"
+)
+
+var errIsDirectory = errors.New("is a directory")
+
+// NewHandler returns an http.Handler serving fs over WebDAV, with the paths
+// of the requests starting with prefix, which may be empty. The locks are
+// kept in memory.
+func NewHandler(fs billy.Filesystem, prefix string) *webdav.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: New(fs),
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// FileSystem is a webdav.FileSystem over a billy.Filesystem. The root of the
+// filesystem can't be removed nor renamed, and the files can only be created
+// in existing directories, as done by webdav.Dir.
+type FileSystem struct {
+	fs billy.Filesystem
+}
+
+// New returns a new FileSystem over fs.
+func New(fs billy.Filesystem) *FileSystem {
+	return &FileSystem{fs: fs}
+}
+
+// Mkdir creates the named directory, failing if it already exists or its
+// parent doesn't.
+func (fsys *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return billy.Mkdir(fsys.fs, fsys.filename(name), perm)
+}
+
+// OpenFile opens the named file with the given flag and perm, as
+// os.OpenFile. The directories can only be opened for reading.
+func (fsys *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	filename := fsys.filename(name)
+	fi, err := fsys.fs.Stat(filename)
+	if err == nil && fi.IsDir() {
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errIsDirectory}
+		}
+
+		return &dir{fs: fsys.fs, filename: filename, fi: fi}, nil
+	}
+
+	if os.IsNotExist(err) && flag&os.O_CREATE != 0 {
+		if err := fsys.checkParent("open", name); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := fsys.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f}, nil
+}
+
+// RemoveAll removes the named file and everything it contains, it does
+// nothing if it doesn't exist.
+func (fsys *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	filename := fsys.filename(name)
+	if filename == "" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrInvalid}
+	}
+
+	return billy.RemoveAll(fsys.fs, filename)
+}
+
+// Rename renames the file oldName to newName.
+func (fsys *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	from, to := fsys.filename(oldName), fsys.filename(newName)
+	if from == "" || to == "" {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: os.ErrInvalid}
+	}
+
+	return fsys.fs.Rename(from, to)
+}
+
+// Stat returns the FileInfo of the named file, following symbolic links.
+func (fsys *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fsys.fs.Stat(fsys.filename(name))
+}
+
+// filename returns the filename in the filesystem of the given WebDAV name,
+// empty for the root.
+func (fsys *FileSystem) filename(name string) string {
+	rel := strings.TrimPrefix(path.Clean("/"+name), "/")
+	return filepath.FromSlash(rel)
+}
+
+// checkParent checks that the parent of the named file is a directory.
+func (fsys *FileSystem) checkParent(op, name string) error {
+	parent := fsys.filename(path.Dir(path.Clean("/" + name)))
+	if parent == "" {
+		return nil
+	}
+
+	fi, err := fsys.fs.Stat(parent)
+	if err != nil || !fi.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	return nil
+}
+
+// file adapts a billy.File to webdav.File.
+type file struct {
+	billy.File
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.Filename(), Err: errors.New("not a directory")}
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.File.Stat()
+}
+
+// dir is a directory open as a webdav.File, listed on the first call to
+// Readdir.
+type dir struct {
+	fs       billy.Filesystem
+	filename string
+	fi       billy.FileInfo
+
+	entries []os.FileInfo
+	listed  bool
+}
+
+// Readdir returns the next count entries of the directory, sorted by name,
+// or all of the remaining ones if count is zero or negative, as
+// os.File.Readdir.
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		entries, err := d.fs.ReadDir(d.filename)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries = make([]os.FileInfo, len(entries))
+		for i, e := range entries {
+			d.entries[i] = e
+		}
+
+		d.listed = true
+	}
+
+	if count <= 0 {
+		l := d.entries
+		d.entries = nil
+		return l, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+
+	l := d.entries[:count]
+	d.entries = d.entries[count:]
+	return l, nil
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	return d.fi, nil
+}
+
+func (d *dir) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.filename, Err: errIsDirectory}
+}
+
+func (d *dir) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.filename, Err: errIsDirectory}
+}
+
+// Seek only rewinds the listing of the directory.
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, &os.PathError{Op: "seek", Path: d.filename, Err: errIsDirectory}
+	}
+
+	d.entries, d.listed = nil, false
+	return 0, nil
+}
+
+func (d *dir) Close() error {
+	return nil
+}
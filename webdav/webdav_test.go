@@ -0,0 +1,129 @@
+package webdav
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+	. "gopkg.in/check.v1"
+	"srcd.works/go-billy.v1"
+	"srcd.works/go-billy.v1/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type WebDAVSuite struct {
+	fs   billy.Filesystem
+	fsys *FileSystem
+}
+
+var _ = Suite(&WebDAVSuite{})
+
+var _ webdav.FileSystem = &FileSystem{}
+
+var ctx = context.Background()
+
+func (s *WebDAVSuite) SetUpTest(c *C) {
+	s.fs = memory.New()
+	writeFile(c, s.fs, "qux/foo", "foo")
+	s.fsys = New(s.fs)
+}
+
+func (s *WebDAVSuite) TestMkdir(c *C) {
+	c.Assert(s.fsys.Mkdir(ctx, "/qux/bar", 0755), IsNil)
+	fi, err := s.fs.Stat("qux/bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	err = s.fsys.Mkdir(ctx, "/qux/bar", 0755)
+	c.Assert(os.IsExist(err), Equals, true)
+	err = s.fsys.Mkdir(ctx, "/missing/bar", 0755)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WebDAVSuite) TestOpenFile(c *C) {
+	_, err := s.fsys.OpenFile(ctx, "/missing/bar", os.O_RDWR|os.O_CREATE, 0666)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	d, err := s.fsys.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	c.Assert(err, IsNil)
+	l, err := d.Readdir(0)
+	c.Assert(err, IsNil)
+	c.Assert(l, HasLen, 1)
+	c.Assert(l[0].Name(), Equals, "qux")
+	c.Assert(d.Close(), IsNil)
+
+	_, err = s.fsys.OpenFile(ctx, "/qux", os.O_RDWR, 0)
+	c.Assert(err, NotNil)
+}
+
+func (s *WebDAVSuite) TestRemoveAll(c *C) {
+	c.Assert(s.fsys.RemoveAll(ctx, "/"), NotNil)
+	c.Assert(s.fsys.RemoveAll(ctx, "/qux"), IsNil)
+	_, err := s.fs.Stat("qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WebDAVSuite) TestHandler(c *C) {
+	srv := httptest.NewServer(NewHandler(s.fs, "/dav"))
+	defer srv.Close()
+
+	res := s.do(c, "PUT", srv.URL+"/dav/qux/bar", "bar")
+	c.Assert(res.StatusCode, Equals, http.StatusCreated)
+	c.Assert(readFile(c, s.fs, "qux/bar"), Equals, "bar")
+
+	res = s.do(c, "GET", srv.URL+"/dav/qux/foo", "")
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	body, err := ioutil.ReadAll(res.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "foo")
+
+	res = s.do(c, "MKCOL", srv.URL+"/dav/baz", "")
+	c.Assert(res.StatusCode, Equals, http.StatusCreated)
+
+	res = s.do(c, "PROPFIND", srv.URL+"/dav/qux", "")
+	c.Assert(res.StatusCode, Equals, http.StatusMultiStatus)
+	body, err = ioutil.ReadAll(res.Body)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(body), "/dav/qux/bar"), Equals, true)
+	c.Assert(strings.Contains(string(body), "/dav/qux/foo"), Equals, true)
+
+	res = s.do(c, "DELETE", srv.URL+"/dav/qux/foo", "")
+	c.Assert(res.StatusCode, Equals, http.StatusNoContent)
+	_, err = s.fs.Stat("qux/foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WebDAVSuite) do(c *C, method, url, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	c.Assert(err, IsNil)
+	if method == "PROPFIND" {
+		req.Header.Set("Depth", "1")
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	return res
+}
+
+func writeFile(c *C, fs billy.Filesystem, filename, content string) {
+	f, err := fs.Create(filename)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func readFile(c *C, fs billy.Filesystem, filename string) string {
+	f, err := fs.Open(filename)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return string(data)
+}